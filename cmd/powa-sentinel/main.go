@@ -4,19 +4,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
 	"github.com/powa-team/powa-sentinel/internal/engine"
+	"github.com/powa-team/powa-sentinel/internal/model"
 	"github.com/powa-team/powa-sentinel/internal/notifier"
 	"github.com/powa-team/powa-sentinel/internal/reader"
+	"github.com/powa-team/powa-sentinel/internal/retry"
 	"github.com/powa-team/powa-sentinel/internal/scheduler"
 	"github.com/powa-team/powa-sentinel/internal/server"
 )
@@ -33,6 +40,18 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	runOnce := flag.Bool("once", false, "Run analysis once and exit (skip scheduler)")
 	showVersion := flag.Bool("version", false, "Show version information")
+	configTest := flag.Bool("config-test", false, "Validate configuration and exit; with --fixture, print the resulting alert as JSON")
+	doctor := flag.Bool("doctor", false, "Diagnose common setup issues (connectivity, extensions, privileges, data freshness) and exit")
+	fixturePath := flag.String("fixture", "", "Read metrics from a JSON fixture instead of the database (requires --once or --config-test)")
+	rangeCurrent := flag.String("range-current", "", "Compare an explicit current range START/END (RFC 3339) against --range-baseline, then exit")
+	rangeBaseline := flag.String("range-baseline", "", "Baseline range START/END (RFC 3339) for --range-current")
+	failOnFindings := flag.Bool("fail-on-findings", false, "With --once or --range-current, exit non-zero after notifying if the alert has findings")
+	failOnSeverity := flag.String("fail-on-severity", "", "Like --fail-on-findings, but only for findings at or above this severity (info, low, medium, high, critical)")
+	failExitCode := flag.Int("fail-exit-code", 2, "Exit code used by --fail-on-findings and --fail-on-severity")
+	saveBaseline := flag.String("save-baseline", "", "Capture the current window's metrics to this file for rules.regression.baseline_file, then exit")
+	outputPath := flag.String("output", "", "With --once or --range-current, write the alert as JSON to this path (- for stdout) instead of notifying")
+	summaryJSON := flag.Bool("summary-json", false, "With --once or --range-current, print a one-line JSON summary of the run to stdout when it ends")
+	pprofAddr := flag.String("pprof-addr", "", "Debug only: serve net/http/pprof on this address, e.g. localhost:6060 (overrides server.pprof_addr)")
 	flag.Parse()
 
 	if *showVersion {
@@ -45,85 +64,244 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if *pprofAddr != "" {
+		cfg.Server.PprofAddr = *pprofAddr
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
+	for _, db := range cfg.Databases() {
+		for _, d := range []*config.DatabaseConfig{db, db.Monitored} {
+			if d != nil && d.PlaintextRemote() {
+				log.Printf("WARNING: database.sslmode is disable for non-loopback host %s; the password and query text are sent unencrypted", d.Host)
+			}
+		}
+	}
+	if soft, _ := cfg.Analysis.SoftTimeoutParsed(); soft >= scheduler.DefaultAnalysisTimeout {
+		log.Printf("WARNING: analysis.soft_timeout %s is not shorter than the %s analysis timeout; runs time out before returning partial findings", soft, scheduler.DefaultAnalysisTimeout)
+	}
 
-	log.Printf("powa-sentinel %s starting...", version)
+	if *fixturePath != "" && !*runOnce && !*configTest && *saveBaseline == "" {
+		log.Fatalf("--fixture requires --once, --config-test or --save-baseline")
+	}
 
-	// Initialize database reader
-	dbReader, err := reader.New(&cfg.Database)
-	if err != nil {
-		log.Fatalf("Failed to initialize database reader: %v", err)
+	if (*rangeCurrent == "") != (*rangeBaseline == "") {
+		log.Fatalf("--range-current and --range-baseline must be used together")
+	}
+	if *rangeCurrent != "" && *fixturePath != "" {
+		log.Fatalf("--range-current cannot be combined with --fixture")
+	}
+	gate := *failOnFindings || *failOnSeverity != ""
+	if gate && !*runOnce && *rangeCurrent == "" {
+		log.Fatalf("--fail-on-findings and --fail-on-severity require --once or --range-current")
+	}
+	if *failOnSeverity != "" && !engine.ValidSeverity(*failOnSeverity) {
+		log.Fatalf("Invalid --fail-on-severity %q: must be info, low, medium, high or critical", *failOnSeverity)
+	}
+	if gate && *failExitCode <= 0 {
+		log.Fatalf("--fail-exit-code must be positive")
+	}
+	if *outputPath != "" && !*runOnce && *rangeCurrent == "" {
+		log.Fatalf("--output requires --once or --range-current")
+	}
+	if *summaryJSON && !*runOnce && *rangeCurrent == "" {
+		log.Fatalf("--summary-json requires --once or --range-current")
+	}
+	started := time.Now()
+	// finishOnce ends a one-shot run once the alerts of every repository are
+	// sent, or written to --output: it prints the --summary-json line last, then
+	// fails with err or exits with the gate exit code
+	finishOnce := func(alerts []*model.AlertContext, err error) {
+		if *outputPath != "" && err == nil {
+			if werr := writeOutput(*outputPath, os.Stdout, alerts...); werr != nil {
+				err = fmt.Errorf("failed to write --output: %w", werr)
+			}
+		}
+		if *summaryJSON {
+			if werr := writeSummary(os.Stdout, summarize(alerts, time.Since(started), err)); werr != nil {
+				log.Printf("Failed to write --summary-json: %v", werr)
+			}
+		}
+		if err != nil {
+			log.Fatalf("Run failed: %v", err)
+		}
+		for _, alert := range alerts {
+			if gate && engine.HasFindings(alert, *failOnSeverity) {
+				log.Printf("Findings meet the failure threshold, exiting with code %d", *failExitCode)
+				os.Exit(*failExitCode)
+			}
+		}
 	}
-	defer dbReader.Close()
 
-	// Test database connection
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	if err := dbReader.Ping(ctx); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	var current, baseline model.TimeWindow
+	if *rangeCurrent != "" {
+		if current, err = parseRange(*rangeCurrent); err != nil {
+			log.Fatalf("Invalid --range-current: %v", err)
+		}
+		if baseline, err = parseRange(*rangeBaseline); err != nil {
+			log.Fatalf("Invalid --range-baseline: %v", err)
+		}
 	}
-	cancel()
-	log.Println("Database connection established")
 
-	// Initialize analysis engine
-	eng := engine.New(cfg, dbReader)
+	if *configTest {
+		if *fixturePath == "" {
+			fmt.Println("Configuration OK")
+			return
+		}
+		if err := runFixture(cfg, *fixturePath); err != nil {
+			log.Fatalf("Config test failed: %v", err)
+		}
+		return
+	}
 
-	// Initialize notifier
-	var notify notifier.Notifier
-	switch cfg.Notifier.Type {
-	case "wecom":
-		notify, err = notifier.NewWeComNotifier(&cfg.Notifier)
-		if err != nil {
-			log.Fatalf("Failed to initialize WeCom notifier: %v", err)
+	if *doctor {
+		if !runDoctor(cfg) {
+			os.Exit(1)
 		}
-	case "console":
-		notify = notifier.NewConsoleNotifier()
-	default:
-		log.Fatalf("Unknown notifier type: %s", cfg.Notifier.Type)
+		return
 	}
-	log.Printf("Notifier initialized: %s", notify.Name())
 
-	// Run-once mode
-	if *runOnce {
-		log.Println("Running single analysis (--once mode)")
+	log.Printf("powa-sentinel %s starting...", version)
+
+	// Run-once mode against a recorded fixture (no database connection)
+	if *fixturePath != "" {
+		fixtureReader, err := reader.NewFixtureReader(*fixturePath)
+		if err != nil {
+			log.Fatalf("Failed to load fixture: %v", err)
+		}
+		eng := engine.New(cfg, fixtureReader)
+		if *saveBaseline != "" {
+			saveBaselineAndExit(eng, *saveBaseline)
+			return
+		}
+		var notify notifier.Notifier
+		if *outputPath == "" {
+			notify = newNotifier(cfg, nil, false)
+		}
+		alert, err := runOnceAndExit(eng.Analyze, notify, cfg.Analysis.RetryBudget)
+		finishOnce([]*model.AlertContext{alert}, err)
+		return
+	}
 
-		// Use same timeout as scheduler would
-		analysisCtx, analysisCancel := context.WithTimeout(context.Background(), scheduler.DefaultAnalysisTimeout)
-		defer analysisCancel()
+	if *saveBaseline != "" && len(cfg.Repositories) > 1 {
+		log.Fatalf("--save-baseline captures a single repository, but database lists %d", len(cfg.Repositories))
+	}
 
-		alert, err := eng.Analyze(analysisCtx)
+	// Initialize a database reader and analysis engine per PoWA repository
+	healthServer := server.New(&cfg.Server, nil)
+	var repos []scheduler.Repository
+	for _, db := range cfg.Databases() {
+		prefix := ""
+		if db.Name != "" {
+			prefix = "[" + db.Name + "] "
+		}
+		dbReader, err := reader.New(db)
 		if err != nil {
-			if analysisCtx.Err() == context.DeadlineExceeded {
-				log.Fatalf("Analysis timed out after %v", scheduler.DefaultAnalysisTimeout)
-			}
-			log.Fatalf("Analysis failed: %v", err)
+			log.Fatalf("%sFailed to initialize database reader: %v", prefix, err)
 		}
+		defer dbReader.Close()
+		dbReader.SetDatabaseFilter(cfg.Analysis.SingleDatabase)
+		dbReader.SetMinImprovement(cfg.Rules.IndexSuggestion.MinImprovementPercent)
+		dbReader.SetMaxScanErrorRatio(cfg.Rules.IndexSuggestion.MaxScanErrorRatio)
+		dbReader.SetTailLatency(engine.NeedsStddev(cfg))
+		// --output writes the slow SQL findings with their kcache metrics
+		dbReader.SetKCacheEnrichment(engine.NeedsKCache(cfg) || *outputPath != "")
+		dbReader.SetSchemaFilter(cfg.Analysis.IncludeSchemas, cfg.Analysis.ExcludeSchemas)
+		dbReader.SetMaxQueryLength(cfg.Analysis.MaxQueryLength)
+		dbReader.SetSkipEmptyQueries(cfg.Analysis.SkipEmptyQueriesEnabled())
 
-		if err := notify.Send(analysisCtx, alert); err != nil {
-			if analysisCtx.Err() == context.DeadlineExceeded {
-				log.Fatalf("Notification timed out")
+		// Test database connection
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := dbReader.Ping(ctx); err != nil {
+			log.Fatalf("%sFailed to connect to database: %v", prefix, err)
+		}
+		// Compare database.expected_extensions with the repository before the first run
+		if len(db.ExpectedExtensions) > 0 {
+			if err := dbReader.CheckExpectedExtensions(ctx); err != nil {
+				log.Fatalf("%sEnvironment check failed: %v", prefix, err)
 			}
-			log.Fatalf("Notification failed: %v", err)
 		}
+		cancel()
+		log.Printf("%sDatabase connection established", prefix)
 
-		log.Println("Analysis complete, exiting")
+		eng := engine.New(cfg, dbReader)
+		eng.SetRepository(db.Name)
+		if db.Monitored != nil {
+			monitored := openMonitored(db.Monitored, prefix)
+			defer monitored.Close()
+			eng.SetInstance(monitored)
+		}
+		repos = append(repos, scheduler.Repository{Name: db.Name, Engine: eng})
+		healthServer.AddRepository(db.Name, dbReader)
+	}
+
+	if *saveBaseline != "" {
+		saveBaselineAndExit(repos[0].Engine, *saveBaseline)
 		return
 	}
 
-	// Initialize health server
-	healthServer := server.New(&cfg.Server, dbReader)
-	if err := healthServer.Start(); err != nil {
-		log.Fatalf("Failed to start health server: %v", err)
+	// Initialize notifiers: each repository is its own alert stream, so
+	// suppression, digests and escalation keep separate state. --output
+	// replaces them.
+	sendMetrics := notifier.NewSendMetrics()
+	if *outputPath == "" {
+		for i := range repos {
+			repos[i].Notifier = newNotifier(cfg, sendMetrics, *rangeCurrent == "" && !*runOnce)
+		}
+	}
+
+	// Explicit range comparison (post-deploy verification) or run-once mode,
+	// one repository after the other
+	if *rangeCurrent != "" || *runOnce {
+		var alerts []*model.AlertContext
+		for _, repo := range repos {
+			analyze := repo.Engine.Analyze
+			if *rangeCurrent != "" {
+				analyze = func(ctx context.Context) (*model.AlertContext, error) {
+					return repo.Engine.AnalyzeRange(ctx, current.Start, current.End, baseline.Start, baseline.End)
+				}
+			}
+			alert, err := runOnceAndExit(analyze, repo.Notifier, cfg.Analysis.RetryBudget)
+			alerts = append(alerts, alert)
+			if err != nil {
+				finishOnce(alerts, err)
+			}
+		}
+		finishOnce(alerts, nil)
+		return
 	}
 
 	// Initialize scheduler (cron interpreted in configured timezone; Location set by config.Validate)
-	sched := scheduler.New(eng, notify, cfg.Schedule.Location)
+	sched := scheduler.NewForRepositories(repos, cfg.Schedule.Location)
+	sched.SetConcurrency(cfg.Schedule.RepositoryConcurrency())
+	sched.SetRetryBudget(cfg.Analysis.RetryBudget)
+	if backoff, _ := cfg.Schedule.FailureBackoffParsed(); backoff > 0 {
+		backoffMax, _ := cfg.Schedule.FailureBackoffMaxParsed()
+		sched.SetFailureBackoff(backoff, backoffMax)
+	}
 	if err := sched.Schedule(cfg.Schedule.Cron); err != nil {
 		log.Fatalf("Failed to schedule job: %v", err)
 	}
+
+	// Initialize health server
+	healthServer.SetPauser(sched)
+	healthServer.SetSendMetrics(sendMetrics)
+	sched.SetAlertHook(healthServer.RecordAlert)
+	sched.SetHeartbeat(scheduler.NewHeartbeat(cfg.Heartbeat))
+	if interval, _ := cfg.Server.NotifierCheckIntervalParsed(); interval > 0 {
+		// Every repository shares the notifier endpoint: probing one chain is enough
+		if p, ok := notifier.ProberOf(repos[0].Notifier); ok {
+			healthServer.SetNotifierProbe(cfg.Notifier.Type, p, interval)
+		} else {
+			log.Printf("Notifier %s has no endpoint to probe; skipping notifier health check", cfg.Notifier.Type)
+		}
+	}
+	if err := healthServer.Start(); err != nil {
+		log.Fatalf("Failed to start health server: %v", err)
+	}
+
 	sched.Start()
 	log.Printf("Scheduler started with cron: %s (timezone: %s)", cfg.Schedule.Cron, cfg.Schedule.Timezone)
 
@@ -133,15 +311,14 @@ func main() {
 	sig := <-sigChan
 	log.Printf("Received signal %v, shutting down...", sig)
 
-	// Graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Graceful shutdown (timeout validated by config.Validate)
+	shutdownTimeout, _ := cfg.Server.ShutdownTimeoutParsed()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer shutdownCancel()
 
-	// Stop scheduler
-	schedCtx := sched.Stop()
-	select {
-	case <-schedCtx.Done():
-	case <-shutdownCtx.Done():
+	// Stop scheduler, letting an in-flight analysis finish within the budget
+	if err := sched.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Scheduler shutdown after %v: %v", shutdownTimeout, err)
 	}
 
 	// Stop health server
@@ -151,3 +328,276 @@ func main() {
 
 	log.Println("Shutdown complete")
 }
+
+// newNotifier builds the notifier selected by cfg.Notifier.Type, wrapped for
+// suppression, the notification threshold and escalation when configured, and for digests when digest is
+// set (scheduled runs only: a single run would never flush one). With metrics,
+// the primary and escalation notifiers each record their sends, labelled by
+// type (the escalation's prefixed "escalation:"). Both log every alert they are
+// given through a TeeNotifier; there is no alert store to tee to yet.
+func newNotifier(cfg *config.Config, metrics *notifier.SendMetrics, digest bool) notifier.Notifier {
+	build := func(nc *config.NotifierConfig, label string) notifier.Notifier {
+		var n notifier.Notifier = notifier.NewRedactingNotifier(notifier.NewTeeNotifier(buildNotifier(nc), nil))
+		if metrics != nil {
+			n = notifier.NewMeteredNotifier(n, label, metrics)
+		}
+		return n
+	}
+	notify := build(&cfg.Notifier, cfg.Notifier.Type)
+	if len(cfg.Notifier.Routes) > 0 {
+		// Routing comes first so suppression, thresholds and digests judge the whole alert
+		routes := make(map[string]notifier.Notifier, len(cfg.Notifier.Routes))
+		for severity, rc := range cfg.Notifier.Routes {
+			routes[severity] = build(rc, "route:"+severity+":"+rc.Type)
+		}
+		notify = notifier.NewRoutingNotifier(notify, routes)
+	}
+	if !cfg.Notifier.SendOnEmpty {
+		notify = notifier.NewNoDataSkippingNotifier(notify)
+	}
+	if cfg.Notifier.SuppressIfUnchanged {
+		forceInterval, err := cfg.Notifier.ForceIntervalParsed()
+		if err != nil {
+			log.Fatalf("Invalid notifier.force_interval: %v", err)
+		}
+		notify = notifier.NewSuppressingNotifier(notify, forceInterval)
+		log.Printf("Unchanged alerts are suppressed (forced re-send every %v)", forceInterval)
+	}
+	if n, sev := cfg.Notifier.MinFindings, cfg.Notifier.MinSeverity; n > 0 || sev != "" {
+		notify = notifier.NewThresholdNotifier(notify, n, sev)
+	}
+	if interval, err := cfg.Notifier.DigestIntervalParsed(); err != nil {
+		log.Fatalf("Invalid notifier.digest_interval: %v", err)
+	} else if interval > 0 && digest {
+		notify = notifier.NewDigestingNotifier(notify, interval, cfg.Notifier.DigestBreakthrough)
+		log.Printf("Alerts are sent as a digest every %v", interval)
+	}
+	if sev := cfg.Notifier.RecoverySeverity; sev != "" {
+		// Recoveries have no findings of their own, so they skip the threshold and digest
+		notify = notifier.NewRecoveringNotifier(notify, build(&cfg.Notifier, "recovery:"+cfg.Notifier.Type))
+		log.Printf("Findings at or above %s send a recovery notification when they clear", sev)
+	}
+	if esc := cfg.Notifier.Escalation; esc != nil {
+		// The escalation channel is never suppressed: each finding escalates once per streak
+		notify = notifier.NewEscalatingNotifier(notify, build(&esc.NotifierConfig, "escalation:"+esc.Type))
+		log.Printf("Critical findings persisting for %d run(s) are escalated", esc.AfterRuns)
+	}
+	log.Printf("Notifier initialized: %s", notify.Name())
+	return notify
+}
+
+// buildNotifier creates the notifier of type nc.Type.
+func buildNotifier(nc *config.NotifierConfig) notifier.Notifier {
+	switch nc.Type {
+	case "wecom":
+		notify, err := notifier.NewWeComNotifier(nc)
+		if err != nil {
+			log.Fatalf("Failed to initialize WeCom notifier: %v", err)
+		}
+		return notify
+	case "console":
+		return notifier.NewConsoleNotifier(nc)
+	case "csv":
+		return notifier.NewCSVNotifier(nc)
+	case "ndjson":
+		return notifier.NewNDJSONNotifier(nc)
+	case "syslog":
+		notify, err := notifier.NewSyslogNotifier(nc)
+		if err != nil {
+			log.Fatalf("Failed to initialize syslog notifier: %v", err)
+		}
+		return notify
+	case "kafka":
+		notify, err := notifier.NewKafkaNotifier(nc)
+		if err != nil {
+			log.Fatalf("Failed to initialize Kafka notifier: %v", err)
+		}
+		return notify
+	case "sns":
+		notify, err := notifier.NewSNSNotifier(nc)
+		if err != nil {
+			log.Fatalf("Failed to initialize SNS notifier: %v", err)
+		}
+		return notify
+	default:
+		log.Fatalf("Unknown notifier type: %s", nc.Type)
+		return nil
+	}
+}
+
+// openMonitored connects to the monitored instance of database.monitored. The
+// checks reading it log and skip their errors, so an instance that is down at
+// startup only logs a warning.
+func openMonitored(db *config.DatabaseConfig, prefix string) *reader.Reader {
+	monitored, err := reader.New(db)
+	if err != nil {
+		log.Fatalf("%sFailed to initialize monitored instance reader: %v", prefix, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := monitored.Ping(ctx); err != nil {
+		log.Printf("%sWARNING: cannot connect to the monitored instance %s: %v", prefix, db.Host, err)
+	} else {
+		log.Printf("%sMonitored instance connection established", prefix)
+	}
+	return monitored
+}
+
+// runOnceAndExit runs a single analysis and sends the result (--once mode), or
+// only returns it with a nil notify (--output). The analysis and the send share
+// one timeout and analysis.retry_budget, as in a scheduled run. It returns the
+// alert so callers can gate the exit code on its findings, along with the
+// analysis or notification failure the caller exits on; a failed send still
+// returns the alert.
+func runOnceAndExit(analyze func(context.Context) (*model.AlertContext, error), notify notifier.Notifier, retryBudget int) (*model.AlertContext, error) {
+	log.Println("Running single analysis (--once mode)")
+
+	// Use same timeout as scheduler would
+	analysisCtx, analysisCancel := context.WithTimeout(context.Background(), scheduler.DefaultAnalysisTimeout)
+	defer analysisCancel()
+	analysisCtx = retry.WithBudget(analysisCtx, retryBudget)
+
+	alert, err := analyze(analysisCtx)
+	if err != nil {
+		if analysisCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("analysis timed out after %v", scheduler.DefaultAnalysisTimeout)
+		}
+		return nil, fmt.Errorf("analysis failed: %w", err)
+	}
+
+	if notify == nil {
+		log.Println("Analysis complete, skipping notifiers")
+		return alert, nil
+	}
+	if err := notify.Send(analysisCtx, alert); err != nil {
+		if analysisCtx.Err() == context.DeadlineExceeded {
+			return alert, errors.New("notification timed out")
+		}
+		return alert, fmt.Errorf("notification failed: %w", err)
+	}
+
+	log.Println("Analysis complete, exiting")
+	return alert, nil
+}
+
+// writeOutput writes the alerts as indented JSON to path, or to stdout when
+// path is "-". Several repositories give one JSON document per alert, in
+// order, which jq and most JSON stream readers accept.
+func writeOutput(path string, stdout io.Writer, alerts ...*model.AlertContext) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	for _, alert := range alerts {
+		if err := enc.Encode(alert); err != nil {
+			return fmt.Errorf("encoding alert %s: %w", alert.ReqID, err)
+		}
+	}
+	if path == "-" {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d alert(s) to %s", len(alerts), path)
+	return nil
+}
+
+// saveBaselineAndExit captures the current metrics to path (--save-baseline mode).
+func saveBaselineAndExit(eng *engine.Engine, path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), scheduler.DefaultAnalysisTimeout)
+	defer cancel()
+
+	snap, err := eng.SaveBaseline(ctx, path)
+	if err != nil {
+		log.Fatalf("Saving baseline failed: %v", err)
+	}
+	log.Printf("Saved baseline of %d queries (%s ~ %s) to %s",
+		len(snap.Metrics), snap.Window.Start.Format(time.RFC3339), snap.Window.End.Format(time.RFC3339), path)
+}
+
+// parseRange parses a START/END pair of RFC 3339 timestamps.
+func parseRange(s string) (model.TimeWindow, error) {
+	startStr, endStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return model.TimeWindow{}, fmt.Errorf("expected START/END, got %q", s)
+	}
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(startStr))
+	if err != nil {
+		return model.TimeWindow{}, fmt.Errorf("parsing start: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(endStr))
+	if err != nil {
+		return model.TimeWindow{}, fmt.Errorf("parsing end: %w", err)
+	}
+	if !start.Before(end) {
+		return model.TimeWindow{}, fmt.Errorf("start %s must be before end %s", startStr, endStr)
+	}
+	return model.TimeWindow{Start: start, End: end}, nil
+}
+
+// runFixture analyzes a recorded fixture and prints the alert as JSON to stdout
+// so CI can assert which findings fire for the configured rule thresholds.
+func runFixture(cfg *config.Config, path string) error {
+	fixtureReader, err := reader.NewFixtureReader(path)
+	if err != nil {
+		return err
+	}
+
+	alert, err := engine.New(cfg, fixtureReader).Analyze(context.Background())
+	if err != nil {
+		return fmt.Errorf("analyzing fixture: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(alert)
+}
+
+// runDoctor prints a setup checklist for each repository and reports whether
+// all hard checks passed.
+func runDoctor(cfg *config.Config) bool {
+	passed := true
+	for i, db := range cfg.Databases() {
+		if db.Name != "" {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("== %s ==\n", db.Name)
+		}
+		passed = diagnoseRepository(cfg, db) && passed
+	}
+	return passed
+}
+
+// diagnoseRepository prints the setup checklist of one repository.
+func diagnoseRepository(cfg *config.Config, db *config.DatabaseConfig) bool {
+	dbReader, err := reader.New(db)
+	if err != nil {
+		fmt.Printf("[%s] Database reader: %v\n", reader.CheckFail, err)
+		return false
+	}
+	defer dbReader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Data older than one analysis window would leave the current window empty
+	staleAfter, _ := cfg.Analysis.WindowDurationParsed()
+	diagnosis := dbReader.Diagnose(ctx, staleAfter)
+
+	for _, c := range diagnosis {
+		line := fmt.Sprintf("[%s] %s", c.Status, c.Name)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Println(line)
+		if c.Hint != "" && c.Status != reader.CheckPass {
+			fmt.Printf("       hint: %s\n", c.Hint)
+		}
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n",
+		diagnosis.Count(reader.CheckPass), diagnosis.Count(reader.CheckWarn), diagnosis.Count(reader.CheckFail))
+
+	return !diagnosis.Failed()
+}
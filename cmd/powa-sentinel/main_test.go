@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestWriteOutput_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	alert := &model.AlertContext{ReqID: "r1", Summary: model.AlertSummary{RegressionCount: 2}}
+
+	var stdout bytes.Buffer
+	if err := writeOutput(path, &stdout, alert); err != nil {
+		t.Fatalf("writeOutput() error = %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("writing to a file must leave stdout alone, got %q", stdout.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got model.AlertContext
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("output is not an alert JSON document: %v\n%s", err, data)
+	}
+	if got.ReqID != "r1" || got.Summary.RegressionCount != 2 {
+		t.Errorf("decoded alert = %+v, want report r1 with 2 regressions", got)
+	}
+}
+
+func TestWriteOutput_Stdout(t *testing.T) {
+	var stdout bytes.Buffer
+	alerts := []*model.AlertContext{{ReqID: "r1", Repository: "eu"}, {ReqID: "r2", Repository: "us"}}
+	if err := writeOutput("-", &stdout, alerts...); err != nil {
+		t.Fatalf("writeOutput() error = %v", err)
+	}
+
+	// One document per repository, in order
+	dec := json.NewDecoder(strings.NewReader(stdout.String()))
+	for _, want := range alerts {
+		var got model.AlertContext
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("decoding alert %s: %v\n%s", want.ReqID, err, stdout.String())
+		}
+		if got.ReqID != want.ReqID || got.Repository != want.Repository {
+			t.Errorf("alert = %s/%s, want %s/%s", got.ReqID, got.Repository, want.ReqID, want.Repository)
+		}
+	}
+	if dec.More() {
+		t.Error("unexpected trailing output")
+	}
+}
+
+func TestWriteOutput_BadPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "report.json")
+	if err := writeOutput(path, &bytes.Buffer{}, &model.AlertContext{ReqID: "r1"}); err == nil {
+		t.Error("writeOutput() into a missing directory should fail")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID: "r1",
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, Severity: "critical"},
+			{QueryID: 2, Severity: "high"},
+			{QueryID: 3, Severity: "critical"},
+		},
+		CustomFindings: []model.CustomFinding{{Rule: "dead_tuples", Severity: "low"}},
+		CallSpikes:     []model.CallSpikeItem{{QueryID: 4}},
+	}
+
+	var stdout bytes.Buffer
+	if err := writeSummary(&stdout, summarize([]*model.AlertContext{alert}, 1500*time.Millisecond, nil)); err != nil {
+		t.Fatalf("writeSummary() error = %v", err)
+	}
+	line := stdout.String()
+	if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+		t.Fatalf("summary must be a single line, got %q", line)
+	}
+
+	var got struct {
+		Status     string         `json:"status"`
+		RunID      string         `json:"run_id"`
+		DurationMS int64          `json:"duration_ms"`
+		Findings   int            `json:"findings"`
+		Severities map[string]int `json:"severities"`
+		Errors     []string       `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatalf("summary is not valid JSON: %v\n%s", err, line)
+	}
+	if got.Status != "ok" || got.RunID != "r1" || got.DurationMS != 1500 || len(got.Errors) != 0 {
+		t.Errorf("summary = %+v, want ok run r1 of 1500ms without errors", got)
+	}
+	if got.Findings != 5 {
+		t.Errorf("findings = %d, want 5", got.Findings)
+	}
+	want := map[string]int{"critical": 2, "high": 1, "low": 1}
+	if !reflect.DeepEqual(got.Severities, want) {
+		t.Errorf("severities = %v, want %v", got.Severities, want)
+	}
+}
+
+func TestSummarize_Failure(t *testing.T) {
+	alerts := []*model.AlertContext{
+		{ReqID: "r1", Repository: "eu", StaleData: &model.StaleDataFinding{Severity: "medium"}},
+		{ReqID: "r2", Repository: "us"},
+	}
+	s := summarize(alerts, time.Second, errors.New("notification failed: boom"))
+
+	if s.Status != "failed" || len(s.Errors) != 1 || s.Errors[0] != "notification failed: boom" {
+		t.Errorf("status = %s, errors = %v, want failed with the notification error", s.Status, s.Errors)
+	}
+	if s.RunID != "" || len(s.Runs) != 2 {
+		t.Fatalf("several repositories should be listed per run, got run_id %q and %d runs", s.RunID, len(s.Runs))
+	}
+	if s.Runs[0].Repository != "eu" || s.Runs[0].Findings != 1 || s.Runs[1].Findings != 0 {
+		t.Errorf("runs = %+v, want 1 finding in eu and none in us", s.Runs)
+	}
+	if s.Severities["medium"] != 1 {
+		t.Errorf("severities = %v, want the stale data severity", s.Severities)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// runSummary is the single JSON line --summary-json prints when a one-shot
+// run ends, for wrappers that cannot read a chat notification.
+type runSummary struct {
+	// Status is "ok", or "failed" when the analysis or a notification failed.
+	Status string `json:"status"`
+
+	// RunID is the alert identifier of a single-repository run.
+	RunID string `json:"run_id,omitempty"`
+
+	// DurationMS is the wall time of the run in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+
+	// Findings counts every finding --fail-on-findings matches.
+	Findings int `json:"findings"`
+
+	// Severities counts the findings that carry a severity, by severity.
+	Severities map[string]int `json:"severities"`
+
+	// Errors lists the failures and truncation notes of the run.
+	Errors []string `json:"errors"`
+
+	// Runs breaks the counts down per repository when there are several.
+	Runs []repositorySummary `json:"runs,omitempty"`
+}
+
+// repositorySummary is the part of a runSummary about one repository's alert.
+type repositorySummary struct {
+	Repository string         `json:"repository"`
+	RunID      string         `json:"run_id"`
+	Findings   int            `json:"findings"`
+	Severities map[string]int `json:"severities"`
+}
+
+// summarize builds the summary of a one-shot run that produced alerts, in
+// repository order, and ended with err, if any.
+func summarize(alerts []*model.AlertContext, duration time.Duration, err error) runSummary {
+	s := runSummary{
+		Status:     "ok",
+		DurationMS: duration.Milliseconds(),
+		Severities: map[string]int{},
+		Errors:     []string{},
+	}
+	for _, alert := range alerts {
+		if alert == nil {
+			continue
+		}
+		r := repositorySummary{Repository: alert.Repository, RunID: alert.ReqID, Severities: map[string]int{}}
+		countFindings(alert, &r)
+		s.Findings += r.Findings
+		for severity, n := range r.Severities {
+			s.Severities[severity] += n
+		}
+		if alert.Truncated != nil {
+			s.Errors = append(s.Errors, alert.Truncated.Note())
+		}
+		s.Runs = append(s.Runs, r)
+	}
+	if len(s.Runs) == 1 {
+		s.RunID = s.Runs[0].RunID
+		s.Runs = nil
+	}
+	if err != nil {
+		s.Status = "failed"
+		s.Errors = append(s.Errors, err.Error())
+	}
+	return s
+}
+
+// countFindings fills r with the findings of alert, counted like
+// engine.HasFindings sees them.
+func countFindings(alert *model.AlertContext, r *repositorySummary) {
+	severity := func(s string) {
+		if s != "" {
+			r.Severities[s]++
+		}
+	}
+	for _, f := range alert.Regressions {
+		severity(f.Severity)
+	}
+	for _, f := range alert.CustomFindings {
+		severity(f.Severity)
+	}
+	r.Findings = len(alert.Regressions) + len(alert.CustomFindings) + len(alert.CallSpikes) + len(alert.WaitEvents) +
+		len(alert.Flapping) + len(alert.CrossServer) + len(alert.Vacuum) + len(alert.Suggestions)
+	if alert.StaleData != nil {
+		r.Findings++
+		severity(alert.StaleData.Severity)
+	}
+	if alert.WorkloadGrowth != nil {
+		r.Findings++
+	}
+	if alert.ConnectionSaturation != nil {
+		r.Findings++
+	}
+}
+
+// writeSummary writes s to w as one line of JSON.
+func writeSummary(w io.Writer, s runSummary) error {
+	return json.NewEncoder(w).Encode(s)
+}
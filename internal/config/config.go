@@ -3,54 +3,352 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"math/bits"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the complete application configuration.
 type Config struct {
 	Database DatabaseConfig `yaml:"database"`
+	// Repositories replace Database when the database section is a list, one
+	// entry per PoWA repository; see Databases.
+	Repositories []DatabaseConfig `yaml:"-"`
+
 	Schedule ScheduleConfig `yaml:"schedule"`
 	Analysis AnalysisConfig `yaml:"analysis"`
 	Rules    RulesConfig    `yaml:"rules"`
 	Notifier NotifierConfig `yaml:"notifier"`
 	Server   ServerConfig   `yaml:"server"`
+
+	// Environment names the deployment (e.g. staging, prod); webhook URLs can
+	// refer to it as {{.Env}}, so one file serves every environment.
+	Environment string `yaml:"environment"`
+
+	// Heartbeat pings an external dead-man's-switch monitor after each run.
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+
+	// Labels are attached to every alert and finding (e.g. env, team) for downstream routing.
+	Labels map[string]string `yaml:"labels"`
+	// DatabaseLabels override or extend Labels for findings of a given database name.
+	DatabaseLabels map[string]map[string]string `yaml:"database_labels"`
+	// DatabaseOverrides replace rule thresholds for findings of a given database name.
+	DatabaseOverrides map[string]RuleOverrides `yaml:"database_overrides"`
+}
+
+// UnmarshalYAML accepts the database section as one repository (a mapping) or
+// several (a list of mappings, each with a name).
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	type plain Config // without this method
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if value.Content[i].Value != "database" || value.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+			if err := value.Content[i+1].Decode(&c.Repositories); err != nil {
+				return err
+			}
+			if len(c.Repositories) == 0 {
+				return fmt.Errorf("line %d: database list is empty", value.Content[i+1].Line)
+			}
+			rest := *value
+			rest.Content = append(slices.Clone(value.Content[:i]), value.Content[i+2:]...)
+			value = &rest
+			break
+		}
+	}
+	return value.Decode((*plain)(c))
+}
+
+// Databases returns the PoWA repositories to analyze: every entry of a
+// database list, or the single database mapping.
+func (c *Config) Databases() []*DatabaseConfig {
+	if len(c.Repositories) == 0 {
+		return []*DatabaseConfig{&c.Database}
+	}
+	dbs := make([]*DatabaseConfig, len(c.Repositories))
+	for i := range c.Repositories {
+		dbs[i] = &c.Repositories[i]
+	}
+	return dbs
+}
+
+// databaseKey is the config path of the i-th repository in error messages.
+func (c *Config) databaseKey(i int) string {
+	if len(c.Repositories) == 0 {
+		return "database"
+	}
+	return fmt.Sprintf("database[%d]", i)
 }
 
 // DatabaseConfig holds PostgreSQL connection settings.
 type DatabaseConfig struct {
+	Name               string   `yaml:"name"` // repository name alerts are tagged with; required for each entry of a database list
 	Host               string   `yaml:"host"`
 	Port               int      `yaml:"port"`
 	User               string   `yaml:"user"`
 	Password           string   `yaml:"password"`
+	PasswordFile       string   `yaml:"password_file"` // read password from this file at load time (Docker/Kubernetes secrets)
 	DBName             string   `yaml:"dbname"`
 	SSLMode            string   `yaml:"sslmode"`
 	ExpectedExtensions []string `yaml:"expected_extensions"` // optional: compare with actual and log mismatches (env expectation check)
+	StrictExtensions   bool     `yaml:"strict_extensions"`   // fail at startup instead of warning when an expected extension is missing
+	IAMAuth            bool     `yaml:"iam_auth"`            // use a short-lived AWS RDS IAM auth token as password (requires -tags rdsiam build)
+	AWSRegion          string   `yaml:"aws_region"`          // region for IAM token signing; empty uses the AWS SDK default chain
+	ApplicationName    string   `yaml:"application_name"`    // reported in pg_stat_activity for auditing
+
+	InitSQL []string `yaml:"init_sql"` // SET/SELECT statements run on every new connection, e.g. SET search_path
+
+	MaxConcurrentQueries int    `yaml:"max_concurrent_queries"` // reader calls in flight at once; 0 means DatabasePoolSize
+	AcquireTimeout       string `yaml:"acquire_timeout"`        // fail a reader call that waits longer for a connection (empty = wait for its context)
+
+	Retries    int    `yaml:"retries"`     // retry metrics queries that fail on a lost or refused connection this many times (0 = never)
+	RetryDelay string `yaml:"retry_delay"` // wait before the first retry, doubled for each next one
+
+	UseCursor       bool `yaml:"use_cursor"`        // read metrics through a server-side cursor instead of one result set
+	CursorBatchSize int  `yaml:"cursor_batch_size"` // rows fetched from the cursor at a time; 0 means DefaultCursorBatchSize
+
+	MaxQueryRows int `yaml:"max_query_rows"` // LIMIT of the metrics, wait event and table statistics queries; 0 means DefaultMaxQueryRows
+
+	// Monitored connects to the monitored PostgreSQL instance itself, for the
+	// checks that read its live state (pg_stat_activity, settings) rather than
+	// PoWA's history. Nil skips those checks.
+	Monitored *DatabaseConfig `yaml:"monitored"`
+}
+
+// DatabasePoolSize is the number of connections the reader keeps open at most.
+const DatabasePoolSize = 5
+
+// QueryConcurrency returns how many reader calls may run at once: MaxConcurrentQueries,
+// or the pool size when it is not set.
+func (d *DatabaseConfig) QueryConcurrency() int {
+	if d.MaxConcurrentQueries > 0 {
+		return d.MaxConcurrentQueries
+	}
+	return DatabasePoolSize
+}
+
+// AcquireTimeoutParsed returns the parsed acquire timeout; empty disables it.
+func (d *DatabaseConfig) AcquireTimeoutParsed() (time.Duration, error) {
+	if d.AcquireTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(d.AcquireTimeout)
+}
+
+// DefaultDatabaseRetryDelay is the wait before the first metrics query retry
+// when database.retry_delay is not set.
+const DefaultDatabaseRetryDelay = time.Second
+
+// RetryDelayParsed returns the parsed delay before the first metrics query
+// retry; empty means DefaultDatabaseRetryDelay.
+func (d *DatabaseConfig) RetryDelayParsed() (time.Duration, error) {
+	if d.RetryDelay == "" {
+		return DefaultDatabaseRetryDelay, nil
+	}
+	return time.ParseDuration(d.RetryDelay)
+}
+
+// DefaultCursorBatchSize is the number of rows fetched from a metrics cursor
+// at a time when database.cursor_batch_size is not set.
+const DefaultCursorBatchSize = 1000
+
+// CursorBatch returns how many rows to fetch from a metrics cursor at a time:
+// CursorBatchSize, or DefaultCursorBatchSize when it is not set.
+func (d *DatabaseConfig) CursorBatch() int {
+	if d.CursorBatchSize > 0 {
+		return d.CursorBatchSize
+	}
+	return DefaultCursorBatchSize
+}
+
+// DefaultMaxQueryRows is the row limit of the reader's metrics, wait event and
+// table statistics queries when database.max_query_rows is not set.
+const DefaultMaxQueryRows = 10000
+
+// MaxQueryRowsLimit bounds database.max_query_rows, so a typo cannot make a
+// run load a whole repository into memory.
+const MaxQueryRowsLimit = 1000000
+
+// QueryRowLimit returns the row limit of the reader's bulk queries:
+// MaxQueryRows, or DefaultMaxQueryRows when it is not set.
+func (d *DatabaseConfig) QueryRowLimit() int {
+	if d.MaxQueryRows > 0 {
+		return d.MaxQueryRows
+	}
+	return DefaultMaxQueryRows
+}
+
+// DefaultApplicationName labels powa-sentinel sessions in pg_stat_activity.
+const DefaultApplicationName = "powa-sentinel"
+
+// SSLModes lists the accepted database.sslmode values, as defined by libpq.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// PlaintextRemote reports whether SSL is disabled for a host other than the
+// loopback interface or a Unix socket, so credentials cross the network unencrypted.
+func (d *DatabaseConfig) PlaintextRemote() bool {
+	if d.SSLMode != "disable" || d.Host == "" || strings.HasPrefix(d.Host, "/") || d.Host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(d.Host)
+	return ip == nil || !ip.IsLoopback()
 }
 
 // DSN returns the PostgreSQL connection string.
 func (d *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		d.Host, d.Port, d.User, d.Password, d.DBName, d.SSLMode,
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s %s",
+		d.Host, d.Port, d.User, d.Password, d.DBName, d.SSLMode, d.SessionParams(),
 	)
 }
 
+// SessionParams returns the connection parameters every session carries: the
+// application_name for auditing and default_transaction_read_only, so the
+// server rejects writes whatever statement future code sends.
+func (d *DatabaseConfig) SessionParams() string {
+	name := d.ApplicationName
+	if name == "" {
+		name = DefaultApplicationName
+	}
+	return fmt.Sprintf("application_name=%s default_transaction_read_only=on", QuoteDSNValue(name))
+}
+
+// QuoteDSNValue quotes a libpq key/value connection string value so values
+// containing spaces, quotes or backslashes are passed through unchanged.
+func QuoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
 // ScheduleConfig defines when analysis jobs run.
 type ScheduleConfig struct {
 	Cron     string         `yaml:"cron"`
 	Timezone string         `yaml:"timezone"` // IANA name (e.g. UTC, Asia/Shanghai); cron is interpreted in this zone
 	Location *time.Location `yaml:"-"`        // set during Validate(); use this to avoid parsing timezone twice
+
+	Concurrency int `yaml:"concurrency"` // repositories of a database list analyzed at once on each tick; 0 means DefaultScheduleConcurrency
+
+	FailureBackoff    string `yaml:"failure_backoff"`     // ticks skipped after a repository's run fails, doubled per consecutive failure (empty = never skip)
+	FailureBackoffMax string `yaml:"failure_backoff_max"` // cap on the failure backoff; empty means DefaultFailureBackoffMax
+}
+
+// DefaultFailureBackoffMax caps the failure backoff when
+// schedule.failure_backoff_max is unset.
+const DefaultFailureBackoffMax = time.Hour
+
+// FailureBackoffParsed returns the parsed initial failure backoff; empty disables it.
+func (s *ScheduleConfig) FailureBackoffParsed() (time.Duration, error) {
+	if s.FailureBackoff == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s.FailureBackoff)
+}
+
+// FailureBackoffMaxParsed returns the parsed failure backoff cap, falling back
+// to DefaultFailureBackoffMax.
+func (s *ScheduleConfig) FailureBackoffMaxParsed() (time.Duration, error) {
+	if s.FailureBackoffMax == "" {
+		return DefaultFailureBackoffMax, nil
+	}
+	return time.ParseDuration(s.FailureBackoffMax)
+}
+
+// DefaultScheduleConcurrency bounds how many repositories are analyzed at once
+// when schedule.concurrency is unset.
+const DefaultScheduleConcurrency = 2
+
+// RepositoryConcurrency returns how many repositories may be analyzed at once.
+func (s *ScheduleConfig) RepositoryConcurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return DefaultScheduleConcurrency
 }
 
-// AnalysisConfig defines analysis time windows.
+// AnalysisConfig defines analysis time windows and query text handling.
 type AnalysisConfig struct {
-	WindowDuration   string `yaml:"window_duration"`
-	ComparisonOffset string `yaml:"comparison_offset"`
+	WindowDuration   string   `yaml:"window_duration"`
+	ComparisonOffset string   `yaml:"comparison_offset"`
+	WindowMode       string   `yaml:"window_mode"`      // "fixed" (default) or "since_last_run"
+	MaxWindow        string   `yaml:"max_window"`       // upper bound for since_last_run windows; defaults to window_duration
+	SingleDatabase   string   `yaml:"single_database"`  // analyze only this monitored database (empty = all)
+	MaxDataAge       string   `yaml:"max_data_age"`     // report stale data when the newest snapshot is older (empty = no check)
+	SoftTimeout      string   `yaml:"soft_timeout"`     // stop starting rules after this long and send partial findings (empty = off)
+	DisplayTimezone  string   `yaml:"display_timezone"` // IANA name used to render timestamps in alert text (empty = server local)
+	RedactQueries    bool     `yaml:"redact_queries"`   // replace string/numeric literals in query text with ***
+	RedactPatterns   []string `yaml:"redact_patterns"`  // extra regexes; matches are replaced with ***
+	MaxQueryLength   int      `yaml:"max_query_length"` // truncate query text to this many bytes (0 = no limit)
+	SilenceFile      string   `yaml:"silence_file"`     // YAML list of silenced queryids/patterns, re-read every run (empty = off)
+
+	SkipEmptyQueries *bool `yaml:"skip_empty_queries"` // drop statements PoWA has no query text for yet (default true); false shows a placeholder
+
+	// Guard the repository against a misconfigured lookback, e.g. a year-long window
+	MaxWindowDuration   string `yaml:"max_window_duration"`   // upper bound for window_duration, max_window and --range-* spans (empty = no limit)
+	MaxComparisonOffset string `yaml:"max_comparison_offset"` // upper bound for comparison_offset (empty = no limit)
+
+	AllowlistQueryIDs []int64  `yaml:"allowlist_queryids"` // queries never analyzed or reported, e.g. reports that are slow by design
+	AllowlistPatterns []string `yaml:"allowlist_patterns"` // regexes on query text; matching queries are never analyzed or reported
+
+	Weights     SignificanceWeights `yaml:"weights"`      // ranks findings across rules; all zero keeps per-rule ordering
+	MaxFindings int                 `yaml:"max_findings"` // cap on findings in the alert body, least significant dropped first (0 = no cap)
+
+	TopDatabases int `yaml:"top_databases"` // list the N databases with the most total execution time (0 = off)
+	TopUsers     int `yaml:"top_users"`     // list the N roles with the most total execution time (0 = off)
+
+	GroupByUser bool `yaml:"group_by_user"` // rank and compare each query per role instead of merging its roles' rows
+
+	FetchParallelism int `yaml:"fetch_parallelism"` // independent metric windows fetched at once, within database.max_concurrent_queries (0 = one after another)
+
+	RetryBudget int `yaml:"retry_budget"` // retries one run may spend across reader and notifier, within the analysis timeout (0 = bounded by the timeout only)
+
+	IncludeSchemas []string `yaml:"include_schemas"` // keep only index suggestions on tables in these schemas (empty = all)
+	ExcludeSchemas []string `yaml:"exclude_schemas"` // drop index suggestions on tables in these schemas
+
+	DisplayLocation *time.Location `yaml:"-"` // set during Validate() from DisplayTimezone
+}
+
+// SkipEmptyQueriesEnabled reports whether statements without query text are
+// dropped, which is the default.
+func (a *AnalysisConfig) SkipEmptyQueriesEnabled() bool {
+	return a.SkipEmptyQueries == nil || *a.SkipEmptyQueries
+}
+
+// SignificanceWeights weights each normalized metric when ranking findings across rules.
+type SignificanceWeights struct {
+	TotalTime         float64 `yaml:"total_time"`         // share of total execution time (slow SQL, regressions)
+	RegressionPercent float64 `yaml:"regression_percent"` // mean time increase (regressions)
+	AffectedQueries   float64 `yaml:"affected_queries"`   // queries that would benefit (index suggestions)
+}
+
+// IsZero reports whether no weight is configured.
+func (w SignificanceWeights) IsZero() bool {
+	return w.TotalTime == 0 && w.RegressionPercent == 0 && w.AffectedQueries == 0
+}
+
+// SchemaAllowed reports whether findings on a table in schema pass
+// include_schemas and exclude_schemas. An empty schema is "public".
+func (a *AnalysisConfig) SchemaAllowed(schema string) bool {
+	if schema == "" {
+		schema = "public"
+	}
+	if len(a.IncludeSchemas) > 0 && !slices.Contains(a.IncludeSchemas, schema) {
+		return false
+	}
+	return !slices.Contains(a.ExcludeSchemas, schema)
 }
 
 // WindowDurationParsed returns the parsed window duration.
@@ -63,212 +361,1349 @@ func (a *AnalysisConfig) ComparisonOffsetParsed() (time.Duration, error) {
 	return time.ParseDuration(a.ComparisonOffset)
 }
 
+// Analysis window modes.
+const (
+	WindowModeFixed        = "fixed"
+	WindowModeSinceLastRun = "since_last_run"
+)
+
+// SoftTimeoutParsed returns the parsed soft timeout; empty disables it.
+func (a *AnalysisConfig) SoftTimeoutParsed() (time.Duration, error) {
+	if a.SoftTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(a.SoftTimeout)
+}
+
+// MaxDataAgeParsed returns the parsed maximum data age; empty disables the check.
+func (a *AnalysisConfig) MaxDataAgeParsed() (time.Duration, error) {
+	if a.MaxDataAge == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(a.MaxDataAge)
+}
+
+// MaxWindowDurationParsed returns the parsed window length cap; empty disables it.
+func (a *AnalysisConfig) MaxWindowDurationParsed() (time.Duration, error) {
+	if a.MaxWindowDuration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(a.MaxWindowDuration)
+}
+
+// MaxComparisonOffsetParsed returns the parsed maximum comparison offset; empty disables the cap.
+func (a *AnalysisConfig) MaxComparisonOffsetParsed() (time.Duration, error) {
+	if a.MaxComparisonOffset == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(a.MaxComparisonOffset)
+}
+
+// MaxWindowParsed returns the parsed maximum window, falling back to the window duration.
+func (a *AnalysisConfig) MaxWindowParsed() (time.Duration, error) {
+	if a.MaxWindow == "" {
+		return a.WindowDurationParsed()
+	}
+	return time.ParseDuration(a.MaxWindow)
+}
+
 // RulesConfig contains all rule configurations.
 type RulesConfig struct {
 	SlowSQL         SlowSQLRuleConfig         `yaml:"slow_sql"`
 	Regression      RegressionRuleConfig      `yaml:"regression"`
 	IndexSuggestion IndexSuggestionRuleConfig `yaml:"index_suggestion"`
+	CallSpike       CallSpikeRuleConfig       `yaml:"call_spike"`
+	Waits           WaitsRuleConfig           `yaml:"waits"`
+	Flapping        FlappingRuleConfig        `yaml:"flapping"`
+	Vacuum          VacuumRuleConfig          `yaml:"vacuum"`
+	CrossServer     CrossServerRuleConfig     `yaml:"cross_server"`
+	WorkloadGrowth  WorkloadGrowthRuleConfig  `yaml:"workload_growth"`
+	Connections     ConnectionsRuleConfig     `yaml:"connections"`
+	Custom          []CustomRuleConfig        `yaml:"custom"`
+}
+
+// Cooldowns returns the cooldown of each rule that sets one, keyed by the
+// rule's config name (slow_sql, regression, ...); custom rules are keyed by
+// their name as "custom:<name>". Values are parsed durations; Validate rejects
+// invalid and negative ones.
+func (r *RulesConfig) Cooldowns() map[string]time.Duration {
+	raw := map[string]string{
+		"slow_sql":         r.SlowSQL.Cooldown,
+		"regression":       r.Regression.Cooldown,
+		"index_suggestion": r.IndexSuggestion.Cooldown,
+		"call_spike":       r.CallSpike.Cooldown,
+		"waits":            r.Waits.Cooldown,
+		"flapping":         r.Flapping.Cooldown,
+		"vacuum":           r.Vacuum.Cooldown,
+		"cross_server":     r.CrossServer.Cooldown,
+		"workload_growth":  r.WorkloadGrowth.Cooldown,
+		"connections":      r.Connections.Cooldown,
+	}
+	for _, c := range r.Custom {
+		raw["custom:"+c.Name] = c.Cooldown
+	}
+	cooldowns := make(map[string]time.Duration)
+	for rule, s := range raw {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			cooldowns[rule] = d
+		}
+	}
+	return cooldowns
 }
 
 // SlowSQLRuleConfig defines slow SQL detection parameters.
 type SlowSQLRuleConfig struct {
-	TopN   int    `yaml:"top_n"`
-	RankBy string `yaml:"rank_by"`
+	TopN   int        `yaml:"top_n"`
+	RankBy RankByList `yaml:"rank_by"` // one metric, or several ranked in turn, each "metric" or "metric:N"
+
+	IncludeExplain bool   `yaml:"include_explain"` // attach the EXPLAIN (never ANALYZE) plan of the worst slow queries, planned on database.monitored
+	MaxExplains    int    `yaml:"max_explains"`    // slow queries explained per run
+	ExplainTimeout string `yaml:"explain_timeout"` // give up on a plan after this long
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
+
+// ExplainTimeoutParsed returns explain_timeout as a duration; empty means the
+// plan is only bounded by the analysis timeout.
+func (s *SlowSQLRuleConfig) ExplainTimeoutParsed() (time.Duration, error) {
+	if s.ExplainTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s.ExplainTimeout)
+}
+
+// SlowQueryMetrics are the metrics rules.slow_sql.rank_by can rank by.
+var SlowQueryMetrics = []string{"total_time", "mean_time", "cpu_time", "io_time"}
+
+// RankByList is rules.slow_sql.rank_by: a single metric, a comma-separated
+// string such as the RULES_SLOW_SQL_RANK_BY override, or a YAML list.
+type RankByList []string
+
+// UnmarshalYAML accepts a scalar as well as a list.
+func (l *RankByList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		var items []string
+		if err := value.Decode(&items); err != nil {
+			return err
+		}
+		*l = items
+		return nil
+	}
+	*l = nil
+	for _, item := range strings.Split(value.Value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// SlowSQLRanking is one entry of rules.slow_sql.rank_by.
+type SlowSQLRanking struct {
+	Metric string // one of SlowQueryMetrics
+	TopN   int    // queries listed for this metric
+}
+
+// Rankings returns the rank_by entries in order. An entry without ":N" lists
+// top_n queries; entries that do not parse are left to Validate.
+func (s *SlowSQLRuleConfig) Rankings() []SlowSQLRanking {
+	rankings := make([]SlowSQLRanking, 0, len(s.RankBy))
+	for _, entry := range s.RankBy {
+		if r, err := parseRanking(entry, s.TopN); err == nil {
+			rankings = append(rankings, r)
+		}
+	}
+	return rankings
+}
+
+// parseRanking parses a "metric" or "metric:N" rank_by entry.
+func parseRanking(entry string, topN int) (SlowSQLRanking, error) {
+	metric, count, hasCount := strings.Cut(entry, ":")
+	r := SlowSQLRanking{Metric: strings.TrimSpace(metric), TopN: topN}
+	if !slices.Contains(SlowQueryMetrics, r.Metric) {
+		return r, fmt.Errorf("must be one of: %s", strings.Join(SlowQueryMetrics, ", "))
+	}
+	if hasCount {
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n < 1 {
+			return r, fmt.Errorf("count %q must be a positive integer", count)
+		}
+		r.TopN = n
+	}
+	return r, nil
 }
 
 // RegressionRuleConfig defines regression detection parameters.
 type RegressionRuleConfig struct {
 	ThresholdPercent float64 `yaml:"threshold_percent"`
+	IgnoreNewQueries bool    `yaml:"ignore_new_queries"` // drop queries with no usable baseline instead of reporting them as "new query"
+	WarmupRuns       int     `yaml:"warmup_runs"`        // report regressions as info during the first N runs while baselines are thin
+
+	BaselineFile  string `yaml:"baseline_file"`  // compare against a snapshot written by --save-baseline instead of comparison_offset
+	BaselineScope string `yaml:"baseline_scope"` // "current_queries" (default) or "window"; which baseline queries are fetched
+
+	Metric     string  `yaml:"metric"`      // "mean" (default) or "tail": compare mean + tail_factor standard deviations where PoWA records them
+	TailFactor float64 `yaml:"tail_factor"` // standard deviations added to the mean for the tail metric
+
+	MinQueryAge string `yaml:"min_query_age"` // lower regressions of queries first seen more recently than this by one severity; empty disables
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
 }
 
-// IndexSuggestionRuleConfig defines index suggestion filtering.
-type IndexSuggestionRuleConfig struct {
-	MinImprovementPercent float64 `yaml:"min_improvement_percent"`
+// MinQueryAgeParsed returns the parsed minimum query age; empty disables it.
+func (r *RegressionRuleConfig) MinQueryAgeParsed() (time.Duration, error) {
+	if r.MinQueryAge == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.MinQueryAge)
 }
 
-// NotifierConfig holds notification channel settings.
-type NotifierConfig struct {
-	Type       string `yaml:"type"`
-	WebhookURL string `yaml:"webhook_url"`
-	Retries    int    `yaml:"retries"`
-	RetryDelay string `yaml:"retry_delay"`
+// Baseline scopes: fetch the baseline of the current window's queries only, or
+// the baseline window's own top queries.
+const (
+	BaselineScopeCurrentQueries = "current_queries"
+	BaselineScopeWindow         = "window"
+)
+
+// Regression metrics: compare the mean execution time, or a tail estimate of
+// mean + tail_factor standard deviations.
+const (
+	RegressionMetricMean = "mean"
+	RegressionMetricTail = "tail"
+)
+
+// DefaultTailFactor puts the tail estimate near the 95th percentile of a
+// normal distribution.
+const DefaultTailFactor = 1.645
+
+// CallSpikeRuleConfig defines call-volume spike detection (retry storms, N+1).
+// The rule is disabled when ThresholdPercent is 0.
+type CallSpikeRuleConfig struct {
+	ThresholdPercent float64 `yaml:"threshold_percent"` // min % increase in calls over the baseline
+	MinCalls         int64   `yaml:"min_calls"`         // ignore queries with fewer calls in the current window
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
 }
 
-// RetryDelayParsed returns the parsed retry delay duration.
-func (n *NotifierConfig) RetryDelayParsed() (time.Duration, error) {
-	return time.ParseDuration(n.RetryDelay)
+// CrossServerRuleConfig compares each query on every other PoWA server with the
+// same query on a reference server in the same window (e.g. green against
+// blue). The rule is disabled when ThresholdPercent is 0.
+type CrossServerRuleConfig struct {
+	ReferenceSrvID   int     `yaml:"reference_srvid"`   // PoWA srvid of the reference server (0 is the local server)
+	ThresholdPercent float64 `yaml:"threshold_percent"` // min % by which the query's mean time exceeds the reference
+	MinCalls         int64   `yaml:"min_calls"`         // ignore queries with fewer calls on either server
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
 }
 
-// ServerConfig holds HTTP server settings.
-type ServerConfig struct {
-	Port      int  `yaml:"port"`
-	DeepCheck bool `yaml:"deep_check"`
+// WorkloadGrowthRuleConfig compares the total execution time of all queries in
+// the current window against the baseline. The rule is disabled when
+// ThresholdPercent is 0.
+type WorkloadGrowthRuleConfig struct {
+	ThresholdPercent float64 `yaml:"threshold_percent"` // min % growth of total time per second of window
+	TopContributors  int     `yaml:"top_contributors"`  // queries listed as adding the most time (default 5)
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
 }
 
-// Load reads and parses the configuration file.
-func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
-	}
+// ConnectionsRuleConfig compares the client connections of the monitored
+// instance against its max_connections. The rule is disabled when MaxPercent is
+// 0 and skipped without a database.monitored connection.
+type ConnectionsRuleConfig struct {
+	MaxPercent float64 `yaml:"max_percent"` // fire when connections exceed this % of max_connections
 
-	// Expand environment variables
-	expanded := expandEnvVars(string(data))
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config file: %w", err)
-	}
+// WaitsRuleConfig defines lock/IO wait detection from pg_wait_sampling. The rule
+// is disabled when MinPercent is 0 and skipped when PoWA does not collect waits.
+type WaitsRuleConfig struct {
+	MinPercent    float64 `yaml:"min_percent"`    // min share of a query's execution time spent on Lock and IO waits
+	ProfilePeriod string  `yaml:"profile_period"` // pg_wait_sampling.profile_period of the monitored instances (default 10ms)
 
-	// Apply defaults
-	applyDefaults(&cfg)
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
 
-	return &cfg, nil
+// ProfilePeriodParsed returns the sampling period that converts samples to wait time.
+func (w *WaitsRuleConfig) ProfilePeriodParsed() (time.Duration, error) {
+	return time.ParseDuration(w.ProfilePeriod)
 }
 
-// expandEnvVars expands ${VAR} and ${VAR:-default} patterns in the input string.
-func expandEnvVars(input string) string {
-	// Pattern: ${VAR:-default} or ${VAR}
-	re := regexp.MustCompile(`\$\{([^}:]+)(?::-([^}]*))?\}`)
+// FlappingRuleConfig defines detection of queries whose mean time oscillates
+// across the last Windows analysis windows. The rule is disabled when MaxCV is 0.
+type FlappingRuleConfig struct {
+	MaxCV    float64 `yaml:"max_cv"`    // flag queries whose mean time coefficient of variation (stddev/mean) reaches this
+	Windows  int     `yaml:"windows"`   // consecutive window_duration windows to compare, ending now (default 6)
+	MinCalls int64   `yaml:"min_calls"` // ignore windows in which the query ran fewer times
 
-	return re.ReplaceAllStringFunc(input, func(match string) string {
-		parts := re.FindStringSubmatch(match)
-		if len(parts) < 2 {
-			return match
-		}
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
 
-		varName := parts[1]
-		defaultVal := ""
-		if len(parts) > 2 {
-			defaultVal = parts[2]
-		}
+// MaxFlappingWindows caps rules.flapping.windows: each window is one query
+// against the PoWA repository.
+const MaxFlappingWindows = 24
 
-		if val, exists := os.LookupEnv(varName); exists {
-			return val
-		}
-		return defaultVal
-	})
+// VacuumRuleConfig defines detection of tables needing VACUUM or ANALYZE from
+// PoWA's pg_stat_all_tables snapshots. The rule is disabled when both
+// MaxDeadRatioPercent and MaxAge are unset, and skipped without snapshots.
+type VacuumRuleConfig struct {
+	MaxDeadRatioPercent float64 `yaml:"max_dead_ratio_percent"` // flag tables whose dead tuples reach this % of all tuples
+	MaxAge              string  `yaml:"max_age"`                // flag tables not vacuumed or analyzed within this long, e.g. "168h"
+	MinDeadTuples       int64   `yaml:"min_dead_tuples"`        // ignore tables with fewer dead tuples (default 1000)
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
 }
 
-// applyDefaults sets default values for any unset configuration fields.
-func applyDefaults(cfg *Config) {
-	// Database defaults
-	if cfg.Database.Host == "" {
-		cfg.Database.Host = "127.0.0.1"
-	}
-	if cfg.Database.Port == 0 {
-		cfg.Database.Port = 5432
-	}
-	if cfg.Database.User == "" {
-		cfg.Database.User = "powa_readonly"
-	}
-	if cfg.Database.DBName == "" {
-		cfg.Database.DBName = "powa"
+// MaxAgeParsed returns the vacuum/analyze age limit, or 0 when it is not set.
+func (v *VacuumRuleConfig) MaxAgeParsed() (time.Duration, error) {
+	if v.MaxAge == "" {
+		return 0, nil
 	}
-	if cfg.Database.SSLMode == "" {
-		cfg.Database.SSLMode = "disable"
+	return time.ParseDuration(v.MaxAge)
+}
+
+// IndexSuggestionRuleConfig defines index suggestion filtering.
+type IndexSuggestionRuleConfig struct {
+	MinImprovementPercent float64 `yaml:"min_improvement_percent"`
+	MinAffectedQueries    int     `yaml:"min_affected_queries"` // drop indexes that would help fewer queries (0 = no floor)
+	MaxScanErrorRatio     float64 `yaml:"max_scan_error_ratio"` // fail the rule when more than this fraction of rows fails to scan (1 = never)
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
+
+// CustomRuleConfig defines a user-supplied SQL check. Every returned row whose
+// ThresholdColumn satisfies ThresholdOperator against ThresholdValue becomes a finding.
+type CustomRuleConfig struct {
+	Name              string  `yaml:"name"`
+	Query             string  `yaml:"query"` // a single SELECT, run in a read-only transaction
+	ThresholdColumn   string  `yaml:"threshold_column"`
+	ThresholdOperator string  `yaml:"threshold_operator"` // one of >, >=, <, <=, =, !=
+	ThresholdValue    float64 `yaml:"threshold_value"`
+	Severity          string  `yaml:"severity"` // critical, high, medium (default), low or info
+	Timeout           string  `yaml:"timeout"`  // statement_timeout for the query (default 30s)
+
+	Cooldown string `yaml:"cooldown"` // leave the rule out of alerts for this long after one carrying its findings was sent; empty disables
+}
+
+// CustomRuleOperators lists the comparison operators allowed in custom rules.
+var CustomRuleOperators = []string{">", ">=", "<", "<=", "=", "!="}
+
+// TimeoutParsed returns the parsed statement timeout, defaulting to 30s.
+func (r *CustomRuleConfig) TimeoutParsed() (time.Duration, error) {
+	if r.Timeout == "" {
+		return 30 * time.Second, nil
 	}
+	return time.ParseDuration(r.Timeout)
+}
 
-	// Schedule defaults (6-field cron with seconds)
-	if cfg.Schedule.Cron == "" {
-		cfg.Schedule.Cron = "0 0 9 * * 1" // Every Monday at 9:00 AM
+// validate checks that the rule is complete and its query is a single SELECT.
+func (r *CustomRuleConfig) validate(i int) []string {
+	var errs []string
+	prefix := fmt.Sprintf("rules.custom[%d]", i)
+	if r.Name != "" {
+		prefix = fmt.Sprintf("rules.custom[%s]", r.Name)
+	} else {
+		errs = append(errs, prefix+".name is required")
 	}
-	if cfg.Schedule.Timezone == "" {
-		cfg.Schedule.Timezone = "UTC"
+	if err := checkSingleSelect(r.Query); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.query %v", prefix, err))
 	}
-
-	// Analysis defaults
-	if cfg.Analysis.WindowDuration == "" {
-		cfg.Analysis.WindowDuration = "24h"
+	if r.ThresholdColumn == "" {
+		errs = append(errs, prefix+".threshold_column is required")
 	}
-	if cfg.Analysis.ComparisonOffset == "" {
-		cfg.Analysis.ComparisonOffset = "168h"
+	validOp := false
+	for _, op := range CustomRuleOperators {
+		if r.ThresholdOperator == op {
+			validOp = true
+		}
 	}
-
-	// Rules defaults
-	if cfg.Rules.SlowSQL.TopN == 0 {
-		cfg.Rules.SlowSQL.TopN = 10
+	if !validOp {
+		errs = append(errs, fmt.Sprintf("%s.threshold_operator must be one of: %s", prefix, strings.Join(CustomRuleOperators, " ")))
 	}
-	if cfg.Rules.SlowSQL.RankBy == "" {
-		cfg.Rules.SlowSQL.RankBy = "total_time"
+	switch r.Severity {
+	case "", "critical", "high", "medium", "low", "info":
+	default:
+		errs = append(errs, prefix+".severity must be one of: critical, high, medium, low, info")
 	}
-	if cfg.Rules.Regression.ThresholdPercent == 0 {
-		cfg.Rules.Regression.ThresholdPercent = 50
+	if d, err := r.TimeoutParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.timeout is invalid: %v", prefix, err))
+	} else if d <= 0 {
+		errs = append(errs, prefix+".timeout must be positive")
 	}
-	if cfg.Rules.IndexSuggestion.MinImprovementPercent == 0 {
-		cfg.Rules.IndexSuggestion.MinImprovementPercent = 30
+	if err := cooldownError(prefix, r.Cooldown); err != "" {
+		errs = append(errs, err)
 	}
+	return errs
+}
 
-	// Notifier defaults
-	if cfg.Notifier.Type == "" {
-		cfg.Notifier.Type = "console"
-	}
-	if cfg.Notifier.Retries == 0 {
-		cfg.Notifier.Retries = 3
+// checkSingleSelect rejects empty queries, multiple statements and anything
+// other than SELECT or WITH. Semicolons are only allowed at the very end, so a
+// literal containing ";" must be avoided. The query still runs read-only.
+func checkSingleSelect(query string) error {
+	q := strings.TrimSpace(query)
+	q = strings.TrimSpace(strings.TrimRight(q, ";"))
+	if q == "" {
+		return fmt.Errorf("is required")
 	}
-	if cfg.Notifier.RetryDelay == "" {
-		cfg.Notifier.RetryDelay = "1s"
+	if strings.Contains(q, ";") {
+		return fmt.Errorf("must be a single statement")
 	}
-
-	// Server defaults
-	if cfg.Server.Port == 0 {
-		cfg.Server.Port = 8080
+	fields := strings.Fields(q)
+	if first := strings.ToUpper(fields[0]); first != "SELECT" && first != "WITH" {
+		return fmt.Errorf("must be a SELECT statement")
 	}
+	return nil
 }
 
-// Validate checks that the configuration is valid.
-func (c *Config) Validate() error {
-	var errs []string
+// NotifierConfig holds notification channel settings.
+type NotifierConfig struct {
+	Type       string             `yaml:"type"`
+	WebhookURL string             `yaml:"webhook_url"`
+	Retries    int                `yaml:"retries"`
+	RetryDelay string             `yaml:"retry_delay"`
+	ProxyURL   string             `yaml:"proxy_url"` // HTTP(S) proxy for webhook notifiers; empty uses HTTPS_PROXY/HTTP_PROXY
+	File       FileNotifierConfig `yaml:"file"`
+	Syslog     SyslogConfig       `yaml:"syslog"`
+	Console    ConsoleConfig      `yaml:"console"`
+	WeCom      WeComConfig        `yaml:"wecom"`
+	Kafka      KafkaConfig        `yaml:"kafka"`
+	SNS        SNSConfig          `yaml:"sns"`
+	Precision  *int               `yaml:"precision"` // decimals in text notifier output; nil uses DefaultPrecision
 
-	// Validate database
-	if c.Database.Host == "" {
-		errs = append(errs, "database.host is required")
-	}
-	validExpectedExtensions := map[string]bool{"pg_stat_kcache": true, "pg_qualstats": true}
-	seenInvalid := make(map[string]bool)
-	for _, ext := range c.Database.ExpectedExtensions {
-		if !validExpectedExtensions[ext] && !seenInvalid[ext] {
-			seenInvalid[ext] = true
-			errs = append(errs, fmt.Sprintf("database.expected_extensions: %q is not allowed; use pg_stat_kcache and/or pg_qualstats", ext))
-		}
-	}
+	SuppressIfUnchanged bool   `yaml:"suppress_if_unchanged"` // skip sending when findings match the last sent alert
+	ForceInterval       string `yaml:"force_interval"`        // re-send an unchanged alert after this long ("0s" = never)
+	Mode                string `yaml:"mode"`                  // "full" (default) or "delta": only new and resolved findings since the last run
+	Verbosity           string `yaml:"verbosity"`             // "summary", "normal" (default) or "detailed": how much text notifiers render
+	SendOnEmpty         bool   `yaml:"send_on_empty"`         // send alerts for windows without any statements ("no data")
+	TitleTemplate       string `yaml:"title_template"`        // Go template for the message title of notifiers that have one
+	QueryURLTemplate    string `yaml:"query_url_template"`    // link for each query finding, e.g. https://powa/server/{srvid}/database/{db}/query/{queryid}
 
-	// Validate notifier type
-	validNotifierTypes := map[string]bool{"wecom": true, "console": true}
-	if !validNotifierTypes[c.Notifier.Type] {
-		errs = append(errs, "notifier.type must be one of: wecom, console")
-	}
+	IncludeRawMetrics bool `yaml:"include_raw_metrics"` // embed the analyzed metric rows in JSON payloads (kafka alert messages, sns, --output)
+	RawMetricsLimit   int  `yaml:"raw_metrics_limit"`   // rows include_raw_metrics embeds at most; 0 means DefaultRawMetricsLimit
 
-	// Validate notifier webhook URL
-	if c.Notifier.Type == "wecom" && c.Notifier.WebhookURL == "" {
-		errs = append(errs, "notifier.webhook_url is required when type is 'wecom'")
-	}
+	WebhookURLFile string `yaml:"webhook_url_file"` // read webhook_url from this file at load time (Docker/Kubernetes secrets)
 
-	// Validate durations
-	if _, err := c.Analysis.WindowDurationParsed(); err != nil {
-		errs = append(errs, fmt.Sprintf("analysis.window_duration is invalid: %v", err))
+	DigestInterval     string `yaml:"digest_interval"`     // buffer runs and send one digest per interval, e.g. "24h" (empty = send every run)
+	DigestBreakthrough string `yaml:"digest_breakthrough"` // also send alerts with a finding at or above this severity right away (empty = never)
+
+	MinFindings int    `yaml:"min_findings"` // send only alerts with at least this many findings (0 = no minimum)
+	MinSeverity string `yaml:"min_severity"` // count only findings at or above this severity toward min_findings (empty = all)
+
+	RecoverySeverity string `yaml:"recovery_severity"` // send a recovery notification when a finding at or above this severity clears (empty = never)
+
+	Escalation *EscalationConfig `yaml:"escalation"` // optional second notifier for critical findings that persist across runs
+
+	Routes map[string]*NotifierConfig `yaml:"routes"` // send the findings of a severity to their own notifier instead of this one
+}
+
+// EscalationConfig routes critical findings that persist for AfterRuns
+// consecutive runs to a second notifier (e.g. a pager next to a chat webhook).
+// The notifier settings are inlined: type, webhook_url, syslog and so on.
+type EscalationConfig struct {
+	NotifierConfig `yaml:",inline"`
+
+	AfterRuns int    `yaml:"after_runs"` // consecutive runs a critical finding must persist before escalating
+	StateFile string `yaml:"state_file"` // optional JSON file keeping the run counts across restarts
+}
+
+// Notifier modes.
+const (
+	NotifierModeFull  = "full"
+	NotifierModeDelta = "delta"
+)
+
+// Notifier verbosity levels for text notifiers (console, wecom).
+const (
+	VerbositySummary  = "summary"  // counts and the single worst finding
+	VerbosityNormal   = "normal"   // capped sections with query previews
+	VerbosityDetailed = "detailed" // every finding with full query text and all metrics
+)
+
+// DefaultRawMetricsLimit caps the rows notifier.include_raw_metrics embeds when
+// notifier.raw_metrics_limit is unset.
+const DefaultRawMetricsLimit = 500
+
+// RawMetricsCap returns how many metric rows an alert embeds with IncludeRawMetrics.
+func (n *NotifierConfig) RawMetricsCap() int {
+	if n.RawMetricsLimit > 0 {
+		return n.RawMetricsLimit
+	}
+	return DefaultRawMetricsLimit
+}
+
+// ForceIntervalParsed returns the parsed force interval; empty means never force.
+func (n *NotifierConfig) ForceIntervalParsed() (time.Duration, error) {
+	if n.ForceInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(n.ForceInterval)
+}
+
+// DigestIntervalParsed returns the parsed digest interval; empty disables digests.
+func (n *NotifierConfig) DigestIntervalParsed() (time.Duration, error) {
+	if n.DigestInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(n.DigestInterval)
+}
+
+// DefaultPrecision is the number of decimals text notifiers show when notifier.precision is unset.
+const DefaultPrecision = 2
+
+// DisplayPrecision returns the configured number of decimals for text output.
+func (n *NotifierConfig) DisplayPrecision() int {
+	if n.Precision == nil {
+		return DefaultPrecision
+	}
+	return *n.Precision
+}
+
+// FileNotifierConfig holds settings for notifiers that write to a file (e.g. csv).
+type FileNotifierConfig struct {
+	Path string `yaml:"path"` // output file; empty writes to stdout
+}
+
+// ConsoleConfig holds settings specific to the console notifier.
+type ConsoleConfig struct {
+	Stream string `yaml:"stream"` // "stdout" (default) or "stderr"
+	Color  string `yaml:"color"`  // "auto" (default): color severities on a terminal only, "always" or "never"
+}
+
+// Console notifier streams and color modes.
+const (
+	ConsoleStreamStdout = "stdout"
+	ConsoleStreamStderr = "stderr"
+
+	ConsoleColorAuto   = "auto"
+	ConsoleColorAlways = "always"
+	ConsoleColorNever  = "never"
+)
+
+// WeComConfig holds settings specific to the WeCom notifier.
+type WeComConfig struct {
+	Format  string `yaml:"format"`   // "markdown" (default), "text" or "template_card"
+	CardURL string `yaml:"card_url"` // page the template_card title links to, e.g. the PoWA web UI
+}
+
+// WeCom message formats.
+const (
+	WeComFormatMarkdown     = "markdown"
+	WeComFormatText         = "text"
+	WeComFormatTemplateCard = "template_card"
+)
+
+// KafkaConfig holds settings specific to the Kafka notifier.
+type KafkaConfig struct {
+	Brokers []string        `yaml:"brokers"` // host:port bootstrap brokers
+	Topic   string          `yaml:"topic"`
+	Message string          `yaml:"message"` // "finding" (default): one message per finding, or "alert": one per run
+	SASL    KafkaSASLConfig `yaml:"sasl"`
+	TLS     KafkaTLSConfig  `yaml:"tls"`
+}
+
+// KafkaSASLConfig holds SASL credentials for the Kafka brokers.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // "plain", "scram-sha-256" or "scram-sha-512" (empty = no SASL)
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// KafkaTLSConfig enables TLS to the Kafka brokers.
+type KafkaTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`              // PEM bundle to verify the brokers; empty uses the system roots
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // do not verify the broker certificates
+}
+
+// Kafka message granularities.
+const (
+	KafkaMessageFinding = "finding"
+	KafkaMessageAlert   = "alert"
+)
+
+// KafkaSASLMechanisms lists the supported notifier.kafka.sasl.mechanism values.
+var KafkaSASLMechanisms = []string{"plain", "scram-sha-256", "scram-sha-512"}
+
+// SNSConfig holds settings specific to the Amazon SNS notifier.
+type SNSConfig struct {
+	TopicARN string `yaml:"topic_arn"`
+	Region   string `yaml:"region"` // region of the SNS client; empty uses the topic ARN's region
+}
+
+// SyslogConfig holds settings for the RFC 5424 syslog notifier.
+type SyslogConfig struct {
+	Address    string            `yaml:"address"`    // host:port of the syslog receiver
+	Network    string            `yaml:"network"`    // "udp" (default) or "tcp"
+	Facility   string            `yaml:"facility"`   // syslog facility name, e.g. "local0" (default)
+	Severities map[string]string `yaml:"severities"` // alert severity -> syslog severity name, overrides the defaults
+}
+
+// syslogFacilities maps RFC 5424 facility names to their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities maps RFC 5424 severity names to their codes.
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// SyslogFacilityCode returns the numeric code of a syslog facility name.
+func SyslogFacilityCode(name string) (int, bool) {
+	code, ok := syslogFacilities[name]
+	return code, ok
+}
+
+// SyslogSeverityCode returns the numeric code of a syslog severity name.
+func SyslogSeverityCode(name string) (int, bool) {
+	code, ok := syslogSeverities[name]
+	return code, ok
+}
+
+// RetryDelayParsed returns the parsed retry delay duration.
+func (n *NotifierConfig) RetryDelayParsed() (time.Duration, error) {
+	return time.ParseDuration(n.RetryDelay)
+}
+
+// ServerConfig holds HTTP server settings.
+type ServerConfig struct {
+	Port            int    `yaml:"port"`
+	DeepCheck       bool   `yaml:"deep_check"`
+	ShutdownTimeout string `yaml:"shutdown_timeout"` // budget for draining in-flight analysis and HTTP requests on SIGTERM
+
+	NotifierCheckInterval string `yaml:"notifier_check_interval"` // how often to probe the notifier endpoint; empty disables
+	QueryMetrics          int    `yaml:"query_metrics"`           // top slow queries exported as per-query /metrics gauges; 0 disables
+
+	PprofAddr string `yaml:"pprof_addr"` // debug-only listener for net/http/pprof, e.g. "localhost:6060"; empty disables
+}
+
+// ShutdownTimeoutParsed returns the parsed shutdown timeout.
+func (s *ServerConfig) ShutdownTimeoutParsed() (time.Duration, error) {
+	return time.ParseDuration(s.ShutdownTimeout)
+}
+
+// NotifierCheckIntervalParsed returns the parsed notifier probe interval; empty means disabled.
+func (s *ServerConfig) NotifierCheckIntervalParsed() (time.Duration, error) {
+	if s.NotifierCheckInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s.NotifierCheckInterval)
+}
+
+// HeartbeatConfig holds dead-man's-switch ping settings (healthchecks.io,
+// Cronitor, ...). The scheduler GETs URL after every run that analyzed and
+// notified successfully, so the monitor alerts when powa-sentinel stops running.
+type HeartbeatConfig struct {
+	URL      string `yaml:"url"`       // pinged after each successful run; empty disables the heartbeat
+	StartURL string `yaml:"start_url"` // optional: pinged when a run starts (e.g. https://hc-ping.com/<uuid>/start)
+	FailURL  string `yaml:"fail_url"`  // optional: pinged when a run fails instead of skipping the ping (e.g. .../fail)
+	Timeout  string `yaml:"timeout"`   // per-ping timeout; a failed ping is logged and never fails the run
+}
+
+// TimeoutParsed returns the parsed per-ping timeout.
+func (h *HeartbeatConfig) TimeoutParsed() (time.Duration, error) {
+	return time.ParseDuration(h.Timeout)
+}
+
+// Load reads and parses the configuration file, then applies POWA_SENTINEL_*
+// environment variables on top (see EnvPrefix). A missing file is not an
+// error when such variables are set, so the whole config can come from the
+// environment.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) || !hasEnvConfig() {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		data = nil
+	}
+
+	// Expand environment variables
+	expanded := expandEnvVars(string(data))
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Repositories) > 0 && !reflect.ValueOf(cfg.Database).IsZero() {
+		return nil, fmt.Errorf("%sDATABASE_* variables cannot be used when database is a list", EnvPrefix)
+	}
+
+	if err := resolveSecretFiles(&cfg); err != nil {
+		return nil, err
+	}
+	if err := resolveWebhookTemplates(&cfg); err != nil {
+		return nil, err
+	}
+
+	// Apply defaults
+	applyDefaults(&cfg)
+
+	return &cfg, nil
+}
+
+// resolveSecretFiles replaces each value that has a *_file counterpart with the
+// trimmed contents of that file.
+func resolveSecretFiles(cfg *Config) error {
+	type secret struct {
+		key, fileKey string
+		value        *string
+		path         string
+	}
+	secrets := []secret{
+		{"notifier.webhook_url", "notifier.webhook_url_file", &cfg.Notifier.WebhookURL, cfg.Notifier.WebhookURLFile},
+	}
+	for i, db := range cfg.Databases() {
+		key := cfg.databaseKey(i)
+		secrets = append(secrets, secret{key + ".password", key + ".password_file", &db.Password, db.PasswordFile})
+		if m := db.Monitored; m != nil {
+			secrets = append(secrets, secret{key + ".monitored.password", key + ".monitored.password_file", &m.Password, m.PasswordFile})
+		}
+	}
+	if esc := cfg.Notifier.Escalation; esc != nil {
+		secrets = append(secrets, secret{"notifier.escalation.webhook_url", "notifier.escalation.webhook_url_file",
+			&esc.WebhookURL, esc.WebhookURLFile})
+	}
+	for _, severity := range slices.Sorted(maps.Keys(cfg.Notifier.Routes)) {
+		if route := cfg.Notifier.Routes[severity]; route != nil {
+			key := "notifier.routes." + severity
+			secrets = append(secrets, secret{key + ".webhook_url", key + ".webhook_url_file", &route.WebhookURL, route.WebhookURLFile})
+		}
+	}
+	for _, s := range secrets {
+		if s.path == "" {
+			continue
+		}
+		if *s.value != "" {
+			return fmt.Errorf("%s and %s are mutually exclusive", s.key, s.fileKey)
+		}
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", s.fileKey, err)
+		}
+		*s.value = strings.TrimSpace(string(data))
+	}
+	return nil
+}
+
+// webhookTemplateData is what webhook URL templates see.
+type webhookTemplateData struct {
+	Env string // Config.Environment
+}
+
+// resolveWebhookTemplates renders the webhook URLs that contain template
+// tokens such as {{.Env}}; plain URLs are left untouched.
+func resolveWebhookTemplates(cfg *Config) error {
+	type webhook struct {
+		key   string
+		value *string
+	}
+	urls := []webhook{{"notifier.webhook_url", &cfg.Notifier.WebhookURL}}
+	if esc := cfg.Notifier.Escalation; esc != nil {
+		urls = append(urls, webhook{"notifier.escalation.webhook_url", &esc.WebhookURL})
+	}
+	for _, severity := range slices.Sorted(maps.Keys(cfg.Notifier.Routes)) {
+		if route := cfg.Notifier.Routes[severity]; route != nil {
+			urls = append(urls, webhook{"notifier.routes." + severity + ".webhook_url", &route.WebhookURL})
+		}
+	}
+	for _, u := range urls {
+		if !strings.Contains(*u.value, "{{") {
+			continue
+		}
+		if cfg.Environment == "" {
+			return fmt.Errorf("%s is a template but environment is not set", u.key)
+		}
+		tmpl, err := template.New(u.key).Option("missingkey=error").Parse(*u.value)
+		if err != nil {
+			return fmt.Errorf("parsing %s template: %w", u.key, err)
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, webhookTemplateData{Env: cfg.Environment}); err != nil {
+			return fmt.Errorf("rendering %s template: %w", u.key, err)
+		}
+		*u.value = sb.String()
+	}
+	return nil
+}
+
+// expandEnvVars expands ${VAR} and ${VAR:-default} patterns in the input string.
+func expandEnvVars(input string) string {
+	// Pattern: ${VAR:-default} or ${VAR}
+	re := regexp.MustCompile(`\$\{([^}:]+)(?::-([^}]*))?\}`)
+
+	return re.ReplaceAllStringFunc(input, func(match string) string {
+		parts := re.FindStringSubmatch(match)
+		if len(parts) < 2 {
+			return match
+		}
+
+		varName := parts[1]
+		defaultVal := ""
+		if len(parts) > 2 {
+			defaultVal = parts[2]
+		}
+
+		if val, exists := os.LookupEnv(varName); exists {
+			return val
+		}
+		return defaultVal
+	})
+}
+
+// applyDefaults sets the connection defaults of one repository.
+func (d *DatabaseConfig) applyDefaults() {
+	if d.Host == "" {
+		d.Host = "127.0.0.1"
+	}
+	if d.Port == 0 {
+		d.Port = 5432
+	}
+	if d.User == "" {
+		d.User = "powa_readonly"
+	}
+	if d.DBName == "" {
+		d.DBName = "powa"
+	}
+	if d.SSLMode == "" {
+		d.SSLMode = "prefer"
+	}
+	if d.ApplicationName == "" {
+		d.ApplicationName = DefaultApplicationName
+	}
+	if m := d.Monitored; m != nil {
+		if m.DBName == "" {
+			m.DBName = "postgres"
+		}
+		m.applyDefaults()
+	}
+}
+
+// applyDefaults sets default values for any unset configuration fields.
+func applyDefaults(cfg *Config) {
+	for _, db := range cfg.Databases() {
+		db.applyDefaults()
+	}
+
+	// Schedule defaults (6-field cron with seconds)
+	if cfg.Schedule.Cron == "" {
+		cfg.Schedule.Cron = "0 0 9 * * 1" // Every Monday at 9:00 AM
+	}
+	if cfg.Schedule.Timezone == "" {
+		cfg.Schedule.Timezone = "UTC"
+	}
+
+	// Analysis defaults
+	if cfg.Analysis.WindowDuration == "" {
+		cfg.Analysis.WindowDuration = "24h"
+	}
+	if cfg.Analysis.ComparisonOffset == "" {
+		cfg.Analysis.ComparisonOffset = "168h"
+	}
+	if cfg.Analysis.WindowMode == "" {
+		cfg.Analysis.WindowMode = WindowModeFixed
+	}
+
+	// Rules defaults
+	if cfg.Rules.SlowSQL.TopN == 0 {
+		cfg.Rules.SlowSQL.TopN = 10
+	}
+	if len(cfg.Rules.SlowSQL.RankBy) == 0 {
+		cfg.Rules.SlowSQL.RankBy = RankByList{"total_time"}
+	}
+	if cfg.Rules.SlowSQL.MaxExplains == 0 {
+		cfg.Rules.SlowSQL.MaxExplains = 1
+	}
+	if cfg.Rules.SlowSQL.ExplainTimeout == "" {
+		cfg.Rules.SlowSQL.ExplainTimeout = "5s"
+	}
+	if cfg.Rules.Regression.ThresholdPercent == 0 {
+		cfg.Rules.Regression.ThresholdPercent = 50
+	}
+	if cfg.Rules.WorkloadGrowth.TopContributors == 0 {
+		cfg.Rules.WorkloadGrowth.TopContributors = 5
+	}
+	if cfg.Rules.Regression.BaselineScope == "" {
+		cfg.Rules.Regression.BaselineScope = BaselineScopeCurrentQueries
+	}
+	if cfg.Rules.Regression.Metric == "" {
+		cfg.Rules.Regression.Metric = RegressionMetricMean
+	}
+	if cfg.Rules.Regression.TailFactor == 0 {
+		cfg.Rules.Regression.TailFactor = DefaultTailFactor
+	}
+	if cfg.Rules.IndexSuggestion.MinImprovementPercent == 0 {
+		cfg.Rules.IndexSuggestion.MinImprovementPercent = 30
+	}
+	if cfg.Rules.IndexSuggestion.MaxScanErrorRatio == 0 {
+		cfg.Rules.IndexSuggestion.MaxScanErrorRatio = 0.5
+	}
+	if cfg.Rules.Waits.ProfilePeriod == "" {
+		cfg.Rules.Waits.ProfilePeriod = "10ms"
+	}
+	if cfg.Rules.Flapping.Windows == 0 {
+		cfg.Rules.Flapping.Windows = 6
+	}
+	if cfg.Rules.Vacuum.MinDeadTuples == 0 {
+		cfg.Rules.Vacuum.MinDeadTuples = 1000
+	}
+
+	// Notifier defaults
+	cfg.Notifier.applyDefaults()
+	if cfg.Notifier.Escalation != nil {
+		cfg.Notifier.Escalation.applyDefaults()
+	}
+	for _, route := range cfg.Notifier.Routes {
+		if route != nil {
+			route.applyDefaults()
+		}
+	}
+
+	// Server defaults
+	if cfg.Server.Port == 0 {
+		cfg.Server.Port = 8080
+	}
+	if cfg.Server.ShutdownTimeout == "" {
+		cfg.Server.ShutdownTimeout = "30s"
+	}
+
+	// Heartbeat defaults
+	if cfg.Heartbeat.Timeout == "" {
+		cfg.Heartbeat.Timeout = "10s"
+	}
+}
+
+// applyDefaults fills unset notifier fields; it is shared by the primary and
+// escalation notifiers.
+func (n *NotifierConfig) applyDefaults() {
+	if n.Type == "" {
+		n.Type = "console"
+	}
+	if n.Retries == 0 {
+		n.Retries = 3
+	}
+	if n.RetryDelay == "" {
+		n.RetryDelay = "1s"
+	}
+	if n.ForceInterval == "" {
+		n.ForceInterval = "24h"
+	}
+	if n.Mode == "" {
+		n.Mode = NotifierModeFull
+	}
+	if n.Verbosity == "" {
+		n.Verbosity = VerbosityNormal
+	}
+	if n.Console.Stream == "" {
+		n.Console.Stream = ConsoleStreamStdout
+	}
+	if n.Console.Color == "" {
+		n.Console.Color = ConsoleColorAuto
+	}
+	if n.WeCom.Format == "" {
+		n.WeCom.Format = WeComFormatMarkdown
+	}
+	if n.Syslog.Network == "" {
+		n.Syslog.Network = "udp"
+	}
+	if n.Syslog.Facility == "" {
+		n.Syslog.Facility = "local0"
+	}
+	if n.Kafka.Message == "" {
+		n.Kafka.Message = KafkaMessageFinding
+	}
+}
+
+// Validate checks that the configuration is valid.
+func (c *Config) Validate() error {
+	var errs []string
+
+	// Validate every repository
+	names := make(map[string]bool)
+	for i, db := range c.Databases() {
+		key := c.databaseKey(i)
+		errs = append(errs, db.validate(key)...)
+		if len(c.Repositories) == 0 {
+			continue
+		}
+		// Alerts of a list are told apart by repository name
+		if db.Name == "" {
+			errs = append(errs, key+".name is required when database is a list")
+		} else if names[db.Name] {
+			errs = append(errs, fmt.Sprintf("%s.name %q is used by another repository", key, db.Name))
+		}
+		names[db.Name] = true
+	}
+	if len(c.Repositories) > 1 {
+		// These files hold the state of one repository
+		if c.Rules.Regression.BaselineFile != "" {
+			errs = append(errs, "rules.regression.baseline_file cannot be used with several repositories")
+		}
+		if esc := c.Notifier.Escalation; esc != nil && esc.StateFile != "" {
+			errs = append(errs, "notifier.escalation.state_file cannot be used with several repositories")
+		}
+	}
+	if c.Schedule.Concurrency < 0 {
+		errs = append(errs, "schedule.concurrency must not be negative")
+	}
+	backoff, err := c.Schedule.FailureBackoffParsed()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("schedule.failure_backoff is invalid: %v", err))
+	} else if backoff < 0 {
+		errs = append(errs, "schedule.failure_backoff must not be negative")
+	}
+	if d, err := c.Schedule.FailureBackoffMaxParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("schedule.failure_backoff_max is invalid: %v", err))
+	} else if d <= 0 {
+		errs = append(errs, "schedule.failure_backoff_max must be positive")
+	} else if d < backoff {
+		errs = append(errs, "schedule.failure_backoff_max must not be shorter than schedule.failure_backoff")
+	}
+
+	// Validate notifier settings
+	errs = append(errs, c.Notifier.validate("notifier")...)
+	if esc := c.Notifier.Escalation; esc != nil {
+		errs = append(errs, esc.validate("notifier.escalation")...)
+		if esc.AfterRuns < 1 {
+			errs = append(errs, "notifier.escalation.after_runs must be at least 1")
+		}
+		if esc.Escalation != nil {
+			errs = append(errs, "notifier.escalation must not define its own escalation")
+		}
+		if esc.DigestInterval != "" || esc.DigestBreakthrough != "" {
+			errs = append(errs, "notifier.escalation must not set digest_interval or digest_breakthrough: escalations are always sent right away")
+		}
+		if esc.MinFindings != 0 || esc.MinSeverity != "" {
+			errs = append(errs, "notifier.escalation must not set min_findings or min_severity: every escalation is sent")
+		}
+		if esc.RecoverySeverity != "" {
+			errs = append(errs, "notifier.escalation must not set recovery_severity: recoveries go to the notifier itself")
+		}
+		if len(esc.Routes) > 0 {
+			errs = append(errs, "notifier.escalation must not define routes")
+		}
+		if esc.IncludeRawMetrics || esc.RawMetricsLimit != 0 {
+			errs = append(errs, "notifier.escalation must not set include_raw_metrics or raw_metrics_limit: escalations carry only their findings")
+		}
+	}
+	for _, severity := range slices.Sorted(maps.Keys(c.Notifier.Routes)) {
+		key := "notifier.routes." + severity
+		route := c.Notifier.Routes[severity]
+		switch severity {
+		case "critical", "high", "medium", "low", "info":
+		default:
+			errs = append(errs, fmt.Sprintf("notifier.routes: %q is not a severity; use critical, high, medium, low or info", severity))
+			continue
+		}
+		if route == nil {
+			errs = append(errs, key+" must configure a notifier")
+			continue
+		}
+		errs = append(errs, route.validate(key)...)
+		if route.Escalation != nil || len(route.Routes) > 0 {
+			errs = append(errs, key+" must not define its own escalation or routes")
+		}
+		if route.DigestInterval != "" || route.DigestBreakthrough != "" || route.MinFindings != 0 || route.MinSeverity != "" || route.RecoverySeverity != "" {
+			errs = append(errs, key+" must not set digest, threshold or recovery options: they apply to the notifier before routing")
+		}
+		if route.IncludeRawMetrics || route.RawMetricsLimit != 0 {
+			errs = append(errs, key+" must not set include_raw_metrics or raw_metrics_limit: raw metrics stay with the notifier itself")
+		}
+	}
+	if d, err := c.Notifier.DigestIntervalParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("notifier.digest_interval is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "notifier.digest_interval must not be negative")
+	} else if d > 0 && c.Notifier.Mode == NotifierModeDelta {
+		errs = append(errs, "notifier.digest_interval cannot be combined with notifier.mode delta: a digest lists every finding of its runs")
 	}
-	if _, err := c.Analysis.ComparisonOffsetParsed(); err != nil {
+	switch c.Notifier.DigestBreakthrough {
+	case "", "critical", "high", "medium", "low", "info":
+	default:
+		errs = append(errs, "notifier.digest_breakthrough must be one of: critical, high, medium, low, info")
+	}
+	if c.Notifier.DigestBreakthrough != "" && c.Notifier.DigestInterval == "" {
+		errs = append(errs, "notifier.digest_breakthrough requires notifier.digest_interval")
+	}
+	if c.Notifier.RawMetricsLimit < 0 {
+		errs = append(errs, "notifier.raw_metrics_limit must not be negative")
+	}
+	if c.Notifier.MinFindings < 0 {
+		errs = append(errs, "notifier.min_findings must not be negative")
+	}
+	switch c.Notifier.MinSeverity {
+	case "", "critical", "high", "medium", "low", "info":
+	default:
+		errs = append(errs, "notifier.min_severity must be one of: critical, high, medium, low, info")
+	}
+	switch c.Notifier.RecoverySeverity {
+	case "", "critical", "high", "medium", "low", "info":
+	default:
+		errs = append(errs, "notifier.recovery_severity must be one of: critical, high, medium, low, info")
+	}
+
+	// Validate durations
+	window, err := c.Analysis.WindowDurationParsed()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("analysis.window_duration is invalid: %v", err))
+	}
+	offset, err := c.Analysis.ComparisonOffsetParsed()
+	if err != nil {
 		errs = append(errs, fmt.Sprintf("analysis.comparison_offset is invalid: %v", err))
 	}
-	if _, err := c.Notifier.RetryDelayParsed(); err != nil {
-		errs = append(errs, fmt.Sprintf("notifier.retry_delay is invalid: %v", err))
+	if d, err := c.Analysis.MaxDataAgeParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("analysis.max_data_age is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "analysis.max_data_age must not be negative")
+	}
+	if d, err := c.Analysis.SoftTimeoutParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("analysis.soft_timeout is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "analysis.soft_timeout must not be negative")
+	}
+	if slices.Contains(c.Analysis.IncludeSchemas, "") {
+		errs = append(errs, "analysis.include_schemas must not contain empty schema names")
+	}
+	if slices.Contains(c.Analysis.ExcludeSchemas, "") {
+		errs = append(errs, "analysis.exclude_schemas must not contain empty schema names")
+	}
+	switch c.Analysis.WindowMode {
+	case "", WindowModeFixed, WindowModeSinceLastRun:
+	default:
+		errs = append(errs, fmt.Sprintf("analysis.window_mode must be %q or %q", WindowModeFixed, WindowModeSinceLastRun))
+	}
+	var maxWindow time.Duration
+	if c.Analysis.MaxWindow != "" {
+		if d, err := time.ParseDuration(c.Analysis.MaxWindow); err != nil {
+			errs = append(errs, fmt.Sprintf("analysis.max_window is invalid: %v", err))
+		} else if d <= 0 {
+			errs = append(errs, "analysis.max_window must be positive")
+		} else {
+			maxWindow = d
+		}
+	}
+	if d, err := c.Analysis.MaxWindowDurationParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("analysis.max_window_duration is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "analysis.max_window_duration must not be negative")
+	} else if d > 0 {
+		if window > d {
+			errs = append(errs, fmt.Sprintf("analysis.window_duration %s exceeds analysis.max_window_duration %s", window, d))
+		}
+		if maxWindow > d {
+			errs = append(errs, fmt.Sprintf("analysis.max_window %s exceeds analysis.max_window_duration %s", maxWindow, d))
+		}
+	}
+	if d, err := c.Analysis.MaxComparisonOffsetParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("analysis.max_comparison_offset is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "analysis.max_comparison_offset must not be negative")
+	} else if d > 0 && offset > d {
+		errs = append(errs, fmt.Sprintf("analysis.comparison_offset %s exceeds analysis.max_comparison_offset %s", offset, d))
+	}
+	for _, p := range c.Analysis.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			errs = append(errs, fmt.Sprintf("analysis.redact_patterns: %q is invalid: %v", p, err))
+		}
+	}
+	for _, p := range c.Analysis.AllowlistPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			errs = append(errs, fmt.Sprintf("analysis.allowlist_patterns: %q is invalid: %v", p, err))
+		}
+	}
+	if c.Analysis.MaxQueryLength < 0 {
+		errs = append(errs, "analysis.max_query_length must not be negative")
+	}
+	if w := c.Analysis.Weights; w.TotalTime < 0 || w.RegressionPercent < 0 || w.AffectedQueries < 0 {
+		errs = append(errs, "analysis.weights must not be negative")
+	}
+	if c.Analysis.MaxFindings < 0 {
+		errs = append(errs, "analysis.max_findings must not be negative")
+	}
+	if c.Analysis.TopDatabases < 0 {
+		errs = append(errs, "analysis.top_databases must not be negative")
+	}
+	if c.Analysis.TopUsers < 0 {
+		errs = append(errs, "analysis.top_users must not be negative")
+	}
+	if c.Analysis.FetchParallelism < 0 {
+		errs = append(errs, "analysis.fetch_parallelism must not be negative")
+	}
+	if c.Analysis.RetryBudget < 0 {
+		errs = append(errs, "analysis.retry_budget must not be negative")
+	}
+
+	if c.Server.ShutdownTimeout != "" {
+		if d, err := c.Server.ShutdownTimeoutParsed(); err != nil {
+			errs = append(errs, fmt.Sprintf("server.shutdown_timeout is invalid: %v", err))
+		} else if d <= 0 {
+			errs = append(errs, "server.shutdown_timeout must be positive")
+		}
+	}
+	if d, err := c.Server.NotifierCheckIntervalParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("server.notifier_check_interval is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "server.notifier_check_interval must not be negative")
+	}
+	if c.Server.QueryMetrics < 0 {
+		errs = append(errs, "server.query_metrics must not be negative")
+	}
+	if c.Server.PprofAddr != "" {
+		if _, port, err := net.SplitHostPort(c.Server.PprofAddr); err != nil {
+			errs = append(errs, fmt.Sprintf("server.pprof_addr is invalid: %v", err))
+		} else if port == strconv.Itoa(c.Server.Port) {
+			errs = append(errs, fmt.Sprintf("server.pprof_addr must not use the health server port %d", c.Server.Port))
+		}
+	}
+
+	for _, h := range []struct{ key, url string }{
+		{"heartbeat.url", c.Heartbeat.URL},
+		{"heartbeat.start_url", c.Heartbeat.StartURL},
+		{"heartbeat.fail_url", c.Heartbeat.FailURL},
+	} {
+		if h.url == "" {
+			continue
+		}
+		if u, err := url.Parse(h.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, h.key+" must be an absolute http or https URL")
+		}
+	}
+	if (c.Heartbeat.StartURL != "" || c.Heartbeat.FailURL != "") && c.Heartbeat.URL == "" {
+		errs = append(errs, "heartbeat.url is required when heartbeat.start_url or heartbeat.fail_url is set")
+	}
+	if c.Heartbeat.URL != "" {
+		if d, err := c.Heartbeat.TimeoutParsed(); err != nil {
+			errs = append(errs, fmt.Sprintf("heartbeat.timeout is invalid: %v", err))
+		} else if d <= 0 {
+			errs = append(errs, "heartbeat.timeout must be positive")
+		}
 	}
 
+	// An empty cron is only seen before applyDefaults; Load always sets one
+	if c.Schedule.Cron != "" {
+		if err := validateCron(c.Schedule.Cron); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
 	// Validate schedule timezone and cache Location for use by scheduler (parse once)
-	if loc, err := time.LoadLocation(c.Schedule.Timezone); err != nil {
-		errs = append(errs, fmt.Sprintf("schedule.timezone %q is invalid: %v", c.Schedule.Timezone, err))
+	if loc, err := loadLocation("schedule.timezone", c.Schedule.Timezone); err != nil {
+		errs = append(errs, err.Error())
 	} else {
 		c.Schedule.Location = loc
 	}
+	// Display timezone for alert text; empty keeps the server's local zone
+	if c.Analysis.DisplayTimezone != "" {
+		if loc, err := loadLocation("analysis.display_timezone", c.Analysis.DisplayTimezone); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			c.Analysis.DisplayLocation = loc
+		}
+	}
 
 	// Validate rule values
 	if c.Rules.SlowSQL.TopN < 1 {
 		errs = append(errs, "rules.slow_sql.top_n must be at least 1")
 	}
-	validRankBy := map[string]bool{"total_time": true, "mean_time": true, "cpu_time": true, "io_time": true}
-	if !validRankBy[c.Rules.SlowSQL.RankBy] {
-		errs = append(errs, "rules.slow_sql.rank_by must be one of: total_time, mean_time, cpu_time, io_time")
+	if len(c.Rules.SlowSQL.RankBy) == 0 {
+		errs = append(errs, fmt.Sprintf("rules.slow_sql.rank_by must be one of: %s", strings.Join(SlowQueryMetrics, ", ")))
+	}
+	rankedBy := make(map[string]bool, len(c.Rules.SlowSQL.RankBy))
+	for _, entry := range c.Rules.SlowSQL.RankBy {
+		r, err := parseRanking(entry, c.Rules.SlowSQL.TopN)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("rules.slow_sql.rank_by: %q %v", entry, err))
+		} else if rankedBy[r.Metric] {
+			errs = append(errs, fmt.Sprintf("rules.slow_sql.rank_by lists %s more than once", r.Metric))
+		}
+		rankedBy[r.Metric] = true
+	}
+	if c.Rules.SlowSQL.MaxExplains < 0 {
+		errs = append(errs, "rules.slow_sql.max_explains must not be negative")
+	}
+	if d, err := c.Rules.SlowSQL.ExplainTimeoutParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("rules.slow_sql.explain_timeout is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "rules.slow_sql.explain_timeout must not be negative")
+	}
+	switch c.Rules.Regression.BaselineScope {
+	case "", BaselineScopeCurrentQueries, BaselineScopeWindow:
+	default:
+		errs = append(errs, fmt.Sprintf("rules.regression.baseline_scope must be %q or %q", BaselineScopeCurrentQueries, BaselineScopeWindow))
+	}
+	switch c.Rules.Regression.Metric {
+	case "", RegressionMetricMean, RegressionMetricTail:
+	default:
+		errs = append(errs, fmt.Sprintf("rules.regression.metric must be %q or %q", RegressionMetricMean, RegressionMetricTail))
+	}
+	if c.Rules.Regression.TailFactor < 0 {
+		errs = append(errs, "rules.regression.tail_factor must not be negative")
+	}
+	if c.Rules.Regression.WarmupRuns < 0 {
+		errs = append(errs, "rules.regression.warmup_runs must not be negative")
+	}
+	if d, err := c.Rules.Regression.MinQueryAgeParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("rules.regression.min_query_age is invalid: %v", err))
+	} else if d < 0 {
+		errs = append(errs, "rules.regression.min_query_age must not be negative")
+	}
+	if c.Rules.IndexSuggestion.MinAffectedQueries < 0 {
+		errs = append(errs, "rules.index_suggestion.min_affected_queries must not be negative")
+	}
+	if r := c.Rules.IndexSuggestion.MaxScanErrorRatio; r < 0 || r > 1 {
+		errs = append(errs, "rules.index_suggestion.max_scan_error_ratio must be between 0 and 1")
+	}
+	errs = append(errs, c.Rules.validateThresholds("rules")...)
+	if c.Rules.CrossServer.ThresholdPercent < 0 {
+		errs = append(errs, "rules.cross_server.threshold_percent must not be negative")
+	}
+	if c.Rules.CrossServer.ReferenceSrvID < 0 {
+		errs = append(errs, "rules.cross_server.reference_srvid must not be negative")
+	}
+	if c.Rules.CrossServer.MinCalls < 0 {
+		errs = append(errs, "rules.cross_server.min_calls must not be negative")
+	}
+	if c.Rules.WorkloadGrowth.ThresholdPercent < 0 {
+		errs = append(errs, "rules.workload_growth.threshold_percent must not be negative")
+	}
+	if c.Rules.WorkloadGrowth.TopContributors < 0 {
+		errs = append(errs, "rules.workload_growth.top_contributors must not be negative")
+	}
+	if p := c.Rules.Connections.MaxPercent; p < 0 || p > 100 {
+		errs = append(errs, "rules.connections.max_percent must be between 0 and 100")
+	}
+	errs = append(errs, c.Rules.validateCooldowns()...)
+	errs = append(errs, c.validateDatabaseOverrides()...)
+	customNames := make(map[string]bool, len(c.Rules.Custom))
+	for i := range c.Rules.Custom {
+		rule := &c.Rules.Custom[i]
+		errs = append(errs, rule.validate(i)...)
+		if rule.Name != "" && customNames[rule.Name] {
+			errs = append(errs, fmt.Sprintf("rules.custom: duplicate rule name %q", rule.Name))
+		}
+		customNames[rule.Name] = true
+	}
+
+	// Validate label keys
+	for k := range c.Labels {
+		if strings.TrimSpace(k) == "" {
+			errs = append(errs, "labels: keys must not be empty")
+			break
+		}
+	}
+	for db, labels := range c.DatabaseLabels {
+		for k := range labels {
+			if strings.TrimSpace(k) == "" {
+				errs = append(errs, fmt.Sprintf("database_labels.%s: keys must not be empty", db))
+				break
+			}
+		}
 	}
 
 	if len(errs) > 0 {
@@ -277,3 +1712,382 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// validateCooldowns checks the cooldown of every built-in rule; custom rules
+// check their own.
+func (r *RulesConfig) validateCooldowns() []string {
+	var errs []string
+	check := func(key, s string) {
+		if err := cooldownError(key, s); err != "" {
+			errs = append(errs, err)
+		}
+	}
+	check("rules.slow_sql", r.SlowSQL.Cooldown)
+	check("rules.regression", r.Regression.Cooldown)
+	check("rules.index_suggestion", r.IndexSuggestion.Cooldown)
+	check("rules.call_spike", r.CallSpike.Cooldown)
+	check("rules.waits", r.Waits.Cooldown)
+	check("rules.flapping", r.Flapping.Cooldown)
+	check("rules.vacuum", r.Vacuum.Cooldown)
+	check("rules.cross_server", r.CrossServer.Cooldown)
+	check("rules.workload_growth", r.WorkloadGrowth.Cooldown)
+	check("rules.connections", r.Connections.Cooldown)
+	return errs
+}
+
+// cooldownError describes what is wrong with the cooldown s of the rule at key,
+// or returns "" when it is empty or valid.
+func cooldownError(key, s string) string {
+	if s == "" {
+		return ""
+	}
+	if d, err := time.ParseDuration(s); err != nil {
+		return fmt.Sprintf("%s.cooldown is invalid: %v", key, err)
+	} else if d < 0 {
+		return key + ".cooldown must not be negative"
+	}
+	return ""
+}
+
+// validateThresholds checks the rule thresholds that database_overrides can
+// also set; key prefixes every error ("rules" or "database_overrides.<db>").
+func (r *RulesConfig) validateThresholds(key string) []string {
+	var errs []string
+	if r.CallSpike.ThresholdPercent < 0 {
+		errs = append(errs, key+".call_spike.threshold_percent must not be negative")
+	}
+	if r.CallSpike.MinCalls < 0 {
+		errs = append(errs, key+".call_spike.min_calls must not be negative")
+	}
+	if p := r.Waits.MinPercent; p < 0 || p > 100 {
+		errs = append(errs, key+".waits.min_percent must be between 0 and 100")
+	}
+	if r.Waits.ProfilePeriod != "" {
+		if d, err := r.Waits.ProfilePeriodParsed(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.waits.profile_period is invalid: %v", key, err))
+		} else if d <= 0 {
+			errs = append(errs, key+".waits.profile_period must be positive")
+		}
+	}
+	if r.Flapping.MaxCV < 0 {
+		errs = append(errs, key+".flapping.max_cv must not be negative")
+	}
+	if r.Flapping.MaxCV > 0 {
+		if w := r.Flapping.Windows; w < 3 || w > MaxFlappingWindows {
+			errs = append(errs, fmt.Sprintf("%s.flapping.windows must be between 3 and %d", key, MaxFlappingWindows))
+		}
+	}
+	if r.Flapping.MinCalls < 0 {
+		errs = append(errs, key+".flapping.min_calls must not be negative")
+	}
+	if p := r.Vacuum.MaxDeadRatioPercent; p < 0 || p > 100 {
+		errs = append(errs, key+".vacuum.max_dead_ratio_percent must be between 0 and 100")
+	}
+	if d, err := r.Vacuum.MaxAgeParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.vacuum.max_age is invalid: %v", key, err))
+	} else if d < 0 {
+		errs = append(errs, key+".vacuum.max_age must not be negative")
+	}
+	if r.Vacuum.MinDeadTuples < 0 {
+		errs = append(errs, key+".vacuum.min_dead_tuples must not be negative")
+	}
+	return errs
+}
+
+// validate checks a repository's connection settings; key prefixes every
+// error ("database", or "database[i]" for an entry of a list).
+func (d *DatabaseConfig) validate(key string) []string {
+	var errs []string
+	if d.Host == "" {
+		errs = append(errs, key+".host is required")
+	}
+	validExpectedExtensions := map[string]bool{"pg_stat_kcache": true, "pg_qualstats": true}
+	seenInvalid := make(map[string]bool)
+	for _, ext := range d.ExpectedExtensions {
+		if !validExpectedExtensions[ext] && !seenInvalid[ext] {
+			seenInvalid[ext] = true
+			errs = append(errs, fmt.Sprintf("%s.expected_extensions: %q is not allowed; use pg_stat_kcache and/or pg_qualstats", key, ext))
+		}
+	}
+
+	for i, stmt := range d.InitSQL {
+		if err := checkInitSQL(stmt); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.init_sql[%d]: %v", key, i, err))
+		}
+	}
+
+	if n := d.MaxConcurrentQueries; n < 0 || n > DatabasePoolSize {
+		errs = append(errs, fmt.Sprintf("%s.max_concurrent_queries must be between 1 and %d (the connection pool size)", key, DatabasePoolSize))
+	}
+	if t, err := d.AcquireTimeoutParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.acquire_timeout is invalid: %v", key, err))
+	} else if t < 0 {
+		errs = append(errs, key+".acquire_timeout must not be negative")
+	}
+	if d.Retries < 0 {
+		errs = append(errs, key+".retries must not be negative")
+	}
+	if t, err := d.RetryDelayParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.retry_delay is invalid: %v", key, err))
+	} else if t < 0 {
+		errs = append(errs, key+".retry_delay must not be negative")
+	}
+	if d.CursorBatchSize < 0 {
+		errs = append(errs, key+".cursor_batch_size must not be negative")
+	}
+	if n := d.MaxQueryRows; n < 0 || n > MaxQueryRowsLimit {
+		errs = append(errs, fmt.Sprintf("%s.max_query_rows must be between 1 and %d", key, MaxQueryRowsLimit))
+	}
+	if d.SSLMode != "" && !slices.Contains(SSLModes, d.SSLMode) {
+		errs = append(errs, fmt.Sprintf("%s.sslmode %q is invalid: must be one of: %s", key, d.SSLMode, strings.Join(SSLModes, " ")))
+	}
+	if d.IAMAuth {
+		validIAMSSLModes := map[string]bool{"require": true, "verify-ca": true, "verify-full": true}
+		if !validIAMSSLModes[d.SSLMode] {
+			errs = append(errs, fmt.Sprintf("%s.sslmode must be require, verify-ca or verify-full when %s.iam_auth is enabled", key, key))
+		}
+	}
+	if m := d.Monitored; m != nil {
+		errs = append(errs, m.validate(key+".monitored")...)
+		// The monitored instance is not a PoWA repository
+		if m.Name != "" || len(m.ExpectedExtensions) > 0 || m.StrictExtensions || m.Monitored != nil {
+			errs = append(errs, key+".monitored only takes connection keys (no name, expected_extensions, strict_extensions or monitored)")
+		}
+	}
+	return errs
+}
+
+// validate checks a notifier's settings; key prefixes every error ("notifier"
+// or "notifier.escalation").
+func (n *NotifierConfig) validate(key string) []string {
+	var errs []string
+	validNotifierTypes := map[string]bool{"wecom": true, "console": true, "csv": true, "syslog": true, "ndjson": true, "kafka": true, "sns": true}
+	if !validNotifierTypes[n.Type] {
+		errs = append(errs, key+".type must be one of: wecom, console, csv, syslog, ndjson, kafka, sns")
+	}
+
+	if d, err := n.ForceIntervalParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.force_interval is invalid: %v", key, err))
+	} else if d < 0 {
+		errs = append(errs, key+".force_interval must not be negative")
+	}
+	switch n.Mode {
+	case "", NotifierModeFull, NotifierModeDelta:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.mode must be %q or %q", key, NotifierModeFull, NotifierModeDelta))
+	}
+	switch n.Verbosity {
+	case "", VerbositySummary, VerbosityNormal, VerbosityDetailed:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.verbosity must be one of: %s, %s, %s",
+			key, VerbositySummary, VerbosityNormal, VerbosityDetailed))
+	}
+	if _, err := template.New("title").Parse(n.TitleTemplate); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.title_template is invalid: %v", key, err))
+	}
+	if err := checkQueryURLTemplate(n.QueryURLTemplate); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.query_url_template is invalid: %v", key, err))
+	}
+	if p := n.Precision; p != nil && (*p < 0 || *p > 6) {
+		errs = append(errs, key+".precision must be between 0 and 6")
+	}
+
+	if n.Type == "syslog" {
+		errs = append(errs, n.Syslog.validate(key)...)
+	}
+	if n.Type == "kafka" {
+		errs = append(errs, n.Kafka.validate(key)...)
+	}
+	if n.Type == "sns" {
+		errs = append(errs, n.SNS.validate(key)...)
+	}
+
+	switch n.Console.Stream {
+	case "", ConsoleStreamStdout, ConsoleStreamStderr:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.console.stream must be %q or %q", key, ConsoleStreamStdout, ConsoleStreamStderr))
+	}
+	switch n.Console.Color {
+	case "", ConsoleColorAuto, ConsoleColorAlways, ConsoleColorNever:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.console.color must be one of: %s, %s, %s",
+			key, ConsoleColorAuto, ConsoleColorAlways, ConsoleColorNever))
+	}
+
+	// Validate notifier webhook URL
+	if n.Type == "wecom" && n.WebhookURL == "" {
+		errs = append(errs, key+".webhook_url is required when type is 'wecom'")
+	}
+	if n.WebhookURL != "" {
+		if u, err := url.Parse(n.WebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, key+".webhook_url is invalid: expected an absolute URL")
+		}
+	}
+	switch n.WeCom.Format {
+	case "", WeComFormatMarkdown, WeComFormatText:
+	case WeComFormatTemplateCard:
+		// WeCom rejects a text_notice card without a card_action
+		if u, err := url.Parse(n.WeCom.CardURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("%s.wecom.card_url must be an absolute URL when %s.wecom.format is %s", key, key, WeComFormatTemplateCard))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s.wecom.format must be one of: %s, %s, %s",
+			key, WeComFormatMarkdown, WeComFormatText, WeComFormatTemplateCard))
+	}
+
+	if n.ProxyURL != "" {
+		if u, err := url.Parse(n.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("%s.proxy_url %q is invalid: expected scheme://host[:port]", key, n.ProxyURL))
+		}
+	}
+	if _, err := n.RetryDelayParsed(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.retry_delay is invalid: %v", key, err))
+	}
+	return errs
+}
+
+// validate checks the Kafka brokers, topic, message granularity and SASL/TLS settings.
+func (k *KafkaConfig) validate(key string) []string {
+	var errs []string
+	if len(k.Brokers) == 0 {
+		errs = append(errs, fmt.Sprintf("%s.kafka.brokers is required when %s.type is kafka", key, key))
+	}
+	for _, b := range k.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.kafka.brokers: %q is invalid: expected host:port", key, b))
+		}
+	}
+	if k.Topic == "" {
+		errs = append(errs, fmt.Sprintf("%s.kafka.topic is required when %s.type is kafka", key, key))
+	}
+	switch k.Message {
+	case "", KafkaMessageFinding, KafkaMessageAlert:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.kafka.message must be %q or %q", key, KafkaMessageFinding, KafkaMessageAlert))
+	}
+	if m := k.SASL.Mechanism; m != "" {
+		if !slices.Contains(KafkaSASLMechanisms, m) {
+			errs = append(errs, fmt.Sprintf("%s.kafka.sasl.mechanism %q is invalid: must be one of: %s", key, m, strings.Join(KafkaSASLMechanisms, ", ")))
+		}
+		if k.SASL.Username == "" {
+			errs = append(errs, fmt.Sprintf("%s.kafka.sasl.username is required when %s.kafka.sasl.mechanism is set", key, key))
+		}
+	}
+	if !k.TLS.Enabled && (k.TLS.CAFile != "" || k.TLS.InsecureSkipVerify) {
+		errs = append(errs, fmt.Sprintf("%s.kafka.tls.ca_file and insecure_skip_verify require %s.kafka.tls.enabled", key, key))
+	}
+	return errs
+}
+
+// validate checks the SNS topic ARN, arn:<partition>:sns:<region>:<account>:<topic>.
+func (s *SNSConfig) validate(key string) []string {
+	if s.TopicARN == "" {
+		return []string{fmt.Sprintf("%s.sns.topic_arn is required when %s.type is sns", key, key)}
+	}
+	parts := strings.Split(s.TopicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[5] == "" {
+		return []string{fmt.Sprintf("%s.sns.topic_arn %q is invalid: expected arn:aws:sns:<region>:<account>:<topic>", key, s.TopicARN)}
+	}
+	return nil
+}
+
+// validate checks the syslog receiver address, network, facility and severity mapping.
+func (s *SyslogConfig) validate(key string) []string {
+	var errs []string
+	if s.Address == "" {
+		errs = append(errs, fmt.Sprintf("%s.syslog.address is required when %s.type is syslog", key, key))
+	} else if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.syslog.address %q is invalid: expected host:port", key, s.Address))
+	}
+	if s.Network != "" && s.Network != "udp" && s.Network != "tcp" {
+		errs = append(errs, key+".syslog.network must be udp or tcp")
+	}
+	if _, ok := SyslogFacilityCode(s.Facility); s.Facility != "" && !ok {
+		errs = append(errs, fmt.Sprintf("%s.syslog.facility %q is not a valid syslog facility", key, s.Facility))
+	}
+	for sev, name := range s.Severities {
+		if _, ok := SyslogSeverityCode(name); !ok {
+			errs = append(errs, fmt.Sprintf("%s.syslog.severities[%s]: %q is not a valid syslog severity", key, sev, name))
+		}
+	}
+	return errs
+}
+
+// QueryURLPlaceholders are the fields notifier.query_url_template can insert.
+var QueryURLPlaceholders = []string{"{srvid}", "{server}", "{db}", "{queryid}"}
+
+// queryURLPlaceholder matches a {name} placeholder.
+var queryURLPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkQueryURLTemplate reports unknown placeholders and templates that do not
+// form an absolute http(s) URL. An empty template is valid.
+func checkQueryURLTemplate(tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	for _, p := range queryURLPlaceholder.FindAllString(tmpl, -1) {
+		if !slices.Contains(QueryURLPlaceholders, p) {
+			return fmt.Errorf("unknown placeholder %s (use %s)", p, strings.Join(QueryURLPlaceholders, ", "))
+		}
+	}
+	u, err := url.Parse(queryURLPlaceholder.ReplaceAllString(tmpl, "1"))
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// checkInitSQL accepts a single SET or SELECT statement. Sessions are read-only
+// anyway; this catches typos and pasted scripts before the first connection.
+func checkInitSQL(stmt string) error {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
+		return fmt.Errorf("statement is empty")
+	}
+	if verb := strings.ToUpper(fields[0]); verb != "SET" && verb != "SELECT" {
+		return fmt.Errorf("%q is not allowed; only SET and SELECT statements can run at connect time", fields[0])
+	}
+	if strings.Contains(trimmed, ";") {
+		return fmt.Errorf("must be a single statement")
+	}
+	return nil
+}
+
+// loadLocation resolves an IANA timezone name for the given config key.
+// cronParser accepts what the scheduler's cron.WithSeconds() does: six fields
+// starting with seconds, or a descriptor such as @daily or @every 1h.
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validateCron parses schedule.cron and rejects schedules that fire more than
+// once a minute: PoWA snapshots are minutes apart, so such runs would analyze
+// the same data over and over while loading the repository.
+func validateCron(spec string) error {
+	sched, err := cronParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("schedule.cron %q is invalid: %v (want six fields, second minute hour day month dow, e.g. \"0 0 9 * * 1\")", spec, err)
+	}
+	tooFrequent := false
+	switch s := sched.(type) {
+	case *cron.SpecSchedule:
+		// The top bit marks a `*` field; more than one second set fires several times a minute
+		tooFrequent = bits.OnesCount64(s.Second&^(1<<63)) > 1
+	case cron.ConstantDelaySchedule:
+		tooFrequent = s.Delay < time.Minute
+	}
+	if tooFrequent {
+		return fmt.Errorf("schedule.cron %q runs more than once a minute; check the leading seconds field (e.g. \"0 */5 * * * *\" runs every 5 minutes)", spec)
+	}
+	return nil
+}
+
+func loadLocation(key, name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q is invalid: %v", key, name, err)
+	}
+	return loc, nil
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -76,6 +78,423 @@ func TestExpandEnvVars(t *testing.T) {
 	}
 }
 
+func TestLoad_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	passwordFile := writeFile("password", "s3cret\n")
+	webhookFile := writeFile("webhook", "  https://example.com/hook\n")
+
+	tests := []struct {
+		name        string
+		yaml        string
+		wantErr     string
+		wantPass    string
+		wantWebhook string
+	}{
+		{
+			name:        "resolved from files",
+			yaml:        "database:\n  password_file: " + passwordFile + "\nnotifier:\n  webhook_url_file: " + webhookFile + "\n",
+			wantPass:    "s3cret",
+			wantWebhook: "https://example.com/hook",
+		},
+		{
+			name:     "inline only",
+			yaml:     "database:\n  password: inline\n",
+			wantPass: "inline",
+		},
+		{
+			name:    "inline and file",
+			yaml:    "database:\n  password: inline\n  password_file: " + passwordFile + "\n",
+			wantErr: "database.password and database.password_file are mutually exclusive",
+		},
+		{
+			name:    "missing file",
+			yaml:    "notifier:\n  webhook_url_file: " + filepath.Join(dir, "missing") + "\n",
+			wantErr: "reading notifier.webhook_url_file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeFile("config.yaml", tt.yaml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Database.Password != tt.wantPass {
+				t.Errorf("Database.Password = %q, want %q", cfg.Database.Password, tt.wantPass)
+			}
+			if cfg.Notifier.WebhookURL != tt.wantWebhook {
+				t.Errorf("Notifier.WebhookURL = %q, want %q", cfg.Notifier.WebhookURL, tt.wantWebhook)
+			}
+		})
+	}
+}
+
+func TestLoad_EnvOnly(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	for k, v := range map[string]string{
+		"POWA_SENTINEL_DATABASE_HOST":                      "db.internal",
+		"POWA_SENTINEL_DATABASE_PORT":                      "6432",
+		"POWA_SENTINEL_DATABASE_USER":                      "powa_readonly",
+		"POWA_SENTINEL_DATABASE_PASSWORD":                  "s3cret",
+		"POWA_SENTINEL_DATABASE_SSLMODE":                   "require",
+		"POWA_SENTINEL_DATABASE_EXPECTED_EXTENSIONS":       "pg_stat_kcache, pg_qualstats",
+		"POWA_SENTINEL_SCHEDULE_CRON":                      "0 0 * * * *",
+		"POWA_SENTINEL_ANALYSIS_WINDOW_DURATION":           "1h",
+		"POWA_SENTINEL_RULES_REGRESSION_THRESHOLD_PERCENT": "75.5",
+		"POWA_SENTINEL_NOTIFIER_TYPE":                      "wecom",
+		"POWA_SENTINEL_NOTIFIER_WEBHOOK_URL":               "https://example.com/hook",
+		"POWA_SENTINEL_NOTIFIER_WECOM_FORMAT":              "text",
+		"POWA_SENTINEL_NOTIFIER_SUPPRESS_IF_UNCHANGED":     "true",
+		"POWA_SENTINEL_SERVER_PORT":                        "9090",
+	} {
+		t.Setenv(k, v)
+	}
+
+	cfg, err := Load(missing)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if cfg.Database.Host != "db.internal" || cfg.Database.Port != 6432 || cfg.Database.Password != "s3cret" ||
+		cfg.Database.SSLMode != "require" {
+		t.Errorf("Database = %+v, want the values from the environment", cfg.Database)
+	}
+	if got := cfg.Database.ExpectedExtensions; len(got) != 2 || got[0] != "pg_stat_kcache" || got[1] != "pg_qualstats" {
+		t.Errorf("ExpectedExtensions = %q, want the comma-separated list", got)
+	}
+	if cfg.Schedule.Cron != "0 0 * * * *" || cfg.Analysis.WindowDuration != "1h" ||
+		cfg.Rules.Regression.ThresholdPercent != 75.5 || cfg.Server.Port != 9090 {
+		t.Errorf("schedule/analysis/rules/server not taken from the environment: %+v", cfg)
+	}
+	if cfg.Notifier.Type != "wecom" || cfg.Notifier.WebhookURL != "https://example.com/hook" ||
+		cfg.Notifier.WeCom.Format != "text" || !cfg.Notifier.SuppressIfUnchanged {
+		t.Errorf("Notifier = %+v, want the values from the environment", cfg.Notifier)
+	}
+	// Keys without a variable still get their defaults
+	if cfg.Database.DBName != "powa" || cfg.Analysis.ComparisonOffset != "168h" {
+		t.Errorf("defaults not applied: dbname %q, comparison_offset %q", cfg.Database.DBName, cfg.Analysis.ComparisonOffset)
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("database:\n  host: from-file\n  user: file_user\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POWA_SENTINEL_DATABASE_HOST", "from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Database.Host != "from-env" {
+		t.Errorf("Database.Host = %q, want the environment to override the file", cfg.Database.Host)
+	}
+	if cfg.Database.User != "file_user" {
+		t.Errorf("Database.User = %q, want the file value when no variable is set", cfg.Database.User)
+	}
+}
+
+func TestLoad_SkipEmptyQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("database:\n  host: localhost\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.Analysis.SkipEmptyQueriesEnabled() {
+		t.Error("statements without query text should be skipped by default")
+	}
+
+	if err := os.WriteFile(path, []byte("analysis:\n  skip_empty_queries: false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Analysis.SkipEmptyQueriesEnabled() {
+		t.Error("skip_empty_queries: false should keep statements without query text")
+	}
+
+	t.Setenv("POWA_SENTINEL_ANALYSIS_SKIP_EMPTY_QUERIES", "true")
+	if cfg, err = Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.Analysis.SkipEmptyQueriesEnabled() {
+		t.Error("POWA_SENTINEL_ANALYSIS_SKIP_EMPTY_QUERIES should override the file")
+	}
+}
+
+func TestLoad_RankByList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	tests := []struct {
+		name string
+		yaml string
+		want []SlowSQLRanking
+	}{
+		{"single metric", "rank_by: mean_time", []SlowSQLRanking{{"mean_time", 10}}},
+		{"comma-separated", `rank_by: "total_time, mean_time:5"`, []SlowSQLRanking{{"total_time", 10}, {"mean_time", 5}}},
+		{"list", "rank_by: [total_time, \"io_time:3\"]", []SlowSQLRanking{{"total_time", 10}, {"io_time", 3}}},
+		{"default", "top_n: 4", []SlowSQLRanking{{"total_time", 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "database:\n  host: localhost\nrules:\n  slow_sql:\n    " + tt.yaml + "\n"
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := cfg.Rules.SlowSQL.Rankings(); !slices.Equal(got, tt.want) {
+				t.Errorf("Rankings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("POWA_SENTINEL_RULES_SLOW_SQL_RANK_BY", "cpu_time:2,total_time")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, want := cfg.Rules.SlowSQL.Rankings(), []SlowSQLRanking{{"cpu_time", 2}, {"total_time", 4}}; !slices.Equal(got, want) {
+		t.Errorf("Rankings() with env override = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_EnvErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	if _, err := Load(missing); err == nil || !strings.Contains(err.Error(), "reading config file") {
+		t.Errorf("Load() without file or variables error = %v, want a read error", err)
+	}
+
+	t.Setenv("POWA_SENTINEL_DATABASE_PORT", "five")
+	if _, err := Load(missing); err == nil || !strings.Contains(err.Error(), "POWA_SENTINEL_DATABASE_PORT") {
+		t.Errorf("Load() error = %v, want one naming POWA_SENTINEL_DATABASE_PORT", err)
+	}
+
+	t.Setenv("POWA_SENTINEL_DATABASE_PORT", "5432")
+	t.Setenv("POWA_SENTINEL_LABELS", "env=prod")
+	if _, err := Load(missing); err == nil || !strings.Contains(err.Error(), "only be set in the config file") {
+		t.Errorf("Load() error = %v, want maps to be rejected", err)
+	}
+}
+
+func TestLoad_DatabaseOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `rules:
+  regression:
+    threshold_percent: 20
+database_overrides:
+  analytics:
+    regression:
+      threshold_percent: 200
+    vacuum:
+      max_age: 720h
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.RulesFor("analytics"); got.Regression.ThresholdPercent != 200 || got.Vacuum.MaxAge != "720h" {
+		t.Errorf("RulesFor(analytics) = threshold %v, max_age %q, want 200 and 720h", got.Regression.ThresholdPercent, got.Vacuum.MaxAge)
+	}
+	if got := cfg.RulesFor("oltp"); got.Regression.ThresholdPercent != 20 || got.Vacuum.MaxAge != "" {
+		t.Errorf("RulesFor(oltp) = threshold %v, max_age %q, want the global rules", got.Regression.ThresholdPercent, got.Vacuum.MaxAge)
+	}
+	if cfg.Rules.Regression.ThresholdPercent != 20 {
+		t.Errorf("global threshold changed to %v", cfg.Rules.Regression.ThresholdPercent)
+	}
+
+	for _, bad := range []string{
+		"database_overrides:\n  analytics:\n    regression:\n      threshold: 200\n",
+		"database_overrides:\n  analytics:\n    slow_sql:\n      top_n: 5\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "unknown rule field") {
+			t.Errorf("Load(%q) error = %v, want an unknown rule field error", bad, err)
+		}
+	}
+}
+
+func TestLoad_Repositories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `database:
+  - name: east
+    host: east.internal
+  - name: west
+    host: west.internal
+    port: 6432
+schedule:
+  concurrency: 1
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	dbs := cfg.Databases()
+	if len(dbs) != 2 || dbs[0].Name != "east" || dbs[1].Name != "west" {
+		t.Fatalf("Databases() = %+v, want east and west", dbs)
+	}
+	if dbs[0].Port != 5432 || dbs[0].DBName != "powa" || dbs[1].Port != 6432 {
+		t.Errorf("repository defaults = port %d, dbname %q; west port %d", dbs[0].Port, dbs[0].DBName, dbs[1].Port)
+	}
+	if got := cfg.Schedule.RepositoryConcurrency(); got != 1 {
+		t.Errorf("RepositoryConcurrency() = %d, want 1", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// A single mapping is one unnamed repository
+	single := &Config{}
+	if got := single.Databases(); len(got) != 1 || got[0] != &single.Database {
+		t.Errorf("Databases() of a single mapping = %+v", got)
+	}
+
+	t.Setenv("POWA_SENTINEL_DATABASE_HOST", "other.internal")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "cannot be used when database is a list") {
+		t.Errorf("Load() error = %v, want DATABASE_* variables rejected for a list", err)
+	}
+	os.Unsetenv("POWA_SENTINEL_DATABASE_HOST")
+
+	if err := os.WriteFile(path, []byte("database: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "database list is empty") {
+		t.Errorf("Load() error = %v, want an empty list rejected", err)
+	}
+}
+
+func TestLoad_WebhookTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "plain URL",
+			yaml: "notifier:\n  webhook_url: https://hooks.example.com/a?key={x}\n",
+			want: "https://hooks.example.com/a?key={x}",
+		},
+		{
+			name: "resolved from environment",
+			yaml: "environment: staging\nnotifier:\n  webhook_url: https://hooks-{{.Env}}.example.com/send\n",
+			want: "https://hooks-staging.example.com/send",
+		},
+		{
+			name:    "environment not set",
+			yaml:    "notifier:\n  webhook_url: https://hooks-{{.Env}}.example.com/send\n",
+			wantErr: "notifier.webhook_url is a template but environment is not set",
+		},
+		{
+			name:    "unknown field",
+			yaml:    "environment: prod\nnotifier:\n  webhook_url: https://{{.Region}}.example.com\n",
+			wantErr: "rendering notifier.webhook_url template",
+		},
+		{
+			name:    "malformed template",
+			yaml:    "environment: prod\nnotifier:\n  webhook_url: https://{{.Env.example.com\n",
+			wantErr: "parsing notifier.webhook_url template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Notifier.WebhookURL != tt.want {
+				t.Errorf("webhook_url = %q, want %q", cfg.Notifier.WebhookURL, tt.want)
+			}
+		})
+	}
+
+	// The environment can come from POWA_SENTINEL_ENVIRONMENT, and the
+	// escalation webhook is rendered too
+	yaml := "notifier:\n  webhook_url: https://{{.Env}}.example.com/a\n  escalation:\n    after_runs: 2\n    webhook_url: https://{{.Env}}.example.com/pager\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POWA_SENTINEL_ENVIRONMENT", "prod")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Notifier.WebhookURL != "https://prod.example.com/a" || cfg.Notifier.Escalation.WebhookURL != "https://prod.example.com/pager" {
+		t.Errorf("webhook URLs = %q and %q, want the prod hosts", cfg.Notifier.WebhookURL, cfg.Notifier.Escalation.WebhookURL)
+	}
+}
+
+func TestLoad_Escalation(t *testing.T) {
+	dir := t.TempDir()
+	webhookFile := filepath.Join(dir, "pager")
+	if err := os.WriteFile(webhookFile, []byte("https://example.com/pager\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	yaml := "notifier:\n  type: console\n  escalation:\n    after_runs: 3\n    type: wecom\n    webhook_url_file: " + webhookFile + "\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	esc := cfg.Notifier.Escalation
+	if esc == nil {
+		t.Fatal("Notifier.Escalation = nil, want parsed escalation")
+	}
+	if esc.AfterRuns != 3 || esc.Type != "wecom" || esc.WebhookURL != "https://example.com/pager" {
+		t.Errorf("Escalation = after_runs %d, type %q, webhook %q", esc.AfterRuns, esc.Type, esc.WebhookURL)
+	}
+	if esc.Retries != 3 || esc.RetryDelay != "1s" {
+		t.Errorf("Escalation defaults = retries %d, retry_delay %q, want 3 and 1s", esc.Retries, esc.RetryDelay)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
 func TestApplyDefaults(t *testing.T) {
 	cfg := &Config{}
 	applyDefaults(cfg)
@@ -93,17 +512,30 @@ func TestApplyDefaults(t *testing.T) {
 	if cfg.Database.DBName != "powa" {
 		t.Errorf("Database.DBName = %q, want %q", cfg.Database.DBName, "powa")
 	}
+	if cfg.Database.SSLMode != "prefer" {
+		t.Errorf("Database.SSLMode = %q, want %q", cfg.Database.SSLMode, "prefer")
+	}
 
 	// Check rules defaults
 	if cfg.Rules.SlowSQL.TopN != 10 {
 		t.Errorf("Rules.SlowSQL.TopN = %d, want %d", cfg.Rules.SlowSQL.TopN, 10)
 	}
-	if cfg.Rules.SlowSQL.RankBy != "total_time" {
+	if !slices.Equal(cfg.Rules.SlowSQL.RankBy, RankByList{"total_time"}) {
 		t.Errorf("Rules.SlowSQL.RankBy = %q, want %q", cfg.Rules.SlowSQL.RankBy, "total_time")
 	}
+	if cfg.Rules.SlowSQL.IncludeExplain || cfg.Rules.SlowSQL.MaxExplains != 1 || cfg.Rules.SlowSQL.ExplainTimeout != "5s" {
+		t.Errorf("Rules.SlowSQL explain defaults = %v, %d, %q, want false, 1, 5s",
+			cfg.Rules.SlowSQL.IncludeExplain, cfg.Rules.SlowSQL.MaxExplains, cfg.Rules.SlowSQL.ExplainTimeout)
+	}
 	if cfg.Rules.Regression.ThresholdPercent != 50 {
 		t.Errorf("Rules.Regression.ThresholdPercent = %f, want %f", cfg.Rules.Regression.ThresholdPercent, 50.0)
 	}
+	if cfg.Rules.Regression.Metric != RegressionMetricMean || cfg.Rules.Regression.TailFactor != DefaultTailFactor {
+		t.Errorf("Rules.Regression metric = %q, tail_factor %v, want mean and %v", cfg.Rules.Regression.Metric, cfg.Rules.Regression.TailFactor, DefaultTailFactor)
+	}
+	if cfg.Rules.IndexSuggestion.MaxScanErrorRatio != 0.5 {
+		t.Errorf("Rules.IndexSuggestion.MaxScanErrorRatio = %v, want 0.5", cfg.Rules.IndexSuggestion.MaxScanErrorRatio)
+	}
 
 	// Check notifier defaults
 	if cfg.Notifier.Type != "console" {
@@ -131,7 +563,7 @@ func TestConfig_Validate(t *testing.T) {
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
 				Rules: RulesConfig{
-					SlowSQL:         SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"},
+					SlowSQL:         SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
 					Regression:      RegressionRuleConfig{ThresholdPercent: 50},
 					IndexSuggestion: IndexSuggestionRuleConfig{MinImprovementPercent: 30},
 				},
@@ -146,7 +578,7 @@ func TestConfig_Validate(t *testing.T) {
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
 				Rules: RulesConfig{
-					SlowSQL:         SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"},
+					SlowSQL:         SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
 					Regression:      RegressionRuleConfig{ThresholdPercent: 50},
 					IndexSuggestion: IndexSuggestionRuleConfig{MinImprovementPercent: 30},
 				},
@@ -160,7 +592,7 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
@@ -170,7 +602,7 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
 				Notifier: NotifierConfig{Type: "invalid", RetryDelay: "1s"},
 			},
 			wantErr: true,
@@ -180,7 +612,7 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
 				Notifier: NotifierConfig{Type: "wecom", WebhookURL: "", RetryDelay: "1s"},
 			},
 			wantErr: true,
@@ -190,7 +622,7 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "invalid", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
@@ -200,7 +632,7 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 0, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 0, RankBy: RankByList{"total_time"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
@@ -210,86 +642,1601 @@ func TestConfig_Validate(t *testing.T) {
 			cfg: Config{
 				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "invalid"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"invalid"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "valid expected_extensions",
+			name: "invalid explain_timeout",
 			cfg: Config{
-				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"pg_stat_kcache", "pg_qualstats"}},
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}, IncludeExplain: true, ExplainTimeout: "soon"}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max_explains",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}, MaxExplains: -1}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid redact pattern",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", RedactPatterns: []string{"("}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative significance weight",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", Weights: SignificanceWeights{TotalTime: -1}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid window mode",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", WindowMode: "rolling"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "syslog without address",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "syslog", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "syslog invalid facility",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "syslog", RetryDelay: "1s", Syslog: SyslogConfig{Address: "noc:514", Facility: "local9"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid syslog",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "syslog", RetryDelay: "1s", Syslog: SyslogConfig{Address: "noc:514", Network: "tcp", Facility: "local0"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "precision out of range",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Precision: intPtr(7)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom rule valid",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Custom: []CustomRuleConfig{{Name: "dead", Query: "SELECT n FROM t;", ThresholdColumn: "n", ThresholdOperator: ">", ThresholdValue: 1}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: false,
 		},
 		{
-			name: "invalid expected_extensions",
+			name: "custom rule not a select",
 			cfg: Config{
-				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "pg_qualstats"}},
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Custom: []CustomRuleConfig{{Name: "drop", Query: "DELETE FROM t", ThresholdColumn: "n", ThresholdOperator: ">"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid expected_extensions deduplicated",
+			name: "custom rule multiple statements",
 			cfg: Config{
-				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "powa", "other"}},
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
 				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Custom: []CustomRuleConfig{{Name: "multi", Query: "SELECT 1; DROP TABLE t", ThresholdColumn: "n", ThresholdOperator: ">"}}},
 				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
 			},
 			wantErr: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := tt.cfg.Validate()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestConfig_Validate_ExpectedExtensions_Deduplicated(t *testing.T) {
-	cfg := Config{
-		Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "powa", "other"}},
-		Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
-		Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: "total_time"}},
-		Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
-	}
-	err := cfg.Validate()
-	if err == nil {
-		t.Fatal("Validate() expected error for invalid expected_extensions")
-	}
-	msg := err.Error()
-	// Each invalid extension should be reported only once (powa once, other once)
-	if strings.Count(msg, `"powa"`) != 1 {
-		t.Errorf("expected exactly one error line for powa, got %d mentions in: %s", strings.Count(msg, `"powa"`), msg)
-	}
-	if strings.Count(msg, `"other"`) != 1 {
-		t.Errorf("expected exactly one error line for other, got %d mentions in: %s", strings.Count(msg, `"other"`), msg)
-	}
-}
-
-func TestDatabaseConfig_DSN(t *testing.T) {
-	cfg := DatabaseConfig{
-		Host:     "localhost",
-		Port:     5432,
-		User:     "testuser",
-		Password: "testpass",
-		DBName:   "testdb",
+		{
+			name: "custom rule invalid operator",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Custom: []CustomRuleConfig{{Name: "op", Query: "SELECT n FROM t", ThresholdColumn: "n", ThresholdOperator: "LIKE"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid force interval",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", SuppressIfUnchanged: true, ForceInterval: "daily"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "digest",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", DigestInterval: "24h", DigestBreakthrough: "critical"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "digest with delta mode",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", DigestInterval: "24h", Mode: NotifierModeDelta},
+			},
+			wantErr: true,
+		},
+		{
+			name: "digest breakthrough without interval",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", DigestBreakthrough: "high"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid digest breakthrough",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", DigestInterval: "24h", DigestBreakthrough: "urgent"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid cron descriptor",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "@every 1h", Timezone: "Asia/Shanghai"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "five-field cron",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "0 9 * * 1"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid cron field",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "0 0 25 * * *"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cron every second",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "* * * * * *"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cron several times a minute",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "*/10 * * * * *"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cron every few seconds",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "@every 30s"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid schedule timezone",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Cron: "0 0 9 * * 1", Timezone: "Mars/Olympus"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid min query age",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules: RulesConfig{
+					SlowSQL:    SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
+					Regression: RegressionRuleConfig{MinQueryAge: "a week"},
+				},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid console stream",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Console: ConsoleConfig{Stream: "stdlog"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid console color",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Console: ConsoleConfig{Color: "yes"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repositories",
+			cfg: Config{
+				Repositories: []DatabaseConfig{{Name: "east", Host: "east", Port: 5432}, {Name: "west", Host: "west", Port: 5432}},
+				Analysis:     AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "repository without name",
+			cfg: Config{
+				Repositories: []DatabaseConfig{{Name: "east", Host: "east", Port: 5432}, {Host: "west", Port: 5432}},
+				Analysis:     AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate repository name",
+			cfg: Config{
+				Repositories: []DatabaseConfig{{Name: "east", Host: "east", Port: 5432}, {Name: "east", Host: "west", Port: 5432}},
+				Analysis:     AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository without host",
+			cfg: Config{
+				Repositories: []DatabaseConfig{{Name: "east", Port: 5432}},
+				Analysis:     AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "baseline file with several repositories",
+			cfg: Config{
+				Repositories: []DatabaseConfig{{Name: "east", Host: "east", Port: 5432}, {Name: "west", Host: "west", Port: 5432}},
+				Analysis:     AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:        RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Regression: RegressionRuleConfig{BaselineFile: "baseline.json"}},
+				Notifier:     NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative schedule concurrency",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{Concurrency: -1},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "schedule failure backoff",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{FailureBackoff: "5m", FailureBackoffMax: "2h"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "schedule failure backoff above its cap",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Schedule: ScheduleConfig{FailureBackoff: "2h"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "monitored instance",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, Monitored: &DatabaseConfig{Host: "pg.internal", Port: 5432}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Connections: ConnectionsRuleConfig{MaxPercent: 80}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "monitored instance without host",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, Monitored: &DatabaseConfig{Port: 5432}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "monitored instance with expected extensions",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, Monitored: &DatabaseConfig{Host: "pg.internal", ExpectedExtensions: []string{"pg_qualstats"}}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}}, Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "connections max percent above 100",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Connections: ConnectionsRuleConfig{MaxPercent: 120}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative webhook url",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "wecom", RetryDelay: "1s", WebhookURL: "hooks.example.com/send"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification threshold",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", MinFindings: 3, MinSeverity: "medium"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative min findings",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", MinFindings: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid min severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", MinSeverity: "urgent"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "recovery severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", RecoverySeverity: "medium"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid recovery severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", RecoverySeverity: "warning"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "escalation with recovery severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Escalation: &EscalationConfig{
+					NotifierConfig: NotifierConfig{Type: "console", RetryDelay: "1s", RecoverySeverity: "high"}, AfterRuns: 2}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "escalation with min findings",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"}, Rules: RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Escalation: &EscalationConfig{
+					NotifierConfig: NotifierConfig{Type: "console", RetryDelay: "1s", MinFindings: 2}, AfterRuns: 2}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rule cooldown",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, IndexSuggestion: IndexSuggestionRuleConfig{Cooldown: "24h"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid rule cooldown",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}, Cooldown: "daily"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative rule cooldown",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Vacuum: VacuumRuleConfig{Cooldown: "-1h"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid custom rule cooldown",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432}, Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Custom: []CustomRuleConfig{{Name: "dead", Query: "SELECT n FROM t", ThresholdColumn: "n", ThresholdOperator: ">", Cooldown: "1 day"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "window within max_window_duration",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", MaxWindowDuration: "48h", MaxComparisonOffset: "720h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "window_duration exceeds max_window_duration",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "8760h", ComparisonOffset: "168h", MaxWindowDuration: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "max_window exceeds max_window_duration",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "168h", WindowMode: WindowModeSinceLastRun, MaxWindow: "720h", MaxWindowDuration: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "comparison_offset exceeds max_comparison_offset",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "8760h", MaxComparisonOffset: "720h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid max_window_duration",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", MaxWindowDuration: "a week"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown rank_by metric in list",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time", "rows"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rank_by count",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time", "mean_time:0"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate rank_by metric",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"mean_time", "mean_time:5"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rank_by list",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time", "mean_time:5"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid display timezone",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", DisplayTimezone: "Mars/Olympus"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative call spike min calls",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules: RulesConfig{
+					SlowSQL:   SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
+					CallSpike: CallSpikeRuleConfig{ThresholdPercent: 500, MinCalls: -1},
+				},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid max data age",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", MaxDataAge: "2 hours"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid escalation",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Escalation: &EscalationConfig{
+					NotifierConfig: NotifierConfig{Type: "console", RetryDelay: "1s"}, AfterRuns: 3,
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "severity routes",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Routes: map[string]*NotifierConfig{
+					"critical": {Type: "console", RetryDelay: "1s"},
+					"info":     {Type: "ndjson", RetryDelay: "1s"},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "raw metrics",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "sns", RetryDelay: "1s", SNS: SNSConfig{TopicARN: "arn:aws:sns:us-east-1:123456789012:powa"}, IncludeRawMetrics: true, RawMetricsLimit: 1000},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative raw metrics limit",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", IncludeRawMetrics: true, RawMetricsLimit: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "route with raw metrics",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Routes: map[string]*NotifierConfig{"info": {Type: "console", RetryDelay: "1s", IncludeRawMetrics: true}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "route for an unknown severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Routes: map[string]*NotifierConfig{"urgent": {Type: "console", RetryDelay: "1s"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "route wecom without webhook",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Routes: map[string]*NotifierConfig{"critical": {Type: "wecom", RetryDelay: "1s"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "route with min severity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Routes: map[string]*NotifierConfig{"info": {Type: "console", RetryDelay: "1s", MinSeverity: "high"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "escalation without after_runs",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Escalation: &EscalationConfig{
+					NotifierConfig: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "escalation wecom without webhook",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Escalation: &EscalationConfig{
+					NotifierConfig: NotifierConfig{Type: "wecom", RetryDelay: "1s"}, AfterRuns: 3,
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative notifier check interval",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Server:   ServerConfig{NotifierCheckInterval: "-5m"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "waits min percent above 100",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules: RulesConfig{
+					SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
+					Waits:   WaitsRuleConfig{MinPercent: 150},
+				},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown notifier verbosity",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", Verbosity: "verbose"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty excluded schema",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", ExcludeSchemas: []string{"audit", ""}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative regression warmup runs",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules: RulesConfig{
+					SlowSQL:    SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}},
+					Regression: RegressionRuleConfig{WarmupRuns: -1},
+				},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "init sql set and select",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432,
+					InitSQL: []string{"SET search_path TO powa, public", "set role powa_reader;", "SELECT set_config('statement_timeout', '30s', false)"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "init sql write statement",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, InitSQL: []string{"DELETE FROM powa_statements"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "init sql multiple statements",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, InitSQL: []string{"SET ROLE a; DROP TABLE b"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative top databases",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", TopDatabases: -1},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative top users",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", TopUsers: -1},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative fetch parallelism",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", FetchParallelism: -1},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative server query_metrics",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Server:   ServerConfig{QueryMetrics: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid title template",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", TitleTemplate: `[{{.Label "env"}}] {{.Count "critical"}} critical`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unparsable title template",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", TitleTemplate: "{{.Count"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "max_concurrent_queries within pool",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, MaxConcurrentQueries: 3},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "max_concurrent_queries above pool size",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, MaxConcurrentQueries: 6},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "acquire_timeout",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, AcquireTimeout: "5s"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid acquire_timeout",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, AcquireTimeout: "soon"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "database retries with retry budget",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, Retries: 2, RetryDelay: "500ms"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", RetryBudget: 4},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid database retry_delay",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, Retries: 2, RetryDelay: "soon"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative cursor_batch_size",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, UseCursor: true, CursorBatchSize: -1},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "max_query_rows above limit",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, MaxQueryRows: MaxQueryRowsLimit + 1},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max_query_rows",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, MaxQueryRows: -1},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative retry_budget",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", RetryBudget: -1},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max_concurrent_queries",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, MaxConcurrentQueries: -1},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wecom template_card with card_url",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "wecom", WebhookURL: "https://example.com/hook", RetryDelay: "1s", WeCom: WeComConfig{Format: "template_card", CardURL: "https://powa.example.com/"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "wecom template_card without card_url",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "wecom", WebhookURL: "https://example.com/hook", RetryDelay: "1s", WeCom: WeComConfig{Format: "template_card"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid wecom format",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "wecom", WebhookURL: "https://example.com/hook", RetryDelay: "1s", WeCom: WeComConfig{Format: "news"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "flapping rule enabled",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Flapping: FlappingRuleConfig{MaxCV: 0.5, Windows: 6}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "flapping windows above cap",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Flapping: FlappingRuleConfig{MaxCV: 0.5, Windows: 48}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative flapping max_cv",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Flapping: FlappingRuleConfig{MaxCV: -1, Windows: 6}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "vacuum rule enabled",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Vacuum: VacuumRuleConfig{MaxDeadRatioPercent: 20, MaxAge: "168h"}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "vacuum dead ratio above 100",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Vacuum: VacuumRuleConfig{MaxDeadRatioPercent: 120}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid vacuum max_age",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Vacuum: VacuumRuleConfig{MaxAge: "weekly"}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid query url template",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", QueryURLTemplate: "https://powa.example.com/server/{srvid}/database/{db}/query/{queryid}"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "query url template with unknown placeholder",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", QueryURLTemplate: "https://powa.example.com/query/{query_id}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative query url template",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", QueryURLTemplate: "/server/{srvid}/query/{queryid}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "heartbeat enabled",
+			cfg: Config{
+				Database:  DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:  AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:     RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:  NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Heartbeat: HeartbeatConfig{URL: "https://hc-ping.com/abc", StartURL: "https://hc-ping.com/abc/start", Timeout: "10s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative heartbeat url",
+			cfg: Config{
+				Database:  DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:  AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:     RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:  NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Heartbeat: HeartbeatConfig{URL: "hc-ping.com/abc", Timeout: "10s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "heartbeat fail_url without url",
+			cfg: Config{
+				Database:  DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:  AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:     RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:  NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Heartbeat: HeartbeatConfig{FailURL: "https://hc-ping.com/abc/fail", Timeout: "10s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid heartbeat timeout",
+			cfg: Config{
+				Database:  DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:  AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:     RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:  NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Heartbeat: HeartbeatConfig{URL: "https://hc-ping.com/abc", Timeout: "soon"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid database override",
+			cfg: Config{
+				Database:          DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:          AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:             RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:          NotifierConfig{Type: "console", RetryDelay: "1s"},
+				DatabaseOverrides: map[string]RuleOverrides{"analytics": {Waits: WaitsRuleOverride{MinPercent: floatPtr(50)}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "database override waits min_percent above 100",
+			cfg: Config{
+				Database:          DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:          AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:             RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier:          NotifierConfig{Type: "console", RetryDelay: "1s"},
+				DatabaseOverrides: map[string]RuleOverrides{"analytics": {Waits: WaitsRuleOverride{MinPercent: floatPtr(150)}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "database override enabling flapping with invalid windows",
+			cfg: Config{
+				Database:          DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis:          AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:             RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Flapping: FlappingRuleConfig{Windows: 2}},
+				Notifier:          NotifierConfig{Type: "console", RetryDelay: "1s"},
+				DatabaseOverrides: map[string]RuleOverrides{"analytics": {Flapping: FlappingRuleOverride{MaxCV: floatPtr(0.5)}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid soft timeout",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", SoftTimeout: "3m"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid soft timeout",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", SoftTimeout: "soon"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative soft timeout",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", SoftTimeout: "-1m"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid kafka notifier",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Brokers: []string{"kafka-1:9092", "kafka-2:9092"}, Topic: "powa.findings", Message: "alert",
+					SASL: KafkaSASLConfig{Mechanism: "scram-sha-512", Username: "sentinel"}, TLS: KafkaTLSConfig{Enabled: true}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "kafka without brokers",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Topic: "powa.findings"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kafka broker without port",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Brokers: []string{"kafka-1"}, Topic: "powa.findings"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kafka without topic",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Brokers: []string{"kafka-1:9092"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kafka invalid sasl mechanism",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Brokers: []string{"kafka-1:9092"}, Topic: "t", SASL: KafkaSASLConfig{Mechanism: "gssapi", Username: "u"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kafka ca file without tls",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "kafka", RetryDelay: "1s", Kafka: KafkaConfig{Brokers: []string{"kafka-1:9092"}, Topic: "t", TLS: KafkaTLSConfig{CAFile: "/etc/ssl/ca.pem"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid regression metric",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Regression: RegressionRuleConfig{Metric: "p99"}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "index suggestion scan error ratio above 1",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, IndexSuggestion: IndexSuggestionRuleConfig{MaxScanErrorRatio: 1.5}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid sns notifier",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "sns", RetryDelay: "1s", SNS: SNSConfig{TopicARN: "arn:aws:sns:eu-west-1:123456789012:powa-alerts"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "sns without topic arn",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "sns", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sns topic arn of another service",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "sns", RetryDelay: "1s", SNS: SNSConfig{TopicARN: "arn:aws:sqs:eu-west-1:123456789012:powa-alerts"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cross server rule",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, CrossServer: CrossServerRuleConfig{ReferenceSrvID: 1, ThresholdPercent: 30, MinCalls: 100}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cross server negative threshold",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, CrossServer: CrossServerRuleConfig{ThresholdPercent: -10}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pprof addr",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Server:   ServerConfig{Port: 8080, PprofAddr: "localhost:6060"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pprof addr on health port",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Server:   ServerConfig{Port: 8080, PprofAddr: ":8080"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pprof addr without port",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+				Server:   ServerConfig{Port: 8080, PprofAddr: "localhost"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid baseline scope",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, Regression: RegressionRuleConfig{BaselineScope: "all"}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "workload growth rule",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, WorkloadGrowth: WorkloadGrowthRuleConfig{ThresholdPercent: 30, TopContributors: 5}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "workload growth negative top contributors",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}, WorkloadGrowth: WorkloadGrowthRuleConfig{ThresholdPercent: 30, TopContributors: -1}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "allowlist",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", AllowlistQueryIDs: []int64{42}, AllowlistPatterns: []string{`^SELECT build_report\(`}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid allowlist pattern",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h", AllowlistPatterns: []string{"("}},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "iam auth without ssl",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, IAMAuth: true, SSLMode: "disable"},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid proxy url",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s", ProxyURL: "proxy.corp:3128"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid expected_extensions",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"pg_stat_kcache", "pg_qualstats"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid expected_extensions",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "pg_qualstats"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid expected_extensions deduplicated",
+			cfg: Config{
+				Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "powa", "other"}},
+				Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+				Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+				Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_SSLMode(t *testing.T) {
+	tests := []struct {
+		sslmode string
+		wantErr bool
+	}{
+		{"disable", false},
+		{"allow", false},
+		{"prefer", false},
+		{"require", false},
+		{"verify-ca", false},
+		{"verify-full", false},
+		{"requir", true},
+		{"Require", true},
+		{"true", true},
+	}
+	for _, tt := range tests {
+		cfg := Config{
+			Database: DatabaseConfig{Host: "localhost", Port: 5432, SSLMode: tt.sslmode},
+			Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+			Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+			Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+		}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("sslmode %q: Validate() error = %v, wantErr %v", tt.sslmode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDatabaseConfig_PlaintextRemote(t *testing.T) {
+	tests := []struct {
+		host    string
+		sslmode string
+		want    bool
+	}{
+		{"db.example.com", "disable", true},
+		{"10.0.0.5", "disable", true},
+		{"localhost", "disable", false},
+		{"127.0.0.1", "disable", false},
+		{"::1", "disable", false},
+		{"/var/run/postgresql", "disable", false},
+		{"db.example.com", "prefer", false},
+		{"db.example.com", "verify-full", false},
+	}
+	for _, tt := range tests {
+		d := DatabaseConfig{Host: tt.host, SSLMode: tt.sslmode}
+		if got := d.PlaintextRemote(); got != tt.want {
+			t.Errorf("PlaintextRemote(%s, %s) = %v, want %v", tt.host, tt.sslmode, got, tt.want)
+		}
+	}
+}
+
+func TestConfig_Validate_ExpectedExtensions_Deduplicated(t *testing.T) {
+	cfg := Config{
+		Database: DatabaseConfig{Host: "localhost", Port: 5432, ExpectedExtensions: []string{"powa", "powa", "other"}},
+		Analysis: AnalysisConfig{WindowDuration: "24h", ComparisonOffset: "168h"},
+		Rules:    RulesConfig{SlowSQL: SlowSQLRuleConfig{TopN: 10, RankBy: RankByList{"total_time"}}},
+		Notifier: NotifierConfig{Type: "console", RetryDelay: "1s"},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for invalid expected_extensions")
+	}
+	msg := err.Error()
+	// Each invalid extension should be reported only once (powa once, other once)
+	if strings.Count(msg, `"powa"`) != 1 {
+		t.Errorf("expected exactly one error line for powa, got %d mentions in: %s", strings.Count(msg, `"powa"`), msg)
+	}
+	if strings.Count(msg, `"other"`) != 1 {
+		t.Errorf("expected exactly one error line for other, got %d mentions in: %s", strings.Count(msg, `"other"`), msg)
+	}
+}
+
+func TestDatabaseConfig_DSN(t *testing.T) {
+	cfg := DatabaseConfig{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "testuser",
+		Password: "testpass",
+		DBName:   "testdb",
 		SSLMode:  "disable",
 	}
 
-	expected := "host=localhost port=5432 user=testuser password=testpass dbname=testdb sslmode=disable"
+	expected := "host=localhost port=5432 user=testuser password=testpass dbname=testdb sslmode=disable " +
+		"application_name='powa-sentinel' default_transaction_read_only=on"
 	if dsn := cfg.DSN(); dsn != expected {
 		t.Errorf("DSN() = %q, want %q", dsn, expected)
 	}
+
+	cfg.ApplicationName = "sentinel's audit"
+	if dsn := cfg.DSN(); !strings.Contains(dsn, `application_name='sentinel\'s audit'`) {
+		t.Errorf("DSN() = %q, want quoted application_name override", dsn)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
 }
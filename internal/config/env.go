@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables that set config keys directly.
+// The rest of the name is the key's YAML path, upper-cased and joined with
+// underscores: POWA_SENTINEL_DATABASE_HOST sets database.host and
+// POWA_SENTINEL_NOTIFIER_SYSLOG_ADDRESS sets notifier.syslog.address.
+const EnvPrefix = "POWA_SENTINEL_"
+
+// hasEnvConfig reports whether any POWA_SENTINEL_* variable is set, so Load
+// can run without a config file.
+func hasEnvConfig() bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, EnvPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvOverrides sets every scalar config key whose POWA_SENTINEL_* variable
+// is set, overriding the file. String lists (e.g. analysis.include_schemas)
+// take comma-separated values; maps, lists of objects and optional blocks such
+// as notifier.escalation can only be set in the file.
+func applyEnvOverrides(cfg *Config) error {
+	return bindEnv(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"))
+}
+
+// bindEnv walks struct v, whose fields take their variable names from name.
+func bindEnv(v reflect.Value, name string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		envName := name + "_" + strings.ToUpper(key)
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := bindEnv(field, envName); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(field, raw); err != nil {
+			return fmt.Errorf("%s: %w", envName, err)
+		}
+	}
+	return nil
+}
+
+// setEnvValue parses raw into field according to its kind.
+func setEnvValue(field reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("can only be set in the config file")
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+	case reflect.Pointer:
+		// Optional scalars such as analysis.skip_empty_queries; optional blocks stay file-only
+		if field.Type().Elem().Kind() == reflect.Struct {
+			return fmt.Errorf("can only be set in the config file")
+		}
+		v := reflect.New(field.Type().Elem())
+		if err := setEnvValue(v.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(v)
+	default:
+		return fmt.Errorf("can only be set in the config file")
+	}
+	return nil
+}
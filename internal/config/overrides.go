@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleOverrides are the rule thresholds database_overrides can replace for the
+// findings of one database. Unset fields keep the global rules value.
+type RuleOverrides struct {
+	Regression RegressionRuleOverride `yaml:"regression"`
+	CallSpike  CallSpikeRuleOverride  `yaml:"call_spike"`
+	Waits      WaitsRuleOverride      `yaml:"waits"`
+	Flapping   FlappingRuleOverride   `yaml:"flapping"`
+	Vacuum     VacuumRuleOverride     `yaml:"vacuum"`
+}
+
+// RegressionRuleOverride overrides rules.regression for one database.
+type RegressionRuleOverride struct {
+	ThresholdPercent *float64 `yaml:"threshold_percent"`
+	IgnoreNewQueries *bool    `yaml:"ignore_new_queries"`
+}
+
+// CallSpikeRuleOverride overrides rules.call_spike for one database.
+type CallSpikeRuleOverride struct {
+	ThresholdPercent *float64 `yaml:"threshold_percent"`
+	MinCalls         *int64   `yaml:"min_calls"`
+}
+
+// WaitsRuleOverride overrides rules.waits for one database.
+type WaitsRuleOverride struct {
+	MinPercent *float64 `yaml:"min_percent"`
+}
+
+// FlappingRuleOverride overrides rules.flapping for one database. The number
+// of windows is global: all databases share the same range queries.
+type FlappingRuleOverride struct {
+	MaxCV    *float64 `yaml:"max_cv"`
+	MinCalls *int64   `yaml:"min_calls"`
+}
+
+// VacuumRuleOverride overrides rules.vacuum for one database.
+type VacuumRuleOverride struct {
+	MaxDeadRatioPercent *float64 `yaml:"max_dead_ratio_percent"`
+	MaxAge              *string  `yaml:"max_age"`
+	MinDeadTuples       *int64   `yaml:"min_dead_tuples"`
+}
+
+// UnmarshalYAML rejects keys that are not overridable rule fields, so a typo
+// does not silently leave the global threshold in place.
+func (o *RuleOverrides) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkOverrideFields(value, reflect.TypeOf(*o), ""); err != nil {
+		return err
+	}
+	type plain RuleOverrides
+	return value.Decode((*plain)(o))
+}
+
+// checkOverrideFields walks mapping node against struct t's yaml tags.
+func checkOverrideFields(node *yaml.Node, t reflect.Type, path string) error {
+	if node.Kind != yaml.MappingNode {
+		return nil // left to Decode, which reports the type mismatch
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		fields[key] = t.Field(i).Type
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		ft, ok := fields[key]
+		if !ok {
+			known := make([]string, 0, len(fields))
+			for k := range fields {
+				known = append(known, path+k)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("line %d: database_overrides: unknown rule field %q (overridable: %s)",
+				node.Content[i].Line, path+key, strings.Join(known, ", "))
+		}
+		if ft.Kind() == reflect.Struct {
+			if err := checkOverrideFields(node.Content[i+1], ft, path+key+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply copies the set fields of o over r.
+func (o *RuleOverrides) apply(r *RulesConfig) {
+	setIf(&r.Regression.ThresholdPercent, o.Regression.ThresholdPercent)
+	setIf(&r.Regression.IgnoreNewQueries, o.Regression.IgnoreNewQueries)
+	setIf(&r.CallSpike.ThresholdPercent, o.CallSpike.ThresholdPercent)
+	setIf(&r.CallSpike.MinCalls, o.CallSpike.MinCalls)
+	setIf(&r.Waits.MinPercent, o.Waits.MinPercent)
+	setIf(&r.Flapping.MaxCV, o.Flapping.MaxCV)
+	setIf(&r.Flapping.MinCalls, o.Flapping.MinCalls)
+	setIf(&r.Vacuum.MaxDeadRatioPercent, o.Vacuum.MaxDeadRatioPercent)
+	setIf(&r.Vacuum.MaxAge, o.Vacuum.MaxAge)
+	setIf(&r.Vacuum.MinDeadTuples, o.Vacuum.MinDeadTuples)
+}
+
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// RulesFor returns the rules in effect for findings of database: the global
+// rules with its database_overrides entry, if any, applied.
+func (c *Config) RulesFor(database string) RulesConfig {
+	rules := c.Rules
+	if o, ok := c.DatabaseOverrides[database]; ok {
+		o.apply(&rules)
+	}
+	return rules
+}
+
+// AnyRules reports whether enabled holds for the global rules or for the
+// effective rules of any overridden database, e.g. to decide whether a rule
+// that is off globally must still fetch its data.
+func (c *Config) AnyRules(enabled func(r *RulesConfig) bool) bool {
+	if enabled(&c.Rules) {
+		return true
+	}
+	for db := range c.DatabaseOverrides {
+		rules := c.RulesFor(db)
+		if enabled(&rules) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDatabaseOverrides checks each override's values on their own, so an
+// invalid global threshold is reported once, under rules.
+func (c *Config) validateDatabaseOverrides() []string {
+	dbs := make([]string, 0, len(c.DatabaseOverrides))
+	for db := range c.DatabaseOverrides {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+
+	var errs []string
+	for _, db := range dbs {
+		if strings.TrimSpace(db) == "" {
+			errs = append(errs, "database_overrides: database names must not be empty")
+			continue
+		}
+		o := c.DatabaseOverrides[db]
+		rules := RulesConfig{Flapping: FlappingRuleConfig{Windows: c.Rules.Flapping.Windows}}
+		o.apply(&rules)
+		errs = append(errs, rules.validateThresholds("database_overrides."+db)...)
+	}
+	return errs
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// queryAllowlist drops queries that are slow by design, such as report
+// generation, according to analysis.allowlist_queryids and
+// analysis.allowlist_patterns. Unlike analysis.silence_file it is permanent and
+// removes the queries from the fetched metrics, so no rule ever sees them.
+type queryAllowlist struct {
+	queryIDs map[int64]bool
+	patterns []*regexp.Regexp
+}
+
+// newQueryAllowlist compiles the configured patterns. Invalid patterns are
+// rejected by config.Validate; any that slip through are logged and skipped.
+func newQueryAllowlist(cfg *config.AnalysisConfig) *queryAllowlist {
+	a := &queryAllowlist{}
+	if len(cfg.AllowlistQueryIDs) > 0 {
+		a.queryIDs = make(map[int64]bool, len(cfg.AllowlistQueryIDs))
+		for _, id := range cfg.AllowlistQueryIDs {
+			a.queryIDs[id] = true
+		}
+	}
+	for _, p := range cfg.AllowlistPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Printf("Warning: skipping invalid allowlist pattern %q: %v", p, err)
+			continue
+		}
+		a.patterns = append(a.patterns, re)
+	}
+	return a
+}
+
+// allows reports whether the query is allowlisted.
+func (a *queryAllowlist) allows(m *model.MetricSnapshot) bool {
+	if a.queryIDs[m.QueryID] {
+		return true
+	}
+	for _, re := range a.patterns {
+		if re.MatchString(m.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterMetrics returns metrics without the allowlisted snapshots, leaving the
+// reader's slice untouched. It runs before redaction, so patterns match the
+// query text as PoWA recorded it.
+func (a *queryAllowlist) filterMetrics(metrics []model.MetricSnapshot) []model.MetricSnapshot {
+	if len(a.queryIDs) == 0 && len(a.patterns) == 0 {
+		return metrics
+	}
+	kept := make([]model.MetricSnapshot, 0, len(metrics))
+	for i := range metrics {
+		if !a.allows(&metrics[i]) {
+			kept = append(kept, metrics[i])
+		}
+	}
+	return kept
+}
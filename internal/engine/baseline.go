@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// BaselineSnapshot is a frozen set of metrics written by SaveBaseline. With
+// rules.regression.baseline_file set, every run compares against it instead
+// of the rolling comparison_offset window.
+type BaselineSnapshot struct {
+	Window  model.TimeWindow       `json:"window"`
+	Metrics []model.MetricSnapshot `json:"metrics"`
+}
+
+// SaveBaseline captures the current window's metrics to path, e.g. right after
+// a known-good release, for later runs to compare against.
+func (e *Engine) SaveBaseline(ctx context.Context, path string) (*BaselineSnapshot, error) {
+	now := e.now().UTC()
+	windowDuration, err := e.windowFor(now)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := e.reader.GetCurrentMetrics(ctx, windowDuration)
+	if err != nil {
+		return nil, fmt.Errorf("fetching current metrics: %w", err)
+	}
+	// Redact before the query text is written to disk
+	e.redactor.redactMetrics(metrics)
+
+	snap := &BaselineSnapshot{
+		Window:  model.TimeWindow{Start: now.Add(-windowDuration), End: now},
+		Metrics: metrics,
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, fmt.Errorf("writing baseline file: %w", err)
+	}
+	return snap, nil
+}
+
+// loadBaseline reads a snapshot written by SaveBaseline.
+func loadBaseline(path string) (*BaselineSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline file: %w", err)
+	}
+	var snap BaselineSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parsing baseline file %s: %w", path, err)
+	}
+	return &snap, nil
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// detectCallSpikes reports queries whose call count grew by at least
+// ThresholdPercent over the baseline and reached MinCalls in the current
+// window, both resolved per database. Queries without baseline calls are
+// left to the regression rule, which reports them as new queries.
+func (e *Engine) detectCallSpikes(current, baseline []model.MetricSnapshot) []model.CallSpikeItem {
+	enabled := func(r *config.RulesConfig) bool { return r.CallSpike.ThresholdPercent > 0 }
+	if len(current) == 0 || !e.cfg.AnyRules(enabled) {
+		return nil
+	}
+
+	type comparisonKey struct {
+		queryID int64
+		server  string
+		db      string
+		userID  int64
+	}
+	baselineCalls := make(map[comparisonKey]int64, len(baseline))
+	for _, m := range baseline {
+		baselineCalls[comparisonKey{m.QueryID, m.ServerName, m.DatabaseName, m.UserID}] = m.Calls
+	}
+
+	var spikes []model.CallSpikeItem
+	for _, curr := range current {
+		cfg := e.cfg.RulesFor(curr.DatabaseName).CallSpike
+		if cfg.ThresholdPercent <= 0 || curr.Calls < cfg.MinCalls {
+			continue
+		}
+		base := baselineCalls[comparisonKey{curr.QueryID, curr.ServerName, curr.DatabaseName, curr.UserID}]
+		if base <= 0 {
+			continue
+		}
+		changePercent := float64(curr.Calls-base) / float64(base) * 100
+		if changePercent < cfg.ThresholdPercent {
+			continue
+		}
+		spikes = append(spikes, model.CallSpikeItem{
+			QueryID:       curr.QueryID,
+			Query:         curr.Query,
+			DatabaseName:  curr.DatabaseName,
+			ServerName:    curr.ServerName,
+			SrvID:         curr.SrvID,
+			UserID:        curr.UserID,
+			UserName:      curr.UserName,
+			BaselineCalls: base,
+			CurrentCalls:  curr.Calls,
+			ChangePercent: changePercent,
+		})
+	}
+
+	sort.Slice(spikes, func(i, j int) bool {
+		return spikes[i].ChangePercent > spikes[j].ChangePercent
+	})
+	return spikes
+}
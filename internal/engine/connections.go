@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// InstanceReader reads the live state of the monitored instance, as opposed to
+// the history PoWA keeps in its repository. The checks that need it are skipped
+// until SetInstance provides one.
+type InstanceReader interface {
+	GetConnectionUsage(ctx context.Context) (model.ConnectionUsage, error)
+}
+
+var _ InstanceReader = (*reader.Reader)(nil)
+
+// SetInstance connects the engine to the monitored instance (database.monitored).
+func (e *Engine) SetInstance(ir InstanceReader) {
+	e.instance = ir
+}
+
+// checkConnections returns a finding when the monitored instance's client
+// connections exceed rules.connections.max_percent of max_connections, or nil
+// when they do not, the rule is disabled or no monitored instance is set.
+// Lookup errors are logged and skipped like other optional checks.
+func (e *Engine) checkConnections(ctx context.Context) *model.ConnectionSaturationFinding {
+	maxPercent := e.cfg.Rules.Connections.MaxPercent
+	if maxPercent <= 0 || e.instance == nil {
+		return nil
+	}
+
+	usage, err := e.instance.GetConnectionUsage(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to check connection usage: %v", err)
+		return nil
+	}
+	return connectionSaturation(usage, maxPercent)
+}
+
+// connectionSaturation compares usage against maxPercent of max_connections.
+func connectionSaturation(usage model.ConnectionUsage, maxPercent float64) *model.ConnectionSaturationFinding {
+	if usage.MaxConnections <= 0 {
+		return nil
+	}
+	pct := float64(usage.Total) / float64(usage.MaxConnections) * 100
+	if pct <= maxPercent {
+		return nil
+	}
+	return &model.ConnectionSaturationFinding{
+		ConnectionUsage: usage,
+		UsagePercent:    pct,
+		MaxPercent:      maxPercent,
+	}
+}
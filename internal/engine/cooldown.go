@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// applyCooldowns leaves out the findings of each rule whose rules.<rule>.cooldown
+// has not elapsed since an alert carrying the rule's findings was last
+// delivered (see RecordDelivery), so a slow-moving rule such as
+// index_suggestion reports at most once per cooldown while the others report
+// every run. Held findings are dropped from the alert body and summary only:
+// Analyze tracks delta and escalation from the findings before cooldowns, so a
+// held finding neither resolves nor loses its escalation streak. The delivery
+// times are kept in memory, so a restart lets every rule report again.
+func (e *Engine) applyCooldowns(alertCtx *model.AlertContext, now time.Time) {
+	cooldowns := e.cfg.Rules.Cooldowns()
+	if len(cooldowns) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// hold reports whether the rule's n findings are held back
+	hold := func(rule string, n int) bool {
+		cooldown, ok := cooldowns[rule]
+		if !ok || n == 0 {
+			return false
+		}
+		if last, seen := e.ruleDelivered[rule]; seen && now.Sub(last) < cooldown {
+			log.Printf("Holding back %d %s finding(s) until the rule's %s cooldown ends at %s",
+				n, rule, cooldown, last.Add(cooldown).Format(time.RFC3339))
+			return true
+		}
+		return false
+	}
+
+	if hold("slow_sql", len(alertCtx.TopSlowSQL)) {
+		alertCtx.TopSlowSQL = nil
+	}
+	if hold("regression", len(alertCtx.Regressions)) {
+		alertCtx.Regressions = nil
+	}
+	if hold("index_suggestion", len(alertCtx.Suggestions)) {
+		alertCtx.Suggestions = nil
+	}
+	if hold("call_spike", len(alertCtx.CallSpikes)) {
+		alertCtx.CallSpikes = nil
+	}
+	if hold("waits", len(alertCtx.WaitEvents)) {
+		alertCtx.WaitEvents = nil
+	}
+	if hold("flapping", len(alertCtx.Flapping)) {
+		alertCtx.Flapping = nil
+	}
+	if hold("vacuum", len(alertCtx.Vacuum)) {
+		alertCtx.Vacuum = nil
+	}
+	if hold("cross_server", len(alertCtx.CrossServer)) {
+		alertCtx.CrossServer = nil
+	}
+	if alertCtx.WorkloadGrowth != nil && hold("workload_growth", 1) {
+		alertCtx.WorkloadGrowth = nil
+	}
+	if alertCtx.ConnectionSaturation != nil && hold("connections", 1) {
+		alertCtx.ConnectionSaturation = nil
+	}
+
+	if len(alertCtx.CustomFindings) > 0 {
+		counts := customRuleCounts(alertCtx)
+		held := make(map[string]bool, len(counts))
+		for rule, n := range counts {
+			held[rule] = hold("custom:"+rule, n)
+		}
+		// Filter into a new slice: Analyze still tracks the unfiltered one
+		var kept []model.CustomFinding
+		for _, f := range alertCtx.CustomFindings {
+			if !held[f.Rule] {
+				kept = append(kept, f)
+			}
+		}
+		alertCtx.CustomFindings = kept
+	}
+}
+
+// RecordDelivery starts the cooldown of every rule with findings in the
+// alert, from the alert's timestamp. Call it once the alert was sent: a rule
+// whose findings were never delivered, e.g. because the webhook failed, keeps
+// reporting. Notifiers that hold an alert back without an error (min_findings,
+// suppression, the digest) count as a delivery.
+func (e *Engine) RecordDelivery(alertCtx *model.AlertContext) {
+	cooldowns := e.cfg.Rules.Cooldowns()
+	if len(cooldowns) == 0 || alertCtx == nil {
+		return
+	}
+
+	counts := map[string]int{
+		"slow_sql":         len(alertCtx.TopSlowSQL),
+		"regression":       len(alertCtx.Regressions),
+		"index_suggestion": len(alertCtx.Suggestions),
+		"call_spike":       len(alertCtx.CallSpikes),
+		"waits":            len(alertCtx.WaitEvents),
+		"flapping":         len(alertCtx.Flapping),
+		"vacuum":           len(alertCtx.Vacuum),
+		"cross_server":     len(alertCtx.CrossServer),
+	}
+	if alertCtx.WorkloadGrowth != nil {
+		counts["workload_growth"] = 1
+	}
+	if alertCtx.ConnectionSaturation != nil {
+		counts["connections"] = 1
+	}
+	for rule, n := range customRuleCounts(alertCtx) {
+		counts["custom:"+rule] = n
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ruleDelivered == nil {
+		e.ruleDelivered = make(map[string]time.Time)
+	}
+	for rule, n := range counts {
+		if _, ok := cooldowns[rule]; ok && n > 0 {
+			e.ruleDelivered[rule] = alertCtx.Timestamp
+		}
+	}
+}
+
+// customRuleCounts counts the alert's custom findings by rule name.
+func customRuleCounts(alertCtx *model.AlertContext) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range alertCtx.CustomFindings {
+		counts[f.Rule]++
+	}
+	return counts
+}
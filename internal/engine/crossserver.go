@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// detectCrossServer compares every query in current with the same query on
+// rules.cross_server.reference_srvid and reports those whose mean time is at
+// least ThresholdPercent higher. Queries are matched by database and query
+// text: queryids depend on object OIDs and usually differ between a blue and a
+// green server. Both sides need MinCalls calls in the window.
+func (e *Engine) detectCrossServer(current []model.MetricSnapshot) []model.CrossServerItem {
+	cfg := e.cfg.Rules.CrossServer
+	if cfg.ThresholdPercent <= 0 || len(current) == 0 {
+		return nil
+	}
+
+	type queryKey struct {
+		srvID int
+		db    string
+		text  string
+	}
+	// A query can have one row per user; compare the totals of all of them
+	type totals struct {
+		snapshot  model.MetricSnapshot
+		totalTime float64
+		calls     int64
+	}
+	perQuery := make(map[queryKey]*totals)
+	var order []queryKey
+	for _, m := range current {
+		text := m.Query
+		if m.QueryHash != "" {
+			text = m.QueryHash
+		}
+		k := queryKey{m.SrvID, m.DatabaseName, text}
+		if t, ok := perQuery[k]; ok {
+			t.totalTime += m.TotalTime
+			t.calls += m.Calls
+			continue
+		}
+		perQuery[k] = &totals{snapshot: m, totalTime: m.TotalTime, calls: m.Calls}
+		order = append(order, k)
+	}
+
+	var items []model.CrossServerItem
+	for _, k := range order {
+		if k.srvID == cfg.ReferenceSrvID {
+			continue
+		}
+		t := perQuery[k]
+		ref, ok := perQuery[queryKey{cfg.ReferenceSrvID, k.db, k.text}]
+		if !ok || t.calls <= 0 || ref.calls <= 0 || t.calls < cfg.MinCalls || ref.calls < cfg.MinCalls {
+			continue
+		}
+		mean := t.totalTime / float64(t.calls)
+		refMean := ref.totalTime / float64(ref.calls)
+		if refMean <= 0 {
+			continue
+		}
+		changePercent := (mean - refMean) / refMean * 100
+		if changePercent < cfg.ThresholdPercent {
+			continue
+		}
+		items = append(items, model.CrossServerItem{
+			QueryID:             t.snapshot.QueryID,
+			Query:               t.snapshot.Query,
+			DatabaseName:        t.snapshot.DatabaseName,
+			ServerName:          t.snapshot.ServerName,
+			SrvID:               t.snapshot.SrvID,
+			ReferenceServerName: ref.snapshot.ServerName,
+			ReferenceSrvID:      ref.snapshot.SrvID,
+			ReferenceMeanTime:   refMean,
+			MeanTime:            mean,
+			ChangePercent:       changePercent,
+			ReferenceCalls:      ref.calls,
+			Calls:               t.calls,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ChangePercent > items[j].ChangePercent
+	})
+	return items
+}
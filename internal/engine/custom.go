@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// CustomQueryRunner is implemented by readers that can run config-defined SQL
+// rules. Custom rules are skipped for readers that do not implement it.
+type CustomQueryRunner interface {
+	QueryCustom(ctx context.Context, query string, timeout time.Duration) (*reader.CustomResult, error)
+}
+
+var _ CustomQueryRunner = (*reader.Reader)(nil)
+
+// evaluateCustomRules runs every configured custom rule and returns one finding
+// per row whose threshold column satisfies the rule's comparison. A failing
+// rule is logged and skipped so it cannot block the built-in rules.
+func (e *Engine) evaluateCustomRules(ctx context.Context) []model.CustomFinding {
+	rules := e.cfg.Rules.Custom
+	if len(rules) == 0 {
+		return nil
+	}
+	runner, ok := e.reader.(CustomQueryRunner)
+	if !ok {
+		log.Printf("Warning: reader does not support custom rules; skipping %d rule(s)", len(rules))
+		return nil
+	}
+
+	var findings []model.CustomFinding
+	for i := range rules {
+		rule := &rules[i]
+		timeout, err := rule.TimeoutParsed()
+		if err != nil {
+			log.Printf("Warning: custom rule %q: invalid timeout: %v", rule.Name, err)
+			continue
+		}
+		result, err := runner.QueryCustom(ctx, rule.Query, timeout)
+		if err != nil {
+			log.Printf("Warning: custom rule %q failed: %v", rule.Name, err)
+			continue
+		}
+		findings = append(findings, matchCustomRule(rule, result)...)
+	}
+	return findings
+}
+
+// matchCustomRule turns the rows of a custom rule result that satisfy the
+// threshold into findings. Rows with a NULL or non-numeric threshold value are skipped.
+func matchCustomRule(rule *config.CustomRuleConfig, result *reader.CustomResult) []model.CustomFinding {
+	col := -1
+	for i, c := range result.Columns {
+		if c == rule.ThresholdColumn {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		log.Printf("Warning: custom rule %q: column %q not in result (columns: %s)",
+			rule.Name, rule.ThresholdColumn, strings.Join(result.Columns, ", "))
+		return nil
+	}
+
+	severity := rule.Severity
+	if severity == "" {
+		severity = "medium"
+	}
+
+	var findings []model.CustomFinding
+	for _, row := range result.Rows {
+		if !row[col].Valid {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[col].String), 64)
+		if err != nil {
+			continue
+		}
+		if !compareThreshold(value, rule.ThresholdOperator, rule.ThresholdValue) {
+			continue
+		}
+
+		values := make(map[string]string, len(row))
+		for i, v := range row {
+			if v.Valid {
+				values[result.Columns[i]] = v.String
+			}
+		}
+		findings = append(findings, model.CustomFinding{
+			Rule:      rule.Name,
+			Severity:  severity,
+			Column:    rule.ThresholdColumn,
+			Value:     value,
+			Operator:  rule.ThresholdOperator,
+			Threshold: rule.ThresholdValue,
+			Row:       values,
+			Columns:   result.Columns,
+		})
+	}
+	return findings
+}
+
+// compareThreshold applies one of config.CustomRuleOperators.
+func compareThreshold(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "=":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
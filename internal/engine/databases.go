@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// summarizeDatabases aggregates the current snapshots per database and returns
+// the analysis.top_databases databases with the most total execution time. The
+// totals cover the statements the reader fetched, which is every statement
+// unless the database.max_query_rows limit was reached.
+func (e *Engine) summarizeDatabases(current []model.MetricSnapshot) []model.DatabaseSummary {
+	topN := e.cfg.Analysis.TopDatabases
+	if topN <= 0 || len(current) == 0 {
+		return nil
+	}
+
+	type databaseKey struct {
+		server string
+		db     string
+	}
+	byDatabase := make(map[databaseKey]*model.DatabaseSummary)
+	var totalTime float64
+	for _, m := range current {
+		key := databaseKey{server: m.ServerName, db: m.DatabaseName}
+		s, ok := byDatabase[key]
+		if !ok {
+			s = &model.DatabaseSummary{DatabaseName: m.DatabaseName, ServerName: m.ServerName}
+			byDatabase[key] = s
+		}
+		s.TotalTime += m.TotalTime
+		s.Calls += m.Calls
+		s.QueryCount++
+		totalTime += m.TotalTime
+	}
+
+	summaries := make([]model.DatabaseSummary, 0, len(byDatabase))
+	for _, s := range byDatabase {
+		if totalTime > 0 {
+			s.TimePercent = s.TotalTime / totalTime * 100
+		}
+		summaries = append(summaries, *s)
+	}
+
+	// Hottest first; calls and then names keep the order stable
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.TotalTime != b.TotalTime {
+			return a.TotalTime > b.TotalTime
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		if a.ServerName != b.ServerName {
+			return a.ServerName < b.ServerName
+		}
+		return a.DatabaseName < b.DatabaseName
+	})
+
+	if len(summaries) > topN {
+		summaries = summaries[:topN]
+	}
+	return summaries
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// consolidateSuggestions merges index suggestions that one index would serve and
+// attaches candidate DDL to each remaining suggestion. A suggestion whose
+// columns are a leading prefix of another on the same table is folded into the
+// wider one, keeping the higher improvement estimate and summing the affected
+// queries. The result is ordered by EstImprovementPercent descending.
+func consolidateSuggestions(suggestions []model.IndexSuggestion) []model.IndexSuggestion {
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	// Widest first, so narrower suggestions find the index that covers them
+	sorted := make([]model.IndexSuggestion, len(suggestions))
+	copy(sorted, suggestions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Columns) > len(sorted[j].Columns)
+	})
+
+	var merged []model.IndexSuggestion
+	for _, s := range sorted {
+		covered := false
+		for i := range merged {
+			m := &merged[i]
+			if m.Schema != s.Schema || m.Table != s.Table || !hasColumnPrefix(m.Columns, s.Columns) {
+				continue
+			}
+			if s.EstImprovementPercent > m.EstImprovementPercent {
+				m.EstImprovementPercent = s.EstImprovementPercent
+			}
+			m.AffectedQueries += s.AffectedQueries
+			m.Examples = mergeExamples(m.Examples, s.Examples)
+			covered = true
+			break
+		}
+		if !covered {
+			s.Columns = append([]string(nil), s.Columns...)
+			s.Examples = slices.Clone(s.Examples)
+			merged = append(merged, s)
+		}
+	}
+
+	for i := range merged {
+		if merged[i].SuggestedDDL == "" {
+			merged[i].SuggestedDDL = indexDDL(merged[i])
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].EstImprovementPercent > merged[j].EstImprovementPercent
+	})
+	return merged
+}
+
+// mergeExamples adds the examples of a folded suggestion for columns the wider
+// one has none for. Both share the folded columns, so order is kept.
+func mergeExamples(examples, more []model.ColumnExample) []model.ColumnExample {
+	for _, ex := range more {
+		if !slices.ContainsFunc(examples, func(e model.ColumnExample) bool { return e.Column == ex.Column }) {
+			examples = append(examples, ex)
+		}
+	}
+	return examples
+}
+
+// hasColumnPrefix reports whether prefix matches the leading columns of cols.
+func hasColumnPrefix(cols, prefix []string) bool {
+	if len(prefix) == 0 || len(prefix) > len(cols) {
+		return false
+	}
+	for i, c := range prefix {
+		if cols[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// indexDDL builds an advisory CREATE INDEX CONCURRENTLY statement for s. The
+// index name is left to PostgreSQL so the statement never collides with an
+// existing index name.
+func indexDDL(s model.IndexSuggestion) string {
+	if s.Table == "" || len(s.Columns) == 0 {
+		return ""
+	}
+	table := quoteIdentifier(s.Table)
+	if s.Schema != "" {
+		table = quoteIdentifier(s.Schema) + "." + table
+	}
+	cols := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		cols[i] = quoteIdentifier(c)
+	}
+	return "CREATE INDEX CONCURRENTLY ON " + table + " (" + strings.Join(cols, ", ") + ");"
+}
+
+// quoteIdentifier quotes a PostgreSQL identifier, doubling embedded quotes.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
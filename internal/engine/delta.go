@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// findingRefs lists every finding of the alert in a form comparable across runs.
+func findingRefs(alertCtx *model.AlertContext) []model.FindingRef {
+	var refs []model.FindingRef
+	if alertCtx.StaleData != nil {
+		refs = append(refs, staleDataRef(alertCtx.StaleData))
+	}
+	for _, q := range alertCtx.TopSlowSQL {
+		refs = append(refs, model.FindingRef{Rule: "slow_sql", QueryID: q.QueryID, DatabaseName: q.DatabaseName,
+			ServerName: q.ServerName, UserID: q.UserID, Subject: q.Query})
+	}
+	for _, r := range alertCtx.Regressions {
+		refs = append(refs, regressionRef(r))
+	}
+	for _, s := range alertCtx.CallSpikes {
+		refs = append(refs, model.FindingRef{Rule: "call_spike", QueryID: s.QueryID, DatabaseName: s.DatabaseName,
+			ServerName: s.ServerName, UserID: s.UserID, Subject: s.Query})
+	}
+	for _, w := range alertCtx.WaitEvents {
+		refs = append(refs, model.FindingRef{Rule: "wait_events", QueryID: w.QueryID, DatabaseName: w.DatabaseName,
+			ServerName: w.ServerName, Subject: w.Query})
+	}
+	for _, f := range alertCtx.Flapping {
+		refs = append(refs, model.FindingRef{Rule: "flapping", QueryID: f.QueryID, DatabaseName: f.DatabaseName,
+			ServerName: f.ServerName, Subject: f.Query})
+	}
+	for _, c := range alertCtx.CrossServer {
+		refs = append(refs, model.FindingRef{Rule: "cross_server", QueryID: c.QueryID, DatabaseName: c.DatabaseName,
+			ServerName: c.ServerName, Subject: c.Query})
+	}
+	if alertCtx.WorkloadGrowth != nil {
+		refs = append(refs, model.FindingRef{Rule: "workload_growth", Subject: "total query time grew"})
+	}
+	if alertCtx.ConnectionSaturation != nil {
+		refs = append(refs, model.FindingRef{Rule: "connection_saturation", Subject: "connections near max_connections"})
+	}
+	for _, v := range alertCtx.Vacuum {
+		refs = append(refs, model.FindingRef{Rule: "vacuum", DatabaseName: v.DatabaseName,
+			Subject: v.DatabaseName + "/" + v.FullTableName()})
+	}
+	for _, s := range alertCtx.Suggestions {
+		refs = append(refs, model.FindingRef{Rule: "index_suggestion",
+			Subject: fmt.Sprintf("%s (%s)", s.FullTableName(), strings.Join(s.Columns, ", "))})
+	}
+	for _, f := range alertCtx.CustomFindings {
+		refs = append(refs, customRef(f))
+	}
+	return refs
+}
+
+// staleDataRef identifies the stale data finding; there is at most one per run.
+func staleDataRef(sd *model.StaleDataFinding) model.FindingRef {
+	return model.FindingRef{Rule: "stale_data", Subject: "PoWA snapshots are stale", Severity: sd.Severity}
+}
+
+// regressionRef identifies a regression or new query finding.
+func regressionRef(r model.RegressionItem) model.FindingRef {
+	rule := "regression"
+	if r.IsNewQuery {
+		rule = "new_query"
+	}
+	return model.FindingRef{Rule: rule, QueryID: r.QueryID, DatabaseName: r.DatabaseName,
+		ServerName: r.ServerName, UserID: r.UserID, Subject: r.Query, Severity: r.Severity}
+}
+
+// customRef identifies a custom rule finding by its rule and row.
+func customRef(f model.CustomFinding) model.FindingRef {
+	return model.FindingRef{Rule: "custom:" + f.Rule, Subject: sortedRow(f.Row), Severity: f.Severity}
+}
+
+// sortedRow renders a custom rule row as "col=value" pairs in key order.
+func sortedRow(row map[string]string) string {
+	keys := make([]string, 0, len(row))
+	for k := range row {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + row[k]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// diffFindings compares current findings with the previous run's, keyed by
+// FindingRef.Key. It returns the delta and the finding set to compare the next
+// run against. With no previous run every finding is new.
+func diffFindings(previous map[string]model.FindingRef, current []model.FindingRef) (*model.FindingDelta, map[string]model.FindingRef) {
+	delta := &model.FindingDelta{}
+	next := make(map[string]model.FindingRef, len(current))
+	for _, ref := range current {
+		key := ref.Key()
+		if _, dup := next[key]; dup {
+			continue
+		}
+		next[key] = ref
+		if _, seen := previous[key]; seen {
+			delta.Still = append(delta.Still, ref)
+		} else {
+			delta.New = append(delta.New, ref)
+		}
+	}
+
+	var resolvedKeys []string
+	for key := range previous {
+		if _, ok := next[key]; !ok {
+			resolvedKeys = append(resolvedKeys, key)
+		}
+	}
+	sort.Strings(resolvedKeys)
+	for _, key := range resolvedKeys {
+		delta.Resolved = append(delta.Resolved, previous[key])
+	}
+	return delta, next
+}
+
+// trackDelta diffs the alert's findings against the previous run and remembers
+// them for the next one.
+func (e *Engine) trackDelta(alertCtx *model.AlertContext) *model.FindingDelta {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delta, next := diffFindings(e.prevFindings, findingRefs(alertCtx))
+	e.prevFindings = next
+	return delta
+}
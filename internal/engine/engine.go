@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
@@ -15,47 +17,170 @@ import (
 
 // Scoring limits to prevent single dimension from dominating
 const (
-	MaxRegressionDeduction  = 50 // Maximum points deducted for regressions
-	MaxSuggestionDeduction  = 30 // Maximum points deducted for index suggestions
+	MaxRegressionDeduction = 50 // Maximum points deducted for regressions
+	MaxSuggestionDeduction = 30 // Maximum points deducted for index suggestions
+	StaleDataDeduction     = 10 // Points deducted when PoWA data is stale
+)
+
+// MetricsReader is the data source the engine analyzes. *reader.Reader reads
+// from a live PoWA repository; *reader.FixtureReader replays a recorded fixture.
+type MetricsReader interface {
+	GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error)
+	GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error)
+	GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error)
+}
+
+// RangeReader is implemented by readers that can fetch metrics for an explicit
+// time range. AnalyzeRange requires it.
+type RangeReader interface {
+	GetMetricsRange(ctx context.Context, start, end time.Time) ([]model.MetricSnapshot, error)
+}
+
+// TargetedBaselineReader is implemented by readers that can fetch the baseline
+// of given queryids only. With rules.regression.baseline_scope current_queries
+// Analyze uses it, so every current query is compared to its own baseline
+// however far down the baseline window's row limit it ranks.
+type TargetedBaselineReader interface {
+	GetBaselineMetricsFor(ctx context.Context, offset, window time.Duration, queryIDs []int64) ([]model.MetricSnapshot, error)
+}
+
+// Compile-time checks that both reader implementations satisfy MetricsReader.
+var (
+	_ MetricsReader          = (*reader.Reader)(nil)
+	_ MetricsReader          = (*reader.FixtureReader)(nil)
+	_ RangeReader            = (*reader.Reader)(nil)
+	_ TargetedBaselineReader = (*reader.Reader)(nil)
 )
 
 // Engine performs analysis on PoWA data and generates alerts.
 type Engine struct {
-	cfg    *config.Config
-	reader *reader.Reader
+	cfg        *config.Config
+	reader     MetricsReader
+	instance   InstanceReader // monitored instance, nil when database.monitored is not set
+	repository string         // tags every alert; empty for a single repository
+	redactor   *queryRedactor
+	allowlist  *queryAllowlist
+	now        func() time.Time
+
+	mu           sync.Mutex
+	lastRunEnd   time.Time                   // end of the last successful window, used by since_last_run
+	prevFindings map[string]model.FindingRef // findings of the last run, used by delta notifications
+
+	criticalRuns map[string]int // consecutive runs each critical finding has been seen, used by escalation
+
+	completedRuns int // successful scheduled runs since start, used by the regression warmup
+
+	ruleDelivered map[string]time.Time // when an alert carrying each cooldown rule's findings was last delivered
+
+	recoverable map[string]model.FindingRef // findings of the last run at or above notifier.recovery_severity
 }
 
 // New creates a new Engine with the given configuration and reader.
-func New(cfg *config.Config, r *reader.Reader) *Engine {
-	return &Engine{
-		cfg:    cfg,
-		reader: r,
+func New(cfg *config.Config, r MetricsReader) *Engine {
+	e := &Engine{
+		cfg:       cfg,
+		reader:    r,
+		redactor:  newQueryRedactor(&cfg.Analysis),
+		allowlist: newQueryAllowlist(&cfg.Analysis),
+		now:       time.Now,
 	}
+	if esc := cfg.Notifier.Escalation; esc != nil && esc.StateFile != "" {
+		runs, err := loadEscalationState(esc.StateFile)
+		if err != nil {
+			log.Printf("Warning: ignoring escalation state: %v", err)
+		}
+		e.criticalRuns = runs
+	}
+	return e
+}
+
+// SetRepository names the PoWA repository the engine analyzes, tagging its
+// alerts when several repositories are configured.
+func (e *Engine) SetRepository(name string) {
+	e.repository = name
 }
 
 // Analyze runs the complete analysis and returns an AlertContext.
 func (e *Engine) Analyze(ctx context.Context) (*model.AlertContext, error) {
-	// Parse time windows
-	windowDuration, err := e.cfg.Analysis.WindowDurationParsed()
+	// Parse time windows (alert timestamps are kept in UTC; notifiers convert for display)
+	now := e.now().UTC()
+	rules := e.newRuleRunner(now)
+	windowDuration, err := e.windowFor(now)
 	if err != nil {
-		return nil, fmt.Errorf("parsing window duration: %w", err)
+		return nil, err
 	}
 
 	comparisonOffset, err := e.cfg.Analysis.ComparisonOffsetParsed()
 	if err != nil {
 		return nil, fmt.Errorf("parsing comparison offset: %w", err)
 	}
+	if limit, _ := e.cfg.Analysis.MaxComparisonOffsetParsed(); limit > 0 && comparisonOffset > limit {
+		log.Printf("Warning: comparison_offset %s exceeds analysis.max_comparison_offset; using %s", comparisonOffset, limit)
+		comparisonOffset = limit
+	}
 
-	// Fetch current metrics
-	currentMetrics, err := e.reader.GetCurrentMetrics(ctx, windowDuration)
-	if err != nil {
-		return nil, fmt.Errorf("fetching current metrics: %w", err)
+	// Build time windows
+	analysisWindow := model.TimeWindow{
+		Start: now.Add(-windowDuration),
+		End:   now,
+	}
+	baselineWindow := model.TimeWindow{
+		Start: now.Add(-comparisonOffset - windowDuration),
+		End:   now.Add(-comparisonOffset),
+	}
+
+	// Skip the expensive queries when PoWA recorded nothing in the window
+	if !e.hasData(ctx, windowDuration) {
+		log.Printf("No statements recorded in the last %s, skipping the statement rules", windowDuration)
+		return e.noDataAlert(ctx, rules, now, analysisWindow, baselineWindow), nil
+	}
+
+	// Fetch current metrics, keeping the rows of each role for the top users summary
+	var currentMetrics, baselineMetrics, userMetrics []model.MetricSnapshot
+	fetchCurrent := func() error {
+		m, err := e.reader.GetCurrentMetrics(ctx, windowDuration)
+		if err != nil {
+			return fmt.Errorf("fetching current metrics: %w", err)
+		}
+		// Drop allowlisted queries first, so the targeted baseline does not fetch them either
+		userMetrics = e.allowlist.filterMetrics(m)
+		currentMetrics = e.groupUsers(userMetrics)
+		return nil
+	}
+
+	// Fetch baseline metrics, from the pinned snapshot when one is configured
+	var pinned *BaselineSnapshot
+	path := e.cfg.Rules.Regression.BaselineFile
+	fetchBaseline := func() error {
+		var err error
+		if path != "" {
+			if pinned, err = loadBaseline(path); err != nil {
+				return err
+			}
+			baselineMetrics = pinned.Metrics
+		} else if baselineMetrics, err = e.fetchBaseline(ctx, comparisonOffset, windowDuration, currentMetrics); err != nil {
+			return fmt.Errorf("fetching baseline metrics: %w", err)
+		}
+		baselineMetrics = e.groupUsers(e.allowlist.filterMetrics(baselineMetrics))
+		return nil
 	}
 
-	// Fetch baseline metrics
-	baselineMetrics, err := e.reader.GetBaselineMetrics(ctx, comparisonOffset, windowDuration)
+	// A targeted baseline needs the current queries; any other can be fetched alongside them
+	if path == "" && e.targetsBaseline() {
+		err = fetchCurrent()
+		if err == nil {
+			err = fetchBaseline()
+		}
+	} else {
+		err = e.fetchAll(2, func(i int) error {
+			if i == 0 {
+				return fetchCurrent()
+			}
+			return fetchBaseline()
+		})
+	}
 	if err != nil {
-		return nil, fmt.Errorf("fetching baseline metrics: %w", err)
+		return nil, err
 	}
 
 	// Fetch index suggestions (non-fatal error)
@@ -66,63 +191,335 @@ func (e *Engine) Analyze(ctx context.Context) (*model.AlertContext, error) {
 		suggestions = nil
 	}
 
-	// Build time windows
-	now := time.Now()
-	analysisWindow := model.TimeWindow{
-		Start: now.Add(-windowDuration),
-		End:   now,
-	}
-	baselineWindow := model.TimeWindow{
-		Start: now.Add(-comparisonOffset - windowDuration),
-		End:   now.Add(-comparisonOffset),
+	// Redact query text before any rule, notifier or store sees it
+	e.redactor.redactMetrics(currentMetrics)
+	e.redactor.redactMetrics(baselineMetrics)
+	e.redactor.redactSuggestions(suggestions)
+
+	if pinned != nil {
+		baselineWindow = pinned.Window
 	}
 
 	// Create alert context
 	alertCtx := &model.AlertContext{
-		ReqID:          generateReqID(),
-		ReportType:     "scheduled",
-		Timestamp:      now,
-		AnalysisWindow: analysisWindow,
-		BaselineWindow: baselineWindow,
+		ReqID:           generateReqID(),
+		ReportType:      "scheduled",
+		Timestamp:       now,
+		AnalysisWindow:  analysisWindow,
+		BaselineWindow:  baselineWindow,
+		Repository:      e.repository,
+		DisplayLocation: e.cfg.Analysis.DisplayLocation,
+		RawMetrics:      e.rawMetrics(currentMetrics),
 	}
 
-	// Run analysis rules
-	alertCtx.TopSlowSQL = e.analyzeSlowSQL(currentMetrics)
-	alertCtx.Regressions = e.detectRegressions(currentMetrics, baselineMetrics)
-	alertCtx.Suggestions = e.filterSuggestions(suggestions)
+	// Run analysis rules, in order, until analysis.soft_timeout passes
+	rules.run("slow_sql", func() {
+		alertCtx.TopSlowSQL = e.analyzeSlowSQL(currentMetrics)
+		e.applyExplain(ctx, alertCtx.TopSlowSQL)
+		alertCtx.TopDatabases = e.summarizeDatabases(currentMetrics)
+		alertCtx.TopUsers = e.summarizeUsers(userMetrics)
+	})
+	rules.run("regression", func() {
+		alertCtx.Regressions = e.detectRegressions(currentMetrics, baselineMetrics)
+		e.applyQueryAge(ctx, alertCtx.Regressions, now)
+		e.applyRegressionWarmup(alertCtx.Regressions)
+	})
+	rules.run("index_suggestion", func() {
+		// Consolidate first so the affected-query floor counts every query a merged index serves
+		alertCtx.Suggestions = e.filterSuggestions(consolidateSuggestions(suggestions))
+	})
+	rules.run("call_spike", func() { alertCtx.CallSpikes = e.detectCallSpikes(currentMetrics, baselineMetrics) })
+	rules.run("workload_growth", func() {
+		alertCtx.WorkloadGrowth = e.detectWorkloadGrowth(currentMetrics, baselineMetrics, analysisWindow, baselineWindow)
+	})
+	rules.run("connections", func() { alertCtx.ConnectionSaturation = e.checkConnections(ctx) })
+	rules.run("waits", func() { alertCtx.WaitEvents = e.detectWaitEvents(ctx, currentMetrics, windowDuration) })
+	rules.run("flapping", func() { alertCtx.Flapping = e.detectFlapping(ctx, now, windowDuration) })
+	rules.run("cross_server", func() { alertCtx.CrossServer = e.detectCrossServer(currentMetrics) })
+	rules.run("vacuum", func() { alertCtx.Vacuum = e.detectVacuum(ctx, now) })
+	rules.run("custom", func() { alertCtx.CustomFindings = e.evaluateCustomRules(ctx) })
+	rules.run("stale_data", func() { alertCtx.StaleData = e.checkDataFreshness(ctx, now) })
+	alertCtx.Truncated = rules.truncated()
+	// Recovery compares every finding still firing, including those silenced or held back below
+	firing := findingRefs(alertCtx)
+	e.applySilences(alertCtx, now)
+	orderFindings(alertCtx)
+
+	// Attach routing labels
+	e.applyLabels(alertCtx)
+
+	// Cooldowns only hold findings back from the alert body: delta and
+	// escalation track them from this copy, so they stay firing
+	tracked := *alertCtx
+	e.applyCooldowns(alertCtx, now)
 
 	// Generate summary
 	alertCtx.Summary = e.generateSummary(alertCtx, len(currentMetrics))
+	alertCtx.Summary.BaselineOnlyQueries = baselineOnlyQueries(currentMetrics, baselineMetrics)
+	tracked.Summary = alertCtx.Summary
+
+	// Diff against the previous run before the cap hides any finding. A
+	// truncated run leaves all three untouched: its skipped rules would
+	// otherwise read as resolved and reset the escalation counts.
+	if alertCtx.Truncated != nil {
+		log.Print(alertCtx.Truncated.Note())
+	} else {
+		if e.cfg.Notifier.Mode == config.NotifierModeDelta {
+			alertCtx.Delta = e.trackDelta(&tracked)
+		}
+		if e.cfg.Notifier.Escalation != nil {
+			alertCtx.Escalation = e.trackEscalation(&tracked)
+		}
+		// A window without data says nothing about whether findings cleared
+		if e.cfg.Notifier.RecoverySeverity != "" && alertCtx.Summary.Empty != model.EmptyNoData {
+			alertCtx.Recovery = e.trackRecovery(alertCtx, firing)
+		}
+	}
+
+	// Order findings by weighted significance and cap the alert body
+	e.rankFindings(alertCtx)
 
+	e.finishRun(now)
 	return alertCtx, nil
 }
 
-// analyzeSlowSQL identifies the top N slow queries.
-func (e *Engine) analyzeSlowSQL(metrics []model.MetricSnapshot) []model.MetricSnapshot {
-	if len(metrics) == 0 {
+// finishRun records the end of an Analyze run at now, for the since_last_run
+// window and the regression warmup.
+func (e *Engine) finishRun(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastRunEnd = now
+	e.completedRuns++
+}
+
+// AnalyzeRange compares two explicit time ranges, e.g. 14:00-16:00 today against
+// 14:00-16:00 yesterday after a deploy. Unlike Analyze it ignores the rolling
+// window and offset and produces a regression-focused alert.
+func (e *Engine) AnalyzeRange(ctx context.Context, currentStart, currentEnd, baselineStart, baselineEnd time.Time) (*model.AlertContext, error) {
+	rr, ok := e.reader.(RangeReader)
+	if !ok {
+		return nil, fmt.Errorf("reader does not support explicit time ranges")
+	}
+	if !currentStart.Before(currentEnd) {
+		return nil, fmt.Errorf("current range start %s must be before end %s", currentStart.Format(time.RFC3339), currentEnd.Format(time.RFC3339))
+	}
+	if !baselineStart.Before(baselineEnd) {
+		return nil, fmt.Errorf("baseline range start %s must be before end %s", baselineStart.Format(time.RFC3339), baselineEnd.Format(time.RFC3339))
+	}
+	currentStart = e.capRange("current", currentStart, currentEnd)
+	baselineStart = e.capRange("baseline", baselineStart, baselineEnd)
+
+	var currentMetrics, baselineMetrics []model.MetricSnapshot
+	err := e.fetchAll(2, func(i int) error {
+		var err error
+		if i == 0 {
+			if currentMetrics, err = rr.GetMetricsRange(ctx, currentStart, currentEnd); err != nil {
+				return fmt.Errorf("fetching current metrics: %w", err)
+			}
+		} else if baselineMetrics, err = rr.GetMetricsRange(ctx, baselineStart, baselineEnd); err != nil {
+			return fmt.Errorf("fetching baseline metrics: %w", err)
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	currentMetrics = e.groupUsers(e.allowlist.filterMetrics(currentMetrics))
+	baselineMetrics = e.groupUsers(e.allowlist.filterMetrics(baselineMetrics))
+
+	e.redactor.redactMetrics(currentMetrics)
+	e.redactor.redactMetrics(baselineMetrics)
+
+	alertCtx := &model.AlertContext{
+		ReqID:           generateReqID(),
+		ReportType:      "range",
+		Timestamp:       e.now().UTC(),
+		AnalysisWindow:  model.TimeWindow{Start: currentStart.UTC(), End: currentEnd.UTC()},
+		BaselineWindow:  model.TimeWindow{Start: baselineStart.UTC(), End: baselineEnd.UTC()},
+		Repository:      e.repository,
+		DisplayLocation: e.cfg.Analysis.DisplayLocation,
+		RawMetrics:      e.rawMetrics(currentMetrics),
+	}
+
+	alertCtx.Regressions = e.detectRegressions(currentMetrics, baselineMetrics)
+	e.applyQueryAge(ctx, alertCtx.Regressions, currentEnd)
+	e.applySilences(alertCtx, alertCtx.Timestamp)
+	orderFindings(alertCtx)
+	e.applyLabels(alertCtx)
+	alertCtx.Summary = e.generateSummary(alertCtx, len(currentMetrics))
+	alertCtx.Summary.BaselineOnlyQueries = baselineOnlyQueries(currentMetrics, baselineMetrics)
+	e.rankFindings(alertCtx)
+
+	return alertCtx, nil
+}
+
+// fetchBaseline returns the baseline window's metrics: those of the current
+// queries only when the reader supports it and rules.regression.baseline_scope
+// is current_queries, else the baseline window's own top queries.
+func (e *Engine) fetchBaseline(ctx context.Context, offset, window time.Duration, current []model.MetricSnapshot) ([]model.MetricSnapshot, error) {
+	tr, ok := e.reader.(TargetedBaselineReader)
+	if !ok || !e.targetsBaseline() {
+		return e.reader.GetBaselineMetrics(ctx, offset, window)
+	}
+	seen := make(map[int64]bool, len(current))
+	ids := make([]int64, 0, len(current))
+	for _, m := range current {
+		if !seen[m.QueryID] {
+			seen[m.QueryID] = true
+			ids = append(ids, m.QueryID)
+		}
 	}
+	return tr.GetBaselineMetricsFor(ctx, offset, window, ids)
+}
 
-	// Make a copy to avoid modifying the original slice
-	sortedMetrics := make([]model.MetricSnapshot, len(metrics))
-	copy(sortedMetrics, metrics)
+// targetsBaseline reports whether fetchBaseline fetches the current queries only.
+func (e *Engine) targetsBaseline() bool {
+	_, ok := e.reader.(TargetedBaselineReader)
+	return ok && e.cfg.Rules.Regression.BaselineScope != config.BaselineScopeWindow
+}
 
-	// Sort based on configured ranking metric
-	rankBy := e.cfg.Rules.SlowSQL.RankBy
-	sortMetrics(sortedMetrics, rankBy)
+// baselineOnlyQueries counts the baseline queries missing from the current
+// window and logs them: they either stopped running or rank beyond the
+// current window's database.max_query_rows limit, and the rules cannot tell which.
+func baselineOnlyQueries(current, baseline []model.MetricSnapshot) int {
+	type queryKey struct {
+		queryID int64
+		server  string
+		db      string
+		userID  int64
+	}
+	inCurrent := make(map[queryKey]bool, len(current))
+	for _, m := range current {
+		inCurrent[queryKey{m.QueryID, m.ServerName, m.DatabaseName, m.UserID}] = true
+	}
+	missing := make(map[queryKey]bool)
+	for _, m := range baseline {
+		if k := (queryKey{m.QueryID, m.ServerName, m.DatabaseName, m.UserID}); !inCurrent[k] {
+			missing[k] = true
+		}
+	}
+	if len(missing) > 0 {
+		log.Printf("%d baseline queries are absent from the current window (possibly resolved, or beyond its database.max_query_rows limit); they are not compared",
+			len(missing))
+	}
+	return len(missing)
+}
+
+// windowFor returns the length of the current analysis window ending at now.
+// In since_last_run mode the window starts where the previous successful run
+// ended, capped to MaxWindow after downtime; the first run, and any run where
+// the clock moved backwards, use WindowDuration. Either way the window is
+// never longer than MaxWindowDuration.
+func (e *Engine) windowFor(now time.Time) (time.Duration, error) {
+	windowDuration, err := e.cfg.Analysis.WindowDurationParsed()
+	if err != nil {
+		return 0, fmt.Errorf("parsing window duration: %w", err)
+	}
+	windowDuration = e.capWindow("window_duration", windowDuration)
+	if e.cfg.Analysis.WindowMode != config.WindowModeSinceLastRun {
+		return windowDuration, nil
+	}
 
-	// Take top N
-	topN := e.cfg.Rules.SlowSQL.TopN
-	if topN > len(sortedMetrics) {
-		topN = len(sortedMetrics)
+	maxWindow, err := e.cfg.Analysis.MaxWindowParsed()
+	if err != nil {
+		return 0, fmt.Errorf("parsing max window: %w", err)
 	}
+	maxWindow = e.capWindow("max_window", maxWindow)
 
-	return sortedMetrics[:topN]
+	e.mu.Lock()
+	lastRunEnd := e.lastRunEnd
+	e.mu.Unlock()
+
+	if lastRunEnd.IsZero() {
+		return windowDuration, nil
+	}
+	elapsed := now.Sub(lastRunEnd)
+	if elapsed <= 0 {
+		log.Printf("Warning: clock is behind the previous run end (%s); using window_duration", lastRunEnd.Format(time.RFC3339))
+		return windowDuration, nil
+	}
+	if elapsed > maxWindow {
+		return maxWindow, nil
+	}
+	return elapsed, nil
+}
+
+// capWindow returns d, or analysis.max_window_duration with a warning when d
+// exceeds it. Validate rejects such configs; this guards the repository
+// against any that skip it.
+func (e *Engine) capWindow(name string, d time.Duration) time.Duration {
+	limit, _ := e.cfg.Analysis.MaxWindowDurationParsed()
+	if limit <= 0 || d <= limit {
+		return d
+	}
+	log.Printf("Warning: %s %s exceeds analysis.max_window_duration; using %s", name, d, limit)
+	return limit
+}
+
+// capRange returns the start of the explicit range start..end, moved forward
+// so the range is no longer than analysis.max_window_duration.
+func (e *Engine) capRange(name string, start, end time.Time) time.Time {
+	limit, _ := e.cfg.Analysis.MaxWindowDurationParsed()
+	if limit <= 0 || end.Sub(start) <= limit {
+		return start
+	}
+	capped := end.Add(-limit)
+	log.Printf("Warning: %s range of %s exceeds analysis.max_window_duration; starting it at %s",
+		name, end.Sub(start), capped.Format(time.RFC3339))
+	return capped
+}
+
+// analyzeSlowSQL identifies the top N slow queries by each rules.slow_sql.rank_by
+// metric in turn. With several metrics, each finding records the metric that
+// listed it, and a query an earlier metric listed still takes one of a later
+// metric's N places but is not repeated.
+func (e *Engine) analyzeSlowSQL(metrics []model.MetricSnapshot) []model.MetricSnapshot {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	rankings := e.cfg.Rules.SlowSQL.Rankings()
+	if len(rankings) == 0 {
+		rankings = []config.SlowSQLRanking{{Metric: "total_time", TopN: e.cfg.Rules.SlowSQL.TopN}}
+	}
+
+	type queryKey struct {
+		queryID int64
+		server  string
+		db      string
+		userID  int64
+	}
+	listed := make(map[queryKey]bool)
+	var top []model.MetricSnapshot
+	for _, ranking := range rankings {
+		// Make a copy to avoid modifying the original slice
+		sortedMetrics := slices.Clone(metrics)
+		sortMetrics(sortedMetrics, ranking.Metric)
+
+		var keys []queryKey
+		for _, m := range sortedMetrics[:min(ranking.TopN, len(sortedMetrics))] {
+			k := queryKey{m.QueryID, m.ServerName, m.DatabaseName, m.UserID}
+			if listed[k] {
+				continue
+			}
+			keys = append(keys, k)
+			if len(rankings) > 1 {
+				m.RankedBy = ranking.Metric
+			}
+			top = append(top, m)
+		}
+		for _, k := range keys {
+			listed[k] = true
+		}
+	}
+	return top
 }
 
 // detectRegressions identifies queries with significant performance degradation.
+// Queries without a usable baseline (absent, or zero mean time) are reported as
+// "new query" entries with info severity unless IgnoreNewQueries is set.
 func (e *Engine) detectRegressions(current, baseline []model.MetricSnapshot) []model.RegressionItem {
-	if len(current) == 0 || len(baseline) == 0 {
+	if len(current) == 0 {
 		return nil
 	}
 
@@ -132,6 +529,7 @@ func (e *Engine) detectRegressions(current, baseline []model.MetricSnapshot) []m
 		queryID int64
 		server  string
 		db      string
+		userID  int64 // 0 unless analysis.group_by_user keeps each role's rows
 	}
 
 	baselineMap := make(map[comparisonKey]model.MetricSnapshot)
@@ -140,34 +538,71 @@ func (e *Engine) detectRegressions(current, baseline []model.MetricSnapshot) []m
 			queryID: m.QueryID,
 			server:  m.ServerName,
 			db:      m.DatabaseName,
+			userID:  m.UserID,
 		}
 		baselineMap[key] = m
 	}
 
-	threshold := e.cfg.Rules.Regression.ThresholdPercent
 	var regressions []model.RegressionItem
+	var meanFallbacks int
 
 	for _, curr := range current {
+		rule := e.cfg.RulesFor(curr.DatabaseName).Regression
 		key := comparisonKey{
 			queryID: curr.QueryID,
 			server:  curr.ServerName,
 			db:      curr.DatabaseName,
+			userID:  curr.UserID,
 		}
 		base, exists := baselineMap[key]
 		if !exists || base.MeanTime == 0 {
+			// No baseline to compare against: skip the percent calculation entirely
+			if !rule.IgnoreNewQueries {
+				regressions = append(regressions, model.RegressionItem{
+					QueryID:         curr.QueryID,
+					Query:           curr.Query,
+					DatabaseName:    curr.DatabaseName,
+					ServerName:      curr.ServerName,
+					SrvID:           curr.SrvID,
+					UserID:          curr.UserID,
+					UserName:        curr.UserName,
+					CurrentMeanTime: curr.MeanTime,
+					CurrentCalls:    curr.Calls,
+					BaselineCalls:   base.Calls,
+					Severity:        "info",
+					IsNewQuery:      true,
+				})
+			}
 			continue
 		}
 
-		changePercent := ((curr.MeanTime - base.MeanTime) / base.MeanTime) * 100
+		// The tail metric compares mean + k stddev where both windows have a
+		// stddev, and falls back to the mean where either does not
+		currTime, baseTime := curr.MeanTime, base.MeanTime
+		var currTail, baseTail float64
+		if rule.Metric == config.RegressionMetricTail {
+			if curr.HasStddev && base.HasStddev {
+				currTail, baseTail = curr.TailTime(rule.TailFactor), base.TailTime(rule.TailFactor)
+				currTime, baseTime = currTail, baseTail
+			} else {
+				meanFallbacks++
+			}
+		}
+		changePercent := ((currTime - baseTime) / baseTime) * 100
 
-		if changePercent >= threshold {
+		if changePercent >= rule.ThresholdPercent {
 			regressions = append(regressions, model.RegressionItem{
 				QueryID:          curr.QueryID,
 				Query:            curr.Query,
 				DatabaseName:     curr.DatabaseName,
 				ServerName:       curr.ServerName,
+				SrvID:            curr.SrvID,
+				UserID:           curr.UserID,
+				UserName:         curr.UserName,
 				CurrentMeanTime:  curr.MeanTime,
 				BaselineMeanTime: base.MeanTime,
+				CurrentTailTime:  currTail,
+				BaselineTailTime: baseTail,
 				ChangePercent:    changePercent,
 				CurrentCalls:     curr.Calls,
 				BaselineCalls:    base.Calls,
@@ -175,26 +610,57 @@ func (e *Engine) detectRegressions(current, baseline []model.MetricSnapshot) []m
 			})
 		}
 	}
+	if meanFallbacks > 0 {
+		log.Printf("rules.regression.metric tail: %d comparison(s) used the mean time, without a stddev in both windows", meanFallbacks)
+	}
 
-	// Sort by change percent descending
+	// Sort by change percent descending; new queries follow, slowest first
 	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].IsNewQuery != regressions[j].IsNewQuery {
+			return !regressions[i].IsNewQuery
+		}
+		if regressions[i].IsNewQuery {
+			return regressions[i].CurrentMeanTime > regressions[j].CurrentMeanTime
+		}
 		return regressions[i].ChangePercent > regressions[j].ChangePercent
 	})
 
 	return regressions
 }
 
-// filterSuggestions filters index suggestions by minimum improvement threshold.
+// applyRegressionWarmup reports regressions as info during the first
+// rules.regression.warmup_runs runs, so thin baselines of a newly monitored
+// environment cannot raise warnings or escalate. The run count is kept in
+// memory; a restart starts a new warmup.
+func (e *Engine) applyRegressionWarmup(regressions []model.RegressionItem) {
+	e.mu.Lock()
+	run := e.completedRuns + 1
+	e.mu.Unlock()
+
+	warmup := e.cfg.Rules.Regression.WarmupRuns
+	if run > warmup || len(regressions) == 0 {
+		return
+	}
+	log.Printf("Regression rule warming up (run %d of %d): reporting %d regression(s) as info", run, warmup, len(regressions))
+	for i := range regressions {
+		regressions[i].Severity = "info"
+	}
+}
+
+// filterSuggestions filters index suggestions by minimum improvement threshold,
+// affected query count and analysis.include_schemas/exclude_schemas.
 func (e *Engine) filterSuggestions(suggestions []model.IndexSuggestion) []model.IndexSuggestion {
 	if len(suggestions) == 0 {
 		return nil
 	}
 
 	minImprovement := e.cfg.Rules.IndexSuggestion.MinImprovementPercent
+	minAffected := e.cfg.Rules.IndexSuggestion.MinAffectedQueries
 	var filtered []model.IndexSuggestion
 
 	for _, s := range suggestions {
-		if s.EstImprovementPercent >= minImprovement {
+		if s.EstImprovementPercent >= minImprovement && s.AffectedQueries >= minAffected &&
+			e.cfg.Analysis.SchemaAllowed(s.Schema) {
 			filtered = append(filtered, s)
 		}
 	}
@@ -212,8 +678,29 @@ func (e *Engine) generateSummary(alertCtx *model.AlertContext, totalQueries int)
 	summary := model.AlertSummary{
 		TotalQueriesAnalyzed: totalQueries,
 		SlowQueryCount:       len(alertCtx.TopSlowSQL),
-		RegressionCount:      len(alertCtx.Regressions),
 		SuggestionCount:      len(alertCtx.Suggestions),
+		CallSpikeCount:       len(alertCtx.CallSpikes),
+		WaitEventCount:       len(alertCtx.WaitEvents),
+		FlappingCount:        len(alertCtx.Flapping),
+		CrossServerCount:     len(alertCtx.CrossServer),
+		VacuumCount:          len(alertCtx.Vacuum),
+		CustomFindingCount:   len(alertCtx.CustomFindings),
+	}
+	for _, r := range alertCtx.Regressions {
+		if r.IsNewQuery {
+			summary.NewQueryCount++
+		} else {
+			summary.RegressionCount++
+		}
+	}
+	if !HasFindings(alertCtx, "") {
+		// A truncated run cannot vouch that all is quiet
+		if alertCtx.Truncated == nil {
+			summary.Empty = model.EmptyNoFindings
+		}
+		if totalQueries == 0 {
+			summary.Empty = model.EmptyNoData
+		}
 	}
 
 	// Calculate health score (0-100)
@@ -230,6 +717,8 @@ func (e *Engine) generateSummary(alertCtx *model.AlertContext, totalQueries int)
 			regressionDeduction += 10
 		case "medium":
 			regressionDeduction += 5
+		case "info":
+			// New queries carry no penalty
 		default:
 			regressionDeduction += 2
 		}
@@ -246,6 +735,11 @@ func (e *Engine) generateSummary(alertCtx *model.AlertContext, totalQueries int)
 	}
 	score -= suggestionDeduction
 
+	// A stopped collector invalidates the other findings, so flag it in the score
+	if alertCtx.StaleData != nil {
+		score -= StaleDataDeduction
+	}
+
 	// Ensure score is within bounds
 	if score < 0 {
 		score = 0
@@ -257,20 +751,62 @@ func (e *Engine) generateSummary(alertCtx *model.AlertContext, totalQueries int)
 	return summary
 }
 
-// sortMetrics sorts metrics by the specified field.
+// applyLabels attaches global labels to the alert and merged per-database labels
+// to each finding. Per-database values take precedence over global ones.
+func (e *Engine) applyLabels(alertCtx *model.AlertContext) {
+	if len(e.cfg.Labels) == 0 && len(e.cfg.DatabaseLabels) == 0 {
+		return
+	}
+	if len(e.cfg.Labels) > 0 {
+		alertCtx.Labels = mergeLabels(e.cfg.Labels, nil)
+	}
+	for i := range alertCtx.TopSlowSQL {
+		alertCtx.TopSlowSQL[i].Labels = e.labelsFor(alertCtx.TopSlowSQL[i].DatabaseName)
+	}
+	for i := range alertCtx.Regressions {
+		alertCtx.Regressions[i].Labels = e.labelsFor(alertCtx.Regressions[i].DatabaseName)
+	}
+	for i := range alertCtx.CallSpikes {
+		alertCtx.CallSpikes[i].Labels = e.labelsFor(alertCtx.CallSpikes[i].DatabaseName)
+	}
+	for i := range alertCtx.WaitEvents {
+		alertCtx.WaitEvents[i].Labels = e.labelsFor(alertCtx.WaitEvents[i].DatabaseName)
+	}
+	for i := range alertCtx.Flapping {
+		alertCtx.Flapping[i].Labels = e.labelsFor(alertCtx.Flapping[i].DatabaseName)
+	}
+	for i := range alertCtx.CrossServer {
+		alertCtx.CrossServer[i].Labels = e.labelsFor(alertCtx.CrossServer[i].DatabaseName)
+	}
+	for i := range alertCtx.Vacuum {
+		alertCtx.Vacuum[i].Labels = e.labelsFor(alertCtx.Vacuum[i].DatabaseName)
+	}
+}
+
+// labelsFor returns the labels for findings in the given database.
+func (e *Engine) labelsFor(database string) map[string]string {
+	return mergeLabels(e.cfg.Labels, e.cfg.DatabaseLabels[database])
+}
+
+// mergeLabels returns a new map with base overlaid by overrides, or nil if both are empty.
+func mergeLabels(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// sortMetrics sorts metrics by the specified field, breaking ties so the top N
+// cut is the same on every run.
 func sortMetrics(metrics []model.MetricSnapshot, rankBy string) {
-	sort.Slice(metrics, func(i, j int) bool {
-		switch rankBy {
-		case "mean_time":
-			return metrics[i].MeanTime > metrics[j].MeanTime
-		case "cpu_time":
-			return metrics[i].TotalCPUTime() > metrics[j].TotalCPUTime()
-		case "io_time":
-			return metrics[i].IOTime() > metrics[j].IOTime()
-		default: // total_time
-			return metrics[i].TotalTime > metrics[j].TotalTime
-		}
-	})
+	sortFindings(metrics, slowSQLKey(rankBy))
 }
 
 // calculateSeverity determines regression severity based on change percent.
@@ -1,10 +1,24 @@
 package engine
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
 	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
 )
 
 func TestAnalyzeSlowSQL(t *testing.T) {
@@ -12,7 +26,7 @@ func TestAnalyzeSlowSQL(t *testing.T) {
 		Rules: config.RulesConfig{
 			SlowSQL: config.SlowSQLRuleConfig{
 				TopN:   3,
-				RankBy: "total_time",
+				RankBy: config.RankByList{"total_time"},
 			},
 		},
 	}
@@ -49,7 +63,7 @@ func TestAnalyzeSlowSQL_RankByMeanTime(t *testing.T) {
 		Rules: config.RulesConfig{
 			SlowSQL: config.SlowSQLRuleConfig{
 				TopN:   2,
-				RankBy: "mean_time",
+				RankBy: config.RankByList{"mean_time"},
 			},
 		},
 	}
@@ -76,6 +90,55 @@ func TestAnalyzeSlowSQL_RankByMeanTime(t *testing.T) {
 	}
 }
 
+func TestAnalyzeSlowSQL_MultipleRankings(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			SlowSQL: config.SlowSQLRuleConfig{TopN: 2, RankBy: config.RankByList{"total_time", "mean_time:3"}},
+		},
+	}
+	eng := New(cfg, nil)
+
+	metrics := []model.MetricSnapshot{
+		{QueryID: 1, TotalTime: 1000, MeanTime: 10},
+		{QueryID: 2, TotalTime: 800, MeanTime: 400},
+		{QueryID: 3, TotalTime: 500, MeanTime: 50},
+		{QueryID: 4, TotalTime: 100, MeanTime: 100},
+		{QueryID: 2, ServerName: "replica", TotalTime: 50, MeanTime: 500},
+	}
+
+	result := eng.analyzeSlowSQL(metrics)
+
+	// Query 2 is in both top lists and keeps its place among the top by total
+	// time; the same queryid on another server is a different query
+	want := []struct {
+		queryID  int64
+		server   string
+		rankedBy string
+	}{
+		{1, "", "total_time"},
+		{2, "", "total_time"},
+		{2, "replica", "mean_time"},
+		{4, "", "mean_time"},
+	}
+	if len(result) != len(want) {
+		t.Fatalf("analyzeSlowSQL() returned %d items, want %d: %+v", len(result), len(want), result)
+	}
+	for i, w := range want {
+		if r := result[i]; r.QueryID != w.queryID || r.ServerName != w.server || r.RankedBy != w.rankedBy {
+			t.Errorf("result[%d] = query %d on %q ranked by %q, want query %d on %q ranked by %q",
+				i, r.QueryID, r.ServerName, r.RankedBy, w.queryID, w.server, w.rankedBy)
+		}
+	}
+
+	// A single ranking leaves the findings untagged
+	cfg.Rules.SlowSQL.RankBy = config.RankByList{"mean_time"}
+	for _, r := range eng.analyzeSlowSQL(metrics) {
+		if r.RankedBy != "" {
+			t.Errorf("query %d ranked by %q, want no ranking with a single metric", r.QueryID, r.RankedBy)
+		}
+	}
+}
+
 func TestDetectRegressions(t *testing.T) {
 	cfg := &config.Config{
 		Rules: config.RulesConfig{
@@ -115,6 +178,234 @@ func TestDetectRegressions(t *testing.T) {
 	}
 }
 
+func TestDetectCallSpikes(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			CallSpike: config.CallSpikeRuleConfig{ThresholdPercent: 500, MinCalls: 1000},
+		},
+	}
+	eng := New(cfg, nil)
+
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Calls: 12000},  // 1100% increase
+		{QueryID: 2, DatabaseName: "app", Calls: 3000},   // 500% increase
+		{QueryID: 3, DatabaseName: "app", Calls: 600},    // 1100% increase, below min_calls
+		{QueryID: 4, DatabaseName: "app", Calls: 2000},   // 100% increase
+		{QueryID: 5, DatabaseName: "app", Calls: 5000},   // no baseline
+		{QueryID: 1, DatabaseName: "other", Calls: 1500}, // same query in another database, no spike
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Calls: 1000},
+		{QueryID: 2, DatabaseName: "app", Calls: 500},
+		{QueryID: 3, DatabaseName: "app", Calls: 50},
+		{QueryID: 4, DatabaseName: "app", Calls: 1000},
+		{QueryID: 1, DatabaseName: "other", Calls: 1400},
+	}
+
+	result := eng.detectCallSpikes(current, baseline)
+
+	if len(result) != 2 {
+		t.Fatalf("detectCallSpikes() returned %d items, want 2: %+v", len(result), result)
+	}
+	if result[0].QueryID != 1 || result[0].DatabaseName != "app" {
+		t.Errorf("First spike should be QueryID 1 in app, got %d in %s", result[0].QueryID, result[0].DatabaseName)
+	}
+	if result[0].BaselineCalls != 1000 || result[0].CurrentCalls != 12000 || result[0].ChangePercent != 1100 {
+		t.Errorf("First spike = %d → %d (%v%%), want 1000 → 12000 (1100%%)",
+			result[0].BaselineCalls, result[0].CurrentCalls, result[0].ChangePercent)
+	}
+	if result[1].QueryID != 2 {
+		t.Errorf("Second spike should be QueryID 2, got %d", result[1].QueryID)
+	}
+}
+
+func TestDetectCallSpikes_DisabledByDefault(t *testing.T) {
+	eng := New(&config.Config{}, nil)
+
+	current := []model.MetricSnapshot{{QueryID: 1, Calls: 100000}}
+	baseline := []model.MetricSnapshot{{QueryID: 1, Calls: 10}}
+
+	if result := eng.detectCallSpikes(current, baseline); len(result) != 0 {
+		t.Errorf("detectCallSpikes() with no threshold returned %d items, want 0", len(result))
+	}
+}
+
+func TestDetectCallSpikes_GroupByUser(t *testing.T) {
+	// With analysis.group_by_user the same query spikes for two roles
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{GroupByUser: true},
+		Rules:    config.RulesConfig{CallSpike: config.CallSpikeRuleConfig{ThresholdPercent: 100}},
+	}
+	eng := New(cfg, nil)
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Calls: 400, UserID: 10, UserName: "web"},
+		{QueryID: 1, DatabaseName: "app", Calls: 300, UserID: 11, UserName: "batch"},
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Calls: 100, UserID: 10, UserName: "web"},
+		{QueryID: 1, DatabaseName: "app", Calls: 100, UserID: 11, UserName: "batch"},
+	}
+
+	got := eng.detectCallSpikes(eng.groupUsers(current), eng.groupUsers(baseline))
+	if len(got) != 2 {
+		t.Fatalf("detectCallSpikes() = %+v, want one spike per role", got)
+	}
+	if got[0].UserID != 10 || got[0].UserName != "web" || got[1].UserID != 11 || got[1].UserName != "batch" {
+		t.Errorf("spikes = %+v, want web then batch", got)
+	}
+
+	refs := findingRefs(&model.AlertContext{CallSpikes: got})
+	if len(refs) != 2 || refs[0].Key() == refs[1].Key() {
+		t.Errorf("finding refs = %+v, want a distinct key per role", refs)
+	}
+}
+
+func TestDetectCrossServer(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			CrossServer: config.CrossServerRuleConfig{ReferenceSrvID: 1, ThresholdPercent: 50, MinCalls: 10},
+		},
+	}
+	eng := New(cfg, nil)
+
+	snap := func(srvID int, queryID int64, query string, totalTime float64, calls int64) model.MetricSnapshot {
+		return model.MetricSnapshot{QueryID: queryID, Query: query, DatabaseName: "app", ServerName: fmt.Sprintf("srv%d", srvID),
+			SrvID: srvID, TotalTime: totalTime, MeanTime: totalTime / float64(calls), Calls: calls}
+	}
+	current := []model.MetricSnapshot{
+		// Reference (blue) server
+		snap(1, 101, "SELECT orders", 1000, 100), // 10ms
+		snap(1, 102, "SELECT users", 2000, 100),  // 20ms
+		snap(1, 103, "SELECT items", 500, 100),   // 5ms
+		snap(1, 104, "SELECT rare", 50, 5),       // below min_calls
+		// Green server: queryids differ, the text matches
+		snap(2, 201, "SELECT orders", 1500, 50), // 30ms over two users: +200%
+		snap(2, 201, "SELECT orders", 1500, 50),
+		snap(2, 202, "SELECT users", 2400, 100), // 24ms: +20%, below the threshold
+		snap(2, 203, "SELECT items", 100, 10),   // 10ms: +100%
+		snap(2, 204, "SELECT rare", 5000, 50),   // reference below min_calls
+		snap(2, 205, "SELECT new", 9000, 100),   // not on the reference server
+	}
+
+	result := eng.detectCrossServer(current)
+	if len(result) != 2 {
+		t.Fatalf("detectCrossServer() returned %d items, want 2: %+v", len(result), result)
+	}
+	if got := result[0]; got.QueryID != 201 || got.SrvID != 2 || got.ReferenceSrvID != 1 || got.ReferenceServerName != "srv1" ||
+		got.MeanTime != 30 || got.ReferenceMeanTime != 10 || got.ChangePercent != 200 || got.Calls != 100 || got.ReferenceCalls != 100 {
+		t.Errorf("result[0] = %+v, want query 201 on srv2 at 30ms against 10ms on srv1 (+200%%)", got)
+	}
+	if got := result[1]; got.QueryID != 203 || got.ChangePercent != 100 {
+		t.Errorf("result[1] = %+v, want query 203 at +100%%", got)
+	}
+
+	// Disabled without a threshold
+	if got := New(&config.Config{}, nil).detectCrossServer(current); got != nil {
+		t.Errorf("detectCrossServer() without a threshold = %+v, want nil", got)
+	}
+}
+
+func TestDetectWorkloadGrowth(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			WorkloadGrowth: config.WorkloadGrowthRuleConfig{ThresholdPercent: 30, TopContributors: 2},
+		},
+	}
+	eng := New(cfg, nil)
+
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	hour := model.TimeWindow{Start: now.Add(-time.Hour), End: now}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", TotalTime: 400, Calls: 40},
+		{QueryID: 2, DatabaseName: "app", TotalTime: 400, Calls: 40},
+		{QueryID: 3, DatabaseName: "app", TotalTime: 200, Calls: 20}, // no longer runs
+	}
+	// Every query grew by less than a regression would need; together they add 50%
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", TotalTime: 300, Calls: 30},
+		{QueryID: 1, DatabaseName: "app", TotalTime: 200, Calls: 20}, // second user
+		{QueryID: 2, DatabaseName: "app", TotalTime: 600, Calls: 60},
+		{QueryID: 4, DatabaseName: "app", TotalTime: 400, Calls: 40}, // new
+	}
+
+	got := eng.detectWorkloadGrowth(current, baseline, hour, hour)
+	if got == nil {
+		t.Fatal("detectWorkloadGrowth() = nil, want a finding")
+	}
+	if got.BaselineTotalTime != 1000 || got.CurrentTotalTime != 1500 || got.ChangePercent != 50 ||
+		got.BaselineCalls != 100 || got.CurrentCalls != 150 {
+		t.Errorf("detectWorkloadGrowth() = %+v, want 1000ms -> 1500ms (+50%%) over 100 -> 150 calls", got)
+	}
+	var contributors []string
+	for _, c := range got.TopContributors {
+		contributors = append(contributors, fmt.Sprintf("%d:%v(%v->%v)", c.QueryID, c.AddedTime, c.BaselineTotalTime, c.CurrentTotalTime))
+	}
+	// Query 4 is new and added the most; query 1 added the least and falls past top_contributors
+	if want := []string{"4:400(0->400)", "2:200(400->600)"}; !slices.Equal(contributors, want) {
+		t.Errorf("TopContributors = %v, want %v", contributors, want)
+	}
+
+	// Rates per second: the same totals over a two-hour baseline are a 200% growth
+	twoHours := model.TimeWindow{Start: now.Add(-2 * time.Hour), End: now}
+	if got := eng.detectWorkloadGrowth(current, baseline, hour, twoHours); got == nil || got.ChangePercent != 200 {
+		t.Errorf("detectWorkloadGrowth() against a 2h baseline = %+v, want +200%%", got)
+	}
+
+	// Below the threshold
+	if got := eng.detectWorkloadGrowth(current[:3], baseline, hour, hour); got != nil {
+		t.Errorf("detectWorkloadGrowth() at +10%% = %+v, want nil", got)
+	}
+
+	// Disabled without a threshold
+	if got := New(&config.Config{}, nil).detectWorkloadGrowth(current, baseline, hour, hour); got != nil {
+		t.Errorf("detectWorkloadGrowth() without a threshold = %+v, want nil", got)
+	}
+}
+
+func TestDatabaseOverrides(t *testing.T) {
+	threshold, spike := 200.0, 100.0
+	cfg := &config.Config{
+		Rules: config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20}},
+		DatabaseOverrides: map[string]config.RuleOverrides{
+			"analytics": {
+				Regression: config.RegressionRuleOverride{ThresholdPercent: &threshold},
+				CallSpike:  config.CallSpikeRuleOverride{ThresholdPercent: &spike},
+			},
+		},
+	}
+	eng := New(cfg, nil)
+
+	// Same 50% slowdown and 10x call growth in both databases
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "oltp", MeanTime: 150, Calls: 1000},
+		{QueryID: 1, DatabaseName: "analytics", MeanTime: 150, Calls: 1000},
+		{QueryID: 2, DatabaseName: "analytics", MeanTime: 400, Calls: 100},
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "oltp", MeanTime: 100, Calls: 100},
+		{QueryID: 1, DatabaseName: "analytics", MeanTime: 100, Calls: 100},
+		{QueryID: 2, DatabaseName: "analytics", MeanTime: 100, Calls: 100},
+	}
+
+	regressions := eng.detectRegressions(current, baseline)
+	if len(regressions) != 2 {
+		t.Fatalf("detectRegressions() returned %d items, want 2: %+v", len(regressions), regressions)
+	}
+	if regressions[0].DatabaseName != "analytics" || regressions[0].QueryID != 2 {
+		t.Errorf("first regression = query %d in %s, want query 2 in analytics (300%% ≥ its 200%% threshold)",
+			regressions[0].QueryID, regressions[0].DatabaseName)
+	}
+	if regressions[1].DatabaseName != "oltp" {
+		t.Errorf("second regression in %s, want oltp (50%% ≥ the global 20%%)", regressions[1].DatabaseName)
+	}
+
+	// Call spikes are off globally and on for analytics only
+	spikes := eng.detectCallSpikes(current, baseline)
+	if len(spikes) != 1 || spikes[0].DatabaseName != "analytics" || spikes[0].QueryID != 1 {
+		t.Errorf("detectCallSpikes() = %+v, want only query 1 in analytics", spikes)
+	}
+}
+
 func TestDetectRegressions_EdgeCases(t *testing.T) {
 	cfg := &config.Config{
 		Rules: config.RulesConfig{
@@ -134,8 +425,11 @@ func TestDetectRegressions_EdgeCases(t *testing.T) {
 
 	t.Run("empty baseline", func(t *testing.T) {
 		result := eng.detectRegressions([]model.MetricSnapshot{{QueryID: 1, MeanTime: 100}}, nil)
-		if result != nil {
-			t.Errorf("Expected nil for empty baseline, got %v", result)
+		if len(result) != 1 || !result[0].IsNewQuery || result[0].Severity != "info" {
+			t.Fatalf("Expected one info new-query entry for empty baseline, got %+v", result)
+		}
+		if result[0].ChangePercent != 0 {
+			t.Errorf("ChangePercent = %f, want 0 for new query", result[0].ChangePercent)
 		}
 	})
 
@@ -143,12 +437,37 @@ func TestDetectRegressions_EdgeCases(t *testing.T) {
 		current := []model.MetricSnapshot{{QueryID: 1, MeanTime: 100}}
 		baseline := []model.MetricSnapshot{{QueryID: 1, MeanTime: 0}}
 		result := eng.detectRegressions(current, baseline)
-		if len(result) != 0 {
-			t.Errorf("Expected 0 regressions for zero baseline, got %d", len(result))
+		if len(result) != 1 || !result[0].IsNewQuery {
+			t.Errorf("Expected a new-query entry for zero baseline, got %+v", result)
+		}
+	})
+
+	t.Run("missing query sorts after regressions", func(t *testing.T) {
+		current := []model.MetricSnapshot{{QueryID: 1, MeanTime: 300}, {QueryID: 2, MeanTime: 50}}
+		baseline := []model.MetricSnapshot{{QueryID: 1, MeanTime: 100}}
+		result := eng.detectRegressions(current, baseline)
+		if len(result) != 2 || result[0].QueryID != 1 || result[1].QueryID != 2 || !result[1].IsNewQuery {
+			t.Errorf("Expected regression for 1 then new query 2, got %+v", result)
 		}
 	})
 }
 
+func TestDetectRegressions_IgnoreNewQueries(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			Regression: config.RegressionRuleConfig{ThresholdPercent: 50, IgnoreNewQueries: true},
+		},
+	}
+	eng := New(cfg, nil)
+
+	current := []model.MetricSnapshot{{QueryID: 1, MeanTime: 100}, {QueryID: 2, MeanTime: 100}}
+	baseline := []model.MetricSnapshot{{QueryID: 2, MeanTime: 0}}
+
+	if result := eng.detectRegressions(current, baseline); len(result) != 0 {
+		t.Errorf("Expected no entries with IgnoreNewQueries, got %+v", result)
+	}
+}
+
 func TestFilterSuggestions(t *testing.T) {
 	cfg := &config.Config{
 		Rules: config.RulesConfig{
@@ -178,10 +497,244 @@ func TestFilterSuggestions(t *testing.T) {
 	}
 }
 
+func TestFilterSuggestions_MinAffectedQueries(t *testing.T) {
+	cfg := &config.Config{
+		Rules: config.RulesConfig{
+			IndexSuggestion: config.IndexSuggestionRuleConfig{
+				MinImprovementPercent: 30,
+				MinAffectedQueries:    3,
+			},
+		},
+	}
+	eng := New(cfg, nil)
+
+	suggestions := []model.IndexSuggestion{
+		{Table: "users", EstImprovementPercent: 50, AffectedQueries: 5},
+		{Table: "audit", EstImprovementPercent: 90, AffectedQueries: 1},  // one rare query
+		{Table: "orders", EstImprovementPercent: 20, AffectedQueries: 8}, // low improvement
+		{Table: "products", EstImprovementPercent: 30, AffectedQueries: 3},
+	}
+
+	result := eng.filterSuggestions(suggestions)
+
+	if len(result) != 2 || result[0].Table != "users" || result[1].Table != "products" {
+		t.Fatalf("filterSuggestions() = %+v, want users and products", result)
+	}
+	if result[0].AffectedQueries != 5 || result[0].EstImprovementPercent != 50 {
+		t.Errorf("kept suggestion = %+v, want affected queries and improvement preserved", result[0])
+	}
+}
+
+func TestFilterSuggestions_Schemas(t *testing.T) {
+	suggestions := []model.IndexSuggestion{
+		{Table: "users", Schema: "public", EstImprovementPercent: 50},
+		{Table: "events", Schema: "audit", EstImprovementPercent: 80},
+		{Table: "jobs", Schema: "vendor", EstImprovementPercent: 60},
+		{Table: "orders", EstImprovementPercent: 40}, // no schema reported: public
+	}
+
+	tests := []struct {
+		name     string
+		analysis config.AnalysisConfig
+		want     []string
+	}{
+		{"no filter", config.AnalysisConfig{}, []string{"events", "jobs", "users", "orders"}},
+		{"exclude", config.AnalysisConfig{ExcludeSchemas: []string{"audit", "vendor"}}, []string{"users", "orders"}},
+		{"include", config.AnalysisConfig{IncludeSchemas: []string{"audit"}}, []string{"events"}},
+		{"include and exclude", config.AnalysisConfig{IncludeSchemas: []string{"public", "vendor"}, ExcludeSchemas: []string{"vendor"}},
+			[]string{"users", "orders"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Analysis: tt.analysis}, nil)
+			var got []string
+			for _, s := range eng.filterSuggestions(suggestions) {
+				got = append(got, s.Table)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("filterSuggestions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeDatabases(t *testing.T) {
+	eng := New(&config.Config{Analysis: config.AnalysisConfig{TopDatabases: 3}}, nil)
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", ServerName: "local", TotalTime: 300, Calls: 10},
+		{QueryID: 2, DatabaseName: "app", ServerName: "local", TotalTime: 200, Calls: 5},
+		{QueryID: 3, DatabaseName: "reports", ServerName: "local", TotalTime: 400, Calls: 2},
+		{QueryID: 4, DatabaseName: "app", ServerName: "replica", TotalTime: 50, Calls: 100},
+		{QueryID: 5, DatabaseName: "auth", ServerName: "local", TotalTime: 50, Calls: 30},
+	}
+
+	got := eng.summarizeDatabases(current)
+	if len(got) != 3 {
+		t.Fatalf("summarizeDatabases() returned %d databases, want 3: %+v", len(got), got)
+	}
+	// app on local sums two queries; the same database name on another server stays separate
+	if got[0].DatabaseName != "app" || got[0].ServerName != "local" || got[0].TotalTime != 500 ||
+		got[0].Calls != 15 || got[0].QueryCount != 2 || got[0].TimePercent != 50 {
+		t.Errorf("first = %+v, want local/app with 500ms, 15 calls, 2 queries, 50%%", got[0])
+	}
+	if got[1].DatabaseName != "reports" {
+		t.Errorf("second = %+v, want reports", got[1])
+	}
+	// Equal total time: more calls ranks first
+	if got[2].DatabaseName != "app" || got[2].ServerName != "replica" {
+		t.Errorf("third = %+v, want replica/app ahead of auth", got[2])
+	}
+
+	if got := New(&config.Config{}, nil).summarizeDatabases(current); got != nil {
+		t.Errorf("summarizeDatabases() with top_databases 0 = %+v, want nil", got)
+	}
+}
+
+func TestMergeUsers(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	metrics := []model.MetricSnapshot{
+		{QueryID: 1, Query: "SELECT 1", DatabaseName: "app", ServerName: "local", TotalTime: 300, MeanTime: 30, Calls: 10, Timestamp: t0, UserID: 10, UserName: "web"},
+		{QueryID: 2, DatabaseName: "app", ServerName: "local", TotalTime: 50, MeanTime: 5, Calls: 10, UserID: 10, UserName: "web"},
+		{QueryID: 1, Query: "SELECT 1", DatabaseName: "app", ServerName: "local", TotalTime: 100, MeanTime: 1, Calls: 90, Timestamp: t0.Add(time.Minute), UserID: 11, UserName: "batch"},
+		{QueryID: 1, DatabaseName: "app", ServerName: "replica", TotalTime: 70, MeanTime: 7, Calls: 10, UserID: 10},
+	}
+
+	got := mergeUsers(metrics)
+	if len(got) != 3 {
+		t.Fatalf("mergeUsers() returned %d rows, want 3: %+v", len(got), got)
+	}
+	// Query 1 on local sums both roles; the mean is recomputed from the sums
+	if q := got[0]; q.QueryID != 1 || q.TotalTime != 400 || q.Calls != 100 || q.MeanTime != 4 ||
+		!q.Timestamp.Equal(t0.Add(time.Minute)) || q.UserID != 0 || q.UserName != "" {
+		t.Errorf("merged query 1 = %+v, want 400ms over 100 calls, mean 4ms, no role", q)
+	}
+	if got[1].QueryID != 2 || got[2].ServerName != "replica" || got[2].UserID != 0 {
+		t.Errorf("other rows = %+v, want query 2 and query 1 on replica without roles", got[1:])
+	}
+	if metrics[0].UserID != 10 || metrics[0].TotalTime != 300 {
+		t.Errorf("mergeUsers() modified its input: %+v", metrics[0])
+	}
+}
+
+func TestSummarizeUsers(t *testing.T) {
+	eng := New(&config.Config{Analysis: config.AnalysisConfig{TopUsers: 2}}, nil)
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", ServerName: "local", TotalTime: 300, Calls: 10, UserID: 10, UserName: "web"},
+		{QueryID: 2, DatabaseName: "app", ServerName: "local", TotalTime: 200, Calls: 5, UserID: 10, UserName: "web"},
+		{QueryID: 2, DatabaseName: "reports", ServerName: "local", TotalTime: 100, Calls: 5, UserID: 10, UserName: "web"},
+		{QueryID: 1, DatabaseName: "app", ServerName: "local", TotalTime: 350, Calls: 2, UserID: 11, UserName: "batch"},
+		{QueryID: 3, DatabaseName: "app", ServerName: "replica", TotalTime: 50, Calls: 1, UserID: 10},
+	}
+
+	got := eng.summarizeUsers(current)
+	if len(got) != 2 {
+		t.Fatalf("summarizeUsers() returned %d roles, want 2: %+v", len(got), got)
+	}
+	// web sums three rows over three distinct queries; the same oid on another server stays separate
+	if got[0].UserName != "web" || got[0].ServerName != "local" || got[0].TotalTime != 600 ||
+		got[0].Calls != 20 || got[0].QueryCount != 3 || got[0].TimePercent != 60 {
+		t.Errorf("first = %+v, want local web with 600ms, 20 calls, 3 queries, 60%%", got[0])
+	}
+	if got[1].UserName != "batch" || got[1].UserID != 11 {
+		t.Errorf("second = %+v, want batch", got[1])
+	}
+
+	if got := New(&config.Config{}, nil).summarizeUsers(current); got != nil {
+		t.Errorf("summarizeUsers() with top_users 0 = %+v, want nil", got)
+	}
+}
+
+func TestDetectRegressions_GroupByUser(t *testing.T) {
+	// The batch role regressed while the web role improved; merged, the query looks flat
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", TotalTime: 100, MeanTime: 1, Calls: 100, UserID: 10, UserName: "web"},
+		{QueryID: 1, DatabaseName: "app", TotalTime: 300, MeanTime: 30, Calls: 10, UserID: 11, UserName: "batch"},
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", TotalTime: 300, MeanTime: 3, Calls: 100, UserID: 10, UserName: "web"},
+		{QueryID: 1, DatabaseName: "app", TotalTime: 100, MeanTime: 10, Calls: 10, UserID: 11, UserName: "batch"},
+	}
+	cfg := &config.Config{Rules: config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50}}}
+
+	eng := New(cfg, nil)
+	if got := eng.detectRegressions(eng.groupUsers(current), eng.groupUsers(baseline)); len(got) != 0 {
+		t.Errorf("merged regressions = %+v, want none", got)
+	}
+
+	cfg.Analysis.GroupByUser = true
+	got := eng.detectRegressions(eng.groupUsers(current), eng.groupUsers(baseline))
+	if len(got) != 1 || got[0].UserName != "batch" || got[0].UserID != 11 || got[0].ChangePercent != 200 {
+		t.Errorf("regressions per role = %+v, want batch up 200%%", got)
+	}
+}
+
+func TestDetectRegressions_TailMetric(t *testing.T) {
+	// Query 1 keeps its mean but its spread grows; query 2 has no stddev and
+	// falls back to the mean, which doubled
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 10, StddevTime: 20, HasStddev: true, Calls: 100},
+		{QueryID: 2, DatabaseName: "app", MeanTime: 20, Calls: 100},
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 10, StddevTime: 5, HasStddev: true, Calls: 100},
+		{QueryID: 2, DatabaseName: "app", MeanTime: 10, Calls: 100},
+	}
+	cfg := &config.Config{Rules: config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50}}}
+	eng := New(cfg, nil)
+
+	if got := eng.detectRegressions(current, baseline); len(got) != 1 || got[0].QueryID != 2 {
+		t.Errorf("mean regressions = %+v, want query 2 only", got)
+	}
+
+	cfg.Rules.Regression.Metric = config.RegressionMetricTail
+	cfg.Rules.Regression.TailFactor = 2
+	got := eng.detectRegressions(current, baseline)
+	if len(got) != 2 {
+		t.Fatalf("tail regressions = %+v, want queries 1 and 2", got)
+	}
+	// Tail 10 + 2*5 = 20 → 10 + 2*20 = 50
+	if r := got[0]; r.QueryID != 1 || r.BaselineTailTime != 20 || r.CurrentTailTime != 50 || r.ChangePercent != 150 ||
+		r.BaselineMeanTime != 10 || r.CurrentMeanTime != 10 {
+		t.Errorf("tail regression = %+v, want tail 20 → 50 (+150%%) with the means", r)
+	}
+	if r := got[1]; r.QueryID != 2 || r.CurrentTailTime != 0 || r.ChangePercent != 100 {
+		t.Errorf("fallback regression = %+v, want the mean compared (+100%%) without a tail", r)
+	}
+}
+
+func TestMergeUsers_PooledStddev(t *testing.T) {
+	// Two roles each at a constant time: pooled, the spread is between them
+	merged := mergeUsers([]model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", TotalTime: 100, MeanTime: 10, Calls: 10, HasStddev: true, UserID: 10},
+		{QueryID: 1, DatabaseName: "app", TotalTime: 300, MeanTime: 30, Calls: 10, HasStddev: true, UserID: 11},
+	})
+	if len(merged) != 1 || merged[0].MeanTime != 20 || merged[0].StddevTime != 10 || !merged[0].HasStddev {
+		t.Errorf("mergeUsers() = %+v, want mean 20 and stddev 10", merged)
+	}
+}
+
 func TestGenerateSummary(t *testing.T) {
 	cfg := &config.Config{}
 	eng := New(cfg, nil)
 
+	t.Run("empty reason", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			alertCtx     *model.AlertContext
+			totalQueries int
+			want         string
+		}{
+			{"no data", &model.AlertContext{}, 0, model.EmptyNoData},
+			{"slow sql only", &model.AlertContext{TopSlowSQL: make([]model.MetricSnapshot, 2)}, 2, model.EmptyNoFindings},
+			{"findings", &model.AlertContext{Regressions: []model.RegressionItem{{QueryID: 1, Severity: "high"}}}, 2, ""},
+		}
+		for _, tt := range tests {
+			if got := eng.generateSummary(tt.alertCtx, tt.totalQueries).Empty; got != tt.want {
+				t.Errorf("%s: Empty = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+	})
+
 	t.Run("healthy score", func(t *testing.T) {
 		alertCtx := &model.AlertContext{
 			TopSlowSQL:  make([]model.MetricSnapshot, 5),
@@ -220,6 +773,24 @@ func TestGenerateSummary(t *testing.T) {
 		}
 	})
 
+	t.Run("new queries are counted separately without penalty", func(t *testing.T) {
+		alertCtx := &model.AlertContext{
+			Regressions: []model.RegressionItem{
+				{Severity: "high"},
+				{Severity: "info", IsNewQuery: true},
+				{Severity: "info", IsNewQuery: true},
+			},
+		}
+		summary := eng.generateSummary(alertCtx, 10)
+
+		if summary.RegressionCount != 1 || summary.NewQueryCount != 2 {
+			t.Errorf("RegressionCount = %d, NewQueryCount = %d, want 1 and 2", summary.RegressionCount, summary.NewQueryCount)
+		}
+		if summary.HealthScore != 90 {
+			t.Errorf("HealthScore = %d, want 90", summary.HealthScore)
+		}
+	})
+
 	t.Run("score floor at 0", func(t *testing.T) {
 		alertCtx := &model.AlertContext{
 			Regressions: []model.RegressionItem{
@@ -287,3 +858,2347 @@ func TestGetHealthStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryRedactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.AnalysisConfig
+		input    string
+		expected string
+	}{
+		{
+			name:     "disabled",
+			cfg:      config.AnalysisConfig{},
+			input:    "SELECT * FROM users WHERE email = 'a@b.c'",
+			expected: "SELECT * FROM users WHERE email = 'a@b.c'",
+		},
+		{
+			name:     "literals",
+			cfg:      config.AnalysisConfig{RedactQueries: true},
+			input:    "SELECT * FROM t1 WHERE name = 'O''Brien' AND age > 42 AND id = $1",
+			expected: "SELECT * FROM t1 WHERE name = *** AND age > *** AND id = $1",
+		},
+		{
+			name:     "patterns",
+			cfg:      config.AnalysisConfig{RedactPatterns: []string{`token=\w+`}},
+			input:    "SELECT f('token=abc123')",
+			expected: "SELECT f('***')",
+		},
+		{
+			name:     "truncation",
+			cfg:      config.AnalysisConfig{MaxQueryLength: 10},
+			input:    "SELECT a, b, c FROM t",
+			expected: "SELECT a, ... (truncated)",
+		},
+		{
+			name:     "truncation keeps runes intact",
+			cfg:      config.AnalysisConfig{MaxQueryLength: 8},
+			input:    "SELECT '日本'",
+			expected: "SELECT '... (truncated)",
+		},
+		{
+			name:     "literal left open by truncation",
+			cfg:      config.AnalysisConfig{RedactQueries: true, MaxQueryLength: 12},
+			input:    "SELECT 'secret-token'",
+			expected: "SELECT ***... (truncated)",
+		},
+		{
+			name:     "unavailable query text placeholder",
+			cfg:      config.AnalysisConfig{RedactQueries: true},
+			input:    model.QueryTextUnavailable(42),
+			expected: "<query text unavailable: queryid 42>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newQueryRedactor(&tt.cfg)
+			metrics := []model.MetricSnapshot{{Query: tt.input}}
+			r.redactMetrics(metrics)
+			if got := metrics[0].Query; got != tt.expected {
+				t.Errorf("redactMetrics(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRedactMetrics_AppliesBeforeRegressions(t *testing.T) {
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{RedactQueries: true},
+		Rules: config.RulesConfig{
+			Regression: config.RegressionRuleConfig{ThresholdPercent: 50},
+		},
+	}
+	eng := New(cfg, nil)
+
+	current := []model.MetricSnapshot{{QueryID: 1, Query: "SELECT 'secret'", MeanTime: 200}}
+	baseline := []model.MetricSnapshot{{QueryID: 1, Query: "SELECT 'secret'", MeanTime: 100}}
+	eng.redactor.redactMetrics(current)
+	eng.redactor.redactMetrics(baseline)
+
+	result := eng.detectRegressions(current, baseline)
+	if len(result) != 1 {
+		t.Fatalf("detectRegressions() returned %d items, want 1", len(result))
+	}
+	if result[0].Query != "SELECT ***" {
+		t.Errorf("regression query = %q, want redacted text", result[0].Query)
+	}
+}
+
+func TestApplyLabels_DatabaseOverridesGlobal(t *testing.T) {
+	cfg := &config.Config{
+		Labels: map[string]string{"env": "prod", "team": "platform"},
+		DatabaseLabels: map[string]map[string]string{
+			"payments": {"team": "payments", "tier": "1"},
+		},
+	}
+	eng := New(cfg, nil)
+
+	alertCtx := &model.AlertContext{
+		TopSlowSQL:  []model.MetricSnapshot{{QueryID: 1, DatabaseName: "payments"}, {QueryID: 2, DatabaseName: "other"}},
+		Regressions: []model.RegressionItem{{QueryID: 3, DatabaseName: "payments"}},
+	}
+	eng.applyLabels(alertCtx)
+
+	if alertCtx.Labels["team"] != "platform" || len(alertCtx.Labels) != 2 {
+		t.Errorf("alert labels = %v, want global labels only", alertCtx.Labels)
+	}
+
+	want := map[string]string{"env": "prod", "team": "payments", "tier": "1"}
+	for _, got := range []map[string]string{alertCtx.TopSlowSQL[0].Labels, alertCtx.Regressions[0].Labels} {
+		if len(got) != len(want) {
+			t.Errorf("labels = %v, want %v", got, want)
+			continue
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("labels[%q] = %q, want %q", k, got[k], v)
+			}
+		}
+	}
+
+	if alertCtx.TopSlowSQL[1].Labels["team"] != "platform" {
+		t.Errorf("database without overrides should inherit global labels, got %v", alertCtx.TopSlowSQL[1].Labels)
+	}
+
+	// Overrides must not leak into the global configuration
+	if cfg.Labels["team"] != "platform" {
+		t.Error("applyLabels mutated the global label map")
+	}
+}
+
+func significanceFixture() *model.AlertContext {
+	return &model.AlertContext{
+		TopSlowSQL: []model.MetricSnapshot{
+			{QueryID: 1, TotalTime: 10000},
+			{QueryID: 2, TotalTime: 2000},
+		},
+		Regressions: []model.RegressionItem{
+			{QueryID: 3, ChangePercent: 400, CurrentMeanTime: 10, CurrentCalls: 100},
+			{QueryID: 4, ChangePercent: 60, CurrentMeanTime: 50, CurrentCalls: 160},
+		},
+		Suggestions: []model.IndexSuggestion{
+			{Table: "orders", AffectedQueries: 8},
+		},
+	}
+}
+
+// rankedIDs flattens the alert into section order for comparison.
+func rankedIDs(alertCtx *model.AlertContext) []string {
+	var ids []string
+	for _, q := range alertCtx.TopSlowSQL {
+		ids = append(ids, fmt.Sprintf("slow:%d", q.QueryID))
+	}
+	for _, r := range alertCtx.Regressions {
+		ids = append(ids, fmt.Sprintf("reg:%d", r.QueryID))
+	}
+	for _, s := range alertCtx.Suggestions {
+		ids = append(ids, "idx:"+s.Table)
+	}
+	return ids
+}
+
+func TestRankFindings_WeightsChangeOrdering(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights config.SignificanceWeights
+		want    []string
+	}{
+		{
+			name:    "total time dominates",
+			weights: config.SignificanceWeights{TotalTime: 1},
+			want:    []string{"slow:1", "slow:2", "reg:4", "reg:3", "idx:orders"},
+		},
+		{
+			name:    "regression percent dominates",
+			weights: config.SignificanceWeights{TotalTime: 0.1, RegressionPercent: 1},
+			want:    []string{"slow:1", "slow:2", "reg:3", "reg:4", "idx:orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Analysis: config.AnalysisConfig{Weights: tt.weights}}
+			eng := New(cfg, nil)
+
+			// Run twice to make sure the ordering is deterministic
+			for i := 0; i < 2; i++ {
+				alertCtx := significanceFixture()
+				eng.rankFindings(alertCtx)
+				got := strings.Join(rankedIDs(alertCtx), ",")
+				if want := strings.Join(tt.want, ","); got != want {
+					t.Errorf("order = %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRankFindings_MaxFindingsDropsLeastSignificant(t *testing.T) {
+	cfg := &config.Config{Analysis: config.AnalysisConfig{
+		Weights:     config.SignificanceWeights{RegressionPercent: 1, AffectedQueries: 0.5},
+		MaxFindings: 2,
+	}}
+	eng := New(cfg, nil)
+
+	alertCtx := significanceFixture()
+	eng.rankFindings(alertCtx)
+
+	got := strings.Join(rankedIDs(alertCtx), ",")
+	if got != "reg:3,idx:orders" {
+		t.Errorf("kept findings = %s, want reg:3,idx:orders", got)
+	}
+	if alertCtx.Summary.OmittedFindings != 3 {
+		t.Errorf("OmittedFindings = %d, want 3", alertCtx.Summary.OmittedFindings)
+	}
+}
+
+func TestRankFindings_NoopWithoutConfig(t *testing.T) {
+	eng := New(&config.Config{}, nil)
+
+	alertCtx := significanceFixture()
+	eng.rankFindings(alertCtx)
+
+	got := strings.Join(rankedIDs(alertCtx), ",")
+	if got != "slow:1,slow:2,reg:3,reg:4,idx:orders" {
+		t.Errorf("order changed without weights: %s", got)
+	}
+}
+
+func TestWindowFor_SinceLastRun(t *testing.T) {
+	cfg := &config.Config{Analysis: config.AnalysisConfig{
+		WindowDuration: "24h",
+		WindowMode:     config.WindowModeSinceLastRun,
+		MaxWindow:      "6h",
+	}}
+	eng := New(cfg, nil)
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{"first run uses window_duration", start, 24 * time.Hour},
+		{"consecutive run covers the gap", start.Add(15 * time.Minute), 15 * time.Minute},
+		{"downtime is capped", start.Add(15*time.Minute + 10*time.Hour), 6 * time.Hour},
+		{"clock skew falls back to window_duration", start, 24 * time.Hour},
+	}
+
+	for _, step := range steps {
+		got, err := eng.windowFor(step.now)
+		if err != nil {
+			t.Fatalf("%s: windowFor() error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: window = %v, want %v", step.name, got, step.want)
+		}
+		// Simulate a successful run ending at now
+		eng.lastRunEnd = step.now
+	}
+}
+
+func TestWindowFor_MaxWindowDuration(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		cfg  config.AnalysisConfig
+		want time.Duration
+	}{
+		{"fixed window within the cap", config.AnalysisConfig{WindowDuration: "24h", MaxWindowDuration: "48h"}, 24 * time.Hour},
+		{"fixed window clamped", config.AnalysisConfig{WindowDuration: "8760h", MaxWindowDuration: "168h"}, 168 * time.Hour},
+		{"no cap", config.AnalysisConfig{WindowDuration: "8760h"}, 8760 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Analysis: tt.cfg}, nil)
+			got, err := eng.windowFor(now)
+			if err != nil {
+				t.Fatalf("windowFor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("window = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Catching up after downtime is clamped too
+	eng := New(&config.Config{Analysis: config.AnalysisConfig{
+		WindowDuration: "1h", WindowMode: config.WindowModeSinceLastRun, MaxWindow: "720h", MaxWindowDuration: "24h",
+	}}, nil)
+	eng.lastRunEnd = now.Add(-100 * time.Hour)
+	if got, err := eng.windowFor(now); err != nil || got != 24*time.Hour {
+		t.Errorf("since_last_run window = %v (err %v), want the 24h cap", got, err)
+	}
+}
+
+// offsetReader records the comparison offset of every baseline fetch.
+type offsetReader struct {
+	rangeReader
+	offsets []time.Duration
+}
+
+func (r *offsetReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	r.offsets = append(r.offsets, offset)
+	return nil, nil
+}
+
+func TestAnalyze_MaxComparisonOffset(t *testing.T) {
+	r := &offsetReader{}
+	cfg := &config.Config{Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "8760h", MaxComparisonOffset: "720h"}}
+	if _, err := New(cfg, r).Analyze(context.Background()); err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(r.offsets) != 1 || r.offsets[0] != 720*time.Hour {
+		t.Errorf("baseline offsets = %v, want the 720h cap", r.offsets)
+	}
+}
+
+func TestAnalyzeRange_MaxWindowDuration(t *testing.T) {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	r := &rangeReader{}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{MaxWindowDuration: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50}},
+	}
+	alertCtx, err := New(cfg, r).AnalyzeRange(context.Background(), day.Add(-30*24*time.Hour), day, day.Add(-48*time.Hour), day.Add(-36*time.Hour))
+	if err != nil {
+		t.Fatalf("AnalyzeRange() error = %v", err)
+	}
+	if got := alertCtx.AnalysisWindow.Start; !got.Equal(day.Add(-24 * time.Hour)) {
+		t.Errorf("current range starts at %s, want it clamped to a day before its end", got)
+	}
+	if got := alertCtx.BaselineWindow.Start; !got.Equal(day.Add(-48 * time.Hour)) {
+		t.Errorf("baseline range starts at %s, want it untouched", got)
+	}
+	if len(r.ranges) != 2 || !r.ranges[0][0].Equal(day.Add(-24*time.Hour)) {
+		t.Errorf("ranges read = %v, want the clamped current range first", r.ranges)
+	}
+}
+
+func TestWindowFor_FixedIgnoresLastRun(t *testing.T) {
+	cfg := &config.Config{Analysis: config.AnalysisConfig{WindowDuration: "1h"}}
+	eng := New(cfg, nil)
+	eng.lastRunEnd = time.Now().Add(-5 * time.Minute)
+
+	got, err := eng.windowFor(time.Now())
+	if err != nil {
+		t.Fatalf("windowFor() error = %v", err)
+	}
+	if got != time.Hour {
+		t.Errorf("window = %v, want 1h", got)
+	}
+}
+
+// rangeReader records the ranges AnalyzeRange requests.
+type rangeReader struct {
+	ranges  [][2]time.Time
+	metrics map[time.Time][]model.MetricSnapshot // keyed by range start
+}
+
+func (r *rangeReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, nil
+}
+
+func (r *rangeReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, nil
+}
+
+func (r *rangeReader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error) {
+	return nil, nil
+}
+
+func (r *rangeReader) GetMetricsRange(ctx context.Context, start, end time.Time) ([]model.MetricSnapshot, error) {
+	r.ranges = append(r.ranges, [2]time.Time{start, end})
+	return r.metrics[start], nil
+}
+
+func TestAnalyzeRange_PassesExactRanges(t *testing.T) {
+	currentStart := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	currentEnd := currentStart.Add(2 * time.Hour)
+	baselineStart := currentStart.Add(-24 * time.Hour)
+	baselineEnd := currentEnd.Add(-24 * time.Hour)
+
+	r := &rangeReader{metrics: map[time.Time][]model.MetricSnapshot{
+		currentStart:  {{QueryID: 1, MeanTime: 30, Calls: 10}},
+		baselineStart: {{QueryID: 1, MeanTime: 10, Calls: 10}},
+	}}
+	cfg := &config.Config{Rules: config.RulesConfig{
+		SlowSQL:    config.SlowSQLRuleConfig{TopN: 5},
+		Regression: config.RegressionRuleConfig{ThresholdPercent: 50},
+	}}
+	eng := New(cfg, r)
+
+	alertCtx, err := eng.AnalyzeRange(context.Background(), currentStart, currentEnd, baselineStart, baselineEnd)
+	if err != nil {
+		t.Fatalf("AnalyzeRange() error = %v", err)
+	}
+
+	want := [][2]time.Time{{currentStart, currentEnd}, {baselineStart, baselineEnd}}
+	if len(r.ranges) != len(want) || r.ranges[0] != want[0] || r.ranges[1] != want[1] {
+		t.Errorf("ranges = %v, want %v", r.ranges, want)
+	}
+	if alertCtx.AnalysisWindow.Start != currentStart || alertCtx.BaselineWindow.End != baselineEnd {
+		t.Errorf("alert windows = %+v / %+v, want the requested ranges", alertCtx.AnalysisWindow, alertCtx.BaselineWindow)
+	}
+	if alertCtx.ReportType != "range" {
+		t.Errorf("ReportType = %q, want range", alertCtx.ReportType)
+	}
+	if len(alertCtx.Regressions) != 1 || alertCtx.Regressions[0].ChangePercent != 200 {
+		t.Errorf("regressions = %+v, want one +200%% regression", alertCtx.Regressions)
+	}
+	if len(alertCtx.TopSlowSQL) != 0 {
+		t.Errorf("range alert should be regression-focused, got %d slow queries", len(alertCtx.TopSlowSQL))
+	}
+}
+
+func TestAnalyzeRange_InvalidRange(t *testing.T) {
+	eng := New(&config.Config{}, &rangeReader{})
+	now := time.Now()
+
+	if _, err := eng.AnalyzeRange(context.Background(), now, now.Add(-time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)); err == nil {
+		t.Error("expected error when current start is after end")
+	}
+}
+
+// customRunner returns a canned result for every custom query.
+type customRunner struct {
+	rangeReader
+	result   *reader.CustomResult
+	queries  []string
+	timeouts []time.Duration
+}
+
+func (c *customRunner) QueryCustom(ctx context.Context, query string, timeout time.Duration) (*reader.CustomResult, error) {
+	c.queries = append(c.queries, query)
+	c.timeouts = append(c.timeouts, timeout)
+	return c.result, nil
+}
+
+func TestEvaluateCustomRules(t *testing.T) {
+	valid := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	runner := &customRunner{result: &reader.CustomResult{
+		Columns: []string{"relname", "n_dead_tup"},
+		Rows: [][]sql.NullString{
+			{valid("orders"), valid("150000")},
+			{valid("users"), valid("20")},
+			{valid("audit"), {}},
+		},
+	}}
+	cfg := &config.Config{Rules: config.RulesConfig{Custom: []config.CustomRuleConfig{{
+		Name:              "dead_tuples",
+		Query:             "SELECT relname, n_dead_tup FROM pg_stat_user_tables",
+		ThresholdColumn:   "n_dead_tup",
+		ThresholdOperator: ">=",
+		ThresholdValue:    100000,
+		Timeout:           "5s",
+	}}}}
+	eng := New(cfg, runner)
+
+	findings := eng.evaluateCustomRules(context.Background())
+
+	if len(runner.queries) != 1 || runner.timeouts[0] != 5*time.Second {
+		t.Errorf("queries = %v timeouts = %v, want one query with 5s timeout", runner.queries, runner.timeouts)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %d: %+v", len(findings), findings)
+	}
+	f := findings[0]
+	if f.Rule != "dead_tuples" || f.Value != 150000 || f.Severity != "medium" || f.Row["relname"] != "orders" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestCompareThreshold(t *testing.T) {
+	tests := []struct {
+		value float64
+		op    string
+		want  bool
+	}{
+		{10, ">", true}, {5, ">", false},
+		{5, ">=", true}, {4, ">=", false},
+		{4, "<", true}, {5, "<", false},
+		{5, "<=", true}, {6, "<=", false},
+		{5, "=", true}, {6, "=", false},
+		{6, "!=", true}, {5, "!=", false},
+		{5, "~", false},
+	}
+	for _, tt := range tests {
+		if got := compareThreshold(tt.value, tt.op, 5); got != tt.want {
+			t.Errorf("compareThreshold(%v %s 5) = %v, want %v", tt.value, tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestStaleDataFinding(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		latest  time.Time
+		wantAge time.Duration
+		stale   bool
+	}{
+		{"fresh", now.Add(-30 * time.Minute), 0, false},
+		{"exactly max age", now.Add(-time.Hour), 0, false},
+		{"stale", now.Add(-3 * time.Hour), 3 * time.Hour, true},
+		{"empty history", time.Time{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := staleDataFinding(tt.latest, now, time.Hour)
+			if (got != nil) != tt.stale {
+				t.Fatalf("staleDataFinding() = %+v, want stale=%v", got, tt.stale)
+			}
+			if got == nil {
+				return
+			}
+			if got.Severity != "high" || got.Age != tt.wantAge || got.MaxAge != time.Hour {
+				t.Errorf("staleDataFinding() = %+v, want high severity, age %v, max age 1h", got, tt.wantAge)
+			}
+		})
+	}
+}
+
+// freshnessReader reports a fixed latest snapshot time.
+type freshnessReader struct {
+	rangeReader
+	latest time.Time
+}
+
+func (r *freshnessReader) LatestSnapshotTime(ctx context.Context) (time.Time, error) {
+	return r.latest, nil
+}
+
+func TestAnalyze_StaleData(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &config.Config{Analysis: config.AnalysisConfig{
+		WindowDuration:   "1h",
+		ComparisonOffset: "24h",
+		MaxDataAge:       "2h",
+	}}
+
+	for _, tt := range []struct {
+		name   string
+		latest time.Time
+		stale  bool
+	}{
+		{"fresh", now.Add(-10 * time.Minute), false},
+		{"collector stopped", now.Add(-26 * time.Hour), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(cfg, &freshnessReader{latest: tt.latest})
+			eng.now = func() time.Time { return now }
+
+			alertCtx, err := eng.Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if (alertCtx.StaleData != nil) != tt.stale {
+				t.Fatalf("StaleData = %+v, want stale=%v", alertCtx.StaleData, tt.stale)
+			}
+			if tt.stale && alertCtx.Summary.HealthScore != 100-StaleDataDeduction {
+				t.Errorf("HealthScore = %d, want %d", alertCtx.Summary.HealthScore, 100-StaleDataDeduction)
+			}
+		})
+	}
+}
+
+// probeReader answers the data probe and counts the metrics fetches.
+type probeReader struct {
+	freshnessReader
+	has      bool
+	probeErr error
+	fetches  int
+	custom   *reader.CustomResult
+}
+
+func (r *probeReader) QueryCustom(ctx context.Context, query string, timeout time.Duration) (*reader.CustomResult, error) {
+	return r.custom, nil
+}
+
+func (r *probeReader) HasDataInWindow(ctx context.Context, window time.Duration) (bool, error) {
+	return r.has, r.probeErr
+}
+
+func (r *probeReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	r.fetches++
+	return []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Calls: 10, TotalTime: 100, MeanTime: 10}}, nil
+}
+
+func TestAnalyze_NoDataProbe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &config.Config{Analysis: config.AnalysisConfig{
+		WindowDuration:   "1h",
+		ComparisonOffset: "24h",
+		MaxDataAge:       "2h",
+	}, Rules: config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 5}}}
+
+	// An empty window skips the metrics queries but still reports stale data
+	r := &probeReader{freshnessReader: freshnessReader{latest: now.Add(-26 * time.Hour)}}
+	eng := New(cfg, r)
+	eng.now = func() time.Time { return now }
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if r.fetches != 0 {
+		t.Errorf("fetched current metrics %d time(s) for an empty window, want 0", r.fetches)
+	}
+	if alertCtx.Summary.Empty != "" || alertCtx.StaleData == nil {
+		t.Errorf("alert = %+v, want only the stale data finding", alertCtx)
+	}
+	if !alertCtx.AnalysisWindow.Start.Equal(now.Add(-time.Hour)) || !alertCtx.AnalysisWindow.End.Equal(now) {
+		t.Errorf("AnalysisWindow = %+v, want the last hour", alertCtx.AnalysisWindow)
+	}
+
+	// Without stale data the alert is a plain no data one
+	r.latest = now.Add(-2 * time.Hour)
+	if alertCtx, _ = eng.Analyze(context.Background()); alertCtx.Summary.Empty != model.EmptyNoData || r.fetches != 0 {
+		t.Errorf("Summary.Empty = %q after %d fetch(es), want %q after none", alertCtx.Summary.Empty, r.fetches, model.EmptyNoData)
+	}
+
+	// Data in the window, or a failed probe, runs the full analysis
+	r.has = true
+	if alertCtx, _ = eng.Analyze(context.Background()); r.fetches != 1 || len(alertCtx.TopSlowSQL) != 1 {
+		t.Errorf("fetched %d time(s) with %d slow queries, want 1 and 1", r.fetches, len(alertCtx.TopSlowSQL))
+	}
+	r.has, r.probeErr = false, errors.New("connection reset")
+	if _, err := eng.Analyze(context.Background()); err != nil || r.fetches != 2 {
+		t.Errorf("Analyze() = %v after %d fetch(es), want the full analysis after a failed probe", err, r.fetches)
+	}
+}
+
+func TestAnalyze_NoDataRunsOtherRules(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules: config.RulesConfig{
+			Regression: config.RegressionRuleConfig{ThresholdPercent: 50, WarmupRuns: 2},
+			Custom: []config.CustomRuleConfig{{
+				Name: "replication_lag", Query: "SELECT lag FROM lag", ThresholdColumn: "lag",
+				ThresholdOperator: ">", ThresholdValue: 30,
+			}},
+		},
+	}
+	r := &probeReader{
+		freshnessReader: freshnessReader{latest: now},
+		custom: &reader.CustomResult{
+			Columns: []string{"lag"},
+			Rows:    [][]sql.NullString{{{String: "60", Valid: true}}},
+		},
+	}
+	eng := New(cfg, r)
+	eng.now = func() time.Time { return now }
+
+	// A stalled collector empties the window, but the custom rule reads
+	// something else and still fires
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(alertCtx.CustomFindings) != 1 || alertCtx.Summary.Empty != "" || r.fetches != 0 {
+		t.Errorf("custom findings = %+v, empty = %q after %d fetch(es), want the lag finding and no fetch",
+			alertCtx.CustomFindings, alertCtx.Summary.Empty, r.fetches)
+	}
+
+	// The empty run moves the since_last_run window but does not use up the warmup
+	if !eng.lastRunEnd.Equal(now) || eng.completedRuns != 0 {
+		t.Errorf("lastRunEnd = %v, completedRuns = %d, want %v and 0", eng.lastRunEnd, eng.completedRuns, now)
+	}
+}
+
+// currentReader returns fixed current metrics.
+type currentReader struct {
+	rangeReader
+	current []model.MetricSnapshot
+}
+
+func (r *currentReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return r.current, nil
+}
+
+func TestAnalyze_RawMetrics(t *testing.T) {
+	r := &currentReader{current: []model.MetricSnapshot{
+		{QueryID: 1, Query: "SELECT * FROM t WHERE id = 42", DatabaseName: "app", Calls: 10, TotalTime: 100, MeanTime: 10},
+		{QueryID: 2, Query: "SELECT 2", DatabaseName: "app", Calls: 10, TotalTime: 300, MeanTime: 30},
+		{QueryID: 3, Query: "SELECT 3", DatabaseName: "app", Calls: 10, TotalTime: 200, MeanTime: 20},
+	}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h", RedactQueries: true},
+		Rules:    config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 1}},
+	}
+
+	// Off by default
+	alertCtx, err := New(cfg, r).Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if alertCtx.RawMetrics != nil {
+		t.Errorf("RawMetrics = %+v without include_raw_metrics, want none", alertCtx.RawMetrics)
+	}
+	if data, _ := json.Marshal(alertCtx); strings.Contains(string(data), "raw_metrics") {
+		t.Errorf("JSON payload has raw_metrics without include_raw_metrics: %s", data)
+	}
+
+	// Enabled: every analyzed row beyond the slow SQL top N, worst first, capped and redacted
+	cfg.Notifier.IncludeRawMetrics = true
+	cfg.Notifier.RawMetricsLimit = 2
+	alertCtx, err = New(cfg, r).Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(alertCtx.RawMetrics) != 2 || alertCtx.RawMetrics[0].QueryID != 2 || alertCtx.RawMetrics[1].QueryID != 3 {
+		t.Fatalf("RawMetrics = %+v, want queries 2 and 3", alertCtx.RawMetrics)
+	}
+	if len(alertCtx.TopSlowSQL) != 1 {
+		t.Errorf("TopSlowSQL has %d queries, want the top 1 only", len(alertCtx.TopSlowSQL))
+	}
+	cfg.Notifier.RawMetricsLimit = 0
+	alertCtx, _ = New(cfg, r).Analyze(context.Background())
+	if len(alertCtx.RawMetrics) != 3 || strings.Contains(alertCtx.RawMetrics[2].Query, "42") {
+		t.Errorf("RawMetrics = %+v, want all 3 queries with redacted text", alertCtx.RawMetrics)
+	}
+}
+
+// instanceReader reports fixed connection counts of a monitored instance.
+type instanceReader struct {
+	usage model.ConnectionUsage
+	err   error
+}
+
+func (r *instanceReader) GetConnectionUsage(ctx context.Context) (model.ConnectionUsage, error) {
+	return r.usage, r.err
+}
+
+func TestConnectionSaturation(t *testing.T) {
+	tests := []struct {
+		name    string
+		usage   model.ConnectionUsage
+		wantPct float64 // 0 when no finding is expected
+	}{
+		{"below threshold", model.ConnectionUsage{Active: 10, Idle: 50, Total: 60, MaxConnections: 100}, 0},
+		{"at threshold", model.ConnectionUsage{Total: 80, MaxConnections: 100}, 0},
+		{"above threshold", model.ConnectionUsage{Active: 30, Idle: 60, Total: 90, MaxConnections: 100}, 90},
+		{"fraction", model.ConnectionUsage{Total: 81, MaxConnections: 90}, 90},
+		{"full", model.ConnectionUsage{Total: 200, MaxConnections: 200}, 100},
+		{"unknown max_connections", model.ConnectionUsage{Total: 10}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := connectionSaturation(tt.usage, 80)
+			if tt.wantPct == 0 {
+				if got != nil {
+					t.Errorf("connectionSaturation() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.UsagePercent != tt.wantPct || got.MaxPercent != 80 || got.ConnectionUsage != tt.usage {
+				t.Errorf("connectionSaturation() = %+v, want %v%% of %+v", got, tt.wantPct, tt.usage)
+			}
+		})
+	}
+}
+
+func TestAnalyze_ConnectionSaturation(t *testing.T) {
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Connections: config.ConnectionsRuleConfig{MaxPercent: 80}},
+	}
+	saturated := &instanceReader{usage: model.ConnectionUsage{Active: 40, Idle: 55, Total: 95, MaxConnections: 100}}
+
+	// No monitored instance: the rule is skipped
+	eng := New(cfg, &rangeReader{})
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if alertCtx.ConnectionSaturation != nil {
+		t.Errorf("ConnectionSaturation without a monitored instance = %+v", alertCtx.ConnectionSaturation)
+	}
+
+	eng.SetInstance(saturated)
+	if alertCtx, err = eng.Analyze(context.Background()); err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if cs := alertCtx.ConnectionSaturation; cs == nil || cs.Total != 95 || cs.UsagePercent != 95 {
+		t.Fatalf("ConnectionSaturation = %+v, want 95 of 100", cs)
+	}
+	if !HasFindings(alertCtx, "") || alertCtx.Summary.Empty != "" {
+		t.Errorf("saturation should count as a finding, summary = %+v", alertCtx.Summary)
+	}
+
+	// A failing lookup is logged and skipped
+	eng.SetInstance(&instanceReader{err: errors.New("connection refused")})
+	if alertCtx, err = eng.Analyze(context.Background()); err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if alertCtx.ConnectionSaturation != nil {
+		t.Errorf("ConnectionSaturation after a lookup error = %+v", alertCtx.ConnectionSaturation)
+	}
+}
+
+// explainReader is a monitored instance that plans every query it is asked to.
+type explainReader struct {
+	instanceReader
+	explained []string
+	err       error
+}
+
+func (r *explainReader) Explain(ctx context.Context, database, query string) (string, error) {
+	r.explained = append(r.explained, query)
+	if r.err != nil {
+		return "", r.err
+	}
+	return "Seq Scan on " + database, nil
+}
+
+func TestApplyExplain(t *testing.T) {
+	cfg := &config.Config{Rules: config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{
+		IncludeExplain: true, MaxExplains: 2, ExplainTimeout: "1s",
+	}}}
+	slowQueries := func() []model.MetricSnapshot {
+		return []model.MetricSnapshot{
+			{QueryID: 1, Query: model.QueryTextUnavailable(1), DatabaseName: "app"},
+			{QueryID: 2, Query: "SELECT * FROM orders WHERE id = $1", DatabaseName: "app"},
+			{QueryID: 3, Query: "SELECT * FROM big" + model.TruncatedQueryMarker, QueryHash: "abc", DatabaseName: "app"},
+			{QueryID: 4, Query: "SELECT count(*) FROM items", DatabaseName: "app"},
+			{QueryID: 5, Query: "SELECT 1", DatabaseName: "app"},
+		}
+	}
+
+	// No monitored instance, or one that cannot plan queries: nothing is explained
+	eng := New(cfg, nil)
+	slow := slowQueries()
+	eng.applyExplain(context.Background(), slow)
+	eng.SetInstance(&instanceReader{})
+	eng.applyExplain(context.Background(), slow)
+	for _, q := range slow {
+		if q.Plan != "" {
+			t.Errorf("query %d has a plan without an explaining instance: %q", q.QueryID, q.Plan)
+		}
+	}
+
+	// Only max_explains plannable queries are explained, worst first
+	er := &explainReader{}
+	eng.SetInstance(er)
+	eng.applyExplain(context.Background(), slow)
+	if want := []string{slow[1].Query, slow[3].Query}; !slices.Equal(er.explained, want) {
+		t.Errorf("explained %q, want %q", er.explained, want)
+	}
+	for i, q := range slow {
+		if wantPlan := i == 1 || i == 3; (q.Plan != "") != wantPlan {
+			t.Errorf("query %d plan = %q, want plan: %v", q.QueryID, q.Plan, wantPlan)
+		}
+	}
+
+	// Disabled by default
+	cfg.Rules.SlowSQL.IncludeExplain = false
+	er.explained = nil
+	eng.applyExplain(context.Background(), slowQueries())
+	if len(er.explained) != 0 {
+		t.Errorf("explained %q with include_explain off", er.explained)
+	}
+
+	// Failures are logged and still count against the cap
+	cfg.Rules.SlowSQL.IncludeExplain = true
+	failing := &explainReader{err: errors.New("permission denied")}
+	eng.SetInstance(failing)
+	slow = slowQueries()
+	eng.applyExplain(context.Background(), slow)
+	if len(failing.explained) != 2 || slow[1].Plan != "" {
+		t.Errorf("explained %q after failures, plan %q", failing.explained, slow[1].Plan)
+	}
+
+	// Redacted text is never planned
+	cfg.Analysis.RedactQueries = true
+	er.explained = nil
+	eng = New(cfg, nil)
+	eng.SetInstance(er)
+	eng.applyExplain(context.Background(), slowQueries())
+	if len(er.explained) != 0 {
+		t.Errorf("explained %q with redaction on", er.explained)
+	}
+}
+
+func TestHasFindings(t *testing.T) {
+	populated := &model.AlertContext{
+		TopSlowSQL:     []model.MetricSnapshot{{QueryID: 1}},
+		Regressions:    []model.RegressionItem{{QueryID: 2, Severity: "high"}, {QueryID: 3, Severity: "info", IsNewQuery: true}},
+		CustomFindings: []model.CustomFinding{{Rule: "dead_tuples", Severity: "medium"}},
+	}
+	tests := []struct {
+		name        string
+		alert       *model.AlertContext
+		minSeverity string
+		want        bool
+	}{
+		{"nil alert", nil, "", false},
+		{"empty alert", &model.AlertContext{}, "", false},
+		{"slow SQL only", &model.AlertContext{TopSlowSQL: []model.MetricSnapshot{{QueryID: 1}}}, "", false},
+		{"suggestion without severity", &model.AlertContext{Suggestions: []model.IndexSuggestion{{Table: "orders"}}}, "", true},
+		{"suggestion below any severity", &model.AlertContext{Suggestions: []model.IndexSuggestion{{Table: "orders"}}}, "info", false},
+		{"any finding", populated, "", true},
+		{"high meets high", populated, "high", true},
+		{"high below critical", populated, "critical", false},
+		{"custom medium meets medium", &model.AlertContext{CustomFindings: populated.CustomFindings}, "medium", true},
+		{"stale data is high", &model.AlertContext{StaleData: &model.StaleDataFinding{Severity: "high"}}, "critical", false},
+		{"stale data meets high", &model.AlertContext{StaleData: &model.StaleDataFinding{Severity: "high"}}, "high", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasFindings(tt.alert, tt.minSeverity); got != tt.want {
+				t.Errorf("HasFindings(%q) = %v, want %v", tt.minSeverity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexDDL(t *testing.T) {
+	tests := []struct {
+		name string
+		s    model.IndexSuggestion
+		want string
+	}{
+		{
+			name: "single column",
+			s:    model.IndexSuggestion{Schema: "public", Table: "orders", Columns: []string{"customer_id"}},
+			want: `CREATE INDEX CONCURRENTLY ON "public"."orders" ("customer_id");`,
+		},
+		{
+			name: "multi column",
+			s:    model.IndexSuggestion{Schema: "sales", Table: "order_items", Columns: []string{"order_id", "sku"}},
+			want: `CREATE INDEX CONCURRENTLY ON "sales"."order_items" ("order_id", "sku");`,
+		},
+		{
+			name: "identifiers needing quotes",
+			s:    model.IndexSuggestion{Table: `Weird "Table"`, Columns: []string{"Mixed Case", "select"}},
+			want: `CREATE INDEX CONCURRENTLY ON "Weird ""Table""" ("Mixed Case", "select");`,
+		},
+		{
+			name: "no columns",
+			s:    model.IndexSuggestion{Table: "orders"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indexDDL(tt.s); got != tt.want {
+				t.Errorf("indexDDL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsolidateSuggestions(t *testing.T) {
+	suggestions := []model.IndexSuggestion{
+		{Schema: "public", Table: "orders", Columns: []string{"customer_id"}, EstImprovementPercent: 80, AffectedQueries: 2},
+		{Schema: "public", Table: "orders", Columns: []string{"customer_id", "created_at"}, EstImprovementPercent: 40, AffectedQueries: 1},
+		{Schema: "public", Table: "orders", Columns: []string{"created_at"}, EstImprovementPercent: 30, AffectedQueries: 1},
+		{Schema: "public", Table: "users", Columns: []string{"email"}, EstImprovementPercent: 60, AffectedQueries: 3,
+			SuggestedDDL: "CREATE INDEX ON users USING btree (email)"},
+	}
+
+	result := consolidateSuggestions(suggestions)
+
+	if len(result) != 3 {
+		t.Fatalf("consolidateSuggestions() returned %d items, want 3: %+v", len(result), result)
+	}
+	// customer_id is served by (customer_id, created_at) and lends it its estimate
+	if got := strings.Join(result[0].Columns, ","); got != "customer_id,created_at" {
+		t.Errorf("first suggestion columns = %s, want customer_id,created_at", got)
+	}
+	if result[0].EstImprovementPercent != 80 || result[0].AffectedQueries != 3 {
+		t.Errorf("merged suggestion = %v%% / %d queries, want 80%% / 3", result[0].EstImprovementPercent, result[0].AffectedQueries)
+	}
+	if result[0].SuggestedDDL != `CREATE INDEX CONCURRENTLY ON "public"."orders" ("customer_id", "created_at");` {
+		t.Errorf("merged DDL = %q", result[0].SuggestedDDL)
+	}
+	if result[1].Table != "users" || result[1].SuggestedDDL != "CREATE INDEX ON users USING btree (email)" {
+		t.Errorf("second suggestion should keep its hypopg DDL, got %+v", result[1])
+	}
+	if got := strings.Join(result[2].Columns, ","); got != "created_at" {
+		t.Errorf("non-leading column must not be merged, got third suggestion %s", got)
+	}
+	if len(suggestions[1].Columns) != 2 || suggestions[0].SuggestedDDL != "" {
+		t.Error("consolidateSuggestions() must not modify its input")
+	}
+}
+
+func TestConsolidateSuggestions_MergesExamples(t *testing.T) {
+	status := model.ColumnExample{Column: "status", Values: []string{"'open'"}, Selectivity: 0.02}
+	created := model.ColumnExample{Column: "created_at", Values: []string{"'2026-01-01'"}}
+	suggestions := []model.IndexSuggestion{
+		{Schema: "public", Table: "orders", Columns: []string{"status"}, EstImprovementPercent: 70, Examples: []model.ColumnExample{status}},
+		{Schema: "public", Table: "orders", Columns: []string{"status", "created_at"}, EstImprovementPercent: 40,
+			Examples: []model.ColumnExample{created}},
+	}
+
+	result := consolidateSuggestions(suggestions)
+
+	if len(result) != 1 {
+		t.Fatalf("consolidateSuggestions() returned %d items, want 1", len(result))
+	}
+	if got := result[0].Examples; len(got) != 2 || got[0].Column != "created_at" || got[1].Column != "status" {
+		t.Errorf("merged examples = %+v, want created_at then the folded status", got)
+	}
+	if len(suggestions[1].Examples) != 1 {
+		t.Error("consolidateSuggestions() must not modify its input examples")
+	}
+}
+
+func TestRedactSuggestions(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AnalysisConfig
+		want string
+	}{
+		{"disabled", config.AnalysisConfig{}, "'alice@example.com'"},
+		{"literals", config.AnalysisConfig{RedactQueries: true}, "***"},
+		{"patterns", config.AnalysisConfig{RedactPatterns: []string{`[\w.]+@[\w.]+`}}, "'***'"},
+		{"length only", config.AnalysisConfig{MaxQueryLength: 5}, "'alice@example.com'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := []model.IndexSuggestion{{
+				Columns:  []string{"email"},
+				Examples: []model.ColumnExample{{Column: "email", Values: []string{"'alice@example.com'"}}},
+			}}
+			newQueryRedactor(&tt.cfg).redactSuggestions(suggestions)
+			if got := suggestions[0].Examples[0].Values[0]; got != tt.want {
+				t.Errorf("example value = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// sequenceReader returns the next current snapshot set on every run against a fixed baseline.
+type sequenceReader struct {
+	rangeReader
+	runs     [][]model.MetricSnapshot
+	baseline []model.MetricSnapshot
+	run      int
+}
+
+func (r *sequenceReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	current := r.runs[r.run]
+	r.run++
+	return current, nil
+}
+
+func (r *sequenceReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	return r.baseline, nil
+}
+
+func TestAnalyze_RecoveryOnce(t *testing.T) {
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 100},
+		{QueryID: 2, DatabaseName: "app", MeanTime: 100},
+	}
+	// Query 1 regresses by 200% (high), query 2 by 50% (low)
+	regressed := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 300},
+		{QueryID: 2, DatabaseName: "app", MeanTime: 150},
+	}
+	normal := slices.Clone(baseline)
+	r := &sequenceReader{
+		baseline: baseline,
+		runs:     [][]model.MetricSnapshot{regressed, nil, normal, normal, regressed, normal},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50}},
+		Notifier: config.NotifierConfig{RecoverySeverity: "medium"},
+	}
+	eng := New(cfg, r)
+
+	// Run 2 has no data and says nothing; query 1 recovers in run 3 and only
+	// then, query 2 is below the recovery severity, and a new streak recovers again
+	wantRecovered := []bool{false, false, true, false, false, true}
+	for run, want := range wantRecovered {
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		rec := alertCtx.Recovery
+		if !want {
+			if rec != nil {
+				t.Errorf("run %d: unexpected recovery %+v", run+1, rec.Delta.Resolved)
+			}
+			continue
+		}
+		if rec == nil {
+			t.Fatalf("run %d: no recovery, want query 1 recovered", run+1)
+		}
+		if rec.ReportType != "recovery" || rec.ReqID != alertCtx.ReqID || len(rec.Delta.Resolved) != 1 ||
+			rec.Delta.Resolved[0].QueryID != 1 || rec.Delta.Resolved[0].Severity != "high" {
+			t.Errorf("run %d: recovery = %s %+v, want the high regression of query 1", run+1, rec.ReportType, rec.Delta.Resolved)
+		}
+	}
+}
+
+func TestAnalyze_RecoveryIgnoresCooldownAndSilence(t *testing.T) {
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 100},
+		{QueryID: 2, DatabaseName: "app", Query: "SELECT 2", MeanTime: 100},
+	}
+	// Both queries regress by 200% (high) for three runs, then clear
+	regressed := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 300},
+		{QueryID: 2, DatabaseName: "app", Query: "SELECT 2", MeanTime: 300},
+	}
+	normal := slices.Clone(baseline)
+	silences := filepath.Join(t.TempDir(), "silences.yaml")
+	r := &sequenceReader{baseline: baseline, runs: [][]model.MetricSnapshot{regressed, regressed, regressed, normal}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h", SilenceFile: silences},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50, Cooldown: "24h"}},
+		Notifier: config.NotifierConfig{RecoverySeverity: "medium"},
+	}
+	eng := New(cfg, r)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The cooldown holds both regressions back after the first run, and query 2
+	// is silenced from the second: neither recovers until the fourth run clears them
+	for run, wantRecovered := range []int{0, 0, 0, 2} {
+		content := ""
+		if run > 0 {
+			content = "silences:\n  - queryid: 2\n"
+		}
+		if err := os.WriteFile(silences, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		now := start.Add(time.Duration(run) * time.Hour)
+		eng.now = func() time.Time { return now }
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		eng.RecordDelivery(alertCtx)
+
+		var recovered int
+		if alertCtx.Recovery != nil {
+			recovered = len(alertCtx.Recovery.Delta.Resolved)
+		}
+		if recovered != wantRecovered {
+			t.Errorf("run %d: %d findings recovered, want %d", run+1, recovered, wantRecovered)
+		}
+	}
+}
+
+func TestAnalyze_DeltaAcrossRuns(t *testing.T) {
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 100},
+		{QueryID: 2, DatabaseName: "app", MeanTime: 100},
+		{QueryID: 3, DatabaseName: "app", MeanTime: 100},
+	}
+	regressed := func(ids ...int64) []model.MetricSnapshot {
+		var out []model.MetricSnapshot
+		for _, id := range ids {
+			out = append(out, model.MetricSnapshot{QueryID: id, DatabaseName: "app", MeanTime: 300})
+		}
+		return out
+	}
+	r := &sequenceReader{
+		baseline: baseline,
+		runs:     [][]model.MetricSnapshot{regressed(1, 2), regressed(2, 3), regressed(3)},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 50}},
+		Notifier: config.NotifierConfig{Mode: config.NotifierModeDelta},
+	}
+	eng := New(cfg, r)
+
+	ids := func(refs []model.FindingRef) []int64 {
+		var out []int64
+		for _, ref := range refs {
+			out = append(out, ref.QueryID)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+		return out
+	}
+	want := []struct{ new, resolved, still []int64 }{
+		{new: []int64{1, 2}},
+		{new: []int64{3}, resolved: []int64{1}, still: []int64{2}},
+		{resolved: []int64{2}, still: []int64{3}},
+	}
+	for run, w := range want {
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		d := alertCtx.Delta
+		if d == nil {
+			t.Fatalf("run %d: Delta not set in delta mode", run+1)
+		}
+		if fmt.Sprint(ids(d.New)) != fmt.Sprint(w.new) || fmt.Sprint(ids(d.Resolved)) != fmt.Sprint(w.resolved) ||
+			fmt.Sprint(ids(d.Still)) != fmt.Sprint(w.still) {
+			t.Errorf("run %d: new=%v resolved=%v still=%v, want new=%v resolved=%v still=%v", run+1,
+				ids(d.New), ids(d.Resolved), ids(d.Still), w.new, w.resolved, w.still)
+		}
+	}
+}
+
+func TestDiffFindings_KeysByRuleAndQuery(t *testing.T) {
+	previous := map[string]model.FindingRef{}
+	for _, ref := range []model.FindingRef{
+		{Rule: "regression", QueryID: 1, DatabaseName: "app"},
+		{Rule: "index_suggestion", Subject: "orders (customer_id)"},
+	} {
+		previous[ref.Key()] = ref
+	}
+	current := []model.FindingRef{
+		{Rule: "call_spike", QueryID: 1, DatabaseName: "app"},       // same query, different rule
+		{Rule: "regression", QueryID: 1, DatabaseName: "reporting"}, // same query id, other database
+		{Rule: "index_suggestion", Subject: "orders (customer_id)"},
+	}
+
+	delta, next := diffFindings(previous, current)
+
+	if len(delta.New) != 2 || len(delta.Still) != 1 || len(delta.Resolved) != 1 {
+		t.Fatalf("delta = %+v, want 2 new, 1 still, 1 resolved", delta)
+	}
+	if delta.Resolved[0].Rule != "regression" || delta.Resolved[0].DatabaseName != "app" {
+		t.Errorf("resolved = %+v, want the app regression", delta.Resolved[0])
+	}
+	if len(next) != 3 {
+		t.Errorf("next finding set has %d entries, want 3", len(next))
+	}
+}
+
+func TestDiffFindings_KeysByRole(t *testing.T) {
+	// With analysis.group_by_user one query regresses for two roles
+	first := []model.FindingRef{
+		regressionRef(model.RegressionItem{QueryID: 1, DatabaseName: "app", UserID: 10}),
+		regressionRef(model.RegressionItem{QueryID: 1, DatabaseName: "app", UserID: 20}),
+	}
+	_, previous := diffFindings(nil, first)
+	if len(previous) != 2 {
+		t.Fatalf("finding set has %d entries, want one per role", len(previous))
+	}
+
+	delta, _ := diffFindings(previous, first[:1])
+	if len(delta.Still) != 1 || len(delta.Resolved) != 1 || delta.Resolved[0].UserID != 20 {
+		t.Errorf("delta = %+v, want role 10 still and role 20 resolved", delta)
+	}
+}
+
+func TestAnalyze_StableOrderAcrossRuns(t *testing.T) {
+	// Every query ties on every metric, so only the tie-breaks order them
+	rows := func(order ...int) []model.MetricSnapshot {
+		var out []model.MetricSnapshot
+		for _, i := range order {
+			out = append(out, model.MetricSnapshot{
+				QueryID: int64(i % 2), DatabaseName: fmt.Sprintf("db%d", i), Query: "SELECT 1",
+				MeanTime: 300, TotalTime: 3000, Calls: 100,
+			})
+		}
+		return out
+	}
+	r := &sequenceReader{
+		baseline: []model.MetricSnapshot{
+			{QueryID: 0, DatabaseName: "db2", MeanTime: 100, Calls: 10},
+			{QueryID: 1, DatabaseName: "db1", MeanTime: 100, Calls: 10},
+			{QueryID: 0, DatabaseName: "db4", MeanTime: 100, Calls: 10},
+			{QueryID: 1, DatabaseName: "db3", MeanTime: 100, Calls: 10},
+		},
+		runs: [][]model.MetricSnapshot{rows(1, 2, 3, 4), rows(4, 3, 2, 1), rows(3, 1, 4, 2)},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules: config.RulesConfig{
+			SlowSQL:    config.SlowSQLRuleConfig{TopN: 3, RankBy: config.RankByList{"total_time"}},
+			Regression: config.RegressionRuleConfig{ThresholdPercent: 50},
+			CallSpike:  config.CallSpikeRuleConfig{ThresholdPercent: 100},
+		},
+	}
+	eng := New(cfg, r)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	eng.now = func() time.Time { return now }
+
+	var first []byte
+	for run := range r.runs {
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		alertCtx.ReqID = ""
+		got, err := json.Marshal(alertCtx)
+		if err != nil {
+			t.Fatalf("run %d: marshal: %v", run+1, err)
+		}
+		if run == 0 {
+			first = got
+			if n := len(alertCtx.Regressions); n != 4 {
+				t.Fatalf("regressions = %d, want 4", n)
+			}
+			var order []string
+			for _, q := range alertCtx.TopSlowSQL {
+				order = append(order, fmt.Sprintf("%d/%s", q.QueryID, q.DatabaseName))
+			}
+			if want := "0/db2 0/db4 1/db1"; strings.Join(order, " ") != want {
+				t.Errorf("slow SQL order = %v, want %s", order, want)
+			}
+			continue
+		}
+		if string(got) != string(first) {
+			t.Errorf("run %d alert differs from run 1:\n%s\n%s", run+1, got, first)
+		}
+	}
+}
+
+func TestAnalyze_SilenceFile(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "silences.yaml")
+	writeSilences := func(t *testing.T, text string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	baseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Query: "SELECT * FROM orders", MeanTime: 100},
+		{QueryID: 2, DatabaseName: "app", Query: "COPY batch FROM STDIN", MeanTime: 100},
+		{QueryID: 3, DatabaseName: "app", Query: "UPDATE stock SET n = $1", MeanTime: 100},
+	}
+	current := func() []model.MetricSnapshot {
+		out := make([]model.MetricSnapshot, len(baseline))
+		for i, m := range baseline {
+			m.MeanTime, m.Calls, m.TotalTime = 300, 10, 3000
+			out[i] = m
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		silences string
+		want     []int64
+	}{
+		{
+			name: "queryid and pattern",
+			silences: `silences:
+  - queryid: 1
+    comment: known slow report
+  - pattern: "^COPY "
+    until: 2024-01-01T18:00:00Z
+`,
+			want: []int64{3},
+		},
+		{
+			name: "expired silence is ignored",
+			silences: `silences:
+  - queryid: 1
+    until: 2024-01-01T11:00:00Z
+`,
+			want: []int64{1, 2, 3},
+		},
+		{
+			name: "other database",
+			silences: `silences:
+  - queryid: 1
+    database: reporting
+`,
+			want: []int64{1, 2, 3},
+		},
+		{
+			name: "invalid file silences nothing",
+			silences: `silences:
+  - pattern: "("
+`,
+			want: []int64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeSilences(t, tt.silences)
+			r := &sequenceReader{baseline: baseline, runs: [][]model.MetricSnapshot{current()}}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h", SilenceFile: path},
+				Rules: config.RulesConfig{
+					SlowSQL:    config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"total_time"}},
+					Regression: config.RegressionRuleConfig{ThresholdPercent: 50},
+				},
+			}
+			eng := New(cfg, r)
+			eng.now = func() time.Time { return now }
+
+			alertCtx, err := eng.Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			var slow, regressed []int64
+			for _, q := range alertCtx.TopSlowSQL {
+				slow = append(slow, q.QueryID)
+			}
+			for _, r := range alertCtx.Regressions {
+				regressed = append(regressed, r.QueryID)
+			}
+			if fmt.Sprint(slow) != fmt.Sprint(tt.want) || fmt.Sprint(regressed) != fmt.Sprint(tt.want) {
+				t.Errorf("slow SQL = %v, regressions = %v, want %v", slow, regressed, tt.want)
+			}
+			if alertCtx.Summary.RegressionCount != len(tt.want) {
+				t.Errorf("RegressionCount = %d, want %d", alertCtx.Summary.RegressionCount, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestAnalyze_RuleCooldown(t *testing.T) {
+	baseline := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 100}}
+	current := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 300, Calls: 10, TotalTime: 3000}}
+	r := &sequenceReader{baseline: baseline, runs: [][]model.MetricSnapshot{current, current, current, current}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules: config.RulesConfig{
+			SlowSQL:    config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"total_time"}},
+			Regression: config.RegressionRuleConfig{ThresholdPercent: 50, Cooldown: "24h"},
+		},
+	}
+	eng := New(cfg, r)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Hourly runs: the first delivery fails, so the regression is held back
+	// until a day after the second run delivered it
+	for _, run := range []struct {
+		after       time.Duration
+		delivered   bool
+		regressions int
+	}{
+		{0, false, 1},
+		{time.Hour, true, 1},
+		{2 * time.Hour, true, 0},
+		{26 * time.Hour, true, 1},
+	} {
+		now := start.Add(run.after)
+		eng.now = func() time.Time { return now }
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("Analyze() error = %v", err)
+		}
+		if len(alertCtx.Regressions) != run.regressions || alertCtx.Summary.RegressionCount != run.regressions {
+			t.Errorf("run at +%s: %d regressions (summary %d), want %d",
+				run.after, len(alertCtx.Regressions), alertCtx.Summary.RegressionCount, run.regressions)
+		}
+		if len(alertCtx.TopSlowSQL) != 1 {
+			t.Errorf("run at +%s: slow SQL without a cooldown must report every run, got %d", run.after, len(alertCtx.TopSlowSQL))
+		}
+		if run.delivered {
+			eng.RecordDelivery(alertCtx)
+		}
+	}
+}
+
+func TestAnalyze_CooldownKeepsEscalationAndDelta(t *testing.T) {
+	baseline := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 100}}
+	critical := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", MeanTime: 700}}
+	r := &sequenceReader{baseline: baseline, runs: [][]model.MetricSnapshot{critical, critical, critical}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20, Cooldown: "24h"}},
+		Notifier: config.NotifierConfig{Mode: config.NotifierModeDelta, Escalation: &config.EscalationConfig{AfterRuns: 3}},
+	}
+	eng := New(cfg, r)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The cooldown holds the critical regression back after the first run, but
+	// it keeps firing: it stays in the delta and escalates on the third run
+	for run, wantRegressions := range []int{1, 0, 0} {
+		now := start.Add(time.Duration(run) * time.Hour)
+		eng.now = func() time.Time { return now }
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		eng.RecordDelivery(alertCtx)
+
+		if len(alertCtx.Regressions) != wantRegressions {
+			t.Errorf("run %d: %d regressions in the alert, want %d", run+1, len(alertCtx.Regressions), wantRegressions)
+		}
+		if d := alertCtx.Delta; d == nil || len(d.Resolved) != 0 {
+			t.Errorf("run %d: delta = %+v, want the held regression not resolved", run+1, d)
+		}
+		escalated := alertCtx.Escalation != nil
+		if escalated != (run == 2) {
+			t.Errorf("run %d: escalated = %v, want %v", run+1, escalated, run == 2)
+		}
+		if escalated && (len(alertCtx.Escalation.Regressions) != 1 || alertCtx.Escalation.Regressions[0].QueryID != 1) {
+			t.Errorf("run %d: escalation regressions = %+v, want query 1", run+1, alertCtx.Escalation.Regressions)
+		}
+	}
+}
+
+func TestApplyCooldowns_CustomRules(t *testing.T) {
+	cfg := &config.Config{Rules: config.RulesConfig{Custom: []config.CustomRuleConfig{
+		{Name: "bloat", Cooldown: "6h"},
+		{Name: "locks"},
+	}}}
+	eng := New(cfg, nil)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	findings := func() *model.AlertContext {
+		return &model.AlertContext{CustomFindings: []model.CustomFinding{{Rule: "bloat"}, {Rule: "locks"}, {Rule: "bloat"}}}
+	}
+
+	first := findings()
+	first.Timestamp = now
+	eng.applyCooldowns(first, now)
+	if len(first.CustomFindings) != 3 {
+		t.Fatalf("first run kept %d custom findings, want 3", len(first.CustomFindings))
+	}
+	eng.RecordDelivery(first)
+	second := findings()
+	eng.applyCooldowns(second, now.Add(time.Hour))
+	if len(second.CustomFindings) != 1 || second.CustomFindings[0].Rule != "locks" {
+		t.Errorf("during the cooldown, custom findings = %+v, want only locks", second.CustomFindings)
+	}
+}
+
+func TestAnalyze_Allowlist(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(queryID int64, query string, mean float64, calls int64) model.MetricSnapshot {
+		return model.MetricSnapshot{QueryID: queryID, DatabaseName: "app", Query: query,
+			MeanTime: mean, Calls: calls, TotalTime: mean * float64(calls)}
+	}
+	baseline := []model.MetricSnapshot{
+		snap(1, "SELECT build_monthly_report($1)", 100, 10),
+		snap(2, "SELECT * FROM report_export WHERE id = $1", 100, 10),
+		snap(3, "SELECT * FROM orders WHERE id = $1", 100, 10),
+	}
+	// Queries 1 and 2 regress, spike, grow the workload and flap; query 3 is steady
+	current := []model.MetricSnapshot{
+		snap(1, "SELECT build_monthly_report($1)", 1000, 100),
+		snap(2, "SELECT * FROM report_export WHERE id = $1", 1000, 100),
+		snap(3, "SELECT * FROM orders WHERE id = $1", 100, 10),
+	}
+	flapping := map[time.Time][]model.MetricSnapshot{}
+	for i, mean := range []float64{10, 1000, 10} {
+		start := now.Add(-time.Duration(3-i) * time.Hour)
+		flapping[start] = []model.MetricSnapshot{
+			snap(1, "SELECT build_monthly_report($1)", mean, 10),
+			snap(2, "SELECT * FROM report_export WHERE id = $1", mean, 10),
+			snap(3, "SELECT * FROM orders WHERE id = $1", 100, 10),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		ids         []int64
+		patterns    []string
+		wantFinding bool
+	}{
+		{name: "no allowlist", wantFinding: true},
+		{name: "queryid only", ids: []int64{1}, wantFinding: true},
+		{name: "queryid and pattern", ids: []int64{1}, patterns: []string{`\breport_export\b`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &sequenceReader{
+				rangeReader: rangeReader{metrics: flapping},
+				baseline:    baseline,
+				runs:        [][]model.MetricSnapshot{current},
+			}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{
+					WindowDuration:    "1h",
+					ComparisonOffset:  "24h",
+					RedactQueries:     true,
+					AllowlistQueryIDs: tt.ids,
+					AllowlistPatterns: tt.patterns,
+				},
+				Rules: config.RulesConfig{
+					SlowSQL:        config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"total_time"}},
+					Regression:     config.RegressionRuleConfig{ThresholdPercent: 50},
+					CallSpike:      config.CallSpikeRuleConfig{ThresholdPercent: 100},
+					Flapping:       config.FlappingRuleConfig{Windows: 3, MaxCV: 0.5},
+					WorkloadGrowth: config.WorkloadGrowthRuleConfig{ThresholdPercent: 50, TopContributors: 5},
+				},
+			}
+			eng := New(cfg, r)
+			eng.now = func() time.Time { return now }
+
+			alertCtx, err := eng.Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if got := HasFindings(alertCtx, ""); got != tt.wantFinding {
+				t.Errorf("HasFindings() = %v, want %v: %+v", got, tt.wantFinding, findingRefs(alertCtx))
+			}
+			for _, ref := range findingRefs(alertCtx) {
+				if slices.Contains(tt.ids, ref.QueryID) {
+					t.Errorf("allowlisted query %d reported by %s", ref.QueryID, ref.Rule)
+				}
+			}
+			if !tt.wantFinding && (len(alertCtx.TopSlowSQL) != 1 || alertCtx.TopSlowSQL[0].QueryID != 3) {
+				t.Errorf("TopSlowSQL = %+v, want only query 3", alertCtx.TopSlowSQL)
+			}
+		})
+	}
+	// The reader's rows are left intact for the next run
+	if len(current) != 3 || current[1].QueryID != 2 {
+		t.Errorf("current metrics were modified: %+v", current)
+	}
+}
+
+// targetedReader serves the baseline by queryid and records the ids asked for.
+type targetedReader struct {
+	sequenceReader
+	requested []int64
+}
+
+func (r *targetedReader) GetBaselineMetricsFor(ctx context.Context, offset, window time.Duration, queryIDs []int64) ([]model.MetricSnapshot, error) {
+	r.requested = queryIDs
+	var out []model.MetricSnapshot
+	for _, m := range r.baseline {
+		if slices.Contains(queryIDs, m.QueryID) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestAnalyze_BaselineScope(t *testing.T) {
+	// Query 3 is beyond the baseline window's row limit: only the targeted fetch finds it
+	windowBaseline := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 100},
+		{QueryID: 9, DatabaseName: "app", MeanTime: 100}, // no longer runs
+	}
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", MeanTime: 300, Calls: 10},
+		{QueryID: 3, DatabaseName: "app", MeanTime: 300, Calls: 10},
+		{QueryID: 3, DatabaseName: "app", MeanTime: 300, Calls: 5}, // second user
+	}
+
+	tests := []struct {
+		scope         string
+		wantRequested []int64
+		wantNew       bool // query 3 reported as a new query
+		wantOnly      int
+	}{
+		{scope: config.BaselineScopeCurrentQueries, wantRequested: []int64{1, 3}, wantOnly: 0},
+		{scope: config.BaselineScopeWindow, wantNew: true, wantOnly: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			r := &targetedReader{sequenceReader: sequenceReader{
+				runs:     [][]model.MetricSnapshot{current},
+				baseline: windowBaseline,
+			}}
+			if tt.scope == config.BaselineScopeCurrentQueries {
+				r.baseline = append(windowBaseline, model.MetricSnapshot{QueryID: 3, DatabaseName: "app", MeanTime: 100})
+			}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+				Rules: config.RulesConfig{Regression: config.RegressionRuleConfig{
+					ThresholdPercent: 50,
+					BaselineScope:    tt.scope,
+				}},
+			}
+			alertCtx, err := New(cfg, r).Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if fmt.Sprint(r.requested) != fmt.Sprint(tt.wantRequested) {
+				t.Errorf("requested queryids = %v, want %v", r.requested, tt.wantRequested)
+			}
+			var gotNew bool
+			for _, reg := range alertCtx.Regressions {
+				if reg.QueryID == 3 {
+					gotNew = reg.IsNewQuery
+				}
+			}
+			if gotNew != tt.wantNew {
+				t.Errorf("query 3 IsNewQuery = %v, want %v", gotNew, tt.wantNew)
+			}
+			if alertCtx.Summary.BaselineOnlyQueries != tt.wantOnly {
+				t.Errorf("BaselineOnlyQueries = %d, want %d", alertCtx.Summary.BaselineOnlyQueries, tt.wantOnly)
+			}
+		})
+	}
+}
+
+func TestAnalyze_NoDeltaInFullMode(t *testing.T) {
+	r := &sequenceReader{runs: [][]model.MetricSnapshot{{{QueryID: 1, MeanTime: 300}}}}
+	eng := New(&config.Config{Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"}}, r)
+
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if alertCtx.Delta != nil {
+		t.Errorf("Delta = %+v, want nil outside delta mode", alertCtx.Delta)
+	}
+}
+
+func TestAnalyze_EscalatesAtAfterRuns(t *testing.T) {
+	baseline := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 100}, {QueryID: 2, DatabaseName: "app", MeanTime: 100}}
+	critical := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 700}, {QueryID: 2, DatabaseName: "app", MeanTime: 150}}
+	recovered := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 100}}
+	r := &sequenceReader{
+		baseline: baseline,
+		runs:     [][]model.MetricSnapshot{critical, critical, critical, critical, recovered, critical, critical, critical},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20}},
+		Notifier: config.NotifierConfig{Escalation: &config.EscalationConfig{AfterRuns: 3}},
+	}
+	eng := New(cfg, r)
+
+	// Escalates on the 3rd consecutive run only, and again after the streak restarts
+	want := []bool{false, false, true, false, false, false, false, true}
+	for run, escalated := range want {
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		if (alertCtx.Escalation != nil) != escalated {
+			t.Fatalf("run %d: Escalation = %+v, want escalated=%v", run+1, alertCtx.Escalation, escalated)
+		}
+		if !escalated {
+			continue
+		}
+		esc := alertCtx.Escalation
+		if esc.ReportType != "escalation" || esc.ReqID != alertCtx.ReqID {
+			t.Errorf("run %d: escalation header = %q/%q", run+1, esc.ReportType, esc.ReqID)
+		}
+		// The medium regression of query 2 never escalates
+		if len(esc.Regressions) != 1 || esc.Regressions[0].QueryID != 1 || esc.Regressions[0].Severity != "critical" {
+			t.Errorf("run %d: escalated regressions = %+v, want only critical query 1", run+1, esc.Regressions)
+		}
+	}
+}
+
+func TestAnalyze_RegressionWarmup(t *testing.T) {
+	baseline := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 100}}
+	current := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 700}}
+	r := &sequenceReader{
+		baseline: baseline,
+		runs:     [][]model.MetricSnapshot{current, current, current, current},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20, WarmupRuns: 2}},
+	}
+	eng := New(cfg, r)
+
+	// The same 600% regression is info while warming up and critical afterwards
+	want := []string{"info", "info", "critical", "critical"}
+	for run, severity := range want {
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		if len(alertCtx.Regressions) != 1 || alertCtx.Regressions[0].Severity != severity {
+			t.Errorf("run %d: regressions = %+v, want one %s regression", run+1, alertCtx.Regressions, severity)
+		}
+	}
+}
+
+// queryAgeReader reports fixed first-seen times.
+type queryAgeReader struct {
+	sequenceReader
+	firstSeen map[int64]time.Time
+}
+
+func (r *queryAgeReader) GetQueryFirstSeen(ctx context.Context, queryIDs []int64) (map[int64]time.Time, error) {
+	return r.firstSeen, nil
+}
+
+func TestAnalyze_MinQueryAge(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	r := &queryAgeReader{
+		sequenceReader: sequenceReader{
+			baseline: []model.MetricSnapshot{
+				{QueryID: 1, DatabaseName: "app", MeanTime: 100},
+				{QueryID: 2, DatabaseName: "app", MeanTime: 100},
+			},
+			runs: [][]model.MetricSnapshot{{
+				{QueryID: 1, DatabaseName: "app", MeanTime: 700},
+				{QueryID: 2, DatabaseName: "app", MeanTime: 700},
+				{QueryID: 3, DatabaseName: "app", MeanTime: 50},
+			}},
+		},
+		// Query 2 predates the retained history, so the reader leaves it out
+		firstSeen: map[int64]time.Time{
+			1: now.Add(-2 * time.Hour),
+			3: now.Add(-time.Hour),
+		},
+	}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20, MinQueryAge: "24h"}},
+	}
+	eng := New(cfg, r)
+	eng.now = func() time.Time { return now }
+
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	got := make(map[int64]model.RegressionItem)
+	for _, reg := range alertCtx.Regressions {
+		got[reg.QueryID] = reg
+	}
+	if q := got[1]; q.Severity != "high" || !q.FirstSeen.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("young query regression = %s first seen %v, want high (lowered from critical) first seen 2h ago", q.Severity, q.FirstSeen)
+	}
+	if q := got[2]; q.Severity != "critical" || !q.FirstSeen.IsZero() {
+		t.Errorf("query of unknown age = %s first seen %v, want critical with no first-seen time", q.Severity, q.FirstSeen)
+	}
+	if q := got[3]; !q.IsNewQuery || q.Severity != "info" || q.FirstSeen.IsZero() {
+		t.Errorf("new query = %+v, want an info new query with its first-seen time", q)
+	}
+}
+
+func TestLowerSeverity(t *testing.T) {
+	for in, want := range map[string]string{"critical": "high", "medium": "low", "low": "info", "info": "info", "": ""} {
+		if got := lowerSeverity(in); got != want {
+			t.Errorf("lowerSeverity(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAnalyze_EscalationStateFile(t *testing.T) {
+	baseline := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 100}}
+	critical := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 700}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20}},
+		Notifier: config.NotifierConfig{Escalation: &config.EscalationConfig{
+			AfterRuns: 2, StateFile: filepath.Join(t.TempDir(), "escalation.json"),
+		}},
+	}
+
+	// Each engine stands for a process restart; the count survives through the state file
+	for run, escalated := range []bool{false, true} {
+		eng := New(cfg, &sequenceReader{baseline: baseline, runs: [][]model.MetricSnapshot{critical}})
+		alertCtx, err := eng.Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Analyze() error = %v", run+1, err)
+		}
+		if (alertCtx.Escalation != nil) != escalated {
+			t.Errorf("run %d: escalated = %v, want %v", run+1, alertCtx.Escalation != nil, escalated)
+		}
+	}
+}
+
+// waitReader serves synthetic pg_wait_sampling samples.
+type waitReader struct {
+	rangeReader
+	samples []model.WaitEventSample
+}
+
+func (r *waitReader) GetWaitEvents(ctx context.Context, window time.Duration) ([]model.WaitEventSample, error) {
+	return r.samples, nil
+}
+
+func TestDetectWaitEvents(t *testing.T) {
+	current := []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", Query: "UPDATE accounts", TotalTime: 10000},
+		{QueryID: 2, DatabaseName: "app", Query: "SELECT orders", TotalTime: 20000},
+		{QueryID: 3, DatabaseName: "app", Query: "SELECT cheap", TotalTime: 10000},
+		{QueryID: 4, DatabaseName: "app", Query: "SELECT cpu", TotalTime: 10000},
+	}
+	r := &waitReader{samples: []model.WaitEventSample{
+		// query 1: 6s of lock waits out of 10s, mostly on transactionid
+		{QueryID: 1, DatabaseName: "app", EventType: "Lock", Event: "transactionid", Samples: 500},
+		{QueryID: 1, DatabaseName: "app", EventType: "Lock", Event: "tuple", Samples: 100},
+		// query 2: 10s of IO waits out of 20s, exactly at the threshold
+		{QueryID: 2, DatabaseName: "app", EventType: "IO", Event: "DataFileRead", Samples: 1000},
+		// query 3: 1s of lock waits, below the threshold
+		{QueryID: 3, DatabaseName: "app", EventType: "Lock", Event: "relation", Samples: 100},
+		// query 4: only non-blocking waits, which the rule ignores
+		{QueryID: 4, DatabaseName: "app", EventType: "LWLock", Event: "WALWrite", Samples: 900},
+		{QueryID: 4, DatabaseName: "app", EventType: "Client", Event: "ClientRead", Samples: 900},
+	}}
+	cfg := &config.Config{Rules: config.RulesConfig{Waits: config.WaitsRuleConfig{MinPercent: 50, ProfilePeriod: "10ms"}}}
+	eng := New(cfg, r)
+
+	items := eng.detectWaitEvents(context.Background(), current, time.Hour)
+	if len(items) != 2 {
+		t.Fatalf("detectWaitEvents() returned %d items, want 2: %+v", len(items), items)
+	}
+	if got := items[0]; got.QueryID != 1 || got.WaitTime != 6000 || got.WaitPercent != 60 ||
+		got.DominantEventType != "Lock" || got.DominantEvent != "transactionid" {
+		t.Errorf("items[0] = %+v, want query 1 with 6000ms (60%%) mostly Lock:transactionid", got)
+	}
+	if got := items[1]; got.QueryID != 2 || got.WaitPercent != 50 || got.DominantEvent != "DataFileRead" {
+		t.Errorf("items[1] = %+v, want query 2 with 50%% DataFileRead", got)
+	}
+}
+
+func TestDetectWaitEvents_Skipped(t *testing.T) {
+	current := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", TotalTime: 1000}}
+	samples := []model.WaitEventSample{{QueryID: 1, DatabaseName: "app", EventType: "Lock", Event: "tuple", Samples: 100}}
+
+	tests := []struct {
+		name   string
+		cfg    config.WaitsRuleConfig
+		reader MetricsReader
+	}{
+		{"disabled by default", config.WaitsRuleConfig{}, &waitReader{samples: samples}},
+		{"reader without wait sampling", config.WaitsRuleConfig{MinPercent: 10}, &rangeReader{}},
+		{"no samples", config.WaitsRuleConfig{MinPercent: 10}, &waitReader{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Rules: config.RulesConfig{Waits: tt.cfg}}, tt.reader)
+			if items := eng.detectWaitEvents(context.Background(), current, time.Hour); items != nil {
+				t.Errorf("detectWaitEvents() = %+v, want nil", items)
+			}
+		})
+	}
+}
+
+// slowWaitReader returns current metrics and advances the test clock while
+// fetching wait events.
+type slowWaitReader struct {
+	waitReader
+	current []model.MetricSnapshot
+	clock   *time.Time
+	delay   time.Duration
+}
+
+func (r *slowWaitReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return r.current, nil
+}
+
+func (r *slowWaitReader) GetWaitEvents(ctx context.Context, window time.Duration) ([]model.WaitEventSample, error) {
+	*r.clock = r.clock.Add(r.delay)
+	return r.samples, nil
+}
+
+func TestAnalyze_SoftTimeout(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	current := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", TotalTime: 1000, MeanTime: 10, Calls: 100}}
+
+	for _, tt := range []struct {
+		name        string
+		softTimeout string
+		wantSkipped []string
+	}{
+		{"waits run past the soft timeout", "1m", []string{"flapping", "cross_server", "vacuum", "custom", "stale_data"}},
+		{"waits finish in time", "10m", nil},
+		{"no soft timeout", "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := start
+			r := &slowWaitReader{current: current, clock: &clock, delay: 2 * time.Minute}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h", SoftTimeout: tt.softTimeout},
+				Rules: config.RulesConfig{
+					SlowSQL: config.SlowSQLRuleConfig{TopN: 5, RankBy: config.RankByList{"total_time"}},
+					Waits:   config.WaitsRuleConfig{MinPercent: 10},
+				},
+			}
+			eng := New(cfg, r)
+			eng.now = func() time.Time { return clock }
+
+			alert, err := eng.Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if len(alert.TopSlowSQL) != 1 {
+				t.Errorf("TopSlowSQL = %+v, want the query found before the soft timeout", alert.TopSlowSQL)
+			}
+			if tt.wantSkipped == nil {
+				if alert.Truncated != nil {
+					t.Errorf("Truncated = %+v, want nil", alert.Truncated)
+				}
+				return
+			}
+			if alert.Truncated == nil {
+				t.Fatal("Truncated = nil, want the skipped rules")
+			}
+			if got, want := strings.Join(alert.Truncated.SkippedRules, ","), strings.Join(tt.wantSkipped, ","); got != want {
+				t.Errorf("SkippedRules = %s, want %s", got, want)
+			}
+			if note := alert.Truncated.Note(); !strings.Contains(note, "soft timeout (1m0s)") || !strings.Contains(note, "flapping, cross_server") {
+				t.Errorf("Note() = %q, want the soft timeout and skipped rules", note)
+			}
+			if alert.Summary.Empty != "" {
+				t.Errorf("Summary.Empty = %q, want unset for a truncated run", alert.Summary.Empty)
+			}
+		})
+	}
+}
+
+func TestAnalyze_BaselineFileRoundTrip(t *testing.T) {
+	known := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 100, Calls: 10}}
+	regressed := []model.MetricSnapshot{{QueryID: 1, DatabaseName: "app", MeanTime: 300, Calls: 10}}
+	// The rolling baseline has drifted to the regressed state and hides the regression
+	r := &sequenceReader{baseline: regressed, runs: [][]model.MetricSnapshot{known, regressed}}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	captured := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{ThresholdPercent: 20}},
+	}
+
+	eng := New(cfg, r)
+	eng.now = func() time.Time { return captured }
+	snap, err := eng.SaveBaseline(context.Background(), path)
+	if err != nil {
+		t.Fatalf("SaveBaseline() error = %v", err)
+	}
+	if len(snap.Metrics) != 1 || !snap.Window.End.Equal(captured) {
+		t.Errorf("snapshot = %+v, want one query ending at %v", snap, captured)
+	}
+
+	cfg.Rules.Regression.BaselineFile = path
+	eng.now = func() time.Time { return captured.Add(48 * time.Hour) }
+	alertCtx, err := eng.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if len(alertCtx.Regressions) != 1 || alertCtx.Regressions[0].BaselineMeanTime != 100 {
+		t.Errorf("regressions = %+v, want one regression against the pinned 100 ms mean", alertCtx.Regressions)
+	}
+	if !alertCtx.BaselineWindow.End.Equal(captured) {
+		t.Errorf("BaselineWindow = %+v, want the pinned snapshot window", alertCtx.BaselineWindow)
+	}
+}
+
+func TestAnalyze_BaselineFileMissing(t *testing.T) {
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{Regression: config.RegressionRuleConfig{BaselineFile: filepath.Join(t.TempDir(), "missing.json")}},
+	}
+	eng := New(cfg, &sequenceReader{runs: [][]model.MetricSnapshot{nil}})
+	if _, err := eng.Analyze(context.Background()); err == nil || !strings.Contains(err.Error(), "reading baseline file") {
+		t.Errorf("Analyze() error = %v, want a baseline file error", err)
+	}
+}
+
+func TestMeanAndCV(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		wantMean float64
+		wantCV   float64
+	}{
+		{"steady", []float64{10, 10, 10, 10}, 10, 0},
+		{"oscillating", []float64{5, 15, 5, 15}, 10, 0.5},
+		{"fast and very slow", []float64{1, 19, 1, 19}, 10, 0.9},
+		{"zero mean", []float64{0, 0, 0}, 0, 0},
+		{"empty", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mean, cv := meanAndCV(tt.values)
+			if math.Abs(mean-tt.wantMean) > 1e-9 || math.Abs(cv-tt.wantCV) > 1e-9 {
+				t.Errorf("meanAndCV(%v) = %v, %v, want %v, %v", tt.values, mean, cv, tt.wantMean, tt.wantCV)
+			}
+		})
+	}
+}
+
+func TestDetectFlapping(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	r := &rangeReader{metrics: map[time.Time][]model.MetricSnapshot{}}
+	// Query 1 alternates between 5ms and 15ms, query 2 is steady at 10ms, and
+	// query 3 oscillates too but only ran in two windows.
+	for i, mean := range []float64{5, 15, 5, 15} {
+		start := now.Add(-time.Duration(4-i) * time.Hour)
+		r.metrics[start] = []model.MetricSnapshot{
+			{QueryID: 1, DatabaseName: "app", Query: "SELECT flap", TotalTime: mean * 10, Calls: 10},
+			{QueryID: 2, DatabaseName: "app", Query: "SELECT steady", TotalTime: 100, Calls: 10},
+		}
+		if i < 2 {
+			r.metrics[start] = append(r.metrics[start],
+				model.MetricSnapshot{QueryID: 3, DatabaseName: "app", TotalTime: mean * 10, Calls: 10})
+		}
+	}
+	cfg := &config.Config{Rules: config.RulesConfig{Flapping: config.FlappingRuleConfig{MaxCV: 0.4, Windows: 4}}}
+	eng := New(cfg, r)
+
+	items := eng.detectFlapping(context.Background(), now, time.Hour)
+	if len(r.ranges) != 4 || r.ranges[0][0] != now.Add(-4*time.Hour) || r.ranges[3][1] != now {
+		t.Errorf("ranges = %v, want four consecutive hours ending now", r.ranges)
+	}
+	if len(items) != 1 {
+		t.Fatalf("detectFlapping() returned %d items, want 1: %+v", len(items), items)
+	}
+	got := items[0]
+	if got.QueryID != 1 || math.Abs(got.CV-0.5) > 1e-9 || got.MeanTime != 10 {
+		t.Errorf("items[0] = %+v, want query 1 with mean 10ms and CV 0.5", got)
+	}
+	if len(got.Series) != 4 || got.Series[0].MeanTime != 5 || got.Series[1].MeanTime != 15 ||
+		got.Series[0].Window.Start != now.Add(-4*time.Hour) {
+		t.Errorf("series = %+v, want 5, 15, 5, 15 oldest first", got.Series)
+	}
+}
+
+func TestDetectFlapping_Skipped(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		cfg    config.FlappingRuleConfig
+		reader MetricsReader
+	}{
+		{"disabled by default", config.FlappingRuleConfig{Windows: 6}, &rangeReader{}},
+		{"reader without ranges", config.FlappingRuleConfig{MaxCV: 0.5, Windows: 6}, &reader.FixtureReader{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Rules: config.RulesConfig{Flapping: tt.cfg}}, tt.reader)
+			if items := eng.detectFlapping(context.Background(), now, time.Hour); items != nil {
+				t.Errorf("detectFlapping() = %+v, want nil", items)
+			}
+		})
+	}
+}
+
+// slowRangeReader answers every range fetch with the same snapshots after a
+// fixed delay, standing in for a repository round-trip, and records the most
+// fetches it saw in flight at once. It is safe for concurrent use.
+type slowRangeReader struct {
+	rangeReader
+	delay   time.Duration
+	metrics []model.MetricSnapshot
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	fail        map[time.Time]error // keyed by range start
+}
+
+func (r *slowRangeReader) GetMetricsRange(ctx context.Context, start, end time.Time) ([]model.MetricSnapshot, error) {
+	r.mu.Lock()
+	r.inFlight++
+	r.maxInFlight = max(r.maxInFlight, r.inFlight)
+	err := r.fail[start]
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(r.metrics), nil
+}
+
+// multiServerMetrics builds one snapshot per query on each of servers PoWA
+// servers, as a single reader fetch returns them.
+func multiServerMetrics(servers, queries int) []model.MetricSnapshot {
+	out := make([]model.MetricSnapshot, 0, servers*queries)
+	for s := range servers {
+		for q := range queries {
+			out = append(out, model.MetricSnapshot{
+				QueryID: int64(q + 1), ServerName: fmt.Sprintf("srv%d", s), DatabaseName: "app",
+				Query: fmt.Sprintf("SELECT %d", q), TotalTime: float64(10 * (q + 1)), Calls: 10,
+			})
+		}
+	}
+	return out
+}
+
+func TestDetectFlapping_FetchParallelism(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	for _, parallelism := range []int{0, 1, 3, 12} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			r := &slowRangeReader{delay: time.Millisecond, metrics: multiServerMetrics(3, 2)}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{FetchParallelism: parallelism},
+				Rules:    config.RulesConfig{Flapping: config.FlappingRuleConfig{MaxCV: 0.4, Windows: 6}},
+			}
+			New(cfg, r).detectFlapping(context.Background(), now, time.Hour)
+
+			// Never more than the windows themselves, one at a time by default
+			if want := min(max(parallelism, 1), 6); r.maxInFlight != want {
+				t.Errorf("max fetches in flight = %d, want %d", r.maxInFlight, want)
+			}
+		})
+	}
+}
+
+func TestDetectFlapping_ParallelFetchError(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	r := &slowRangeReader{metrics: multiServerMetrics(2, 1), fail: map[time.Time]error{
+		now.Add(-3 * time.Hour): errors.New("canceling statement due to statement timeout"),
+	}}
+	cfg := &config.Config{
+		Analysis: config.AnalysisConfig{FetchParallelism: 4},
+		Rules:    config.RulesConfig{Flapping: config.FlappingRuleConfig{MaxCV: 0.01, Windows: 4}},
+	}
+	if items := New(cfg, r).detectFlapping(context.Background(), now, time.Hour); items != nil {
+		t.Errorf("detectFlapping() = %+v, want the rule skipped when a window fails", items)
+	}
+}
+
+func TestFetchAll_LowestError(t *testing.T) {
+	e := New(&config.Config{Analysis: config.AnalysisConfig{FetchParallelism: 3}}, &rangeReader{})
+	var mu sync.Mutex
+	var fetched []int
+	err := e.fetchAll(5, func(i int) error {
+		mu.Lock()
+		fetched = append(fetched, i)
+		mu.Unlock()
+		if i == 1 || i == 3 {
+			return fmt.Errorf("window %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "window 1" {
+		t.Errorf("fetchAll() error = %v, want the first failing window's", err)
+	}
+	if len(fetched) != 5 {
+		t.Errorf("fetched %d windows, want all 5 started", len(fetched))
+	}
+}
+
+func TestAnalyzeRange_FetchParallelism(t *testing.T) {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	r := &slowRangeReader{delay: time.Millisecond, metrics: multiServerMetrics(2, 3)}
+	cfg := &config.Config{Analysis: config.AnalysisConfig{FetchParallelism: 2}}
+	alert, err := New(cfg, r).AnalyzeRange(context.Background(), day, day.Add(time.Hour), day.Add(-24*time.Hour), day.Add(-23*time.Hour))
+	if err != nil {
+		t.Fatalf("AnalyzeRange() error = %v", err)
+	}
+	if r.maxInFlight != 2 {
+		t.Errorf("max fetches in flight = %d, want current and baseline together", r.maxInFlight)
+	}
+	if alert.Summary.TotalQueriesAnalyzed != 6 {
+		t.Errorf("total queries = %d, want all 6 current snapshots", alert.Summary.TotalQueriesAnalyzed)
+	}
+}
+
+// BenchmarkDetectFlapping_Fetch compares fetching the flapping windows one
+// after another with fetching them in parallel. Each window is one reader call
+// covering every server with a 2ms simulated round-trip; four at a time the
+// twelve windows take three round-trips instead of twelve.
+func BenchmarkDetectFlapping_Fetch(b *testing.B) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	metrics := multiServerMetrics(8, 50)
+	for _, bc := range []struct {
+		name        string
+		parallelism int
+	}{{"serial", 1}, {"parallel", 4}} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := &slowRangeReader{delay: 2 * time.Millisecond, metrics: metrics}
+			cfg := &config.Config{
+				Analysis: config.AnalysisConfig{FetchParallelism: bc.parallelism},
+				Rules:    config.RulesConfig{Flapping: config.FlappingRuleConfig{MaxCV: 0.4, Windows: 12}},
+			}
+			eng := New(cfg, r)
+			for b.Loop() {
+				eng.detectFlapping(context.Background(), now, time.Hour)
+			}
+		})
+	}
+}
+
+func TestVacuumItems(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	old := now.Add(-10 * 24 * time.Hour)
+	tables := []model.TableStats{
+		// 25% dead, recently maintained: flagged for the ratio only
+		{DatabaseName: "app", Schema: "public", Table: "orders", LiveTuples: 30000, DeadTuples: 10000, LastVacuum: recent, LastAnalyze: recent},
+		// 1% dead but not vacuumed for 10 days and never analyzed
+		{DatabaseName: "app", Schema: "public", Table: "events", LiveTuples: 990000, DeadTuples: 10000, LastVacuum: old},
+		// healthy
+		{DatabaseName: "app", Schema: "public", Table: "users", LiveTuples: 100000, DeadTuples: 2000, LastVacuum: recent, LastAnalyze: recent},
+		// bloated but below min_dead_tuples
+		{DatabaseName: "app", Schema: "public", Table: "tiny", LiveTuples: 10, DeadTuples: 90},
+	}
+	cfg := &config.Config{Rules: config.RulesConfig{Vacuum: config.VacuumRuleConfig{
+		MaxDeadRatioPercent: 20, MaxAge: "168h", MinDeadTuples: 1000,
+	}}}
+	eng := New(cfg, &rangeReader{})
+
+	items := eng.vacuumItems(tables, now)
+	if len(items) != 2 {
+		t.Fatalf("vacuumItems() returned %d items, want 2: %+v", len(items), items)
+	}
+	if got := items[0]; got.Table != "orders" || got.DeadRatioPercent != 25 ||
+		strings.Join(got.Reasons, ",") != "dead_tuples" {
+		t.Errorf("items[0] = %+v, want orders at 25%% for dead_tuples", got)
+	}
+	if got := items[1]; got.Table != "events" || got.DeadRatioPercent != 1 ||
+		strings.Join(got.Reasons, ",") != "vacuum_age,analyze_age" {
+		t.Errorf("items[1] = %+v, want events at 1%% for vacuum_age,analyze_age", got)
+	}
+}
+
+type vacuumReader struct {
+	rangeReader
+	tables []model.TableStats
+}
+
+func (r *vacuumReader) GetVacuumCandidates(ctx context.Context) ([]model.TableStats, error) {
+	return r.tables, nil
+}
+
+func TestDetectVacuum_Skipped(t *testing.T) {
+	tables := []model.TableStats{{DatabaseName: "app", Table: "orders", LiveTuples: 1000, DeadTuples: 9000}}
+	tests := []struct {
+		name   string
+		cfg    config.VacuumRuleConfig
+		reader MetricsReader
+	}{
+		{"disabled by default", config.VacuumRuleConfig{MinDeadTuples: 1000}, &vacuumReader{tables: tables}},
+		{"reader without table statistics", config.VacuumRuleConfig{MaxDeadRatioPercent: 20}, &rangeReader{}},
+		{"no snapshots", config.VacuumRuleConfig{MaxDeadRatioPercent: 20}, &vacuumReader{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Rules: config.RulesConfig{Vacuum: tt.cfg}}, tt.reader)
+			if items := eng.detectVacuum(context.Background(), time.Now()); items != nil {
+				t.Errorf("detectVacuum() = %+v, want nil", items)
+			}
+		})
+	}
+}
+
+func TestNeedsKCache(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want bool
+	}{
+		{"slow SQL by total time", config.Config{Rules: config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"total_time", "mean_time:5"}}}}, false},
+		{"slow SQL by cpu time", config.Config{Rules: config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"total_time", "cpu_time:5"}}}}, true},
+		{"slow SQL by io time", config.Config{Rules: config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 10, RankBy: config.RankByList{"io_time"}}}}, true},
+		{"detailed console", config.Config{Notifier: config.NotifierConfig{Type: "console", Verbosity: config.VerbosityDetailed}}, true},
+		{"kafka findings", config.Config{Notifier: config.NotifierConfig{Type: "kafka", Kafka: config.KafkaConfig{Message: config.KafkaMessageFinding}}}, false},
+		{"kafka alerts", config.Config{Notifier: config.NotifierConfig{Type: "kafka", Kafka: config.KafkaConfig{Message: config.KafkaMessageAlert}}}, true},
+		{"raw metrics", config.Config{Notifier: config.NotifierConfig{Type: "ndjson", IncludeRawMetrics: true}}, true},
+		{"detailed route", config.Config{Notifier: config.NotifierConfig{Type: "console", Routes: map[string]*config.NotifierConfig{
+			"critical": {Type: "wecom", Verbosity: config.VerbosityDetailed},
+		}}}, true},
+		{"sns escalation", config.Config{Notifier: config.NotifierConfig{Type: "console", Escalation: &config.EscalationConfig{
+			NotifierConfig: config.NotifierConfig{Type: "sns"},
+		}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsKCache(&tt.cfg); got != tt.want {
+				t.Errorf("NeedsKCache() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
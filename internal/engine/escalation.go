@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// trackEscalation counts the consecutive runs each critical finding has been
+// seen and returns an alert holding the findings whose count just reached
+// notifier.escalation.after_runs, or nil when none did. A finding escalates
+// once per streak: it must disappear for a run before it can escalate again.
+func (e *Engine) trackEscalation(alertCtx *model.AlertContext) *model.AlertContext {
+	esc := e.cfg.Notifier.Escalation
+
+	e.mu.Lock()
+	next := make(map[string]int)
+	escalate := make(map[string]bool)
+	for _, ref := range findingRefs(alertCtx) {
+		if ref.Severity != "critical" {
+			continue
+		}
+		key := ref.Key()
+		if _, dup := next[key]; dup {
+			continue
+		}
+		next[key] = e.criticalRuns[key] + 1
+		if next[key] == esc.AfterRuns {
+			escalate[key] = true
+		}
+	}
+	e.criticalRuns = next
+	e.mu.Unlock()
+
+	if esc.StateFile != "" {
+		if err := saveEscalationState(esc.StateFile, next); err != nil {
+			log.Printf("Warning: failed to save escalation state: %v", err)
+		}
+	}
+	if len(escalate) == 0 {
+		return nil
+	}
+	return escalationAlert(alertCtx, escalate)
+}
+
+// escalationAlert copies the alert header and keeps only the findings whose
+// FindingRef key is in keys.
+func escalationAlert(alertCtx *model.AlertContext, keys map[string]bool) *model.AlertContext {
+	out := &model.AlertContext{
+		ReqID:           alertCtx.ReqID,
+		ReportType:      "escalation",
+		Timestamp:       alertCtx.Timestamp,
+		AnalysisWindow:  alertCtx.AnalysisWindow,
+		BaselineWindow:  alertCtx.BaselineWindow,
+		DatabaseName:    alertCtx.DatabaseName,
+		Repository:      alertCtx.Repository,
+		Summary:         alertCtx.Summary,
+		Labels:          alertCtx.Labels,
+		DisplayLocation: alertCtx.DisplayLocation,
+	}
+	if sd := alertCtx.StaleData; sd != nil && keys[staleDataRef(sd).Key()] {
+		out.StaleData = sd
+	}
+	for _, r := range alertCtx.Regressions {
+		if keys[regressionRef(r).Key()] {
+			out.Regressions = append(out.Regressions, r)
+		}
+	}
+	for _, f := range alertCtx.CustomFindings {
+		if keys[customRef(f).Key()] {
+			out.CustomFindings = append(out.CustomFindings, f)
+		}
+	}
+	return out
+}
+
+// loadEscalationState reads the run counts saved by saveEscalationState. A
+// missing file is not an error: the counts start from zero.
+func loadEscalationState(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs map[string]int
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return runs, nil
+}
+
+// saveEscalationState writes the run counts to path.
+func saveEscalationState(path string, runs map[string]int) error {
+	data, err := json.Marshal(runs)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data through a temporary file so a crash never leaves
+// a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
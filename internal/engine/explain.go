@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// ExplainReader is implemented by monitored-instance readers that can plan a
+// query. Slow SQL findings carry no plan without it.
+type ExplainReader interface {
+	Explain(ctx context.Context, database, query string) (string, error)
+}
+
+var _ ExplainReader = (*reader.Reader)(nil)
+
+// applyExplain attaches the EXPLAIN plan of the first
+// rules.slow_sql.max_explains slow queries, worst first, when
+// rules.slow_sql.include_explain is set and the monitored instance can plan
+// queries. Truncated or unavailable query text cannot be planned and does not
+// count against the cap, and neither is anything planned when query text is
+// redacted, since a plan repeats the constants redaction hides. Each plan is
+// bounded by rules.slow_sql.explain_timeout; errors are logged and skipped
+// like other optional checks.
+func (e *Engine) applyExplain(ctx context.Context, slow []model.MetricSnapshot) {
+	rule := e.cfg.Rules.SlowSQL
+	if !rule.IncludeExplain || len(slow) == 0 {
+		return
+	}
+	er, ok := e.instance.(ExplainReader)
+	if !ok {
+		return
+	}
+	if e.redactor.literals || len(e.redactor.patterns) > 0 {
+		log.Print("Skipping rules.slow_sql.include_explain: query text is redacted")
+		return
+	}
+
+	timeout, _ := rule.ExplainTimeoutParsed()
+	explained := 0
+	for i := range slow {
+		if explained >= rule.MaxExplains {
+			return
+		}
+		q := &slow[i]
+		if q.QueryHash != "" || model.IsQueryTextUnavailable(q.Query) {
+			continue
+		}
+		explained++
+		plan, err := e.explain(ctx, er, q.DatabaseName, q.Query, timeout)
+		if err != nil {
+			log.Printf("Warning: failed to explain query %d: %v", q.QueryID, err)
+			continue
+		}
+		q.Plan = plan
+	}
+}
+
+// explain plans one query, giving up after timeout when it is positive.
+func (e *Engine) explain(ctx context.Context, er ExplainReader, database, query string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return er.Explain(ctx, database, query)
+}
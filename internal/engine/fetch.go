@@ -0,0 +1,41 @@
+package engine
+
+import "sync"
+
+// fetchAll runs fetch for each of n independent metric windows, with up to
+// analysis.fetch_parallelism of them in flight; 0 or 1 runs them one after
+// another. Each fetch covers every PoWA server in one query already, so this
+// only overlaps windows (flapping windows, the current and baseline range):
+// the reader's database.max_concurrent_queries limiter still bounds the calls
+// that reach the repository. It returns the error of the lowest failing
+// window, after every started fetch has returned.
+func (e *Engine) fetchAll(n int, fetch func(i int) error) error {
+	parallelism := min(max(e.cfg.Analysis.FetchParallelism, 1), n)
+	errs := make([]error, n)
+	if parallelism <= 1 {
+		for i := range n {
+			if errs[i] = fetch(i); errs[i] != nil {
+				return errs[i]
+			}
+		}
+		return nil
+	}
+
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range n {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			errs[i] = fetch(i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// minFlappingPoints is the number of windows a query must have run in before
+// its variation means anything.
+const minFlappingPoints = 3
+
+// detectFlapping fetches the last rules.flapping.windows windows of length
+// window, ending at now, and reports queries whose mean time oscillates across
+// them. It returns nil when the rule is disabled or the reader cannot fetch
+// explicit ranges; a window that fails to load skips the rule for this run.
+func (e *Engine) detectFlapping(ctx context.Context, now time.Time, window time.Duration) []model.FlappingItem {
+	cfg := e.cfg.Rules.Flapping
+	enabled := func(r *config.RulesConfig) bool { return r.Flapping.MaxCV > 0 }
+	if window <= 0 || !e.cfg.AnyRules(enabled) {
+		return nil
+	}
+	rr, ok := e.reader.(RangeReader)
+	if !ok {
+		return nil
+	}
+
+	n := cfg.Windows
+	if n > config.MaxFlappingWindows {
+		n = config.MaxFlappingWindows
+	}
+	windows := make([]model.TimeWindow, n)
+	metrics := make([][]model.MetricSnapshot, n)
+	for i := range windows {
+		// Oldest first
+		end := now.Add(-time.Duration(n-1-i) * window)
+		windows[i] = model.TimeWindow{Start: end.Add(-window), End: end}
+	}
+	err := e.fetchAll(n, func(i int) error {
+		m, err := rr.GetMetricsRange(ctx, windows[i].Start, windows[i].End)
+		if err != nil {
+			return fmt.Errorf("fetching window %d of %d: %w", i+1, n, err)
+		}
+		m = e.allowlist.filterMetrics(m)
+		e.redactor.redactMetrics(m)
+		metrics[i] = m
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: skipping flapping rule: %v", err)
+		return nil
+	}
+	return e.flappingItems(windows, metrics)
+}
+
+// flappingItems builds each query's mean time series over windows and keeps
+// the queries whose coefficient of variation reaches rules.flapping.max_cv.
+// max_cv and min_calls are resolved per database.
+func (e *Engine) flappingItems(windows []model.TimeWindow, metrics [][]model.MetricSnapshot) []model.FlappingItem {
+	type comparisonKey struct {
+		queryID int64
+		server  string
+		db      string
+	}
+	items := make(map[comparisonKey]*model.FlappingItem)
+	var order []comparisonKey
+	for i, window := range metrics {
+		// A query can have one row per user; a window's point covers all of them
+		type totals struct {
+			snapshot  model.MetricSnapshot
+			totalTime float64
+			calls     int64
+		}
+		perQuery := make(map[comparisonKey]*totals)
+		var windowOrder []comparisonKey
+		for _, m := range window {
+			k := comparisonKey{m.QueryID, m.ServerName, m.DatabaseName}
+			if t, ok := perQuery[k]; ok {
+				t.totalTime += m.TotalTime
+				t.calls += m.Calls
+				continue
+			}
+			perQuery[k] = &totals{snapshot: m, totalTime: m.TotalTime, calls: m.Calls}
+			windowOrder = append(windowOrder, k)
+		}
+
+		for _, k := range windowOrder {
+			t := perQuery[k]
+			if t.calls <= 0 || t.calls < e.cfg.RulesFor(k.db).Flapping.MinCalls {
+				continue
+			}
+			item, ok := items[k]
+			if !ok {
+				item = &model.FlappingItem{
+					QueryID:      t.snapshot.QueryID,
+					DatabaseName: t.snapshot.DatabaseName,
+					ServerName:   t.snapshot.ServerName,
+					SrvID:        t.snapshot.SrvID,
+				}
+				items[k] = item
+				order = append(order, k)
+			}
+			// Keep the newest query text
+			item.Query = t.snapshot.Query
+			item.Series = append(item.Series, model.FlappingPoint{
+				Window:   windows[i],
+				MeanTime: t.totalTime / float64(t.calls),
+				Calls:    t.calls,
+			})
+		}
+	}
+
+	var flapping []model.FlappingItem
+	for _, k := range order {
+		item := items[k]
+		if len(item.Series) < minFlappingPoints {
+			continue
+		}
+		values := make([]float64, len(item.Series))
+		for i, p := range item.Series {
+			values[i] = p.MeanTime
+		}
+		item.MeanTime, item.CV = meanAndCV(values)
+		if maxCV := e.cfg.RulesFor(k.db).Flapping.MaxCV; maxCV > 0 && item.CV >= maxCV {
+			flapping = append(flapping, *item)
+		}
+	}
+
+	sort.Slice(flapping, func(i, j int) bool {
+		if flapping[i].CV != flapping[j].CV {
+			return flapping[i].CV > flapping[j].CV
+		}
+		return flapping[i].MeanTime > flapping[j].MeanTime
+	})
+	return flapping
+}
+
+// meanAndCV returns the mean of values and their coefficient of variation
+// (population standard deviation divided by the mean), or a CV of 0 when the
+// mean is not positive.
+func meanAndCV(values []float64) (mean, cv float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if mean <= 0 {
+		return mean, 0
+	}
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	return mean, math.Sqrt(variance) / mean
+}
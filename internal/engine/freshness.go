@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// FreshnessReader is implemented by readers that can report when PoWA last
+// recorded a snapshot. The stale data check is skipped for readers without it.
+type FreshnessReader interface {
+	LatestSnapshotTime(ctx context.Context) (time.Time, error)
+}
+
+var _ FreshnessReader = (*reader.Reader)(nil)
+
+// checkDataFreshness returns a high-severity finding when the newest snapshot is
+// older than analysis.max_data_age, or nil when the data is fresh or the check is
+// disabled. Lookup errors are logged and skipped like other optional checks.
+func (e *Engine) checkDataFreshness(ctx context.Context, now time.Time) *model.StaleDataFinding {
+	maxAge, err := e.cfg.Analysis.MaxDataAgeParsed()
+	if err != nil || maxAge <= 0 {
+		return nil
+	}
+	fr, ok := e.reader.(FreshnessReader)
+	if !ok {
+		return nil
+	}
+
+	latest, err := fr.LatestSnapshotTime(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to check data freshness: %v", err)
+		return nil
+	}
+	return staleDataFinding(latest, now, maxAge)
+}
+
+// staleDataFinding compares the newest snapshot against maxAge at now.
+// An empty history (zero latest) is always stale.
+func staleDataFinding(latest, now time.Time, maxAge time.Duration) *model.StaleDataFinding {
+	var age time.Duration
+	if !latest.IsZero() {
+		age = now.Sub(latest)
+		if age <= maxAge {
+			return nil
+		}
+	}
+	return &model.StaleDataFinding{
+		Severity:       "high",
+		LatestSnapshot: latest.UTC(),
+		Age:            age.Round(time.Second),
+		MaxAge:         maxAge,
+	}
+}
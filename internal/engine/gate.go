@@ -0,0 +1,44 @@
+package engine
+
+import "github.com/powa-team/powa-sentinel/internal/model"
+
+// ValidSeverity reports whether s is a finding severity HasFindings understands.
+func ValidSeverity(s string) bool {
+	return model.SeverityRank(s) > 0
+}
+
+// HasFindings reports whether the alert contains a finding at or above
+// minSeverity, for release gates around --once runs. An empty minSeverity
+// matches any finding, including those without a severity (call spikes, wait
+// events, flapping queries, cross-server slowdowns, workload growth,
+// connection saturation, vacuum candidates, index suggestions). The slow SQL
+// ranking lists the top queries of every run and is never treated as a
+// finding.
+func HasFindings(alert *model.AlertContext, minSeverity string) bool {
+	if alert == nil {
+		return false
+	}
+	if minSeverity == "" {
+		return len(alert.Regressions) > 0 || len(alert.CallSpikes) > 0 || len(alert.WaitEvents) > 0 ||
+			len(alert.Flapping) > 0 || len(alert.CrossServer) > 0 || len(alert.Vacuum) > 0 ||
+			len(alert.Suggestions) > 0 || len(alert.CustomFindings) > 0 || alert.StaleData != nil ||
+			alert.WorkloadGrowth != nil || alert.ConnectionSaturation != nil
+	}
+
+	threshold := model.SeverityRank(minSeverity)
+	meets := func(severity string) bool {
+		rank := model.SeverityRank(severity)
+		return rank > 0 && rank >= threshold
+	}
+	for _, r := range alert.Regressions {
+		if meets(r.Severity) {
+			return true
+		}
+	}
+	for _, f := range alert.CustomFindings {
+		if meets(f.Severity) {
+			return true
+		}
+	}
+	return alert.StaleData != nil && meets(alert.StaleData.Severity)
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// NeedsKCache reports whether anything cfg enables consumes the pg_stat_kcache
+// CPU and block I/O metrics: a cpu_time or io_time slow SQL ranking, the
+// detailed verbosity of text notifiers, which prints them, or a JSON payload
+// that embeds the metrics (include_raw_metrics, sns, kafka alert messages).
+// Otherwise readers can skip the kcache enrichment query of every window.
+func NeedsKCache(cfg *config.Config) bool {
+	for _, r := range cfg.Rules.SlowSQL.Rankings() {
+		if r.Metric == "cpu_time" || r.Metric == "io_time" {
+			return true
+		}
+	}
+	if cfg.Notifier.IncludeRawMetrics {
+		return true
+	}
+	notifiers := []*config.NotifierConfig{&cfg.Notifier}
+	if esc := cfg.Notifier.Escalation; esc != nil {
+		notifiers = append(notifiers, &esc.NotifierConfig)
+	}
+	for _, route := range cfg.Notifier.Routes {
+		if route != nil {
+			notifiers = append(notifiers, route)
+		}
+	}
+	return slices.ContainsFunc(notifiers, showsKCache)
+}
+
+// showsKCache reports whether the notifier of n renders or embeds the
+// pg_stat_kcache metrics of slow SQL findings.
+func showsKCache(n *config.NotifierConfig) bool {
+	switch {
+	case n.Verbosity == config.VerbosityDetailed:
+		return true
+	case n.Type == "sns":
+		return true
+	case n.Type == "kafka":
+		return n.Kafka.Message == config.KafkaMessageAlert
+	}
+	return false
+}
+
+// NeedsStddev reports whether anything cfg enables consumes the execution
+// time standard deviation: rules.regression.metric tail.
+func NeedsStddev(cfg *config.Config) bool {
+	return cfg.Rules.Regression.Metric == config.RegressionMetricTail
+}
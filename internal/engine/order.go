@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// findingKey is what a finding is ordered by within its alert section: higher
+// severity first, then the rule's scores (highest first, in order), then the
+// query id and identity fields ascending. The identity fields make the order
+// total, so findings with equal scores never fall back on repository row order.
+type findingKey struct {
+	severity int
+	scores   []float64
+	queryID  int64
+	ident    []string
+}
+
+// before reports whether k sorts before o.
+func (k findingKey) before(o findingKey) bool {
+	if k.severity != o.severity {
+		return k.severity > o.severity
+	}
+	for i := 0; i < len(k.scores) && i < len(o.scores); i++ {
+		if k.scores[i] != o.scores[i] {
+			return k.scores[i] > o.scores[i]
+		}
+	}
+	if k.queryID != o.queryID {
+		return k.queryID < o.queryID
+	}
+	for i := 0; i < len(k.ident) && i < len(o.ident); i++ {
+		if k.ident[i] != o.ident[i] {
+			return k.ident[i] < o.ident[i]
+		}
+	}
+	return false
+}
+
+// sortFindings stably sorts items by key, computing each key once.
+func sortFindings[T any](items []T, key func(*T) findingKey) {
+	if len(items) < 2 {
+		return
+	}
+	keys := make([]findingKey, len(items))
+	order := make([]int, len(items))
+	for i := range items {
+		keys[i] = key(&items[i])
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]].before(keys[order[b]])
+	})
+	sorted := make([]T, len(items))
+	for i, j := range order {
+		sorted[i] = items[j]
+	}
+	copy(items, sorted)
+}
+
+// orderFindings gives every finding section of the alert a total order, so
+// repeated runs on identical data produce identical alerts whatever order the
+// repository returned rows in. Each section keeps its rule's ranking; this
+// only settles ties. The slow SQL ranking is already ordered by sortMetrics,
+// and custom findings keep their configured rule order and the row order of
+// each rule's query.
+func orderFindings(alertCtx *model.AlertContext) {
+	sortFindings(alertCtx.Regressions, func(r *model.RegressionItem) findingKey {
+		// New queries follow the regressions of the same severity, slowest first
+		scores := []float64{1, r.ChangePercent, r.CurrentMeanTime}
+		if r.IsNewQuery {
+			scores = []float64{0, r.CurrentMeanTime}
+		}
+		return findingKey{model.SeverityRank(r.Severity), scores, r.QueryID, []string{r.ServerName, r.DatabaseName, r.Query}}
+	})
+	sortFindings(alertCtx.Suggestions, func(s *model.IndexSuggestion) findingKey {
+		return findingKey{0, []float64{s.EstImprovementPercent, float64(s.AffectedQueries)}, 0,
+			[]string{s.FullTableName(), strings.Join(s.Columns, ","), s.AccessType}}
+	})
+	sortFindings(alertCtx.CallSpikes, func(c *model.CallSpikeItem) findingKey {
+		return findingKey{0, []float64{c.ChangePercent, float64(c.CurrentCalls)}, c.QueryID, []string{c.ServerName, c.DatabaseName, c.Query}}
+	})
+	sortFindings(alertCtx.WaitEvents, func(w *model.WaitEventItem) findingKey {
+		return findingKey{0, []float64{w.WaitPercent, w.WaitTime}, w.QueryID, []string{w.ServerName, w.DatabaseName, w.Query}}
+	})
+	sortFindings(alertCtx.Flapping, func(f *model.FlappingItem) findingKey {
+		return findingKey{0, []float64{f.CV, f.MeanTime}, f.QueryID, []string{f.ServerName, f.DatabaseName, f.Query}}
+	})
+	sortFindings(alertCtx.CrossServer, func(c *model.CrossServerItem) findingKey {
+		return findingKey{0, []float64{c.ChangePercent, c.MeanTime}, c.QueryID, []string{c.ServerName, c.DatabaseName, c.Query}}
+	})
+	sortFindings(alertCtx.Vacuum, func(v *model.VacuumItem) findingKey {
+		return findingKey{0, []float64{v.DeadRatioPercent, float64(v.DeadTuples)}, 0, []string{v.DatabaseName, v.FullTableName()}}
+	})
+}
+
+// slowSQLKey orders query metrics by rankBy, then by total time and calls.
+func slowSQLKey(rankBy string) func(m *model.MetricSnapshot) findingKey {
+	return func(m *model.MetricSnapshot) findingKey {
+		var score float64
+		switch rankBy {
+		case "mean_time":
+			score = m.MeanTime
+		case "cpu_time":
+			score = m.TotalCPUTime()
+		case "io_time":
+			score = m.IOTime()
+		default: // total_time
+			score = m.TotalTime
+		}
+		return findingKey{0, []float64{score, m.TotalTime, float64(m.Calls)}, m.QueryID, []string{m.ServerName, m.DatabaseName, m.Query}}
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// DataProbeReader is implemented by readers that can cheaply tell whether the
+// analysis window holds any statement snapshots. Analyze skips its queries
+// when it does not; readers without it always run the full analysis.
+type DataProbeReader interface {
+	HasDataInWindow(ctx context.Context, window time.Duration) (bool, error)
+}
+
+var _ DataProbeReader = (*reader.Reader)(nil)
+
+// hasData reports whether the analysis window may hold statements. A failed
+// probe is logged and counts as data, so the full analysis runs and reports
+// the actual error.
+func (e *Engine) hasData(ctx context.Context, window time.Duration) bool {
+	pr, ok := e.reader.(DataProbeReader)
+	if !ok {
+		return true
+	}
+	has, err := pr.HasDataInWindow(ctx, window)
+	if err != nil {
+		log.Printf("Warning: failed to probe the analysis window for data: %v", err)
+		return true
+	}
+	return has
+}
+
+// noDataAlert returns the alert of a run whose window holds no statements,
+// without fetching metrics. Only the rules that do not read statements run:
+// connections, vacuum, custom rules and the stale data check, which explains
+// the empty window. Delta, escalation and recovery are not tracked, as a
+// window without data says nothing about whether findings cleared, and the
+// run does not count towards the regression warmup.
+func (e *Engine) noDataAlert(ctx context.Context, rules *ruleRunner, now time.Time, analysisWindow, baselineWindow model.TimeWindow) *model.AlertContext {
+	alertCtx := &model.AlertContext{
+		ReqID:           generateReqID(),
+		ReportType:      "scheduled",
+		Timestamp:       now,
+		AnalysisWindow:  analysisWindow,
+		BaselineWindow:  baselineWindow,
+		Repository:      e.repository,
+		DisplayLocation: e.cfg.Analysis.DisplayLocation,
+	}
+	rules.run("connections", func() { alertCtx.ConnectionSaturation = e.checkConnections(ctx) })
+	rules.run("vacuum", func() { alertCtx.Vacuum = e.detectVacuum(ctx, now) })
+	rules.run("custom", func() { alertCtx.CustomFindings = e.evaluateCustomRules(ctx) })
+	rules.run("stale_data", func() { alertCtx.StaleData = e.checkDataFreshness(ctx, now) })
+	alertCtx.Truncated = rules.truncated()
+	if alertCtx.Truncated != nil {
+		log.Print(alertCtx.Truncated.Note())
+	}
+	orderFindings(alertCtx)
+	e.applyLabels(alertCtx)
+	e.applyCooldowns(alertCtx, now)
+	alertCtx.Summary = e.generateSummary(alertCtx, 0)
+
+	// The next since_last_run window starts here, but the warmup needs analyzed runs
+	e.mu.Lock()
+	e.lastRunEnd = now
+	e.mu.Unlock()
+	return alertCtx
+}
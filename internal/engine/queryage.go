@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// QueryAgeReader is implemented by readers that can report when each query was
+// first snapshotted. Regressions carry no query age for readers without it.
+type QueryAgeReader interface {
+	GetQueryFirstSeen(ctx context.Context, queryIDs []int64) (map[int64]time.Time, error)
+}
+
+var _ QueryAgeReader = (*reader.Reader)(nil)
+
+// severityLadder lists finding severities from least to most severe.
+var severityLadder = []string{"info", "low", "medium", "high", "critical"}
+
+// applyQueryAge sets each regression's first-seen time and, with
+// rules.regression.min_query_age, lowers the severity of regressions of
+// queries first seen less than that before at by one level: a query that has
+// only just appeared has little history to regress from. Lookup errors are
+// logged and skipped like other optional checks.
+func (e *Engine) applyQueryAge(ctx context.Context, regressions []model.RegressionItem, at time.Time) {
+	qr, ok := e.reader.(QueryAgeReader)
+	if !ok || len(regressions) == 0 {
+		return
+	}
+
+	queryIDs := make([]int64, 0, len(regressions))
+	for _, r := range regressions {
+		queryIDs = append(queryIDs, r.QueryID)
+	}
+	firstSeen, err := qr.GetQueryFirstSeen(ctx, queryIDs)
+	if err != nil {
+		log.Printf("Warning: failed to fetch query first-seen times: %v", err)
+		return
+	}
+
+	minAge, _ := e.cfg.Rules.Regression.MinQueryAgeParsed()
+	for i := range regressions {
+		r := &regressions[i]
+		seen, ok := firstSeen[r.QueryID]
+		if !ok {
+			continue
+		}
+		r.FirstSeen = seen.UTC()
+		if minAge > 0 && !r.IsNewQuery && at.Sub(seen) < minAge {
+			lowered := lowerSeverity(r.Severity)
+			log.Printf("Query %d was first seen %s ago, under rules.regression.min_query_age %s: lowering its regression from %s to %s",
+				r.QueryID, at.Sub(seen).Round(time.Minute), minAge, r.Severity, lowered)
+			r.Severity = lowered
+		}
+	}
+}
+
+// lowerSeverity returns the severity one level below s; info stays info.
+func lowerSeverity(s string) string {
+	if rank := model.SeverityRank(s); rank > 1 {
+		return severityLadder[rank-2]
+	}
+	return s
+}
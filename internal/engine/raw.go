@@ -0,0 +1,20 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// rawMetrics returns the rows an alert embeds with notifier.include_raw_metrics:
+// a copy of metrics, worst total time first, capped at
+// notifier.raw_metrics_limit, or nil when the option is off.
+func (e *Engine) rawMetrics(metrics []model.MetricSnapshot) []model.MetricSnapshot {
+	if !e.cfg.Notifier.IncludeRawMetrics || len(metrics) == 0 {
+		return nil
+	}
+	raw := slices.Clone(metrics)
+	slices.SortStableFunc(raw, func(a, b model.MetricSnapshot) int { return cmp.Compare(b.TotalTime, a.TotalTime) })
+	return raw[:min(len(raw), e.cfg.Notifier.RawMetricsCap())]
+}
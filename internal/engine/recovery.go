@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// trackRecovery remembers the firing findings at or above
+// notifier.recovery_severity and returns an alert, with the header of
+// alertCtx, listing those of the previous run that no longer fire, or nil when
+// none cleared. firing holds the findings before silences and cooldowns, which
+// hide a finding without clearing it. A cleared finding is forgotten, so it
+// recovers once; it must fire again before it can recover again.
+func (e *Engine) trackRecovery(alertCtx *model.AlertContext, firing []model.FindingRef) *model.AlertContext {
+	threshold := model.SeverityRank(e.cfg.Notifier.RecoverySeverity)
+
+	e.mu.Lock()
+	next := make(map[string]model.FindingRef)
+	for _, ref := range firing {
+		if rank := model.SeverityRank(ref.Severity); rank > 0 && rank >= threshold {
+			next[ref.Key()] = ref
+		}
+	}
+	var recovered []model.FindingRef
+	for key, ref := range e.recoverable {
+		if _, ok := next[key]; !ok {
+			recovered = append(recovered, ref)
+		}
+	}
+	e.recoverable = next
+	e.mu.Unlock()
+
+	if len(recovered) == 0 {
+		return nil
+	}
+	sort.Slice(recovered, func(i, j int) bool { return recovered[i].Key() < recovered[j].Key() })
+	return &model.AlertContext{
+		ReqID:           alertCtx.ReqID,
+		ReportType:      "recovery",
+		Timestamp:       alertCtx.Timestamp,
+		AnalysisWindow:  alertCtx.AnalysisWindow,
+		BaselineWindow:  alertCtx.BaselineWindow,
+		DatabaseName:    alertCtx.DatabaseName,
+		Repository:      alertCtx.Repository,
+		Delta:           &model.FindingDelta{Resolved: recovered},
+		Summary:         model.AlertSummary{HealthScore: alertCtx.Summary.HealthScore, HealthStatus: alertCtx.Summary.HealthStatus},
+		Labels:          alertCtx.Labels,
+		DisplayLocation: alertCtx.DisplayLocation,
+	}
+}
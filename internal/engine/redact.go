@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// redactedPlaceholder replaces any sensitive fragment of query text.
+const redactedPlaceholder = "***"
+
+// literalPattern matches single-quoted string literals (including doubled-quote
+// escapes) and standalone numeric literals that survive pg_stat_statements
+// normalization, as well as a string literal left open by truncation. The
+// leading group keeps the preceding character so $N placeholders and
+// identifiers such as t1 are left untouched.
+var literalPattern = regexp.MustCompile(`(^|[^$\w])(?:'(?:[^']|'')*(?:'|$)|\d+(?:\.\d+)?\b)`)
+
+// queryRedactor masks and truncates query text according to AnalysisConfig.
+type queryRedactor struct {
+	literals  bool
+	patterns  []*regexp.Regexp
+	maxLength int
+}
+
+// newQueryRedactor compiles the configured patterns. Invalid patterns are
+// rejected by config.Validate; any that slip through are logged and skipped.
+func newQueryRedactor(cfg *config.AnalysisConfig) *queryRedactor {
+	r := &queryRedactor{
+		literals:  cfg.RedactQueries,
+		maxLength: cfg.MaxQueryLength,
+	}
+	for _, p := range cfg.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Printf("Warning: skipping invalid redact pattern %q: %v", p, err)
+			continue
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r
+}
+
+// enabled reports whether the redactor would change any query text.
+func (r *queryRedactor) enabled() bool {
+	return r.literals || len(r.patterns) > 0 || r.maxLength > 0
+}
+
+// apply returns the redacted query text. A truncation marker is kept as is.
+func (r *queryRedactor) apply(query string) string {
+	if model.IsQueryTextUnavailable(query) {
+		return query // no text to redact, and the queryid is not a literal
+	}
+	query, truncated := strings.CutSuffix(query, model.TruncatedQueryMarker)
+	for _, re := range r.patterns {
+		query = re.ReplaceAllString(query, redactedPlaceholder)
+	}
+	if r.literals {
+		query = literalPattern.ReplaceAllString(query, "${1}"+redactedPlaceholder)
+	}
+	if truncated {
+		query += model.TruncatedQueryMarker
+	}
+	return query
+}
+
+// redactMetrics truncates and then redacts the query text of every snapshot
+// in place. The database reader already truncates as it reads; this covers
+// the other readers (fixtures, baseline files).
+func (r *queryRedactor) redactMetrics(metrics []model.MetricSnapshot) {
+	if !r.enabled() {
+		return
+	}
+	for i := range metrics {
+		metrics[i].TruncateQuery(r.maxLength)
+		metrics[i].Query = r.apply(metrics[i].Query)
+	}
+}
+
+// redactSuggestions masks the example constants of index suggestions, which
+// are literals just like those in query text.
+func (r *queryRedactor) redactSuggestions(suggestions []model.IndexSuggestion) {
+	if !r.literals && len(r.patterns) == 0 {
+		return
+	}
+	for i := range suggestions {
+		for j := range suggestions[i].Examples {
+			values := suggestions[i].Examples[j].Values
+			for k, v := range values {
+				for _, re := range r.patterns {
+					v = re.ReplaceAllString(v, redactedPlaceholder)
+				}
+				if r.literals {
+					v = redactedPlaceholder
+				}
+				values[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// Finding kinds in tie-break order: when scores are equal, regressions rank
+// before slow queries, which rank before index suggestions.
+const (
+	kindRegression = iota
+	kindSlowSQL
+	kindSuggestion
+)
+
+// rankedFinding references one finding of the alert together with its score.
+type rankedFinding struct {
+	kind  int
+	index int
+	score float64
+}
+
+// rankFindings orders each section of the alert by weighted significance and,
+// when MaxFindings is set, keeps only the most significant findings across all
+// rules. Every metric is normalized to [0, 1] by the largest value in the alert
+// so weights are comparable across rules. It is a no-op when neither weights
+// nor a cap are configured.
+func (e *Engine) rankFindings(alertCtx *model.AlertContext) {
+	weights := e.cfg.Analysis.Weights
+	maxFindings := e.cfg.Analysis.MaxFindings
+	if weights.IsZero() && maxFindings == 0 {
+		return
+	}
+
+	// Normalization bases
+	var maxTotal, maxPercent, maxAffected float64
+	for _, q := range alertCtx.TopSlowSQL {
+		maxTotal = maxFloat(maxTotal, q.TotalTime)
+	}
+	for _, r := range alertCtx.Regressions {
+		maxTotal = maxFloat(maxTotal, regressionTotalTime(r))
+		maxPercent = maxFloat(maxPercent, r.ChangePercent)
+	}
+	for _, s := range alertCtx.Suggestions {
+		maxAffected = maxFloat(maxAffected, float64(s.AffectedQueries))
+	}
+
+	var ranked []rankedFinding
+	for i, r := range alertCtx.Regressions {
+		score := weights.TotalTime*ratio(regressionTotalTime(r), maxTotal) +
+			weights.RegressionPercent*ratio(r.ChangePercent, maxPercent)
+		ranked = append(ranked, rankedFinding{kindRegression, i, score})
+	}
+	for i, q := range alertCtx.TopSlowSQL {
+		ranked = append(ranked, rankedFinding{kindSlowSQL, i, weights.TotalTime * ratio(q.TotalTime, maxTotal)})
+	}
+	for i, s := range alertCtx.Suggestions {
+		score := weights.AffectedQueries * ratio(float64(s.AffectedQueries), maxAffected)
+		ranked = append(ranked, rankedFinding{kindSuggestion, i, score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		if ranked[i].kind != ranked[j].kind {
+			return ranked[i].kind < ranked[j].kind
+		}
+		return ranked[i].index < ranked[j].index
+	})
+
+	if maxFindings > 0 && len(ranked) > maxFindings {
+		alertCtx.Summary.OmittedFindings = len(ranked) - maxFindings
+		ranked = ranked[:maxFindings]
+	}
+
+	var slow []model.MetricSnapshot
+	var regressions []model.RegressionItem
+	var suggestions []model.IndexSuggestion
+	for _, f := range ranked {
+		switch f.kind {
+		case kindRegression:
+			regressions = append(regressions, alertCtx.Regressions[f.index])
+		case kindSlowSQL:
+			slow = append(slow, alertCtx.TopSlowSQL[f.index])
+		case kindSuggestion:
+			suggestions = append(suggestions, alertCtx.Suggestions[f.index])
+		}
+	}
+	// Several slow SQL rankings stay in rank_by order, each ordered by significance
+	if rankings := e.cfg.Rules.SlowSQL.Rankings(); len(rankings) > 1 {
+		position := make(map[string]int, len(rankings))
+		for i, r := range rankings {
+			position[r.Metric] = i
+		}
+		sort.SliceStable(slow, func(i, j int) bool {
+			return position[slow[i].RankedBy] < position[slow[j].RankedBy]
+		})
+	}
+	alertCtx.TopSlowSQL = slow
+	alertCtx.Regressions = regressions
+	alertCtx.Suggestions = suggestions
+}
+
+// regressionTotalTime approximates the current total time of a regressed query.
+func regressionTotalTime(r model.RegressionItem) float64 {
+	return r.CurrentMeanTime * float64(r.CurrentCalls)
+}
+
+// ratio returns v/max, or 0 when max is not positive.
+func ratio(v, max float64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return v / max
+}
+
+func maxFloat(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// silence is one entry of analysis.silence_file. It matches a query finding
+// by queryid or by a regular expression on the query text, optionally only in
+// one database, until it expires.
+type silence struct {
+	QueryID  int64     `yaml:"queryid"`
+	Pattern  string    `yaml:"pattern"`
+	Database string    `yaml:"database"`
+	Until    time.Time `yaml:"until"`
+	Comment  string    `yaml:"comment"`
+
+	re *regexp.Regexp
+}
+
+// silenceFile is the document analysis.silence_file holds.
+type silenceFile struct {
+	Silences []silence `yaml:"silences"`
+}
+
+// matches reports whether the silence covers the query.
+func (s *silence) matches(queryID int64, database, query string) bool {
+	if s.Database != "" && s.Database != database {
+		return false
+	}
+	if s.QueryID != 0 && s.QueryID != queryID {
+		return false
+	}
+	return s.re == nil || s.re.MatchString(query)
+}
+
+// loadSilences reads path and returns the silences that have not expired at
+// now. The file is read on every run so silences can be added or lifted
+// without a restart.
+func loadSilences(path string, now time.Time) ([]silence, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading silence file: %w", err)
+	}
+	var f silenceFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing silence file %s: %w", path, err)
+	}
+
+	var active []silence
+	for i, s := range f.Silences {
+		if s.QueryID == 0 && s.Pattern == "" {
+			return nil, fmt.Errorf("silence file %s: entry %d needs a queryid or a pattern", path, i+1)
+		}
+		if s.Pattern != "" {
+			if s.re, err = regexp.Compile(s.Pattern); err != nil {
+				return nil, fmt.Errorf("silence file %s: entry %d: invalid pattern: %w", path, i+1, err)
+			}
+		}
+		if !s.Until.IsZero() && !now.Before(s.Until) {
+			continue
+		}
+		active = append(active, s)
+	}
+	return active, nil
+}
+
+// applySilences drops the query findings matched by an unexpired entry of
+// analysis.silence_file, logging each one. A file that cannot be read or
+// parsed is logged and silences nothing, so a broken edit can only make the
+// alert louder.
+func (e *Engine) applySilences(alertCtx *model.AlertContext, now time.Time) {
+	path := e.cfg.Analysis.SilenceFile
+	if path == "" {
+		return
+	}
+	silences, err := loadSilences(path, now)
+	if err != nil {
+		log.Printf("Warning: ignoring silences: %v", err)
+		return
+	}
+	if len(silences) == 0 {
+		return
+	}
+
+	// silenced reports whether the finding is silenced, logging it if so
+	silenced := func(rule string, queryID int64, database, query string) bool {
+		for i := range silences {
+			if silences[i].matches(queryID, database, query) {
+				log.Printf("Silenced %s finding for queryid %d in %s%s", rule, queryID, database, silences[i].describe())
+				return true
+			}
+		}
+		return false
+	}
+	alertCtx.TopSlowSQL = dropSilenced(alertCtx.TopSlowSQL, func(m *model.MetricSnapshot) bool {
+		return silenced("slow_sql", m.QueryID, m.DatabaseName, m.Query)
+	})
+	alertCtx.Regressions = dropSilenced(alertCtx.Regressions, func(r *model.RegressionItem) bool {
+		return silenced("regression", r.QueryID, r.DatabaseName, r.Query)
+	})
+	alertCtx.CallSpikes = dropSilenced(alertCtx.CallSpikes, func(c *model.CallSpikeItem) bool {
+		return silenced("call_spike", c.QueryID, c.DatabaseName, c.Query)
+	})
+	alertCtx.WaitEvents = dropSilenced(alertCtx.WaitEvents, func(w *model.WaitEventItem) bool {
+		return silenced("wait_events", w.QueryID, w.DatabaseName, w.Query)
+	})
+	alertCtx.Flapping = dropSilenced(alertCtx.Flapping, func(f *model.FlappingItem) bool {
+		return silenced("flapping", f.QueryID, f.DatabaseName, f.Query)
+	})
+	alertCtx.CrossServer = dropSilenced(alertCtx.CrossServer, func(c *model.CrossServerItem) bool {
+		return silenced("cross_server", c.QueryID, c.DatabaseName, c.Query)
+	})
+}
+
+// dropSilenced filters items in place, keeping those for which silenced is false.
+func dropSilenced[T any](items []T, silenced func(*T) bool) []T {
+	kept := items[:0]
+	for i := range items {
+		if !silenced(&items[i]) {
+			kept = append(kept, items[i])
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// describe returns the silence's expiry and comment for the log, e.g.
+// " until 2024-01-01T06:00:00Z: nightly batch".
+func (s *silence) describe() string {
+	var d string
+	if !s.Until.IsZero() {
+		d = " until " + s.Until.UTC().Format(time.RFC3339)
+	}
+	if s.Comment != "" {
+		d += ": " + s.Comment
+	}
+	return d
+}
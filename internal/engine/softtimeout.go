@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// ruleRunner runs an analysis's rules until analysis.soft_timeout has passed
+// since the run started, then records the rules it skips. A rule that is
+// already running is not interrupted; the hard timeout on ctx still applies.
+type ruleRunner struct {
+	now      func() time.Time
+	timeout  time.Duration
+	deadline time.Time // zero when no soft timeout is configured
+	skipped  []string
+}
+
+// newRuleRunner starts the soft timeout clock at start.
+func (e *Engine) newRuleRunner(start time.Time) *ruleRunner {
+	r := &ruleRunner{now: e.now}
+	if d, err := e.cfg.Analysis.SoftTimeoutParsed(); err == nil && d > 0 {
+		r.timeout, r.deadline = d, start.Add(d)
+	}
+	return r
+}
+
+// run calls fn unless the soft deadline has passed.
+func (r *ruleRunner) run(name string, fn func()) {
+	if !r.deadline.IsZero() && r.now().After(r.deadline) {
+		r.skipped = append(r.skipped, name)
+		return
+	}
+	fn()
+}
+
+// truncated describes the skipped rules, or returns nil when every rule ran.
+func (r *ruleRunner) truncated() *model.TruncatedRun {
+	if len(r.skipped) == 0 {
+		return nil
+	}
+	return &model.TruncatedRun{SoftTimeout: r.timeout, SkippedRules: r.skipped}
+}
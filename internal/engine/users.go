@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"math"
+	"sort"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// groupUsers returns metrics with one row per query, merging the rows PoWA
+// keeps for each role that ran it, unless analysis.group_by_user keeps them
+// apart so every rule ranks and compares each query per role.
+func (e *Engine) groupUsers(metrics []model.MetricSnapshot) []model.MetricSnapshot {
+	if e.cfg.Analysis.GroupByUser {
+		return metrics
+	}
+	return mergeUsers(metrics)
+}
+
+// mergeUsers sums the rows of each query across roles, keeping the first row's
+// text and pg_stat_kcache data, which PoWA does not keep per role. Standard
+// deviations are pooled over the roles' calls. Merged rows carry no role; the
+// order of first appearance is kept.
+func mergeUsers(metrics []model.MetricSnapshot) []model.MetricSnapshot {
+	if len(metrics) == 0 {
+		return metrics
+	}
+	type queryKey struct {
+		queryID int64
+		server  string
+		db      string
+	}
+	index := make(map[queryKey]int, len(metrics))
+	merged := make([]model.MetricSnapshot, 0, len(metrics))
+	for _, m := range metrics {
+		k := queryKey{m.QueryID, m.ServerName, m.DatabaseName}
+		i, ok := index[k]
+		if !ok {
+			m.UserID, m.UserName = 0, ""
+			index[k] = len(merged)
+			merged = append(merged, m)
+			continue
+		}
+		q := &merged[i]
+		// Sum of squares of both groups, to pool the variance around the new mean
+		squares := sumOfSquares(q) + sumOfSquares(&m)
+		q.TotalTime += m.TotalTime
+		q.Calls += m.Calls
+		if q.Calls > 0 {
+			q.MeanTime = q.TotalTime / float64(q.Calls)
+		}
+		if q.HasStddev = q.HasStddev && m.HasStddev; q.HasStddev && q.Calls > 0 {
+			q.StddevTime = math.Sqrt(max(squares/float64(q.Calls)-q.MeanTime*q.MeanTime, 0))
+		}
+		if m.Timestamp.After(q.Timestamp) {
+			q.Timestamp = m.Timestamp
+		}
+	}
+	return merged
+}
+
+// sumOfSquares returns the sum of the squared execution times of m's calls
+// implied by its mean and standard deviation.
+func sumOfSquares(m *model.MetricSnapshot) float64 {
+	return float64(m.Calls) * (m.StddevTime*m.StddevTime + m.MeanTime*m.MeanTime)
+}
+
+// summarizeUsers aggregates the current snapshots, one row per query and role,
+// per role and returns the analysis.top_users roles with the most total
+// execution time. Like summarizeDatabases it covers the fetched statements.
+func (e *Engine) summarizeUsers(current []model.MetricSnapshot) []model.UserSummary {
+	topN := e.cfg.Analysis.TopUsers
+	if topN <= 0 || len(current) == 0 {
+		return nil
+	}
+
+	type userKey struct {
+		server string
+		userID int64
+	}
+	type queryKey struct {
+		queryID int64
+		db      string
+	}
+	byUser := make(map[userKey]*model.UserSummary)
+	queries := make(map[userKey]map[queryKey]bool)
+	var totalTime float64
+	for _, m := range current {
+		key := userKey{server: m.ServerName, userID: m.UserID}
+		s, ok := byUser[key]
+		if !ok {
+			s = &model.UserSummary{UserID: m.UserID, UserName: m.UserName, ServerName: m.ServerName}
+			byUser[key] = s
+			queries[key] = make(map[queryKey]bool)
+		}
+		s.TotalTime += m.TotalTime
+		s.Calls += m.Calls
+		queries[key][queryKey{m.QueryID, m.DatabaseName}] = true
+		totalTime += m.TotalTime
+	}
+
+	summaries := make([]model.UserSummary, 0, len(byUser))
+	for key, s := range byUser {
+		s.QueryCount = len(queries[key])
+		if totalTime > 0 {
+			s.TimePercent = s.TotalTime / totalTime * 100
+		}
+		summaries = append(summaries, *s)
+	}
+
+	// Busiest first; calls and then server and role keep the order stable
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.TotalTime != b.TotalTime {
+			return a.TotalTime > b.TotalTime
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		if a.ServerName != b.ServerName {
+			return a.ServerName < b.ServerName
+		}
+		return a.UserID < b.UserID
+	})
+
+	if len(summaries) > topN {
+		summaries = summaries[:topN]
+	}
+	return summaries
+}
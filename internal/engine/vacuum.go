@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// VacuumReader is implemented by readers that can fetch PoWA's table
+// statistics snapshots. The vacuum rule is skipped for readers without it.
+type VacuumReader interface {
+	GetVacuumCandidates(ctx context.Context) ([]model.TableStats, error)
+}
+
+var _ VacuumReader = (*reader.Reader)(nil)
+
+// detectVacuum fetches the latest table statistics and reports tables needing
+// VACUUM or ANALYZE, or nil when the rule is disabled or no snapshots exist.
+func (e *Engine) detectVacuum(ctx context.Context, now time.Time) []model.VacuumItem {
+	if !e.cfg.AnyRules(vacuumEnabled) {
+		return nil
+	}
+	vr, ok := e.reader.(VacuumReader)
+	if !ok {
+		return nil
+	}
+	tables, err := vr.GetVacuumCandidates(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to fetch table statistics: %v", err)
+		return nil
+	}
+	return e.vacuumItems(tables, now)
+}
+
+// vacuumEnabled reports whether r sets a dead-tuple ratio or a valid max age.
+func vacuumEnabled(r *config.RulesConfig) bool {
+	maxAge, err := r.Vacuum.MaxAgeParsed()
+	return err == nil && (r.Vacuum.MaxDeadRatioPercent > 0 || maxAge > 0)
+}
+
+// vacuumItems keeps the tables with at least rules.vacuum.min_dead_tuples dead
+// tuples whose dead-tuple ratio reaches max_dead_ratio_percent, or whose last
+// VACUUM or ANALYZE is older than max_age (or never happened). The thresholds
+// are resolved per database.
+func (e *Engine) vacuumItems(tables []model.TableStats, now time.Time) []model.VacuumItem {
+	var items []model.VacuumItem
+	for _, t := range tables {
+		rules := e.cfg.RulesFor(t.DatabaseName)
+		if !vacuumEnabled(&rules) {
+			continue
+		}
+		cfg := rules.Vacuum
+		if t.DeadTuples < cfg.MinDeadTuples || t.DeadTuples <= 0 || !e.cfg.Analysis.SchemaAllowed(t.Schema) {
+			continue
+		}
+		maxAge, _ := cfg.MaxAgeParsed()
+		tooOld := func(last time.Time) bool {
+			return maxAge > 0 && (last.IsZero() || now.Sub(last) > maxAge)
+		}
+		ratio := float64(t.DeadTuples) / float64(t.LiveTuples+t.DeadTuples) * 100
+
+		var reasons []string
+		if cfg.MaxDeadRatioPercent > 0 && ratio >= cfg.MaxDeadRatioPercent {
+			reasons = append(reasons, "dead_tuples")
+		}
+		if tooOld(t.LastVacuum) {
+			reasons = append(reasons, "vacuum_age")
+		}
+		if tooOld(t.LastAnalyze) {
+			reasons = append(reasons, "analyze_age")
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		items = append(items, model.VacuumItem{
+			DatabaseName:     t.DatabaseName,
+			Schema:           t.Schema,
+			Table:            t.Table,
+			DeadTuples:       t.DeadTuples,
+			DeadRatioPercent: ratio,
+			LastVacuum:       t.LastVacuum,
+			LastAnalyze:      t.LastAnalyze,
+			Reasons:          reasons,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DeadRatioPercent != items[j].DeadRatioPercent {
+			return items[i].DeadRatioPercent > items[j].DeadRatioPercent
+		}
+		return items[i].DeadTuples > items[j].DeadTuples
+	})
+	return items
+}
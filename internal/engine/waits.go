@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+)
+
+// WaitEventReader is implemented by readers that can fetch pg_wait_sampling
+// samples. The waits rule is skipped for readers without it.
+type WaitEventReader interface {
+	GetWaitEvents(ctx context.Context, window time.Duration) ([]model.WaitEventSample, error)
+}
+
+var _ WaitEventReader = (*reader.Reader)(nil)
+
+// blockingWaitTypes are the wait event types the waits rule counts: a query
+// waiting on these is blocked rather than working.
+var blockingWaitTypes = map[string]bool{"Lock": true, "IO": true}
+
+// detectWaitEvents fetches the window's wait samples and reports queries that
+// spent at least rules.waits.min_percent of their execution time on Lock or IO
+// waits, or nil when the rule is disabled or wait sampling is unavailable.
+func (e *Engine) detectWaitEvents(ctx context.Context, current []model.MetricSnapshot, window time.Duration) []model.WaitEventItem {
+	enabled := func(r *config.RulesConfig) bool { return r.Waits.MinPercent > 0 }
+	if len(current) == 0 || !e.cfg.AnyRules(enabled) {
+		return nil
+	}
+	wr, ok := e.reader.(WaitEventReader)
+	if !ok {
+		return nil
+	}
+	samples, err := wr.GetWaitEvents(ctx, window)
+	if err != nil {
+		log.Printf("Warning: failed to fetch wait events: %v", err)
+		return nil
+	}
+	return e.waitEventItems(current, samples)
+}
+
+// waitEventItems converts wait samples to wait time with the profile period and
+// compares it with each query's execution time.
+func (e *Engine) waitEventItems(current []model.MetricSnapshot, samples []model.WaitEventSample) []model.WaitEventItem {
+	period, err := e.cfg.Rules.Waits.ProfilePeriodParsed()
+	if err != nil || period <= 0 {
+		period = 10 * time.Millisecond
+	}
+	periodMs := float64(period) / float64(time.Millisecond)
+
+	type queryKey struct {
+		queryID int64
+		srvID   int
+		db      string
+	}
+	type eventKey struct{ eventType, event string }
+	waits := make(map[queryKey]map[eventKey]int64)
+	for _, s := range samples {
+		if !blockingWaitTypes[s.EventType] || s.Samples <= 0 {
+			continue
+		}
+		k := queryKey{s.QueryID, s.SrvID, s.DatabaseName}
+		if waits[k] == nil {
+			waits[k] = make(map[eventKey]int64)
+		}
+		waits[k][eventKey{s.EventType, s.Event}] += s.Samples
+	}
+
+	// A query can have one row per user; its wait samples cover all of them
+	type queryTotals struct {
+		snapshot  model.MetricSnapshot
+		totalTime float64
+	}
+	totals := make(map[queryKey]*queryTotals)
+	var order []queryKey
+	for _, m := range current {
+		k := queryKey{m.QueryID, m.SrvID, m.DatabaseName}
+		if waits[k] == nil {
+			continue
+		}
+		if t, ok := totals[k]; ok {
+			t.totalTime += m.TotalTime
+			continue
+		}
+		totals[k] = &queryTotals{snapshot: m, totalTime: m.TotalTime}
+		order = append(order, k)
+	}
+
+	var items []model.WaitEventItem
+	for _, k := range order {
+		t := totals[k]
+		if t.totalTime <= 0 {
+			continue
+		}
+		var count int64
+		var dominant eventKey
+		var dominantCount int64
+		for ev, n := range waits[k] {
+			count += n
+			if n > dominantCount || (n == dominantCount && ev.eventType+":"+ev.event < dominant.eventType+":"+dominant.event) {
+				dominant, dominantCount = ev, n
+			}
+		}
+		waitTime := float64(count) * periodMs
+		percent := waitTime / t.totalTime * 100
+		if percent > 100 { // sampling error on short windows
+			percent = 100
+		}
+		if minPercent := e.cfg.RulesFor(t.snapshot.DatabaseName).Waits.MinPercent; minPercent <= 0 || percent < minPercent {
+			continue
+		}
+		items = append(items, model.WaitEventItem{
+			QueryID:           t.snapshot.QueryID,
+			Query:             t.snapshot.Query,
+			DatabaseName:      t.snapshot.DatabaseName,
+			ServerName:        t.snapshot.ServerName,
+			SrvID:             t.snapshot.SrvID,
+			TotalTime:         t.totalTime,
+			WaitTime:          waitTime,
+			WaitPercent:       percent,
+			DominantEventType: dominant.eventType,
+			DominantEvent:     dominant.event,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].WaitPercent != items[j].WaitPercent {
+			return items[i].WaitPercent > items[j].WaitPercent
+		}
+		return items[i].WaitTime > items[j].WaitTime
+	})
+	return items
+}
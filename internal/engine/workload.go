@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// detectWorkloadGrowth compares the total execution time of all queries in
+// current against baseline and reports a growth of at least
+// rules.workload_growth.threshold_percent. Totals are compared per second of
+// their window, so a pinned baseline of another length still compares. The
+// finding lists the queries that added the most time, which catches a load
+// increase spread over many queries that each stay under the regression
+// threshold.
+func (e *Engine) detectWorkloadGrowth(current, baseline []model.MetricSnapshot, currentWindow, baselineWindow model.TimeWindow) *model.WorkloadGrowthFinding {
+	cfg := e.cfg.Rules.WorkloadGrowth
+	if cfg.ThresholdPercent <= 0 || len(current) == 0 || len(baseline) == 0 {
+		return nil
+	}
+
+	finding := &model.WorkloadGrowthFinding{}
+	for _, m := range baseline {
+		finding.BaselineTotalTime += m.TotalTime
+		finding.BaselineCalls += m.Calls
+	}
+	for _, m := range current {
+		finding.CurrentTotalTime += m.TotalTime
+		finding.CurrentCalls += m.Calls
+	}
+	currentSeconds := currentWindow.Duration().Seconds()
+	baselineSeconds := baselineWindow.Duration().Seconds()
+	if finding.BaselineTotalTime <= 0 || currentSeconds <= 0 || baselineSeconds <= 0 {
+		return nil
+	}
+	baselineRate := finding.BaselineTotalTime / baselineSeconds
+	currentRate := finding.CurrentTotalTime / currentSeconds
+	finding.ChangePercent = (currentRate - baselineRate) / baselineRate * 100
+	if finding.ChangePercent < cfg.ThresholdPercent {
+		return nil
+	}
+	finding.TopContributors = workloadContributors(current, baseline, cfg.TopContributors)
+	return finding
+}
+
+// workloadContributors returns up to limit queries whose total time grew the
+// most, summing the rows of each query across users.
+func workloadContributors(current, baseline []model.MetricSnapshot, limit int) []model.WorkloadContributor {
+	if limit <= 0 {
+		return nil
+	}
+	type queryKey struct {
+		queryID int64
+		server  string
+		db      string
+	}
+	baselineTime := make(map[queryKey]float64, len(baseline))
+	for _, m := range baseline {
+		baselineTime[queryKey{m.QueryID, m.ServerName, m.DatabaseName}] += m.TotalTime
+	}
+
+	perQuery := make(map[queryKey]*model.WorkloadContributor)
+	var order []queryKey
+	for _, m := range current {
+		k := queryKey{m.QueryID, m.ServerName, m.DatabaseName}
+		if c, ok := perQuery[k]; ok {
+			c.CurrentTotalTime += m.TotalTime
+			continue
+		}
+		perQuery[k] = &model.WorkloadContributor{
+			QueryID:          m.QueryID,
+			Query:            m.Query,
+			DatabaseName:     m.DatabaseName,
+			ServerName:       m.ServerName,
+			SrvID:            m.SrvID,
+			CurrentTotalTime: m.TotalTime,
+		}
+		order = append(order, k)
+	}
+
+	var contributors []model.WorkloadContributor
+	for _, k := range order {
+		c := perQuery[k]
+		c.BaselineTotalTime = baselineTime[k]
+		c.AddedTime = c.CurrentTotalTime - c.BaselineTotalTime
+		if c.AddedTime > 0 {
+			contributors = append(contributors, *c)
+		}
+	}
+	sortFindings(contributors, func(c *model.WorkloadContributor) findingKey {
+		return findingKey{0, []float64{c.AddedTime, c.CurrentTotalTime}, c.QueryID, []string{c.ServerName, c.DatabaseName, c.Query}}
+	})
+	if len(contributors) > limit {
+		contributors = contributors[:limit]
+	}
+	return contributors
+}
@@ -0,0 +1,99 @@
+// Package format renders metric values for human-readable notifier output.
+// Machine-readable outputs (JSON, CSV, syslog structured data) keep raw values.
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter renders numbers with a fixed number of decimals.
+type Formatter struct {
+	precision int
+}
+
+// New returns a Formatter that rounds to precision decimals. A negative
+// precision is treated as zero.
+func New(precision int) Formatter {
+	if precision < 0 {
+		precision = 0
+	}
+	return Formatter{precision: precision}
+}
+
+// Duration renders a duration given in milliseconds in the largest unit that
+// keeps the value readable: "850.12 ms", "1.23 s" or "2.50 min". The unit is
+// chosen after rounding, so 999.999 ms renders as "1.00 s" rather than "1000.00 ms".
+func (f Formatter) Duration(ms float64) string {
+	abs := math.Abs(ms)
+	switch {
+	case f.round(abs) < 1000:
+		return f.Number(ms) + " ms"
+	case f.round(abs/1000) < 60:
+		return f.Number(ms/1000) + " s"
+	default:
+		return f.Number(ms/60000) + " min"
+	}
+}
+
+// Number renders v with the configured number of decimals.
+func (f Formatter) Number(v float64) string {
+	return strconv.FormatFloat(v, 'f', f.precision, 64)
+}
+
+// Percent renders a percentage such as "+12.35%". Positive values are signed
+// so increases read naturally in regression lines.
+func (f Formatter) Percent(p float64) string {
+	s := f.Number(p) + "%"
+	if f.round(p) > 0 {
+		s = "+" + s
+	}
+	return s
+}
+
+// round rounds v to the configured precision.
+func (f Formatter) round(v float64) float64 {
+	scale := math.Pow(10, float64(f.precision))
+	return math.Round(v*scale) / scale
+}
+
+// Age renders how long ago something happened in whole days, or whole hours
+// under two days: "12 days ago", "5 h ago" or "under an hour ago".
+func Age(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return strconv.FormatInt(int64(d/(24*time.Hour)), 10) + " days ago"
+	case d >= time.Hour:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + " h ago"
+	default:
+		return "under an hour ago"
+	}
+}
+
+// Count renders an integer with thousands separators, e.g. "1,234,567".
+func Count(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= 3 {
+		return sign + s
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sign)
+	head := len(s) % 3
+	if head > 0 {
+		sb.WriteString(s[:head])
+	}
+	for i := head; i < len(s); i += 3 {
+		if sb.Len() > len(sign) {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(s[i : i+3])
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatter_Duration(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		ms        float64
+		want      string
+	}{
+		{"zero", 2, 0, "0.00 ms"},
+		{"sub-millisecond", 2, 0.004, "0.00 ms"},
+		{"milliseconds", 2, 850.12345, "850.12 ms"},
+		{"just below one second", 2, 999.99, "999.99 ms"},
+		{"rounds up to one second", 2, 999.996, "1.00 s"},
+		{"one second", 2, 1000, "1.00 s"},
+		{"seconds", 2, 1234.56789012, "1.23 s"},
+		{"just below one minute", 2, 59994, "59.99 s"},
+		{"rounds up to one minute", 2, 59996, "1.00 min"},
+		{"minutes", 2, 150000, "2.50 min"},
+		{"hours stay in minutes", 1, 7200000, "120.0 min"},
+		{"zero precision", 0, 1234.56789012, "1 s"},
+		{"zero precision boundary", 0, 999.5, "1 s"},
+		{"negative", 2, -1500, "-1.50 s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.precision).Duration(tt.ms); got != tt.want {
+				t.Errorf("Duration(%v) = %q, want %q", tt.ms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatter_Percent(t *testing.T) {
+	tests := []struct {
+		precision int
+		p         float64
+		want      string
+	}{
+		{1, 160, "+160.0%"},
+		{2, 12.3456, "+12.35%"},
+		{0, 0.4, "0%"},
+		{1, -25, "-25.0%"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.precision).Percent(tt.p); got != tt.want {
+			t.Errorf("Percent(%v) with precision %d = %q, want %q", tt.p, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestNew_NegativePrecision(t *testing.T) {
+	if got := New(-1).Number(1.6); got != "2" {
+		t.Errorf("Number() = %q, want zero decimals", got)
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{7, "7"},
+		{999, "999"},
+		{1000, "1,000"},
+		{12345, "12,345"},
+		{999999, "999,999"},
+		{1000000, "1,000,000"},
+		{1234567890, "1,234,567,890"},
+		{-1234, "-1,234"},
+		{-999, "-999"},
+		{-9223372036854775808, "-9,223,372,036,854,775,808"},
+	}
+
+	for _, tt := range tests {
+		if got := Count(tt.n); got != tt.want {
+			t.Errorf("Count(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "under an hour ago"},
+		{59 * time.Minute, "under an hour ago"},
+		{time.Hour, "1 h ago"},
+		{47*time.Hour + 59*time.Minute, "47 h ago"},
+		{48 * time.Hour, "2 days ago"},
+		{90*24*time.Hour + 23*time.Hour, "90 days ago"},
+	}
+
+	for _, tt := range tests {
+		if got := Age(tt.d); got != tt.want {
+			t.Errorf("Age(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // AlertContext contains all the analysis results to be included in a notification.
 type AlertContext struct {
@@ -22,17 +26,100 @@ type AlertContext struct {
 	// DatabaseName is the target database being analyzed.
 	DatabaseName string `json:"database_name"`
 
+	// Repository is the name of the PoWA repository analyzed when database is
+	// a list; empty for a single repository.
+	Repository string `json:"repository,omitempty"`
+
 	// TopSlowSQL contains the top N slow queries identified.
 	TopSlowSQL []MetricSnapshot `json:"top_slow_sql,omitempty"`
 
+	// TopDatabases ranks databases by total execution time in the analysis window.
+	TopDatabases []DatabaseSummary `json:"top_databases,omitempty"`
+
+	// TopUsers ranks roles by total execution time in the analysis window.
+	TopUsers []UserSummary `json:"top_users,omitempty"`
+
 	// Regressions contains queries with significant performance degradation.
 	Regressions []RegressionItem `json:"regressions,omitempty"`
 
 	// Suggestions contains index optimization recommendations.
 	Suggestions []IndexSuggestion `json:"suggestions,omitempty"`
 
+	// CallSpikes contains queries whose call volume jumped relative to the baseline.
+	CallSpikes []CallSpikeItem `json:"call_spikes,omitempty"`
+
+	// WaitEvents contains queries that spent a large share of their time on lock or IO waits.
+	WaitEvents []WaitEventItem `json:"wait_events,omitempty"`
+
+	// Flapping contains queries whose mean time oscillates across recent windows.
+	Flapping []FlappingItem `json:"flapping,omitempty"`
+
+	// CrossServer contains queries slower on a server than on the reference server.
+	CrossServer []CrossServerItem `json:"cross_server,omitempty"`
+
+	// Vacuum contains tables with many dead tuples or without a recent VACUUM/ANALYZE.
+	Vacuum []VacuumItem `json:"vacuum,omitempty"`
+
+	// WorkloadGrowth is set when the total execution time of all queries grew
+	// by at least rules.workload_growth.threshold_percent over the baseline.
+	WorkloadGrowth *WorkloadGrowthFinding `json:"workload_growth,omitempty"`
+
+	// ConnectionSaturation is set when the monitored instance's client
+	// connections reached rules.connections.max_percent of max_connections.
+	ConnectionSaturation *ConnectionSaturationFinding `json:"connection_saturation,omitempty"`
+
+	// StaleData is set when PoWA's newest snapshot is older than analysis.max_data_age.
+	StaleData *StaleDataFinding `json:"stale_data,omitempty"`
+
+	// Truncated is set when analysis.soft_timeout passed mid-run: the alert only
+	// holds the findings of the rules that ran before it.
+	Truncated *TruncatedRun `json:"truncated,omitempty"`
+
+	// DigestRuns is the number of runs consolidated into this alert when
+	// notifier.digest_interval is set; 0 for the alert of a single run.
+	DigestRuns int `json:"digest_runs,omitempty"`
+
+	// Delta lists findings that appeared, disappeared or persisted since the
+	// previous run. It is only set when notifier.mode is "delta".
+	Delta *FindingDelta `json:"delta,omitempty"`
+
+	// Escalation holds the critical findings that just reached
+	// notifier.escalation.after_runs consecutive runs, for the escalation notifier.
+	Escalation *AlertContext `json:"escalation,omitempty"`
+
+	// Recovery lists, as the Resolved findings of its Delta, the findings at
+	// or above notifier.recovery_severity that fired last run and cleared in
+	// this one, for the recovery notification.
+	Recovery *AlertContext `json:"recovery,omitempty"`
+
+	// CustomFindings contains rows from config-defined SQL rules that crossed their threshold.
+	CustomFindings []CustomFinding `json:"custom_findings,omitempty"`
+
+	// RawMetrics holds the analyzed metric rows of the current window, worst
+	// total time first, up to notifier.raw_metrics_limit, when
+	// notifier.include_raw_metrics is set; query text is redacted like
+	// everywhere else.
+	RawMetrics []MetricSnapshot `json:"raw_metrics,omitempty"`
+
 	// Summary contains aggregated health metrics.
 	Summary AlertSummary `json:"summary"`
+
+	// Labels are the configured global labels (e.g. env, team) used for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DisplayLocation is the timezone text notifiers render timestamps in.
+	// Timestamps themselves are UTC; nil renders in the server's local zone.
+	DisplayLocation *time.Location `json:"-"`
+}
+
+// DisplayTime renders t in the alert's display timezone with its UTC offset.
+func (a *AlertContext) DisplayTime(t time.Time, layout string) string {
+	if a.DisplayLocation != nil {
+		t = t.In(a.DisplayLocation)
+	} else {
+		t = t.Local()
+	}
+	return t.Format(layout + " -07:00")
 }
 
 // TimeWindow represents a time range for analysis.
@@ -54,17 +141,106 @@ type AlertSummary struct {
 	// SlowQueryCount is the number of queries exceeding thresholds.
 	SlowQueryCount int `json:"slow_query_count"`
 
-	// RegressionCount is the number of detected performance regressions.
+	// RegressionCount is the number of detected performance regressions (excluding new queries).
 	RegressionCount int `json:"regression_count"`
 
+	// NewQueryCount is the number of queries reported as new because they have no baseline.
+	NewQueryCount int `json:"new_query_count,omitempty"`
+
+	// OmittedFindings is the number of less significant findings dropped by analysis.max_findings.
+	// Counts above include them; the alert body does not.
+	OmittedFindings int `json:"omitted_findings,omitempty"`
+
+	// BaselineOnlyQueries is the number of baseline queries absent from the
+	// current window: possibly resolved, or beyond the reader's row limit.
+	// They are not compared or reported as resolved.
+	BaselineOnlyQueries int `json:"baseline_only_queries,omitempty"`
+
 	// SuggestionCount is the number of index optimization suggestions.
 	SuggestionCount int `json:"suggestion_count"`
 
+	// CallSpikeCount is the number of queries with a call-volume spike.
+	CallSpikeCount int `json:"call_spike_count,omitempty"`
+
+	// WaitEventCount is the number of queries dominated by lock or IO waits.
+	WaitEventCount int `json:"wait_event_count,omitempty"`
+
+	// FlappingCount is the number of queries with an oscillating mean time.
+	FlappingCount int `json:"flapping_count,omitempty"`
+
+	// CrossServerCount is the number of queries slower than on the reference server.
+	CrossServerCount int `json:"cross_server_count,omitempty"`
+
+	// VacuumCount is the number of tables needing VACUUM or ANALYZE.
+	VacuumCount int `json:"vacuum_count,omitempty"`
+
+	// CustomFindingCount is the number of findings from custom SQL rules.
+	CustomFindingCount int `json:"custom_finding_count,omitempty"`
+
 	// HealthScore is an overall health score from 0-100.
 	HealthScore int `json:"health_score"`
 
 	// HealthStatus is a human-readable status (e.g., "healthy", "warning", "critical").
 	HealthStatus string `json:"health_status"`
+
+	// Empty is set when the alert has no findings: EmptyNoData or EmptyNoFindings.
+	Empty string `json:"empty,omitempty"`
+}
+
+// Reasons an alert has no findings (AlertSummary.Empty).
+const (
+	// EmptyNoData means no statements were recorded in the analysis window, which
+	// may be a quiet night or a collector or filter problem.
+	EmptyNoData = "no_data"
+
+	// EmptyNoFindings means statements were analyzed and no rule fired.
+	EmptyNoFindings = "no_findings"
+)
+
+// DatabaseSummary aggregates the current window's statements of one database.
+type DatabaseSummary struct {
+	// DatabaseName is the monitored database.
+	DatabaseName string `json:"database_name"`
+
+	// ServerName is the server alias or hostname (PoWA 4+).
+	ServerName string `json:"server_name"`
+
+	// TotalTime is the summed execution time in milliseconds.
+	TotalTime float64 `json:"total_time"`
+
+	// Calls is the summed number of calls.
+	Calls int64 `json:"calls"`
+
+	// QueryCount is the number of distinct queries seen.
+	QueryCount int `json:"query_count"`
+
+	// TimePercent is the database's share of the total execution time of all databases.
+	TimePercent float64 `json:"time_percent"`
+}
+
+// UserSummary aggregates the current window's statements of one role.
+type UserSummary struct {
+	// UserID is the oid of the role.
+	UserID int64 `json:"userid"`
+
+	// UserName is the role name; empty when it could not be looked up.
+	UserName string `json:"user_name,omitempty"`
+
+	// ServerName is the server alias or hostname (PoWA 4+). Role oids are
+	// per server, so each server's roles are summarized apart.
+	ServerName string `json:"server_name"`
+
+	// TotalTime is the summed execution time in milliseconds.
+	TotalTime float64 `json:"total_time"`
+
+	// Calls is the summed number of calls.
+	Calls int64 `json:"calls"`
+
+	// QueryCount is the number of distinct queries the role ran.
+	QueryCount int `json:"query_count"`
+
+	// TimePercent is the role's share of the total execution time of all roles.
+	TimePercent float64 `json:"time_percent"`
 }
 
 // RegressionItem represents a query with detected performance regression.
@@ -81,6 +257,14 @@ type RegressionItem struct {
 	// ServerName is the server alias or hostname (PoWA 4+).
 	ServerName string `json:"server_name"`
 
+	// SrvID is the internal PoWA server ID (PoWA 4+), used in query links.
+	SrvID int `json:"srvid"`
+
+	// UserID and UserName are the role the query regressed for; only set
+	// with analysis.group_by_user.
+	UserID   int64  `json:"userid,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+
 	// CurrentMeanTime is the mean execution time in the current window.
 	CurrentMeanTime float64 `json:"current_mean_time"`
 
@@ -96,8 +280,370 @@ type RegressionItem struct {
 	// BaselineCalls is the number of calls in the baseline window.
 	BaselineCalls int64 `json:"baseline_calls"`
 
-	// Severity indicates the regression severity ("low", "medium", "high", "critical").
+	// Severity indicates the regression severity ("info", "low", "medium", "high", "critical").
+	// New queries without a usable baseline are always "info".
+	Severity string `json:"severity"`
+
+	// CurrentTailTime and BaselineTailTime are the estimated tail execution
+	// times (mean + k standard deviations) that ChangePercent compares with
+	// rules.regression.metric tail; zero when the comparison used the mean.
+	CurrentTailTime  float64 `json:"current_tail_time,omitempty"`
+	BaselineTailTime float64 `json:"baseline_tail_time,omitempty"`
+
+	// IsNewQuery is true when the query has no baseline data (or a zero baseline mean time),
+	// so no change percent can be computed.
+	IsNewQuery bool `json:"is_new_query,omitempty"`
+
+	// FirstSeen is the earliest retained PoWA snapshot of the query; zero when
+	// unknown or when the query predates the retained history.
+	FirstSeen time.Time `json:"first_seen,omitzero"`
+
+	// Labels are the global labels merged with per-database overrides.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// FindingDelta compares the findings of a run with those of the previous run.
+type FindingDelta struct {
+	// New are findings absent from the previous run.
+	New []FindingRef `json:"new"`
+
+	// Resolved are findings of the previous run that no longer fire.
+	Resolved []FindingRef `json:"resolved"`
+
+	// Still are findings present in both runs.
+	Still []FindingRef `json:"still"`
+}
+
+// FindingRef identifies one finding across runs.
+type FindingRef struct {
+	// Rule is the rule that produced the finding (e.g. "regression", "custom:dead_tuples").
+	Rule string `json:"rule"`
+
+	// QueryID is the query the finding is about, if any.
+	QueryID int64 `json:"query_id,omitempty"`
+
+	// DatabaseName is the database of the query, if any.
+	DatabaseName string `json:"database_name,omitempty"`
+
+	// ServerName is the server of the query, if any.
+	ServerName string `json:"server_name,omitempty"`
+
+	// UserID is the role of the query with analysis.group_by_user, if any.
+	UserID int64 `json:"userid,omitempty"`
+
+	// Subject describes the finding: query text, table and columns, or a custom row.
+	Subject string `json:"subject"`
+
+	// Severity is the finding severity, if the rule assigns one.
+	Severity string `json:"severity,omitempty"`
+}
+
+// Key identifies the finding: rule and query, and role when set, for query
+// findings, rule and subject otherwise.
+func (f FindingRef) Key() string {
+	if f.QueryID != 0 {
+		key := fmt.Sprintf("%s|%s|%s|%d", f.Rule, f.ServerName, f.DatabaseName, f.QueryID)
+		if f.UserID != 0 {
+			key += fmt.Sprintf("|%d", f.UserID)
+		}
+		return key
+	}
+	return f.Rule + "|" + f.Subject
+}
+
+// severityRanks orders finding severities from least to most severe.
+var severityRanks = map[string]int{
+	"info":     1,
+	"low":      2,
+	"medium":   3,
+	"high":     4,
+	"critical": 5,
+}
+
+// SeverityRank returns the rank of a finding severity, from 1 ("info") to 5
+// ("critical"); unknown and empty severities rank 0.
+func SeverityRank(severity string) int {
+	return severityRanks[severity]
+}
+
+// StaleDataFinding reports that the PoWA repository stopped receiving snapshots,
+// which usually means the collector is down and the other findings describe old data.
+type StaleDataFinding struct {
+	// Severity is always "high".
 	Severity string `json:"severity"`
+
+	// LatestSnapshot is the timestamp of the newest snapshot; zero when the history is empty.
+	LatestSnapshot time.Time `json:"latest_snapshot"`
+
+	// Age is how old the newest snapshot was when the analysis ran.
+	Age time.Duration `json:"age"`
+
+	// MaxAge is the configured analysis.max_data_age.
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// ConnectionUsage counts the client connections of the monitored instance.
+type ConnectionUsage struct {
+	// Active is the number of connections running a statement.
+	Active int `json:"active"`
+
+	// Idle is the number of idle connections, in a transaction or not.
+	Idle int `json:"idle"`
+
+	// Total is the number of client connections in any state.
+	Total int `json:"total"`
+
+	// MaxConnections is the instance's max_connections setting.
+	MaxConnections int `json:"max_connections"`
+}
+
+// ConnectionSaturationFinding reports that the monitored instance is running
+// out of connections: new clients are refused once Total reaches
+// MaxConnections, and slow ones pile up before that.
+type ConnectionSaturationFinding struct {
+	ConnectionUsage
+
+	// UsagePercent is Total as a percentage of MaxConnections.
+	UsagePercent float64 `json:"usage_percent"`
+
+	// MaxPercent is the configured rules.connections.max_percent.
+	MaxPercent float64 `json:"max_percent"`
+}
+
+// WorkloadGrowthFinding reports that the overall load grew: the total execution
+// time of all queries in the current window exceeds the baseline's, per second
+// of window, by rules.workload_growth.threshold_percent, even when no single
+// query regressed.
+type WorkloadGrowthFinding struct {
+	// BaselineTotalTime is the total execution time of all baseline queries in milliseconds.
+	BaselineTotalTime float64 `json:"baseline_total_time"`
+
+	// CurrentTotalTime is the total execution time of all current queries in milliseconds.
+	CurrentTotalTime float64 `json:"current_total_time"`
+
+	// ChangePercent is the growth of total time per second of window, so a
+	// pinned baseline of another length still compares.
+	ChangePercent float64 `json:"change_percent"`
+
+	// BaselineCalls and CurrentCalls are the total calls of each window.
+	BaselineCalls int64 `json:"baseline_calls"`
+	CurrentCalls  int64 `json:"current_calls"`
+
+	// TopContributors are the queries that added the most total time, largest first.
+	TopContributors []WorkloadContributor `json:"top_contributors,omitempty"`
+}
+
+// WorkloadContributor is one query's share of a workload growth.
+type WorkloadContributor struct {
+	QueryID      int64  `json:"query_id"`
+	Query        string `json:"query"`
+	DatabaseName string `json:"database_name"`
+	ServerName   string `json:"server_name"`
+	SrvID        int    `json:"srvid"`
+
+	// BaselineTotalTime and CurrentTotalTime are the query's total execution
+	// time in each window in milliseconds; the baseline is 0 for new queries.
+	BaselineTotalTime float64 `json:"baseline_total_time"`
+	CurrentTotalTime  float64 `json:"current_total_time"`
+
+	// AddedTime is CurrentTotalTime minus BaselineTotalTime.
+	AddedTime float64 `json:"added_time"`
+}
+
+// CallSpikeItem represents a query whose call count jumped relative to the baseline.
+type CallSpikeItem struct {
+	// QueryID is the unique identifier for the query.
+	QueryID int64 `json:"query_id"`
+
+	// Query is the normalized query text.
+	Query string `json:"query"`
+
+	// DatabaseName is the database where the query runs.
+	DatabaseName string `json:"database_name"`
+
+	// ServerName is the server alias or hostname (PoWA 4+).
+	ServerName string `json:"server_name"`
+
+	// SrvID is the internal PoWA server ID (PoWA 4+), used in query links.
+	SrvID int `json:"srvid"`
+
+	// UserID and UserName are the role whose calls spiked; only set with
+	// analysis.group_by_user.
+	UserID   int64  `json:"userid,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+
+	// BaselineCalls is the number of calls in the baseline window.
+	BaselineCalls int64 `json:"baseline_calls"`
+
+	// CurrentCalls is the number of calls in the current window.
+	CurrentCalls int64 `json:"current_calls"`
+
+	// ChangePercent is the percentage change in calls ((current - baseline) / baseline * 100).
+	ChangePercent float64 `json:"change_percent"`
+
+	// Labels are the merged global and per-database labels for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CrossServerItem represents a query whose mean time on one server exceeds the
+// mean time of the same query on rules.cross_server.reference_srvid in the same
+// window. Queries are matched by database and query text, since queryids can
+// differ between servers.
+type CrossServerItem struct {
+	// QueryID is the query's identifier on the compared server.
+	QueryID int64 `json:"query_id"`
+
+	// Query is the normalized query text.
+	Query string `json:"query"`
+
+	// DatabaseName is the database where the query runs.
+	DatabaseName string `json:"database_name"`
+
+	// ServerName is the compared server's alias or hostname.
+	ServerName string `json:"server_name"`
+
+	// SrvID is the compared server's PoWA server ID, used in query links.
+	SrvID int `json:"srvid"`
+
+	// ReferenceServerName is the reference server's alias or hostname.
+	ReferenceServerName string `json:"reference_server_name"`
+
+	// ReferenceSrvID is the reference server's PoWA server ID.
+	ReferenceSrvID int `json:"reference_srvid"`
+
+	// ReferenceMeanTime is the query's mean time on the reference server in milliseconds.
+	ReferenceMeanTime float64 `json:"reference_mean_time"`
+
+	// MeanTime is the query's mean time on the compared server in milliseconds.
+	MeanTime float64 `json:"mean_time"`
+
+	// ChangePercent is the difference in mean time ((mean - reference) / reference * 100).
+	ChangePercent float64 `json:"change_percent"`
+
+	// ReferenceCalls is the number of calls on the reference server.
+	ReferenceCalls int64 `json:"reference_calls"`
+
+	// Calls is the number of calls on the compared server.
+	Calls int64 `json:"calls"`
+
+	// Labels are the merged global and per-database labels for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// WaitEventItem represents a query that spent at least rules.waits.min_percent
+// of its execution time waiting on locks or IO, as sampled by pg_wait_sampling.
+type WaitEventItem struct {
+	// QueryID is the unique identifier for the query.
+	QueryID int64 `json:"query_id"`
+
+	// Query is the normalized query text.
+	Query string `json:"query"`
+
+	// DatabaseName is the database where the query runs.
+	DatabaseName string `json:"database_name"`
+
+	// ServerName is the server alias or hostname (PoWA 4+).
+	ServerName string `json:"server_name"`
+
+	// SrvID is the internal PoWA server ID (PoWA 4+), used in query links.
+	SrvID int `json:"srvid"`
+
+	// TotalTime is the query's total execution time in the window in milliseconds.
+	TotalTime float64 `json:"total_time_ms"`
+
+	// WaitTime is the estimated lock and IO wait time in milliseconds (samples x profile period).
+	WaitTime float64 `json:"wait_time_ms"`
+
+	// WaitPercent is WaitTime as a percentage of TotalTime, capped at 100.
+	WaitPercent float64 `json:"wait_percent"`
+
+	// DominantEventType is the wait event type with the most samples ("Lock" or "IO").
+	DominantEventType string `json:"dominant_event_type"`
+
+	// DominantEvent is the wait event with the most samples (e.g. "transactionid", "DataFileRead").
+	DominantEvent string `json:"dominant_event"`
+
+	// Labels are the merged global and per-database labels for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// FlappingItem represents a query whose mean time varied by at least
+// rules.flapping.max_cv (stddev/mean) across the last rules.flapping.windows windows.
+type FlappingItem struct {
+	// QueryID is the unique identifier for the query.
+	QueryID int64 `json:"query_id"`
+
+	// Query is the normalized query text.
+	Query string `json:"query"`
+
+	// DatabaseName is the database where the query runs.
+	DatabaseName string `json:"database_name"`
+
+	// ServerName is the server alias or hostname (PoWA 4+).
+	ServerName string `json:"server_name"`
+
+	// SrvID is the internal PoWA server ID (PoWA 4+), used in query links.
+	SrvID int `json:"srvid"`
+
+	// MeanTime is the average of the per-window mean times in milliseconds.
+	MeanTime float64 `json:"mean_time_ms"`
+
+	// CV is the coefficient of variation of the per-window mean times.
+	CV float64 `json:"cv"`
+
+	// Series holds the query's mean time in each window it ran in, oldest first.
+	Series []FlappingPoint `json:"series"`
+
+	// Labels are the merged global and per-database labels for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// VacuumItem represents a table flagged by rules.vacuum.
+type VacuumItem struct {
+	// DatabaseName is the database containing the table.
+	DatabaseName string `json:"database_name"`
+
+	// Schema is the schema containing the table.
+	Schema string `json:"schema"`
+
+	// Table is the table name.
+	Table string `json:"table"`
+
+	// DeadTuples is the estimated number of dead rows.
+	DeadTuples int64 `json:"n_dead_tup"`
+
+	// DeadRatioPercent is DeadTuples as a percentage of live plus dead rows.
+	DeadRatioPercent float64 `json:"dead_ratio_percent"`
+
+	// LastVacuum and LastAnalyze are the latest manual or automatic run; zero when never run.
+	LastVacuum  time.Time `json:"last_vacuum,omitempty"`
+	LastAnalyze time.Time `json:"last_analyze,omitempty"`
+
+	// Reasons lists why the table was flagged: "dead_tuples", "vacuum_age", "analyze_age".
+	Reasons []string `json:"reasons"`
+
+	// Labels are the merged global and per-database labels for routing.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// FullTableName returns the qualified table name (schema.table) unless in public.
+func (v *VacuumItem) FullTableName() string {
+	if v.Schema == "" || v.Schema == "public" {
+		return v.Table
+	}
+	return v.Schema + "." + v.Table
+}
+
+// FlappingPoint is a query's mean time in one window of the flapping rule.
+type FlappingPoint struct {
+	// Window is the window the point covers.
+	Window TimeWindow `json:"window"`
+
+	// MeanTime is the query's mean execution time in the window in milliseconds.
+	MeanTime float64 `json:"mean_time_ms"`
+
+	// Calls is the number of calls in the window.
+	Calls int64 `json:"calls"`
 }
 
 // IndexSuggestion represents a missing index recommendation.
@@ -123,8 +669,26 @@ type IndexSuggestion struct {
 	// AffectedQueries is the count of queries that would benefit from this index.
 	AffectedQueries int `json:"affected_queries"`
 
-	// SuggestedDDL is the CREATE INDEX statement (if available from hypopg).
+	// SuggestedDDL is the CREATE INDEX statement, from hypopg when available and
+	// otherwise synthesized by the engine. It is advisory: review before running.
 	SuggestedDDL string `json:"suggested_ddl,omitempty"`
+
+	// Examples are constants pg_qualstats saw compared against the columns,
+	// when it tracks them (pg_qualstats.track_constants), in column order.
+	Examples []ColumnExample `json:"examples,omitempty"`
+}
+
+// ColumnExample holds representative constants for one suggested column.
+type ColumnExample struct {
+	// Column is the column name.
+	Column string `json:"column"`
+
+	// Values are up to a few distinct constants the column was compared to.
+	Values []string `json:"values,omitempty"`
+
+	// Selectivity is the average fraction of rows (0-1) the predicates on
+	// the column kept; 0 when unknown.
+	Selectivity float64 `json:"selectivity,omitempty"`
 }
 
 // FullTableName returns the fully qualified table name.
@@ -134,3 +698,43 @@ func (s *IndexSuggestion) FullTableName() string {
 	}
 	return s.Schema + "." + s.Table
 }
+
+// CustomFinding is one row of a custom SQL rule whose threshold column
+// satisfied the configured comparison.
+type CustomFinding struct {
+	// Rule is the configured rule name.
+	Rule string `json:"rule"`
+
+	// Severity is the configured rule severity.
+	Severity string `json:"severity"`
+
+	// Column is the threshold column that was compared.
+	Column string `json:"column"`
+
+	// Value is the threshold column's value in this row.
+	Value float64 `json:"value"`
+
+	// Operator and Threshold describe the comparison that matched (e.g. "> 100").
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+
+	// Row holds every column of the matching row as text; NULL columns are omitted.
+	Row map[string]string `json:"row"`
+
+	// Columns preserves the query's column order for rendering Row.
+	Columns []string `json:"-"`
+}
+
+// TruncatedRun describes an analysis cut short by analysis.soft_timeout.
+type TruncatedRun struct {
+	// SoftTimeout is the configured analysis.soft_timeout.
+	SoftTimeout time.Duration `json:"soft_timeout"`
+
+	// SkippedRules lists the rules that did not run, in run order.
+	SkippedRules []string `json:"skipped_rules"`
+}
+
+// Note returns the line notifiers show for a truncated run.
+func (t *TruncatedRun) Note() string {
+	return fmt.Sprintf("Analysis truncated due to soft timeout (%s): skipped %s", t.SoftTimeout, strings.Join(t.SkippedRules, ", "))
+}
@@ -1,7 +1,14 @@
 // Package model defines the core data structures used by powa-sentinel.
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 // MetricSnapshot represents a point-in-time snapshot of query performance metrics.
 // It maps to data from powa_statements and optionally pg_stat_kcache.
@@ -12,6 +19,10 @@ type MetricSnapshot struct {
 	// Query is the normalized query text with placeholders instead of literal values.
 	Query string `json:"query"`
 
+	// QueryHash is the SHA-256 (hex) of the full query text. It is only set when
+	// Query was cut to analysis.max_query_length.
+	QueryHash string `json:"query_hash,omitempty"`
+
 	// DatabaseName is the name of the database where the query was executed.
 	DatabaseName string `json:"database_name"`
 
@@ -30,9 +41,27 @@ type MetricSnapshot struct {
 	// Calls is the number of times the query was executed.
 	Calls int64 `json:"calls"`
 
+	// StddevTime is the standard deviation of the execution time in
+	// milliseconds, from the last snapshot in the window. pg_stat_statements
+	// keeps it since its last reset, not per window. Only read for
+	// rules.regression.metric tail, and only when the PoWA history records it.
+	StddevTime float64 `json:"stddev_time,omitempty"`
+
+	// HasStddev indicates if StddevTime was read for this snapshot.
+	HasStddev bool `json:"has_stddev,omitempty"`
+
 	// Timestamp is the time of the snapshot/aggregation window.
 	Timestamp time.Time `json:"timestamp"`
 
+	// UserID is the oid of the role that ran the query. PoWA keeps one row per
+	// query and role; unless analysis.group_by_user is set the engine merges
+	// them and UserID is 0.
+	UserID int64 `json:"userid,omitempty"`
+
+	// UserName is the role name of UserID, looked up in pg_roles. It is empty
+	// for roles of remote PoWA servers, whose oids the repository cannot map.
+	UserName string `json:"user_name,omitempty"`
+
 	// --- Optional fields from pg_stat_kcache ---
 
 	// ReadsBlks is the number of blocks read from disk (pg_stat_kcache).
@@ -49,6 +78,63 @@ type MetricSnapshot struct {
 
 	// HasKCacheData indicates if pg_stat_kcache data is available for this snapshot.
 	HasKCacheData bool `json:"has_kcache_data,omitempty"`
+
+	// Labels are the global labels merged with per-database overrides (set on slow SQL findings).
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// RankedBy is the rules.slow_sql.rank_by metric that listed this slow SQL
+	// finding. It is only set when several metrics are ranked.
+	RankedBy string `json:"ranked_by,omitempty"`
+
+	// Plan is the EXPLAIN output of the query on the monitored instance, set on
+	// the worst slow SQL findings with rules.slow_sql.include_explain.
+	Plan string `json:"plan,omitempty"`
+}
+
+// TruncatedQueryMarker ends query text cut to analysis.max_query_length.
+const TruncatedQueryMarker = "... (truncated)"
+
+// queryTextUnavailablePrefix starts the placeholder of statements without text.
+const queryTextUnavailablePrefix = "<query text unavailable: queryid "
+
+// QueryTextUnavailable returns the placeholder shown for a statement whose text
+// PoWA has not collected yet.
+func QueryTextUnavailable(queryID int64) string {
+	return fmt.Sprintf("%s%d>", queryTextUnavailablePrefix, queryID)
+}
+
+// IsQueryTextUnavailable reports whether query is a QueryTextUnavailable placeholder.
+func IsQueryTextUnavailable(query string) bool {
+	return strings.HasPrefix(query, queryTextUnavailablePrefix)
+}
+
+// TruncateQuery cuts Query to its first max bytes, without splitting a rune,
+// appends TruncatedQueryMarker and keeps the hash of the full text in
+// QueryHash. It does nothing when max <= 0, the text fits, or the text was
+// already truncated.
+func (m *MetricSnapshot) TruncateQuery(max int) {
+	if max <= 0 || len(m.Query) <= max || m.QueryHash != "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(m.Query))
+	m.QueryHash = hex.EncodeToString(sum[:])
+	for max > 0 && !utf8.RuneStart(m.Query[max]) {
+		max--
+	}
+	m.Query = m.Query[:max] + TruncatedQueryMarker
+}
+
+// UserLabel names the role of a query or summary: its name, or its oid when
+// the name is unknown. It is empty for rows that merge every role (userID 0).
+func UserLabel(userID int64, userName string) string {
+	switch {
+	case userName != "":
+		return userName
+	case userID != 0:
+		return fmt.Sprintf("role %d", userID)
+	default:
+		return ""
+	}
 }
 
 // TotalCPUTime returns the combined user and system CPU time.
@@ -56,6 +142,12 @@ func (m *MetricSnapshot) TotalCPUTime() float64 {
 	return m.UserCPUTime + m.SystemCPUTime
 }
 
+// TailTime estimates the tail execution time as the mean plus k standard
+// deviations.
+func (m *MetricSnapshot) TailTime(k float64) float64 {
+	return m.MeanTime + k*m.StddevTime
+}
+
 // IOTime returns a combined I/O metric based on read/write blocks.
 // This is a simplified metric; actual I/O time would require more context.
 func (m *MetricSnapshot) IOTime() float64 {
@@ -63,3 +155,46 @@ func (m *MetricSnapshot) IOTime() float64 {
 	// This is a placeholder calculation
 	return float64(m.ReadsBlks+m.WritesBlks) * 0.01
 }
+
+// TableStats is the latest pg_stat_all_tables snapshot of one table, as
+// collected by PoWA.
+type TableStats struct {
+	// DatabaseName is the database containing the table.
+	DatabaseName string `json:"database_name"`
+
+	// Schema is the schema containing the table.
+	Schema string `json:"schema"`
+
+	// Table is the table name.
+	Table string `json:"table"`
+
+	// LiveTuples and DeadTuples are the estimated live and dead row counts.
+	LiveTuples int64 `json:"n_live_tup"`
+	DeadTuples int64 `json:"n_dead_tup"`
+
+	// LastVacuum and LastAnalyze are the latest manual or automatic run; zero when never run.
+	LastVacuum  time.Time `json:"last_vacuum"`
+	LastAnalyze time.Time `json:"last_analyze"`
+}
+
+// WaitEventSample is the number of pg_wait_sampling samples one query spent on
+// one wait event during a window, as collected by PoWA.
+type WaitEventSample struct {
+	// QueryID is the unique identifier for the normalized query text.
+	QueryID int64 `json:"query_id"`
+
+	// SrvID is the PoWA server ID (0 for local/PoWA 3).
+	SrvID int `json:"srvid"`
+
+	// DatabaseName is the name of the database where the query waited.
+	DatabaseName string `json:"database_name"`
+
+	// EventType is the wait event type (e.g. "Lock", "IO", "LWLock").
+	EventType string `json:"event_type"`
+
+	// Event is the wait event name (e.g. "transactionid", "DataFileRead").
+	Event string `json:"event"`
+
+	// Samples is the number of samples taken in the window.
+	Samples int64 `json:"samples"`
+}
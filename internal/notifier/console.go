@@ -3,18 +3,71 @@ package notifier
 import (
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"math"
+	"os"
 	"strings"
 
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/format"
 	"github.com/powa-team/powa-sentinel/internal/model"
 )
 
 // ConsoleNotifier prints alerts to the console (useful for testing).
-type ConsoleNotifier struct{}
+type ConsoleNotifier struct {
+	out       io.Writer
+	color     bool // wrap severities in ANSI colors
+	units     format.Formatter
+	verbosity verbosity
+	queryURL  *queryURLTemplate
+}
 
-// NewConsoleNotifier creates a new console notifier.
-func NewConsoleNotifier() *ConsoleNotifier {
-	return &ConsoleNotifier{}
+// NewConsoleNotifier creates a new console notifier writing to
+// notifier.console.stream, stdout unless set to stderr.
+func NewConsoleNotifier(cfg *config.NotifierConfig) *ConsoleNotifier {
+	out := os.Stdout
+	if cfg.Console.Stream == config.ConsoleStreamStderr {
+		out = os.Stderr
+	}
+	return &ConsoleNotifier{
+		out:       out,
+		color:     useColor(cfg.Console.Color, out),
+		units:     format.New(cfg.DisplayPrecision()),
+		verbosity: parseVerbosity(cfg.Verbosity),
+		queryURL:  newQueryURLTemplate(cfg.QueryURLTemplate),
+	}
+}
+
+// useColor resolves notifier.console.color for f. Auto colors only a
+// terminal, and honors the NO_COLOR convention.
+func useColor(mode string, f *os.File) bool {
+	switch mode {
+	case config.ConsoleColorAlways:
+		return true
+	case config.ConsoleColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// severityColors are the ANSI SGR codes of each severity; info stays plain.
+var severityColors = map[string]string{
+	"low":      "36",   // cyan
+	"medium":   "33",   // yellow
+	"high":     "31",   // red
+	"critical": "1;31", // bold red
+}
+
+// severity renders a finding severity, colored when the console allows it.
+func (c *ConsoleNotifier) severity(s string) string {
+	if code, ok := severityColors[s]; ok && c.color {
+		return "\x1b[" + code + "m" + s + "\x1b[0m"
+	}
+	return s
 }
 
 // Name returns the notifier name.
@@ -24,6 +77,12 @@ func (c *ConsoleNotifier) Name() string {
 
 // Send prints the alert to the console.
 func (c *ConsoleNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	_, err := io.WriteString(c.out, c.format(alert))
+	return err
+}
+
+// format renders the alert as a console report at the notifier's verbosity.
+func (c *ConsoleNotifier) format(alert *model.AlertContext) string {
 	var sb strings.Builder
 
 	sb.WriteString("\n")
@@ -31,70 +90,363 @@ func (c *ConsoleNotifier) Send(ctx context.Context, alert *model.AlertContext) e
 	sb.WriteString("                    POWA SENTINEL REPORT                       \n")
 	sb.WriteString("═══════════════════════════════════════════════════════════════\n")
 	sb.WriteString(fmt.Sprintf("Report ID:    %s\n", alert.ReqID))
-	sb.WriteString(fmt.Sprintf("Timestamp:    %s\n", alert.Timestamp.Format("2006-01-02 15:04:05")))
+	sb.WriteString(fmt.Sprintf("Timestamp:    %s\n", alert.DisplayTime(alert.Timestamp, "2006-01-02 15:04:05")))
 	sb.WriteString(fmt.Sprintf("Health Score: %d/100 (%s)\n", alert.Summary.HealthScore, alert.Summary.HealthStatus))
+	if alert.Repository != "" {
+		sb.WriteString(fmt.Sprintf("Repository:   %s\n", alert.Repository))
+	}
+	if alert.DigestRuns > 0 {
+		sb.WriteString(fmt.Sprintf("Digest:       %d runs\n", alert.DigestRuns))
+	}
+	if len(alert.Labels) > 0 {
+		sb.WriteString(fmt.Sprintf("Labels:       %s\n", formatLabels(alert.Labels)))
+	}
 	sb.WriteString("───────────────────────────────────────────────────────────────\n")
 
 	sb.WriteString(fmt.Sprintf("Analysis Window:  %s ~ %s\n",
-		alert.AnalysisWindow.Start.Format("2006-01-02 15:04"),
-		alert.AnalysisWindow.End.Format("2006-01-02 15:04")))
+		alert.DisplayTime(alert.AnalysisWindow.Start, "2006-01-02 15:04"),
+		alert.DisplayTime(alert.AnalysisWindow.End, "2006-01-02 15:04")))
 	sb.WriteString(fmt.Sprintf("Baseline Window:  %s ~ %s\n",
-		alert.BaselineWindow.Start.Format("2006-01-02 15:04"),
-		alert.BaselineWindow.End.Format("2006-01-02 15:04")))
+		alert.DisplayTime(alert.BaselineWindow.Start, "2006-01-02 15:04"),
+		alert.DisplayTime(alert.BaselineWindow.End, "2006-01-02 15:04")))
 	sb.WriteString("───────────────────────────────────────────────────────────────\n")
 
 	sb.WriteString("\n📊 SUMMARY\n")
-	sb.WriteString(fmt.Sprintf("  • Queries Analyzed: %d\n", alert.Summary.TotalQueriesAnalyzed))
+	sb.WriteString(fmt.Sprintf("  • Queries Analyzed: %s\n", format.Count(int64(alert.Summary.TotalQueriesAnalyzed))))
 	sb.WriteString(fmt.Sprintf("  • Slow Queries:     %d\n", alert.Summary.SlowQueryCount))
 	sb.WriteString(fmt.Sprintf("  • Regressions:      %d\n", alert.Summary.RegressionCount))
+	if alert.Summary.NewQueryCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • New Queries:      %d\n", alert.Summary.NewQueryCount))
+	}
+	if alert.Summary.CallSpikeCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Call Spikes:      %d\n", alert.Summary.CallSpikeCount))
+	}
+	if alert.Summary.WaitEventCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Lock/IO Waits:    %d\n", alert.Summary.WaitEventCount))
+	}
+	if alert.Summary.FlappingCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Flapping Queries: %d\n", alert.Summary.FlappingCount))
+	}
+	if alert.Summary.CrossServerCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Slower Than Reference: %d\n", alert.Summary.CrossServerCount))
+	}
+	if alert.WorkloadGrowth != nil {
+		sb.WriteString(fmt.Sprintf("  • Workload Growth:  %s\n", c.units.Percent(alert.WorkloadGrowth.ChangePercent)))
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		sb.WriteString(fmt.Sprintf("  • Connections:      %d/%d (%.1f%%)\n", cs.Total, cs.MaxConnections, cs.UsagePercent))
+	}
+	if alert.Summary.VacuumCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Vacuum Needed:    %d\n", alert.Summary.VacuumCount))
+	}
 	sb.WriteString(fmt.Sprintf("  • Index Suggestions: %d\n", alert.Summary.SuggestionCount))
+	if alert.Summary.OmittedFindings > 0 {
+		sb.WriteString(fmt.Sprintf("  • Omitted (less significant): %d\n", alert.Summary.OmittedFindings))
+	}
+	if alert.Summary.CustomFindingCount > 0 {
+		sb.WriteString(fmt.Sprintf("  • Custom Findings:  %d\n", alert.Summary.CustomFindingCount))
+	}
+	if msg := formatEmpty(alert); msg != "" {
+		sb.WriteString(fmt.Sprintf("\n  %s\n", msg))
+	}
+	if alert.Truncated != nil {
+		sb.WriteString(fmt.Sprintf("\n  ⚠️ %s\n", alert.Truncated.Note()))
+	}
+
+	if c.verbosity == verbositySummary {
+		sb.WriteString("\n🔥 WORST FINDING\n")
+		if ref, ok := worstFinding(alert); ok {
+			sb.WriteString(fmt.Sprintf("  %s\n", formatFindingRef(ref, 60)))
+		} else {
+			sb.WriteString("  none\n")
+		}
+		sb.WriteString("\n═══════════════════════════════════════════════════════════════\n")
+		return sb.String()
+	}
+
+	if alert.StaleData != nil {
+		sb.WriteString(fmt.Sprintf("\n⚠ STALE DATA [%s]\n", c.severity(alert.StaleData.Severity)))
+		sb.WriteString(fmt.Sprintf("  %s\n", formatStaleData(alert.StaleData)))
+	}
+
+	if alert.Delta != nil {
+		// Delta mode replaces the per-rule lists with changes since the last run
+		sb.WriteString("\n🔁 CHANGES SINCE LAST RUN\n")
+		for _, section := range deltaSections(alert.Delta) {
+			sb.WriteString(fmt.Sprintf("  %s (%d):\n", section.title, len(section.refs)))
+			limit := c.verbosity.limit(20, len(section.refs))
+			for i, ref := range section.refs {
+				if i >= limit {
+					sb.WriteString(fmt.Sprintf("    ... and %d more\n", len(section.refs)-limit))
+					break
+				}
+				sb.WriteString(fmt.Sprintf("    %s %s\n", section.marker, formatFindingRef(ref, c.verbosity.textLen(60))))
+			}
+		}
+		sb.WriteString("\n═══════════════════════════════════════════════════════════════\n")
+		return sb.String()
+	}
+
+	if len(alert.TopDatabases) > 0 {
+		sb.WriteString("\n🗄 TOP DATABASES\n")
+		for i, d := range alert.TopDatabases {
+			serverInfo := d.DatabaseName
+			if d.ServerName != "" && d.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", d.ServerName, d.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%s] %s (%s of total, ×%s calls, %s queries)\n",
+				i+1, serverInfo, c.units.Duration(d.TotalTime), c.units.Number(d.TimePercent)+"%",
+				format.Count(d.Calls), format.Count(int64(d.QueryCount))))
+		}
+	}
+
+	if len(alert.TopUsers) > 0 {
+		sb.WriteString("\n👤 TOP USERS\n")
+		for i, u := range alert.TopUsers {
+			userInfo := model.UserLabel(u.UserID, u.UserName)
+			if u.ServerName != "" && u.ServerName != "local" {
+				userInfo = fmt.Sprintf("%s/%s", u.ServerName, userInfo)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%s] %s (%s of total, ×%s calls, %s queries)\n",
+				i+1, userInfo, c.units.Duration(u.TotalTime), c.units.Number(u.TimePercent)+"%",
+				format.Count(u.Calls), format.Count(int64(u.QueryCount))))
+		}
+	}
 
 	if len(alert.TopSlowSQL) > 0 {
 		sb.WriteString("\n⏱ TOP SLOW QUERIES\n")
-		for i, q := range alert.TopSlowSQL {
-			sb.WriteString(fmt.Sprintf("  %d. [%d] %.2fms (×%d calls)\n",
-				i+1, q.QueryID, q.TotalTime, q.Calls))
-			query := strings.Join(strings.Fields(q.Query), " ")
-			if len(query) > 60 {
-				query = query[:57] + "..."
-			}
-			sb.WriteString(fmt.Sprintf("      %s\n", query))
+	}
+	for _, group := range slowSQLGroups(alert.TopSlowSQL) {
+		if group[0].RankedBy != "" {
+			sb.WriteString(fmt.Sprintf("  %s:\n", rankingTitle(group[0].RankedBy)))
+		}
+		for i, q := range group {
+			sb.WriteString(fmt.Sprintf("  %d. [%d] %s (×%s calls)\n",
+				i+1, q.QueryID, c.units.Duration(q.TotalTime), format.Count(q.Calls)))
+			if c.verbosity == verbosityDetailed {
+				sb.WriteString(fmt.Sprintf("      mean %s", c.units.Duration(q.MeanTime)))
+				if q.HasKCacheData {
+					sb.WriteString(fmt.Sprintf(", cpu %s, %s blocks read, %s written", c.units.Duration(q.TotalCPUTime()),
+						format.Count(q.ReadsBlks), format.Count(q.WritesBlks)))
+				}
+				sb.WriteString("\n")
+			}
+			if user := model.UserLabel(q.UserID, q.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("      user %s\n", user))
+			}
+			c.writeLabels(&sb, q.Labels)
+			c.writeQueryURL(&sb, q.SrvID, q.ServerName, q.DatabaseName, q.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(q.Query, c.verbosity.textLen(60))))
+			if q.Plan != "" {
+				sb.WriteString("      plan:\n")
+				for _, line := range strings.Split(truncatePlan(q.Plan, c.verbosity.limit(10, math.MaxInt)), "\n") {
+					sb.WriteString(fmt.Sprintf("        %s\n", line))
+				}
+			}
 		}
 	}
 
 	if len(alert.Regressions) > 0 {
 		sb.WriteString("\n📈 REGRESSIONS\n")
-		count := 0
+		limit := c.verbosity.limit(20, len(alert.Regressions))
 		for i, r := range alert.Regressions {
-			if count >= 20 {
-				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.Regressions)-20))
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.Regressions)-limit))
 				break
 			}
-			count++
 			serverInfo := r.DatabaseName
 			if r.ServerName != "" && r.ServerName != "local" {
 				serverInfo = fmt.Sprintf("%s/%s", r.ServerName, r.DatabaseName)
 			}
-			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %.2fms → %.2fms (+%.1f%%) [%s]\n",
-				i+1, r.QueryID, serverInfo, r.BaselineMeanTime, r.CurrentMeanTime, r.ChangePercent, r.Severity))
-			query := strings.Join(strings.Fields(r.Query), " ")
-			if len(query) > 60 {
-				query = query[:57] + "..."
+			if r.IsNewQuery {
+				sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %s (new query, no baseline)\n",
+					i+1, r.QueryID, serverInfo, c.units.Duration(r.CurrentMeanTime)))
+			} else if r.CurrentTailTime > 0 {
+				sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] tail %s → %s (%s) [%s]\n",
+					i+1, r.QueryID, serverInfo, c.units.Duration(r.BaselineTailTime), c.units.Duration(r.CurrentTailTime),
+					c.units.Percent(r.ChangePercent), c.severity(r.Severity)))
+				sb.WriteString(fmt.Sprintf("      mean %s → %s\n", c.units.Duration(r.BaselineMeanTime), c.units.Duration(r.CurrentMeanTime)))
+			} else {
+				sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %s → %s (%s) [%s]\n",
+					i+1, r.QueryID, serverInfo, c.units.Duration(r.BaselineMeanTime), c.units.Duration(r.CurrentMeanTime),
+					c.units.Percent(r.ChangePercent), c.severity(r.Severity)))
+			}
+			if !r.FirstSeen.IsZero() {
+				sb.WriteString(fmt.Sprintf("      first seen %s\n", format.Age(alert.Timestamp.Sub(r.FirstSeen))))
+			}
+			if c.verbosity == verbosityDetailed {
+				sb.WriteString(fmt.Sprintf("      calls %s → %s\n", format.Count(r.BaselineCalls), format.Count(r.CurrentCalls)))
+			}
+			if user := model.UserLabel(r.UserID, r.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("      user %s\n", user))
+			}
+			c.writeLabels(&sb, r.Labels)
+			c.writeQueryURL(&sb, r.SrvID, r.ServerName, r.DatabaseName, r.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(r.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if len(alert.CallSpikes) > 0 {
+		sb.WriteString("\n📞 CALL SPIKES\n")
+		limit := c.verbosity.limit(20, len(alert.CallSpikes))
+		for i, s := range alert.CallSpikes {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.CallSpikes)-limit))
+				break
+			}
+			serverInfo := s.DatabaseName
+			if s.ServerName != "" && s.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", s.ServerName, s.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %s → %s calls (%s)\n",
+				i+1, s.QueryID, serverInfo, format.Count(s.BaselineCalls), format.Count(s.CurrentCalls),
+				c.units.Percent(s.ChangePercent)))
+			if user := model.UserLabel(s.UserID, s.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("      user %s\n", user))
+			}
+			c.writeLabels(&sb, s.Labels)
+			c.writeQueryURL(&sb, s.SrvID, s.ServerName, s.DatabaseName, s.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(s.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if len(alert.WaitEvents) > 0 {
+		sb.WriteString("\n⏳ LOCK/IO WAITS\n")
+		limit := c.verbosity.limit(20, len(alert.WaitEvents))
+		for i, we := range alert.WaitEvents {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.WaitEvents)-limit))
+				break
+			}
+			serverInfo := we.DatabaseName
+			if we.ServerName != "" && we.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", we.ServerName, we.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %s of %s waiting (%s), mostly %s:%s\n",
+				i+1, we.QueryID, serverInfo, c.units.Duration(we.WaitTime), c.units.Duration(we.TotalTime),
+				c.units.Percent(we.WaitPercent), we.DominantEventType, we.DominantEvent))
+			c.writeLabels(&sb, we.Labels)
+			c.writeQueryURL(&sb, we.SrvID, we.ServerName, we.DatabaseName, we.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(we.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if len(alert.Flapping) > 0 {
+		sb.WriteString("\n〰️ FLAPPING QUERIES\n")
+		limit := c.verbosity.limit(20, len(alert.Flapping))
+		for i, f := range alert.Flapping {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.Flapping)-limit))
+				break
+			}
+			serverInfo := f.DatabaseName
+			if f.ServerName != "" && f.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", f.ServerName, f.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] mean %s, CV %s over %d windows: %s\n",
+				i+1, f.QueryID, serverInfo, c.units.Duration(f.MeanTime), c.units.Number(f.CV), len(f.Series),
+				formatSeries(c.units, f.Series)))
+			c.writeLabels(&sb, f.Labels)
+			c.writeQueryURL(&sb, f.SrvID, f.ServerName, f.DatabaseName, f.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(f.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if len(alert.CrossServer) > 0 {
+		sb.WriteString("\n🔀 SLOWER THAN REFERENCE SERVER\n")
+		limit := c.verbosity.limit(20, len(alert.CrossServer))
+		for i, x := range alert.CrossServer {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.CrossServer)-limit))
+				break
+			}
+			serverInfo := x.DatabaseName
+			if x.ServerName != "" && x.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", x.ServerName, x.DatabaseName)
 			}
-			sb.WriteString(fmt.Sprintf("      %s\n", query))
+			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] %s vs %s on %s (%s)\n",
+				i+1, x.QueryID, serverInfo, c.units.Duration(x.MeanTime), c.units.Duration(x.ReferenceMeanTime),
+				x.ReferenceServerName, c.units.Percent(x.ChangePercent)))
+			c.writeLabels(&sb, x.Labels)
+			c.writeQueryURL(&sb, x.SrvID, x.ServerName, x.DatabaseName, x.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(x.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if wg := alert.WorkloadGrowth; wg != nil {
+		sb.WriteString("\n📈 WORKLOAD GROWTH\n")
+		sb.WriteString(fmt.Sprintf("  Total time %s → %s (%s per second of window), calls %s → %s\n",
+			c.units.Duration(wg.BaselineTotalTime), c.units.Duration(wg.CurrentTotalTime), c.units.Percent(wg.ChangePercent),
+			format.Count(wg.BaselineCalls), format.Count(wg.CurrentCalls)))
+		for i, x := range wg.TopContributors {
+			serverInfo := x.DatabaseName
+			if x.ServerName != "" && x.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", x.ServerName, x.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%d] [%s] +%s (%s → %s)\n",
+				i+1, x.QueryID, serverInfo, c.units.Duration(x.AddedTime),
+				c.units.Duration(x.BaselineTotalTime), c.units.Duration(x.CurrentTotalTime)))
+			c.writeQueryURL(&sb, x.SrvID, x.ServerName, x.DatabaseName, x.QueryID)
+			sb.WriteString(fmt.Sprintf("      %s\n", truncateQuery(x.Query, c.verbosity.textLen(60))))
+		}
+	}
+
+	if cs := alert.ConnectionSaturation; cs != nil {
+		sb.WriteString("\n🔌 CONNECTION SATURATION\n")
+		sb.WriteString(fmt.Sprintf("  %s\n", formatConnectionSaturation(cs)))
+	}
+
+	if len(alert.Vacuum) > 0 {
+		sb.WriteString("\n🧹 VACUUM/ANALYZE NEEDED\n")
+		limit := c.verbosity.limit(20, len(alert.Vacuum))
+		for i, v := range alert.Vacuum {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(alert.Vacuum)-limit))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("  %d. [%s] %s\n", i+1, v.DatabaseName, formatVacuum(alert, v)))
+			c.writeLabels(&sb, v.Labels)
 		}
 	}
 
 	if len(alert.Suggestions) > 0 {
-		sb.WriteString("\n💡 INDEX SUGGESTIONS\n")
+		sb.WriteString("\n💡 INDEX SUGGESTIONS (advisory DDL, review before running)\n")
 		for i, s := range alert.Suggestions {
-			sb.WriteString(fmt.Sprintf("  %d. %s (%s) - Est. +%.0f%%\n",
-				i+1, s.FullTableName(), strings.Join(s.Columns, ", "), s.EstImprovementPercent))
+			sb.WriteString(fmt.Sprintf("  %d. %s (%s) - Est. %s, %s queries\n",
+				i+1, s.FullTableName(), strings.Join(s.Columns, ", "), c.units.Percent(s.EstImprovementPercent),
+				format.Count(int64(s.AffectedQueries))))
+			for _, ex := range s.Examples {
+				sb.WriteString(fmt.Sprintf("      example: %s\n", formatColumnExample(ex, c.units)))
+			}
+			if s.SuggestedDDL != "" {
+				sb.WriteString(fmt.Sprintf("      %s\n", s.SuggestedDDL))
+			}
+		}
+	}
+
+	if len(alert.CustomFindings) > 0 {
+		sb.WriteString("\n🧩 CUSTOM RULES\n")
+		for i, f := range alert.CustomFindings {
+			sb.WriteString(fmt.Sprintf("  %d. [%s] %s = %s (%s %s) [%s]\n",
+				i+1, f.Rule, f.Column, c.units.Number(f.Value), f.Operator, c.units.Number(f.Threshold), c.severity(f.Severity)))
+			sb.WriteString(fmt.Sprintf("      %s\n", formatCustomRow(f)))
 		}
 	}
 
 	sb.WriteString("\n═══════════════════════════════════════════════════════════════\n")
+	return sb.String()
+}
+
+// writeQueryURL adds the finding's notifier.query_url_template link.
+func (c *ConsoleNotifier) writeQueryURL(sb *strings.Builder, srvID int, server, database string, queryID int64) {
+	if link := c.queryURL.render(srvID, server, database, queryID); link != "" {
+		sb.WriteString(fmt.Sprintf("      🔗 %s\n", link))
+	}
+}
 
-	log.Print(sb.String())
-	return nil
+// writeLabels lists a finding's labels in detailed output.
+func (c *ConsoleNotifier) writeLabels(sb *strings.Builder, labels map[string]string) {
+	if c.verbosity == verbosityDetailed && len(labels) > 0 {
+		sb.WriteString(fmt.Sprintf("      labels: %s\n", formatLabels(labels)))
+	}
 }
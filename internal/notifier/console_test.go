@@ -0,0 +1,156 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestNewConsoleNotifier_Stream(t *testing.T) {
+	tests := []struct {
+		stream string
+		want   *os.File
+	}{
+		{"", os.Stdout},
+		{config.ConsoleStreamStdout, os.Stdout},
+		{config.ConsoleStreamStderr, os.Stderr},
+	}
+	for _, tt := range tests {
+		c := NewConsoleNotifier(&config.NotifierConfig{Console: config.ConsoleConfig{Stream: tt.stream}})
+		if c.out != tt.want {
+			t.Errorf("stream %q writes to %v, want %v", tt.stream, c.out, tt.want.Name())
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	// A regular file stands for a pipe or redirect: never a terminal
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		mode string
+		want bool
+	}{
+		{config.ConsoleColorAuto, false},
+		{"", false},
+		{config.ConsoleColorNever, false},
+		{config.ConsoleColorAlways, true},
+	}
+	for _, tt := range tests {
+		if got := useColor(tt.mode, f); got != tt.want {
+			t.Errorf("useColor(%q, file) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestConsoleNotifier_SuggestionExamples(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID: "r1",
+		Suggestions: []model.IndexSuggestion{{
+			Schema: "public", Table: "orders", Columns: []string{"status", "created_at"}, EstImprovementPercent: 60, AffectedQueries: 4,
+			Examples: []model.ColumnExample{
+				{Column: "status", Values: []string{"'new'", "'open'"}, Selectivity: 0.02},
+				{Column: "created_at", Values: []string{"'2026-01-01'"}},
+			},
+		}},
+		Summary: model.AlertSummary{SuggestionCount: 1},
+	}
+
+	got := NewConsoleNotifier(&config.NotifierConfig{}).format(alert)
+	for _, want := range []string{
+		"example: status = 'new', 'open' (keeps 2.00% of rows)\n",
+		"example: created_at = '2026-01-01'\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConsoleNotifier_SlowSQLRankings(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID: "r1",
+		TopSlowSQL: []model.MetricSnapshot{
+			{QueryID: 1, TotalTime: 1000, Calls: 10, Query: "SELECT 1", RankedBy: "total_time"},
+			{QueryID: 2, TotalTime: 800, Calls: 2, Query: "SELECT 2", RankedBy: "total_time"},
+			{QueryID: 3, TotalTime: 100, Calls: 1, Query: "SELECT 3", RankedBy: "mean_time"},
+		},
+		Summary: model.AlertSummary{SlowQueryCount: 3},
+	}
+
+	got := NewConsoleNotifier(&config.NotifierConfig{}).format(alert)
+	total, mean := strings.Index(got, "  By total time:\n  1. [1]"), strings.Index(got, "  By mean time:\n  1. [3]")
+	if total < 0 || mean < total {
+		t.Errorf("want a numbered sub-section per ranking, total time first:\n%s", got)
+	}
+	if strings.Count(got, "TOP SLOW QUERIES") != 1 {
+		t.Errorf("want one slow query section:\n%s", got)
+	}
+}
+
+func TestConsoleNotifier_SlowSQLPlan(t *testing.T) {
+	plan := strings.TrimSuffix(strings.Repeat("  ->  Seq Scan on t\n", 12), "\n")
+	alert := &model.AlertContext{
+		ReqID:      "r1",
+		TopSlowSQL: []model.MetricSnapshot{{QueryID: 1, TotalTime: 1000, Calls: 10, Query: "SELECT * FROM t", Plan: plan}},
+		Summary:    model.AlertSummary{SlowQueryCount: 1},
+	}
+
+	got := NewConsoleNotifier(&config.NotifierConfig{}).format(alert)
+	if !strings.Contains(got, "      plan:\n          ->  Seq Scan on t\n") || !strings.Contains(got, "... (2 more lines)") {
+		t.Errorf("want the plan's first 10 lines, indented:\n%s", got)
+	}
+	got = NewConsoleNotifier(&config.NotifierConfig{Verbosity: config.VerbosityDetailed}).format(alert)
+	if strings.Count(got, "Seq Scan on t") != 12 {
+		t.Errorf("want the whole plan when detailed:\n%s", got)
+	}
+}
+
+func TestConsoleNotifier_TopUsers(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID:      "r1",
+		TopUsers:   []model.UserSummary{{UserID: 10, UserName: "app", TotalTime: 1000, Calls: 10, QueryCount: 2, TimePercent: 75}, {UserID: 16384, ServerName: "remote", TotalTime: 300, Calls: 3, QueryCount: 1}},
+		TopSlowSQL: []model.MetricSnapshot{{QueryID: 1, TotalTime: 1000, Calls: 10, Query: "SELECT 1", UserID: 10, UserName: "app"}},
+		Summary:    model.AlertSummary{SlowQueryCount: 1},
+	}
+
+	got := NewConsoleNotifier(&config.NotifierConfig{}).format(alert)
+	for _, want := range []string{"👤 TOP USERS", "1. [app]", "2. [remote/role 16384]", "      user app\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConsoleNotifier_SeverityColor(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID:       "r1",
+		Regressions: []model.RegressionItem{{QueryID: 1, DatabaseName: "app", BaselineMeanTime: 10, CurrentMeanTime: 100, ChangePercent: 900, Severity: "critical"}},
+		Summary:     model.AlertSummary{RegressionCount: 1},
+	}
+
+	var out bytes.Buffer
+	c := NewConsoleNotifier(&config.NotifierConfig{})
+	c.out = &out
+	if err := c.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "[critical]") || strings.Contains(got, "\x1b[") {
+		t.Errorf("uncolored output should carry the plain severity and no escape codes:\n%s", got)
+	}
+
+	c.color = true
+	if got := c.format(alert); !strings.Contains(got, "[\x1b[1;31mcritical\x1b[0m]") {
+		t.Errorf("colored output missing the bold red severity:\n%q", got)
+	}
+}
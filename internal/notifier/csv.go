@@ -0,0 +1,154 @@
+package notifier
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// csvHeader lists the columns written by CSVNotifier.
+var csvHeader = []string{"rule", "severity", "database", "server", "queryid", "metric_before", "metric_after", "query", "labels"}
+
+// CSVNotifier writes findings as CSV rows for spreadsheets.
+type CSVNotifier struct {
+	path string
+	out  io.Writer // used when path is empty; defaults to stdout
+}
+
+// NewCSVNotifier creates a new CSV notifier. Rows are written to cfg.File.Path
+// (truncated on every run) or to stdout when no path is configured.
+func NewCSVNotifier(cfg *config.NotifierConfig) *CSVNotifier {
+	return &CSVNotifier{
+		path: cfg.File.Path,
+		out:  os.Stdout,
+	}
+}
+
+// Name returns the notifier name.
+func (c *CSVNotifier) Name() string {
+	return "csv"
+}
+
+// Send writes the alert's findings as CSV.
+func (c *CSVNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	out := c.out
+	if c.path != "" {
+		f, err := os.Create(c.path)
+		if err != nil {
+			return fmt.Errorf("creating csv file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := writeCSV(out, alert); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	return nil
+}
+
+// writeCSV renders one row per finding. encoding/csv quotes fields containing
+// commas, quotes or newlines, so multiline query text stays in a single cell.
+func writeCSV(out io.Writer, alert *model.AlertContext) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, q := range alert.TopSlowSQL {
+		w.Write([]string{
+			"slow_sql", "", q.DatabaseName, q.ServerName, strconv.FormatInt(q.QueryID, 10),
+			"", formatFloat(q.TotalTime), q.Query, formatLabels(q.Labels),
+		})
+	}
+	for _, r := range alert.Regressions {
+		rule, before := "regression", formatFloat(r.BaselineMeanTime)
+		if r.IsNewQuery {
+			rule, before = "new_query", ""
+		}
+		w.Write([]string{
+			rule, r.Severity, r.DatabaseName, r.ServerName, strconv.FormatInt(r.QueryID, 10),
+			before, formatFloat(r.CurrentMeanTime), r.Query, formatLabels(r.Labels),
+		})
+	}
+	for _, s := range alert.CallSpikes {
+		w.Write([]string{
+			"call_spike", "", s.DatabaseName, s.ServerName, strconv.FormatInt(s.QueryID, 10),
+			strconv.FormatInt(s.BaselineCalls, 10), strconv.FormatInt(s.CurrentCalls, 10), s.Query, formatLabels(s.Labels),
+		})
+	}
+	for _, we := range alert.WaitEvents {
+		w.Write([]string{
+			"wait_events", "", we.DatabaseName, we.ServerName, strconv.FormatInt(we.QueryID, 10),
+			formatFloat(we.TotalTime), formatFloat(we.WaitTime), we.Query, formatLabels(we.Labels),
+		})
+	}
+	for _, f := range alert.Flapping {
+		w.Write([]string{
+			"flapping", "", f.DatabaseName, f.ServerName, strconv.FormatInt(f.QueryID, 10),
+			formatFloat(f.MeanTime), formatFloat(f.CV), f.Query, formatLabels(f.Labels),
+		})
+	}
+	for _, c := range alert.CrossServer {
+		w.Write([]string{
+			"cross_server", "", c.DatabaseName, c.ServerName, strconv.FormatInt(c.QueryID, 10),
+			formatFloat(c.ReferenceMeanTime), formatFloat(c.MeanTime), c.Query, formatLabels(c.Labels),
+		})
+	}
+	if wg := alert.WorkloadGrowth; wg != nil {
+		w.Write([]string{
+			"workload_growth", "", "", "", "",
+			formatFloat(wg.BaselineTotalTime), formatFloat(wg.CurrentTotalTime), formatWorkloadGrowth(wg), formatLabels(alert.Labels),
+		})
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		w.Write([]string{
+			"connection_saturation", "", "", "", "",
+			strconv.Itoa(cs.MaxConnections), strconv.Itoa(cs.Total), formatConnectionSaturation(cs), formatLabels(alert.Labels),
+		})
+	}
+	for _, v := range alert.Vacuum {
+		w.Write([]string{
+			"vacuum", "", v.DatabaseName, "", "",
+			formatFloat(v.DeadRatioPercent), strconv.FormatInt(v.DeadTuples, 10),
+			fmt.Sprintf("%s (%s)", v.FullTableName(), strings.Join(v.Reasons, ", ")), formatLabels(v.Labels),
+		})
+	}
+	for _, s := range alert.Suggestions {
+		w.Write([]string{
+			"index_suggestion", "", "", "", "",
+			"", formatFloat(s.EstImprovementPercent),
+			fmt.Sprintf("%s (%s)", s.FullTableName(), strings.Join(s.Columns, ", ")),
+			formatLabels(alert.Labels),
+		})
+	}
+
+	for _, f := range alert.CustomFindings {
+		w.Write([]string{
+			"custom:" + f.Rule, f.Severity, "", "", "",
+			formatFloat(f.Threshold), formatFloat(f.Value), formatCustomRow(f), formatLabels(alert.Labels),
+		})
+	}
+
+	if s := alert.StaleData; s != nil {
+		w.Write([]string{
+			"stale_data", s.Severity, "", "", "",
+			s.MaxAge.String(), s.Age.String(), formatStaleData(s), formatLabels(alert.Labels),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// formatFloat renders a metric without trailing zeros.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
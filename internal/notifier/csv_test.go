@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func testCSVAlert() *model.AlertContext {
+	return &model.AlertContext{
+		Labels: map[string]string{"env": "prod"},
+		TopSlowSQL: []model.MetricSnapshot{
+			{QueryID: 1, Query: "SELECT \"a\", b\nFROM t", DatabaseName: "app", ServerName: "local", TotalTime: 1234.5,
+				Labels: map[string]string{"env": "prod", "team": "payments"}},
+		},
+		Regressions: []model.RegressionItem{
+			{QueryID: 2, Query: "UPDATE t SET x = $1,\n  y = $2", DatabaseName: "app", ServerName: "srv1",
+				BaselineMeanTime: 10, CurrentMeanTime: 25.5, Severity: "medium"},
+		},
+		Suggestions: []model.IndexSuggestion{
+			{Table: "orders", Schema: "sales", Columns: []string{"customer_id", "created_at"}, EstImprovementPercent: 40},
+		},
+	}
+}
+
+func TestCSVNotifier_Send(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewCSVNotifier(&config.NotifierConfig{})
+	n.out = &buf
+
+	if err := n.Send(context.Background(), testCSVAlert()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header + 3 rows, got %d", len(records))
+	}
+
+	expected := [][]string{
+		csvHeader,
+		{"slow_sql", "", "app", "local", "1", "", "1234.5", "SELECT \"a\", b\nFROM t", "env=prod team=payments"},
+		{"regression", "medium", "app", "srv1", "2", "10", "25.5", "UPDATE t SET x = $1,\n  y = $2", ""},
+		{"index_suggestion", "", "", "", "", "", "40", "sales.orders (customer_id, created_at)", "env=prod"},
+	}
+	for i, want := range expected {
+		for j := range want {
+			if records[i][j] != want[j] {
+				t.Errorf("row %d col %d = %q, want %q", i, j, records[i][j], want[j])
+			}
+		}
+	}
+}
+
+func TestCSVNotifier_WritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "findings.csv")
+	n := NewCSVNotifier(&config.NotifierConfig{File: config.FileNotifierConfig{Path: path}})
+
+	if err := n.Send(context.Background(), testCSVAlert()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Errorf("expected 4 records, got %d", len(records))
+	}
+}
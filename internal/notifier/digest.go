@@ -0,0 +1,210 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// DigestingNotifier wraps a Notifier and buffers each run's alert, sending one
+// consolidated digest once interval has passed since the first buffered run.
+// Alerts with a finding at or above the breakthrough severity are also sent
+// right away, and still counted in the digest; an empty breakthrough holds
+// every alert back until the digest.
+type DigestingNotifier struct {
+	inner        Notifier
+	interval     time.Duration
+	breakthrough string
+	now          func() time.Time
+
+	mu      sync.Mutex
+	pending []*model.AlertContext
+	started time.Time // when the first pending run was buffered
+}
+
+// NewDigestingNotifier wraps inner. Buffered runs are kept in memory only, so a
+// restart drops the digest in progress.
+func NewDigestingNotifier(inner Notifier, interval time.Duration, breakthrough string) *DigestingNotifier {
+	return &DigestingNotifier{
+		inner:        inner,
+		interval:     interval,
+		breakthrough: breakthrough,
+		now:          time.Now,
+	}
+}
+
+// Name returns the wrapped notifier's name.
+func (d *DigestingNotifier) Name() string {
+	return d.inner.Name() + " (digest)"
+}
+
+// Send buffers the alert and sends the digest when the interval is over. A
+// digest that fails to send stays buffered and is retried with the next run.
+func (d *DigestingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	now := d.now()
+
+	var breakErr error
+	if ref, ok := d.breaksThrough(alert); ok {
+		log.Printf("%s finding breaks through the digest: %s (report %s)", ref.Severity, ref.Rule, alert.ReqID)
+		if err := d.inner.Send(ctx, alert); err != nil {
+			breakErr = fmt.Errorf("sending breakthrough alert: %w", err)
+		}
+	}
+
+	d.mu.Lock()
+	if len(d.pending) == 0 {
+		d.started = now
+	}
+	d.pending = append(d.pending, alert)
+	started := d.started
+	runs := d.pending
+	d.mu.Unlock()
+
+	if now.Sub(started) < d.interval {
+		log.Printf("Buffered report %s for the digest (%d run(s) since %s)",
+			alert.ReqID, len(runs), started.Format(time.RFC3339))
+		return breakErr
+	}
+
+	if err := d.inner.Send(ctx, digestAlert(runs)); err != nil {
+		return errors.Join(breakErr, fmt.Errorf("sending digest of %d runs: %w", len(runs), err))
+	}
+	d.mu.Lock()
+	d.pending = d.pending[len(runs):]
+	if len(d.pending) > 0 {
+		d.started = now
+	}
+	d.mu.Unlock()
+	return breakErr
+}
+
+// breaksThrough returns the alert's worst finding when it reaches the
+// breakthrough severity.
+func (d *DigestingNotifier) breaksThrough(alert *model.AlertContext) (model.FindingRef, bool) {
+	if d.breakthrough == "" {
+		return model.FindingRef{}, false
+	}
+	ref, ok := worstFinding(alert)
+	if !ok || model.SeverityRank(ref.Severity) < model.SeverityRank(d.breakthrough) {
+		return model.FindingRef{}, false
+	}
+	return ref, true
+}
+
+// digestAlert consolidates runs, oldest first, into one alert. Every finding
+// seen in any run is listed once, with the metrics of its latest run; the slow
+// SQL and database rankings, raw metrics, labels and stale data check are
+// those of the latest run. The health score is the worst of all runs.
+func digestAlert(runs []*model.AlertContext) *model.AlertContext {
+	first, last := runs[0], runs[len(runs)-1]
+	out := &model.AlertContext{
+		ReqID:           last.ReqID,
+		ReportType:      "digest",
+		Timestamp:       last.Timestamp,
+		AnalysisWindow:  model.TimeWindow{Start: first.AnalysisWindow.Start, End: last.AnalysisWindow.End},
+		BaselineWindow:  last.BaselineWindow,
+		DatabaseName:    last.DatabaseName,
+		Repository:      last.Repository,
+		TopSlowSQL:      last.TopSlowSQL,
+		TopDatabases:    last.TopDatabases,
+		TopUsers:        last.TopUsers,
+		RawMetrics:      last.RawMetrics,
+		StaleData:       last.StaleData,
+		Labels:          last.Labels,
+		DisplayLocation: last.DisplayLocation,
+		DigestRuns:      len(runs),
+	}
+
+	worst := last.Summary
+	noData := true
+	for _, r := range runs {
+		out.Regressions = mergeFindings(out.Regressions, r.Regressions, func(x *model.RegressionItem) string {
+			return fmt.Sprintf("%t/%d/%s/%s/%d/%s", x.IsNewQuery, x.QueryID, x.ServerName, x.DatabaseName, x.UserID, x.Query)
+		})
+		out.CallSpikes = mergeFindings(out.CallSpikes, r.CallSpikes, func(x *model.CallSpikeItem) string {
+			return fmt.Sprintf("%d/%s/%s/%d/%s", x.QueryID, x.ServerName, x.DatabaseName, x.UserID, x.Query)
+		})
+		out.WaitEvents = mergeFindings(out.WaitEvents, r.WaitEvents, func(x *model.WaitEventItem) string {
+			return fmt.Sprintf("%d/%s/%s/%s", x.QueryID, x.ServerName, x.DatabaseName, x.Query)
+		})
+		out.Flapping = mergeFindings(out.Flapping, r.Flapping, func(x *model.FlappingItem) string {
+			return fmt.Sprintf("%d/%s/%s/%s", x.QueryID, x.ServerName, x.DatabaseName, x.Query)
+		})
+		out.CrossServer = mergeFindings(out.CrossServer, r.CrossServer, func(x *model.CrossServerItem) string {
+			return fmt.Sprintf("%d/%s/%s/%s", x.QueryID, x.ServerName, x.DatabaseName, x.Query)
+		})
+		out.Vacuum = mergeFindings(out.Vacuum, r.Vacuum, func(x *model.VacuumItem) string {
+			return x.DatabaseName + "/" + x.FullTableName()
+		})
+		out.Suggestions = mergeFindings(out.Suggestions, r.Suggestions, func(x *model.IndexSuggestion) string {
+			return x.FullTableName() + "/" + strings.Join(x.Columns, ",")
+		})
+		out.CustomFindings = mergeFindings(out.CustomFindings, r.CustomFindings, func(x *model.CustomFinding) string {
+			return x.Rule + "/" + formatCustomRow(*x)
+		})
+		if r.WorkloadGrowth != nil {
+			out.WorkloadGrowth = r.WorkloadGrowth
+		}
+		if r.ConnectionSaturation != nil {
+			out.ConnectionSaturation = r.ConnectionSaturation
+		}
+		if r.Summary.HealthScore < worst.HealthScore {
+			worst = r.Summary
+		}
+		noData = noData && r.Summary.Empty == model.EmptyNoData
+	}
+
+	out.Summary = model.AlertSummary{
+		TotalQueriesAnalyzed: last.Summary.TotalQueriesAnalyzed,
+		SlowQueryCount:       len(out.TopSlowSQL),
+		SuggestionCount:      len(out.Suggestions),
+		CallSpikeCount:       len(out.CallSpikes),
+		WaitEventCount:       len(out.WaitEvents),
+		FlappingCount:        len(out.Flapping),
+		CrossServerCount:     len(out.CrossServer),
+		VacuumCount:          len(out.Vacuum),
+		CustomFindingCount:   len(out.CustomFindings),
+		HealthScore:          worst.HealthScore,
+		HealthStatus:         worst.HealthStatus,
+	}
+	for _, r := range out.Regressions {
+		if r.IsNewQuery {
+			out.Summary.NewQueryCount++
+		} else {
+			out.Summary.RegressionCount++
+		}
+	}
+	if len(findingRefs(out)) == 0 {
+		out.Summary.Empty = model.EmptyNoFindings
+		if noData {
+			out.Summary.Empty = model.EmptyNoData
+		}
+	}
+	return out
+}
+
+// mergeFindings appends the items of a later run to merged. An item already
+// merged under the same key is replaced in place, so each finding keeps the
+// position of its first run and the metrics of its latest.
+func mergeFindings[T any](merged, items []T, key func(*T) string) []T {
+	index := make(map[string]int, len(merged))
+	for i := range merged {
+		index[key(&merged[i])] = i
+	}
+	for i := range items {
+		k := key(&items[i])
+		if j, ok := index[k]; ok {
+			merged[j] = items[i]
+			continue
+		}
+		index[k] = len(merged)
+		merged = append(merged, items[i])
+	}
+	return merged
+}
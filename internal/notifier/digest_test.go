@@ -0,0 +1,151 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func testDigestRun(reqID string, start time.Time, score int, regressions ...model.RegressionItem) *model.AlertContext {
+	return &model.AlertContext{
+		ReqID:          reqID,
+		Timestamp:      start.Add(time.Hour),
+		AnalysisWindow: model.TimeWindow{Start: start, End: start.Add(time.Hour)},
+		TopSlowSQL:     []model.MetricSnapshot{{QueryID: 9, DatabaseName: "app", Query: reqID}},
+		Regressions:    regressions,
+		Summary:        model.AlertSummary{HealthScore: score, HealthStatus: "healthy"},
+	}
+}
+
+func TestDigestingNotifier_BuffersUntilInterval(t *testing.T) {
+	inner := &recordingNotifier{}
+	n := NewDigestingNotifier(inner, 3*time.Hour, "")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(time.Hour)
+	n.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	reg := func(queryID int64, mean float64, severity string) model.RegressionItem {
+		return model.RegressionItem{QueryID: queryID, DatabaseName: "app", CurrentMeanTime: mean, Severity: severity}
+	}
+	runs := []*model.AlertContext{
+		testDigestRun("r1", start, 90, reg(1, 20, "medium")),
+		testDigestRun("r2", start.Add(time.Hour), 60, reg(1, 40, "high"), reg(2, 30, "medium")),
+		testDigestRun("r3", start.Add(2*time.Hour), 95),
+	}
+	for i, run := range runs[:2] {
+		if err := n.Send(ctx, run); err != nil {
+			t.Fatalf("Send(%s) error = %v", run.ReqID, err)
+		}
+		if len(inner.alerts) != 0 {
+			t.Fatalf("run %d within the interval was sent: %+v", i+1, inner.alerts)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	// The third run reaches the interval and flushes all three
+	now = now.Add(time.Hour)
+	if err := n.Send(ctx, runs[2]); err != nil {
+		t.Fatalf("Send(r3) error = %v", err)
+	}
+	if len(inner.alerts) != 1 {
+		t.Fatalf("sent %d alerts at the interval boundary, want 1 digest", len(inner.alerts))
+	}
+	d := inner.alerts[0]
+	if d.ReportType != "digest" || d.DigestRuns != 3 || d.ReqID != "r3" {
+		t.Errorf("digest header = %s/%d/%s, want digest/3/r3", d.ReportType, d.DigestRuns, d.ReqID)
+	}
+	if d.AnalysisWindow.Start != start || d.AnalysisWindow.End != start.Add(3*time.Hour) {
+		t.Errorf("digest window = %+v, want the three runs' windows", d.AnalysisWindow)
+	}
+	// Query 1 regressed in two runs and is listed once, as of its latest run
+	if len(d.Regressions) != 2 || d.Regressions[0].QueryID != 1 || d.Regressions[0].CurrentMeanTime != 40 || d.Regressions[1].QueryID != 2 {
+		t.Errorf("digest regressions = %+v, want queries 1 (40ms) and 2", d.Regressions)
+	}
+	if d.Summary.RegressionCount != 2 || d.Summary.HealthScore != 60 {
+		t.Errorf("digest summary = %+v, want 2 regressions and the worst health score 60", d.Summary)
+	}
+	if len(d.TopSlowSQL) != 1 || d.TopSlowSQL[0].Query != "r3" {
+		t.Errorf("digest slow SQL = %+v, want the latest run's ranking", d.TopSlowSQL)
+	}
+
+	// The next run starts a new interval
+	now = now.Add(time.Hour)
+	if err := n.Send(ctx, testDigestRun("r4", start.Add(4*time.Hour), 100)); err != nil {
+		t.Fatalf("Send(r4) error = %v", err)
+	}
+	if len(inner.alerts) != 1 {
+		t.Errorf("run after the digest was sent right away, sent=%d", len(inner.alerts))
+	}
+}
+
+func TestDigestAlert_KeepsEachRole(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	reg := func(userID int64, mean float64) model.RegressionItem {
+		return model.RegressionItem{QueryID: 1, DatabaseName: "app", UserID: userID, CurrentMeanTime: mean}
+	}
+	d := digestAlert([]*model.AlertContext{
+		testDigestRun("r1", start, 90, reg(10, 20), reg(20, 30)),
+		testDigestRun("r2", start.Add(time.Hour), 90, reg(10, 25)),
+	})
+
+	// With analysis.group_by_user the query regressed for two roles: both stay listed
+	if len(d.Regressions) != 2 || d.Regressions[0].UserID != 10 || d.Regressions[0].CurrentMeanTime != 25 ||
+		d.Regressions[1].UserID != 20 {
+		t.Errorf("digest regressions = %+v, want role 10 (25ms) and role 20", d.Regressions)
+	}
+}
+
+func TestDigestingNotifier_Breakthrough(t *testing.T) {
+	inner := &recordingNotifier{}
+	n := NewDigestingNotifier(inner, 24*time.Hour, "critical")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return start }
+	ctx := context.Background()
+
+	if err := n.Send(ctx, testDigestRun("high", start, 70, model.RegressionItem{QueryID: 1, Severity: "high"})); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 0 {
+		t.Fatalf("high finding broke through a critical threshold")
+	}
+	if err := n.Send(ctx, testDigestRun("critical", start, 40, model.RegressionItem{QueryID: 2, Severity: "critical"})); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 1 || inner.alerts[0].ReqID != "critical" || inner.alerts[0].DigestRuns != 0 {
+		t.Fatalf("critical finding should be sent right away as is, got %+v", inner.alerts)
+	}
+	if got := len(n.pending); got != 2 {
+		t.Errorf("pending runs = %d, want 2: breakthrough alerts stay in the digest", got)
+	}
+}
+
+func TestDigestingNotifier_FailedDigestStaysBuffered(t *testing.T) {
+	inner := &recordingNotifier{err: errors.New("webhook down")}
+	n := NewDigestingNotifier(inner, time.Hour, "")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	n.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	n.Send(ctx, testDigestRun("r1", start, 90))
+	now = now.Add(time.Hour)
+	if err := n.Send(ctx, testDigestRun("r2", start.Add(time.Hour), 90)); err == nil {
+		t.Fatal("Send() error = nil, want the digest failure")
+	}
+
+	inner.err = nil
+	now = now.Add(time.Hour)
+	if err := n.Send(ctx, testDigestRun("r3", start.Add(2*time.Hour), 90)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if last := inner.alerts[len(inner.alerts)-1]; last.DigestRuns != 3 {
+		t.Errorf("retried digest covers %d runs, want 3", last.DigestRuns)
+	}
+	if len(n.pending) != 0 {
+		t.Errorf("pending runs after the digest = %d, want 0", len(n.pending))
+	}
+}
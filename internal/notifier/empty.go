@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// NoDataSkippingNotifier wraps a Notifier and skips alerts for analysis windows
+// without any statements, which is the default unless notifier.send_on_empty
+// is set. Windows with data but no findings are still sent: they carry the
+// slow query ranking and say that all is quiet.
+type NoDataSkippingNotifier struct {
+	inner Notifier
+}
+
+// NewNoDataSkippingNotifier wraps inner.
+func NewNoDataSkippingNotifier(inner Notifier) *NoDataSkippingNotifier {
+	return &NoDataSkippingNotifier{inner: inner}
+}
+
+// Name returns the wrapped notifier's name.
+func (n *NoDataSkippingNotifier) Name() string {
+	return n.inner.Name()
+}
+
+// Send forwards the alert unless its window had no data.
+func (n *NoDataSkippingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	if alert.Summary.Empty == model.EmptyNoData {
+		log.Printf("No statements in the analysis window; skipping notification (report %s, set notifier.send_on_empty to send it)", alert.ReqID)
+		return nil
+	}
+	return n.inner.Send(ctx, alert)
+}
@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func emptyAlert(reason string) *model.AlertContext {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	alert := &model.AlertContext{
+		ReqID:           "req-quiet",
+		AnalysisWindow:  model.TimeWindow{Start: start, End: start.Add(8 * time.Hour)},
+		Summary:         model.AlertSummary{HealthScore: 100, HealthStatus: "healthy", Empty: reason},
+		DisplayLocation: time.UTC,
+	}
+	if reason == model.EmptyNoFindings {
+		alert.Summary.TotalQueriesAnalyzed = 3
+		alert.TopSlowSQL = []model.MetricSnapshot{{QueryID: 1, Query: "SELECT 1", TotalTime: 10, Calls: 1}}
+	}
+	return alert
+}
+
+func TestNoDataSkippingNotifier(t *testing.T) {
+	inner := &recordingNotifier{}
+	n := NewNoDataSkippingNotifier(inner)
+
+	if err := n.Send(context.Background(), emptyAlert(model.EmptyNoData)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 0 {
+		t.Errorf("a window without data should not be sent, got %d alerts", len(inner.alerts))
+	}
+
+	// Data without findings still carries the slow query ranking
+	if err := n.Send(context.Background(), emptyAlert(model.EmptyNoFindings)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := n.Send(context.Background(), emptyAlert("")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 2 {
+		t.Errorf("expected quiet and regular alerts to be sent, got %d", len(inner.alerts))
+	}
+}
+
+func TestFormatEmpty_Messages(t *testing.T) {
+	console := NewConsoleNotifier(&config.NotifierConfig{})
+	wecom, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: "http://localhost", RetryDelay: "1ms"})
+
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{model.EmptyNoData, "No data: no statements were recorded in 2024-05-01 00:00 +00:00 ~ 2024-05-01 08:00 +00:00"},
+		{model.EmptyNoFindings, "All quiet: no findings in 2024-05-01 00:00 +00:00 ~ 2024-05-01 08:00 +00:00"},
+	}
+	for _, tt := range tests {
+		alert := emptyAlert(tt.reason)
+		if got := console.format(alert); !strings.Contains(got, tt.want) {
+			t.Errorf("console output for %s missing %q:\n%s", tt.reason, tt.want, got)
+		}
+		if got := wecom.formatMessage(alert); !strings.Contains(got, tt.want) {
+			t.Errorf("wecom output for %s missing %q:\n%s", tt.reason, tt.want, got)
+		}
+	}
+
+	if got := formatEmpty(emptyAlert("")); got != "" {
+		t.Errorf("formatEmpty() for an alert with findings = %q, want empty", got)
+	}
+}
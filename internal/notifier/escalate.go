@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// EscalatingNotifier sends every alert to the primary notifier and, when the
+// engine attached an escalation (critical findings that persisted for the
+// configured number of runs), also sends that escalation to a second notifier.
+type EscalatingNotifier struct {
+	primary    Notifier
+	escalation Notifier
+}
+
+// NewEscalatingNotifier wraps primary with an escalation channel.
+func NewEscalatingNotifier(primary, escalation Notifier) *EscalatingNotifier {
+	return &EscalatingNotifier{primary: primary, escalation: escalation}
+}
+
+// Name returns both notifier names.
+func (e *EscalatingNotifier) Name() string {
+	return fmt.Sprintf("%s (escalation: %s)", e.primary.Name(), e.escalation.Name())
+}
+
+// Send forwards the alert to the primary notifier and its escalation, if any,
+// to the escalation notifier. A primary failure does not hold back the
+// escalation; both errors are returned.
+func (e *EscalatingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	primaryErr := e.primary.Send(ctx, alert)
+	if alert.Escalation == nil {
+		return primaryErr
+	}
+
+	log.Printf("Escalating persistent critical findings to %s (report %s)", e.escalation.Name(), alert.ReqID)
+	var escErr error
+	if err := e.escalation.Send(ctx, alert.Escalation); err != nil {
+		escErr = fmt.Errorf("escalation via %s: %w", e.escalation.Name(), err)
+	}
+	return errors.Join(primaryErr, escErr)
+}
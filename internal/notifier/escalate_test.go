@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// recordingNotifier remembers the alerts it was sent and fails with err.
+type recordingNotifier struct {
+	alerts []*model.AlertContext
+	err    error
+}
+
+func (r *recordingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	r.alerts = append(r.alerts, alert)
+	return r.err
+}
+
+func (r *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func TestEscalatingNotifier_Send(t *testing.T) {
+	primary, escalation := &recordingNotifier{}, &recordingNotifier{}
+	n := NewEscalatingNotifier(primary, escalation)
+
+	if err := n.Send(context.Background(), &model.AlertContext{ReqID: "quiet"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(primary.alerts) != 1 || len(escalation.alerts) != 0 {
+		t.Fatalf("without escalation: primary=%d escalation=%d, want 1/0", len(primary.alerts), len(escalation.alerts))
+	}
+
+	esc := &model.AlertContext{ReqID: "loud", ReportType: "escalation"}
+	if err := n.Send(context.Background(), &model.AlertContext{ReqID: "loud", Escalation: esc}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(primary.alerts) != 2 || len(escalation.alerts) != 1 || escalation.alerts[0] != esc {
+		t.Errorf("with escalation: primary=%d escalation=%v, want the escalation alert", len(primary.alerts), escalation.alerts)
+	}
+}
+
+func TestEscalatingNotifier_PrimaryFailureStillEscalates(t *testing.T) {
+	primaryErr := errors.New("webhook down")
+	primary, escalation := &recordingNotifier{err: primaryErr}, &recordingNotifier{}
+	n := NewEscalatingNotifier(primary, escalation)
+
+	err := n.Send(context.Background(), &model.AlertContext{Escalation: &model.AlertContext{}})
+	if !errors.Is(err, primaryErr) {
+		t.Errorf("Send() error = %v, want the primary error", err)
+	}
+	if len(escalation.alerts) != 1 {
+		t.Errorf("escalation sent %d time(s), want 1", len(escalation.alerts))
+	}
+
+	escalation.err = errors.New("pager down")
+	primary.err = nil
+	err = n.Send(context.Background(), &model.AlertContext{Escalation: &model.AlertContext{}})
+	if err == nil || !strings.Contains(err.Error(), "escalation via recording: pager down") {
+		t.Errorf("Send() error = %v, want the escalation error", err)
+	}
+}
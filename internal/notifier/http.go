@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// httpClientTimeout bounds a single webhook request, including retries' individual attempts.
+const httpClientTimeout = 30 * time.Second
+
+// NewHTTPClient builds the HTTP client shared by webhook notifiers. Requests go
+// through cfg.ProxyURL when set; otherwise HTTPS_PROXY/HTTP_PROXY/NO_PROXY apply.
+func NewHTTPClient(cfg *config.NotifierConfig) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+	}
+
+	return &http.Client{
+		Timeout:   httpClientTimeout,
+		Transport: transport,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send", nil)
+
+	t.Run("configured proxy", func(t *testing.T) {
+		client, err := NewHTTPClient(&config.NotifierConfig{ProxyURL: "http://proxy.corp:3128"})
+		if err != nil {
+			t.Fatalf("NewHTTPClient() error = %v", err)
+		}
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("expected *http.Transport, got %T", client.Transport)
+		}
+		proxyURL, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("Proxy() error = %v", err)
+		}
+		if proxyURL == nil || proxyURL.String() != "http://proxy.corp:3128" {
+			t.Errorf("Proxy() = %v, want http://proxy.corp:3128", proxyURL)
+		}
+	})
+
+	t.Run("environment proxy by default", func(t *testing.T) {
+		client, err := NewHTTPClient(&config.NotifierConfig{})
+		if err != nil {
+			t.Fatalf("NewHTTPClient() error = %v", err)
+		}
+		// http.ProxyFromEnvironment caches the environment on first use, so only
+		// check that a proxy function is installed.
+		if client.Transport.(*http.Transport).Proxy == nil {
+			t.Fatal("expected a proxy function that honours HTTPS_PROXY")
+		}
+	})
+
+	t.Run("timeout is set", func(t *testing.T) {
+		client, _ := NewHTTPClient(&config.NotifierConfig{})
+		if client.Timeout != httpClientTimeout {
+			t.Errorf("Timeout = %v, want %v", client.Timeout, httpClientTimeout)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// kafkaMessage is one record published to notifier.kafka.topic.
+type kafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// kafkaProducer publishes records to the configured topic. The client backed
+// implementation is only built with -tags kafka (see kafka_client.go), so
+// default builds do not depend on a Kafka library.
+type kafkaProducer interface {
+	Produce(ctx context.Context, msgs []kafkaMessage) error
+}
+
+// KafkaNotifier publishes findings as JSON messages to a Kafka topic.
+type KafkaNotifier struct {
+	topic      string
+	wholeAlert bool
+	producer   kafkaProducer
+}
+
+// NewKafkaNotifier creates a new Kafka notifier from cfg.Kafka.
+func NewKafkaNotifier(cfg *config.NotifierConfig) (*KafkaNotifier, error) {
+	kc := cfg.Kafka
+	if len(kc.Brokers) == 0 || kc.Topic == "" {
+		return nil, fmt.Errorf("kafka brokers and topic are required")
+	}
+	producer, err := newKafkaProducer(&kc)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaNotifier{topic: kc.Topic, wholeAlert: kc.Message == config.KafkaMessageAlert, producer: producer}, nil
+}
+
+// Name returns the notifier name.
+func (n *KafkaNotifier) Name() string {
+	return "kafka"
+}
+
+// Send publishes the alert's messages in one batch; an alert without findings
+// publishes nothing in finding mode.
+func (n *KafkaNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	msgs, err := kafkaMessages(alert, n.wholeAlert)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := n.producer.Produce(ctx, msgs); err != nil {
+		return fmt.Errorf("publishing to kafka topic %s: %w", n.topic, err)
+	}
+	return nil
+}
+
+// kafkaMessages builds the records for alert. In finding mode each value is the
+// finding's NDJSON object, keyed by its queryid so a query's findings stay on
+// one partition; findings without a query are keyed by table, then rule. In
+// alert mode the whole alert is one unkeyed message.
+func kafkaMessages(alert *model.AlertContext, wholeAlert bool) ([]kafkaMessage, error) {
+	if wholeAlert {
+		value, err := json.Marshal(alert)
+		if err != nil {
+			return nil, fmt.Errorf("encoding alert: %w", err)
+		}
+		return []kafkaMessage{{Value: value}}, nil
+	}
+
+	findings := ndjsonFindings(alert)
+	msgs := make([]kafkaMessage, 0, len(findings))
+	for _, f := range findings {
+		value, err := json.Marshal(f)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s finding: %w", f.Rule, err)
+		}
+		key := f.Rule
+		switch {
+		case f.QueryID != 0:
+			key = strconv.FormatInt(f.QueryID, 10)
+		case f.Table != "":
+			key = f.Table
+		}
+		msgs = append(msgs, kafkaMessage{Key: []byte(key), Value: value})
+	}
+	return msgs, nil
+}
@@ -0,0 +1,89 @@
+//go:build kafka
+
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// kafkaWriter is the kafka-go backed kafkaProducer. A key hash picks the
+// partition, so messages with the same key keep their order.
+type kafkaWriter struct {
+	w *kafka.Writer
+}
+
+// newKafkaProducer returns a producer for cfg's brokers and topic.
+func newKafkaProducer(cfg *config.KafkaConfig) (kafkaProducer, error) {
+	transport := &kafka.Transport{DialTimeout: 10 * time.Second}
+	if cfg.SASL.Mechanism != "" {
+		mechanism, err := kafkaSASLMechanism(&cfg.SASL)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := kafkaTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLS = tlsConfig
+	}
+	return &kafkaWriter{w: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		Transport:    transport,
+	}}, nil
+}
+
+// Produce writes msgs synchronously, so Send reports delivery failures.
+func (p *kafkaWriter) Produce(ctx context.Context, msgs []kafkaMessage) error {
+	records := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		records[i] = kafka.Message{Key: m.Key, Value: m.Value}
+	}
+	return p.w.WriteMessages(ctx, records...)
+}
+
+func kafkaSASLMechanism(cfg *config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported kafka SASL mechanism %q", cfg.Mechanism)
+	}
+}
+
+func kafkaTLSConfig(cfg *config.KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka CA file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
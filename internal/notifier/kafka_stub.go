@@ -0,0 +1,15 @@
+//go:build !kafka
+
+package notifier
+
+import (
+	"errors"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// newKafkaProducer is unavailable in default builds so a Kafka client is not a
+// dependency for users who do not publish to Kafka. Build with -tags kafka.
+func newKafkaProducer(cfg *config.KafkaConfig) (kafkaProducer, error) {
+	return nil, errors.New("notifier type kafka requires a binary built with -tags kafka")
+}
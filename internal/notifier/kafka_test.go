@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// mockProducer records the batches it is asked to publish.
+type mockProducer struct {
+	batches [][]kafkaMessage
+	err     error
+}
+
+func (p *mockProducer) Produce(ctx context.Context, msgs []kafkaMessage) error {
+	p.batches = append(p.batches, msgs)
+	return p.err
+}
+
+func TestKafkaNotifier_Send(t *testing.T) {
+	p := &mockProducer{}
+	n := &KafkaNotifier{topic: "powa.findings", producer: p}
+
+	alert := testCSVAlert()
+	alert.ReqID = "run-42"
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(p.batches) != 1 {
+		t.Fatalf("expected one batch per alert, got %d", len(p.batches))
+	}
+
+	msgs := p.batches[0]
+	want := []struct{ key, rule string }{
+		{"1", "slow_sql"},
+		{"2", "regression"},
+		{"sales.orders", "index_suggestion"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("expected one message per finding (%d), got %d", len(want), len(msgs))
+	}
+	for i, w := range want {
+		var value map[string]interface{}
+		if err := json.Unmarshal(msgs[i].Value, &value); err != nil {
+			t.Fatalf("message %d is not a JSON object: %v\n%s", i, err, msgs[i].Value)
+		}
+		if string(msgs[i].Key) != w.key || value["rule"] != w.rule || value["run_id"] != "run-42" {
+			t.Errorf("message %d = key %q, %s; want key %q and rule %s", i, msgs[i].Key, msgs[i].Value, w.key, w.rule)
+		}
+	}
+}
+
+func TestKafkaNotifier_SendWholeAlert(t *testing.T) {
+	p := &mockProducer{}
+	n := &KafkaNotifier{topic: "powa.alerts", wholeAlert: true, producer: p}
+
+	alert := testCSVAlert()
+	alert.ReqID = "run-42"
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(p.batches) != 1 || len(p.batches[0]) != 1 {
+		t.Fatalf("expected a single message, got %+v", p.batches)
+	}
+	msg := p.batches[0][0]
+	var decoded model.AlertContext
+	if err := json.Unmarshal(msg.Value, &decoded); err != nil {
+		t.Fatalf("message is not an alert: %v", err)
+	}
+	if msg.Key != nil || decoded.ReqID != "run-42" || len(decoded.Regressions) != 1 {
+		t.Errorf("message = key %q, %s; want the unkeyed alert", msg.Key, msg.Value)
+	}
+}
+
+func TestKafkaNotifier_SendEmptyAndError(t *testing.T) {
+	p := &mockProducer{}
+	n := &KafkaNotifier{topic: "powa.findings", producer: p}
+	if err := n.Send(context.Background(), &model.AlertContext{}); err != nil || len(p.batches) != 0 {
+		t.Errorf("empty alert: err = %v, batches = %d; want nothing published", err, len(p.batches))
+	}
+
+	p.err = errors.New("broker unavailable")
+	err := n.Send(context.Background(), testCSVAlert())
+	if err == nil || !strings.Contains(err.Error(), "powa.findings") || !errors.Is(err, p.err) {
+		t.Errorf("Send() error = %v, want the wrapped producer error naming the topic", err)
+	}
+}
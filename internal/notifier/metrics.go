@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// SendDurationBuckets are the upper bounds, in seconds, of the send duration
+// histogram. They reach past the default retry budget so retried sends land in
+// a bucket of their own.
+var SendDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// SendMetrics collects the duration and outcome of every Send of the notifiers
+// wrapped by NewMeteredNotifier, for the health server's /metrics endpoint.
+type SendMetrics struct {
+	mu     sync.Mutex
+	series map[string]*SendStats
+}
+
+// SendStats is the send histogram and failure count of one notifier.
+type SendStats struct {
+	Notifier string
+	Buckets  []uint64 // cumulative counts per SendDurationBuckets bound
+	Count    uint64
+	Sum      float64 // seconds
+	Failures uint64
+}
+
+// NewSendMetrics returns an empty collector.
+func NewSendMetrics() *SendMetrics {
+	return &SendMetrics{series: make(map[string]*SendStats)}
+}
+
+// observe records one send by the named notifier.
+func (m *SendMetrics) observe(name string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[name]
+	if !ok {
+		s = &SendStats{Notifier: name, Buckets: make([]uint64, len(SendDurationBuckets))}
+		m.series[name] = s
+	}
+	seconds := d.Seconds()
+	for i, bound := range SendDurationBuckets {
+		if seconds <= bound {
+			s.Buckets[i]++
+		}
+	}
+	s.Count++
+	s.Sum += seconds
+	if err != nil {
+		s.Failures++
+	}
+}
+
+// Snapshot returns a copy of every notifier's stats, ordered by name.
+func (m *SendMetrics) Snapshot() []SendStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]SendStats, 0, len(m.series))
+	for _, s := range m.series {
+		c := *s
+		c.Buckets = append([]uint64(nil), s.Buckets...)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Notifier < out[j].Notifier })
+	return out
+}
+
+// MeteredNotifier records the duration and outcome of each Send of the wrapped
+// notifier, retries included, under its metrics label.
+type MeteredNotifier struct {
+	inner   Notifier
+	label   string
+	metrics *SendMetrics
+}
+
+// NewMeteredNotifier wraps inner, recording its sends into metrics under label.
+func NewMeteredNotifier(inner Notifier, label string, metrics *SendMetrics) *MeteredNotifier {
+	return &MeteredNotifier{inner: inner, label: label, metrics: metrics}
+}
+
+// Name returns the wrapped notifier's name.
+func (n *MeteredNotifier) Name() string {
+	return n.inner.Name()
+}
+
+// Send forwards the alert and records how long it took and whether it failed.
+func (n *MeteredNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	start := time.Now()
+	err := n.inner.Send(ctx, alert)
+	n.metrics.observe(n.label, time.Since(start), err)
+	return err
+}
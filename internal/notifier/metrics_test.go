@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestMeteredNotifier_RecordsPerChild(t *testing.T) {
+	metrics := NewSendMetrics()
+	primary := &recordingNotifier{}
+	escalation := &recordingNotifier{err: errors.New("relay down")}
+	n := NewEscalatingNotifier(
+		NewMeteredNotifier(primary, "wecom", metrics),
+		NewMeteredNotifier(escalation, "escalation:syslog", metrics),
+	)
+
+	alert := &model.AlertContext{ReqID: "r1"}
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send() without escalation error = %v", err)
+	}
+	alert = &model.AlertContext{ReqID: "r2", Escalation: &model.AlertContext{ReqID: "r2"}}
+	if err := n.Send(context.Background(), alert); err == nil {
+		t.Fatal("Send() error = nil, want the escalation failure")
+	}
+
+	stats := metrics.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("Snapshot() = %d series, want 2: %+v", len(stats), stats)
+	}
+	esc, wecom := stats[0], stats[1] // ordered by name
+	if wecom.Notifier != "wecom" || wecom.Count != 2 || wecom.Failures != 0 {
+		t.Errorf("wecom stats = %+v, want 2 sends, 0 failures", wecom)
+	}
+	if esc.Notifier != "escalation:syslog" || esc.Count != 1 || esc.Failures != 1 {
+		t.Errorf("escalation stats = %+v, want 1 send, 1 failure", esc)
+	}
+	// Instant sends fall in every bucket
+	for i, c := range wecom.Buckets {
+		if c != wecom.Count {
+			t.Errorf("wecom bucket le=%g = %d, want %d", SendDurationBuckets[i], c, wecom.Count)
+		}
+	}
+}
+
+func TestProberOf_LooksThroughMeteredNotifier(t *testing.T) {
+	wecom := &WeComNotifier{}
+	if p, ok := ProberOf(NewMeteredNotifier(wecom, "wecom", NewSendMetrics())); !ok || p != Prober(wecom) {
+		t.Errorf("ProberOf(metered wecom) = %v, %v; want the WeCom notifier", p, ok)
+	}
+}
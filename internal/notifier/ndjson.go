@@ -0,0 +1,209 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// ndjsonFinding is one NDJSON line. rule, severity, run_id, timestamp and
+// metrics are always present; the remaining fields only when the finding has
+// them. Rule names match the csv rule column.
+type ndjsonFinding struct {
+	RunID     string             `json:"run_id"`
+	Timestamp time.Time          `json:"timestamp"`
+	Rule      string             `json:"rule"`
+	Severity  string             `json:"severity"`
+	Database  string             `json:"database,omitempty"`
+	Server    string             `json:"server,omitempty"`
+	QueryID   int64              `json:"queryid,omitempty"`
+	Table     string             `json:"table,omitempty"`
+	Query     string             `json:"query,omitempty"`
+	Message   string             `json:"message,omitempty"`
+	Metrics   map[string]float64 `json:"metrics"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+}
+
+// NDJSONNotifier writes one JSON object per finding to stdout, for log
+// pipelines that tail container output.
+type NDJSONNotifier struct {
+	out io.Writer
+}
+
+// NewNDJSONNotifier creates a new NDJSON notifier writing to stdout.
+func NewNDJSONNotifier(cfg *config.NotifierConfig) *NDJSONNotifier {
+	return &NDJSONNotifier{out: os.Stdout}
+}
+
+// Name returns the notifier name.
+func (n *NDJSONNotifier) Name() string {
+	return "ndjson"
+}
+
+// Send writes the alert's findings, one line each. Every line is written with
+// a single Write so it reaches the pipeline whole; an alert without findings
+// writes nothing.
+func (n *NDJSONNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	for _, f := range ndjsonFindings(alert) {
+		line, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("encoding %s finding: %w", f.Rule, err)
+		}
+		if _, err := n.out.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("writing ndjson: %w", err)
+		}
+	}
+	return nil
+}
+
+// ndjsonFindings flattens the alert into lines, stale data first and custom findings last.
+func ndjsonFindings(alert *model.AlertContext) []ndjsonFinding {
+	ts := alert.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	finding := func(rule, severity string, labels map[string]string, metrics map[string]float64) ndjsonFinding {
+		if labels == nil {
+			labels = alert.Labels
+		}
+		return ndjsonFinding{RunID: alert.ReqID, Timestamp: ts.UTC(), Rule: rule, Severity: severity, Metrics: metrics, Labels: labels}
+	}
+
+	var lines []ndjsonFinding
+	if sd := alert.StaleData; sd != nil {
+		f := finding("stale_data", sd.Severity, nil, map[string]float64{
+			"age_seconds":     sd.Age.Seconds(),
+			"max_age_seconds": sd.MaxAge.Seconds(),
+		})
+		f.Message = formatStaleData(sd)
+		lines = append(lines, f)
+	}
+	for _, q := range alert.TopSlowSQL {
+		f := finding("slow_sql", "", q.Labels, map[string]float64{
+			"total_time_ms": q.TotalTime,
+			"mean_time_ms":  q.MeanTime,
+			"calls":         float64(q.Calls),
+		})
+		f.Database, f.Server, f.QueryID, f.Query = q.DatabaseName, q.ServerName, q.QueryID, q.Query
+		lines = append(lines, f)
+	}
+	for _, r := range alert.Regressions {
+		rule, metrics := "regression", map[string]float64{
+			"baseline_mean_ms": r.BaselineMeanTime,
+			"current_mean_ms":  r.CurrentMeanTime,
+			"change_percent":   r.ChangePercent,
+			"baseline_calls":   float64(r.BaselineCalls),
+			"current_calls":    float64(r.CurrentCalls),
+		}
+		if r.CurrentTailTime > 0 {
+			metrics["baseline_tail_ms"] = r.BaselineTailTime
+			metrics["current_tail_ms"] = r.CurrentTailTime
+		}
+		if r.IsNewQuery {
+			rule, metrics = "new_query", map[string]float64{
+				"current_mean_ms": r.CurrentMeanTime,
+				"current_calls":   float64(r.CurrentCalls),
+			}
+		}
+		f := finding(rule, r.Severity, r.Labels, metrics)
+		f.Database, f.Server, f.QueryID, f.Query = r.DatabaseName, r.ServerName, r.QueryID, r.Query
+		lines = append(lines, f)
+	}
+	for _, cs := range alert.CallSpikes {
+		f := finding("call_spike", "", cs.Labels, map[string]float64{
+			"baseline_calls": float64(cs.BaselineCalls),
+			"current_calls":  float64(cs.CurrentCalls),
+			"change_percent": cs.ChangePercent,
+		})
+		f.Database, f.Server, f.QueryID, f.Query = cs.DatabaseName, cs.ServerName, cs.QueryID, cs.Query
+		lines = append(lines, f)
+	}
+	for _, we := range alert.WaitEvents {
+		f := finding("wait_events", "", we.Labels, map[string]float64{
+			"total_time_ms": we.TotalTime,
+			"wait_time_ms":  we.WaitTime,
+			"wait_percent":  we.WaitPercent,
+		})
+		f.Database, f.Server, f.QueryID, f.Query = we.DatabaseName, we.ServerName, we.QueryID, we.Query
+		f.Message = we.DominantEventType + ":" + we.DominantEvent
+		lines = append(lines, f)
+	}
+	for _, fl := range alert.Flapping {
+		f := finding("flapping", "", fl.Labels, map[string]float64{
+			"mean_time_ms": fl.MeanTime,
+			"cv":           fl.CV,
+			"windows":      float64(len(fl.Series)),
+		})
+		f.Database, f.Server, f.QueryID, f.Query = fl.DatabaseName, fl.ServerName, fl.QueryID, fl.Query
+		lines = append(lines, f)
+	}
+	for _, c := range alert.CrossServer {
+		f := finding("cross_server", "", c.Labels, map[string]float64{
+			"reference_mean_ms": c.ReferenceMeanTime,
+			"mean_time_ms":      c.MeanTime,
+			"change_percent":    c.ChangePercent,
+			"reference_calls":   float64(c.ReferenceCalls),
+			"calls":             float64(c.Calls),
+		})
+		f.Database, f.Server, f.QueryID, f.Query = c.DatabaseName, c.ServerName, c.QueryID, c.Query
+		f.Message = "reference server " + c.ReferenceServerName
+		lines = append(lines, f)
+	}
+	if wg := alert.WorkloadGrowth; wg != nil {
+		f := finding("workload_growth", "", nil, map[string]float64{
+			"baseline_total_time_ms": wg.BaselineTotalTime,
+			"current_total_time_ms":  wg.CurrentTotalTime,
+			"change_percent":         wg.ChangePercent,
+			"baseline_calls":         float64(wg.BaselineCalls),
+			"current_calls":          float64(wg.CurrentCalls),
+		})
+		f.Message = formatWorkloadGrowth(wg)
+		lines = append(lines, f)
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		f := finding("connection_saturation", "", nil, map[string]float64{
+			"active":          float64(cs.Active),
+			"idle":            float64(cs.Idle),
+			"connections":     float64(cs.Total),
+			"max_connections": float64(cs.MaxConnections),
+			"usage_percent":   cs.UsagePercent,
+		})
+		f.Message = formatConnectionSaturation(cs)
+		lines = append(lines, f)
+	}
+	for _, v := range alert.Vacuum {
+		f := finding("vacuum", "", v.Labels, map[string]float64{
+			"n_dead_tup":         float64(v.DeadTuples),
+			"dead_ratio_percent": v.DeadRatioPercent,
+		})
+		f.Database, f.Table, f.Message = v.DatabaseName, v.FullTableName(), strings.Join(v.Reasons, ",")
+		lines = append(lines, f)
+	}
+	for _, sg := range alert.Suggestions {
+		f := finding("index_suggestion", "", nil, map[string]float64{
+			"est_improvement_percent": sg.EstImprovementPercent,
+			"affected_queries":        float64(sg.AffectedQueries),
+		})
+		f.Table, f.Message = sg.FullTableName(), sg.SuggestedDDL
+		if f.Message == "" {
+			f.Message = fmt.Sprintf("index on %s (%s)", sg.FullTableName(), strings.Join(sg.Columns, ", "))
+		}
+		lines = append(lines, f)
+	}
+	for _, cf := range alert.CustomFindings {
+		f := finding("custom:"+cf.Rule, cf.Severity, nil, map[string]float64{
+			"value":     cf.Value,
+			"threshold": cf.Threshold,
+		})
+		f.Message = formatCustomRow(cf)
+		lines = append(lines, f)
+	}
+	return lines
+}
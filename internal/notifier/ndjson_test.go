@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestNDJSONNotifier_Send(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNDJSONNotifier(&config.NotifierConfig{})
+	n.out = &buf
+
+	alert := testCSVAlert()
+	alert.ReqID = "run-42"
+	alert.CallSpikes = []model.CallSpikeItem{{QueryID: 3, DatabaseName: "app", BaselineCalls: 10, CurrentCalls: 500, ChangePercent: 4900}}
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v\n%s", len(lines)+1, err, sc.Text())
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("expected one line per finding (4), got %d:\n%s", len(lines), buf.String())
+	}
+
+	want := []struct {
+		rule, severity string
+		queryID        float64
+		metric         string
+		value          float64
+	}{
+		{"slow_sql", "", 1, "total_time_ms", 1234.5},
+		{"regression", "medium", 2, "current_mean_ms", 25.5},
+		{"call_spike", "", 3, "current_calls", 500},
+		{"index_suggestion", "", 0, "est_improvement_percent", 40},
+	}
+	for i, w := range want {
+		line := lines[i]
+		if line["rule"] != w.rule || line["severity"] != w.severity || line["run_id"] != "run-42" {
+			t.Errorf("line %d: rule=%v severity=%v run_id=%v, want %s %q run-42", i, line["rule"], line["severity"], line["run_id"], w.rule, w.severity)
+		}
+		if q, _ := line["queryid"].(float64); q != w.queryID {
+			t.Errorf("line %d: queryid = %v, want %v", i, line["queryid"], w.queryID)
+		}
+		metrics, _ := line["metrics"].(map[string]interface{})
+		if metrics[w.metric] != w.value {
+			t.Errorf("line %d: metrics[%s] = %v, want %v", i, w.metric, metrics[w.metric], w.value)
+		}
+	}
+	// Findings without their own labels carry the alert's
+	if labels, _ := lines[3]["labels"].(map[string]interface{}); labels["env"] != "prod" {
+		t.Errorf("index suggestion labels = %v, want env=prod", lines[3]["labels"])
+	}
+	if labels, _ := lines[0]["labels"].(map[string]interface{}); labels["team"] != "payments" {
+		t.Errorf("slow query labels = %v, want its own labels", lines[0]["labels"])
+	}
+}
+
+func TestNDJSONNotifier_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNDJSONNotifier(&config.NotifierConfig{})
+	n.out = &buf
+	if err := n.Send(context.Background(), &model.AlertContext{ReqID: "run-1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("alert without findings wrote %q", buf.String())
+	}
+}
@@ -15,3 +15,40 @@ type Notifier interface {
 	// Name returns the name of the notifier.
 	Name() string
 }
+
+// Prober is implemented by notifiers that can check their channel is reachable
+// without sending an alert. Notifiers without a remote endpoint (console, csv)
+// do not implement it.
+type Prober interface {
+	// Probe returns an error when the channel cannot be reached.
+	Probe(ctx context.Context) error
+}
+
+// ProberOf returns the Prober behind n, looking through the wrappers
+// (suppression, threshold, escalation, metering, ...) to the primary notifier.
+func ProberOf(n Notifier) (Prober, bool) {
+	switch w := n.(type) {
+	case *SuppressingNotifier:
+		return ProberOf(w.inner)
+	case *EscalatingNotifier:
+		return ProberOf(w.primary)
+	case *RecoveringNotifier:
+		return ProberOf(w.primary)
+	case *NoDataSkippingNotifier:
+		return ProberOf(w.inner)
+	case *MeteredNotifier:
+		return ProberOf(w.inner)
+	case *DigestingNotifier:
+		return ProberOf(w.inner)
+	case *ThresholdNotifier:
+		return ProberOf(w.inner)
+	case *TeeNotifier:
+		return ProberOf(w.inner)
+	case *RedactingNotifier:
+		return ProberOf(w.inner)
+	case *RoutingNotifier:
+		return ProberOf(w.primary)
+	}
+	p, ok := n.(Prober)
+	return p, ok
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// queryURLTemplate renders notifier.query_url_template, the link added to each
+// query finding. Placeholders ({srvid}, {server}, {db}, {queryid}) are
+// path-escaped before the first "?" and query-escaped after it, so a database
+// named "sales/eu" cannot change the link's path. A nil template renders "".
+type queryURLTemplate struct {
+	path, query string // template text before and from the first "?"
+}
+
+// newQueryURLTemplate returns nil for an empty template. The template is
+// checked by config validation.
+func newQueryURLTemplate(text string) *queryURLTemplate {
+	if text == "" {
+		return nil
+	}
+	path, query := text, ""
+	if i := strings.Index(text, "?"); i >= 0 {
+		path, query = text[:i], text[i:]
+	}
+	return &queryURLTemplate{path: path, query: query}
+}
+
+// render returns the link for one query finding.
+func (t *queryURLTemplate) render(srvID int, server, database string, queryID int64) string {
+	if t == nil {
+		return ""
+	}
+	values := []string{strconv.Itoa(srvID), server, database, strconv.FormatInt(queryID, 10)}
+	return replacePlaceholders(t.path, values, url.PathEscape) + replacePlaceholders(t.query, values, url.QueryEscape)
+}
+
+// replacePlaceholders substitutes {srvid}, {server}, {db} and {queryid} in s
+// with values in that order, escaped by escape.
+func replacePlaceholders(s string, values []string, escape func(string) string) string {
+	if s == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{srvid}", escape(values[0]),
+		"{server}", escape(values[1]),
+		"{db}", escape(values[2]),
+		"{queryid}", escape(values[3]),
+	).Replace(s)
+}
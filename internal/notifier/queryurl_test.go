@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestQueryURLTemplate_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		server   string
+		database string
+		want     string
+	}{
+		{"unset", "", "local", "app", ""},
+		{"path", "https://powa.example.com/server/{srvid}/database/{db}/query/{queryid}", "local", "app",
+			"https://powa.example.com/server/3/database/app/query/-42"},
+		{"special characters in path", "https://powa.example.com/server/{srvid}/database/{db}/query/{queryid}", "local", "my db/prod?#%",
+			"https://powa.example.com/server/3/database/my%20db%2Fprod%3F%23%25/query/-42"},
+		{"special characters in query string", "https://powa.example.com/{server}?db={db}&q={queryid}", "eu west", "a&b=c+d",
+			"https://powa.example.com/eu%20west?db=a%26b%3Dc%2Bd&q=-42"},
+		{"unicode", "https://powa.example.com/database/{db}", "local", "销售",
+			"https://powa.example.com/database/%E9%94%80%E5%94%AE"},
+	}
+	for _, tt := range tests {
+		if got := newQueryURLTemplate(tt.text).render(3, tt.server, tt.database, -42); got != tt.want {
+			t.Errorf("%s: render() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryURLTemplate_Notifiers(t *testing.T) {
+	cfg := &config.NotifierConfig{
+		WebhookURL:       "http://localhost",
+		RetryDelay:       "1ms",
+		QueryURLTemplate: "https://powa.example.com/server/{srvid}/database/{db}/query/{queryid}",
+	}
+	alert := &model.AlertContext{
+		Regressions: []model.RegressionItem{{QueryID: 7, SrvID: 1, DatabaseName: "my db", Severity: "high", Query: "SELECT 1"}},
+	}
+	const link = "https://powa.example.com/server/1/database/my%20db/query/7"
+
+	w, err := NewWeComNotifier(cfg)
+	if err != nil {
+		t.Fatalf("NewWeComNotifier() error = %v", err)
+	}
+	if got := w.formatMessage(alert); !strings.Contains(got, "[Open in PoWA]("+link+")") {
+		t.Errorf("markdown message missing link:\n%s", got)
+	}
+	if got := strings.Join(plainTextBlocks(w.formatBlocks(alert)), "\n"); !strings.Contains(got, "Open in PoWA: "+link) {
+		t.Errorf("text message missing link:\n%s", got)
+	}
+	if got := NewConsoleNotifier(cfg).format(alert); !strings.Contains(got, "🔗 "+link) {
+		t.Errorf("console report missing link:\n%s", got)
+	}
+}
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// RecoveringNotifier sends every alert to the primary notifier and, when the
+// engine attached a recovery (findings at or above notifier.recovery_severity
+// that cleared since the last run), also sends that recovery to a second
+// notifier. The recovery notifier is built from the same settings but without
+// the suppression, threshold and digest wrappers, which would hold back an
+// alert that has no findings of its own.
+type RecoveringNotifier struct {
+	primary  Notifier
+	recovery Notifier
+}
+
+// NewRecoveringNotifier wraps primary with a recovery channel.
+func NewRecoveringNotifier(primary, recovery Notifier) *RecoveringNotifier {
+	return &RecoveringNotifier{primary: primary, recovery: recovery}
+}
+
+// Name returns the primary notifier's name.
+func (r *RecoveringNotifier) Name() string {
+	return r.primary.Name()
+}
+
+// Send forwards the alert to the primary notifier and its recovery, if any,
+// to the recovery notifier. A primary failure does not hold back the
+// recovery; both errors are returned.
+func (r *RecoveringNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	primaryErr := r.primary.Send(ctx, alert)
+	if alert.Recovery == nil {
+		return primaryErr
+	}
+
+	log.Printf("Sending recovery of %d finding(s) via %s (report %s)",
+		len(alert.Recovery.Delta.Resolved), r.recovery.Name(), alert.ReqID)
+	var recErr error
+	if err := r.recovery.Send(ctx, alert.Recovery); err != nil {
+		recErr = fmt.Errorf("recovery via %s: %w", r.recovery.Name(), err)
+	}
+	return errors.Join(primaryErr, recErr)
+}
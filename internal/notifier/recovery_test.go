@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestRecoveringNotifier_Send(t *testing.T) {
+	primary, recovery := &recordingNotifier{}, &recordingNotifier{}
+	n := NewRecoveringNotifier(primary, recovery)
+
+	if err := n.Send(context.Background(), &model.AlertContext{ReqID: "still"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(primary.alerts) != 1 || len(recovery.alerts) != 0 {
+		t.Fatalf("without recovery: primary=%d recovery=%d, want 1/0", len(primary.alerts), len(recovery.alerts))
+	}
+
+	rec := &model.AlertContext{ReqID: "ok", ReportType: "recovery", Delta: &model.FindingDelta{
+		Resolved: []model.FindingRef{{Rule: "regression", QueryID: 1, Severity: "critical"}},
+	}}
+	if err := n.Send(context.Background(), &model.AlertContext{ReqID: "ok", Recovery: rec}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(primary.alerts) != 2 || len(recovery.alerts) != 1 || recovery.alerts[0] != rec {
+		t.Errorf("with recovery: primary=%d recovery=%v, want the recovery alert", len(primary.alerts), recovery.alerts)
+	}
+
+	// A failing primary does not hold back the recovery
+	primary.err, recovery.err = errors.New("webhook down"), errors.New("pager down")
+	err := n.Send(context.Background(), &model.AlertContext{Recovery: rec})
+	if !errors.Is(err, primary.err) || !strings.Contains(err.Error(), "recovery via recording: pager down") {
+		t.Errorf("Send() error = %v, want both errors", err)
+	}
+	if len(recovery.alerts) != 2 {
+		t.Errorf("recovery sent %d time(s), want 2", len(recovery.alerts))
+	}
+}
+
+func TestConsoleNotifier_Recovery(t *testing.T) {
+	rec := &model.AlertContext{ReqID: "r1", ReportType: "recovery", Delta: &model.FindingDelta{
+		Resolved: []model.FindingRef{{Rule: "regression", QueryID: 7, DatabaseName: "app", Severity: "critical", Subject: "SELECT 1"}},
+	}}
+	got := NewConsoleNotifier(&config.NotifierConfig{}).format(rec)
+	if !strings.Contains(got, "Resolved (1):") || !strings.Contains(got, "- [regression] [7] [app] (critical) SELECT 1") {
+		t.Errorf("recovery output missing the resolved finding:\n%s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// secretPlaceholder replaces every credential RedactSecrets removes.
+const secretPlaceholder = "***"
+
+var (
+	// urlPattern finds URLs in error text, e.g. the webhook URL net/http puts
+	// in a *url.Error along with its key.
+	urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+	// secretPatterns match credentials outside URLs: key=value pairs (DSNs,
+	// form bodies), Authorization headers and AWS access key IDs. The first
+	// group, when present, is kept.
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key|signature)\s*[=:]\s*)[^\s&,;"']+`),
+		regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/-]+=*`),
+		regexp.MustCompile(`()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	}
+)
+
+// RedactSecrets masks credentials in s before it is logged: the password of
+// URL user info, every query string value of a URL (webhook keys and tokens
+// travel there), and the patterns of secretPatterns.
+func RedactSecrets(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, redactURL)
+	for _, p := range secretPatterns {
+		s = p.ReplaceAllString(s, "${1}"+secretPlaceholder)
+	}
+	return s
+}
+
+// redactURL masks the password and query values of raw, leaving the rest
+// readable so the failing host and path still show.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), secretPlaceholder)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q[k] = []string{secretPlaceholder}
+		}
+		u.RawQuery = q.Encode()
+	}
+	// Encode escapes the placeholder; keep it readable
+	return unescapePlaceholder.Replace(u.String())
+}
+
+var unescapePlaceholder = strings.NewReplacer(url.QueryEscape(secretPlaceholder), secretPlaceholder)
+
+// redactedError carries the redacted message of err, while errors.Is and
+// errors.As still see err.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// RedactError returns err with RedactSecrets applied to its message, or nil.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := RedactSecrets(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// RedactingNotifier wraps a Notifier so the errors it returns, which the
+// scheduler and --once mode log, carry no webhook keys or other credentials.
+type RedactingNotifier struct {
+	inner Notifier
+}
+
+// NewRedactingNotifier wraps inner.
+func NewRedactingNotifier(inner Notifier) *RedactingNotifier {
+	return &RedactingNotifier{inner: inner}
+}
+
+// Name returns the wrapped notifier's name.
+func (r *RedactingNotifier) Name() string {
+	return r.inner.Name()
+}
+
+// Send forwards the alert and redacts the error, if any.
+func (r *RedactingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	return RedactError(r.inner.Send(ctx, alert))
+}
@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// RoutingNotifier sends the findings of each routed severity to that
+// severity's notifier (e.g. info to a log channel, critical to a pager) and
+// the rest of the alert to the primary notifier. Every finding has a single
+// severity, so it lands on exactly one channel; findings without a severity
+// (call spikes, index suggestions, ...) always stay with the primary.
+type RoutingNotifier struct {
+	primary Notifier
+	routes  map[string]Notifier // by severity
+}
+
+// NewRoutingNotifier wraps primary with routes, keyed by severity.
+func NewRoutingNotifier(primary Notifier, routes map[string]Notifier) *RoutingNotifier {
+	return &RoutingNotifier{primary: primary, routes: routes}
+}
+
+// Name returns the primary name and the route of each severity.
+func (r *RoutingNotifier) Name() string {
+	var routes []string
+	for _, severity := range r.severities() {
+		routes = append(routes, severity+": "+r.routes[severity].Name())
+	}
+	return fmt.Sprintf("%s (routes: %s)", r.primary.Name(), strings.Join(routes, ", "))
+}
+
+// Send sends each routed severity's findings to its notifier, most severe
+// first, then the alert without them to the primary notifier. Routes without
+// findings in this alert send nothing. A failed route does not hold back the
+// others or the primary; every error is returned.
+func (r *RoutingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	var errs []error
+	for _, severity := range r.severities() {
+		routed := routeAlert(alert, func(s string) bool { return s == severity })
+		if !hasSeverityFindings(routed) {
+			continue
+		}
+		route := r.routes[severity]
+		log.Printf("Routing %s findings to %s (report %s)", severity, route.Name(), alert.ReqID)
+		if err := route.Send(ctx, routed); err != nil {
+			errs = append(errs, fmt.Errorf("%s route via %s: %w", severity, route.Name(), err))
+		}
+	}
+
+	rest := *alert
+	kept := routeAlert(alert, func(s string) bool { _, routed := r.routes[s]; return !routed })
+	rest.Regressions, rest.CustomFindings, rest.StaleData = kept.Regressions, kept.CustomFindings, kept.StaleData
+	countSeverityFindings(&rest.Summary, &rest)
+	return errors.Join(append([]error{r.primary.Send(ctx, &rest)}, errs...)...)
+}
+
+// severities returns the routed severities, most severe first.
+func (r *RoutingNotifier) severities() []string {
+	return slices.SortedFunc(maps.Keys(r.routes), func(a, b string) int {
+		return model.SeverityRank(b) - model.SeverityRank(a)
+	})
+}
+
+// routeAlert copies the alert header and keeps only the findings whose
+// severity match accepts. Its summary counts those findings only; the health
+// score is the whole alert's.
+func routeAlert(alert *model.AlertContext, match func(severity string) bool) *model.AlertContext {
+	out := &model.AlertContext{
+		ReqID:           alert.ReqID,
+		ReportType:      alert.ReportType,
+		Timestamp:       alert.Timestamp,
+		AnalysisWindow:  alert.AnalysisWindow,
+		BaselineWindow:  alert.BaselineWindow,
+		DatabaseName:    alert.DatabaseName,
+		Repository:      alert.Repository,
+		Labels:          alert.Labels,
+		DisplayLocation: alert.DisplayLocation,
+		Summary: model.AlertSummary{
+			TotalQueriesAnalyzed: alert.Summary.TotalQueriesAnalyzed,
+			HealthScore:          alert.Summary.HealthScore,
+			HealthStatus:         alert.Summary.HealthStatus,
+		},
+	}
+	if sd := alert.StaleData; sd != nil && match(sd.Severity) {
+		out.StaleData = sd
+	}
+	for _, f := range alert.Regressions {
+		if match(f.Severity) {
+			out.Regressions = append(out.Regressions, f)
+		}
+	}
+	for _, f := range alert.CustomFindings {
+		if match(f.Severity) {
+			out.CustomFindings = append(out.CustomFindings, f)
+		}
+	}
+	countSeverityFindings(&out.Summary, out)
+	return out
+}
+
+// countSeverityFindings sets the summary counts of the findings that carry a
+// severity to those alert holds, so a channel does not report findings routed
+// to another.
+func countSeverityFindings(summary *model.AlertSummary, alert *model.AlertContext) {
+	summary.RegressionCount, summary.NewQueryCount = 0, 0
+	for _, r := range alert.Regressions {
+		if r.IsNewQuery {
+			summary.NewQueryCount++
+		} else {
+			summary.RegressionCount++
+		}
+	}
+	summary.CustomFindingCount = len(alert.CustomFindings)
+}
+
+// hasSeverityFindings reports whether alert holds any of the findings that
+// carry a severity.
+func hasSeverityFindings(alert *model.AlertContext) bool {
+	return alert.StaleData != nil || len(alert.Regressions) > 0 || len(alert.CustomFindings) > 0
+}
@@ -0,0 +1,108 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestRoutingNotifier_Send(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID: "r1",
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, Severity: "critical"},
+			{QueryID: 2, Severity: "info"},
+			{QueryID: 3, Severity: "high"},
+			{QueryID: 4, Severity: "critical"},
+		},
+		CustomFindings: []model.CustomFinding{{Rule: "dead_tuples", Severity: "info"}},
+		StaleData:      &model.StaleDataFinding{Severity: "critical"},
+		CallSpikes:     []model.CallSpikeItem{{QueryID: 5}},
+		TopSlowSQL:     []model.MetricSnapshot{{QueryID: 1}},
+		Summary:        model.AlertSummary{RegressionCount: 4, CustomFindingCount: 1, CallSpikeCount: 1, SlowQueryCount: 1, HealthScore: 40},
+	}
+	primary, pager, logChannel := &recordingNotifier{}, &recordingNotifier{}, &recordingNotifier{}
+	n := NewRoutingNotifier(primary, map[string]Notifier{"critical": pager, "info": logChannel})
+
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// Criticals land on the pager only
+	if len(pager.alerts) != 1 {
+		t.Fatalf("pager got %d alert(s), want 1", len(pager.alerts))
+	}
+	crit := pager.alerts[0]
+	if len(crit.Regressions) != 2 || crit.Regressions[0].QueryID != 1 || crit.Regressions[1].QueryID != 4 ||
+		crit.StaleData == nil || len(crit.CustomFindings) != 0 || len(crit.CallSpikes) != 0 || crit.ReqID != "r1" {
+		t.Errorf("pager alert = %+v, want regressions 1 and 4 and the stale data", crit)
+	}
+	if crit.Summary.RegressionCount != 2 || crit.Summary.CustomFindingCount != 0 || crit.Summary.CallSpikeCount != 0 ||
+		crit.Summary.HealthScore != 40 {
+		t.Errorf("pager summary = %+v, want 2 regressions and the alert's health score", crit.Summary)
+	}
+
+	// Infos land on the log channel only
+	if len(logChannel.alerts) != 1 {
+		t.Fatalf("log channel got %d alert(s), want 1", len(logChannel.alerts))
+	}
+	info := logChannel.alerts[0]
+	if len(info.Regressions) != 1 || info.Regressions[0].QueryID != 2 || len(info.CustomFindings) != 1 || info.StaleData != nil {
+		t.Errorf("log channel alert = %+v, want regression 2 and the custom finding", info)
+	}
+
+	// The primary keeps the unrouted severities and findings without one, and
+	// nothing already routed
+	if len(primary.alerts) != 1 {
+		t.Fatalf("primary got %d alert(s), want 1", len(primary.alerts))
+	}
+	rest := primary.alerts[0]
+	if len(rest.Regressions) != 1 || rest.Regressions[0].QueryID != 3 || len(rest.CustomFindings) != 0 || rest.StaleData != nil {
+		t.Errorf("primary alert = %+v, want only regression 3 among severity findings", rest)
+	}
+	if rest.Summary.RegressionCount != 1 || rest.Summary.CustomFindingCount != 0 || rest.Summary.CallSpikeCount != 1 {
+		t.Errorf("primary summary = %+v, want 1 regression and the call spike", rest.Summary)
+	}
+	if len(rest.CallSpikes) != 1 || len(rest.TopSlowSQL) != 1 {
+		t.Errorf("primary alert should keep call spikes and slow SQL, got %+v", rest)
+	}
+	if len(alert.Regressions) != 4 || alert.StaleData == nil || alert.Summary.RegressionCount != 4 {
+		t.Error("routing must not modify the original alert")
+	}
+}
+
+func TestRoutingNotifier_SendWithoutRoutedFindings(t *testing.T) {
+	primary, pager := &recordingNotifier{}, &recordingNotifier{}
+	n := NewRoutingNotifier(primary, map[string]Notifier{"critical": pager})
+
+	alert := &model.AlertContext{ReqID: "r1", Regressions: []model.RegressionItem{{QueryID: 1, Severity: "high"}}}
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(pager.alerts) != 0 {
+		t.Errorf("pager got %d alert(s) without critical findings, want none", len(pager.alerts))
+	}
+	if len(primary.alerts) != 1 || len(primary.alerts[0].Regressions) != 1 {
+		t.Errorf("primary should get the whole alert, got %+v", primary.alerts)
+	}
+}
+
+func TestRoutingNotifier_SendRouteFailure(t *testing.T) {
+	routeErr := errors.New("pager down")
+	primary, pager := &recordingNotifier{}, &recordingNotifier{err: routeErr}
+	n := NewRoutingNotifier(primary, map[string]Notifier{"critical": pager})
+
+	alert := &model.AlertContext{ReqID: "r1", Regressions: []model.RegressionItem{{QueryID: 1, Severity: "critical"}}}
+	err := n.Send(context.Background(), alert)
+	if !errors.Is(err, routeErr) {
+		t.Errorf("Send() error = %v, want the route error", err)
+	}
+	if len(primary.alerts) != 1 {
+		t.Error("a failed route must not hold back the primary")
+	}
+	if got, want := n.Name(), "recording (routes: critical: recording)"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/retry"
+)
+
+// SNS limits on a Publish request.
+const (
+	snsMaxSubject = 100
+	snsMaxMessage = 256 * 1024
+)
+
+// snsMessage is one Publish request to notifier.sns.topic_arn.
+type snsMessage struct {
+	TopicARN string
+	Subject  string
+	Message  string
+}
+
+// snsPublisher publishes to a topic. The AWS SDK backed implementation is only
+// built with -tags sns (see sns_client.go), so default builds do not depend on
+// the AWS SDK.
+type snsPublisher interface {
+	Publish(ctx context.Context, msg snsMessage) error
+}
+
+// SNSNotifier publishes each alert as JSON to an Amazon SNS topic.
+type SNSNotifier struct {
+	topicARN   string
+	retries    int
+	retryDelay time.Duration
+	title      *titleTemplate
+	publisher  snsPublisher
+}
+
+// NewSNSNotifier creates a new SNS notifier from cfg.SNS. Credentials come from
+// the AWS SDK default chain (environment, shared config, IRSA, instance profile).
+func NewSNSNotifier(cfg *config.NotifierConfig) (*SNSNotifier, error) {
+	if cfg.SNS.TopicARN == "" {
+		return nil, fmt.Errorf("sns topic_arn is required")
+	}
+	retryDelay, err := cfg.RetryDelayParsed()
+	if err != nil {
+		retryDelay = time.Second
+	}
+	title, err := newTitleTemplate(cfg.TitleTemplate)
+	if err != nil {
+		return nil, err
+	}
+	region := cfg.SNS.Region
+	if region == "" {
+		region = snsTopicRegion(cfg.SNS.TopicARN)
+	}
+	publisher, err := newSNSPublisher(region)
+	if err != nil {
+		return nil, err
+	}
+	return &SNSNotifier{
+		topicARN:   cfg.SNS.TopicARN,
+		retries:    cfg.Retries,
+		retryDelay: retryDelay,
+		title:      title,
+		publisher:  publisher,
+	}, nil
+}
+
+// Name returns the notifier name.
+func (n *SNSNotifier) Name() string {
+	return "sns"
+}
+
+// Send publishes the alert, retrying failed publishes up to notifier.retries
+// times with exponential backoff.
+func (n *SNSNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	msg, err := n.message(alert)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	delay := n.retryDelay
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			if err := retry.Wait(ctx, delay); err != nil {
+				return fmt.Errorf("retry cancelled after %d attempt(s): %w (last error: %v)", attempt, err, lastErr)
+			}
+			delay *= 2
+		}
+		err := n.publisher.Publish(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("publish cancelled on attempt %d: %w", attempt+1, ctx.Err())
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("publishing to sns topic %s failed after %d retries: %w", n.topicARN, n.retries, lastErr)
+}
+
+// message builds the Publish request: the JSON alert as the message, the
+// rendered title as the subject email subscriptions show.
+func (n *SNSNotifier) message(alert *model.AlertContext) (snsMessage, error) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return snsMessage{}, fmt.Errorf("encoding alert: %w", err)
+	}
+	if len(body) > snsMaxMessage {
+		return snsMessage{}, fmt.Errorf("alert is %d bytes of JSON, over the SNS limit of %d; lower analysis.max_findings", len(body), snsMaxMessage)
+	}
+	return snsMessage{TopicARN: n.topicARN, Subject: snsSubject(n.title.render(alert)), Message: string(body)}, nil
+}
+
+// snsSubject fits title to what SNS accepts as a subject: printable ASCII, at
+// most 100 characters. A title with nothing left falls back to the default.
+func snsSubject(title string) string {
+	subject := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, title)
+	subject = strings.Join(strings.Fields(subject), " ")
+	if subject == "" {
+		return DefaultTitleTemplate
+	}
+	if len(subject) > snsMaxSubject {
+		subject = strings.TrimSpace(subject[:snsMaxSubject-3]) + "..."
+	}
+	return subject
+}
+
+// snsTopicRegion returns the region of a topic ARN
+// (arn:aws:sns:<region>:<account>:<topic>), or "" when arn has none.
+func snsTopicRegion(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 {
+		return ""
+	}
+	return parts[3]
+}
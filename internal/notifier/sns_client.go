@@ -0,0 +1,42 @@
+//go:build sns
+
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// snsClient is the AWS SDK backed snsPublisher.
+type snsClient struct {
+	c *sns.Client
+}
+
+// newSNSPublisher returns a publisher using the AWS SDK default credential
+// chain. An empty region is resolved from the environment as well.
+func newSNSPublisher(region string) (snsPublisher, error) {
+	// notifier.retries governs retries, not the SDK's own retryer
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRetryMaxAttempts(1)}
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return &snsClient{c: sns.NewFromConfig(awsCfg)}, nil
+}
+
+// Publish sends msg as one SNS Publish request.
+func (p *snsClient) Publish(ctx context.Context, msg snsMessage) error {
+	_, err := p.c.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(msg.TopicARN),
+		Subject:  aws.String(msg.Subject),
+		Message:  aws.String(msg.Message),
+	})
+	return err
+}
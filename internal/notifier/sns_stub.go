@@ -0,0 +1,11 @@
+//go:build !sns
+
+package notifier
+
+import "errors"
+
+// newSNSPublisher is unavailable in default builds so the AWS SDK is not a
+// dependency for users who do not publish to SNS. Build with -tags sns.
+func newSNSPublisher(region string) (snsPublisher, error) {
+	return nil, errors.New("notifier type sns requires a binary built with -tags sns")
+}
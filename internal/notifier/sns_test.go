@@ -0,0 +1,109 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// mockPublisher records the messages it is asked to publish, failing the
+// first failures of them.
+type mockPublisher struct {
+	msgs     []snsMessage
+	failures int
+}
+
+func (p *mockPublisher) Publish(ctx context.Context, msg snsMessage) error {
+	p.msgs = append(p.msgs, msg)
+	if len(p.msgs) <= p.failures {
+		return errors.New("throttled")
+	}
+	return nil
+}
+
+func testSNSNotifier(t *testing.T, p *mockPublisher, titleTemplate string) *SNSNotifier {
+	t.Helper()
+	title, err := newTitleTemplate(titleTemplate)
+	if err != nil {
+		t.Fatalf("newTitleTemplate() error = %v", err)
+	}
+	return &SNSNotifier{
+		topicARN:   "arn:aws:sns:eu-west-1:123456789012:powa-alerts",
+		retries:    2,
+		retryDelay: time.Millisecond,
+		title:      title,
+		publisher:  p,
+	}
+}
+
+func TestSNSNotifier_Send(t *testing.T) {
+	p := &mockPublisher{}
+	n := testSNSNotifier(t, p, `[{{.Label "env"}}] {{.Count "critical"}} critical`)
+
+	alert := testCSVAlert()
+	alert.ReqID = "run-42"
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(p.msgs) != 1 {
+		t.Fatalf("expected one publish per alert, got %d", len(p.msgs))
+	}
+
+	msg := p.msgs[0]
+	if msg.TopicARN != n.topicARN || msg.Subject != "[prod] 0 critical" {
+		t.Errorf("publish = topic %q, subject %q; want the topic and rendered title", msg.TopicARN, msg.Subject)
+	}
+	var decoded model.AlertContext
+	if err := json.Unmarshal([]byte(msg.Message), &decoded); err != nil {
+		t.Fatalf("message is not an alert: %v\n%s", err, msg.Message)
+	}
+	if decoded.ReqID != "run-42" || len(decoded.Regressions) != 1 {
+		t.Errorf("message = %s, want the JSON alert", msg.Message)
+	}
+}
+
+func TestSNSNotifier_SendRetries(t *testing.T) {
+	p := &mockPublisher{failures: 2}
+	n := testSNSNotifier(t, p, "")
+	if err := n.Send(context.Background(), testCSVAlert()); err != nil || len(p.msgs) != 3 {
+		t.Errorf("Send() = %v after %d publishes, want success on the third", err, len(p.msgs))
+	}
+
+	p = &mockPublisher{failures: 5}
+	n = testSNSNotifier(t, p, "")
+	err := n.Send(context.Background(), testCSVAlert())
+	if err == nil || !strings.Contains(err.Error(), "powa-alerts") || len(p.msgs) != 3 {
+		t.Errorf("Send() = %v after %d publishes, want a failure naming the topic after 3", err, len(p.msgs))
+	}
+}
+
+func TestSNSSubject(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"PoWA Sentinel Report", "PoWA Sentinel Report"},
+		{"🚨 3 critical\tfindings", "3 critical findings"},
+		{"📈", DefaultTitleTemplate},
+		{strings.Repeat("a", 120), strings.Repeat("a", 97) + "..."},
+	}
+	for _, tt := range tests {
+		if got := snsSubject(tt.title); got != tt.want {
+			t.Errorf("snsSubject(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSNSTopicRegion(t *testing.T) {
+	if got := snsTopicRegion("arn:aws:sns:eu-west-1:123456789012:powa-alerts"); got != "eu-west-1" {
+		t.Errorf("snsTopicRegion() = %q, want eu-west-1", got)
+	}
+	if got := snsTopicRegion("powa-alerts"); got != "" {
+		t.Errorf("snsTopicRegion() = %q for a bare name, want empty", got)
+	}
+}
@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// SuppressingNotifier wraps a Notifier and skips sending when an alert's
+// findings are identical to the last alert that was sent. An unchanged alert
+// is still re-sent once forceInterval has passed since the last send.
+type SuppressingNotifier struct {
+	inner         Notifier
+	forceInterval time.Duration // 0 never forces a re-send
+	now           func() time.Time
+
+	mu       sync.Mutex
+	lastHash string
+	lastSent time.Time
+}
+
+// NewSuppressingNotifier wraps inner. The last hash is kept in memory only.
+func NewSuppressingNotifier(inner Notifier, forceInterval time.Duration) *SuppressingNotifier {
+	return &SuppressingNotifier{
+		inner:         inner,
+		forceInterval: forceInterval,
+		now:           time.Now,
+	}
+}
+
+// Name returns the wrapped notifier's name.
+func (s *SuppressingNotifier) Name() string {
+	return s.inner.Name()
+}
+
+// Send forwards the alert unless its findings match the previously sent alert.
+func (s *SuppressingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	hash := FindingsHash(alert)
+	now := s.now()
+
+	s.mu.Lock()
+	unchanged := hash == s.lastHash
+	due := s.forceInterval > 0 && now.Sub(s.lastSent) >= s.forceInterval
+	s.mu.Unlock()
+
+	if unchanged && !due {
+		log.Printf("Findings unchanged since %s; skipping notification (report %s)",
+			s.lastSent.Format(time.RFC3339), alert.ReqID)
+		return nil
+	}
+
+	if err := s.inner.Send(ctx, alert); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.lastHash = hash
+	s.lastSent = now
+	s.mu.Unlock()
+	return nil
+}
+
+// FindingsHash returns a stable hash of an alert's findings and their metrics.
+// Run-specific fields (report ID, timestamps, windows) are excluded so two runs
+// that found the same problems hash identically.
+func FindingsHash(alert *model.AlertContext) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "health %d %s\n", alert.Summary.HealthScore, alert.Summary.HealthStatus)
+	if alert.StaleData != nil {
+		// Age grows every run; the snapshot time only changes once the collector recovers
+		fmt.Fprintf(h, "stale %d\n", alert.StaleData.LatestSnapshot.Unix())
+	}
+	for _, q := range alert.TopSlowSQL {
+		fmt.Fprintf(h, "slow %d %s %s %d %v %v %d %v %v\n", q.QueryID, q.ServerName, q.DatabaseName, q.UserID,
+			q.TotalTime, q.MeanTime, q.Calls, q.TotalCPUTime(), q.IOTime())
+	}
+	for _, r := range alert.Regressions {
+		fmt.Fprintf(h, "regression %d %s %s %d %s %t %v %v %v\n", r.QueryID, r.ServerName, r.DatabaseName, r.UserID,
+			r.Severity, r.IsNewQuery, r.BaselineMeanTime, r.CurrentMeanTime, r.ChangePercent)
+	}
+	for _, cs := range alert.CallSpikes {
+		fmt.Fprintf(h, "call_spike %d %s %s %d %d %d\n", cs.QueryID, cs.ServerName, cs.DatabaseName, cs.UserID,
+			cs.BaselineCalls, cs.CurrentCalls)
+	}
+	for _, we := range alert.WaitEvents {
+		fmt.Fprintf(h, "wait_events %d %s %s %s:%s %v\n", we.QueryID, we.ServerName, we.DatabaseName,
+			we.DominantEventType, we.DominantEvent, we.WaitPercent)
+	}
+	for _, v := range alert.Vacuum {
+		fmt.Fprintf(h, "vacuum %s %s %d %s\n", v.DatabaseName, v.FullTableName(), v.DeadTuples, strings.Join(v.Reasons, ","))
+	}
+	for _, f := range alert.Flapping {
+		fmt.Fprintf(h, "flapping %d %s %s %v %v\n", f.QueryID, f.ServerName, f.DatabaseName, f.MeanTime, f.CV)
+	}
+	for _, c := range alert.CrossServer {
+		fmt.Fprintf(h, "cross_server %d %s %s %v %v\n", c.QueryID, c.ServerName, c.DatabaseName, c.ReferenceMeanTime, c.MeanTime)
+	}
+	if wg := alert.WorkloadGrowth; wg != nil {
+		fmt.Fprintf(h, "workload_growth %v %v\n", wg.BaselineTotalTime, wg.CurrentTotalTime)
+		for _, c := range wg.TopContributors {
+			fmt.Fprintf(h, "workload_contributor %d %s %s %v\n", c.QueryID, c.ServerName, c.DatabaseName, c.AddedTime)
+		}
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		fmt.Fprintf(h, "connection_saturation %d %d\n", cs.Total, cs.MaxConnections)
+	}
+	for _, sg := range alert.Suggestions {
+		fmt.Fprintf(h, "suggestion %s %s %v %d\n", sg.FullTableName(), strings.Join(sg.Columns, ","),
+			sg.EstImprovementPercent, sg.AffectedQueries)
+	}
+	for _, f := range alert.CustomFindings {
+		fmt.Fprintf(h, "custom %s %s %v ", f.Rule, f.Column, f.Value)
+		writeSortedMap(h, f.Row)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeSortedMap writes m in key order so map iteration order cannot change the hash.
+func writeSortedMap(w io.Writer, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%q=%q ", k, m[k])
+	}
+	fmt.Fprintln(w)
+}
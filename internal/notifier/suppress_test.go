@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// countingNotifier records how many alerts reached it.
+type countingNotifier struct {
+	sent int
+}
+
+func (c *countingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	c.sent++
+	return nil
+}
+
+func (c *countingNotifier) Name() string {
+	return "counting"
+}
+
+func testSuppressAlert(reqID string, meanTime float64) *model.AlertContext {
+	return &model.AlertContext{
+		ReqID:     reqID,
+		Timestamp: time.Now(),
+		Summary:   model.AlertSummary{HealthScore: 90, HealthStatus: "healthy"},
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, DatabaseName: "app", Severity: "medium", BaselineMeanTime: 10, CurrentMeanTime: meanTime, ChangePercent: 60},
+		},
+		CustomFindings: []model.CustomFinding{
+			{Rule: "dead", Column: "n", Value: 5, Row: map[string]string{"relname": "orders", "n": "5"}},
+		},
+	}
+}
+
+func TestFindingsHash(t *testing.T) {
+	base := FindingsHash(testSuppressAlert("a", 16))
+
+	if got := FindingsHash(testSuppressAlert("b", 16)); got != base {
+		t.Error("hash should ignore report ID and timestamp")
+	}
+	if got := FindingsHash(testSuppressAlert("a", 17)); got == base {
+		t.Error("changing a finding's metric should change the hash")
+	}
+}
+
+func TestSuppressingNotifier(t *testing.T) {
+	inner := &countingNotifier{}
+	n := NewSuppressingNotifier(inner, time.Hour)
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	n.Send(ctx, testSuppressAlert("r1", 16))
+	if inner.sent != 1 {
+		t.Fatalf("first alert should be sent, sent=%d", inner.sent)
+	}
+
+	now = now.Add(10 * time.Minute)
+	n.Send(ctx, testSuppressAlert("r2", 16))
+	if inner.sent != 1 {
+		t.Errorf("identical alert should be suppressed, sent=%d", inner.sent)
+	}
+
+	now = now.Add(10 * time.Minute)
+	n.Send(ctx, testSuppressAlert("r3", 17))
+	if inner.sent != 2 {
+		t.Errorf("changed metric should re-notify, sent=%d", inner.sent)
+	}
+
+	now = now.Add(time.Hour)
+	n.Send(ctx, testSuppressAlert("r4", 17))
+	if inner.sent != 3 {
+		t.Errorf("unchanged alert should be re-sent after force interval, sent=%d", inner.sent)
+	}
+
+	if n.Name() != "counting" {
+		t.Errorf("Name() = %q, want wrapped notifier name", n.Name())
+	}
+}
@@ -0,0 +1,287 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+const (
+	syslogAppName = "powa-sentinel"
+	// syslogSDID is the structured data element carrying finding details. 32473 is the
+	// enterprise number RFC 5612 reserves for documentation and private use.
+	syslogSDID        = "powa@32473"
+	syslogMaxQuery    = 512 // bytes of query text kept in each message
+	syslogDialTimeout = 10 * time.Second
+)
+
+// defaultSyslogSeverities maps alert and health severities to syslog severity names.
+var defaultSyslogSeverities = map[string]string{
+	"critical": "crit",
+	"high":     "err",
+	"medium":   "warning",
+	"low":      "notice",
+	"info":     "info",
+	"warning":  "warning",
+	"healthy":  "info",
+	"":         "notice", // findings without a severity (slow SQL, index suggestions)
+}
+
+// SyslogNotifier writes one RFC 5424 message per finding to a syslog receiver.
+type SyslogNotifier struct {
+	network    string
+	address    string
+	facility   int
+	severities map[string]int
+	hostname   string
+	pid        int
+	dial       func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewSyslogNotifier creates a new syslog notifier from cfg.Syslog.
+func NewSyslogNotifier(cfg *config.NotifierConfig) (*SyslogNotifier, error) {
+	sc := cfg.Syslog
+	if sc.Address == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+	network := sc.Network
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	facilityName := sc.Facility
+	if facilityName == "" {
+		facilityName = "local0"
+	}
+	facility, ok := config.SyslogFacilityCode(facilityName)
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facilityName)
+	}
+
+	severities := make(map[string]int, len(defaultSyslogSeverities))
+	for sev, name := range defaultSyslogSeverities {
+		severities[sev], _ = config.SyslogSeverityCode(name)
+	}
+	for sev, name := range sc.Severities {
+		code, ok := config.SyslogSeverityCode(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog severity %q for %q", name, sev)
+		}
+		severities[sev] = code
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	d := &net.Dialer{Timeout: syslogDialTimeout}
+	return &SyslogNotifier{
+		network:    network,
+		address:    sc.Address,
+		facility:   facility,
+		severities: severities,
+		hostname:   hostname,
+		pid:        os.Getpid(),
+		dial:       d.DialContext,
+	}, nil
+}
+
+// Name returns the notifier name.
+func (s *SyslogNotifier) Name() string {
+	return "syslog"
+}
+
+// Send writes a summary message followed by one message per finding.
+func (s *SyslogNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	conn, err := s.dial(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("connecting to syslog %s://%s: %w", s.network, s.address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	for _, msg := range s.formatMessages(alert) {
+		if s.network == "tcp" {
+			// RFC 6587 octet-counting framing
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return fmt.Errorf("writing to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// formatMessages renders the alert as RFC 5424 messages.
+func (s *SyslogNotifier) formatMessages(alert *model.AlertContext) []string {
+	ts := alert.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	base := []sdParam{{"reqid", alert.ReqID}}
+	labelKeys := make([]string, 0, len(alert.Labels))
+	for k := range alert.Labels {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	for _, k := range labelKeys {
+		base = append(base, sdParam{"label." + k, alert.Labels[k]})
+	}
+
+	summary := fmt.Sprintf("health=%d status=%s slow=%d regressions=%d new_queries=%d call_spikes=%d wait_events=%d suggestions=%d",
+		alert.Summary.HealthScore, alert.Summary.HealthStatus, alert.Summary.SlowQueryCount,
+		alert.Summary.RegressionCount, alert.Summary.NewQueryCount, alert.Summary.CallSpikeCount,
+		alert.Summary.WaitEventCount, alert.Summary.SuggestionCount)
+	msgs := []string{s.format(ts, alert.Summary.HealthStatus, "summary", base, summary)}
+
+	if sd := alert.StaleData; sd != nil {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"severity", sd.Severity}, sdParam{"age", sd.Age.String()}, sdParam{"max_age", sd.MaxAge.String()})
+		if !sd.LatestSnapshot.IsZero() {
+			params = append(params, sdParam{"latest_snapshot", sd.LatestSnapshot.Format(time.RFC3339)})
+		}
+		msgs = append(msgs, s.format(ts, sd.Severity, "stale_data", params, formatStaleData(sd)))
+	}
+	for _, q := range alert.TopSlowSQL {
+		params := append(findingParams(base, q.QueryID, q.DatabaseName, q.ServerName, ""),
+			sdParam{"total_time_ms", formatFloat(q.TotalTime)}, sdParam{"calls", strconv.FormatInt(q.Calls, 10)})
+		msgs = append(msgs, s.format(ts, "", "slow_sql", params, truncateQuery(q.Query, syslogMaxQuery)))
+	}
+	for _, r := range alert.Regressions {
+		msgID := "regression"
+		params := findingParams(base, r.QueryID, r.DatabaseName, r.ServerName, r.Severity)
+		if r.IsNewQuery {
+			msgID = "new_query"
+		} else {
+			params = append(params, sdParam{"baseline_mean_ms", formatFloat(r.BaselineMeanTime)},
+				sdParam{"change_percent", formatFloat(r.ChangePercent)})
+		}
+		params = append(params, sdParam{"current_mean_ms", formatFloat(r.CurrentMeanTime)})
+		msgs = append(msgs, s.format(ts, r.Severity, msgID, params, truncateQuery(r.Query, syslogMaxQuery)))
+	}
+	for _, cs := range alert.CallSpikes {
+		params := append(findingParams(base, cs.QueryID, cs.DatabaseName, cs.ServerName, ""),
+			sdParam{"baseline_calls", strconv.FormatInt(cs.BaselineCalls, 10)},
+			sdParam{"current_calls", strconv.FormatInt(cs.CurrentCalls, 10)},
+			sdParam{"change_percent", formatFloat(cs.ChangePercent)})
+		msgs = append(msgs, s.format(ts, "", "call_spike", params, truncateQuery(cs.Query, syslogMaxQuery)))
+	}
+	for _, we := range alert.WaitEvents {
+		params := append(findingParams(base, we.QueryID, we.DatabaseName, we.ServerName, ""),
+			sdParam{"total_time_ms", formatFloat(we.TotalTime)},
+			sdParam{"wait_time_ms", formatFloat(we.WaitTime)},
+			sdParam{"wait_percent", formatFloat(we.WaitPercent)},
+			sdParam{"wait_event", we.DominantEventType + ":" + we.DominantEvent})
+		msgs = append(msgs, s.format(ts, "", "wait_events", params, truncateQuery(we.Query, syslogMaxQuery)))
+	}
+	for _, f := range alert.Flapping {
+		params := append(findingParams(base, f.QueryID, f.DatabaseName, f.ServerName, ""),
+			sdParam{"mean_time_ms", formatFloat(f.MeanTime)}, sdParam{"cv", formatFloat(f.CV)},
+			sdParam{"windows", strconv.Itoa(len(f.Series))})
+		msgs = append(msgs, s.format(ts, "", "flapping", params, truncateQuery(f.Query, syslogMaxQuery)))
+	}
+	for _, c := range alert.CrossServer {
+		params := append(findingParams(base, c.QueryID, c.DatabaseName, c.ServerName, ""),
+			sdParam{"reference_server", c.ReferenceServerName},
+			sdParam{"reference_mean_ms", formatFloat(c.ReferenceMeanTime)},
+			sdParam{"mean_time_ms", formatFloat(c.MeanTime)},
+			sdParam{"change_percent", formatFloat(c.ChangePercent)})
+		msgs = append(msgs, s.format(ts, "", "cross_server", params, truncateQuery(c.Query, syslogMaxQuery)))
+	}
+	if wg := alert.WorkloadGrowth; wg != nil {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"baseline_total_time_ms", formatFloat(wg.BaselineTotalTime)},
+			sdParam{"current_total_time_ms", formatFloat(wg.CurrentTotalTime)},
+			sdParam{"change_percent", formatFloat(wg.ChangePercent)})
+		msgs = append(msgs, s.format(ts, "", "workload_growth", params, formatWorkloadGrowth(wg)))
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"connections", strconv.Itoa(cs.Total)},
+			sdParam{"max_connections", strconv.Itoa(cs.MaxConnections)},
+			sdParam{"usage_percent", formatFloat(cs.UsagePercent)})
+		msgs = append(msgs, s.format(ts, "", "connection_saturation", params, formatConnectionSaturation(cs)))
+	}
+	for _, v := range alert.Vacuum {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"database", v.DatabaseName}, sdParam{"table", v.FullTableName()},
+			sdParam{"n_dead_tup", strconv.FormatInt(v.DeadTuples, 10)},
+			sdParam{"dead_ratio_percent", formatFloat(v.DeadRatioPercent)},
+			sdParam{"reasons", strings.Join(v.Reasons, ",")})
+		msgs = append(msgs, s.format(ts, "", "vacuum", params, formatVacuum(alert, v)))
+	}
+	for _, sg := range alert.Suggestions {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"table", sg.FullTableName()}, sdParam{"columns", strings.Join(sg.Columns, ",")},
+			sdParam{"est_improvement_percent", formatFloat(sg.EstImprovementPercent)},
+			sdParam{"affected_queries", strconv.Itoa(sg.AffectedQueries)})
+		msg := sg.SuggestedDDL
+		if msg == "" {
+			msg = fmt.Sprintf("index on %s (%s)", sg.FullTableName(), strings.Join(sg.Columns, ", "))
+		}
+		msgs = append(msgs, s.format(ts, "", "index_suggestion", params, truncateQuery(msg, syslogMaxQuery)))
+	}
+	for _, f := range alert.CustomFindings {
+		params := append(append([]sdParam(nil), base...),
+			sdParam{"rule", f.Rule}, sdParam{"severity", f.Severity}, sdParam{"column", f.Column},
+			sdParam{"value", formatFloat(f.Value)}, sdParam{"threshold", f.Operator + " " + formatFloat(f.Threshold)})
+		msgs = append(msgs, s.format(ts, f.Severity, "custom", params, truncateQuery(formatCustomRow(f), syslogMaxQuery)))
+	}
+	return msgs
+}
+
+// format builds a single RFC 5424 message.
+func (s *SyslogNotifier) format(ts time.Time, severity, msgID string, params []sdParam, msg string) string {
+	sev, ok := s.severities[severity]
+	if !ok {
+		sev = s.severities[""]
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		sd.WriteString(fmt.Sprintf(" %s=\"%s\"", p.name, escapeSDValue(p.value)))
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.facility*8+sev, ts.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, syslogAppName, s.pid, msgID, sd.String(), msg)
+}
+
+// sdParam is one structured data parameter.
+type sdParam struct {
+	name  string
+	value string
+}
+
+// findingParams copies base and appends the parameters shared by query findings.
+func findingParams(base []sdParam, queryID int64, database, server, severity string) []sdParam {
+	return append(append([]sdParam(nil), base...),
+		sdParam{"queryid", strconv.FormatInt(queryID, 10)},
+		sdParam{"database", database},
+		sdParam{"server", server},
+		sdParam{"severity", severity})
+}
+
+// escapeSDValue escapes the characters RFC 5424 reserves in PARAM-VALUE.
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
@@ -0,0 +1,162 @@
+package notifier
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// rfc5424Pattern matches HEADER SP STRUCTURED-DATA SP MSG.
+var rfc5424Pattern = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) powa-sentinel (\d+) (\S+) (\[powa@32473[^\n]*?\]) (.*)$`)
+
+func TestSyslogNotifier_SendUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	n, err := NewSyslogNotifier(&config.NotifierConfig{Syslog: config.SyslogConfig{
+		Address:    listener.LocalAddr().String(),
+		Facility:   "local3",
+		Severities: map[string]string{"medium": "err"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+
+	alert := &model.AlertContext{
+		ReqID:     "req-1",
+		Timestamp: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		Labels:    map[string]string{"env": "prod"},
+		Summary:   model.AlertSummary{HealthScore: 70, HealthStatus: "warning", RegressionCount: 1},
+		TopSlowSQL: []model.MetricSnapshot{
+			{QueryID: 1, Query: "SELECT *\n  FROM orders", DatabaseName: "app", TotalTime: 1500, Calls: 3},
+		},
+		Regressions: []model.RegressionItem{
+			{QueryID: 2, Query: `SELECT "x]" FROM t`, DatabaseName: "app", Severity: "medium",
+				BaselineMeanTime: 10, CurrentMeanTime: 20, ChangePercent: 100},
+		},
+	}
+
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 4096)
+	var got []string
+	for i := 0; i < 3; i++ {
+		size, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("reading datagram %d: %v", i+1, err)
+		}
+		got = append(got, string(buf[:size]))
+	}
+
+	// local3 = 19; warning = 4, notice = 5, err = 3 (overridden for medium)
+	expected := []struct {
+		pri, msgID, msg string
+		sd              []string
+	}{
+		{"156", "summary", "health=70 status=warning slow=0 regressions=1 new_queries=0 call_spikes=0 wait_events=0 suggestions=0",
+			[]string{`reqid="req-1"`, `label.env="prod"`}},
+		{"157", "slow_sql", "SELECT * FROM orders",
+			[]string{`queryid="1"`, `database="app"`, `total_time_ms="1500"`, `calls="3"`}},
+		{"155", "regression", `SELECT "x]" FROM t`,
+			[]string{`queryid="2"`, `severity="medium"`, `change_percent="100"`}},
+	}
+
+	for i, want := range expected {
+		m := rfc5424Pattern.FindStringSubmatch(got[i])
+		if m == nil {
+			t.Fatalf("message %d is not RFC 5424: %q", i+1, got[i])
+		}
+		if m[1] != want.pri {
+			t.Errorf("message %d PRI = %s, want %s", i+1, m[1], want.pri)
+		}
+		if m[2] != "2024-05-01T09:00:00.000000Z" {
+			t.Errorf("message %d timestamp = %s", i+1, m[2])
+		}
+		if m[5] != want.msgID {
+			t.Errorf("message %d MSGID = %s, want %s", i+1, m[5], want.msgID)
+		}
+		if m[7] != want.msg {
+			t.Errorf("message %d MSG = %q, want %q", i+1, m[7], want.msg)
+		}
+		for _, param := range want.sd {
+			if !strings.Contains(m[6], param) {
+				t.Errorf("message %d structured data %s missing %s", i+1, m[6], param)
+			}
+		}
+	}
+}
+
+func TestSyslogNotifier_SendTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sb strings.Builder
+		buf := make([]byte, 4096)
+		for {
+			size, err := conn.Read(buf)
+			sb.Write(buf[:size])
+			if err != nil {
+				break
+			}
+		}
+		received <- sb.String()
+	}()
+
+	n, err := NewSyslogNotifier(&config.NotifierConfig{Syslog: config.SyslogConfig{
+		Address: listener.Addr().String(),
+		Network: "tcp",
+	}})
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+	if err := n.Send(context.Background(), &model.AlertContext{Summary: model.AlertSummary{HealthStatus: "healthy"}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		size, msg, ok := strings.Cut(data, " ")
+		if !ok || size != strconv.Itoa(len(msg)) {
+			t.Errorf("frame not octet-counted: %q", data)
+		}
+		// local0 = 16; healthy maps to info = 6
+		if !strings.HasPrefix(msg, "<134>1 ") {
+			t.Errorf("unexpected message %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestNewSyslogNotifier_InvalidFacility(t *testing.T) {
+	_, err := NewSyslogNotifier(&config.NotifierConfig{Syslog: config.SyslogConfig{
+		Address:  "127.0.0.1:514",
+		Facility: "local9",
+	}})
+	if err == nil {
+		t.Error("expected error for unknown facility")
+	}
+}
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// AlertStore persists the alerts a TeeNotifier is asked to send.
+type AlertStore interface {
+	SaveAlert(ctx context.Context, alert *model.AlertContext) error
+}
+
+// TeeNotifier wraps a Notifier and, on every Send, logs a one-line summary of
+// the alert and saves it to the store before forwarding it, so what was tried
+// is recorded whichever channel is configured and whether or not the send
+// succeeds. A store that fails is logged and does not hold back the alert.
+type TeeNotifier struct {
+	inner Notifier
+	store AlertStore
+}
+
+// NewTeeNotifier wraps inner. A nil store only logs.
+func NewTeeNotifier(inner Notifier, store AlertStore) *TeeNotifier {
+	return &TeeNotifier{inner: inner, store: store}
+}
+
+// Name returns the wrapped notifier's name.
+func (t *TeeNotifier) Name() string {
+	return t.inner.Name()
+}
+
+// Send records the alert, then forwards it.
+func (t *TeeNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	log.Printf("Sending report %s via %s: %s", alert.ReqID, t.inner.Name(), formatSummaryLine(alert))
+	if t.store != nil {
+		if err := t.store.SaveAlert(ctx, alert); err != nil {
+			log.Printf("Warning: failed to store report %s: %v", alert.ReqID, err)
+		}
+	}
+	return t.inner.Send(ctx, alert)
+}
+
+// formatSummaryLine summarizes the alert's findings and health in one line,
+// e.g. "3 finding(s), worst critical regression; health 62/100 (warning)".
+func formatSummaryLine(alert *model.AlertContext) string {
+	s := fmt.Sprintf("%d finding(s)", len(findingRefs(alert)))
+	if ref, ok := worstFinding(alert); ok && ref.Severity != "" {
+		s += fmt.Sprintf(", worst %s %s", ref.Severity, ref.Rule)
+	}
+	return s + fmt.Sprintf("; health %d/100 (%s)", alert.Summary.HealthScore, alert.Summary.HealthStatus)
+}
@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// memoryStore records saved alerts and fails with err when set.
+type memoryStore struct {
+	alerts []*model.AlertContext
+	err    error
+}
+
+func (s *memoryStore) SaveAlert(ctx context.Context, alert *model.AlertContext) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func TestTeeNotifier_Send(t *testing.T) {
+	alert := &model.AlertContext{ReqID: "r1", Regressions: []model.RegressionItem{{QueryID: 1, Severity: "high"}}}
+
+	inner, store := &recordingNotifier{}, &memoryStore{}
+	n := NewTeeNotifier(inner, store)
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 1 || inner.alerts[0] != alert {
+		t.Errorf("forwarded %d alert(s), want the alert", len(inner.alerts))
+	}
+	if len(store.alerts) != 1 || store.alerts[0] != alert {
+		t.Errorf("stored %d alert(s), want the alert", len(store.alerts))
+	}
+
+	// A failed send is still recorded and its error returned
+	inner.err = errors.New("webhook down")
+	if err := n.Send(context.Background(), alert); err == nil {
+		t.Error("Send() should return the inner notifier's error")
+	}
+	if len(store.alerts) != 2 {
+		t.Errorf("stored %d alert(s), want the failed send recorded too", len(store.alerts))
+	}
+
+	// A failing store does not hold back the alert
+	inner.err, store.err = nil, errors.New("disk full")
+	if err := n.Send(context.Background(), alert); err != nil {
+		t.Errorf("Send() error = %v, want the store error logged only", err)
+	}
+	if len(inner.alerts) != 3 {
+		t.Errorf("forwarded %d alert(s), want 3", len(inner.alerts))
+	}
+
+	// Without a store the tee only logs
+	if err := NewTeeNotifier(inner, nil).Send(context.Background(), alert); err != nil || len(inner.alerts) != 4 {
+		t.Errorf("Send() without store = %v after %d alert(s), want it forwarded", err, len(inner.alerts))
+	}
+}
+
+func TestFormatSummaryLine(t *testing.T) {
+	alert := &model.AlertContext{
+		Regressions: []model.RegressionItem{{QueryID: 1, Severity: "low"}, {QueryID: 2, Severity: "critical"}},
+		CallSpikes:  []model.CallSpikeItem{{QueryID: 3}},
+		Summary:     model.AlertSummary{HealthScore: 62, HealthStatus: "warning"},
+	}
+	if got, want := formatSummaryLine(alert), "3 finding(s), worst critical regression; health 62/100 (warning)"; got != want {
+		t.Errorf("formatSummaryLine() = %q, want %q", got, want)
+	}
+	if got, want := formatSummaryLine(&model.AlertContext{Summary: model.AlertSummary{HealthScore: 100, HealthStatus: "healthy"}}),
+		"0 finding(s); health 100/100 (healthy)"; got != want {
+		t.Errorf("formatSummaryLine(empty) = %q, want %q", got, want)
+	}
+}
+
+func TestProberOf_LooksThroughTeeAndThreshold(t *testing.T) {
+	wecom := &WeComNotifier{}
+	n := NewThresholdNotifier(NewTeeNotifier(wecom, nil), 2, "")
+	if p, ok := ProberOf(n); !ok || p != Prober(wecom) {
+		t.Errorf("ProberOf(threshold(tee(wecom))) = %v, %v; want the WeCom notifier", p, ok)
+	}
+}
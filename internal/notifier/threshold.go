@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"context"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// ThresholdNotifier wraps a Notifier and holds back alerts with fewer than
+// notifier.min_findings findings at or above notifier.min_severity, so one
+// borderline finding per run does not ping anyone. With a minimum severity,
+// findings without one (call spikes, index suggestions, ...) never count.
+type ThresholdNotifier struct {
+	inner       Notifier
+	minFindings int
+	minSeverity string
+}
+
+// NewThresholdNotifier wraps inner. A minFindings below 1 requires a single
+// finding, which with minSeverity set holds back alerts without one at or
+// above it.
+func NewThresholdNotifier(inner Notifier, minFindings int, minSeverity string) *ThresholdNotifier {
+	return &ThresholdNotifier{inner: inner, minFindings: max(minFindings, 1), minSeverity: minSeverity}
+}
+
+// Name returns the wrapped notifier's name.
+func (n *ThresholdNotifier) Name() string {
+	return n.inner.Name()
+}
+
+// Send forwards the alert when it has enough findings.
+func (n *ThresholdNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	if count := n.count(alert); count < n.minFindings {
+		counted := "finding(s)"
+		if n.minSeverity != "" {
+			counted += " at or above " + n.minSeverity
+		}
+		log.Printf("%d %s, below notification threshold of %d; skipping notification (report %s)",
+			count, counted, n.minFindings, alert.ReqID)
+		return nil
+	}
+	return n.inner.Send(ctx, alert)
+}
+
+// count returns the alert's findings at or above the minimum severity.
+func (n *ThresholdNotifier) count(alert *model.AlertContext) int {
+	refs := findingRefs(alert)
+	if n.minSeverity == "" {
+		return len(refs)
+	}
+	threshold := model.SeverityRank(n.minSeverity)
+	count := 0
+	for _, ref := range refs {
+		if rank := model.SeverityRank(ref.Severity); rank > 0 && rank >= threshold {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestThresholdNotifier(t *testing.T) {
+	alert := &model.AlertContext{
+		ReqID: "r1",
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, Severity: "high"},
+			{QueryID: 2, Severity: "low"},
+		},
+		CallSpikes: []model.CallSpikeItem{{QueryID: 3}},
+	}
+	tests := []struct {
+		name        string
+		minFindings int
+		minSeverity string
+		wantSent    bool
+	}{
+		{"meets the minimum", 3, "", true},
+		{"below the minimum", 4, "", false},
+		{"severity alone requires one finding", 0, "high", true},
+		{"no finding at the severity", 0, "critical", false},
+		// Only the high regression counts: the low one and the call spike without severity do not
+		{"severity filters the count", 2, "medium", false},
+		{"severity and minimum met", 2, "low", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &recordingNotifier{}
+			n := NewThresholdNotifier(inner, tt.minFindings, tt.minSeverity)
+			if err := n.Send(context.Background(), alert); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if sent := len(inner.alerts) == 1; sent != tt.wantSent {
+				t.Errorf("sent = %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+
+	// An alert without findings is held back by any threshold
+	inner := &recordingNotifier{}
+	if err := NewThresholdNotifier(inner, 1, "").Send(context.Background(), &model.AlertContext{ReqID: "quiet"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(inner.alerts) != 0 {
+		t.Errorf("alert without findings was sent")
+	}
+}
@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// DefaultTitleTemplate is the message title used when notifier.title_template is empty.
+const DefaultTitleTemplate = "PoWA Sentinel Report"
+
+// titleTemplate renders notifier.title_template for notifiers with a title
+// line. Templates execute against titleData, e.g.
+// `[{{.Label "env"}}] {{.Count "critical"}} critical findings`.
+type titleTemplate struct {
+	tmpl *template.Template
+}
+
+// newTitleTemplate parses text, or DefaultTitleTemplate when it is empty, and
+// renders it once against an empty alert so unknown fields fail at startup.
+func newTitleTemplate(text string) (*titleTemplate, error) {
+	if text == "" {
+		text = DefaultTitleTemplate
+	}
+	tmpl, err := template.New("title").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing title template: %w", err)
+	}
+	t := &titleTemplate{tmpl: tmpl}
+	if _, err := t.execute(&model.AlertContext{}); err != nil {
+		return nil, fmt.Errorf("rendering title template: %w", err)
+	}
+	return t, nil
+}
+
+// render returns the title for alert. A template that fails on this alert
+// falls back to DefaultTitleTemplate rather than dropping the notification.
+func (t *titleTemplate) render(alert *model.AlertContext) string {
+	title, err := t.execute(alert)
+	if err != nil {
+		log.Printf("Warning: rendering title template: %v", err)
+		return DefaultTitleTemplate
+	}
+	return title
+}
+
+func (t *titleTemplate) execute(alert *model.AlertContext) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, titleData{AlertContext: alert, refs: findingRefs(alert)}); err != nil {
+		return "", err
+	}
+	// A title is a single line
+	return strings.Join(strings.Fields(sb.String()), " "), nil
+}
+
+// titleData is what title templates see: the alert's fields plus counting helpers.
+type titleData struct {
+	*model.AlertContext
+	refs []model.FindingRef
+}
+
+// Count returns the number of findings with the given severity (critical,
+// high, medium, low, info).
+func (d titleData) Count(severity string) int {
+	n := 0
+	for _, ref := range d.refs {
+		if ref.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Findings returns the number of findings of any severity, without the slow SQL ranking.
+func (d titleData) Findings() int {
+	return len(d.refs)
+}
+
+// Label returns the value of an alert label, or "" when it is not set.
+func (d titleData) Label(key string) string {
+	return d.Labels[key]
+}
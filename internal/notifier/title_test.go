@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+func TestTitleTemplate_Render(t *testing.T) {
+	alert := &model.AlertContext{
+		Labels: map[string]string{"env": "prod"},
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, Severity: "critical"},
+			{QueryID: 2, Severity: "critical"},
+			{QueryID: 3, Severity: "critical"},
+			{QueryID: 4, Severity: "medium"},
+		},
+		Suggestions: []model.IndexSuggestion{{Table: "orders", Columns: []string{"id"}}},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"default", "", DefaultTitleTemplate},
+		{"critical regressions", `[{{.Label "env"}}] {{.Count "critical"}} critical regressions`, "[prod] 3 critical regressions"},
+		{"all findings", `{{.Findings}} findings, health {{.Summary.HealthScore}}`, "5 findings, health 0"},
+		{"missing label", `[{{.Label "region"}}] report`, "[] report"},
+		{"multi-line collapsed", "PoWA\n  {{.Count \"medium\"}} medium", "PoWA 1 medium"},
+	}
+	for _, tt := range tests {
+		title, err := newTitleTemplate(tt.text)
+		if err != nil {
+			t.Fatalf("%s: newTitleTemplate() error = %v", tt.name, err)
+		}
+		if got := title.render(alert); got != tt.want {
+			t.Errorf("%s: render() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTitleTemplate_Invalid(t *testing.T) {
+	for _, text := range []string{`{{.Count "critical"`, `{{.NoSuchField}}`} {
+		if _, err := newTitleTemplate(text); err == nil {
+			t.Errorf("newTitleTemplate(%q) expected error", text)
+		}
+	}
+}
+
+func TestWeCom_TitleTemplate(t *testing.T) {
+	w, err := NewWeComNotifier(&config.NotifierConfig{
+		WebhookURL:    "http://localhost",
+		RetryDelay:    "1ms",
+		TitleTemplate: `{{.Count "critical"}} critical regressions`,
+	})
+	if err != nil {
+		t.Fatalf("NewWeComNotifier() error = %v", err)
+	}
+	alert := &model.AlertContext{
+		Summary:     model.AlertSummary{HealthStatus: "critical"},
+		Regressions: []model.RegressionItem{{QueryID: 1, Severity: "critical", Query: "SELECT 1"}},
+	}
+	if got := w.formatMessage(alert); !strings.HasPrefix(got, "## 🔴 1 critical regressions\n") {
+		t.Errorf("message does not start with the templated title:\n%s", got)
+	}
+
+	if _, err := NewWeComNotifier(&config.NotifierConfig{WebhookURL: "http://localhost", TitleTemplate: "{{.Nope}}"}); err == nil {
+		t.Error("NewWeComNotifier() expected error for a template with an unknown field")
+	}
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// verbosity is how much of an alert the text notifiers render (notifier.verbosity).
+type verbosity int
+
+const (
+	// verbositySummary renders the counts and the single worst finding.
+	verbositySummary verbosity = iota
+	// verbosityNormal renders each section up to its entry limit with query previews.
+	verbosityNormal
+	// verbosityDetailed renders every finding with its full query text and all metrics.
+	verbosityDetailed
+)
+
+// parseVerbosity maps a validated notifier.verbosity value; empty means normal.
+func parseVerbosity(s string) verbosity {
+	switch s {
+	case config.VerbositySummary:
+		return verbositySummary
+	case config.VerbosityDetailed:
+		return verbosityDetailed
+	default:
+		return verbosityNormal
+	}
+}
+
+// limit returns how many of total section entries to list: n, or all of them
+// when detailed.
+func (v verbosity) limit(n, total int) int {
+	if v == verbosityDetailed {
+		return total
+	}
+	return n
+}
+
+// textLen returns the byte limit for query text and other one-line subjects:
+// maxLen, or no limit when detailed.
+func (v verbosity) textLen(maxLen int) int {
+	if v == verbosityDetailed {
+		return math.MaxInt
+	}
+	return maxLen
+}
+
+// worstFinding picks the finding a summary notification shows: the highest
+// severity wins, and ties go to the earlier rule (stale data, regressions,
+// custom rules). Findings without a severity (waits, call spikes, index
+// suggestions) are only picked when nothing else fired. The slow SQL ranking
+// is never a finding. It returns false when the alert has no findings.
+func worstFinding(alert *model.AlertContext) (model.FindingRef, bool) {
+	refs := findingRefs(alert)
+	if len(refs) == 0 {
+		return model.FindingRef{}, false
+	}
+
+	worst := refs[0]
+	for _, ref := range refs[1:] {
+		if model.SeverityRank(ref.Severity) > model.SeverityRank(worst.Severity) {
+			worst = ref
+		}
+	}
+	return worst, true
+}
+
+// findingRefs lists every finding of alert in rule order, without the slow SQL ranking.
+func findingRefs(alert *model.AlertContext) []model.FindingRef {
+	var refs []model.FindingRef
+	if sd := alert.StaleData; sd != nil {
+		refs = append(refs, model.FindingRef{Rule: "stale_data", Subject: formatStaleData(sd), Severity: sd.Severity})
+	}
+	for _, r := range alert.Regressions {
+		rule := "regression"
+		if r.IsNewQuery {
+			rule = "new_query"
+		}
+		refs = append(refs, model.FindingRef{Rule: rule, QueryID: r.QueryID, DatabaseName: r.DatabaseName,
+			ServerName: r.ServerName, UserID: r.UserID, Subject: r.Query, Severity: r.Severity})
+	}
+	for _, f := range alert.CustomFindings {
+		refs = append(refs, model.FindingRef{Rule: "custom:" + f.Rule, Subject: formatCustomRow(f), Severity: f.Severity})
+	}
+	for _, w := range alert.WaitEvents {
+		refs = append(refs, model.FindingRef{Rule: "wait_events", QueryID: w.QueryID, DatabaseName: w.DatabaseName,
+			ServerName: w.ServerName, Subject: w.Query})
+	}
+	for _, f := range alert.Flapping {
+		refs = append(refs, model.FindingRef{Rule: "flapping", QueryID: f.QueryID, DatabaseName: f.DatabaseName,
+			ServerName: f.ServerName, Subject: f.Query})
+	}
+	for _, c := range alert.CrossServer {
+		refs = append(refs, model.FindingRef{Rule: "cross_server", QueryID: c.QueryID, DatabaseName: c.DatabaseName,
+			ServerName: c.ServerName, Subject: c.Query})
+	}
+	for _, s := range alert.CallSpikes {
+		refs = append(refs, model.FindingRef{Rule: "call_spike", QueryID: s.QueryID, DatabaseName: s.DatabaseName,
+			ServerName: s.ServerName, UserID: s.UserID, Subject: s.Query})
+	}
+	if wg := alert.WorkloadGrowth; wg != nil {
+		refs = append(refs, model.FindingRef{Rule: "workload_growth", Subject: formatWorkloadGrowth(wg)})
+	}
+	if cs := alert.ConnectionSaturation; cs != nil {
+		refs = append(refs, model.FindingRef{Rule: "connection_saturation", Subject: formatConnectionSaturation(cs)})
+	}
+	for _, v := range alert.Vacuum {
+		refs = append(refs, model.FindingRef{Rule: "vacuum", DatabaseName: v.DatabaseName,
+			Subject: v.DatabaseName + "/" + v.FullTableName()})
+	}
+	for _, s := range alert.Suggestions {
+		refs = append(refs, model.FindingRef{Rule: "index_suggestion",
+			Subject: fmt.Sprintf("%s (%s)", s.FullTableName(), strings.Join(s.Columns, ", "))})
+	}
+	return refs
+}
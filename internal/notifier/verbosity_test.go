@@ -0,0 +1,128 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// verbosityAlert has more regressions than any section limit, a query longer
+// than any preview and a single critical regression as its worst finding.
+func verbosityAlert() *model.AlertContext {
+	longQuery := "SELECT " + strings.Repeat("col, ", 100) + "id FROM orders WHERE status = $1"
+	alert := &model.AlertContext{
+		ReqID: "req-1",
+		TopSlowSQL: []model.MetricSnapshot{
+			{QueryID: 1, Query: longQuery, DatabaseName: "app", TotalTime: 5000, MeanTime: 50, Calls: 100},
+		},
+		Summary: model.AlertSummary{HealthScore: 60, HealthStatus: "warning", SlowQueryCount: 1, RegressionCount: 25},
+	}
+	for i := 0; i < 25; i++ {
+		alert.Regressions = append(alert.Regressions, model.RegressionItem{
+			QueryID: int64(100 + i), Query: fmt.Sprintf("SELECT %d FROM t", i), DatabaseName: "app",
+			BaselineMeanTime: 10, CurrentMeanTime: 20, ChangePercent: 100, BaselineCalls: 40, CurrentCalls: 400,
+			Severity: "medium",
+		})
+	}
+	alert.Regressions[7].Severity = "critical"
+	return alert
+}
+
+func TestVerbosity_Console(t *testing.T) {
+	alert := verbosityAlert()
+	render := func(level string) string {
+		return NewConsoleNotifier(&config.NotifierConfig{Verbosity: level}).format(alert)
+	}
+
+	summary := render(config.VerbositySummary)
+	if !strings.Contains(summary, "Regressions:      25") {
+		t.Error("summary should keep the counts")
+	}
+	if !strings.Contains(summary, "[regression] [107] [app] (critical) SELECT 7 FROM t") {
+		t.Errorf("summary should show the worst finding, got:\n%s", summary)
+	}
+	if strings.Contains(summary, "TOP SLOW QUERIES") || strings.Contains(summary, "REGRESSIONS") {
+		t.Error("summary should not list sections")
+	}
+
+	normal := render(config.VerbosityNormal)
+	if !strings.Contains(normal, "... and 5 more") {
+		t.Error("normal should cap regressions at 20")
+	}
+	if strings.Contains(normal, "status = $1") || strings.Contains(normal, "calls 40 → 400") {
+		t.Error("normal should truncate queries and omit detailed metrics")
+	}
+
+	detailed := render(config.VerbosityDetailed)
+	if strings.Contains(detailed, "more\n") || !strings.Contains(detailed, "SELECT 24 FROM t") {
+		t.Error("detailed should list every regression")
+	}
+	if !strings.Contains(detailed, "status = $1") {
+		t.Error("detailed should include the full query text")
+	}
+	if !strings.Contains(detailed, "calls 40 → 400") || !strings.Contains(detailed, "mean 50.00 ms") {
+		t.Errorf("detailed should include all metrics, got:\n%s", detailed)
+	}
+	if !(len(summary) < len(normal) && len(normal) < len(detailed)) {
+		t.Errorf("output should grow with verbosity: %d, %d, %d", len(summary), len(normal), len(detailed))
+	}
+}
+
+func TestVerbosity_WeCom(t *testing.T) {
+	alert := verbosityAlert()
+	render := func(level string) string {
+		w, err := NewWeComNotifier(&config.NotifierConfig{WebhookURL: "http://localhost", RetryDelay: "1ms", Verbosity: level})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.formatMessage(alert)
+	}
+
+	summary := render(config.VerbositySummary)
+	if !strings.Contains(summary, "**Worst Finding**: 🔴 [regression] [107] [app] (critical) SELECT 7 FROM t") {
+		t.Errorf("summary should show the worst finding, got:\n%s", summary)
+	}
+	if strings.Contains(summary, "```sql") {
+		t.Error("summary should not list findings")
+	}
+
+	normal := render(config.VerbosityNormal)
+	if !strings.Contains(normal, "... and 15 more") || strings.Contains(normal, "status = $1") {
+		t.Error("normal should cap regressions at 10 and truncate queries")
+	}
+
+	detailed := render(config.VerbosityDetailed)
+	if strings.Contains(detailed, "more\n") || !strings.Contains(detailed, "status = $1") {
+		t.Error("detailed should list every finding with its full query text")
+	}
+	if !strings.Contains(detailed, "Calls: 40 → 400") || !strings.Contains(detailed, "Mean Time: 50.00 ms") {
+		t.Errorf("detailed should include all metrics, got:\n%s", detailed)
+	}
+}
+
+func TestWorstFinding(t *testing.T) {
+	if _, ok := worstFinding(&model.AlertContext{TopSlowSQL: []model.MetricSnapshot{{QueryID: 1}}}); ok {
+		t.Error("slow SQL alone is not a finding")
+	}
+
+	alert := &model.AlertContext{
+		CallSpikes: []model.CallSpikeItem{{QueryID: 1, Query: "SELECT 1"}},
+		Regressions: []model.RegressionItem{
+			{QueryID: 2, Query: "SELECT 2", Severity: "high"},
+			{QueryID: 3, Query: "SELECT 3", Severity: "info", IsNewQuery: true},
+		},
+		CustomFindings: []model.CustomFinding{{Rule: "bloat", Severity: "high"}},
+	}
+	ref, ok := worstFinding(alert)
+	if !ok || ref.QueryID != 2 {
+		t.Errorf("worstFinding = %+v, want the high regression ahead of the equal custom finding", ref)
+	}
+
+	ref, _ = worstFinding(&model.AlertContext{CallSpikes: alert.CallSpikes})
+	if ref.Rule != "call_spike" {
+		t.Errorf("worstFinding = %+v, want the call spike when nothing has a severity", ref)
+	}
+}
@@ -5,12 +5,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/format"
 	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/retry"
+)
+
+const (
+	// wecomSafeLimit is the chunk size used to stay under WeCom's 4096-byte markdown
+	// limit, leaving room for the part header and JSON escaping.
+	wecomSafeLimit = 4000
+
+	// wecomMinInterval spaces consecutive chunk posts to stay within WeCom's
+	// limit of 20 messages per minute per bot.
+	wecomMinInterval = 3 * time.Second
 )
 
 // WeComNotifier sends alerts to WeCom (WeChat Work) via webhook.
@@ -19,13 +34,26 @@ type WeComNotifier struct {
 	retries    int
 	retryDelay time.Duration
 	client     *http.Client
+
+	// minInterval is the delay between consecutive chunks of one alert.
+	minInterval time.Duration
+
+	units     format.Formatter
+	verbosity verbosity
+	title     *titleTemplate
+	queryURL  *queryURLTemplate
+
+	// format is notifier.wecom.format; cardURL is the template card's link.
+	format  string
+	cardURL string
 }
 
 // wecomMessage represents the WeCom webhook message format.
 type wecomMessage struct {
-	MsgType  string           `json:"msgtype"`
-	Markdown *markdownContent `json:"markdown,omitempty"`
-	Text     *textContent     `json:"text,omitempty"`
+	MsgType      string             `json:"msgtype"`
+	Markdown     *markdownContent   `json:"markdown,omitempty"`
+	Text         *textContent       `json:"text,omitempty"`
+	TemplateCard *wecomTemplateCard `json:"template_card,omitempty"`
 }
 
 type markdownContent struct {
@@ -49,13 +77,27 @@ func NewWeComNotifier(cfg *config.NotifierConfig) (*WeComNotifier, error) {
 		retryDelay = time.Second
 	}
 
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	title, err := newTitleTemplate(cfg.TitleTemplate)
+	if err != nil {
+		return nil, err
+	}
+
 	return &WeComNotifier{
-		webhookURL: cfg.WebhookURL,
-		retries:    cfg.Retries,
-		retryDelay: retryDelay,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		webhookURL:  cfg.WebhookURL,
+		retries:     cfg.Retries,
+		retryDelay:  retryDelay,
+		client:      client,
+		minInterval: wecomMinInterval,
+		units:       format.New(cfg.DisplayPrecision()),
+		verbosity:   parseVerbosity(cfg.Verbosity),
+		title:       title,
+		queryURL:    newQueryURLTemplate(cfg.QueryURLTemplate),
+		format:      cfg.WeCom.Format,
+		cardURL:     cfg.WeCom.CardURL,
 	}, nil
 }
 
@@ -64,17 +106,60 @@ func (w *WeComNotifier) Name() string {
 	return "wecom"
 }
 
-// Send sends the alert to WeCom.
+// Probe sends a HEAD request to the webhook endpoint with the key stripped, so
+// it can never post a message and errors never reveal the key. Any response
+// below 500 proves the host is reachable.
+func (w *WeComNotifier) Probe(ctx context.Context) error {
+	u, err := url.Parse(w.webhookURL)
+	if err != nil {
+		return fmt.Errorf("parsing webhook url: %w", err)
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("creating probe request: %w", err)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("probing %s: %w", u.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("probing %s: HTTP %d", u.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// Send sends the alert to WeCom in the configured format.
 func (w *WeComNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
-	content := w.formatMessage(alert)
+	if w.format == config.WeComFormatTemplateCard {
+		// A card carries only the summary, so it always fits in one message
+		return w.sendWithRetry(ctx, wecomMessage{MsgType: "template_card", TemplateCard: w.formatCard(alert)})
+	}
 
-	// Split message if it exceeds WeCom limit (4096 bytes)
-	chunks := splitMessage(content, 4096)
+	// Split message if it exceeds WeCom limit (4096 bytes markdown, 2048 bytes
+	// text), keeping each finding intact
+	blocks, limit, header := w.formatBlocks(alert), wecomSafeLimit, "**(Part %d/%d)**\n\n"
+	if w.format == config.WeComFormatText {
+		blocks, limit, header = plainTextBlocks(blocks), wecomTextSafeLimit, "(Part %d/%d)\n\n"
+	}
+	chunks := packBlocks(blocks, limit)
 
 	for i, chunk := range chunks {
-		// Add pagination indicator if multiple chunks
+		// Add pagination header if multiple chunks
 		if len(chunks) > 1 {
-			chunk += fmt.Sprintf("\n\n*(Part %d/%d)*", i+1, len(chunks))
+			chunk = fmt.Sprintf(header, i+1, len(chunks)) + chunk
+		}
+
+		// Respect WeCom's per-bot rate limit between consecutive posts
+		if i > 0 && w.minInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("waiting to send chunk %d: %w", i+1, ctx.Err())
+			case <-time.After(w.minInterval):
+			}
 		}
 
 		msg := wecomMessage{
@@ -83,6 +168,9 @@ func (w *WeComNotifier) Send(ctx context.Context, alert *model.AlertContext) err
 				Content: chunk,
 			},
 		}
+		if w.format == config.WeComFormatText {
+			msg = wecomMessage{MsgType: "text", Text: &textContent{Content: chunk}}
+		}
 
 		if err := w.sendWithRetry(ctx, msg); err != nil {
 			return fmt.Errorf("failed to send chunk %d: %w", i+1, err)
@@ -94,40 +182,176 @@ func (w *WeComNotifier) Send(ctx context.Context, alert *model.AlertContext) err
 
 // formatMessage creates a markdown message from the alert context.
 func (w *WeComNotifier) formatMessage(alert *model.AlertContext) string {
+	return strings.Join(w.formatBlocks(alert), "")
+}
+
+// formatBlocks renders the alert as a sequence of markdown blocks. Each block is
+// an indivisible unit (header, summary, or a single finding) so chunking never
+// splits a finding across messages. Section headings are attached to the first
+// entry of their section.
+func (w *WeComNotifier) formatBlocks(alert *model.AlertContext) []string {
+	var blocks []string
 	var sb strings.Builder
 
 	// Header with health status
 	statusEmoji := getStatusEmoji(alert.Summary.HealthStatus)
-	sb.WriteString(fmt.Sprintf("## %s PoWA Sentinel Report\n\n", statusEmoji))
+	sb.WriteString(fmt.Sprintf("## %s %s\n\n", statusEmoji, w.title.render(alert)))
 
 	// Summary section (L1 - Management level)
 	sb.WriteString("### 📊 Summary\n")
 	sb.WriteString(fmt.Sprintf("> **Health Score**: %d/100 (%s)\n",
 		alert.Summary.HealthScore, alert.Summary.HealthStatus))
+	if alert.Repository != "" {
+		sb.WriteString(fmt.Sprintf("> **Repository**: %s\n", alert.Repository))
+	}
 	sb.WriteString(fmt.Sprintf("> **Analysis Period**: %s ~ %s\n",
-		alert.AnalysisWindow.Start.Format("2006-01-02 15:04"),
-		alert.AnalysisWindow.End.Format("2006-01-02 15:04")))
-	sb.WriteString(fmt.Sprintf("> **Queries Analyzed**: %d\n\n",
-		alert.Summary.TotalQueriesAnalyzed))
+		alert.DisplayTime(alert.AnalysisWindow.Start, "2006-01-02 15:04"),
+		alert.DisplayTime(alert.AnalysisWindow.End, "2006-01-02 15:04")))
+	sb.WriteString(fmt.Sprintf("> **Queries Analyzed**: %s\n",
+		format.Count(int64(alert.Summary.TotalQueriesAnalyzed))))
+	if alert.DigestRuns > 0 {
+		sb.WriteString(fmt.Sprintf("> **Digest**: %d runs\n", alert.DigestRuns))
+	}
+	if len(alert.Labels) > 0 {
+		sb.WriteString(fmt.Sprintf("> **Labels**: %s\n", formatLabels(alert.Labels)))
+	}
+	sb.WriteString("\n")
 
 	// Issues summary
-	if alert.Summary.RegressionCount > 0 || alert.Summary.SuggestionCount > 0 {
+	if alert.Summary.RegressionCount > 0 || alert.Summary.NewQueryCount > 0 || alert.Summary.SuggestionCount > 0 ||
+		alert.Summary.CallSpikeCount > 0 || alert.Summary.WaitEventCount > 0 || alert.Summary.FlappingCount > 0 || alert.Summary.VacuumCount > 0 ||
+		alert.Summary.CrossServerCount > 0 ||
+		alert.Summary.CustomFindingCount > 0 ||
+		alert.StaleData != nil || alert.WorkloadGrowth != nil || alert.ConnectionSaturation != nil {
 		sb.WriteString("**Issues Found**:\n")
+		if alert.StaleData != nil {
+			sb.WriteString(fmt.Sprintf("- %s **Stale data**: %s\n",
+				getSeverityIcon(alert.StaleData.Severity), formatStaleData(alert.StaleData)))
+		}
+		if alert.ConnectionSaturation != nil {
+			sb.WriteString(fmt.Sprintf("- 🔌 **Connection saturation**: %s\n", formatConnectionSaturation(alert.ConnectionSaturation)))
+		}
 		if alert.Summary.RegressionCount > 0 {
 			sb.WriteString(fmt.Sprintf("- 🔴 %d Performance Regressions\n", alert.Summary.RegressionCount))
 		}
+		if alert.Summary.NewQueryCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 🆕 %d New Queries\n", alert.Summary.NewQueryCount))
+		}
+		if alert.Summary.CallSpikeCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 📞 %d Call Spikes\n", alert.Summary.CallSpikeCount))
+		}
+		if alert.Summary.WaitEventCount > 0 {
+			sb.WriteString(fmt.Sprintf("- ⏳ %d Queries Blocked on Lock/IO Waits\n", alert.Summary.WaitEventCount))
+		}
+		if alert.Summary.FlappingCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 〰️ %d Flapping Queries\n", alert.Summary.FlappingCount))
+		}
+		if alert.Summary.CrossServerCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 🔀 %d Queries Slower Than the Reference Server\n", alert.Summary.CrossServerCount))
+		}
+		if alert.WorkloadGrowth != nil {
+			sb.WriteString(fmt.Sprintf("- 📈 **Workload growth**: total query time %s\n",
+				w.units.Percent(alert.WorkloadGrowth.ChangePercent)))
+		}
+		if alert.Summary.VacuumCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 🧹 %d Tables Needing VACUUM/ANALYZE\n", alert.Summary.VacuumCount))
+		}
 		if alert.Summary.SuggestionCount > 0 {
 			sb.WriteString(fmt.Sprintf("- 💡 %d Index Suggestions\n", alert.Summary.SuggestionCount))
 		}
+		if alert.Summary.CustomFindingCount > 0 {
+			sb.WriteString(fmt.Sprintf("- 🧩 %d Custom Rule Findings\n", alert.Summary.CustomFindingCount))
+		}
+		if alert.Summary.OmittedFindings > 0 {
+			sb.WriteString(fmt.Sprintf("- %d less significant findings omitted\n", alert.Summary.OmittedFindings))
+		}
 		sb.WriteString("\n")
 	}
+	if msg := formatEmpty(alert); msg != "" {
+		sb.WriteString(fmt.Sprintf("%s **%s**\n\n", getStatusEmoji(alert.Summary.HealthStatus), msg))
+	}
+	if alert.Truncated != nil {
+		sb.WriteString(fmt.Sprintf("> ⚠️ **%s**\n\n", alert.Truncated.Note()))
+	}
+	blocks = append(blocks, sb.String())
+
+	// Summary verbosity keeps only the counts and the single worst finding
+	if w.verbosity == verbositySummary {
+		if ref, ok := worstFinding(alert); ok {
+			blocks = append(blocks, fmt.Sprintf("**Worst Finding**: %s %s\n\n",
+				getSeverityIcon(ref.Severity), formatFindingRef(ref, 300)))
+		}
+		return append(blocks, fmt.Sprintf("---\n*Report ID: %s*\n", alert.ReqID))
+	}
+
+	// Delta mode replaces the per-rule sections with changes since the last run
+	if alert.Delta != nil {
+		for _, section := range deltaSections(alert.Delta) {
+			limit := w.verbosity.limit(10, len(section.refs))
+			for i, ref := range section.refs {
+				sb.Reset()
+				if i == 0 {
+					sb.WriteString(fmt.Sprintf("### 🔁 %s (%d)\n", section.title, len(section.refs)))
+				}
+				if i >= limit { // Limit to top 10 per section in message unless detailed
+					sb.WriteString(fmt.Sprintf("... and %d more\n", len(section.refs)-limit))
+					blocks = append(blocks, sb.String())
+					break
+				}
+				sb.WriteString(fmt.Sprintf("- %s %s\n", section.marker, formatFindingRef(ref, w.verbosity.textLen(120))))
+				blocks = append(blocks, sb.String())
+			}
+			blocks[len(blocks)-1] += "\n"
+		}
+		return append(blocks, fmt.Sprintf("---\n*Report ID: %s*\n", alert.ReqID))
+	}
+
+	// Top databases section (L1/L2 level); short enough to stay one block
+	if len(alert.TopDatabases) > 0 {
+		sb.Reset()
+		sb.WriteString("### 🗄 Top Databases\n")
+		for i, d := range alert.TopDatabases {
+			serverInfo := d.DatabaseName
+			if d.ServerName != "" && d.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", d.ServerName, d.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("%d. **[%s]** %s (**%s**) | Calls: %s | Queries: %s\n",
+				i+1, serverInfo, w.units.Duration(d.TotalTime), w.units.Number(d.TimePercent)+"%",
+				format.Count(d.Calls), format.Count(int64(d.QueryCount))))
+		}
+		blocks = append(blocks, sb.String()+"\n")
+	}
+
+	// Top users section, only with analysis.top_users
+	if len(alert.TopUsers) > 0 {
+		sb.Reset()
+		sb.WriteString("### 👤 Top Users\n")
+		for i, u := range alert.TopUsers {
+			userInfo := model.UserLabel(u.UserID, u.UserName)
+			if u.ServerName != "" && u.ServerName != "local" {
+				userInfo = fmt.Sprintf("%s/%s", u.ServerName, userInfo)
+			}
+			sb.WriteString(fmt.Sprintf("%d. **[%s]** %s (**%s**) | Calls: %s | Queries: %s\n",
+				i+1, userInfo, w.units.Duration(u.TotalTime), w.units.Number(u.TimePercent)+"%",
+				format.Count(u.Calls), format.Count(int64(u.QueryCount))))
+		}
+		blocks = append(blocks, sb.String()+"\n")
+	}
 
 	// Slow SQL section (L2 - Tech Lead level)
-	if len(alert.TopSlowSQL) > 0 {
-		sb.WriteString("### ⏱ Top Slow Queries\n")
-		for i, q := range alert.TopSlowSQL {
-			if i >= 5 { // Limit to top 5 in message
-				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.TopSlowSQL)-5))
+	for g, group := range slowSQLGroups(alert.TopSlowSQL) {
+		limit := w.verbosity.limit(5, len(group))
+		for i, q := range group {
+			sb.Reset()
+			if g == 0 && i == 0 {
+				sb.WriteString("### ⏱ Top Slow Queries\n")
+			}
+			if i == 0 && q.RankedBy != "" {
+				sb.WriteString(fmt.Sprintf("**%s**\n", rankingTitle(q.RankedBy)))
+			}
+			if i >= limit { // Limit to top 5 of each ranking in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(group)-limit))
+				blocks = append(blocks, sb.String())
 				break
 			}
 			serverInfo := q.DatabaseName
@@ -135,20 +359,41 @@ func (w *WeComNotifier) formatMessage(alert *model.AlertContext) string {
 				serverInfo = fmt.Sprintf("%s/%s", q.ServerName, q.DatabaseName)
 			}
 			sb.WriteString(fmt.Sprintf("**%d. [%s] Query ID**: `%d`\n", i+1, serverInfo, q.QueryID))
-			sb.WriteString(fmt.Sprintf("   - Total Time: %.2fms | Calls: %d\n", q.TotalTime, q.Calls))
+			sb.WriteString(fmt.Sprintf("   - Total Time: %s | Calls: %s\n", w.units.Duration(q.TotalTime), format.Count(q.Calls)))
+			if w.verbosity == verbosityDetailed {
+				sb.WriteString(fmt.Sprintf("   - Mean Time: %s\n", w.units.Duration(q.MeanTime)))
+				if q.HasKCacheData {
+					sb.WriteString(fmt.Sprintf("   - CPU: %s | Blocks Read: %s | Blocks Written: %s\n",
+						w.units.Duration(q.TotalCPUTime()), format.Count(q.ReadsBlks), format.Count(q.WritesBlks)))
+				}
+			}
+			if user := model.UserLabel(q.UserID, q.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("   - User: %s\n", user))
+			}
+			w.writeLabels(&sb, q.Labels)
+			w.writeQueryURL(&sb, q.SrvID, q.ServerName, q.DatabaseName, q.QueryID)
 			// Truncate query for readability and use code block
-			queryPreview := truncateQuery(q.Query, 300)
+			queryPreview := truncateQuery(q.Query, w.verbosity.textLen(300))
 			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			if q.Plan != "" {
+				sb.WriteString(fmt.Sprintf("   - Plan:\n```\n%s\n```\n", truncatePlan(q.Plan, w.verbosity.limit(10, math.MaxInt))))
+			}
+			blocks = append(blocks, sb.String())
 		}
-		sb.WriteString("\n")
+		blocks[len(blocks)-1] += "\n"
 	}
 
 	// Regressions section (L2/L3 level)
 	if len(alert.Regressions) > 0 {
-		sb.WriteString("### 📈 Performance Regressions\n")
+		limit := w.verbosity.limit(10, len(alert.Regressions))
 		for i, r := range alert.Regressions {
-			if i >= 10 { // Limit to top 10 in message (increased from 3 as requested)
-				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Regressions)-10))
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 📈 Performance Regressions\n")
+			}
+			if i >= limit { // Limit to top 10 in message unless detailed (increased from 3 as requested)
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Regressions)-limit))
+				blocks = append(blocks, sb.String())
 				break
 			}
 			serverInfo := r.DatabaseName
@@ -156,57 +401,292 @@ func (w *WeComNotifier) formatMessage(alert *model.AlertContext) string {
 				serverInfo = fmt.Sprintf("%s/%s", r.ServerName, r.DatabaseName)
 			}
 			severityIcon := getSeverityIcon(r.Severity)
-			sb.WriteString(fmt.Sprintf("%s **[%s] Query ID**: `%d` (%s)\n", severityIcon, serverInfo, r.QueryID, r.Severity))
-			sb.WriteString(fmt.Sprintf("   - Mean Time: %.2fms → %.2fms (**+%.1f%%**)\n",
-				r.BaselineMeanTime, r.CurrentMeanTime, r.ChangePercent))
+			if r.IsNewQuery {
+				sb.WriteString(fmt.Sprintf("%s **[%s] Query ID**: `%d` (new query)\n", severityIcon, serverInfo, r.QueryID))
+				sb.WriteString(fmt.Sprintf("   - Mean Time: %s (no baseline)\n", w.units.Duration(r.CurrentMeanTime)))
+			} else if r.CurrentTailTime > 0 {
+				sb.WriteString(fmt.Sprintf("%s **[%s] Query ID**: `%d` (%s)\n", severityIcon, serverInfo, r.QueryID, r.Severity))
+				sb.WriteString(fmt.Sprintf("   - Tail Time: %s → %s (**%s**)\n",
+					w.units.Duration(r.BaselineTailTime), w.units.Duration(r.CurrentTailTime), w.units.Percent(r.ChangePercent)))
+				sb.WriteString(fmt.Sprintf("   - Mean Time: %s → %s\n", w.units.Duration(r.BaselineMeanTime), w.units.Duration(r.CurrentMeanTime)))
+			} else {
+				sb.WriteString(fmt.Sprintf("%s **[%s] Query ID**: `%d` (%s)\n", severityIcon, serverInfo, r.QueryID, r.Severity))
+				sb.WriteString(fmt.Sprintf("   - Mean Time: %s → %s (**%s**)\n",
+					w.units.Duration(r.BaselineMeanTime), w.units.Duration(r.CurrentMeanTime), w.units.Percent(r.ChangePercent)))
+			}
+			if !r.FirstSeen.IsZero() {
+				sb.WriteString(fmt.Sprintf("   - First Seen: %s\n", format.Age(alert.Timestamp.Sub(r.FirstSeen))))
+			}
+			if w.verbosity == verbosityDetailed {
+				sb.WriteString(fmt.Sprintf("   - Calls: %s → %s\n", format.Count(r.BaselineCalls), format.Count(r.CurrentCalls)))
+			}
+			if user := model.UserLabel(r.UserID, r.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("   - User: %s\n", user))
+			}
+			w.writeLabels(&sb, r.Labels)
+			w.writeQueryURL(&sb, r.SrvID, r.ServerName, r.DatabaseName, r.QueryID)
 			// Truncate query for readability and use code block
-			queryPreview := truncateQuery(r.Query, 300)
+			queryPreview := truncateQuery(r.Query, w.verbosity.textLen(300))
 			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			blocks = append(blocks, sb.String())
 		}
-		sb.WriteString("\n")
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Call spikes section (L2 level)
+	if len(alert.CallSpikes) > 0 {
+		limit := w.verbosity.limit(5, len(alert.CallSpikes))
+		for i, s := range alert.CallSpikes {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 📞 Call Spikes\n")
+			}
+			if i >= limit { // Limit to top 5 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.CallSpikes)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			serverInfo := s.DatabaseName
+			if s.ServerName != "" && s.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", s.ServerName, s.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("**%d. [%s] Query ID**: `%d`\n", i+1, serverInfo, s.QueryID))
+			sb.WriteString(fmt.Sprintf("   - Calls: %s → %s (**%s**)\n",
+				format.Count(s.BaselineCalls), format.Count(s.CurrentCalls), w.units.Percent(s.ChangePercent)))
+			if user := model.UserLabel(s.UserID, s.UserName); user != "" {
+				sb.WriteString(fmt.Sprintf("   - User: %s\n", user))
+			}
+			w.writeLabels(&sb, s.Labels)
+			w.writeQueryURL(&sb, s.SrvID, s.ServerName, s.DatabaseName, s.QueryID)
+			queryPreview := truncateQuery(s.Query, w.verbosity.textLen(300))
+			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Lock/IO waits section (L2 level)
+	if len(alert.WaitEvents) > 0 {
+		limit := w.verbosity.limit(5, len(alert.WaitEvents))
+		for i, we := range alert.WaitEvents {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### ⏳ Lock/IO Waits\n")
+			}
+			if i >= limit { // Limit to top 5 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.WaitEvents)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			serverInfo := we.DatabaseName
+			if we.ServerName != "" && we.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", we.ServerName, we.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("**%d. [%s] Query ID**: `%d`\n", i+1, serverInfo, we.QueryID))
+			sb.WriteString(fmt.Sprintf("   - Waiting: %s of %s (**%s**)\n",
+				w.units.Duration(we.WaitTime), w.units.Duration(we.TotalTime), w.units.Percent(we.WaitPercent)))
+			sb.WriteString(fmt.Sprintf("   - Dominant Wait: `%s:%s`\n", we.DominantEventType, we.DominantEvent))
+			w.writeLabels(&sb, we.Labels)
+			w.writeQueryURL(&sb, we.SrvID, we.ServerName, we.DatabaseName, we.QueryID)
+			queryPreview := truncateQuery(we.Query, w.verbosity.textLen(300))
+			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Flapping queries section (L2 level)
+	if len(alert.Flapping) > 0 {
+		limit := w.verbosity.limit(5, len(alert.Flapping))
+		for i, f := range alert.Flapping {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 〰️ Flapping Queries\n")
+			}
+			if i >= limit { // Limit to top 5 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Flapping)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			serverInfo := f.DatabaseName
+			if f.ServerName != "" && f.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", f.ServerName, f.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("**%d. [%s] Query ID**: `%d`\n", i+1, serverInfo, f.QueryID))
+			sb.WriteString(fmt.Sprintf("   - Mean Time: %s (**CV %s** over %d windows)\n",
+				w.units.Duration(f.MeanTime), w.units.Number(f.CV), len(f.Series)))
+			sb.WriteString(fmt.Sprintf("   - Series: %s\n", formatSeries(w.units, f.Series)))
+			w.writeLabels(&sb, f.Labels)
+			w.writeQueryURL(&sb, f.SrvID, f.ServerName, f.DatabaseName, f.QueryID)
+			queryPreview := truncateQuery(f.Query, w.verbosity.textLen(300))
+			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Cross-server section (L2 level)
+	if len(alert.CrossServer) > 0 {
+		limit := w.verbosity.limit(5, len(alert.CrossServer))
+		for i, x := range alert.CrossServer {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 🔀 Slower Than Reference Server\n")
+			}
+			if i >= limit { // Limit to top 5 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.CrossServer)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			serverInfo := x.DatabaseName
+			if x.ServerName != "" && x.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", x.ServerName, x.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("**%d. [%s] Query ID**: `%d`\n", i+1, serverInfo, x.QueryID))
+			sb.WriteString(fmt.Sprintf("   - Mean Time: %s → %s (**%s**)\n",
+				w.units.Duration(x.ReferenceMeanTime), w.units.Duration(x.MeanTime), w.units.Percent(x.ChangePercent)))
+			sb.WriteString(fmt.Sprintf("   - Reference: %s (%s calls here, %s there)\n",
+				x.ReferenceServerName, format.Count(x.Calls), format.Count(x.ReferenceCalls)))
+			w.writeLabels(&sb, x.Labels)
+			w.writeQueryURL(&sb, x.SrvID, x.ServerName, x.DatabaseName, x.QueryID)
+			queryPreview := truncateQuery(x.Query, w.verbosity.textLen(300))
+			sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", queryPreview))
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Workload growth section (L2 level); one block with its top contributors
+	if wg := alert.WorkloadGrowth; wg != nil {
+		sb.Reset()
+		sb.WriteString("### 📈 Workload Growth\n")
+		sb.WriteString(fmt.Sprintf("> Total Time: %s → %s (**%s** per second of window)\n",
+			w.units.Duration(wg.BaselineTotalTime), w.units.Duration(wg.CurrentTotalTime), w.units.Percent(wg.ChangePercent)))
+		sb.WriteString(fmt.Sprintf("> Calls: %s → %s\n", format.Count(wg.BaselineCalls), format.Count(wg.CurrentCalls)))
+		for i, x := range wg.TopContributors {
+			serverInfo := x.DatabaseName
+			if x.ServerName != "" && x.ServerName != "local" {
+				serverInfo = fmt.Sprintf("%s/%s", x.ServerName, x.DatabaseName)
+			}
+			sb.WriteString(fmt.Sprintf("%d. [%s] `%d` +%s: %s\n", i+1, serverInfo, x.QueryID,
+				w.units.Duration(x.AddedTime), truncateQuery(x.Query, w.verbosity.textLen(80))))
+		}
+		blocks = append(blocks, sb.String()+"\n")
+	}
+
+	// Vacuum section (L3 - DBA level)
+	if len(alert.Vacuum) > 0 {
+		limit := w.verbosity.limit(5, len(alert.Vacuum))
+		for i, v := range alert.Vacuum {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 🧹 Vacuum/Analyze Needed\n")
+			}
+			if i >= limit { // Limit to top 5 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Vacuum)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%d. **[%s]** %s\n", i+1, v.DatabaseName, formatVacuum(alert, v)))
+			w.writeLabels(&sb, v.Labels)
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
 	}
 
 	// Index suggestions section (L3 - DBA level)
 	if len(alert.Suggestions) > 0 {
-		sb.WriteString("### 💡 Index Suggestions\n")
+		limit := w.verbosity.limit(3, len(alert.Suggestions))
 		for i, s := range alert.Suggestions {
-			if i >= 3 { // Limit to top 3 in message
-				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Suggestions)-3))
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 💡 Index Suggestions\n")
+				sb.WriteString("> Advisory DDL: review and test before running\n")
+			}
+			if i >= limit { // Limit to top 3 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.Suggestions)-limit))
+				blocks = append(blocks, sb.String())
 				break
 			}
-			sb.WriteString(fmt.Sprintf("**%d. %s** (Est. +%.0f%%)\n",
-				i+1, s.FullTableName(), s.EstImprovementPercent))
+			sb.WriteString(fmt.Sprintf("**%d. %s** (Est. %s, %s queries)\n",
+				i+1, s.FullTableName(), w.units.Percent(s.EstImprovementPercent), format.Count(int64(s.AffectedQueries))))
 			sb.WriteString(fmt.Sprintf("   - Columns: `%s`\n", strings.Join(s.Columns, ", ")))
+			for _, ex := range s.Examples {
+				sb.WriteString(fmt.Sprintf("   - Example: `%s`\n", formatColumnExample(ex, w.units)))
+			}
+			if s.SuggestedDDL != "" {
+				sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n", s.SuggestedDDL))
+			}
+			blocks = append(blocks, sb.String())
 		}
-		sb.WriteString("\n")
+		blocks[len(blocks)-1] += "\n"
+	}
+
+	// Custom rules section
+	if len(alert.CustomFindings) > 0 {
+		limit := w.verbosity.limit(10, len(alert.CustomFindings))
+		for i, f := range alert.CustomFindings {
+			sb.Reset()
+			if i == 0 {
+				sb.WriteString("### 🧩 Custom Rules\n")
+			}
+			if i >= limit { // Limit to top 10 in message unless detailed
+				sb.WriteString(fmt.Sprintf("... and %d more\n", len(alert.CustomFindings)-limit))
+				blocks = append(blocks, sb.String())
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%s **%s** (%s): `%s` = %s (%s %s)\n", getSeverityIcon(f.Severity), f.Rule, f.Severity,
+				f.Column, w.units.Number(f.Value), f.Operator, w.units.Number(f.Threshold)))
+			sb.WriteString(fmt.Sprintf("   - %s\n", truncateQuery(formatCustomRow(f), w.verbosity.textLen(300))))
+			blocks = append(blocks, sb.String())
+		}
+		blocks[len(blocks)-1] += "\n"
 	}
 
 	// Footer
-	sb.WriteString("---\n")
-	sb.WriteString(fmt.Sprintf("*Report ID: %s*\n", alert.ReqID))
+	blocks = append(blocks, fmt.Sprintf("---\n*Report ID: %s*\n", alert.ReqID))
 
-	return sb.String()
+	return blocks
 }
 
-// sendWithRetry sends the message with exponential backoff retry.
+// writeQueryURL adds the finding's notifier.query_url_template link.
+func (w *WeComNotifier) writeQueryURL(sb *strings.Builder, srvID int, server, database string, queryID int64) {
+	if link := w.queryURL.render(srvID, server, database, queryID); link != "" {
+		sb.WriteString(fmt.Sprintf("   - [Open in PoWA](%s)\n", link))
+	}
+}
+
+// writeLabels lists a finding's labels in detailed output.
+func (w *WeComNotifier) writeLabels(sb *strings.Builder, labels map[string]string) {
+	if w.verbosity == verbosityDetailed && len(labels) > 0 {
+		sb.WriteString(fmt.Sprintf("   - Labels: %s\n", formatLabels(labels)))
+	}
+}
+
+// sendWithRetry sends the message with exponential backoff retry. It stops as
+// soon as ctx is done, during a request or between attempts, or when the run's
+// retry budget (see retry.Wait) allows no further attempt, and returns that
+// error wrapped with the last delivery error.
 func (w *WeComNotifier) sendWithRetry(ctx context.Context, msg wecomMessage) error {
 	var lastErr error
 	delay := w.retryDelay
 
 	for attempt := 0; attempt <= w.retries; attempt++ {
 		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-				delay *= 2 // Exponential backoff
+			if err := retry.Wait(ctx, delay); err != nil {
+				return fmt.Errorf("retry cancelled after %d attempt(s): %w (last error: %v)", attempt, err, lastErr)
 			}
+			delay *= 2 // Exponential backoff
 		}
 
 		err := w.send(ctx, msg)
 		if err == nil {
 			return nil
 		}
+		if ctx.Err() != nil {
+			// The request was aborted by ctx; further attempts would fail the same way
+			return fmt.Errorf("send cancelled on attempt %d: %w", attempt+1, ctx.Err())
+		}
 		lastErr = err
 	}
 
@@ -271,11 +751,181 @@ func getSeverityIcon(severity string) string {
 		return "🟠"
 	case "medium":
 		return "🟡"
+	case "info":
+		return "🆕"
 	default:
 		return "🔵"
 	}
 }
 
+// formatLabels renders labels as space-separated key=value pairs sorted by key.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// formatCustomRow renders a custom rule row as "col=value" pairs in query column order.
+func formatCustomRow(f model.CustomFinding) string {
+	pairs := make([]string, 0, len(f.Row))
+	for _, c := range f.Columns {
+		if v, ok := f.Row[c]; ok {
+			pairs = append(pairs, c+"="+v)
+		}
+	}
+	if len(pairs) == 0 {
+		// Columns is not serialized; fall back to sorted keys
+		return formatLabels(f.Row)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// deltaSection is one group of a delta notification.
+type deltaSection struct {
+	title  string
+	marker string
+	refs   []model.FindingRef
+}
+
+// deltaSections returns the non-empty New, Resolved and Still groups in display order.
+func deltaSections(d *model.FindingDelta) []deltaSection {
+	var sections []deltaSection
+	for _, s := range []deltaSection{{"New", "+", d.New}, {"Resolved", "-", d.Resolved}, {"Still", "=", d.Still}} {
+		if len(s.refs) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// formatFindingRef describes a finding reference in one line, with the subject
+// collapsed to maxLen bytes.
+func formatFindingRef(ref model.FindingRef, maxLen int) string {
+	var sb strings.Builder
+	sb.WriteString("[" + ref.Rule + "]")
+	if ref.QueryID != 0 {
+		serverInfo := ref.DatabaseName
+		if ref.ServerName != "" && ref.ServerName != "local" {
+			serverInfo = fmt.Sprintf("%s/%s", ref.ServerName, ref.DatabaseName)
+		}
+		sb.WriteString(fmt.Sprintf(" [%d] [%s]", ref.QueryID, serverInfo))
+	}
+	if ref.Severity != "" {
+		sb.WriteString(" (" + ref.Severity + ")")
+	}
+	subject := strings.Join(strings.Fields(ref.Subject), " ")
+	if len(subject) > maxLen {
+		subject = subject[:maxLen-3] + "..."
+	}
+	if subject != "" {
+		sb.WriteString(" " + subject)
+	}
+	return sb.String()
+}
+
+// formatSeries renders a flapping query's per-window mean times, oldest first.
+func formatSeries(units format.Formatter, series []model.FlappingPoint) string {
+	parts := make([]string, len(series))
+	for i, p := range series {
+		parts[i] = units.Duration(p.MeanTime)
+	}
+	return strings.Join(parts, " → ")
+}
+
+// formatVacuum describes a vacuum finding in one line.
+func formatVacuum(alert *model.AlertContext, v model.VacuumItem) string {
+	last := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return alert.DisplayTime(t, "2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s: %s dead tuples (%.1f%%), last vacuum %s, last analyze %s (%s)",
+		v.FullTableName(), format.Count(v.DeadTuples), v.DeadRatioPercent,
+		last(v.LastVacuum), last(v.LastAnalyze), strings.Join(v.Reasons, ", "))
+}
+
+// formatStaleData describes a stale data finding in one line.
+func formatStaleData(s *model.StaleDataFinding) string {
+	if s.LatestSnapshot.IsZero() {
+		return "no PoWA snapshots found; check that powa-collector is running"
+	}
+	return fmt.Sprintf("newest PoWA snapshot is %s old (max %s); check that powa-collector is running", s.Age, s.MaxAge)
+}
+
+// formatWorkloadGrowth describes a workload growth finding in one line.
+func formatWorkloadGrowth(wg *model.WorkloadGrowthFinding) string {
+	return fmt.Sprintf("total query time grew %.1f%% per second of window (%.0fms over %s calls, was %.0fms over %s calls)",
+		wg.ChangePercent, wg.CurrentTotalTime, format.Count(wg.CurrentCalls), wg.BaselineTotalTime, format.Count(wg.BaselineCalls))
+}
+
+// formatConnectionSaturation describes a connection saturation finding in one line.
+func formatConnectionSaturation(cs *model.ConnectionSaturationFinding) string {
+	return fmt.Sprintf("%d of %d connections in use (%.1f%%, max %.0f%%): %d active, %d idle",
+		cs.Total, cs.MaxConnections, cs.UsagePercent, cs.MaxPercent, cs.Active, cs.Idle)
+}
+
+// formatColumnExample lists the constants seen for one suggested column and the
+// share of rows its predicates kept, e.g. "status = 'new', 'open' (keeps 2.0% of rows)".
+func formatColumnExample(ex model.ColumnExample, units format.Formatter) string {
+	s := ex.Column
+	if len(ex.Values) > 0 {
+		s += " = " + strings.Join(ex.Values, ", ")
+	}
+	if ex.Selectivity > 0 {
+		s += fmt.Sprintf(" (keeps %s%% of rows)", units.Number(ex.Selectivity*100))
+	}
+	return s
+}
+
+// formatEmpty explains an alert without findings in one line; it returns "" when
+// the alert has findings.
+func formatEmpty(alert *model.AlertContext) string {
+	window := alert.DisplayTime(alert.AnalysisWindow.Start, "2006-01-02 15:04") + " ~ " +
+		alert.DisplayTime(alert.AnalysisWindow.End, "2006-01-02 15:04")
+	switch alert.Summary.Empty {
+	case model.EmptyNoData:
+		return fmt.Sprintf("No data: no statements were recorded in %s; check that powa-collector is running and the database filters match", window)
+	case model.EmptyNoFindings:
+		return fmt.Sprintf("All quiet: no findings in %s", window)
+	default:
+		return ""
+	}
+}
+
+// slowSQLGroups splits the slow SQL findings into one group per
+// rules.slow_sql.rank_by metric, in order. A single ranking is one group.
+func slowSQLGroups(queries []model.MetricSnapshot) [][]model.MetricSnapshot {
+	var groups [][]model.MetricSnapshot
+	for i, q := range queries {
+		if i == 0 || q.RankedBy != queries[i-1].RankedBy {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], q)
+	}
+	return groups
+}
+
+// rankingTitle names the rank_by metric of a slow SQL group, e.g. "By mean time".
+func rankingTitle(metric string) string {
+	switch metric {
+	case "cpu_time":
+		return "By CPU time"
+	case "io_time":
+		return "By I/O"
+	default:
+		return "By " + strings.ReplaceAll(metric, "_", " ")
+	}
+}
+
 func truncateQuery(query string, maxLen int) string {
 	// Clean up whitespace
 	query = strings.Join(strings.Fields(query), " ")
@@ -285,13 +935,53 @@ func truncateQuery(query string, maxLen int) string {
 	return query[:maxLen-3] + "..."
 }
 
+// truncatePlan returns the first maxLines lines of an EXPLAIN plan, noting how
+// many were left out. Unlike query text, the plan keeps its line breaks and
+// indentation.
+func truncatePlan(plan string, maxLines int) string {
+	lines := strings.Split(plan, "\n")
+	if len(lines) <= maxLines {
+		return plan
+	}
+	return strings.Join(lines[:maxLines], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-maxLines)
+}
+
+// packBlocks groups whole blocks into chunks no longer than maxLen bytes.
+// A block that is larger than maxLen on its own is split by splitMessage.
+func packBlocks(blocks []string, maxLen int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, block := range blocks {
+		if current.Len() > 0 && current.Len()+len(block) > maxLen {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if len(block) > maxLen {
+			parts := splitMessage(block, maxLen)
+			chunks = append(chunks, parts[:len(parts)-1]...)
+			block = parts[len(parts)-1]
+		}
+		current.WriteString(block)
+	}
+
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
+
 // splitMessage splits the markdown message into chunks that fit within WeCom limits.
 func splitMessage(msg string, maxLen int) []string {
-	// Account for the suffix overhead: "\n\n*(Part X/Y)*" which is roughly 20 bytes.
+	// Account for the header overhead: "**(Part X/Y)**\n\n" which is roughly 20 bytes.
 	// We also leave some safety buffer for JSON escaping overhead (though Go's json.Marshal handles it,
 	// byte length can grow if there are many characters needing escape).
 	// A safe chunk size is slightly smaller than the hard limit.
-	const safeLimit = 4000
+	safeLimit := maxLen
+	if safeLimit > wecomSafeLimit {
+		safeLimit = wecomSafeLimit
+	}
 
 	if len(msg) <= safeLimit {
 		return []string{msg}
@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+const (
+	// wecomTextSafeLimit is the chunk size used to stay under WeCom's 2048-byte
+	// text limit, leaving room for the part header.
+	wecomTextSafeLimit = 2000
+
+	// wecomCardMaxItems and wecomCardSubTitleLen are WeCom's limits for the
+	// horizontal_content_list length and the sub_title_text length (in characters).
+	wecomCardMaxItems    = 6
+	wecomCardSubTitleLen = 112
+)
+
+// wecomTemplateCard is a text_notice template card. Field names follow the
+// WeCom group robot API.
+type wecomTemplateCard struct {
+	CardType              string                `json:"card_type"`
+	Source                *wecomCardSource      `json:"source,omitempty"`
+	MainTitle             wecomCardTitle        `json:"main_title"`
+	EmphasisContent       *wecomCardTitle       `json:"emphasis_content,omitempty"`
+	SubTitleText          string                `json:"sub_title_text,omitempty"`
+	HorizontalContentList []wecomCardHorizontal `json:"horizontal_content_list,omitempty"`
+	CardAction            wecomCardAction       `json:"card_action"`
+}
+
+type wecomCardSource struct {
+	Desc string `json:"desc"`
+}
+
+type wecomCardTitle struct {
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+type wecomCardHorizontal struct {
+	KeyName string `json:"keyname"`
+	Value   string `json:"value"`
+}
+
+type wecomCardAction struct {
+	Type int    `json:"type"` // 1 opens URL
+	URL  string `json:"url"`
+}
+
+// formatCard renders the alert as a single template card: the health score as
+// the emphasis, the worst finding as the sub title and the non-zero finding
+// counts as the key/value list.
+func (w *WeComNotifier) formatCard(alert *model.AlertContext) *wecomTemplateCard {
+	card := &wecomTemplateCard{
+		CardType: "text_notice",
+		Source:   &wecomCardSource{Desc: "PoWA Sentinel"},
+		MainTitle: wecomCardTitle{
+			Title: w.title.render(alert),
+			Desc: alert.DisplayTime(alert.AnalysisWindow.Start, "2006-01-02 15:04") + " ~ " +
+				alert.DisplayTime(alert.AnalysisWindow.End, "2006-01-02 15:04"),
+		},
+		EmphasisContent: &wecomCardTitle{
+			Title: strconv.Itoa(alert.Summary.HealthScore),
+			Desc:  "Health Score (" + alert.Summary.HealthStatus + ")",
+		},
+		CardAction: wecomCardAction{Type: 1, URL: w.cardURL},
+	}
+
+	if ref, ok := worstFinding(alert); ok {
+		card.SubTitleText = truncateRunes(getSeverityIcon(ref.Severity)+" "+formatFindingRef(ref, 300), wecomCardSubTitleLen)
+	} else {
+		card.SubTitleText = truncateRunes(formatEmpty(alert), wecomCardSubTitleLen)
+	}
+
+	counts := []struct {
+		name string
+		n    int
+	}{
+		{"Regressions", alert.Summary.RegressionCount},
+		{"New Queries", alert.Summary.NewQueryCount},
+		{"Call Spikes", alert.Summary.CallSpikeCount},
+		{"Waits", alert.Summary.WaitEventCount},
+		{"Flapping", alert.Summary.FlappingCount},
+		{"Cross-server", alert.Summary.CrossServerCount},
+		{"Vacuum", alert.Summary.VacuumCount},
+		{"Index Tips", alert.Summary.SuggestionCount},
+		{"Custom", alert.Summary.CustomFindingCount},
+		{"Slow SQL", len(alert.TopSlowSQL)},
+	}
+	for _, c := range counts {
+		if c.n > 0 && len(card.HorizontalContentList) < wecomCardMaxItems {
+			card.HorizontalContentList = append(card.HorizontalContentList,
+				wecomCardHorizontal{KeyName: c.name, Value: strconv.Itoa(c.n)})
+		}
+	}
+	return card
+}
+
+// markdownLink matches [label](url) links, shown as "label: url" in text.
+var markdownLink = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// plainTextBlocks strips the markdown markup from blocks for text messages,
+// which WeCom shows verbatim.
+func plainTextBlocks(blocks []string) []string {
+	inline := strings.NewReplacer("**", "", "`", "")
+	out := make([]string, len(blocks))
+	for i, block := range blocks {
+		lines := strings.Split(block, "\n")
+		kept := lines[:0]
+		for _, line := range lines {
+			if strings.HasPrefix(line, "```") || line == "---" {
+				continue
+			}
+			if strings.HasPrefix(line, "#") {
+				line = strings.TrimLeft(line, "# ")
+			}
+			line = strings.TrimPrefix(line, "> ")
+			line = markdownLink.ReplaceAllString(inline.Replace(line), "$1: $2")
+			if len(line) > 1 && strings.HasPrefix(line, "*") && strings.HasSuffix(line, "*") {
+				line = line[1 : len(line)-1] // italic footer
+			}
+			kept = append(kept, line)
+		}
+		out[i] = strings.Join(kept, "\n")
+	}
+	return out
+}
+
+// truncateRunes cuts s to at most maxLen characters without splitting a
+// multi-byte character.
+func truncateRunes(s string, maxLen int) string {
+	r := []rune(s)
+	if len(r) <= maxLen {
+		return s
+	}
+	return string(r[:maxLen-3]) + "..."
+}
@@ -3,8 +3,11 @@ package notifier
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -129,3 +132,301 @@ func TestWeComNotifier_Failure(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestWeComNotifier_CancelledMidRetry(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// Without cancellation the backoff alone would take over a minute
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: ts.URL, Retries: 5, RetryDelay: "2s"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := notifier.Send(ctx, &model.AlertContext{Summary: model.AlertSummary{HealthScore: 100}})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Send() error = %v, want context.DeadlineExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "unexpected status code: 500") {
+		t.Errorf("Send() error = %v, want the last delivery error included", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Send() took %v, want it to return promptly after cancellation", elapsed)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt before cancellation, got %d", attempts)
+	}
+}
+
+func TestWeComNotifier_CancelledDuringRequest(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: ts.URL, Retries: 3, RetryDelay: "1ms"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := notifier.Send(ctx, &model.AlertContext{Summary: model.AlertSummary{HealthScore: 100}})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() took %v, want it to return promptly after cancellation", elapsed)
+	}
+}
+
+func TestWeComNotifier_ChunksLargeAlert(t *testing.T) {
+	var payloads []wecomMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg wecomMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		payloads = append(payloads, msg)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: ts.URL, RetryDelay: "1ms"})
+	notifier.minInterval = time.Millisecond
+
+	longQuery := "SELECT " + strings.Repeat("some_column_name, ", 30) + "x FROM t"
+	alert := &model.AlertContext{ReqID: "big", Summary: model.AlertSummary{HealthStatus: "critical"}}
+	for i := 0; i < 10; i++ {
+		alert.TopSlowSQL = append(alert.TopSlowSQL, model.MetricSnapshot{QueryID: int64(i), Query: longQuery})
+		alert.Regressions = append(alert.Regressions, model.RegressionItem{QueryID: int64(100 + i), Query: longQuery, Severity: "high"})
+	}
+
+	if err := notifier.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(payloads) < 2 {
+		t.Fatalf("expected multiple payloads, got %d", len(payloads))
+	}
+
+	var combined strings.Builder
+	for i, p := range payloads {
+		content := p.Markdown.Content
+		if len(content) > 4096 {
+			t.Errorf("payload %d is %d bytes, exceeds WeCom limit", i+1, len(content))
+		}
+		header := fmt.Sprintf("**(Part %d/%d)**\n\n", i+1, len(payloads))
+		if !strings.HasPrefix(content, header) {
+			t.Errorf("payload %d missing part header %q", i+1, header)
+		}
+		// Every code block opened in a payload must be closed in the same payload
+		if strings.Count(content, "```")%2 != 0 {
+			t.Errorf("payload %d splits a finding mid-entry", i+1)
+		}
+		combined.WriteString(strings.TrimPrefix(content, header))
+	}
+
+	if combined.String() != notifier.formatMessage(alert) {
+		t.Error("reassembled payloads do not match the full message")
+	}
+}
+
+func TestWeComNotifier_DisplayTimezoneAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: "http://localhost", RetryDelay: "1ms"})
+
+	// US clocks sprang forward at 2024-03-10 07:00 UTC (02:00 EST -> 03:00 EDT)
+	alert := &model.AlertContext{
+		AnalysisWindow: model.TimeWindow{
+			Start: time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC),
+		},
+		DisplayLocation: loc,
+	}
+
+	msg := notifier.formatMessage(alert)
+	want := "2024-03-10 01:00 -05:00 ~ 2024-03-10 04:00 -04:00"
+	if !strings.Contains(msg, want) {
+		t.Errorf("message does not render window %q in display timezone:\n%s", want, msg)
+	}
+
+	// JSON keeps the UTC instants
+	data, _ := json.Marshal(alert)
+	if !strings.Contains(string(data), `"start":"2024-03-10T06:00:00Z"`) {
+		t.Errorf("JSON should keep UTC timestamps, got %s", data)
+	}
+}
+
+func TestWeComNotifier_FormatDelta(t *testing.T) {
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{WebhookURL: "http://localhost", RetryDelay: "1ms"})
+	alert := &model.AlertContext{
+		ReqID:       "powa-1",
+		Regressions: []model.RegressionItem{{QueryID: 7, DatabaseName: "app", Severity: "high", Query: "SELECT 1"}},
+		Summary:     model.AlertSummary{HealthScore: 90, HealthStatus: "healthy", RegressionCount: 1},
+		Delta: &model.FindingDelta{
+			New:      []model.FindingRef{{Rule: "regression", QueryID: 7, DatabaseName: "app", Severity: "high", Subject: "SELECT 1"}},
+			Resolved: []model.FindingRef{{Rule: "index_suggestion", Subject: "orders (customer_id)"}},
+		},
+	}
+
+	msg := notifier.formatMessage(alert)
+
+	for _, want := range []string{
+		"### 🔁 New (1)\n- + [regression] [7] [app] (high) SELECT 1\n",
+		"### 🔁 Resolved (1)\n- - [index_suggestion] orders (customer_id)\n",
+		"*Report ID: powa-1*",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("delta message missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "### 📈 Performance Regressions") || strings.Contains(msg, "Still") {
+		t.Errorf("delta message should only list changes:\n%s", msg)
+	}
+}
+
+func TestWeComNotifier_TemplateCard(t *testing.T) {
+	var bodies []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	notifier, err := NewWeComNotifier(&config.NotifierConfig{
+		WebhookURL: ts.URL,
+		RetryDelay: "1ms",
+		WeCom:      config.WeComConfig{Format: config.WeComFormatTemplateCard, CardURL: "https://powa.example.com/"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+
+	alert := &model.AlertContext{
+		ReqID:   "card",
+		Summary: model.AlertSummary{HealthScore: 62, HealthStatus: "warning", RegressionCount: 2, SuggestionCount: 1},
+		Regressions: []model.RegressionItem{
+			{QueryID: 1, DatabaseName: "app", Query: "SELECT 1", Severity: "medium"},
+			{QueryID: 2, DatabaseName: "app", Query: "SELECT * FROM " + strings.Repeat("orders_", 40), Severity: "critical"},
+		},
+	}
+	if err := notifier.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("expected one payload, got %d", len(bodies))
+	}
+
+	body := bodies[0]
+	if body["msgtype"] != "template_card" {
+		t.Errorf("msgtype = %v, want template_card", body["msgtype"])
+	}
+	if _, ok := body["markdown"]; ok {
+		t.Error("template_card payload should not carry markdown")
+	}
+	card, ok := body["template_card"].(map[string]any)
+	if !ok {
+		t.Fatalf("template_card missing: %v", body)
+	}
+	if card["card_type"] != "text_notice" {
+		t.Errorf("card_type = %v, want text_notice", card["card_type"])
+	}
+	if title := card["main_title"].(map[string]any)["title"]; title != DefaultTitleTemplate {
+		t.Errorf("main_title.title = %v, want %q", title, DefaultTitleTemplate)
+	}
+	if score := card["emphasis_content"].(map[string]any)["title"]; score != "62" {
+		t.Errorf("emphasis_content.title = %v, want 62", score)
+	}
+	sub, _ := card["sub_title_text"].(string)
+	if !strings.Contains(sub, "[2]") || len([]rune(sub)) > wecomCardSubTitleLen {
+		t.Errorf("sub_title_text should name the critical finding within %d characters, got %q", wecomCardSubTitleLen, sub)
+	}
+
+	wantItems := []map[string]any{{"keyname": "Regressions", "value": "2"}, {"keyname": "Index Tips", "value": "1"}}
+	items, _ := card["horizontal_content_list"].([]any)
+	if len(items) != len(wantItems) {
+		t.Fatalf("horizontal_content_list = %v, want %v", items, wantItems)
+	}
+	for i, want := range wantItems {
+		got := items[i].(map[string]any)
+		if got["keyname"] != want["keyname"] || got["value"] != want["value"] {
+			t.Errorf("horizontal_content_list[%d] = %v, want %v", i, got, want)
+		}
+	}
+
+	action := card["card_action"].(map[string]any)
+	if action["type"] != float64(1) || action["url"] != "https://powa.example.com/" {
+		t.Errorf("card_action = %v, want type 1 with the configured url", action)
+	}
+}
+
+func TestWeComNotifier_TextFormat(t *testing.T) {
+	var payloads []wecomMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg wecomMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		payloads = append(payloads, msg)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	notifier, _ := NewWeComNotifier(&config.NotifierConfig{
+		WebhookURL: ts.URL,
+		RetryDelay: "1ms",
+		WeCom:      config.WeComConfig{Format: config.WeComFormatText},
+	})
+	notifier.minInterval = time.Millisecond
+
+	alert := &model.AlertContext{ReqID: "text", Summary: model.AlertSummary{HealthStatus: "critical"}}
+	for i := 0; i < 10; i++ {
+		alert.Regressions = append(alert.Regressions, model.RegressionItem{QueryID: int64(i),
+			Query: "SELECT * FROM " + strings.Repeat("t, ", 80) + "x", Severity: "high"})
+	}
+	if err := notifier.Send(context.Background(), alert); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(payloads) < 2 {
+		t.Fatalf("expected the alert to be split into several text messages, got %d", len(payloads))
+	}
+	for i, p := range payloads {
+		if p.MsgType != "text" || p.Text == nil {
+			t.Fatalf("payload %d: expected msgtype text, got %+v", i+1, p)
+		}
+		if len(p.Text.Content) > 2048 {
+			t.Errorf("payload %d is %d bytes, exceeds WeCom text limit", i+1, len(p.Text.Content))
+		}
+		for _, markup := range []string{"**", "```", "## "} {
+			if strings.Contains(p.Text.Content, markup) {
+				t.Errorf("payload %d still contains markdown %q", i+1, markup)
+			}
+		}
+	}
+	if !strings.Contains(payloads[0].Text.Content, "SELECT * FROM") {
+		t.Error("text conversion should keep SQL unchanged")
+	}
+}
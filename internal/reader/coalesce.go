@@ -0,0 +1,53 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// detectCoalesceRange reports whether PoWA 4's powa_statements_history has the
+// coalesce_range column its queries prune coalesced rows with. Some PoWA minor
+// versions and custom setups lack it; the queries then filter on the
+// timestamps of the records alone, which reads every row but still works.
+func (r *Reader) detectCoalesceRange(ctx context.Context) bool {
+	var has bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_attribute
+			WHERE attrelid = to_regclass('powa_statements_history')
+				AND attname = 'coalesce_range'
+				AND NOT attisdropped
+		)
+	`).Scan(&has)
+	switch {
+	case err != nil:
+		log.Printf("Warning: detecting powa_statements_history.coalesce_range: %v; filtering on record timestamps instead", err)
+		return false
+	case !has:
+		log.Printf("Warning: powa_statements_history has no coalesce_range column; filtering on record timestamps instead, which reads every row")
+	}
+	return has
+}
+
+// statementsHistory returns a FROM clause over PoWA 4's powa_statements_history
+// and the expression of each row's snapshot time: the bound ("lower" or
+// "upper") of its coalesce_range or, without that column, the ts of each of
+// its records.
+func (r *Reader) statementsHistory(bound string) (from, ts string) {
+	if r.hasCoalesceRange {
+		return "powa_statements_history", fmt.Sprintf("%s(coalesce_range)", bound)
+	}
+	return "powa_statements_history CROSS JOIN LATERAL unnest(records) AS rec", "(rec).ts"
+}
+
+// kcacheRangeFilter returns the predicate pruning a PoWA 4 kcache history
+// table, aliased k, to the coalesced rows overlapping the window, followed by
+// AND; "" without coalesce_range. PoWA adds the column to its history tables
+// together, so the powa_statements_history check stands for kcache as well.
+func (r *Reader) kcacheRangeFilter() string {
+	if !r.hasCoalesceRange {
+		return ""
+	}
+	return "k.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]')\n\t\t\t\t\tAND "
+}
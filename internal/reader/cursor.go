@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// metricsCursor names the server-side cursor metrics queries are read through.
+const metricsCursor = "powa_sentinel_metrics"
+
+// eachRow runs query and calls scan on each of its rows. With
+// database.use_cursor the rows are read through a server-side cursor,
+// database.cursor_batch_size at a time, so memory stays flat however many rows
+// the query returns; otherwise they come from one result set. Connection
+// failures are retried (see queryWithRetry) until the first row is read, not
+// once scan has consumed rows.
+func (r *Reader) eachRow(ctx context.Context, query string, args []any, scan func(*sql.Rows) error) error {
+	if !r.cfg.UseCursor {
+		rows, err := r.queryWithRetry(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+
+	var tx *sql.Tx
+	err := r.withRetry(ctx, func() error {
+		var err error
+		if tx, err = r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "DECLARE "+metricsCursor+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("declaring cursor: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Nothing is written: rolling back closes the cursor
+	defer func() { _ = tx.Rollback() }()
+
+	batch := r.cfg.CursorBatch()
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", batch, metricsCursor)
+	for {
+		n, err := fetchBatch(ctx, tx, fetch, scan)
+		if err != nil {
+			return err
+		}
+		if n < batch {
+			return nil
+		}
+	}
+}
+
+// fetchBatch runs one FETCH on tx and scans its rows, returning how many it read.
+func fetchBatch(ctx context.Context, tx *sql.Tx, fetch string, scan func(*sql.Rows) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, fmt.Errorf("fetching from cursor: %w", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+		if err := scan(rows); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CustomResult holds the rows of a custom rule query rendered as text.
+type CustomResult struct {
+	Columns []string
+	Rows    [][]sql.NullString
+}
+
+// QueryCustom runs a user-defined query in a read-only transaction with the
+// given statement_timeout and returns every row as text.
+func (r *Reader) QueryCustom(ctx context.Context, query string, timeout time.Duration) (*CustomResult, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("starting read-only transaction: %w", err)
+	}
+	// Nothing is written; always roll back
+	defer tx.Rollback()
+
+	timeoutMS := strconv.FormatInt(timeout.Milliseconds(), 10)
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('statement_timeout', $1, true)", timeoutMS); err != nil {
+		return nil, fmt.Errorf("setting statement_timeout: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("running custom query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("reading custom query columns: %w", err)
+	}
+
+	result := &CustomResult{Columns: columns}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanning custom query row: %w", err)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating custom query rows: %w", err)
+	}
+
+	return result, nil
+}
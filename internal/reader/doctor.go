@@ -0,0 +1,198 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CheckStatus is the outcome of a single diagnostic check.
+type CheckStatus string
+
+// Diagnostic check outcomes. Only CheckFail makes the overall diagnosis fail.
+const (
+	CheckPass CheckStatus = "PASS"
+	CheckWarn CheckStatus = "WARN"
+	CheckFail CheckStatus = "FAIL"
+)
+
+// CheckResult describes one diagnostic check performed by Diagnose.
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	Hint   string // actionable advice when the check did not pass
+}
+
+// Diagnosis is the ordered list of checks performed by Diagnose.
+type Diagnosis []CheckResult
+
+// Failed reports whether any hard check failed.
+func (d Diagnosis) Failed() bool {
+	for _, c := range d {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of checks with the given status.
+func (d Diagnosis) Count(status CheckStatus) int {
+	n := 0
+	for _, c := range d {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Diagnose checks common setup issues: connectivity, PoWA and optional extension
+// presence, SELECT privileges on the tables powa-sentinel reads, and whether the
+// statement history holds data newer than staleAfter. It reuses the extension
+// detection performed before every analysis.
+func (r *Reader) Diagnose(ctx context.Context, staleAfter time.Duration) Diagnosis {
+	var d Diagnosis
+
+	if err := r.Ping(ctx); err != nil {
+		return append(d, CheckResult{
+			Name: "Database connection", Status: CheckFail, Detail: err.Error(),
+			Hint: "Check database.host, port, user, password and sslmode, and that the repository accepts connections from this host",
+		})
+	}
+	d = append(d, CheckResult{Name: "Database connection", Status: CheckPass, Detail: fmt.Sprintf("%s:%d/%s", r.cfg.Host, r.cfg.Port, r.cfg.DBName)})
+	d = append(d, r.checkReadOnlySession(ctx))
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return append(d, CheckResult{
+			Name: "PoWA extension", Status: CheckFail, Detail: err.Error(),
+			Hint: "Connect to the PoWA repository database (usually 'powa') where CREATE EXTENSION powa was run",
+		})
+	}
+	d = append(d, CheckResult{Name: "PoWA extension", Status: CheckPass, Detail: fmt.Sprintf("powa %s on PostgreSQL %d", r.powaVersion, r.pgVersion)})
+
+	if r.hasKCache {
+		d = append(d, CheckResult{Name: "pg_stat_kcache", Status: CheckPass, Detail: fmt.Sprintf("history table %s", r.KCacheTable())})
+	} else {
+		d = append(d, CheckResult{
+			Name: "pg_stat_kcache", Status: r.missingStatus("pg_stat_kcache"), Detail: "not available",
+			Hint: "Install pg_stat_kcache to enable cpu_time/io_time ranking",
+		})
+	}
+
+	if r.hasQualStats {
+		d = append(d, CheckResult{Name: "pg_qualstats", Status: CheckPass, Detail: "available"})
+	} else {
+		d = append(d, CheckResult{
+			Name: "pg_qualstats", Status: r.missingStatus("pg_qualstats"), Detail: "not available",
+			Hint: "Install pg_qualstats to enable index suggestions",
+		})
+	}
+
+	if r.hasWaits {
+		d = append(d, CheckResult{Name: "pg_wait_sampling", Status: CheckPass, Detail: "available"})
+	} else {
+		d = append(d, CheckResult{
+			Name: "pg_wait_sampling", Status: r.missingStatus("pg_wait_sampling"), Detail: "not available",
+			Hint: "Install pg_wait_sampling and enable it in PoWA to enable the waits rule",
+		})
+	}
+
+	for _, table := range r.requiredTables() {
+		d = append(d, r.checkSelectPrivilege(ctx, table))
+	}
+
+	if r.hasQualStats {
+		d = append(d, r.checkRelation(ctx, "powa_qualstats_indexes", CheckWarn,
+			"Upgrade PoWA or grant SELECT on powa_qualstats_indexes; index suggestions are skipped without it"))
+	}
+
+	d = append(d, r.checkRecentData(ctx, staleAfter))
+
+	return d
+}
+
+// requiredTables lists the relations read during analysis for the detected PoWA version.
+func (r *Reader) requiredTables() []string {
+	tables := []string{"powa_statements_history", "powa_statements", "powa_databases"}
+	if r.isPoWA4() {
+		tables = append(tables, "powa_servers")
+	}
+	if table := r.KCacheTable(); r.hasKCache && table != "" {
+		tables = append(tables, table)
+	}
+	if r.hasWaits {
+		tables = append(tables, "powa_wait_sampling_history")
+	}
+	return tables
+}
+
+// checkSelectPrivilege verifies the relation exists and the current user can SELECT from it.
+func (r *Reader) checkSelectPrivilege(ctx context.Context, table string) CheckResult {
+	name := "SELECT on " + table
+	var exists, allowed bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT to_regclass($1) IS NOT NULL,
+			COALESCE(has_table_privilege(to_regclass($1), 'SELECT'), false)
+	`, table).Scan(&exists, &allowed)
+	switch {
+	case err != nil:
+		return CheckResult{Name: name, Status: CheckFail, Detail: err.Error()}
+	case !exists:
+		return CheckResult{Name: name, Status: CheckFail, Detail: "relation not found",
+			Hint: "Check that the PoWA schema is on the search_path of database.user"}
+	case !allowed:
+		return CheckResult{Name: name, Status: CheckFail, Detail: "permission denied",
+			Hint: fmt.Sprintf("GRANT SELECT ON %s TO %s", table, r.cfg.User)}
+	}
+	return CheckResult{Name: name, Status: CheckPass}
+}
+
+// checkReadOnlySession verifies the session defaults to read-only transactions,
+// as requested by the connection string.
+func (r *Reader) checkReadOnlySession(ctx context.Context) CheckResult {
+	const name = "Read-only session"
+	var readOnly string
+	if err := r.db.QueryRowContext(ctx, "SHOW default_transaction_read_only").Scan(&readOnly); err != nil {
+		return CheckResult{Name: name, Status: CheckWarn, Detail: err.Error()}
+	}
+	if readOnly != "on" {
+		return CheckResult{Name: name, Status: CheckFail, Detail: "default_transaction_read_only = " + readOnly,
+			Hint: "A pooler in front of the repository may drop startup parameters; set default_transaction_read_only for database.user"}
+	}
+	return CheckResult{Name: name, Status: CheckPass}
+}
+
+// checkRelation verifies the relation exists, reporting status when it does not.
+func (r *Reader) checkRelation(ctx context.Context, relation string, status CheckStatus, hint string) CheckResult {
+	name := relation + " exists"
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, relation).Scan(&exists); err != nil {
+		return CheckResult{Name: name, Status: status, Detail: err.Error(), Hint: hint}
+	}
+	if !exists {
+		return CheckResult{Name: name, Status: status, Detail: "relation not found", Hint: hint}
+	}
+	return CheckResult{Name: name, Status: CheckPass}
+}
+
+// checkRecentData warns when the newest statement history is older than staleAfter.
+func (r *Reader) checkRecentData(ctx context.Context, staleAfter time.Duration) CheckResult {
+	const name = "Recent statement history"
+	latest, err := r.LatestSnapshotTime(ctx)
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckWarn, Detail: err.Error()}
+	}
+	if latest.IsZero() {
+		return CheckResult{Name: name, Status: CheckWarn, Detail: "no history rows",
+			Hint: "Check that powa-collector (or the local background worker) is running"}
+	}
+
+	age := time.Since(latest).Round(time.Second)
+	if age > staleAfter {
+		return CheckResult{Name: name, Status: CheckWarn, Detail: fmt.Sprintf("latest data is %s old", age),
+			Hint: "Check that powa-collector (or the local background worker) is running and snapshots are being coalesced"}
+	}
+	return CheckResult{Name: name, Status: CheckPass, Detail: fmt.Sprintf("latest data %s ago", age)}
+}
@@ -0,0 +1,78 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// explainableStatements are the statements Explain plans. Anything else,
+// EXPLAIN itself included, is refused, so EXPLAIN ANALYZE never runs.
+var explainableStatements = []string{"SELECT", "WITH", "VALUES", "TABLE", "INSERT", "UPDATE", "DELETE", "MERGE"}
+
+// placeholderPattern matches the $n parameters of normalized query text.
+var placeholderPattern = regexp.MustCompile(`\$\d+`)
+
+// Explain returns the text plan of query, which must run in database, on the
+// instance the reader is connected to (database.monitored). The query is only
+// planned, never executed: Explain runs EXPLAIN without ANALYZE, in the
+// session's read-only transaction, and refuses text holding more than one
+// statement. Normalized text with $n placeholders is planned with
+// GENERIC_PLAN, which needs PostgreSQL 16 or later.
+func (r *Reader) Explain(ctx context.Context, database, query string) (string, error) {
+	stmt, err := explainStatement(query)
+	if err != nil {
+		return "", err
+	}
+	if database != r.cfg.DBName {
+		return "", fmt.Errorf("query runs in database %s, the monitored connection is to %s", database, r.cfg.DBName)
+	}
+
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return "", fmt.Errorf("explaining query: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("scanning plan: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("reading plan: %w", err)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// explainStatement returns the EXPLAIN statement for query, or an error when
+// query is not a single plannable statement.
+func explainStatement(query string) (string, error) {
+	q := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if strings.Contains(q, ";") {
+		return "", errors.New("query text holds more than one statement")
+	}
+	fields := strings.Fields(q)
+	if len(fields) == 0 {
+		return "", errors.New("query text is empty")
+	}
+	if keyword := strings.ToUpper(strings.TrimLeft(fields[0], "(")); !slices.Contains(explainableStatements, keyword) {
+		return "", fmt.Errorf("%s statements are not explained", keyword)
+	}
+	if placeholderPattern.MatchString(q) {
+		return "EXPLAIN (GENERIC_PLAN) " + q, nil
+	}
+	return "EXPLAIN " + q, nil
+}
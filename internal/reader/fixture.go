@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// Fixture is a recorded set of PoWA data used to exercise rules without a live
+// database. See docs/en/guides/fixtures.md for the file format.
+type Fixture struct {
+	Current     []model.MetricSnapshot  `json:"current"`
+	Baseline    []model.MetricSnapshot  `json:"baseline"`
+	Suggestions []model.IndexSuggestion `json:"suggestions,omitempty"`
+}
+
+// FixtureReader serves metrics from a Fixture instead of the PoWA repository.
+// Window and offset arguments are ignored: the fixture already holds the
+// current and baseline snapshots.
+type FixtureReader struct {
+	fixture Fixture
+}
+
+// NewFixtureReader loads a JSON fixture from path.
+func NewFixtureReader(path string) (*FixtureReader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture file: %w", err)
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing fixture file: %w", err)
+	}
+
+	return &FixtureReader{fixture: f}, nil
+}
+
+// GetCurrentMetrics returns a copy of the fixture's current snapshots.
+func (f *FixtureReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return copySnapshots(f.fixture.Current), nil
+}
+
+// GetBaselineMetrics returns a copy of the fixture's baseline snapshots.
+func (f *FixtureReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	return copySnapshots(f.fixture.Baseline), nil
+}
+
+// GetIndexSuggestions returns the fixture's index suggestions.
+func (f *FixtureReader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error) {
+	out := make([]model.IndexSuggestion, len(f.fixture.Suggestions))
+	copy(out, f.fixture.Suggestions)
+	return out, nil
+}
+
+// copySnapshots returns a copy so the engine can mutate results (e.g. redaction)
+// without affecting later runs against the same fixture.
+func copySnapshots(in []model.MetricSnapshot) []model.MetricSnapshot {
+	out := make([]model.MetricSnapshot, len(in))
+	copy(out, in)
+	return out
+}
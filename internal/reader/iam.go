@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// TokenProvider supplies a short-lived password, such as an AWS RDS IAM auth
+// token, each time the pool opens a new connection.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// NewWithTokenProvider creates a Reader whose connections authenticate with a
+// fresh token from provider instead of cfg.Password.
+func NewWithTokenProvider(cfg *config.DatabaseConfig, provider TokenProvider) *Reader {
+	db := sql.OpenDB(withInitSQL(&tokenConnector{cfg: cfg, provider: provider}, cfg.InitSQL))
+
+	// Configure connection pool. RDS IAM tokens are valid for 15 minutes, but they
+	// are only checked at connect time, so the usual lifetime is fine.
+	db.SetMaxOpenConns(config.DatabasePoolSize)
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	acquireTimeout, _ := cfg.AcquireTimeoutParsed() // validated with the rest of the configuration
+	retryDelay, _ := cfg.RetryDelayParsed()
+	return &Reader{
+		db:             db,
+		cfg:            cfg,
+		limiter:        newQueryLimiter(cfg.QueryConcurrency()),
+		acquireTimeout: acquireTimeout,
+		retries:        cfg.Retries,
+		retryDelay:     retryDelay,
+	}
+}
+
+// tokenConnector is a driver.Connector that injects a provider token as the
+// password for every new lib/pq connection.
+type tokenConnector struct {
+	cfg      *config.DatabaseConfig
+	provider TokenProvider
+}
+
+// Connect fetches a token and opens a lib/pq connection with it.
+func (c *tokenConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("building connector: %w", err)
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the underlying lib/pq driver.
+func (c *tokenConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// dsn builds the connection string with the current token as password.
+func (c *tokenConnector) dsn(ctx context.Context) (string, error) {
+	token, err := c.provider.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("fetching auth token: %w", err)
+	}
+	d := c.cfg
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s %s",
+		d.Host, d.Port, d.User, config.QuoteDSNValue(token), d.DBName, d.SSLMode, d.SessionParams(),
+	), nil
+}
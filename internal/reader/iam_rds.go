@@ -0,0 +1,44 @@
+//go:build rdsiam
+
+package reader
+
+import (
+	"context"
+	"fmt"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// rdsTokenProvider generates RDS IAM auth tokens using the AWS SDK default
+// credential chain (environment, shared config, IRSA, instance profile).
+type rdsTokenProvider struct {
+	endpoint string
+	region   string
+	user     string
+}
+
+// newRDSTokenProvider returns the AWS SDK backed TokenProvider.
+func newRDSTokenProvider(cfg *config.DatabaseConfig) (TokenProvider, error) {
+	return &rdsTokenProvider{
+		endpoint: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		region:   cfg.AWSRegion,
+		user:     cfg.User,
+	}, nil
+}
+
+// Token signs a new auth token. Credentials are resolved on every call so
+// rotated credentials are picked up when the pool opens new connections.
+func (p *rdsTokenProvider) Token(ctx context.Context) (string, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if p.region != "" {
+		opts = append(opts, awsconfig.WithRegion(p.region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("loading AWS config: %w", err)
+	}
+	return auth.BuildAuthToken(ctx, p.endpoint, awsCfg.Region, p.user, awsCfg.Credentials)
+}
@@ -0,0 +1,15 @@
+//go:build !rdsiam
+
+package reader
+
+import (
+	"errors"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// newRDSTokenProvider is unavailable in default builds so the AWS SDK is not a
+// dependency for users who do not need IAM auth. Build with -tags rdsiam.
+func newRDSTokenProvider(cfg *config.DatabaseConfig) (TokenProvider, error) {
+	return nil, errors.New("database.iam_auth requires a binary built with -tags rdsiam")
+}
@@ -0,0 +1,44 @@
+package reader
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// initConnector is a driver.Connector that runs database.init_sql on every new
+// connection before the pool hands it out, e.g. SET search_path or SET ROLE.
+type initConnector struct {
+	driver.Connector
+	statements []string
+}
+
+// withInitSQL wraps connector so statements run on each new connection; it
+// returns connector unchanged when there are none.
+func withInitSQL(connector driver.Connector, statements []string) driver.Connector {
+	if len(statements) == 0 {
+		return connector
+	}
+	return &initConnector{Connector: connector, statements: statements}
+}
+
+// Connect opens a connection and runs the init statements in order. A failing
+// statement closes the connection, so no session is used half-initialized.
+func (c *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("running init_sql: driver connection cannot execute statements")
+	}
+	for _, stmt := range c.statements {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("running init_sql %q: %w", stmt, err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,35 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// GetConnectionUsage counts the client connections of the instance the reader
+// is connected to, which must be the monitored instance (database.monitored)
+// rather than the PoWA repository. Background workers and replication senders
+// do not count against max_connections and are left out.
+func (r *Reader) GetConnectionUsage(ctx context.Context) (model.ConnectionUsage, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return model.ConnectionUsage{}, err
+	}
+	defer release()
+
+	query := `
+		SELECT
+			count(*) FILTER (WHERE state = 'active'),
+			count(*) FILTER (WHERE state LIKE 'idle%'),
+			count(*),
+			current_setting('max_connections')::int
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'
+	`
+	var usage model.ConnectionUsage
+	if err := r.db.QueryRowContext(ctx, query).Scan(&usage.Active, &usage.Idle, &usage.Total, &usage.MaxConnections); err != nil {
+		return model.ConnectionUsage{}, fmt.Errorf("querying connection usage: %w", err)
+	}
+	return usage, nil
+}
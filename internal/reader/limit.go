@@ -0,0 +1,82 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrPoolExhausted is returned by reader calls that could not get a connection
+// within database.acquire_timeout, as opposed to running out of their own time.
+var ErrPoolExhausted = errors.New("connection pool exhausted")
+
+// QueryStats reports reader queries holding or waiting for a concurrency slot.
+type QueryStats struct {
+	InFlight int64
+	Queued   int64
+}
+
+// queryLimiter bounds the reader calls in flight (database.max_concurrent_queries),
+// so concurrent rules wait here for a slot instead of piling up on the pool and
+// running into their context deadlines.
+type queryLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+	queued   atomic.Int64
+}
+
+func newQueryLimiter(n int) *queryLimiter {
+	return &queryLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a slot or for ctx to be done. The returned func releases the slot.
+func (l *queryLimiter) acquire(ctx context.Context) (func(), error) {
+	l.queued.Add(1)
+	select {
+	case l.slots <- struct{}{}:
+		l.queued.Add(-1)
+		l.inFlight.Add(1)
+		return func() {
+			l.inFlight.Add(-1)
+			<-l.slots
+		}, nil
+	case <-ctx.Done():
+		l.queued.Add(-1)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *queryLimiter) stats() QueryStats {
+	return QueryStats{InFlight: l.inFlight.Load(), Queued: l.queued.Load()}
+}
+
+// acquire takes a query slot; readers built without a limiter are not bounded.
+// The limiter never admits more calls than the pool has connections, so
+// waiting for a slot is waiting for a connection: after acquireTimeout the call
+// fails with ErrPoolExhausted instead of spending the rest of its deadline.
+func (r *Reader) acquire(ctx context.Context) (func(), error) {
+	if r.limiter == nil {
+		return func() {}, nil
+	}
+	if r.acquireTimeout <= 0 {
+		return r.limiter.acquire(ctx)
+	}
+	acquireCtx, cancel := context.WithTimeout(ctx, r.acquireTimeout)
+	defer cancel()
+	release, err := r.limiter.acquire(acquireCtx)
+	if err != nil && ctx.Err() == nil {
+		stats := r.limiter.stats()
+		return nil, fmt.Errorf("%w: no connection free within %s (%d reader calls in flight, %d queued)",
+			ErrPoolExhausted, r.acquireTimeout, stats.InFlight, stats.Queued)
+	}
+	return release, err
+}
+
+// QueryStats returns the number of reader calls running and waiting for a slot.
+func (r *Reader) QueryStats() QueryStats {
+	if r.limiter == nil {
+		return QueryStats{}
+	}
+	return r.limiter.stats()
+}
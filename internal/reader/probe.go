@@ -0,0 +1,45 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HasDataInWindow reports whether powa_statements_history holds a snapshot
+// within the window ending now, the rows GetCurrentMetrics would aggregate.
+// It stops at the first matching row, so an idle window costs one cheap
+// query instead of the full metrics scan.
+func (r *Reader) HasDataInWindow(ctx context.Context, window time.Duration) (bool, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return false, err
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM powa_statements_history ps WHERE ps.ts >= $1 AND ps.ts <= $2)`
+	if r.isPoWA4() {
+		// Same pruning as getMetricsFor: skip coalesced rows outside the window
+		var rangeFilter string
+		if r.hasCoalesceRange {
+			rangeFilter = "ps.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]') AND "
+		}
+		query = fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1 FROM powa_statements_history ps
+				CROSS JOIN LATERAL unnest(ps.records) AS r
+				WHERE %s(r).ts >= $1 AND (r).ts <= $2
+			)`, rangeFilter)
+	}
+
+	endTime := time.Now()
+	var has bool
+	if err := r.db.QueryRowContext(ctx, query, endTime.Add(-window), endTime).Scan(&has); err != nil {
+		return false, fmt.Errorf("probing statements history: %w", err)
+	}
+	return has, nil
+}
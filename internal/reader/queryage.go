@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetQueryFirstSeen returns the timestamp of the earliest retained snapshot of
+// each of the given queries. Queries already present in the oldest retained
+// snapshot are left out: they predate the history, so their first-seen time
+// would only reflect PoWA's retention. No queryids fetch nothing.
+func (r *Reader) GetQueryFirstSeen(ctx context.Context, queryIDs []int64) (map[int64]time.Time, error) {
+	if len(queryIDs) == 0 {
+		return nil, nil
+	}
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return nil, err
+	}
+
+	seen := `SELECT queryid, ts FROM powa_statements_history`
+	if r.isPoWA4() {
+		// Recent snapshots stay in the _current table until they are coalesced
+		from, ts := r.statementsHistory("lower")
+		seen = fmt.Sprintf(`
+			SELECT queryid, %s AS ts FROM %s
+			UNION ALL
+			SELECT queryid, (record).ts FROM powa_statements_history_current`, ts, from)
+	}
+	query := fmt.Sprintf(`
+		WITH seen AS (%s)
+		SELECT queryid, min(ts)
+		FROM seen
+		WHERE queryid = ANY($1)
+		GROUP BY queryid
+		HAVING min(ts) > (SELECT min(ts) FROM seen)
+	`, seen)
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(queryIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying query first-seen times: %w", err)
+	}
+	defer rows.Close()
+
+	firstSeen := make(map[int64]time.Time)
+	for rows.Next() {
+		var queryID int64
+		var ts time.Time
+		if err := rows.Scan(&queryID, &ts); err != nil {
+			return nil, fmt.Errorf("scanning first-seen row: %w", err)
+		}
+		firstSeen[queryID] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating first-seen rows: %w", err)
+	}
+	return firstSeen, nil
+}
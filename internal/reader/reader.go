@@ -4,9 +4,12 @@ package reader
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,44 +18,147 @@ import (
 	"github.com/powa-team/powa-sentinel/internal/model"
 )
 
-// MaxQueryRows limits the number of rows returned by metrics queries.
-const MaxQueryRows = 10000
+// maxExampleValues caps the constants kept per index suggestion column.
+const maxExampleValues = 3
+
+// maxKCacheChoices bounds the windows whose kcache table choice is kept; the
+// cache is cleared when it fills, as the windows move with every run.
+const maxKCacheChoices = 16
+
+// kcacheWindow identifies a window by its bounds for the kcache table choice.
+type kcacheWindow struct {
+	start, end int64 // UnixNano
+}
 
 // Reader handles database connections and queries to the PoWA repository.
 type Reader struct {
-	db           *sql.DB
-	cfg          *config.DatabaseConfig
-	hasKCache    bool
-	hasQualStats bool
-	pgVersion    int    // e.g. 140000
-	powaVersion  string // e.g. 4.0.1
-	kcacheTable  string // Detected table name for kcache history
+	db               *sql.DB
+	cfg              *config.DatabaseConfig
+	hasKCache        bool
+	hasQualStats     bool
+	hasWaits         bool    // pg_wait_sampling is installed and PoWA stores its history
+	hasCoalesceRange bool    // PoWA 4 powa_statements_history has coalesce_range; without it queries filter on record timestamps
+	pgVersion        int     // e.g. 140000
+	powaVersion      string  // e.g. 4.0.1
+	kcacheTable      string  // Detected table name for kcache history; guarded by kcacheMu
+	database         string  // when set, metrics are restricted to this database name
+	minGain          float64 // index suggestions below this estimated improvement % are not fetched
+	maxScanErrs      float64 // fail index suggestions when more than this fraction of rows fails to scan; 0 never fails
+	maxQueryLen      int     // query text longer than this many bytes is truncated; 0 keeps it whole
+	keepEmpty        bool    // statements without query text get a placeholder instead of being dropped
+	skipKCache       bool    // no rule or output uses the pg_stat_kcache metrics: metrics are not enriched with them
+	wantStddev       bool    // detect and read the execution time stddev (rules.regression.metric tail)
+	stddevCol        string  // history column of the execution time stddev; empty when not read
+
+	includeSchemas []string // when set, index suggestions are restricted to these schemas
+	excludeSchemas []string // index suggestions in these schemas are not fetched
+
+	// kcacheCandidates lists every PoWA 4 kcache history table, shortest name first,
+	// when more than one exists; each window enriches from one that has data.
+	kcacheCandidates []string
+	kcacheChoices    map[kcacheWindow]string // candidate chosen per window; guarded by kcacheMu
+	kcacheMu         sync.Mutex
+
+	limiter        *queryLimiter // bounds concurrent calls to database.max_concurrent_queries
+	acquireTimeout time.Duration // database.acquire_timeout; 0 waits for the call's context
+
+	retries    int           // database.retries: metrics queries retried on connection failures
+	retryDelay time.Duration // database.retry_delay, doubled for each retry
 
 	// extensionsOnce ensures extension check runs only once
-	extensionsOnce sync.Once
-	extensionsErr  error
+	extensionsOnce    sync.Once
+	extensionsErr     error
+	missingExtensions []string // database.expected_extensions that the check did not find
+
+	rowLimitMu   sync.Mutex
+	rowLimitHits map[string]time.Time // when each source last returned database.max_query_rows rows
 }
 
 // New creates a new Reader with the given database configuration.
 func New(cfg *config.DatabaseConfig) (*Reader, error) {
-	db, err := sql.Open("postgres", cfg.DSN())
+	if cfg.IAMAuth {
+		provider, err := newRDSTokenProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewWithTokenProvider(cfg, provider), nil
+	}
+
+	connector, err := newSSLConnector(cfg, func(cfg *config.DatabaseConfig) (driver.Connector, error) {
+		return pq.NewConnector(cfg.DSN())
+	})
 	if err != nil {
 		return nil, fmt.Errorf("opening database connection: %w", err)
 	}
+	db := sql.OpenDB(withInitSQL(connector, cfg.InitSQL))
 
 	// Configure connection pool
-	db.SetMaxOpenConns(5)
+	db.SetMaxOpenConns(config.DatabasePoolSize)
 	db.SetMaxIdleConns(2)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
+	acquireTimeout, _ := cfg.AcquireTimeoutParsed() // validated with the rest of the configuration
+	retryDelay, _ := cfg.RetryDelayParsed()
 	reader := &Reader{
-		db:  db,
-		cfg: cfg,
+		db:             db,
+		cfg:            cfg,
+		limiter:        newQueryLimiter(cfg.QueryConcurrency()),
+		acquireTimeout: acquireTimeout,
+		retries:        cfg.Retries,
+		retryDelay:     retryDelay,
 	}
 
 	return reader, nil
 }
 
+// SetDatabaseFilter restricts metrics queries to a single monitored database.
+// An empty name reads every database in the repository.
+func (r *Reader) SetDatabaseFilter(name string) {
+	r.database = name
+}
+
+// SetMinImprovement skips index suggestions whose estimated improvement is below
+// pct, so low-value rows do not take up the suggestion row limit.
+func (r *Reader) SetMinImprovement(pct float64) {
+	r.minGain = pct
+}
+
+// SetMaxScanErrorRatio fails GetIndexSuggestions when more than ratio of its
+// rows fail to scan, which points at a schema mismatch rather than a few bad
+// rows. 0 only logs the failures.
+func (r *Reader) SetMaxScanErrorRatio(ratio float64) {
+	r.maxScanErrs = ratio
+}
+
+// SetMaxQueryLength truncates query text to n bytes as snapshots are read, so
+// enormous ORM queries do not bloat alerts and logs. 0 keeps the full text.
+func (r *Reader) SetMaxQueryLength(n int) {
+	r.maxQueryLen = n
+}
+
+// SetSkipEmptyQueries drops statements whose query text PoWA has not collected
+// yet (NULL or empty), which is the default; with skip false they are kept with
+// a "<query text unavailable: queryid N>" placeholder.
+func (r *Reader) SetSkipEmptyQueries(skip bool) {
+	r.keepEmpty = !skip
+}
+
+// SetKCacheEnrichment controls whether metrics queries are enriched with the
+// pg_stat_kcache CPU and block I/O metrics when the extension is available.
+// It is on by default; turning it off saves the enrichment query of every
+// window when nothing consumes those metrics (see engine.NeedsKCache).
+func (r *Reader) SetKCacheEnrichment(enabled bool) {
+	r.skipKCache = !enabled
+}
+
+// SetSchemaFilter restricts index suggestions to tables in include (all schemas
+// when empty) and outside exclude, so other schemas do not take up the
+// suggestion row limit.
+func (r *Reader) SetSchemaFilter(include, exclude []string) {
+	r.includeSchemas = include
+	r.excludeSchemas = exclude
+}
+
 // Ping tests the database connection.
 func (r *Reader) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
@@ -63,7 +169,7 @@ func (r *Reader) Close() error {
 	return r.db.Close()
 }
 
-// checkExtensions checks for optional extensions (pg_stat_kcache, pg_qualstats).
+// checkExtensions checks for optional extensions (pg_stat_kcache, pg_qualstats, pg_wait_sampling).
 // Thread-safe: uses sync.Once to ensure it only runs once.
 func (r *Reader) checkExtensions(ctx context.Context) error {
 	r.extensionsOnce.Do(func() {
@@ -108,39 +214,55 @@ func (r *Reader) checkExtensions(ctx context.Context) error {
 		}
 		r.hasQualStats = hasQualStats
 
+		// Check for pg_wait_sampling and the PoWA history table it feeds
+		var hasWaits bool
+		err = r.db.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = 'pg_wait_sampling')
+				AND to_regclass('powa_wait_sampling_history') IS NOT NULL
+		`).Scan(&hasWaits)
+		if err != nil {
+			r.extensionsErr = fmt.Errorf("checking pg_wait_sampling extension: %w", err)
+			return
+		}
+		r.hasWaits = hasWaits
+
+		if r.isPoWA4() {
+			r.hasCoalesceRange = r.detectCoalesceRange(ctx)
+		}
+		if r.wantStddev {
+			r.stddevCol = r.detectStddevColumn(ctx)
+		}
+
 		// If PoWA 4+ and kcache is enabled, try to find the correct history table
 		if r.hasKCache && r.isPoWA4() {
 			// Search for a table matching powa_%kcache%history in both public and powa schemas
 			// (PoWA archivist typically creates tables in the powa schema)
-			var schemaName, tableName string
-			err := r.db.QueryRowContext(ctx, `
-				SELECT schemaname, tablename 
-				FROM pg_tables 
-				WHERE schemaname IN ('public', 'powa') 
-				AND tablename LIKE 'powa_%kcache%history'
-				ORDER BY length(tablename) ASC 
-				LIMIT 1
-			`).Scan(&schemaName, &tableName)
-
+			tables, err := r.findKCacheTables(ctx)
 			if err != nil {
-				if err == sql.ErrNoRows {
-					log.Printf("Warning: pg_stat_kcache extension present but no history table found in PoWA 4. Disabling kcache enrichment.")
-					r.hasKCache = false
-				} else {
-					// Don't fail completely, just log
-					log.Printf("Warning: error searching for kcache table: %v. Disabling kcache.", err)
-					r.hasKCache = false
-				}
+				// Don't fail completely, just log
+				log.Printf("Warning: error searching for kcache table: %v. Disabling kcache.", err)
+				r.hasKCache = false
+			} else if len(tables) == 0 {
+				log.Printf("Warning: pg_stat_kcache extension present but no history table found in PoWA 4. Disabling kcache enrichment.")
+				r.hasKCache = false
 			} else {
-				r.kcacheTable = schemaName + "." + tableName
+				r.kcacheTable = tables[0]
 				log.Printf("Detected PoWA 4 kcache table: %s", r.kcacheTable)
+				if len(tables) > 1 {
+					r.kcacheCandidates = tables
+					log.Printf("Found %d kcache history tables (%s); each window uses the first one with data", len(tables), strings.Join(tables, ", "))
+				}
 			}
 		} else if r.hasKCache && !r.isPoWA4() {
 			// Default for PoWA 3
 			r.kcacheTable = "powa_kcache_metrics_history"
 		}
 
-		log.Printf("Extension check: pg_stat_kcache=%v (table=%s), pg_qualstats=%v, powa_version=%s", r.hasKCache, r.kcacheTable, r.hasQualStats, r.powaVersion)
+		log.Printf("Extension check: pg_stat_kcache=%v (table=%s), pg_qualstats=%v, pg_wait_sampling=%v, powa_version=%s",
+			r.hasKCache, r.kcacheTable, r.hasQualStats, r.hasWaits, r.powaVersion)
+		if r.hasKCache && r.skipKCache {
+			log.Printf("pg_stat_kcache metrics are not read: no enabled rule or output uses them")
+		}
 
 		// Optional environment expectation check: compare expected_extensions with actual availability
 		if len(r.cfg.ExpectedExtensions) > 0 {
@@ -151,6 +273,9 @@ func (r *Reader) checkExtensions(ctx context.Context) error {
 			if r.hasQualStats {
 				actual["pg_qualstats"] = true
 			}
+			if r.hasWaits {
+				actual["pg_wait_sampling"] = true
+			}
 			seenMissing := make(map[string]bool)
 			var missing []string
 			for _, ext := range r.cfg.ExpectedExtensions {
@@ -160,7 +285,9 @@ func (r *Reader) checkExtensions(ctx context.Context) error {
 				}
 			}
 			if len(missing) > 0 {
-				log.Printf("Environment check: expected extensions %v; missing: %v", r.cfg.ExpectedExtensions, missing)
+				r.missingExtensions = missing
+				log.Printf("WARNING: Environment check: expected extensions %v; missing: %v. Features that need them are disabled",
+					r.cfg.ExpectedExtensions, missing)
 			}
 		}
 	})
@@ -168,6 +295,114 @@ func (r *Reader) checkExtensions(ctx context.Context) error {
 	return r.extensionsErr
 }
 
+// CheckExpectedExtensions runs the extension check and compares its result with
+// database.expected_extensions. Missing extensions are only logged, unless
+// database.strict_extensions is set: then they are an error.
+func (r *Reader) CheckExpectedExtensions(ctx context.Context) error {
+	if err := r.checkExtensions(ctx); err != nil {
+		return err
+	}
+	if r.cfg.StrictExtensions && len(r.missingExtensions) > 0 {
+		return fmt.Errorf("expected extensions missing: %s (database.strict_extensions is set)", strings.Join(r.missingExtensions, ", "))
+	}
+	return nil
+}
+
+// missingStatus is the doctor status of an optional extension that is not
+// available: a failure when it is expected and database.strict_extensions is set.
+func (r *Reader) missingStatus(ext string) CheckStatus {
+	if r.cfg.StrictExtensions && slices.Contains(r.missingExtensions, ext) {
+		return CheckFail
+	}
+	return CheckWarn
+}
+
+// findKCacheTables returns the schema-qualified PoWA 4 kcache history tables,
+// shortest name first.
+func (r *Reader) findKCacheTables(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT schemaname, tablename
+		FROM pg_tables
+		WHERE schemaname IN ('public', 'powa')
+		AND tablename LIKE 'powa_%kcache%history'
+		ORDER BY length(tablename) ASC, schemaname, tablename
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var schemaName, tableName string
+		if err := rows.Scan(&schemaName, &tableName); err != nil {
+			return nil, err
+		}
+		tables = append(tables, schemaName+"."+tableName)
+	}
+	return tables, rows.Err()
+}
+
+// KCacheTable returns the kcache history table used for CPU/IO enrichment, or
+// "" when pg_stat_kcache is not available.
+func (r *Reader) KCacheTable() string {
+	r.kcacheMu.Lock()
+	defer r.kcacheMu.Unlock()
+	return r.kcacheTable
+}
+
+// selectKCacheTable returns the kcache history table to enrich the window from.
+// With several candidates it probes each in order and picks the first with rows
+// in the window, so CPU/IO is not silently read from an empty table; when none
+// has data it falls back to the shortest name. The choice is kept per window,
+// so fetching the same window again does not probe again.
+func (r *Reader) selectKCacheTable(ctx context.Context, startTime, endTime time.Time) string {
+	window := kcacheWindow{startTime.UnixNano(), endTime.UnixNano()}
+	r.kcacheMu.Lock()
+	table, cached := r.kcacheChoices[window]
+	if len(r.kcacheCandidates) == 0 {
+		table, cached = r.kcacheTable, true
+	}
+	r.kcacheMu.Unlock()
+	if cached {
+		return table
+	}
+
+	// Probe without the lock: the current and baseline windows are enriched
+	// concurrently and must not wait on each other's probes
+	chosen, reason := r.kcacheCandidates[0], "no candidate has data in the window"
+	for _, table := range r.kcacheCandidates {
+		var hasRows bool
+		err := r.db.QueryRowContext(ctx, fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1 FROM %s k
+				CROSS JOIN LATERAL unnest(k.records) AS r
+				WHERE %s(r).ts >= $1 AND (r).ts <= $2
+			)
+		`, table, r.kcacheRangeFilter()), startTime, endTime).Scan(&hasRows)
+		if err != nil {
+			log.Printf("Warning: probing kcache table %s: %v", table, err)
+			continue
+		}
+		if hasRows {
+			chosen, reason = table, "it has data in the window"
+			break
+		}
+	}
+
+	r.kcacheMu.Lock()
+	defer r.kcacheMu.Unlock()
+	if r.kcacheChoices == nil || len(r.kcacheChoices) >= maxKCacheChoices {
+		r.kcacheChoices = make(map[kcacheWindow]string)
+	}
+	r.kcacheChoices[window] = chosen
+	if chosen != r.kcacheTable {
+		log.Printf("Switching kcache table from %s to %s: %s", r.kcacheTable, chosen, reason)
+		r.kcacheTable = chosen
+	}
+	return chosen
+}
+
 // HasKCache returns whether pg_stat_kcache is available.
 func (r *Reader) HasKCache() bool {
 	return r.hasKCache
@@ -178,6 +413,11 @@ func (r *Reader) HasQualStats() bool {
 	return r.hasQualStats
 }
 
+// HasWaitSampling returns whether pg_wait_sampling history is available.
+func (r *Reader) HasWaitSampling() bool {
+	return r.hasWaits
+}
+
 // getExecTimeColumn returns the correct column name for execution time based on PostgreSQL version.
 // PostgreSQL 13+ uses "total_exec_time", earlier versions use "total_time".
 func (r *Reader) getExecTimeColumn() string {
@@ -194,6 +434,12 @@ func (r *Reader) isPoWA4() bool {
 
 // GetCurrentMetrics fetches performance metrics for the specified time window.
 func (r *Reader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	if err := r.checkExtensions(ctx); err != nil {
 		return nil, err
 	}
@@ -206,6 +452,12 @@ func (r *Reader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([
 
 // GetBaselineMetrics fetches baseline metrics for comparison.
 func (r *Reader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	if err := r.checkExtensions(ctx); err != nil {
 		return nil, err
 	}
@@ -216,16 +468,123 @@ func (r *Reader) GetBaselineMetrics(ctx context.Context, offset, window time.Dur
 	return r.getMetrics(ctx, startTime, endTime)
 }
 
+// GetBaselineMetricsFor fetches baseline metrics of the given queries only, so
+// every current query finds its baseline even when it would rank beyond the
+// database.max_query_rows limit of the whole baseline window. No queryids fetch nothing.
+func (r *Reader) GetBaselineMetricsFor(ctx context.Context, offset, window time.Duration, queryIDs []int64) ([]model.MetricSnapshot, error) {
+	if len(queryIDs) == 0 {
+		return nil, nil
+	}
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return nil, err
+	}
+
+	endTime := time.Now().Add(-offset)
+	startTime := endTime.Add(-window)
+
+	return r.getMetricsFor(ctx, startTime, endTime, queryIDs)
+}
+
+// GetMetricsRange fetches metrics for an explicit time range.
+func (r *Reader) GetMetricsRange(ctx context.Context, start, end time.Time) ([]model.MetricSnapshot, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.getMetrics(ctx, start, end)
+}
+
+// LatestSnapshotTime returns the timestamp of the newest statement snapshot, or
+// the zero time when the history is empty.
+func (r *Reader) LatestSnapshotTime(ctx context.Context) (time.Time, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	query := `SELECT max(ts) FROM powa_statements_history`
+	if r.isPoWA4() {
+		// Recent snapshots stay in the _current table until they are coalesced
+		from, ts := r.statementsHistory("upper")
+		query = fmt.Sprintf(`
+			SELECT GREATEST(
+				(SELECT max(%s) FROM %s),
+				(SELECT max((record).ts) FROM powa_statements_history_current)
+			)`, ts, from)
+	}
+
+	var latest sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query).Scan(&latest); err != nil {
+		return time.Time{}, fmt.Errorf("querying latest snapshot: %w", err)
+	}
+	if !latest.Valid {
+		return time.Time{}, nil
+	}
+	return latest.Time, nil
+}
+
 // getMetrics fetches metrics for a specific time range.
 //
 // PoWA history tables store cumulative counters (calls, total_exec_time, etc.). For a time window,
 // we must compute the delta (last − first) per (queryid, …), not SUM of rows. getMetrics and
 // enrichWithKCache both use this first/last aggregation pattern; see powa-schema.md for schema notes.
 func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) ([]model.MetricSnapshot, error) {
+	return r.getMetricsFor(ctx, startTime, endTime, nil)
+}
+
+// getMetricsFor is getMetrics restricted to queryIDs; nil fetches all queries.
+func (r *Reader) getMetricsFor(ctx context.Context, startTime, endTime time.Time, queryIDs []int64) ([]model.MetricSnapshot, error) {
 	// Use LIMIT to prevent unbounded result sets
 	var query string
 
+	// Filter in SQL so LIMIT applies to the selected database's top queries
+	args := []interface{}{startTime, endTime}
+	dbFilter := ""
+	if r.database != "" {
+		dbFilter = "WHERE pd.datname = $3"
+		args = append(args, r.database)
+	}
+	// Restrict the history scan itself, before the first/last aggregation
+	queryFilter := ""
+	if queryIDs != nil {
+		args = append(args, pq.Array(queryIDs))
+		queryFilter = fmt.Sprintf("AND ps.queryid = ANY($%d)", len(args))
+	}
+
+	// The stddev is kept since the last pg_stat_statements reset, so it is not
+	// a delta: take the last one in the window
+	var stddevRecord, stddevLast, stddevSelect string
+	if r.stddevCol != "" {
+		stddevSelect = ",\n\t\t\t\tfl.stddev_time"
+	}
+
 	if r.isPoWA4() {
+		// Prune coalesced rows outside the window when PoWA records their range
+		var rangeFilter string
+		if r.hasCoalesceRange {
+			rangeFilter = "ps.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]')\n\t\t\t\t\tAND "
+		}
+		if r.stddevCol != "" {
+			stddevRecord = fmt.Sprintf(",\n\t\t\t\t\t(r).%s AS stddev_time", r.stddevCol)
+			stddevLast = ",\n\t\t\t\t\t(array_agg(stddev_time ORDER BY ts DESC))[1] AS stddev_time"
+		}
 		// PoWA 4 uses a nested "records" array; each record holds cumulative stats at that ts.
 		// We must use delta (last - first) in the window, not SUM, to get calls/total_time for the period.
 		query = fmt.Sprintf(`
@@ -233,11 +592,11 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 				SELECT ps.queryid, ps.srvid, ps.dbid, ps.userid,
 					(r).ts AS ts,
 					(r).calls AS calls,
-					(r).total_exec_time AS total_exec_time
+					(r).total_exec_time AS total_exec_time%s
 				FROM powa_statements_history ps
 				CROSS JOIN LATERAL unnest(ps.records) AS r
-				WHERE ps.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]')
-					AND (r).ts >= $1 AND (r).ts <= $2
+				WHERE %s(r).ts >= $1 AND (r).ts <= $2
+					%s
 			),
 			first_last AS (
 				SELECT
@@ -246,7 +605,7 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 					(array_agg(total_exec_time ORDER BY ts))[1] AS first_time,
 					(array_agg(calls ORDER BY ts DESC))[1] AS last_calls,
 					(array_agg(total_exec_time ORDER BY ts DESC))[1] AS last_time,
-					MAX(ts) AS ts
+					MAX(ts) AS ts%s
 				FROM u
 				GROUP BY queryid, srvid, dbid, userid
 			)
@@ -261,18 +620,26 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 					THEN (fl.last_time - fl.first_time) / NULLIF(fl.last_calls - fl.first_calls, 0)
 					ELSE 0 END AS mean_time,
 				COALESCE(GREATEST(fl.last_calls - fl.first_calls, 0), 0)::bigint AS calls,
-				fl.ts
+				fl.ts,
+				fl.userid,
+				rol.rolname%s
 			FROM first_last fl
 			JOIN powa_databases pd ON fl.srvid = pd.srvid AND fl.dbid = pd.oid
 			JOIN powa_statements s ON fl.srvid = s.srvid AND fl.queryid = s.queryid AND fl.dbid = s.dbid AND fl.userid = s.userid
 			JOIN powa_servers srv ON fl.srvid = srv.id
+			-- Role oids of remote servers mean nothing in the repository's pg_roles
+			LEFT JOIN pg_roles rol ON fl.srvid = 0 AND rol.oid = fl.userid
+			%s
 			ORDER BY total_time DESC
 			LIMIT %d
-		`, MaxQueryRows)
+		`, stddevRecord, rangeFilter, queryFilter, stddevLast, stddevSelect, dbFilter, r.rowLimit())
 	} else {
 		// PoWA 3 uses flat columns; each row is a cumulative snapshot at ps.ts.
 		// Use delta (last - first) in the window, not SUM, to get calls/total_time for the period.
 		execTimeCol := r.getExecTimeColumn()
+		if r.stddevCol != "" {
+			stddevLast = fmt.Sprintf(",\n\t\t\t\t\t(array_agg(ps.%s ORDER BY ps.ts DESC))[1] AS stddev_time", r.stddevCol)
+		}
 		query = fmt.Sprintf(`
 			WITH first_last AS (
 				SELECT
@@ -281,9 +648,10 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 					(array_agg(ps.%s ORDER BY ps.ts))[1] AS first_time,
 					(array_agg(ps.calls ORDER BY ps.ts DESC))[1] AS last_calls,
 					(array_agg(ps.%s ORDER BY ps.ts DESC))[1] AS last_time,
-					MAX(ps.ts) AS ts
+					MAX(ps.ts) AS ts%s
 				FROM powa_statements_history ps
 				WHERE ps.ts >= $1 AND ps.ts <= $2
+					%s
 				GROUP BY ps.queryid, ps.dbid, ps.userid
 			)
 			SELECT
@@ -297,27 +665,29 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 					THEN (fl.last_time - fl.first_time) / NULLIF(fl.last_calls - fl.first_calls, 0)
 					ELSE 0 END AS mean_time,
 				COALESCE(GREATEST(fl.last_calls - fl.first_calls, 0), 0)::bigint AS calls,
-				fl.ts
+				fl.ts,
+				fl.userid,
+				rol.rolname%s
 			FROM first_last fl
 			JOIN powa_databases pd ON fl.dbid = pd.oid
 			JOIN powa_statements s ON fl.queryid = s.queryid AND fl.dbid = s.dbid AND fl.userid = s.userid
+			LEFT JOIN pg_roles rol ON rol.oid = fl.userid
+			%s
 			ORDER BY total_time DESC
 			LIMIT %d
-		`, execTimeCol, execTimeCol, MaxQueryRows)
+		`, execTimeCol, execTimeCol, stddevLast, queryFilter, stddevSelect, dbFilter, r.rowLimit())
 	}
 
-	rows, err := r.db.QueryContext(ctx, query, startTime, endTime)
-	if err != nil {
-		return nil, fmt.Errorf("querying powa_statements_history: %w", err)
-	}
-	defer rows.Close()
-
 	var snapshots []model.MetricSnapshot
-	for rows.Next() {
+	var read, skipped int
+	err := r.eachRow(ctx, query, args, func(rows *sql.Rows) error {
+		read++
 		var m model.MetricSnapshot
-		if err := rows.Scan(
+		var queryText, userName sql.NullString
+		var stddev sql.NullFloat64
+		dest := []any{
 			&m.QueryID,
-			&m.Query,
+			&queryText,
 			&m.DatabaseName,
 			&m.ServerName,
 			&m.SrvID,
@@ -325,18 +695,38 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 			&m.MeanTime,
 			&m.Calls,
 			&m.Timestamp,
-		); err != nil {
-			return nil, fmt.Errorf("scanning metrics row: %w", err)
+			&m.UserID,
+			&userName,
+		}
+		if r.stddevCol != "" {
+			dest = append(dest, &stddev)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("scanning metrics row: %w", err)
+		}
+		m.UserName = userName.String
+		m.StddevTime, m.HasStddev = stddev.Float64, stddev.Valid
+		if m.Query = queryText.String; strings.TrimSpace(m.Query) == "" {
+			if !r.keepEmpty {
+				skipped++
+				return nil
+			}
+			m.Query = model.QueryTextUnavailable(m.QueryID)
 		}
+		m.TruncateQuery(r.maxQueryLen)
 		snapshots = append(snapshots, m)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying powa_statements_history: %w", err)
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating metrics rows: %w", err)
+	r.noteRowLimit("powa_statements_history", read)
+	if skipped > 0 {
+		log.Printf("Skipped %d statement(s) without query text (set analysis.skip_empty_queries: false to keep them)", skipped)
 	}
 
 	// If pg_stat_kcache is available, enrich with CPU/IO data
-	if r.hasKCache && len(snapshots) > 0 {
+	if r.hasKCache && !r.skipKCache && len(snapshots) > 0 {
 		if err := r.enrichWithKCache(ctx, snapshots, startTime, endTime); err != nil {
 			// Log warning but don't fail - kcache data is optional
 			log.Printf("Warning: failed to enrich with kcache data: %v", err)
@@ -349,9 +739,10 @@ func (r *Reader) getMetrics(ctx context.Context, startTime, endTime time.Time) (
 // enrichWithKCache adds pg_stat_kcache metrics to the snapshots.
 // Kcache history stores cumulative counters; we use delta (last - first) in the window, not SUM.
 func (r *Reader) enrichWithKCache(ctx context.Context, snapshots []model.MetricSnapshot, startTime, endTime time.Time) error {
+	table := r.selectKCacheTable(ctx, startTime, endTime)
 	var query string
 	if r.isPoWA4() {
-		// PoWA 4: first/last delta per (queryid, srvid)
+		// PoWA 4: first/last delta per (queryid, srvid), pruned by coalesce_range when present
 		query = fmt.Sprintf(`
 			WITH u AS (
 				SELECT k.queryid, k.srvid,
@@ -362,8 +753,7 @@ func (r *Reader) enrichWithKCache(ctx context.Context, snapshots []model.MetricS
 					(r).exec_system_time AS exec_system_time
 				FROM %s k
 				CROSS JOIN LATERAL unnest(k.records) AS r
-				WHERE k.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]')
-					AND (r).ts >= $1 AND (r).ts <= $2
+				WHERE %s(r).ts >= $1 AND (r).ts <= $2
 			),
 			first_last AS (
 				SELECT
@@ -387,7 +777,7 @@ func (r *Reader) enrichWithKCache(ctx context.Context, snapshots []model.MetricS
 				COALESCE(GREATEST(last_user_time - first_user_time, 0), 0) AS user_cpu_time,
 				COALESCE(GREATEST(last_system_time - first_system_time, 0), 0) AS system_cpu_time
 			FROM first_last
-		`, r.kcacheTable)
+		`, table, r.kcacheRangeFilter())
 	} else {
 		// PoWA 3: first/last delta per queryid
 		query = fmt.Sprintf(`
@@ -414,7 +804,7 @@ func (r *Reader) enrichWithKCache(ctx context.Context, snapshots []model.MetricS
 				COALESCE(GREATEST(last_user_time - first_user_time, 0), 0) AS user_cpu_time,
 				COALESCE(GREATEST(last_system_time - first_system_time, 0), 0) AS system_cpu_time
 			FROM first_last
-		`, r.kcacheTable)
+		`, table)
 	}
 
 	rows, err := r.db.QueryContext(ctx, query, startTime, endTime)
@@ -472,6 +862,17 @@ func isViewNotExistError(err error) bool {
 	return false
 }
 
+// isUndefinedColumnError checks if the error is due to a missing column, e.g. a
+// PoWA record type from a different version.
+func isUndefinedColumnError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 42703 = undefined_column
+		return pqErr.Code == "42703"
+	}
+	return false
+}
+
 // isPermissionError checks if the error is due to permission denied.
 func isPermissionError(err error) bool {
 	var pqErr *pq.Error
@@ -484,6 +885,12 @@ func isPermissionError(err error) bool {
 
 // GetIndexSuggestions fetches missing index suggestions from pg_qualstats.
 func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	if err := r.checkExtensions(ctx); err != nil {
 		return nil, err
 	}
@@ -492,9 +899,21 @@ func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggesti
 		return nil, nil // No suggestions available without pg_qualstats
 	}
 
+	// Filter schemas in SQL so LIMIT applies to the schemas we report on
+	args := []interface{}{r.minGain}
+	schemaFilter := ""
+	if len(r.includeSchemas) > 0 {
+		args = append(args, pq.Array(r.includeSchemas))
+		schemaFilter += fmt.Sprintf("\n\t\t\tAND nspname = ANY($%d)", len(args))
+	}
+	if len(r.excludeSchemas) > 0 {
+		args = append(args, pq.Array(r.excludeSchemas))
+		schemaFilter += fmt.Sprintf("\n\t\t\tAND NOT nspname = ANY($%d)", len(args))
+	}
+
 	// Query the powa_qualstats view for index suggestions
 	// This is a simplified query; actual implementation may vary based on PoWA version
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			relname as table_name,
 			nspname as schema_name,
@@ -504,12 +923,13 @@ func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggesti
 			count(*) as affected_queries
 		FROM powa_qualstats_indexes
 		WHERE suggestion IS NOT NULL
+			AND avg_filter >= $1%s
 		GROUP BY relname, nspname, qualtype, avg_filter
 		ORDER BY est_improvement_percent DESC
 		LIMIT 100
-	`
+	`, schemaFilter)
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		// Handle expected errors gracefully
 		if isViewNotExistError(err) {
@@ -528,6 +948,7 @@ func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggesti
 
 	var suggestions []model.IndexSuggestion
 	var scanErrors int
+	var firstScanErr error
 	for rows.Next() {
 		var s model.IndexSuggestion
 		var columns []string
@@ -541,6 +962,9 @@ func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggesti
 		)
 		if err != nil {
 			scanErrors++
+			if firstScanErr == nil {
+				firstScanErr = err
+			}
 			if scanErrors <= 3 {
 				log.Printf("Warning: failed to scan index suggestion row: %v", err)
 			}
@@ -554,15 +978,116 @@ func (r *Reader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggesti
 	if scanErrors > 3 {
 		log.Printf("Warning: %d total rows failed to scan in GetIndexSuggestions", scanErrors)
 	}
+	rows.Close()
+
+	if total := scanErrors + len(suggestions); r.maxScanErrs > 0 && float64(scanErrors) > r.maxScanErrs*float64(total) {
+		return nil, fmt.Errorf("%d of %d powa_qualstats_indexes rows failed to scan, more than rules.index_suggestion.max_scan_error_ratio %g "+
+			"(the view may not match this PoWA version): %w", scanErrors, total, r.maxScanErrs, firstScanErr)
+	}
 
+	if len(suggestions) > 0 {
+		r.attachIndexExamples(ctx, suggestions, args, schemaFilter)
+	}
 	return suggestions, nil
 }
 
-// GetDatabaseList returns the list of databases in the PoWA repository.
-func (r *Reader) GetDatabaseList(ctx context.Context) ([]string, error) {
+// attachIndexExamples adds the constants and selectivity pg_qualstats saw for
+// each suggested column. They are only there when pg_qualstats tracks
+// constants and the PoWA version exposes them, so a missing view, column or
+// privilege just leaves the suggestions without examples.
+func (r *Reader) attachIndexExamples(ctx context.Context, suggestions []model.IndexSuggestion, args []interface{}, schemaFilter string) {
+	query := fmt.Sprintf(`
+		SELECT
+			relname as table_name,
+			nspname as schema_name,
+			attname as column_name,
+			(array_agg(DISTINCT constvalue ORDER BY constvalue))[1:%d] as example_values,
+			coalesce(avg(1 - filter_ratio), 0) as selectivity
+		FROM powa_qualstats_indexes, unnest(constvalues) AS constvalue
+		WHERE suggestion IS NOT NULL
+			AND avg_filter >= $1%s
+		GROUP BY relname, nspname, attname
+	`, maxExampleValues, schemaFilter)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if !isViewNotExistError(err) && !isUndefinedColumnError(err) && !isPermissionError(err) {
+			log.Printf("Warning: failed to query index suggestion examples: %v", err)
+		}
+		return
+	}
+	defer rows.Close()
+
+	examples := make(map[string]model.ColumnExample)
+	for rows.Next() {
+		var schema, table string
+		var ex model.ColumnExample
+		if err := rows.Scan(&table, &schema, &ex.Column, pq.Array(&ex.Values), &ex.Selectivity); err != nil {
+			log.Printf("Warning: failed to scan index suggestion example row: %v", err)
+			continue
+		}
+		examples[schema+"."+table+"."+ex.Column] = ex
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: failed to read index suggestion examples: %v", err)
+		return
+	}
+
+	for i := range suggestions {
+		s := &suggestions[i]
+		for _, col := range s.Columns {
+			if ex, ok := examples[s.Schema+"."+s.Table+"."+col]; ok {
+				s.Examples = append(s.Examples, ex)
+			}
+		}
+	}
+}
+
+// GetDatabaseList returns the databases in the PoWA repository. PoWA keeps a
+// database after it is dropped, so with since > 0 only databases with statement
+// snapshots in the last since are returned, most recently seen first; otherwise
+// every database is returned by name. A positive limit caps the list.
+func (r *Reader) GetDatabaseList(ctx context.Context, since time.Duration, limit int) ([]string, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	query := `SELECT DISTINCT datname FROM powa_databases ORDER BY datname`
+	var args []interface{}
+	if since > 0 {
+		if err := r.checkExtensions(ctx); err != nil {
+			return nil, err
+		}
+		seen := `
+				SELECT dbid, ts FROM powa_statements_history WHERE ts >= $1`
+		join := `seen.dbid = pd.oid`
+		if r.isPoWA4() {
+			// Recent snapshots stay in the _current table until they are coalesced
+			from, ts := r.statementsHistory("upper")
+			seen = fmt.Sprintf(`
+				SELECT srvid, dbid, %s AS ts FROM %s
+				WHERE %s >= $1
+				UNION ALL
+				SELECT srvid, dbid, (record).ts FROM powa_statements_history_current
+				WHERE (record).ts >= $1`, ts, from, ts)
+			join = `seen.srvid = pd.srvid AND seen.dbid = pd.oid`
+		}
+		query = fmt.Sprintf(`
+			SELECT pd.datname
+			FROM powa_databases pd
+			JOIN (%s
+			) seen ON %s
+			GROUP BY pd.datname
+			ORDER BY max(seen.ts) DESC, pd.datname`, seen, join)
+		args = append(args, time.Now().Add(-since))
+	}
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("querying powa_databases: %w", err)
 	}
@@ -576,6 +1101,9 @@ func (r *Reader) GetDatabaseList(ctx context.Context) ([]string, error) {
 		}
 		databases = append(databases, name)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading database names: %w", err)
+	}
 
 	return databases, nil
 }
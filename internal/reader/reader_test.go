@@ -1,12 +1,27 @@
 package reader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/retry"
 )
 
 func TestReader_checkExtensions(t *testing.T) {
@@ -36,6 +51,8 @@ func TestReader_checkExtensions(t *testing.T) {
 	// Expect check for pg_qualstats
 	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	// Expect KCache table search (PoWA 3.2.0 is detected, but logic runs if hasKCache is true.
 	// Wait, isPoWA4() returns false for 3.2.0. So table search is SKIPPED.
@@ -84,6 +101,10 @@ func TestReader_checkExtensions_PoWA4_KcacheInPowaSchema(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*coalesce_range").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
 	// PoWA 4 + kcache: search pg_tables for kcache history in public/powa
 	mock.ExpectQuery("SELECT schemaname, tablename").
@@ -106,6 +127,101 @@ func TestReader_checkExtensions_PoWA4_KcacheInPowaSchema(t *testing.T) {
 	}
 }
 
+func TestReader_checkExtensions_PoWA4_MultipleKcacheTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{
+		db:  db,
+		cfg: &config.DatabaseConfig{},
+	}
+
+	mock.ExpectQuery("SHOW server_version_num").
+		WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140000"))
+	mock.ExpectQuery("SELECT extversion FROM pg_extension WHERE extname = 'powa'").
+		WillReturnRows(sqlmock.NewRows([]string{"extversion"}).AddRow("4.2.2"))
+	mock.ExpectQuery("SELECT EXISTS.*pg_stat_kcache").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*coalesce_range").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT schemaname, tablename").
+		WillReturnRows(sqlmock.NewRows([]string{"schemaname", "tablename"}).
+			AddRow("powa", "powa_kcache_history").
+			AddRow("public", "powa_kcache_metrics_history"))
+
+	if err := r.checkExtensions(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The shortest name is the default until a window is probed
+	if got := r.KCacheTable(); got != "powa.powa_kcache_history" {
+		t.Errorf("KCacheTable() = %q, want %q", got, "powa.powa_kcache_history")
+	}
+	if len(r.kcacheCandidates) != 2 {
+		t.Errorf("kcacheCandidates = %v, want both tables", r.kcacheCandidates)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_selectKCacheTable(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	candidates := []string{"powa.powa_kcache_history", "public.powa_kcache_metrics_history"}
+
+	tests := []struct {
+		name          string
+		coalesceRange bool
+		hasRows       []bool // probe result per candidate, in order; probing stops at the first with data
+		want          string
+	}{
+		{"shortest has data", true, []bool{true}, "powa.powa_kcache_history"},
+		{"only the longer name has data", true, []bool{false, true}, "public.powa_kcache_metrics_history"},
+		{"none has data", true, []bool{false, false}, "powa.powa_kcache_history"},
+		{"without coalesce_range", false, []bool{false, true}, "public.powa_kcache_metrics_history"},
+	}
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		r := &Reader{db: db, cfg: &config.DatabaseConfig{}, kcacheTable: candidates[0], kcacheCandidates: candidates,
+			hasCoalesceRange: tt.coalesceRange}
+
+		filter := `WHERE \(r\)\.ts >= \$1`
+		if tt.coalesceRange {
+			filter = `WHERE k\.coalesce_range && tstzrange`
+		}
+		for i, has := range tt.hasRows {
+			mock.ExpectQuery(`(?s)SELECT EXISTS.*FROM `+candidates[i]+` k\s.*`+filter).
+				WithArgs(start, end).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(has))
+		}
+
+		// The second call for the same window reuses the choice without probing
+		for range 2 {
+			if got := r.selectKCacheTable(context.Background(), start, end); got != tt.want {
+				t.Errorf("%s: selectKCacheTable() = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+		if got := r.KCacheTable(); got != tt.want {
+			t.Errorf("%s: KCacheTable() = %q, want %q", tt.name, got, tt.want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", tt.name, err)
+		}
+		db.Close()
+	}
+}
+
 func TestReader_checkExtensions_ExpectedExtensionsMismatch(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -126,6 +242,8 @@ func TestReader_checkExtensions_ExpectedExtensionsMismatch(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	if err := r.checkExtensions(context.Background()); err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -139,6 +257,60 @@ func TestReader_checkExtensions_ExpectedExtensionsMismatch(t *testing.T) {
 	}
 }
 
+func TestReader_CheckExpectedExtensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		strict     bool
+		wantErr    bool
+		wantStatus CheckStatus
+	}{
+		{"missing extension warns", false, false, CheckWarn},
+		{"missing extension fails when strict", true, true, CheckFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{
+				db:  db,
+				cfg: &config.DatabaseConfig{ExpectedExtensions: []string{"pg_stat_kcache", "pg_qualstats"}, StrictExtensions: tt.strict},
+			}
+			mock.ExpectQuery("SHOW server_version_num").
+				WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140000"))
+			mock.ExpectQuery("SELECT extversion FROM pg_extension WHERE extname = 'powa'").
+				WillReturnRows(sqlmock.NewRows([]string{"extversion"}).AddRow("3.2.0"))
+			mock.ExpectQuery("SELECT EXISTS.*pg_stat_kcache").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			err = r.CheckExpectedExtensions(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckExpectedExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && (!strings.Contains(err.Error(), "pg_qualstats") || strings.Contains(err.Error(), "pg_stat_kcache")) {
+				t.Errorf("error = %q, want only pg_qualstats reported missing", err)
+			}
+			// The doctor reports the missing extension with the same strictness
+			if got := r.missingStatus("pg_qualstats"); got != tt.wantStatus {
+				t.Errorf("missingStatus(pg_qualstats) = %s, want %s", got, tt.wantStatus)
+			}
+			if got := r.missingStatus("pg_wait_sampling"); got != CheckWarn {
+				t.Errorf("missingStatus(pg_wait_sampling) = %s, want %s for an extension that is not expected", got, CheckWarn)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
 func TestReader_GetMetrics(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -160,8 +332,8 @@ func TestReader_GetMetrics(t *testing.T) {
 	// Note: We use a regex for the query matching because whitespace/formatting might vary
 	mock.ExpectQuery("SELECT.*powa_statements_history").
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts"}).
-			AddRow(1001, "SELECT 1", "postgres", "local", 0, 100.0, 10.0, 10, now))
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(1001, "SELECT 1", "postgres", "local", 0, 100.0, 10.0, 10, now, 10, nil))
 
 	// Expect kcache enrichment query (since hasKCache=true)
 	mock.ExpectQuery("SELECT.*powa_kcache_metrics_history").
@@ -194,6 +366,222 @@ func TestReader_GetMetrics(t *testing.T) {
 	}
 }
 
+func TestReader_GetMetrics_KCacheEnrichment(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{
+				db:          db,
+				cfg:         &config.DatabaseConfig{},
+				hasKCache:   true,
+				kcacheTable: "powa_kcache_metrics_history",
+			}
+			r.SetKCacheEnrichment(enabled)
+
+			now := time.Now()
+			mock.ExpectQuery("SELECT.*powa_statements_history").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+					AddRow(1001, "SELECT 1", "postgres", "local", 0, 100.0, 10.0, 10, now, 10, nil))
+			mock.ExpectQuery("SELECT.*powa_kcache_metrics_history").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "srvid", "reads", "writes", "user_time", "sys_time"}).
+					AddRow(1001, 0, 50, 10, 5.0, 1.0))
+
+			metrics, err := r.getMetrics(context.Background(), now.Add(-1*time.Hour), now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].HasKCacheData != enabled {
+				t.Errorf("metrics = %+v, want one snapshot with HasKCacheData %v", metrics, enabled)
+			}
+			// Without enrichment the kcache query must never run
+			if err := mock.ExpectationsWereMet(); (err == nil) != enabled {
+				t.Errorf("kcache query ran = %v, want %v", err == nil, enabled)
+			}
+		})
+	}
+}
+
+func TestReader_GetQueryFirstSeen(t *testing.T) {
+	seen := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		powaVersion   string
+		coalesceRange bool
+		query         string
+	}{
+		{"PoWA3", "3.2.0", false, `(?s)SELECT queryid, ts FROM powa_statements_history\).*queryid = ANY\(\$1\).*HAVING min\(ts\) >`},
+		{"PoWA4", "4.2.2", true, `(?s)lower\(coalesce_range\).*powa_statements_history_current.*HAVING min\(ts\) >`},
+		{"PoWA4 without coalesce_range", "4.2.2", false, `(?s)SELECT queryid, \(rec\)\.ts AS ts FROM powa_statements_history CROSS JOIN LATERAL unnest\(records\) AS rec.*powa_statements_history_current`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: tt.powaVersion, hasCoalesceRange: tt.coalesceRange}
+			r.extensionsOnce.Do(func() {}) // extensions already detected
+
+			mock.ExpectQuery(tt.query).
+				WithArgs(pq.Array([]int64{7, 42})).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "min"}).AddRow(42, seen))
+
+			got, err := r.GetQueryFirstSeen(context.Background(), []int64{7, 42})
+			if err != nil {
+				t.Fatalf("GetQueryFirstSeen() error = %v", err)
+			}
+			if len(got) != 1 || !got[42].Equal(seen) {
+				t.Errorf("GetQueryFirstSeen() = %v, want only queryid 42 first seen at %v", got, seen)
+			}
+
+			// Without queryids there is nothing to look up
+			if got, err := r.GetQueryFirstSeen(context.Background(), nil); err != nil || got != nil {
+				t.Errorf("GetQueryFirstSeen(nil) = %v, %v; want no query", got, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_GetBaselineMetricsFor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, powaVersion: "4.2.0", database: "app"}
+	r.extensionsOnce.Do(func() {}) // extensions already detected
+
+	now := time.Now()
+	mock.ExpectQuery(`(?s)FROM powa_statements_history ps.*AND ps\.queryid = ANY\(\$4\).*WHERE pd\.datname = \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "app", pq.Array([]int64{7, 42})).
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(42, "SELECT 1", "app", "main", 1, 100.0, 10.0, 10, now, 10, nil))
+
+	metrics, err := r.GetBaselineMetricsFor(context.Background(), 24*time.Hour, time.Hour, []int64{7, 42})
+	if err != nil {
+		t.Fatalf("GetBaselineMetricsFor() error = %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].QueryID != 42 {
+		t.Errorf("metrics = %+v, want queryid 42", metrics)
+	}
+
+	// Without queryids there is nothing to fetch
+	if metrics, err := r.GetBaselineMetricsFor(context.Background(), 24*time.Hour, time.Hour, nil); err != nil || metrics != nil {
+		t.Errorf("GetBaselineMetricsFor(nil) = %v, %v; want no query", metrics, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetMetrics_MaxQueryLength(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+	r.SetMaxQueryLength(20)
+
+	now := time.Now()
+	long := "SELECT id, name FROM users WHERE id IN ($1, $2, $3, $4)"
+	other := "SELECT id, name FROM users WHERE id IN ($1)"
+	mock.ExpectQuery("SELECT.*powa_statements_history").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(1, long, "app", "local", 0, 100.0, 10.0, 10, now, 10, nil).
+			AddRow(2, other, "app", "local", 0, 50.0, 5.0, 10, now, 10, nil).
+			AddRow(3, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, now, 10, nil))
+
+	metrics, err := r.getMetrics(context.Background(), now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("expected 3 metrics, got %d", len(metrics))
+	}
+
+	want := "SELECT id, name FROM" + model.TruncatedQueryMarker
+	if metrics[0].Query != want || metrics[1].Query != want {
+		t.Errorf("truncated queries = %q, %q; want %q", metrics[0].Query, metrics[1].Query, want)
+	}
+	// The hash covers the full text, so queries sharing the kept prefix still differ
+	sum := sha256.Sum256([]byte(long))
+	if metrics[0].QueryHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("QueryHash = %s, want the SHA-256 of the original text", metrics[0].QueryHash)
+	}
+	if metrics[0].QueryHash == metrics[1].QueryHash {
+		t.Error("expected different hashes for different original texts")
+	}
+	if metrics[2].Query != "SELECT 1" || metrics[2].QueryHash != "" {
+		t.Errorf("short query = %q (hash %q), want it untouched", metrics[2].Query, metrics[2].QueryHash)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetMetrics_EmptyQueryText(t *testing.T) {
+	tests := []struct {
+		name string
+		skip bool
+		want []string
+	}{
+		{"skipped by default", true, []string{"SELECT 1"}},
+		{"placeholder", false, []string{"SELECT 1", "<query text unavailable: queryid 2>", "<query text unavailable: queryid 3>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+			r.SetSkipEmptyQueries(tt.skip)
+
+			now := time.Now()
+			mock.ExpectQuery("SELECT.*powa_statements_history").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+					AddRow(1, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, now, 10, nil).
+					AddRow(2, nil, "app", "local", 0, 10.0, 1.0, 10, now, 10, nil).
+					AddRow(3, "  ", "app", "local", 0, 10.0, 1.0, 10, now, 10, nil))
+
+			metrics, err := r.getMetrics(context.Background(), now.Add(-time.Hour), now)
+			if err != nil {
+				t.Fatalf("a NULL query should scan: %v", err)
+			}
+			var got []string
+			for _, m := range metrics {
+				got = append(got, m.Query)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("queries = %q, want %q", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
 func TestReader_GetIndexSuggestions(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -205,6 +593,7 @@ func TestReader_GetIndexSuggestions(t *testing.T) {
 		db:  db,
 		cfg: &config.DatabaseConfig{},
 	}
+	r.SetMinImprovement(30)
 
 	// 1. Test when extensions check fails/not run yet
 	// Mock checkExtensions first call
@@ -217,9 +606,12 @@ func TestReader_GetIndexSuggestions(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-	// Mock suggestions query
-	mock.ExpectQuery("SELECT.*powa_qualstats_indexes").
+	// Mock suggestions query; the improvement floor is applied in SQL
+	mock.ExpectQuery(`SELECT.*powa_qualstats_indexes.*avg_filter >= \$1`).
+		WithArgs(30.0).
 		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "columns", "qualtype", "est_improvement", "affected_queries"}).
 			AddRow("users", "public", "{id,name}", "Index", 50.5, 10))
 
@@ -242,6 +634,160 @@ func TestReader_GetIndexSuggestions(t *testing.T) {
 	}
 }
 
+func TestReader_GetIndexSuggestions_ScanErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, hasQualStats: true}
+	r.extensionsOnce.Do(func() {}) // extensions already detected
+	r.SetMaxScanErrorRatio(0.5)
+	columns := []string{"table_name", "schema_name", "columns", "qualtype", "est_improvement", "affected_queries"}
+
+	// Two of three rows have a non-numeric improvement: the view does not match
+	mock.ExpectQuery(`powa_qualstats_indexes`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("users", "public", "{id}", "Index", 50.5, 10).
+			AddRow("orders", "public", "{id}", "Index", "n/a", 10).
+			AddRow("items", "public", "{id}", "Index", "n/a", 10))
+	if _, err := r.GetIndexSuggestions(context.Background()); err == nil || !strings.Contains(err.Error(), "2 of 3") {
+		t.Errorf("GetIndexSuggestions() error = %v, want one reporting 2 of 3 rows failing", err)
+	}
+
+	// One bad row in three stays under the ratio and is skipped
+	mock.ExpectQuery(`powa_qualstats_indexes`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("users", "public", "{id}", "Index", 50.5, 10).
+			AddRow("orders", "public", "{id}", "Index", "n/a", 10).
+			AddRow("items", "public", "{id}", "Index", 40.0, 10))
+	mock.ExpectQuery(`qualstats`).WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "column_name", "example_values", "selectivity"}))
+	suggestions, err := r.GetIndexSuggestions(context.Background())
+	if err != nil || len(suggestions) != 2 {
+		t.Errorf("GetIndexSuggestions() = %d suggestions, %v; want 2", len(suggestions), err)
+	}
+}
+
+func TestReader_GetIndexSuggestions_SchemaFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{
+		db:           db,
+		cfg:          &config.DatabaseConfig{},
+		hasQualStats: true,
+	}
+	r.extensionsOnce.Do(func() {})
+	r.SetMinImprovement(30)
+	r.SetSchemaFilter([]string{"public", "billing"}, []string{"audit"})
+
+	mock.ExpectQuery(`SELECT.*powa_qualstats_indexes.*avg_filter >= \$1\s+AND nspname = ANY\(\$2\)\s+AND NOT nspname = ANY\(\$3\)`).
+		WithArgs(30.0, `{"public","billing"}`, `{"audit"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "columns", "qualtype", "est_improvement", "affected_queries"}).
+			AddRow("invoices", "billing", "{customer_id}", "Index", 45.0, 4))
+	// The examples are read with the same filter
+	mock.ExpectQuery(`SELECT.*example_values.*avg_filter >= \$1\s+AND nspname = ANY\(\$2\)\s+AND NOT nspname = ANY\(\$3\)`).
+		WithArgs(30.0, `{"public","billing"}`, `{"audit"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "column_name", "example_values", "selectivity"}))
+
+	suggestions, err := r.GetIndexSuggestions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Schema != "billing" {
+		t.Errorf("suggestions = %+v, want the billing suggestion", suggestions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetIndexSuggestions_Examples(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{
+		db:           db,
+		cfg:          &config.DatabaseConfig{},
+		hasQualStats: true,
+	}
+	r.extensionsOnce.Do(func() {})
+	r.SetMinImprovement(30)
+
+	mock.ExpectQuery(`SELECT.*powa_qualstats_indexes.*avg_filter >= \$1`).
+		WithArgs(30.0).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "columns", "qualtype", "est_improvement", "affected_queries"}).
+			AddRow("orders", "public", "{status,created_at}", "Index", 60.0, 4))
+	mock.ExpectQuery(`SELECT.*example_values.*FROM powa_qualstats_indexes, unnest\(constvalues\).*avg_filter >= \$1`).
+		WithArgs(30.0).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "column_name", "example_values", "selectivity"}).
+			AddRow("orders", "public", "created_at", `{"'2026-01-01'"}`, 0.0).
+			AddRow("orders", "public", "status", `{"'new'","'open'"}`, 0.02).
+			AddRow("users", "public", "status", `{"'active'"}`, 0.5))
+
+	suggestions, err := r.GetIndexSuggestions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("expected 1 suggestion, got %d", len(suggestions))
+	}
+	examples := suggestions[0].Examples
+	if len(examples) != 2 {
+		t.Fatalf("examples = %+v, want one per suggested column", examples)
+	}
+	if examples[0].Column != "status" || strings.Join(examples[0].Values, ",") != "'new','open'" || examples[0].Selectivity != 0.02 {
+		t.Errorf("first example = %+v, want status values with their selectivity", examples[0])
+	}
+	if examples[1].Column != "created_at" || examples[1].Selectivity != 0 {
+		t.Errorf("second example = %+v, want created_at without selectivity", examples[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetIndexSuggestions_NoExamples(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{
+		db:           db,
+		cfg:          &config.DatabaseConfig{},
+		hasQualStats: true,
+	}
+	r.extensionsOnce.Do(func() {})
+	r.SetMinImprovement(30)
+
+	mock.ExpectQuery(`SELECT.*powa_qualstats_indexes.*avg_filter >= \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "schema_name", "columns", "qualtype", "est_improvement", "affected_queries"}).
+			AddRow("orders", "public", "{status}", "Index", 60.0, 4))
+	// Older PoWA versions do not expose the constants
+	mock.ExpectQuery(`SELECT.*example_values`).
+		WillReturnError(&pq.Error{Code: "42703", Message: `column "constvalues" does not exist`})
+
+	suggestions, err := r.GetIndexSuggestions(context.Background())
+	if err != nil {
+		t.Fatalf("a missing constants column should not fail suggestions: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Examples != nil {
+		t.Errorf("suggestions = %+v, want the suggestion without examples", suggestions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestReader_GetMetrics_PoWA4(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -264,8 +810,8 @@ func TestReader_GetMetrics_PoWA4(t *testing.T) {
 	// Use (?s) to enable dot-matches-newline for multi-line query matching
 	mock.ExpectQuery(`(?s)SELECT.*powa_statements_history.*unnest`).
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts"}).
-			AddRow(1001, "SELECT 1", "postgres", "server1", 1, 100.0, 10.0, 10, now))
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(1001, "SELECT 1", "postgres", "server1", 1, 100.0, 10.0, 10, now, 10, nil))
 
 	// Expect PoWA 4 style kcache query (using exec_ prefix and powa_kcache_history)
 	mock.ExpectQuery(`(?s)SELECT.*exec_reads.*powa_kcache_history`).
@@ -292,6 +838,51 @@ func TestReader_GetMetrics_PoWA4(t *testing.T) {
 	}
 }
 
+func TestReader_GetMetrics_PoWA4KCacheCoalesceRange(t *testing.T) {
+	tests := []struct {
+		name          string
+		coalesceRange bool
+		kcache        string
+	}{
+		{"with coalesce_range", true, `(?s)FROM powa_kcache_history k\s+CROSS JOIN LATERAL unnest\(k\.records\) AS r\s+WHERE k\.coalesce_range && tstzrange\(.*\)\s+AND \(r\)\.ts >= \$1`},
+		{"without coalesce_range", false, `(?s)FROM powa_kcache_history k\s+CROSS JOIN LATERAL unnest\(k\.records\) AS r\s+WHERE \(r\)\.ts >= \$1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, hasKCache: true, powaVersion: "4.2.2",
+				kcacheTable: "powa_kcache_history", hasCoalesceRange: tt.coalesceRange}
+			now := time.Now()
+
+			mock.ExpectQuery(`(?s)SELECT.*powa_statements_history.*unnest`).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+					AddRow(1001, "SELECT 1", "postgres", "server1", 1, 100.0, 10.0, 10, now, 10, nil))
+			mock.ExpectQuery(tt.kcache).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "srvid", "reads", "writes", "user_time", "sys_time"}).
+					AddRow(1001, 1, 50, 10, 5.0, 1.0))
+
+			metrics, err := r.getMetrics(context.Background(), now.Add(-time.Hour), now)
+			if err != nil {
+				t.Fatalf("getMetrics() error = %v", err)
+			}
+			if len(metrics) != 1 || !metrics[0].HasKCacheData {
+				t.Errorf("metrics = %+v, want one snapshot enriched from kcache", metrics)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
 // TestReader_GetMetrics_DeltaSemantics asserts that getMetrics returns delta values (last − first in the window),
 // not SUM. The query computes first/last per (queryid, ...) and returns calls = last_calls − first_calls,
 // total_time = last_time − first_time. This test mocks one row with delta-shaped values and asserts them.
@@ -313,8 +904,8 @@ func TestReader_GetMetrics_DeltaSemantics(t *testing.T) {
 	// Simulate first_calls=100, last_calls=150 → calls=50; first_time=1000, last_time=1050 → total_time=50
 	mock.ExpectQuery("SELECT.*powa_statements_history").
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts"}).
-			AddRow(1001, "SELECT 1", "postgres", "local", 0, 50.0, 1.0, 50, now))
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(1001, "SELECT 1", "postgres", "local", 0, 50.0, 1.0, 50, now, 10, nil))
 
 	metrics, err := r.getMetrics(context.Background(), now.Add(-1*time.Hour), now)
 	if err != nil {
@@ -337,3 +928,1139 @@ func TestReader_GetMetrics_DeltaSemantics(t *testing.T) {
 		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
+
+func TestFixtureReader(t *testing.T) {
+	r, err := NewFixtureReader("../../config/fixture.example.json")
+	if err != nil {
+		t.Fatalf("NewFixtureReader() error = %v", err)
+	}
+
+	current, err := r.GetCurrentMetrics(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("GetCurrentMetrics() error = %v", err)
+	}
+	if len(current) != 2 || current[0].QueryID != 1001 {
+		t.Fatalf("unexpected current metrics: %+v", current)
+	}
+
+	// Mutating returned snapshots must not affect the fixture
+	current[0].Query = "changed"
+	again, _ := r.GetCurrentMetrics(context.Background(), time.Hour)
+	if again[0].Query == "changed" {
+		t.Error("GetCurrentMetrics() should return a copy of the fixture data")
+	}
+
+	baseline, err := r.GetBaselineMetrics(context.Background(), time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("GetBaselineMetrics() error = %v", err)
+	}
+	if len(baseline) != 2 {
+		t.Errorf("expected 2 baseline metrics, got %d", len(baseline))
+	}
+
+	suggestions, err := r.GetIndexSuggestions(context.Background())
+	if err != nil {
+		t.Fatalf("GetIndexSuggestions() error = %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Table != "orders" {
+		t.Errorf("unexpected suggestions: %+v", suggestions)
+	}
+}
+
+func TestNewFixtureReader_InvalidFile(t *testing.T) {
+	if _, err := NewFixtureReader("does-not-exist.json"); err == nil {
+		t.Error("expected error for missing fixture file")
+	}
+}
+
+// stubTokenProvider returns a new token on every call.
+type stubTokenProvider struct {
+	calls int
+	err   error
+}
+
+func (s *stubTokenProvider) Token(ctx context.Context) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return fmt.Sprintf("tok'en-%d", s.calls), nil
+}
+
+func TestTokenConnector_InjectsFreshToken(t *testing.T) {
+	provider := &stubTokenProvider{}
+	c := &tokenConnector{
+		cfg:      &config.DatabaseConfig{Host: "db.example.com", Port: 5432, User: "iam_user", Password: "ignored", DBName: "powa", SSLMode: "require"},
+		provider: provider,
+	}
+
+	first, err := c.dsn(context.Background())
+	if err != nil {
+		t.Fatalf("dsn() error = %v", err)
+	}
+	second, _ := c.dsn(context.Background())
+
+	want := `host=db.example.com port=5432 user=iam_user password='tok\'en-1' dbname=powa sslmode=require ` +
+		`application_name='powa-sentinel' default_transaction_read_only=on`
+	if first != want {
+		t.Errorf("dsn() = %q, want %q", first, want)
+	}
+	if first == second || provider.calls != 2 {
+		t.Errorf("expected a new token per connection, got %d calls", provider.calls)
+	}
+}
+
+func TestTokenConnector_ProviderError(t *testing.T) {
+	c := &tokenConnector{
+		cfg:      &config.DatabaseConfig{Host: "localhost", Port: 5432, SSLMode: "require"},
+		provider: &stubTokenProvider{err: errors.New("no credentials")},
+	}
+
+	if _, err := c.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("Connect() error = %v, want provider error", err)
+	}
+}
+
+// mockConnector opens connections from the sqlmock driver registered under dsn.
+type mockConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c *mockConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c *mockConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+func TestInitConnector_RunsStatementsOnConnect(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("init_sql_runs")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	initDB := sql.OpenDB(withInitSQL(&mockConnector{drv: db.Driver(), dsn: "init_sql_runs"},
+		[]string{"SET search_path TO powa, public", "SET ROLE powa_reader"}))
+	defer initDB.Close()
+
+	// The init statements run before the first query on the new connection
+	mock.ExpectExec("SET search_path TO powa, public").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET ROLE powa_reader").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+	var n int
+	if err := initDB.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInitConnector_FailingStatement(t *testing.T) {
+	db, mock, err := sqlmock.NewWithDSN("init_sql_fails")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	initDB := sql.OpenDB(withInitSQL(&mockConnector{drv: db.Driver(), dsn: "init_sql_fails"},
+		[]string{"SET ROLE missing"}))
+	defer initDB.Close()
+
+	mock.ExpectExec("SET ROLE missing").WillReturnError(errors.New(`role "missing" does not exist`))
+
+	err = initDB.PingContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `running init_sql "SET ROLE missing"`) {
+		t.Errorf("PingContext() error = %v, want init_sql error", err)
+	}
+}
+
+// modeConnector records the sslmode it was built for and fails in failMode.
+type modeConnector struct {
+	mode     string
+	failMode string
+	attempts *[]string
+}
+
+func (c *modeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	*c.attempts = append(*c.attempts, c.mode)
+	if c.mode == c.failMode {
+		return nil, errors.New("pq: SSL is not enabled on the server")
+	}
+	return nil, nil
+}
+
+func (c *modeConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestNewSSLConnector_Fallback(t *testing.T) {
+	tests := []struct {
+		sslmode  string
+		failMode string
+		want     []string
+		wantErr  bool
+	}{
+		{"prefer", "", []string{"require"}, false},
+		{"prefer", "require", []string{"require", "disable"}, false},
+		{"allow", "disable", []string{"disable", "require"}, false},
+		{"require", "require", []string{"require"}, true},
+		{"verify-full", "", []string{"verify-full"}, false},
+	}
+	for _, tt := range tests {
+		var attempts []string
+		connector, err := newSSLConnector(&config.DatabaseConfig{SSLMode: tt.sslmode}, func(cfg *config.DatabaseConfig) (driver.Connector, error) {
+			return &modeConnector{mode: cfg.SSLMode, failMode: tt.failMode, attempts: &attempts}, nil
+		})
+		if err != nil {
+			t.Fatalf("newSSLConnector(%s) error = %v", tt.sslmode, err)
+		}
+		_, err = connector.Connect(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Connect() error = %v, wantErr %v", tt.sslmode, err, tt.wantErr)
+		}
+		if strings.Join(attempts, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: attempted modes %v, want %v", tt.sslmode, attempts, tt.want)
+		}
+	}
+}
+
+func TestDiagnosis_Aggregation(t *testing.T) {
+	d := Diagnosis{
+		{Name: "a", Status: CheckPass},
+		{Name: "b", Status: CheckWarn},
+		{Name: "c", Status: CheckWarn},
+	}
+	if d.Failed() {
+		t.Error("warnings alone should not fail the diagnosis")
+	}
+	if d.Count(CheckPass) != 1 || d.Count(CheckWarn) != 2 || d.Count(CheckFail) != 0 {
+		t.Errorf("unexpected counts: pass=%d warn=%d fail=%d", d.Count(CheckPass), d.Count(CheckWarn), d.Count(CheckFail))
+	}
+
+	d = append(d, CheckResult{Name: "d", Status: CheckFail})
+	if !d.Failed() {
+		t.Error("a failed check should fail the diagnosis")
+	}
+}
+
+func TestReader_Diagnose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{
+		db:  db,
+		cfg: &config.DatabaseConfig{Host: "localhost", Port: 5432, User: "powa_readonly", DBName: "powa"},
+	}
+
+	mock.ExpectQuery("SHOW default_transaction_read_only").
+		WillReturnRows(sqlmock.NewRows([]string{"default_transaction_read_only"}).AddRow("on"))
+	mock.ExpectQuery("SHOW server_version_num").
+		WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140000"))
+	mock.ExpectQuery("SELECT extversion FROM pg_extension WHERE extname = 'powa'").
+		WillReturnRows(sqlmock.NewRows([]string{"extversion"}).AddRow("3.2.0"))
+	mock.ExpectQuery("SELECT EXISTS.*pg_stat_kcache").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	privRows := func(exists, allowed bool) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"exists", "allowed"}).AddRow(exists, allowed)
+	}
+	mock.ExpectQuery("has_table_privilege").WithArgs("powa_statements_history").WillReturnRows(privRows(true, true))
+	mock.ExpectQuery("has_table_privilege").WithArgs("powa_statements").WillReturnRows(privRows(true, false))
+	mock.ExpectQuery("has_table_privilege").WithArgs("powa_databases").WillReturnRows(privRows(true, true))
+	mock.ExpectQuery("SELECT to_regclass").WithArgs("powa_qualstats_indexes").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT max\\(ts\\) FROM powa_statements_history").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Now().Add(-2 * time.Hour)))
+
+	d := r.Diagnose(context.Background(), time.Hour)
+
+	if !d.Failed() {
+		t.Error("expected diagnosis to fail on missing SELECT privilege")
+	}
+	statuses := map[string]CheckStatus{}
+	for _, c := range d {
+		statuses[c.Name] = c.Status
+	}
+	expected := map[string]CheckStatus{
+		"Database connection":           CheckPass,
+		"Read-only session":             CheckPass,
+		"PoWA extension":                CheckPass,
+		"pg_stat_kcache":                CheckWarn,
+		"pg_qualstats":                  CheckPass,
+		"pg_wait_sampling":              CheckWarn,
+		"SELECT on powa_statements":     CheckFail,
+		"powa_qualstats_indexes exists": CheckWarn,
+		"Recent statement history":      CheckWarn,
+	}
+	for name, want := range expected {
+		if statuses[name] != want {
+			t.Errorf("check %q = %q, want %q", name, statuses[name], want)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_getMetrics_DatabaseFilter(t *testing.T) {
+	versions := []struct {
+		name        string
+		powaVersion string
+	}{
+		{"PoWA3", "3.2.0"},
+		{"PoWA4", "4.2.2"},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	columns := []string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}
+
+	for _, v := range versions {
+		t.Run(v.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: v.powaVersion}
+			r.SetDatabaseFilter("payments")
+
+			// The predicate must come before ORDER BY/LIMIT so the limit is per database
+			mock.ExpectQuery(`WHERE pd\.datname = \$3\s+ORDER BY total_time DESC\s+LIMIT`).
+				WithArgs(start, end, "payments").
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "SELECT 1", "payments", "local", 0, 10.0, 1.0, 10, end, 10, nil))
+
+			metrics, err := r.getMetrics(context.Background(), start, end)
+			if err != nil {
+				t.Fatalf("getMetrics() error = %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].DatabaseName != "payments" {
+				t.Errorf("metrics = %+v, want one payments row", metrics)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_getMetrics_NoDatabaseFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: "3.2.0"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	mock.ExpectQuery(`LEFT JOIN pg_roles rol ON rol\.oid = fl\.userid\s+ORDER BY total_time DESC`).
+		WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}))
+
+	if _, err := r.getMetrics(context.Background(), start, end); err != nil {
+		t.Fatalf("getMetrics() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_getMetrics_RoleNames(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: "4.2.2"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	// Only the local server's role oids are looked up in pg_roles
+	mock.ExpectQuery(`LEFT JOIN pg_roles rol ON fl\.srvid = 0 AND rol\.oid = fl\.userid`).
+		WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}).
+			AddRow(1, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, end, 16384, "billing").
+			AddRow(1, "SELECT 1", "app", "remote", 2, 10.0, 1.0, 10, end, 16384, nil))
+
+	metrics, err := r.getMetrics(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("getMetrics() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("getMetrics() returned %d rows, want 2", len(metrics))
+	}
+	if metrics[0].UserID != 16384 || metrics[0].UserName != "billing" {
+		t.Errorf("local row role = %d %q, want 16384 billing", metrics[0].UserID, metrics[0].UserName)
+	}
+	if metrics[1].UserID != 16384 || metrics[1].UserName != "" {
+		t.Errorf("remote row role = %d %q, want 16384 without a name", metrics[1].UserID, metrics[1].UserName)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_getMetrics_Cursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{UseCursor: true, CursorBatchSize: 2}, pgVersion: 140000, powaVersion: "4.2.2"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	columns := []string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}
+
+	// Three rows in batches of two: a full batch, then a short one ends the read
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE powa_sentinel_metrics NO SCROLL CURSOR FOR\s+WITH u AS`).
+		WithArgs(start, end).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM powa_sentinel_metrics`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "SELECT 1", "app", "local", 0, 30.0, 3.0, 10, end, 10, nil).
+			AddRow(2, "SELECT 2", "app", "local", 0, 20.0, 2.0, 10, end, 10, nil))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM powa_sentinel_metrics`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "SELECT 3", "app", "local", 0, 10.0, 1.0, 10, end, 10, nil))
+	mock.ExpectRollback()
+
+	metrics, err := r.getMetrics(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("getMetrics() error = %v", err)
+	}
+	if len(metrics) != 3 || metrics[0].QueryID != 1 || metrics[2].QueryID != 3 {
+		t.Errorf("getMetrics() = %+v, want queries 1, 2 and 3 in order", metrics)
+	}
+
+	// A batch that fills up exactly needs one more, empty FETCH to end
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE powa_sentinel_metrics`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM powa_sentinel_metrics`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "SELECT 1", "app", "local", 0, 30.0, 3.0, 10, end, 10, nil).
+			AddRow(2, "SELECT 2", "app", "local", 0, 20.0, 2.0, 10, end, 10, nil))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM powa_sentinel_metrics`).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectRollback()
+
+	if metrics, err = r.getMetrics(context.Background(), start, end); err != nil || len(metrics) != 2 {
+		t.Errorf("getMetrics() = %d rows, %v; want 2 rows", len(metrics), err)
+	}
+
+	// A failed FETCH fails the read and still closes the cursor
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE powa_sentinel_metrics`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM powa_sentinel_metrics`).WillReturnError(errors.New("canceling statement"))
+	mock.ExpectRollback()
+
+	if _, err := r.getMetrics(context.Background(), start, end); err == nil {
+		t.Error("getMetrics() should fail when a FETCH fails")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_getMetrics_CoalesceRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		has   bool
+		where string // the PoWA 4 records filter expected in the metrics query
+	}{
+		{"with coalesce_range", true, `WHERE ps\.coalesce_range && tstzrange\(\$1::timestamptz, \$2::timestamptz, '\[\]'\)\s+AND \(r\)\.ts >= \$1`},
+		{"without coalesce_range", false, `CROSS JOIN LATERAL unnest\(ps\.records\) AS r\s+WHERE \(r\)\.ts >= \$1 AND \(r\)\.ts <= \$2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			end := start.Add(time.Hour)
+
+			mock.ExpectQuery("SHOW server_version_num").
+				WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140000"))
+			mock.ExpectQuery("SELECT extversion FROM pg_extension WHERE extname = 'powa'").
+				WillReturnRows(sqlmock.NewRows([]string{"extversion"}).AddRow("4.2.2"))
+			mock.ExpectQuery("SELECT EXISTS.*pg_stat_kcache").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*attname = 'coalesce_range'").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.has))
+			columns := []string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}
+			mock.ExpectQuery(tt.where).
+				WithArgs(start, end).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, end, 10, nil))
+
+			if err := r.checkExtensions(context.Background()); err != nil {
+				t.Fatalf("checkExtensions() error = %v", err)
+			}
+			if r.hasCoalesceRange != tt.has {
+				t.Errorf("hasCoalesceRange = %v, want %v", r.hasCoalesceRange, tt.has)
+			}
+			metrics, err := r.getMetrics(context.Background(), start, end)
+			if err != nil {
+				t.Fatalf("getMetrics() error = %v", err)
+			}
+			if len(metrics) != 1 {
+				t.Errorf("getMetrics() = %d rows, want 1", len(metrics))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_getMetrics_RowLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{MaxQueryRows: 2}, pgVersion: 140000, powaVersion: "4.2.2"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	columns := []string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}
+
+	// Below the limit: nothing to report
+	mock.ExpectQuery(`ORDER BY total_time DESC\s+LIMIT 2\s*$`).
+		WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "SELECT 1", "app", "local", 0, 30.0, 3.0, 10, end, 10, nil))
+	if _, err := r.getMetrics(context.Background(), start, end); err != nil {
+		t.Fatalf("getMetrics() error = %v", err)
+	}
+	if logs.Len() != 0 || len(r.RowLimitHits()) != 0 {
+		t.Errorf("a read below the limit should not be reported, got hits %v and logs %q", r.RowLimitHits(), logs.String())
+	}
+
+	// As many rows as the limit: probably truncated
+	mock.ExpectQuery(`ORDER BY total_time DESC\s+LIMIT 2\s*$`).
+		WithArgs(start, end).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "SELECT 1", "app", "local", 0, 30.0, 3.0, 10, end, 10, nil).
+			AddRow(2, "SELECT 2", "app", "local", 0, 20.0, 2.0, 10, end, 10, nil))
+	if _, err := r.getMetrics(context.Background(), start, end); err != nil {
+		t.Fatalf("getMetrics() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "powa_statements_history returned 2 rows, the database.max_query_rows limit") {
+		t.Errorf("hitting the limit should log a warning, got %q", logs.String())
+	}
+	if _, ok := r.RowLimitHits()["powa_statements_history"]; !ok || r.RowLimit() != 2 {
+		t.Errorf("RowLimitHits() = %v, RowLimit() = %d; want a powa_statements_history hit of limit 2", r.RowLimitHits(), r.RowLimit())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_getMetrics_Stddev(t *testing.T) {
+	tests := []struct {
+		name    string
+		column  string // detected stddev column; empty when the history has none
+		wantCol string
+	}{
+		{"recorded", "stddev_exec_time", "stddev_exec_time"},
+		{"not recorded", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+			r.SetTailLatency(true)
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			end := start.Add(time.Hour)
+
+			mock.ExpectQuery("SHOW server_version_num").
+				WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140000"))
+			mock.ExpectQuery("SELECT extversion FROM pg_extension WHERE extname = 'powa'").
+				WillReturnRows(sqlmock.NewRows([]string{"extversion"}).AddRow("4.2.2"))
+			mock.ExpectQuery("SELECT EXISTS.*pg_stat_kcache").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*pg_qualstats").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*pg_wait_sampling").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectQuery("SELECT EXISTS.*coalesce_range").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			detect := mock.ExpectQuery(`SELECT attname FROM pg_attribute`).WithArgs("powa_statements_history_record")
+			columns := []string{"queryid", "query", "datname", "server_name", "srvid", "total_time", "mean_time", "calls", "ts", "userid", "rolname"}
+			if tt.column == "" {
+				detect.WillReturnRows(sqlmock.NewRows([]string{"attname"}))
+				mock.ExpectQuery(`rol\.rolname\s+FROM first_last fl`).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, end, 10, nil))
+			} else {
+				detect.WillReturnRows(sqlmock.NewRows([]string{"attname"}).AddRow(tt.column))
+				mock.ExpectQuery(`\(r\)\.stddev_exec_time AS stddev_time.*fl\.stddev_time`).
+					WillReturnRows(sqlmock.NewRows(append(columns, "stddev_time")).AddRow(1, "SELECT 1", "app", "local", 0, 10.0, 1.0, 10, end, 10, nil, 2.5))
+			}
+
+			if err := r.checkExtensions(context.Background()); err != nil {
+				t.Fatalf("checkExtensions() error = %v", err)
+			}
+			metrics, err := r.getMetrics(context.Background(), start, end)
+			if err != nil {
+				t.Fatalf("getMetrics() error = %v", err)
+			}
+			if r.stddevCol != tt.wantCol || r.HasStddev() != (tt.wantCol != "") {
+				t.Errorf("stddev column = %q, want %q", r.stddevCol, tt.wantCol)
+			}
+			if len(metrics) != 1 || metrics[0].HasStddev != (tt.wantCol != "") || (tt.wantCol != "" && metrics[0].StddevTime != 2.5) {
+				t.Errorf("getMetrics() = %+v, want the stddev only when recorded", metrics)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_QueryCustom(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+	query := "SELECT relname, n_dead_tup FROM pg_stat_user_tables"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT set_config\('statement_timeout', \$1, true\)`).
+		WithArgs("5000").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT relname, n_dead_tup FROM pg_stat_user_tables").
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "n_dead_tup"}).
+			AddRow("orders", int64(150000)).
+			AddRow("audit", nil))
+	mock.ExpectRollback()
+
+	result, err := r.QueryCustom(context.Background(), query, 5*time.Second)
+	if err != nil {
+		t.Fatalf("QueryCustom() error = %v", err)
+	}
+
+	if strings.Join(result.Columns, ",") != "relname,n_dead_tup" {
+		t.Errorf("columns = %v", result.Columns)
+	}
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(result.Rows))
+	}
+	if result.Rows[0][1].String != "150000" || !result.Rows[0][1].Valid {
+		t.Errorf("row 0 n_dead_tup = %+v, want 150000", result.Rows[0][1])
+	}
+	if result.Rows[1][1].Valid {
+		t.Errorf("row 1 n_dead_tup should be NULL, got %+v", result.Rows[1][1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetConnectionUsage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// The monitored instance has no PoWA extensions: none are checked
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+	mock.ExpectQuery(`FROM pg_stat_activity\s+WHERE backend_type = 'client backend'`).
+		WillReturnRows(sqlmock.NewRows([]string{"active", "idle", "total", "max_connections"}).AddRow(12, 70, 85, 100))
+
+	got, err := r.GetConnectionUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetConnectionUsage() error = %v", err)
+	}
+	want := model.ConnectionUsage{Active: 12, Idle: 70, Total: 85, MaxConnections: 100}
+	if got != want {
+		t.Errorf("GetConnectionUsage() = %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExplainStatement(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"SELECT * FROM t WHERE id = 1;", "EXPLAIN SELECT * FROM t WHERE id = 1", false},
+		{"select * from t where id = $1", "EXPLAIN (GENERIC_PLAN) select * from t where id = $1", false},
+		{"(SELECT 1) UNION (SELECT 2)", "EXPLAIN (SELECT 1) UNION (SELECT 2)", false},
+		{"EXPLAIN ANALYZE DELETE FROM t", "", true},
+		{"SELECT 1; DELETE FROM t", "", true},
+		{"VACUUM t", "", true},
+		{"  ", "", true},
+	}
+	for _, tt := range tests {
+		got, err := explainStatement(tt.query)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("explainStatement(%q) = %q, %v; want %q, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReader_Explain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{DBName: "app"}}
+	mock.ExpectQuery(`^EXPLAIN SELECT \* FROM orders$`).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).
+			AddRow("Seq Scan on orders  (cost=0.00..35.50 rows=2550 width=4)").
+			AddRow("  Filter: (id > 0)"))
+
+	got, err := r.Explain(context.Background(), "app", "SELECT * FROM orders")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if want := "Seq Scan on orders  (cost=0.00..35.50 rows=2550 width=4)\n  Filter: (id > 0)"; got != want {
+		t.Errorf("Explain() = %q, want %q", got, want)
+	}
+
+	// A query of another database cannot be planned on this connection
+	if _, err := r.Explain(context.Background(), "billing", "SELECT * FROM orders"); err == nil {
+		t.Error("Explain() in another database should fail")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("querying: %w", &pq.Error{Code: "08006"}), true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "42501"}, false},
+		{context.DeadlineExceeded, false},
+		{ErrPoolExhausted, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReader_QueryWithRetry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// A connection failure is retried; a query error is not
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, retries: 2, retryDelay: time.Millisecond}
+	mock.ExpectQuery("SELECT 1").WillReturnError(&pq.Error{Code: "57P01"})
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"x"}).AddRow(1))
+	rows, err := r.queryWithRetry(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("queryWithRetry() error = %v", err)
+	}
+	rows.Close()
+
+	mock.ExpectQuery("SELECT 1").WillReturnError(&pq.Error{Code: "42501"})
+	if _, err := r.queryWithRetry(context.Background(), "SELECT 1"); err == nil {
+		t.Error("queryWithRetry() should fail on a permission error")
+	}
+
+	// The run's retry budget caps the retries below database.retries
+	mock.ExpectQuery("SELECT 1").WillReturnError(&pq.Error{Code: "08006"})
+	mock.ExpectQuery("SELECT 1").WillReturnError(&pq.Error{Code: "08006"})
+	ctx := retry.WithBudget(context.Background(), 1)
+	if _, err := r.queryWithRetry(ctx, "SELECT 1"); !errors.Is(err, retry.ErrBudgetExhausted) {
+		t.Errorf("queryWithRetry() error = %v, want retry.ErrBudgetExhausted", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_LatestSnapshotTime(t *testing.T) {
+	latest := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		powaVersion string
+		query       string
+		value       interface{}
+		want        time.Time
+	}{
+		{"PoWA3", "3.2.0", `SELECT max\(ts\) FROM powa_statements_history`, latest, latest},
+		{"PoWA4", "4.2.2", `powa_statements_history_current`, latest, latest},
+		{"empty history", "3.2.0", `SELECT max\(ts\) FROM powa_statements_history`, nil, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: tt.powaVersion}
+			r.extensionsOnce.Do(func() {}) // extensions already detected
+
+			mock.ExpectQuery(tt.query).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tt.value))
+
+			got, err := r.LatestSnapshotTime(context.Background())
+			if err != nil {
+				t.Fatalf("LatestSnapshotTime() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("LatestSnapshotTime() = %v, want %v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_HasDataInWindow(t *testing.T) {
+	tests := []struct {
+		name          string
+		powaVersion   string
+		coalesceRange bool
+		query         string
+		has           bool
+	}{
+		{"PoWA3", "3.2.0", false, `SELECT EXISTS \(SELECT 1 FROM powa_statements_history ps WHERE ps\.ts >= \$1 AND ps\.ts <= \$2\)`, true},
+		{"PoWA4", "4.2.2", true, `ps\.coalesce_range && tstzrange\(.*\) AND \(r\)\.ts >= \$1`, true},
+		{"PoWA4 without coalesce_range", "4.2.2", false, `unnest\(ps\.records\) AS r\s+WHERE \(r\)\.ts >= \$1`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: tt.powaVersion, hasCoalesceRange: tt.coalesceRange}
+			r.extensionsOnce.Do(func() {}) // extensions already detected
+
+			mock.ExpectQuery(tt.query).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.has))
+
+			got, err := r.HasDataInWindow(context.Background(), time.Hour)
+			if err != nil {
+				t.Fatalf("HasDataInWindow() error = %v", err)
+			}
+			if got != tt.has {
+				t.Errorf("HasDataInWindow() = %v, want %v", got, tt.has)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_GetWaitEvents(t *testing.T) {
+	versions := []struct {
+		name        string
+		powaVersion string
+		query       string
+	}{
+		{"PoWA3", "3.2.0", `FROM powa_wait_sampling_history w\s+WHERE w.ts >= \$1`},
+		{"PoWA4", "4.2.2", `unnest\(w.records\)`},
+	}
+	for _, v := range versions {
+		t.Run(v.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: v.powaVersion, hasWaits: true}
+			r.extensionsOnce.Do(func() {}) // extensions already detected
+			r.SetDatabaseFilter("app")
+
+			mock.ExpectQuery(v.query+`(?s).*pd.datname = \$3`).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "app").
+				WillReturnRows(sqlmock.NewRows([]string{"queryid", "srvid", "datname", "event_type", "event", "samples"}).
+					AddRow(int64(42), 1, "app", "Lock", "transactionid", int64(500)))
+
+			samples, err := r.GetWaitEvents(context.Background(), time.Hour)
+			if err != nil {
+				t.Fatalf("GetWaitEvents() error = %v", err)
+			}
+			want := model.WaitEventSample{QueryID: 42, SrvID: 1, DatabaseName: "app", EventType: "Lock", Event: "transactionid", Samples: 500}
+			if len(samples) != 1 || samples[0] != want {
+				t.Errorf("GetWaitEvents() = %+v, want [%+v]", samples, want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_GetWaitEvents_WithoutExtension(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: "4.2.2"}
+	r.extensionsOnce.Do(func() {})
+
+	samples, err := r.GetWaitEvents(context.Background(), time.Hour)
+	if err != nil || samples != nil {
+		t.Errorf("GetWaitEvents() = %v, %v; want nil, nil without pg_wait_sampling", samples, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryLimiter_BoundsConcurrency(t *testing.T) {
+	r := &Reader{limiter: newQueryLimiter(2)}
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := r.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire() error = %v", err)
+				return
+			}
+			defer done()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		}()
+	}
+
+	// Two calls hold the slots and the other four queue behind them
+	deadline := time.Now().Add(time.Second)
+	for r.QueryStats() != (QueryStats{InFlight: 2, Queued: 4}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("QueryStats() = %+v, want 2 in flight and 4 queued", r.QueryStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if peak.Load() != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak.Load())
+	}
+	if stats := r.QueryStats(); stats != (QueryStats{}) {
+		t.Errorf("QueryStats() after release = %+v, want zero", stats)
+	}
+}
+
+func TestQueryLimiter_ContextCancelled(t *testing.T) {
+	r := &Reader{limiter: newQueryLimiter(1)}
+	done, err := r.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer done()
+
+	// A queued call gives up with its context instead of waiting forever
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.GetDatabaseList(ctx, 0, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetDatabaseList() error = %v, want context.DeadlineExceeded", err)
+	}
+	if stats := r.QueryStats(); stats != (QueryStats{InFlight: 1}) {
+		t.Errorf("QueryStats() = %+v, want only the held slot", stats)
+	}
+}
+
+func TestQueryLimiter_AcquireTimeout(t *testing.T) {
+	r := &Reader{limiter: newQueryLimiter(1), acquireTimeout: 10 * time.Millisecond}
+	done, err := r.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer done()
+
+	// With time left on its context, a call that cannot get a connection says so
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = r.GetDatabaseList(ctx, 0, 0)
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("GetDatabaseList() error = %v, want ErrPoolExhausted", err)
+	}
+	if !strings.Contains(err.Error(), "1 reader calls in flight") {
+		t.Errorf("GetDatabaseList() error = %q, want the calls holding the pool", err)
+	}
+
+	// A context that runs out first keeps its own error
+	short, cancelShort := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelShort()
+	if _, err := r.GetDatabaseList(short, 0, 0); !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("GetDatabaseList() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestReader_GetDatabaseList(t *testing.T) {
+	tests := []struct {
+		name        string
+		powaVersion string
+		since       time.Duration
+		limit       int
+		query       string
+		args        []driver.Value
+		want        []string
+	}{
+		{"all databases", "4.2.2", 0, 0, `^SELECT DISTINCT datname FROM powa_databases ORDER BY datname$`, nil, []string{"app", "dropped", "reports"}},
+		{"limit", "4.2.2", 0, 2, `ORDER BY datname LIMIT 2$`, nil, []string{"app", "dropped"}},
+		{"PoWA4 since", "4.2.2", 24 * time.Hour, 0,
+			`powa_statements_history_current(?s).*seen.srvid = pd.srvid AND seen.dbid = pd.oid(?s).*ORDER BY max\(seen.ts\) DESC, pd.datname$`,
+			[]driver.Value{sqlmock.AnyArg()}, []string{"reports", "app"}},
+		{"PoWA3 since with limit", "3.2.0", 24 * time.Hour, 1,
+			`FROM powa_statements_history WHERE ts >= \$1(?s).*seen.dbid = pd.oid(?s).*LIMIT 1$`,
+			[]driver.Value{sqlmock.AnyArg()}, []string{"reports"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			r := &Reader{db: db, cfg: &config.DatabaseConfig{}, pgVersion: 140000, powaVersion: tt.powaVersion}
+			r.extensionsOnce.Do(func() {}) // extensions already detected
+
+			// The database only returns what the query selects: "dropped" has no recent snapshots
+			rows := sqlmock.NewRows([]string{"datname"})
+			for _, name := range tt.want {
+				rows.AddRow(name)
+			}
+			q := mock.ExpectQuery(tt.query)
+			if tt.args != nil {
+				q.WithArgs(tt.args...)
+			}
+			q.WillReturnRows(rows)
+
+			got, err := r.GetDatabaseList(context.Background(), tt.since, tt.limit)
+			if err != nil {
+				t.Fatalf("GetDatabaseList() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetDatabaseList() = %v, want %v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestReader_GetVacuumCandidates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+	r.SetDatabaseFilter("app")
+
+	vacuumed := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`to_regclass\('powa_all_tables_history_current'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(true))
+	mock.ExpectQuery(`DISTINCT ON \(h.srvid, h.dbid, h.relid\)(?s).*pd.datname = \$1`).
+		WithArgs("app").
+		WillReturnRows(sqlmock.NewRows([]string{"datname", "nspname", "relname", "n_live_tup", "n_dead_tup", "last_vacuum", "last_analyze"}).
+			AddRow("app", "public", "orders", int64(30000), int64(10000), vacuumed, nil))
+
+	tables, err := r.GetVacuumCandidates(context.Background())
+	if err != nil {
+		t.Fatalf("GetVacuumCandidates() error = %v", err)
+	}
+	want := model.TableStats{DatabaseName: "app", Schema: "public", Table: "orders", LiveTuples: 30000, DeadTuples: 10000, LastVacuum: vacuumed}
+	if len(tables) != 1 || tables[0] != want {
+		t.Errorf("GetVacuumCandidates() = %+v, want [%+v]", tables, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReader_GetVacuumCandidates_WithoutSnapshots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	r := &Reader{db: db, cfg: &config.DatabaseConfig{}}
+	mock.ExpectQuery(`to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"available"}).AddRow(false))
+
+	tables, err := r.GetVacuumCandidates(context.Background())
+	if err != nil || tables != nil {
+		t.Errorf("GetVacuumCandidates() = %v, %v; want nil, nil without table statistics snapshots", tables, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
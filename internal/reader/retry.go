@@ -0,0 +1,68 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/powa-team/powa-sentinel/internal/retry"
+)
+
+// queryWithRetry runs query, retrying it up to database.retries times with
+// exponential backoff from database.retry_delay when it fails on a lost or
+// refused connection. Retries draw on the run's retry budget (see retry.Wait),
+// so a flapping repository cannot spend the time the notifiers need.
+func (r *Reader) queryWithRetry(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := r.withRetry(ctx, func() error {
+		var err error
+		rows, err = r.db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// withRetry calls fn, retrying it like queryWithRetry retries its query.
+func (r *Reader) withRetry(ctx context.Context, fn func() error) error {
+	delay := r.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.retries || ctx.Err() != nil || !isTransientError(err) {
+			return err
+		}
+		log.Printf("Warning: query failed on attempt %d, retrying in %s: %v", attempt+1, delay, err)
+		if werr := retry.Wait(ctx, delay); werr != nil {
+			return fmt.Errorf("%w (not retried: %w)", err, werr)
+		}
+		delay *= 2
+	}
+}
+
+// isTransientError reports whether err is a connection failure that a retry
+// may get past, as opposed to an error in the query or its permissions.
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 = connection_exception; 57P01-57P03 = the server is shutting down or starting
+		code := string(pqErr.Code)
+		return strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02" || code == "57P03"
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package reader
+
+import (
+	"log"
+	"maps"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// rowLimit returns the LIMIT of the metrics, wait event and table statistics
+// queries, database.max_query_rows.
+func (r *Reader) rowLimit() int {
+	if r.cfg == nil {
+		return config.DefaultMaxQueryRows
+	}
+	return r.cfg.QueryRowLimit()
+}
+
+// noteRowLimit warns when a read of source returned as many rows as the
+// database.max_query_rows limit, so its results were probably truncated, and
+// records the hit for RowLimitHits.
+func (r *Reader) noteRowLimit(source string, rows int) {
+	limit := r.rowLimit()
+	if rows < limit {
+		return
+	}
+	log.Printf("Warning: %s returned %d rows, the database.max_query_rows limit; results are probably truncated", source, limit)
+
+	r.rowLimitMu.Lock()
+	defer r.rowLimitMu.Unlock()
+	if r.rowLimitHits == nil {
+		r.rowLimitHits = make(map[string]time.Time)
+	}
+	r.rowLimitHits[source] = time.Now()
+}
+
+// RowLimitHits returns when a read of each source (e.g.
+// powa_statements_history) last returned database.max_query_rows rows, for
+// /status. Sources that never hit the limit are absent.
+func (r *Reader) RowLimitHits() map[string]time.Time {
+	r.rowLimitMu.Lock()
+	defer r.rowLimitMu.Unlock()
+	return maps.Clone(r.rowLimitHits)
+}
+
+// RowLimit returns the database.max_query_rows limit of this repository.
+func (r *Reader) RowLimit() int {
+	return r.rowLimit()
+}
@@ -0,0 +1,59 @@
+package reader
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// sslFallbacks maps the libpq sslmodes lib/pq does not implement to the modes
+// tried in order: allow tries plaintext first, prefer tries SSL first.
+var sslFallbacks = map[string][2]string{
+	"allow":  {"disable", "require"},
+	"prefer": {"require", "disable"},
+}
+
+// newSSLConnector builds a connector for cfg with build. For allow and prefer it
+// builds one connector per fallback mode and tries them in turn, as libpq does.
+func newSSLConnector(cfg *config.DatabaseConfig, build func(cfg *config.DatabaseConfig) (driver.Connector, error)) (driver.Connector, error) {
+	modes, ok := sslFallbacks[cfg.SSLMode]
+	if !ok {
+		return build(cfg)
+	}
+	var connectors [2]driver.Connector
+	for i, mode := range modes {
+		modeCfg := *cfg
+		modeCfg.SSLMode = mode
+		connector, err := build(&modeCfg)
+		if err != nil {
+			return nil, err
+		}
+		connectors[i] = connector
+	}
+	return &sslFallbackConnector{first: connectors[0], second: connectors[1]}, nil
+}
+
+// sslFallbackConnector connects with first and, when that fails, with second.
+type sslFallbackConnector struct {
+	first, second driver.Connector
+}
+
+// Connect opens a connection with the first mode, falling back to the second.
+func (c *sslFallbackConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.first.Connect(ctx)
+	if err == nil {
+		return conn, nil
+	}
+	conn, fallbackErr := c.second.Connect(ctx)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fallbackErr)
+	}
+	return conn, nil
+}
+
+// Driver returns the driver of the first connector.
+func (c *sslFallbackConnector) Driver() driver.Driver {
+	return c.first.Driver()
+}
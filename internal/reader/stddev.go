@@ -0,0 +1,49 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// SetTailLatency makes metrics queries read each query's execution time
+// standard deviation, for rules.regression.metric tail. It takes effect when
+// the extensions are detected, so set it before the first query.
+func (r *Reader) SetTailLatency(enabled bool) {
+	r.wantStddev = enabled
+}
+
+// HasStddev returns whether metrics carry the execution time standard deviation.
+func (r *Reader) HasStddev() bool {
+	return r.stddevCol != ""
+}
+
+// detectStddevColumn returns the history column holding the execution time
+// standard deviation, or "" when PoWA does not record it. PoWA 4 keeps the
+// counters in the powa_statements_history_record type of its records array,
+// PoWA 3 in the columns of powa_statements_history.
+func (r *Reader) detectStddevColumn(ctx context.Context) string {
+	rel := "powa_statements_history"
+	if r.isPoWA4() {
+		rel = "powa_statements_history_record"
+	}
+	var col string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT attname FROM pg_attribute
+		WHERE attrelid = to_regclass($1)
+			AND attname IN ('stddev_exec_time', 'stddev_time')
+			AND NOT attisdropped
+		ORDER BY attname
+		LIMIT 1
+	`, rel).Scan(&col)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Printf("Warning: %s has no execution time stddev; rules.regression.metric tail compares the mean time", rel)
+		return ""
+	case err != nil:
+		log.Printf("Warning: detecting the execution time stddev column: %v; rules.regression.metric tail compares the mean time", err)
+		return ""
+	}
+	return col
+}
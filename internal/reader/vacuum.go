@@ -0,0 +1,84 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// GetVacuumCandidates returns the latest pg_stat_all_tables snapshot of each
+// user table, as collected by PoWA 5's table statistics module. It returns nil
+// when the repository has no such snapshots (PoWA 3/4, or the module disabled).
+func (r *Reader) GetVacuumCandidates(ctx context.Context) ([]model.TableStats, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var available bool
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT to_regclass('powa_all_tables_history_current') IS NOT NULL
+			AND to_regclass('powa_catalog_class') IS NOT NULL
+			AND to_regclass('powa_catalog_namespace') IS NOT NULL
+	`).Scan(&available); err != nil {
+		return nil, fmt.Errorf("checking table statistics snapshots: %w", err)
+	}
+	if !available {
+		return nil, nil
+	}
+
+	var args []interface{}
+	dbFilter := ""
+	if r.database != "" {
+		dbFilter = "AND pd.datname = $1"
+		args = append(args, r.database)
+	}
+
+	// _current holds the records not yet aggregated; the newest one per table is its current state
+	query := fmt.Sprintf(`
+		SELECT DISTINCT ON (h.srvid, h.dbid, h.relid)
+			pd.datname, n.nspname, c.relname,
+			(h.record).n_live_tup, (h.record).n_dead_tup,
+			GREATEST((h.record).last_vacuum, (h.record).last_autovacuum),
+			GREATEST((h.record).last_analyze, (h.record).last_autoanalyze)
+		FROM powa_all_tables_history_current h
+		JOIN powa_databases pd ON pd.srvid = h.srvid AND pd.oid = h.dbid
+		JOIN powa_catalog_class c ON c.srvid = h.srvid AND c.dbid = h.dbid AND c.oid = h.relid
+		JOIN powa_catalog_namespace n ON n.srvid = c.srvid AND n.dbid = c.dbid AND n.oid = c.relnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%%' %s
+		ORDER BY h.srvid, h.dbid, h.relid, (h.record).ts DESC
+		LIMIT %d
+	`, dbFilter, r.rowLimit())
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if isViewNotExistError(err) || isUndefinedColumnError(err) || isPermissionError(err) {
+			log.Printf("Warning: cannot read table statistics snapshots, skipping vacuum rule: %v", err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("querying powa_all_tables_history_current: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []model.TableStats
+	for rows.Next() {
+		var t model.TableStats
+		var lastVacuum, lastAnalyze sql.NullTime
+		if err := rows.Scan(&t.DatabaseName, &t.Schema, &t.Table, &t.LiveTuples, &t.DeadTuples,
+			&lastVacuum, &lastAnalyze); err != nil {
+			return nil, fmt.Errorf("scanning table statistics row: %w", err)
+		}
+		t.LastVacuum = lastVacuum.Time
+		t.LastAnalyze = lastAnalyze.Time
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating table statistics rows: %w", err)
+	}
+	r.noteRowLimit("powa_all_tables_history_current", len(tables))
+	return tables, nil
+}
@@ -0,0 +1,109 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/model"
+)
+
+// GetWaitEvents returns the pg_wait_sampling samples each query spent on each
+// wait event during the last window. It returns nil without pg_wait_sampling.
+// Like the statement history, PoWA stores cumulative counts, so each sample
+// count is the delta (last - first) in the window.
+func (r *Reader) GetWaitEvents(ctx context.Context, window time.Duration) ([]model.WaitEventSample, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := r.checkExtensions(ctx); err != nil {
+		return nil, err
+	}
+	if !r.hasWaits {
+		return nil, nil
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-window)
+	args := []interface{}{startTime, endTime}
+	dbFilter := ""
+	if r.database != "" {
+		dbFilter = "AND pd.datname = $3"
+		args = append(args, r.database)
+	}
+
+	var query string
+	if r.isPoWA4() {
+		query = fmt.Sprintf(`
+			WITH u AS (
+				SELECT w.queryid, w.srvid, w.dbid, w.event_type, w.event,
+					(r).ts AS ts,
+					(r).count AS count
+				FROM powa_wait_sampling_history w
+				CROSS JOIN LATERAL unnest(w.records) AS r
+				WHERE w.coalesce_range && tstzrange($1::timestamptz, $2::timestamptz, '[]')
+					AND (r).ts >= $1 AND (r).ts <= $2
+			),
+			first_last AS (
+				SELECT
+					queryid, srvid, dbid, event_type, event,
+					(array_agg(count ORDER BY ts))[1] AS first_count,
+					(array_agg(count ORDER BY ts DESC))[1] AS last_count
+				FROM u
+				GROUP BY queryid, srvid, dbid, event_type, event
+			)
+			SELECT fl.queryid, fl.srvid, pd.datname, fl.event_type, fl.event,
+				(fl.last_count - fl.first_count)::bigint AS samples
+			FROM first_last fl
+			JOIN powa_databases pd ON fl.srvid = pd.srvid AND fl.dbid = pd.oid
+			WHERE fl.last_count > fl.first_count %s
+			LIMIT %d
+		`, dbFilter, r.rowLimit())
+	} else {
+		query = fmt.Sprintf(`
+			WITH first_last AS (
+				SELECT
+					w.queryid, w.dbid, w.event_type, w.event,
+					(array_agg(w.count ORDER BY w.ts))[1] AS first_count,
+					(array_agg(w.count ORDER BY w.ts DESC))[1] AS last_count
+				FROM powa_wait_sampling_history w
+				WHERE w.ts >= $1 AND w.ts <= $2
+				GROUP BY w.queryid, w.dbid, w.event_type, w.event
+			)
+			SELECT fl.queryid, 0 AS srvid, pd.datname, fl.event_type, fl.event,
+				(fl.last_count - fl.first_count)::bigint AS samples
+			FROM first_last fl
+			JOIN powa_databases pd ON fl.dbid = pd.oid
+			WHERE fl.last_count > fl.first_count %s
+			LIMIT %d
+		`, dbFilter, r.rowLimit())
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if isViewNotExistError(err) || isPermissionError(err) {
+			log.Printf("Warning: cannot read powa_wait_sampling_history, skipping wait events: %v", err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("querying powa_wait_sampling_history: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []model.WaitEventSample
+	for rows.Next() {
+		var s model.WaitEventSample
+		if err := rows.Scan(&s.QueryID, &s.SrvID, &s.DatabaseName, &s.EventType, &s.Event, &s.Samples); err != nil {
+			return nil, fmt.Errorf("scanning wait event row: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating wait event rows: %w", err)
+	}
+	r.noteRowLimit("powa_wait_sampling_history", len(samples))
+	return samples, nil
+}
@@ -0,0 +1,75 @@
+// Package retry shares one retry budget between the reader and the notifiers
+// of an analysis run, so neither can spend the run's time on retries the other
+// needs.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned by Wait when the run has no retries left, or
+// when the next attempt would start after the run's deadline; the latter also
+// matches context.DeadlineExceeded.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// budget counts the retries left in one run. It is safe for concurrent use by
+// the rules and notifiers of the run.
+type budget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+type budgetKey struct{}
+
+// WithBudget returns ctx carrying a budget of n retries shared by every Wait
+// on it. n <= 0 leaves the retries bounded by ctx's deadline only.
+func WithBudget(ctx context.Context, n int) context.Context {
+	if n <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetKey{}, &budget{remaining: n})
+}
+
+// Remaining returns the retries left in ctx's budget, or -1 when it has none.
+func Remaining(ctx context.Context) int {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+// Wait takes one retry from ctx's budget and sleeps delay before it. It
+// returns ErrBudgetExhausted without sleeping when the budget is spent or the
+// retry could not start before ctx's deadline, and ctx's error when ctx is
+// done during the sleep.
+func Wait(ctx context.Context, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return fmt.Errorf("%w: %s left in the run, retry due in %s: %w",
+			ErrBudgetExhausted, time.Until(deadline).Round(time.Millisecond), delay, context.DeadlineExceeded)
+	}
+	if b, ok := ctx.Value(budgetKey{}).(*budget); ok {
+		b.mu.Lock()
+		if b.remaining == 0 {
+			b.mu.Unlock()
+			return fmt.Errorf("%w: the run has used all its retries", ErrBudgetExhausted)
+		}
+		b.remaining--
+		b.mu.Unlock()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWait_Budget(t *testing.T) {
+	ctx := WithBudget(context.Background(), 3)
+
+	// Concurrent callers share the budget
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if Wait(ctx, time.Millisecond) == nil {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted != 3 {
+		t.Errorf("granted %d retries, want 3", granted)
+	}
+	if got := Remaining(ctx); got != 0 {
+		t.Errorf("Remaining() = %d, want 0", got)
+	}
+	if err := Wait(ctx, time.Millisecond); !errors.Is(err, ErrBudgetExhausted) || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() on a spent budget = %v, want ErrBudgetExhausted only", err)
+	}
+}
+
+func TestWait_NoBudget(t *testing.T) {
+	ctx := WithBudget(context.Background(), 0)
+	if got := Remaining(ctx); got != -1 {
+		t.Errorf("Remaining() without a budget = %d, want -1", got)
+	}
+	for range 5 {
+		if err := Wait(ctx, 0); err != nil {
+			t.Fatalf("Wait() without a budget = %v", err)
+		}
+	}
+}
+
+func TestWait_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(WithBudget(context.Background(), 5), 100*time.Millisecond)
+	defer cancel()
+
+	// A retry due after the deadline fails at once and leaves the budget alone
+	start := time.Now()
+	err := Wait(ctx, time.Second)
+	if !errors.Is(err, ErrBudgetExhausted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() past the deadline = %v, want ErrBudgetExhausted and context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Wait() took %v, want it to return without sleeping", elapsed)
+	}
+	if got := Remaining(ctx); got != 5 {
+		t.Errorf("Remaining() = %d, want 5", got)
+	}
+
+	// Cancellation during the sleep returns the context error
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := Wait(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() after cancel = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"time"
+)
+
+// failureBackoff tracks the consecutive failures of one repository.
+type failureBackoff struct {
+	failures    int
+	lastErr     string
+	nextAttempt time.Time
+}
+
+// BackoffState reports a repository whose scheduled runs are backing off
+// after consecutive failures.
+type BackoffState struct {
+	Repository          string // empty for a single repository
+	ConsecutiveFailures int
+	NextAttempt         time.Time // scheduled runs skip the repository until then
+	LastError           string
+}
+
+// SetFailureBackoff makes scheduled runs skip a repository for initial after
+// its run fails, doubling the delay with each consecutive failure up to
+// maxDelay, and logs a repeated identical failure as a short reminder instead
+// of in full. The first successful run resets it. RunNow ignores the backoff
+// but its result still counts. initial <= 0 disables it.
+func (s *Scheduler) SetFailureBackoff(initial, maxDelay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backoffInitial = initial
+	s.backoffMax = max(maxDelay, initial)
+	s.backoff = map[string]*failureBackoff{}
+}
+
+// Backoff returns the repositories with consecutive failures, by name, or nil
+// when the failure backoff is disabled.
+func (s *Scheduler) Backoff() []BackoffState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var states []BackoffState
+	for name, b := range s.backoff {
+		states = append(states, BackoffState{
+			Repository:          name,
+			ConsecutiveFailures: b.failures,
+			NextAttempt:         b.nextAttempt,
+			LastError:           b.lastErr,
+		})
+	}
+	slices.SortFunc(states, func(a, b BackoffState) int { return cmp.Compare(a.Repository, b.Repository) })
+	return states
+}
+
+// backingOff reports whether a scheduled run must skip repo because its
+// backoff delay has not elapsed yet.
+func (s *Scheduler) backingOff(repo Repository) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.backoff[repo.Name]
+	return b != nil && s.now().Before(b.nextAttempt)
+}
+
+// recordResult logs the outcome of a repository's run and updates its backoff.
+func (s *Scheduler) recordResult(repo Repository, err error) {
+	prefix := ""
+	if repo.Name != "" {
+		prefix = "[" + repo.Name + "] "
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.backoffInitial <= 0 {
+		if err != nil {
+			log.Printf("%sRun failed: %v", prefix, err)
+		}
+		return
+	}
+
+	b := s.backoff[repo.Name]
+	if err == nil {
+		if b != nil {
+			log.Printf("%sRun succeeded after %d consecutive failure(s), backoff reset", prefix, b.failures)
+			delete(s.backoff, repo.Name)
+		}
+		return
+	}
+
+	if b == nil {
+		b = &failureBackoff{}
+		s.backoff[repo.Name] = b
+	}
+	b.failures++
+	delay := s.backoffDelay(b.failures)
+	b.nextAttempt = s.now().Add(delay)
+	if msg := err.Error(); msg != b.lastErr {
+		b.lastErr = msg
+		log.Printf("%sRun failed: %v; skipping scheduled runs for %v", prefix, err, delay)
+	} else {
+		log.Printf("%sStill failing after %d consecutive runs with the same error; skipping scheduled runs for %v", prefix, b.failures, delay)
+	}
+}
+
+// backoffDelay returns the delay after the given number of consecutive
+// failures: the initial delay, doubled per further failure, capped.
+func (s *Scheduler) backoffDelay(failures int) time.Duration {
+	d := s.backoffInitial
+	for i := 1; i < failures && d < s.backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, s.backoffMax)
+}
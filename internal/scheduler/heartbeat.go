@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/powa-team/powa-sentinel/internal/config"
+)
+
+// Heartbeat pings a dead-man's-switch monitor around analysis runs. Pings are
+// best effort: failures are logged and never fail the run.
+type Heartbeat struct {
+	url, startURL, failURL string
+	timeout                time.Duration
+	client                 *http.Client
+}
+
+// NewHeartbeat returns nil when cfg has no URL, i.e. the heartbeat is disabled.
+func NewHeartbeat(cfg config.HeartbeatConfig) *Heartbeat {
+	if cfg.URL == "" {
+		return nil
+	}
+	timeout, err := cfg.TimeoutParsed()
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Heartbeat{
+		url:      cfg.URL,
+		startURL: cfg.StartURL,
+		failURL:  cfg.FailURL,
+		timeout:  timeout,
+		client:   &http.Client{},
+	}
+}
+
+// start pings the start URL, if configured.
+func (h *Heartbeat) start() {
+	if h != nil && h.startURL != "" {
+		h.ping("start", h.startURL)
+	}
+}
+
+// done pings the success URL, or the fail URL (if configured) when the run failed.
+func (h *Heartbeat) done(success bool) {
+	switch {
+	case h == nil:
+	case success:
+		h.ping("success", h.url)
+	case h.failURL != "":
+		h.ping("failure", h.failURL)
+	default:
+		log.Println("Run failed, skipping heartbeat ping")
+	}
+}
+
+// ping GETs url within the heartbeat timeout. It runs on its own context so a
+// run that timed out still reports its failure.
+func (h *Heartbeat) ping(kind, url string) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if err := h.get(ctx, url); err != nil {
+		log.Printf("Warning: heartbeat %s ping failed: %v", kind, err)
+	}
+}
+
+func (h *Heartbeat) get(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "powa-sentinel")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
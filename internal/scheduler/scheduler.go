@@ -3,7 +3,11 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,48 +15,110 @@ import (
 	"github.com/robfig/cron/v3"
 
 	"github.com/powa-team/powa-sentinel/internal/engine"
+	"github.com/powa-team/powa-sentinel/internal/model"
 	"github.com/powa-team/powa-sentinel/internal/notifier"
+	"github.com/powa-team/powa-sentinel/internal/retry"
 )
 
 // DefaultAnalysisTimeout is the default timeout for analysis runs.
 const DefaultAnalysisTimeout = 5 * time.Minute
 
+// cancelWait bounds how long Shutdown waits for a cancelled analysis to
+// return, so the caller does not close its readers under a run still using them.
+const cancelWait = 5 * time.Second
+
 // Scheduler manages scheduled analysis jobs.
 type Scheduler struct {
 	cron            *cron.Cron
-	engine          *engine.Engine
-	notifier        notifier.Notifier
+	repositories    []Repository
+	concurrency     int // repositories analyzed at once
 	analysisTimeout time.Duration
+	retryBudget     int // retries a run may spend across reader and notifiers (see retry.WithBudget)
+	alertHook       func(alert *model.AlertContext)
+	heartbeat       *Heartbeat
 
 	mu        sync.Mutex
 	running   bool
+	paused    bool  // scheduled runs are skipped while set; RunNow still runs
 	analyzing int32 // atomic flag to prevent concurrent analysis
+
+	// inflight tracks analysis runs (scheduled or RunNow) so Shutdown can wait for them;
+	// cancelRun cancels the current run's context when the shutdown budget is exhausted.
+	// runRepos and runStart describe that run for ErrShutdownTimeout; guarded by mu.
+	inflight  sync.WaitGroup
+	cancelRun context.CancelFunc
+	runRepos  []string
+	runStart  time.Time
+
+	// backoff holds the repositories that failed their last runs, by name,
+	// while SetFailureBackoff enables it; guarded by mu.
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+	backoff        map[string]*failureBackoff
+	now            func() time.Time
+}
+
+// Repository is one PoWA repository a scheduled run analyzes, with the
+// notifier its alerts are sent to.
+type Repository struct {
+	Name     string // empty for a single repository
+	Engine   *engine.Engine
+	Notifier notifier.Notifier
 }
 
 // New creates a new Scheduler. Cron expressions are interpreted in loc; use time.UTC or time.LoadLocation("Asia/Shanghai") etc.
 // If loc is nil, UTC is used.
 func New(eng *engine.Engine, notify notifier.Notifier, loc *time.Location) *Scheduler {
+	return NewForRepositories([]Repository{{Engine: eng, Notifier: notify}}, loc)
+}
+
+// NewForRepositories creates a Scheduler whose runs analyze every repository,
+// one at a time unless SetConcurrency allows more.
+func NewForRepositories(repos []Repository, loc *time.Location) *Scheduler {
 	if loc == nil {
 		loc = time.UTC
 	}
 	return &Scheduler{
 		cron:            cron.New(cron.WithSeconds(), cron.WithLocation(loc)),
-		engine:          eng,
-		notifier:        notify,
+		repositories:    repos,
+		concurrency:     1,
 		analysisTimeout: DefaultAnalysisTimeout,
+		now:             time.Now,
 	}
 }
 
+// SetConcurrency sets how many repositories a run analyzes at once; values
+// below 1 are treated as 1.
+func (s *Scheduler) SetConcurrency(n int) {
+	s.concurrency = max(n, 1)
+}
+
 // SetAnalysisTimeout sets the timeout for analysis runs.
 func (s *Scheduler) SetAnalysisTimeout(timeout time.Duration) {
 	s.analysisTimeout = timeout
 }
 
+// SetRetryBudget limits the retries each run spends across the readers and
+// notifiers of all its repositories, on top of the analysis timeout they
+// already share; n <= 0 leaves them bounded by the timeout only.
+func (s *Scheduler) SetRetryBudget(n int) {
+	s.retryBudget = n
+}
+
+// SetAlertHook registers fn to receive every successfully analyzed alert,
+// before it is sent, e.g. to refresh the health server's query metrics.
+func (s *Scheduler) SetAlertHook(fn func(alert *model.AlertContext)) {
+	s.alertHook = fn
+}
+
+// SetHeartbeat pings h around every analysis run (see NewHeartbeat); nil disables it.
+func (s *Scheduler) SetHeartbeat(h *Heartbeat) {
+	s.heartbeat = h
+}
+
 // Schedule adds a job with the given cron expression.
 func (s *Scheduler) Schedule(cronExpr string) error {
-	_, err := s.cron.AddFunc(cronExpr, func() {
-		s.runAnalysis()
-	})
+	_, err := s.cron.AddFunc(cronExpr, s.runScheduled)
 	if err != nil {
 		return err
 	}
@@ -88,14 +154,122 @@ func (s *Scheduler) Stop() context.Context {
 	return ctx
 }
 
-// RunNow triggers an immediate analysis run (bypassing schedule).
+// ErrShutdownTimeout is returned by Shutdown when an in-flight analysis did not
+// finish within the shutdown budget and had to be cancelled. The returned error
+// wraps it with the repositories the run covers and how long it had been running.
+var ErrShutdownTimeout = errors.New("shutdown timed out with analysis still in progress")
+
+// Shutdown stops the scheduler and waits for any in-flight analysis to finish.
+// If ctx expires first, the running analysis is cancelled, given up to
+// cancelWait to return, and an error wrapping ErrShutdownTimeout is returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	cronCtx := s.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		<-cronCtx.Done()
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	if !s.IsAnalyzing() {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.cancelRun != nil {
+		s.cancelRun()
+	}
+	run := describeRun(s.runRepos, s.now().Sub(s.runStart))
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return fmt.Errorf("%w (%s); cancelled", ErrShutdownTimeout, run)
+	case <-time.After(cancelWait):
+		return fmt.Errorf("%w (%s); still running %v after cancellation", ErrShutdownTimeout, run, cancelWait)
+	}
+}
+
+// describeRun names the repositories of a run, when they have names, and how
+// long it has been running.
+func describeRun(repos []string, elapsed time.Duration) string {
+	running := fmt.Sprintf("running for %v", elapsed.Round(time.Millisecond))
+	if len(repos) == 0 || repos[0] == "" {
+		return running
+	}
+	return fmt.Sprintf("repositories %s, %s", strings.Join(repos, ", "), running)
+}
+
+// Pause skips scheduled runs until Resume is called, e.g. during a maintenance
+// window. A run already in progress is not interrupted.
+func (s *Scheduler) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.paused {
+		s.paused = true
+		log.Println("Scheduled analysis paused")
+	}
+}
+
+// Resume lets scheduled runs execute again after Pause.
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paused {
+		s.paused = false
+		log.Println("Scheduled analysis resumed")
+	}
+}
+
+// IsPaused returns whether scheduled runs are currently skipped.
+func (s *Scheduler) IsPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// RunNow triggers an immediate analysis run (bypassing schedule). It runs even
+// while the scheduler is paused.
 func (s *Scheduler) RunNow() {
-	s.runAnalysis()
+	s.runAnalysis(false)
+}
+
+// runScheduled is the cron job: it runs the analysis unless the scheduler is paused.
+func (s *Scheduler) runScheduled() {
+	if s.IsPaused() {
+		log.Println("Scheduler paused, skipping scheduled analysis")
+		return
+	}
+	s.runAnalysis(true)
 }
 
-// runAnalysis executes the analysis and sends notifications.
+// runAnalysis executes the analysis and sends notifications. A scheduled run
+// skips the repositories backing off after failures, and does nothing when
+// they all are.
 // Uses atomic flag to prevent concurrent analysis runs.
-func (s *Scheduler) runAnalysis() {
+func (s *Scheduler) runAnalysis(scheduled bool) {
+	repos := s.repositories
+	if scheduled {
+		repos = slices.DeleteFunc(slices.Clone(repos), s.backingOff)
+		if len(repos) == 0 {
+			return
+		}
+	}
+
 	// Check if analysis is already running (skip if so)
 	if !atomic.CompareAndSwapInt32(&s.analyzing, 0, 1) {
 		log.Println("Analysis already in progress, skipping this run")
@@ -103,35 +277,86 @@ func (s *Scheduler) runAnalysis() {
 	}
 	defer atomic.StoreInt32(&s.analyzing, 0)
 
+	s.inflight.Add(1)
+	defer s.inflight.Done()
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), s.analysisTimeout)
 	defer cancel()
+	ctx = retry.WithBudget(ctx, s.retryBudget)
+
+	s.mu.Lock()
+	s.cancelRun = cancel
+	s.runRepos = s.runRepos[:0]
+	for _, repo := range repos {
+		s.runRepos = append(s.runRepos, repo.Name)
+	}
+	s.runStart = s.now()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancelRun = nil
+		s.mu.Unlock()
+	}()
 
 	log.Println("Starting scheduled analysis...")
+	s.heartbeat.start()
+
+	// The run succeeds, for the heartbeat, only if every repository does;
+	// one skipped while backing off has not recovered yet
+	var failed atomic.Bool
+	failed.Store(len(repos) < len(s.repositories))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.concurrency)
+	for _, repo := range repos {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			err := s.analyzeRepository(ctx, repo)
+			s.recordResult(repo, err)
+			if err != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	s.heartbeat.done(!failed.Load())
+}
+
+// analyzeRepository analyzes one repository and sends its alert, returning
+// the failure of either; recordResult logs it. Log lines name the repository
+// when it has a name.
+func (s *Scheduler) analyzeRepository(ctx context.Context, repo Repository) error {
+	prefix := ""
+	if repo.Name != "" {
+		prefix = "[" + repo.Name + "] "
+	}
 
-	alert, err := s.engine.Analyze(ctx)
+	alert, err := repo.Engine.Analyze(ctx)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Analysis timed out after %v", s.analysisTimeout)
-		} else {
-			log.Printf("Analysis failed: %v", err)
+			return fmt.Errorf("analysis timed out after %v", s.analysisTimeout)
 		}
-		return
+		return fmt.Errorf("analysis failed: %w", err)
 	}
 
-	log.Printf("Analysis complete: %d slow queries, %d regressions, %d suggestions",
-		len(alert.TopSlowSQL), len(alert.Regressions), len(alert.Suggestions))
+	log.Printf("%sAnalysis complete: %d slow queries, %d regressions, %d suggestions",
+		prefix, len(alert.TopSlowSQL), len(alert.Regressions), len(alert.Suggestions))
+	if s.alertHook != nil {
+		s.alertHook(alert)
+	}
 
-	if err := s.notifier.Send(ctx, alert); err != nil {
+	if err := repo.Notifier.Send(ctx, alert); err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Notification timed out")
-		} else {
-			log.Printf("Notification failed: %v", err)
+			return errors.New("notification timed out")
 		}
-		return
+		return fmt.Errorf("notification failed: %w", err)
 	}
 
-	log.Printf("Notification sent via %s", s.notifier.Name())
+	repo.Engine.RecordDelivery(alert)
+	log.Printf("%sNotification sent via %s", prefix, repo.Notifier.Name())
+	return nil
 }
 
 // IsRunning returns whether the scheduler is currently active.
@@ -1,13 +1,23 @@
 package scheduler
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
 	"github.com/powa-team/powa-sentinel/internal/engine"
 	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/notifier"
+	"github.com/powa-team/powa-sentinel/internal/retry"
 )
 
 // mockNotifier implements notifier.Notifier for testing
@@ -24,6 +34,41 @@ func (m *mockNotifier) Name() string {
 	return "mock"
 }
 
+// blockingReader implements engine.MetricsReader and blocks until released or cancelled.
+type blockingReader struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingReader() *blockingReader {
+	return &blockingReader{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (b *blockingReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	close(b.started)
+	select {
+	case <-b.release:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *blockingReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, nil
+}
+
+func (b *blockingReader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error) {
+	return nil, nil
+}
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Analysis: config.AnalysisConfig{WindowDuration: "1h", ComparisonOffset: "24h"},
+		Rules:    config.RulesConfig{SlowSQL: config.SlowSQLRuleConfig{TopN: 5}},
+	}
+}
+
 // TestScheduler_Concurrency ensures analysis runs are skipped if one is already in progress
 func TestScheduler_Concurrency(t *testing.T) {
 	// Setup with a minimal config (no DB reader needed for this test logic structure,
@@ -124,3 +169,454 @@ func TestScheduler_TimeoutConfig(t *testing.T) {
 		t.Errorf("Timeout = %v, want %v", sched.analysisTimeout, newTimeout)
 	}
 }
+
+func TestScheduler_Shutdown_WaitsForInflightAnalysis(t *testing.T) {
+	r := newBlockingReader()
+	notify := &mockNotifier{}
+	sched := New(engine.New(newTestConfig(), r), notify, time.UTC)
+
+	done := make(chan struct{})
+	go func() {
+		sched.RunNow()
+		close(done)
+	}()
+	<-r.started
+
+	// Release the analysis shortly after shutdown begins; it should complete
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(r.release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sched.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v, want nil", err)
+	}
+	<-done
+
+	if notify.sentCount != 1 {
+		t.Errorf("expected in-flight analysis to notify once, got %d", notify.sentCount)
+	}
+}
+
+func TestScheduler_Shutdown_CancelsOnTimeout(t *testing.T) {
+	r := newBlockingReader()
+	notify := &mockNotifier{}
+	sched := New(engine.New(newTestConfig(), r), notify, time.UTC)
+
+	done := make(chan struct{})
+	go func() {
+		sched.RunNow()
+		close(done)
+	}()
+	<-r.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := sched.Shutdown(ctx)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Shutdown() error = %v, want ErrShutdownTimeout", err)
+	}
+	if !strings.Contains(err.Error(), "running for") || !strings.HasSuffix(err.Error(), "; cancelled") {
+		t.Errorf("Shutdown() error = %q, want how long the run had been going and that it was cancelled", err)
+	}
+
+	// Shutdown waits for the cancelled run, so the caller can close its readers
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Shutdown returned before the cancelled analysis did")
+	}
+	if notify.sentCount != 0 {
+		t.Errorf("cancelled analysis should not notify, got %d sends", notify.sentCount)
+	}
+}
+
+// emptyReader implements engine.MetricsReader with no data.
+type emptyReader struct{}
+
+func (emptyReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, nil
+}
+
+func (emptyReader) GetBaselineMetrics(ctx context.Context, offset, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, nil
+}
+
+func (emptyReader) GetIndexSuggestions(ctx context.Context) ([]model.IndexSuggestion, error) {
+	return nil, nil
+}
+
+func TestScheduler_PauseResume(t *testing.T) {
+	notify := &mockNotifier{}
+	sched := New(engine.New(newTestConfig(), emptyReader{}), notify, time.UTC)
+
+	if sched.IsPaused() {
+		t.Fatal("new scheduler should not be paused")
+	}
+
+	sched.runScheduled()
+	if notify.sentCount != 1 {
+		t.Fatalf("scheduled tick before pause: sent %d, want 1", notify.sentCount)
+	}
+
+	sched.Pause()
+	sched.Pause() // idempotent
+	if !sched.IsPaused() {
+		t.Fatal("IsPaused() = false after Pause()")
+	}
+	sched.runScheduled()
+	sched.runScheduled()
+	if notify.sentCount != 1 {
+		t.Errorf("scheduled ticks while paused should be skipped, sent %d", notify.sentCount)
+	}
+
+	// A manual run is not affected by the pause
+	sched.RunNow()
+	if notify.sentCount != 2 {
+		t.Errorf("RunNow while paused: sent %d, want 2", notify.sentCount)
+	}
+
+	sched.Resume()
+	if sched.IsPaused() {
+		t.Fatal("IsPaused() = true after Resume()")
+	}
+	sched.runScheduled()
+	if notify.sentCount != 3 {
+		t.Errorf("scheduled tick after resume: sent %d, want 3", notify.sentCount)
+	}
+}
+
+// failingReader implements engine.MetricsReader and fails every fetch.
+type failingReader struct{ emptyReader }
+
+func (failingReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestScheduler_Heartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pings = append(pings, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	takePings := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := pings
+		pings = nil
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		reader engine.MetricsReader
+		cfg    config.HeartbeatConfig
+		want   []string
+	}{
+		{"success", emptyReader{}, config.HeartbeatConfig{URL: srv.URL + "/ok"}, []string{"/ok"}},
+		{"success with start", emptyReader{}, config.HeartbeatConfig{URL: srv.URL + "/ok", StartURL: srv.URL + "/start"}, []string{"/start", "/ok"}},
+		{"failure skipped", failingReader{}, config.HeartbeatConfig{URL: srv.URL + "/ok"}, nil},
+		{"failure reported", failingReader{}, config.HeartbeatConfig{URL: srv.URL + "/ok", FailURL: srv.URL + "/fail"}, []string{"/fail"}},
+	}
+	for _, tt := range tests {
+		tt.cfg.Timeout = "1s"
+		sched := New(engine.New(newTestConfig(), tt.reader), &mockNotifier{}, time.UTC)
+		sched.SetHeartbeat(NewHeartbeat(tt.cfg))
+		sched.RunNow()
+
+		got := takePings()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: pings = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: pings = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestScheduler_HeartbeatFailureDoesNotFailRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	notify := &mockNotifier{}
+	sched := New(engine.New(newTestConfig(), emptyReader{}), notify, time.UTC)
+	sched.SetHeartbeat(NewHeartbeat(config.HeartbeatConfig{URL: srv.URL, StartURL: srv.URL, Timeout: "1s"}))
+	sched.RunNow()
+	if notify.sentCount != 1 {
+		t.Errorf("run with a failing heartbeat: sent %d, want 1", notify.sentCount)
+	}
+	if NewHeartbeat(config.HeartbeatConfig{}) != nil {
+		t.Error("NewHeartbeat() without url should be nil")
+	}
+}
+
+// recordingNotifier keeps the alerts it was sent.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []*model.AlertContext
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func (n *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func TestScheduler_Repositories(t *testing.T) {
+	newRepo := func(name string, r engine.MetricsReader) (Repository, *recordingNotifier) {
+		eng := engine.New(newTestConfig(), r)
+		eng.SetRepository(name)
+		n := &recordingNotifier{}
+		return Repository{Name: name, Engine: eng, Notifier: n}, n
+	}
+	eu, euSent := newRepo("eu", emptyReader{})
+	us, usSent := newRepo("us", emptyReader{})
+	sched := NewForRepositories([]Repository{eu, us}, time.UTC)
+	sched.SetConcurrency(2)
+	sched.RunNow()
+
+	// Each repository's alert goes to its own notifier, tagged with its name
+	for name, n := range map[string]*recordingNotifier{"eu": euSent, "us": usSent} {
+		if len(n.alerts) != 1 || n.alerts[0].Repository != name {
+			t.Errorf("%s notifier got %+v, want one alert tagged %s", name, n.alerts, name)
+		}
+	}
+
+	// One failing repository fails the run for the heartbeat, the other still notifies
+	var mu sync.Mutex
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pings = append(pings, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	down, downSent := newRepo("down", failingReader{})
+	up, upSent := newRepo("up", emptyReader{})
+	sched = NewForRepositories([]Repository{down, up}, time.UTC)
+	sched.SetHeartbeat(NewHeartbeat(config.HeartbeatConfig{URL: srv.URL + "/ok", FailURL: srv.URL + "/fail", Timeout: "1s"}))
+	sched.RunNow()
+	if len(downSent.alerts) != 0 || len(upSent.alerts) != 1 {
+		t.Errorf("sent down=%d up=%d, want 0 and 1", len(downSent.alerts), len(upSent.alerts))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pings) != 1 || pings[0] != "/fail" {
+		t.Errorf("heartbeat pings = %v, want [/fail]", pings)
+	}
+}
+
+func TestScheduler_RepositoryConcurrency(t *testing.T) {
+	first, second := newBlockingReader(), newBlockingReader()
+	repos := []Repository{
+		{Name: "first", Engine: engine.New(newTestConfig(), first), Notifier: &mockNotifier{}},
+		{Name: "second", Engine: engine.New(newTestConfig(), second), Notifier: &mockNotifier{}},
+	}
+	sched := NewForRepositories(repos, time.UTC)
+	sched.SetConcurrency(1)
+
+	done := make(chan struct{})
+	go func() {
+		sched.RunNow()
+		close(done)
+	}()
+
+	<-first.started
+	select {
+	case <-second.started:
+		t.Fatal("second repository started while the first held the only slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(first.release)
+	select {
+	case <-second.started:
+	case <-time.After(time.Second):
+		t.Fatal("second repository never started")
+	}
+	close(second.release)
+	<-done
+}
+
+func TestScheduler_BelowNotificationThreshold(t *testing.T) {
+	// The alert of a quiet run is held back by notifier.min_findings, but the
+	// health server still records it
+	sent := &recordingNotifier{}
+	sched := New(engine.New(newTestConfig(), emptyReader{}), notifier.NewThresholdNotifier(sent, 1, ""), time.UTC)
+	var recorded []*model.AlertContext
+	sched.SetAlertHook(func(alert *model.AlertContext) { recorded = append(recorded, alert) })
+	sched.RunNow()
+
+	if len(sent.alerts) != 0 {
+		t.Errorf("sent %d alerts below the threshold, want 0", len(sent.alerts))
+	}
+	if len(recorded) != 1 {
+		t.Errorf("alert hook saw %d alerts, want 1", len(recorded))
+	}
+}
+
+// retryingReader retries its current metrics query until the run allows no
+// more retries, then succeeds.
+type retryingReader struct {
+	emptyReader
+	delay   time.Duration
+	retries int
+}
+
+func (r *retryingReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	for retry.Wait(ctx, r.delay) == nil {
+		r.retries++
+	}
+	return nil, nil
+}
+
+// retryingNotifier records whether the run allowed it a retry.
+type retryingNotifier struct {
+	delay time.Duration
+	err   error
+}
+
+func (n *retryingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	n.err = retry.Wait(ctx, n.delay)
+	return n.err
+}
+
+func (n *retryingNotifier) Name() string {
+	return "retrying"
+}
+
+func TestScheduler_RetryBudget(t *testing.T) {
+	// The reader spends the whole budget, leaving the notifier no retry
+	r := &retryingReader{delay: time.Millisecond}
+	n := &retryingNotifier{delay: time.Millisecond}
+	sched := New(engine.New(newTestConfig(), r), n, time.UTC)
+	sched.SetRetryBudget(3)
+	sched.RunNow()
+
+	if r.retries != 3 {
+		t.Errorf("reader retried %d times, want the budget of 3", r.retries)
+	}
+	if !errors.Is(n.err, retry.ErrBudgetExhausted) {
+		t.Errorf("notifier retry error = %v, want retry.ErrBudgetExhausted", n.err)
+	}
+
+	// Without a retry budget, the reader retries until the next retry would
+	// overrun the timeout, and the notifier gives up at once instead of waiting
+	// for the deadline
+	r = &retryingReader{delay: 50 * time.Millisecond}
+	n = &retryingNotifier{delay: 50 * time.Millisecond}
+	sched = New(engine.New(newTestConfig(), r), n, time.UTC)
+	sched.SetAnalysisTimeout(300 * time.Millisecond)
+	start := time.Now()
+	sched.RunNow()
+
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("run took %v, want it within the 300ms timeout", elapsed)
+	}
+	if r.retries == 0 || r.retries > 5 {
+		t.Errorf("reader retried %d times, want between 1 and 5 within the timeout", r.retries)
+	}
+	if !errors.Is(n.err, retry.ErrBudgetExhausted) || !errors.Is(n.err, context.DeadlineExceeded) {
+		t.Errorf("notifier retry error = %v, want the budget exhausted by the deadline", n.err)
+	}
+}
+
+// flakyReader fails every fetch while down is set and counts the fetches.
+type flakyReader struct {
+	emptyReader
+	down  bool
+	calls int
+}
+
+func (r *flakyReader) GetCurrentMetrics(ctx context.Context, window time.Duration) ([]model.MetricSnapshot, error) {
+	r.calls++
+	if r.down {
+		return nil, errors.New("connection refused")
+	}
+	return nil, nil
+}
+
+func TestScheduler_FailureBackoff(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	reader := &flakyReader{down: true}
+	notify := &mockNotifier{}
+	sched := New(engine.New(newTestConfig(), reader), notify, time.UTC)
+	sched.SetFailureBackoff(time.Minute, 5*time.Minute)
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	sched.now = func() time.Time { return now }
+
+	// Each failure doubles the delay up to the cap; ticks in between skip the repository
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		sched.runScheduled()
+		if reader.calls != i+1 {
+			t.Fatalf("failure %d: reader called %d times, want %d", i+1, reader.calls, i+1)
+		}
+		state := sched.Backoff()
+		if len(state) != 1 || state[0].ConsecutiveFailures != i+1 || !state[0].NextAttempt.Equal(now.Add(want)) {
+			t.Fatalf("failure %d: backoff = %+v, want next attempt in %v", i+1, state, want)
+		}
+		now = now.Add(want - time.Second)
+		sched.runScheduled()
+		if reader.calls != i+1 {
+			t.Fatalf("failure %d: tick before the delay elapsed ran the analysis", i+1)
+		}
+		now = now.Add(time.Second)
+	}
+
+	// The error is logged in full once, then as reminders
+	if got := strings.Count(logs.String(), "connection refused"); got != 1 {
+		t.Errorf("full failure logged %d times, want 1:\n%s", got, logs.String())
+	}
+	if got := strings.Count(logs.String(), "Still failing after"); got != 4 {
+		t.Errorf("got %d reminders, want 4:\n%s", got, logs.String())
+	}
+
+	// A manual run ignores the backoff, and its success resets it
+	now = now.Add(time.Second)
+	sched.runScheduled()
+	reader.down = false
+	sched.RunNow()
+	if reader.calls != 7 || notify.sentCount != 1 {
+		t.Errorf("reader called %d times and sent %d alert(s), want 7 and 1", reader.calls, notify.sentCount)
+	}
+	if state := sched.Backoff(); len(state) != 0 {
+		t.Errorf("backoff after a success = %+v, want none", state)
+	}
+	sched.runScheduled()
+	if notify.sentCount != 2 {
+		t.Errorf("scheduled tick after recovery sent %d alert(s), want 2", notify.sentCount)
+	}
+}
+
+func TestDescribeRun(t *testing.T) {
+	tests := []struct {
+		repos []string
+		want  string
+	}{
+		{[]string{""}, "running for 1m30s"},
+		{[]string{"eu", "us"}, "repositories eu, us, running for 1m30s"},
+	}
+	for _, tt := range tests {
+		if got := describeRun(tt.repos, 90*time.Second); got != tt.want {
+			t.Errorf("describeRun(%q) = %q, want %q", tt.repos, got, tt.want)
+		}
+	}
+}
@@ -5,24 +5,67 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/notifier"
 	"github.com/powa-team/powa-sentinel/internal/reader"
+	"github.com/powa-team/powa-sentinel/internal/scheduler"
 )
 
+// notifierProbeTimeout bounds a single notifier reachability probe.
+const notifierProbeTimeout = 10 * time.Second
+
 // Server provides HTTP endpoints for health checks and monitoring.
 type Server struct {
 	cfg      *config.ServerConfig
-	reader   *reader.Reader
+	repos    []repository // checked by /readyz, /status and deep /healthz
 	server   *http.Server
+	pprof    *http.Server // nil unless server.pprof_addr is set
 	mu       sync.Mutex
 	started  time.Time
 	healthy  bool
 	lastPing time.Time
+
+	prober         notifier.Prober
+	proberName     string
+	probeInterval  time.Duration
+	notifierHealth *NotifierHealth
+	stopProbes     context.CancelFunc
+
+	pauser Pauser
+
+	sendMetrics *notifier.SendMetrics // nil until SetSendMetrics
+
+	queryMetrics map[string][]model.MetricSnapshot // top slow queries of each repository's last run, exported by /metrics
+}
+
+// repository is one PoWA repository the server checks; name is empty for a
+// single repository.
+type repository struct {
+	name   string
+	reader *reader.Reader
+}
+
+// Pauser pauses and resumes scheduled analysis; *scheduler.Scheduler implements it.
+type Pauser interface {
+	Pause()
+	Resume()
+	IsPaused() bool
+}
+
+// BackoffReporter is implemented by a Pauser whose scheduled runs back off
+// after failures; *scheduler.Scheduler implements it.
+type BackoffReporter interface {
+	Backoff() []scheduler.BackoffState
 }
 
 // HealthResponse represents the health check response.
@@ -33,6 +76,51 @@ type HealthResponse struct {
 	Database  *DBHealth `json:"database,omitempty"`
 }
 
+// StatusResponse is the /status body: uptime plus the state of every dependency.
+type StatusResponse struct {
+	Status    string          `json:"status"`
+	Uptime    string          `json:"uptime"`
+	Timestamp time.Time       `json:"timestamp"`
+	Database  *DBHealth       `json:"database,omitempty"`
+	Notifier  *NotifierHealth `json:"notifier,omitempty"`
+	Scheduler *SchedulerState `json:"scheduler,omitempty"`
+	RowLimit  []RowLimitHit   `json:"row_limit_hits,omitempty"`
+}
+
+// RowLimitHit reports a reader query that last returned database.max_query_rows
+// rows, so its results were probably truncated.
+type RowLimitHit struct {
+	Repository string    `json:"repository,omitempty"`
+	Source     string    `json:"source"`
+	Limit      int       `json:"limit"`
+	At         time.Time `json:"at"`
+}
+
+// SchedulerState reports whether scheduled analysis is paused, and which
+// repositories it skips after consecutive failures.
+type SchedulerState struct {
+	Paused  bool                `json:"paused"`
+	Backoff []RepositoryBackoff `json:"backoff,omitempty"`
+}
+
+// RepositoryBackoff reports a repository whose scheduled runs are backing off
+// after consecutive failures (see schedule.failure_backoff).
+type RepositoryBackoff struct {
+	Repository          string    `json:"repository,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	NextAttempt         time.Time `json:"next_attempt"`
+	LastError           string    `json:"last_error"`
+}
+
+// NotifierHealth is the result of the last notifier reachability probe.
+type NotifierHealth struct {
+	Name      string    `json:"name"`
+	Reachable bool      `json:"reachable"`
+	CheckedAt time.Time `json:"checked_at"`
+	Latency   string    `json:"latency,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
 // DBHealth represents database connectivity status.
 type DBHealth struct {
 	Connected bool   `json:"connected"`
@@ -40,13 +128,62 @@ type DBHealth struct {
 	Error     string `json:"error,omitempty"`
 }
 
-// New creates a new Server.
+// New creates a new Server checking the repository r reads; r may be nil.
 func New(cfg *config.ServerConfig, r *reader.Reader) *Server {
-	return &Server{
+	s := &Server{
 		cfg:     cfg,
-		reader:  r,
 		healthy: true,
 	}
+	if r != nil {
+		s.repos = []repository{{reader: r}}
+	}
+	return s
+}
+
+// AddRepository also checks the named repository r reads, when database is a
+// list. It must be called before Start.
+func (s *Server) AddRepository(name string, r *reader.Reader) {
+	s.repos = append(s.repos, repository{name: name, reader: r})
+}
+
+// SetNotifierProbe enables the periodic reachability probe of the named
+// notifier. It must be called before Start; a non-positive interval disables it.
+func (s *Server) SetNotifierProbe(name string, p notifier.Prober, interval time.Duration) {
+	s.proberName = name
+	s.prober = p
+	s.probeInterval = interval
+}
+
+// SetPauser enables POST /pause and POST /resume for scheduled analysis and
+// reports the state in /status. It must be called before Start.
+func (s *Server) SetPauser(p Pauser) {
+	s.pauser = p
+}
+
+// SetSendMetrics exports the notifier send histogram and failure counter in
+// /metrics. It must be called before Start.
+func (s *Server) SetSendMetrics(m *notifier.SendMetrics) {
+	s.sendMetrics = m
+}
+
+// RecordAlert replaces the per-query gauges with the top server.query_metrics
+// slow queries of alert, so series of queries that dropped out disappear.
+func (s *Server) RecordAlert(alert *model.AlertContext) {
+	limit := s.cfg.QueryMetrics
+	if limit <= 0 || alert == nil {
+		return
+	}
+	queries := alert.TopSlowSQL
+	if len(queries) > limit {
+		queries = queries[:limit]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queryMetrics == nil {
+		s.queryMetrics = make(map[string][]model.MetricSnapshot)
+	}
+	s.queryMetrics[alert.Repository] = append([]model.MetricSnapshot(nil), queries...)
 }
 
 // Start begins serving HTTP requests.
@@ -58,6 +195,12 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/readyz", s.handleReady)
 	mux.HandleFunc("/livez", s.handleLive)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	if s.pauser != nil {
+		mux.HandleFunc("/pause", s.handlePause)
+		mux.HandleFunc("/resume", s.handleResume)
+	}
 
 	s.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.cfg.Port),
@@ -66,14 +209,32 @@ func (s *Server) Start() error {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	if s.cfg.PprofAddr != "" {
+		s.pprof = newPprofServer(s.cfg.PprofAddr)
+	}
+
 	s.started = time.Now()
 
+	if s.prober != nil && s.probeInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopProbes = cancel
+		go s.runNotifierProbes(ctx)
+	}
+
 	go func() {
 		log.Printf("Health server listening on :%d", s.cfg.Port)
 		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
 			log.Printf("Health server error: %v", err)
 		}
 	}()
+	if s.pprof != nil {
+		go func() {
+			log.Printf("WARNING: pprof debug server listening on %s; do not expose it outside a debugging session", s.cfg.PprofAddr)
+			if err := s.pprof.ListenAndServe(); err != http.ErrServerClosed {
+				log.Printf("pprof server error: %v", err)
+			}
+		}()
+	}
 
 	return nil
 }
@@ -83,9 +244,16 @@ func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.stopProbes != nil {
+		s.stopProbes()
+	}
 	if s.server == nil {
 		return nil
 	}
+	if s.pprof != nil {
+		// Profiles in progress are not worth delaying shutdown for
+		s.pprof.Close()
+	}
 
 	return s.server.Shutdown(ctx)
 }
@@ -99,7 +267,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Perform deep check if enabled
-	if s.cfg.DeepCheck && s.reader != nil {
+	if s.cfg.DeepCheck && len(s.repos) > 0 {
 		dbHealth := s.checkDatabase(r.Context())
 		response.Database = dbHealth
 		if !dbHealth.Connected {
@@ -118,7 +286,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 // handleReady handles /readyz endpoint (readiness probe).
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	// Check if we can connect to the database
-	if s.reader != nil {
+	if len(s.repos) > 0 {
 		dbHealth := s.checkDatabase(r.Context())
 		if !dbHealth.Connected {
 			s.writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
@@ -146,20 +314,257 @@ func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// checkDatabase tests database connectivity.
-func (s *Server) checkDatabase(ctx context.Context) *DBHealth {
-	health := &DBHealth{}
+// handleStatus handles /status: a summary of the database and notifier checks
+// for humans and dashboards. It always answers 200; probes use /readyz.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	response := StatusResponse{
+		Status:    "ok",
+		Timestamp: time.Now(),
+		Uptime:    time.Since(s.started).Round(time.Second).String(),
+	}
+	if len(s.repos) > 0 {
+		response.Database = s.checkDatabase(r.Context())
+		if !response.Database.Connected {
+			response.Status = "degraded"
+		}
+	}
+	if nh := s.lastNotifierHealth(); nh != nil {
+		response.Notifier = nh
+		if !nh.Reachable {
+			response.Status = "degraded"
+		}
+	}
+	if s.pauser != nil {
+		response.Scheduler = &SchedulerState{Paused: s.pauser.IsPaused()}
+		if b, ok := s.pauser.(BackoffReporter); ok {
+			for _, state := range b.Backoff() {
+				response.Scheduler.Backoff = append(response.Scheduler.Backoff, RepositoryBackoff(state))
+			}
+		}
+	}
+	response.RowLimit = s.rowLimitHits()
+	s.writeJSON(w, http.StatusOK, response)
+}
 
-	start := time.Now()
-	err := s.reader.Ping(ctx)
-	latency := time.Since(start)
+// handlePause handles POST /pause: scheduled runs are skipped until /resume.
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// handleResume handles POST /resume.
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
 
+// setPaused applies a pause or resume request and answers with the new state.
+func (s *Server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if paused {
+		s.pauser.Pause()
+	} else {
+		s.pauser.Resume()
+	}
+	s.writeJSON(w, http.StatusOK, SchedulerState{Paused: s.pauser.IsPaused()})
+}
+
+// handleMetrics handles /metrics in the Prometheus text exposition format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP powa_sentinel_uptime_seconds Seconds since the health server started.\n")
+	fmt.Fprintf(w, "# TYPE powa_sentinel_uptime_seconds gauge\n")
+	fmt.Fprintf(w, "powa_sentinel_uptime_seconds %d\n", int64(time.Since(s.started).Seconds()))
+
+	if len(s.repos) > 0 {
+		var qs reader.QueryStats
+		for _, repo := range s.repos {
+			rs := repo.reader.QueryStats()
+			qs.InFlight += rs.InFlight
+			qs.Queued += rs.Queued
+		}
+		fmt.Fprintf(w, "# HELP powa_sentinel_db_queries_in_flight Reader calls currently holding a database.max_concurrent_queries slot.\n")
+		fmt.Fprintf(w, "# TYPE powa_sentinel_db_queries_in_flight gauge\n")
+		fmt.Fprintf(w, "powa_sentinel_db_queries_in_flight %d\n", qs.InFlight)
+		fmt.Fprintf(w, "# HELP powa_sentinel_db_queries_queued Reader calls waiting for a database.max_concurrent_queries slot.\n")
+		fmt.Fprintf(w, "# TYPE powa_sentinel_db_queries_queued gauge\n")
+		fmt.Fprintf(w, "powa_sentinel_db_queries_queued %d\n", qs.Queued)
+	}
+
+	if nh := s.lastNotifierHealth(); nh != nil {
+		up := 0
+		if nh.Reachable {
+			up = 1
+		}
+		fmt.Fprintf(w, "# HELP powa_sentinel_notifier_up Whether the last notifier reachability probe succeeded.\n")
+		fmt.Fprintf(w, "# TYPE powa_sentinel_notifier_up gauge\n")
+		fmt.Fprintf(w, "powa_sentinel_notifier_up{notifier=%q} %d\n", nh.Name, up)
+		fmt.Fprintf(w, "# HELP powa_sentinel_notifier_probe_timestamp_seconds Unix time of the last notifier probe.\n")
+		fmt.Fprintf(w, "# TYPE powa_sentinel_notifier_probe_timestamp_seconds gauge\n")
+		fmt.Fprintf(w, "powa_sentinel_notifier_probe_timestamp_seconds{notifier=%q} %d\n", nh.Name, nh.CheckedAt.Unix())
+	}
+
+	if s.sendMetrics != nil {
+		writeSendMetrics(w, s.sendMetrics.Snapshot())
+	}
+
+	// Series of every repository, in name order
+	type series struct {
+		labels string
+		q      model.MetricSnapshot
+	}
+	var queries []series
+	s.mu.Lock()
+	for _, repo := range slices.Sorted(maps.Keys(s.queryMetrics)) {
+		for _, q := range s.queryMetrics[repo] {
+			queries = append(queries, series{queryLabels(repo, q), q})
+		}
+	}
+	s.mu.Unlock()
+	if len(queries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP powa_sentinel_query_mean_time_ms Mean execution time of the top slow queries in the last analysis window.\n")
+	fmt.Fprintf(w, "# TYPE powa_sentinel_query_mean_time_ms gauge\n")
+	for _, sr := range queries {
+		fmt.Fprintf(w, "powa_sentinel_query_mean_time_ms{%s} %g\n", sr.labels, sr.q.MeanTime)
+	}
+	fmt.Fprintf(w, "# HELP powa_sentinel_query_total_time_ms Total execution time of the top slow queries in the last analysis window.\n")
+	fmt.Fprintf(w, "# TYPE powa_sentinel_query_total_time_ms gauge\n")
+	for _, sr := range queries {
+		fmt.Fprintf(w, "powa_sentinel_query_total_time_ms{%s} %g\n", sr.labels, sr.q.TotalTime)
+	}
+}
+
+// writeSendMetrics writes the notifier send histogram and failure counter.
+// Notifiers appear once they have sent.
+func writeSendMetrics(w io.Writer, stats []notifier.SendStats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP powa_sentinel_notify_duration_seconds Duration of notifier sends, retries included.\n")
+	fmt.Fprintf(w, "# TYPE powa_sentinel_notify_duration_seconds histogram\n")
+	for _, st := range stats {
+		for i, bound := range notifier.SendDurationBuckets {
+			fmt.Fprintf(w, "powa_sentinel_notify_duration_seconds_bucket{notifier=%q,le=\"%g\"} %d\n", st.Notifier, bound, st.Buckets[i])
+		}
+		fmt.Fprintf(w, "powa_sentinel_notify_duration_seconds_bucket{notifier=%q,le=\"+Inf\"} %d\n", st.Notifier, st.Count)
+		fmt.Fprintf(w, "powa_sentinel_notify_duration_seconds_sum{notifier=%q} %g\n", st.Notifier, st.Sum)
+		fmt.Fprintf(w, "powa_sentinel_notify_duration_seconds_count{notifier=%q} %d\n", st.Notifier, st.Count)
+	}
+	fmt.Fprintf(w, "# HELP powa_sentinel_notify_failures_total Notifier sends that failed after all retries.\n")
+	fmt.Fprintf(w, "# TYPE powa_sentinel_notify_failures_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "powa_sentinel_notify_failures_total{notifier=%q} %d\n", st.Notifier, st.Failures)
+	}
+}
+
+// queryLabels renders the label set identifying a query series; queries of a
+// role (analysis.group_by_user) also carry its oid, and queries of a named
+// repository its name.
+func queryLabels(repository string, q model.MetricSnapshot) string {
+	labels := fmt.Sprintf("queryid=\"%d\",database=%q,server=%q", q.QueryID, q.DatabaseName, q.ServerName)
+	if q.UserID != 0 {
+		labels += fmt.Sprintf(",userid=\"%d\"", q.UserID)
+	}
+	if repository != "" {
+		labels += fmt.Sprintf(",repository=%q", repository)
+	}
+	return labels
+}
+
+// runNotifierProbes probes the notifier right away and then every probeInterval until ctx is done.
+func (s *Server) runNotifierProbes(ctx context.Context) {
+	ticker := time.NewTicker(s.probeInterval)
+	defer ticker.Stop()
+	for {
+		s.probeNotifier(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeNotifier runs one reachability probe and records the result.
+func (s *Server) probeNotifier(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, notifierProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := s.prober.Probe(ctx)
+	health := &NotifierHealth{Name: s.proberName, Reachable: err == nil, CheckedAt: time.Now()}
 	if err != nil {
-		health.Connected = false
 		health.Error = err.Error()
 	} else {
-		health.Connected = true
-		health.Latency = latency.String()
+		health.Latency = time.Since(start).String()
+	}
+
+	s.mu.Lock()
+	prev := s.notifierHealth
+	s.notifierHealth = health
+	s.mu.Unlock()
+
+	// Log transitions only, so a long outage is not repeated every interval
+	if err != nil && (prev == nil || prev.Reachable) {
+		log.Printf("Warning: notifier %s is unreachable: %v", s.proberName, err)
+	} else if err == nil && prev != nil && !prev.Reachable {
+		log.Printf("Notifier %s is reachable again", s.proberName)
+	}
+}
+
+// lastNotifierHealth returns the latest probe result, or nil before the first probe.
+func (s *Server) lastNotifierHealth() *NotifierHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notifierHealth
+}
+
+// rowLimitHits lists the reader queries of every repository that hit
+// database.max_query_rows, by repository and then source.
+func (s *Server) rowLimitHits() []RowLimitHit {
+	var hits []RowLimitHit
+	for _, repo := range s.repos {
+		last := repo.reader.RowLimitHits()
+		for _, source := range slices.Sorted(maps.Keys(last)) {
+			hits = append(hits, RowLimitHit{Repository: repo.name, Source: source, Limit: repo.reader.RowLimit(), At: last[source]})
+		}
+	}
+	return hits
+}
+
+// checkDatabase tests database connectivity. With several repositories it is
+// connected only when every one is; the latency is the slowest ping and the
+// error names each repository that failed.
+func (s *Server) checkDatabase(ctx context.Context) *DBHealth {
+	health := &DBHealth{Connected: true}
+
+	var slowest time.Duration
+	var errs []string
+	for _, repo := range s.repos {
+		start := time.Now()
+		err := repo.reader.Ping(ctx)
+		latency := time.Since(start)
+
+		if err != nil {
+			msg := err.Error()
+			if repo.name != "" {
+				msg = repo.name + ": " + msg
+			}
+			errs = append(errs, msg)
+			continue
+		}
+		slowest = max(slowest, latency)
+	}
+
+	if len(errs) > 0 {
+		health.Connected = false
+		health.Error = strings.Join(errs, "; ")
+	} else {
+		health.Latency = slowest.String()
 		s.lastPing = time.Now()
 	}
 
@@ -1,12 +1,23 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/powa-team/powa-sentinel/internal/config"
+	"github.com/powa-team/powa-sentinel/internal/model"
+	"github.com/powa-team/powa-sentinel/internal/notifier"
+	"github.com/powa-team/powa-sentinel/internal/reader"
+	"github.com/powa-team/powa-sentinel/internal/scheduler"
 )
 
 func TestHealthEndpoints(t *testing.T) {
@@ -123,3 +134,375 @@ func TestHealthResponse_JSON(t *testing.T) {
 		t.Error("Timestamp should not be zero")
 	}
 }
+
+func TestNotifierProbe_ToggleAvailability(t *testing.T) {
+	var available atomic.Bool
+	var posts, keyed atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			posts.Add(1)
+		}
+		if r.URL.Query().Get("key") != "" {
+			keyed.Add(1)
+		}
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed) // WeCom only accepts POST; the host is still up
+	}))
+	defer ts.Close()
+
+	wecom, err := notifier.NewWeComNotifier(&config.NotifierConfig{WebhookURL: ts.URL + "/cgi-bin/webhook/send?key=secret"})
+	if err != nil {
+		t.Fatalf("NewWeComNotifier() error = %v", err)
+	}
+	srv := New(&config.ServerConfig{}, nil)
+	srv.SetNotifierProbe("wecom", wecom, time.Minute)
+
+	status := func() StatusResponse {
+		w := httptest.NewRecorder()
+		srv.handleStatus(w, httptest.NewRequest("GET", "/status", nil))
+		var resp StatusResponse
+		if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding /status: %v", err)
+		}
+		return resp
+	}
+	metrics := func() string {
+		w := httptest.NewRecorder()
+		srv.handleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(w.Result().Body)
+		return string(body)
+	}
+
+	if resp := status(); resp.Notifier != nil || resp.Status != "ok" {
+		t.Errorf("before first probe: status = %q, notifier = %+v", resp.Status, resp.Notifier)
+	}
+
+	for _, up := range []bool{true, false, true} {
+		available.Store(up)
+		srv.probeNotifier(context.Background())
+
+		resp := status()
+		if resp.Notifier == nil || resp.Notifier.Reachable != up {
+			t.Fatalf("available=%v: notifier = %+v", up, resp.Notifier)
+		}
+		wantStatus, wantMetric := "ok", `powa_sentinel_notifier_up{notifier="wecom"} 1`
+		if !up {
+			wantStatus, wantMetric = "degraded", `powa_sentinel_notifier_up{notifier="wecom"} 0`
+			if strings.Contains(resp.Notifier.Error, "secret") {
+				t.Errorf("probe error leaks the webhook key: %s", resp.Notifier.Error)
+			}
+		}
+		if resp.Status != wantStatus {
+			t.Errorf("available=%v: status = %q, want %q", up, resp.Status, wantStatus)
+		}
+		if m := metrics(); !strings.Contains(m, wantMetric) {
+			t.Errorf("available=%v: metrics missing %q:\n%s", up, wantMetric, m)
+		}
+	}
+
+	if posts.Load() != 0 || keyed.Load() != 0 {
+		t.Errorf("probe sent %d non-HEAD request(s) and %d with the key; it must never post an alert", posts.Load(), keyed.Load())
+	}
+}
+
+// fakePauser implements Pauser with a plain flag.
+type fakePauser struct {
+	paused bool
+}
+
+func (p *fakePauser) Pause()         { p.paused = true }
+func (p *fakePauser) Resume()        { p.paused = false }
+func (p *fakePauser) IsPaused() bool { return p.paused }
+
+func TestPauseResume(t *testing.T) {
+	pauser := &fakePauser{}
+	srv := New(&config.ServerConfig{}, nil)
+	srv.SetPauser(pauser)
+
+	call := func(handler http.HandlerFunc, method, path string) (int, SchedulerState) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, path, nil))
+		var state SchedulerState
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Result().Body).Decode(&state); err != nil {
+				t.Fatalf("decoding %s: %v", path, err)
+			}
+		}
+		return w.Code, state
+	}
+	statusPaused := func() bool {
+		w := httptest.NewRecorder()
+		srv.handleStatus(w, httptest.NewRequest("GET", "/status", nil))
+		var resp StatusResponse
+		if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding /status: %v", err)
+		}
+		if resp.Scheduler == nil {
+			t.Fatal("/status has no scheduler state")
+		}
+		return resp.Scheduler.Paused
+	}
+
+	if statusPaused() {
+		t.Error("/status reports paused before /pause")
+	}
+
+	if code, state := call(srv.handlePause, "POST", "/pause"); code != http.StatusOK || !state.Paused {
+		t.Errorf("POST /pause = %d %+v, want 200 paused", code, state)
+	}
+	if !pauser.paused || !statusPaused() {
+		t.Error("scheduler should be paused after POST /pause")
+	}
+
+	// Only POST changes the state
+	if code, _ := call(srv.handleResume, "GET", "/resume"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /resume = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+	if !pauser.paused {
+		t.Error("GET /resume should not resume the scheduler")
+	}
+
+	if code, state := call(srv.handleResume, "POST", "/resume"); code != http.StatusOK || state.Paused {
+		t.Errorf("POST /resume = %d %+v, want 200 not paused", code, state)
+	}
+	if pauser.paused || statusPaused() {
+		t.Error("scheduler should run again after POST /resume")
+	}
+}
+
+// backoffPauser is a fakePauser whose scheduled runs back off.
+type backoffPauser struct {
+	fakePauser
+	states []scheduler.BackoffState
+}
+
+func (p *backoffPauser) Backoff() []scheduler.BackoffState { return p.states }
+
+func TestStatusBackoff(t *testing.T) {
+	next := time.Date(2026, 1, 1, 9, 4, 0, 0, time.UTC)
+	srv := New(&config.ServerConfig{}, nil)
+	srv.SetPauser(&backoffPauser{states: []scheduler.BackoffState{
+		{Repository: "eu", ConsecutiveFailures: 3, NextAttempt: next, LastError: "analysis failed: connection refused"},
+	}})
+
+	w := httptest.NewRecorder()
+	srv.handleStatus(w, httptest.NewRequest("GET", "/status", nil))
+	var resp StatusResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding /status: %v", err)
+	}
+	want := []RepositoryBackoff{{Repository: "eu", ConsecutiveFailures: 3, NextAttempt: next, LastError: "analysis failed: connection refused"}}
+	if resp.Scheduler == nil || !reflect.DeepEqual(resp.Scheduler.Backoff, want) {
+		t.Errorf("/status scheduler = %+v, want backoff %+v", resp.Scheduler, want)
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	srv := New(&config.ServerConfig{QueryMetrics: 2}, nil)
+	metrics := func() string {
+		w := httptest.NewRecorder()
+		srv.handleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(w.Result().Body)
+		return string(body)
+	}
+
+	if m := metrics(); strings.Contains(m, "powa_sentinel_query_") {
+		t.Errorf("query gauges before the first run:\n%s", m)
+	}
+
+	srv.RecordAlert(&model.AlertContext{TopSlowSQL: []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", ServerName: "main", MeanTime: 12.5, TotalTime: 1250},
+		{QueryID: 2, DatabaseName: "app", ServerName: "main", MeanTime: 3, TotalTime: 900},
+		{QueryID: 3, DatabaseName: "app", ServerName: "main", MeanTime: 1, TotalTime: 100},
+	}})
+	m := metrics()
+	for _, want := range []string{
+		`powa_sentinel_query_mean_time_ms{queryid="1",database="app",server="main"} 12.5`,
+		`powa_sentinel_query_total_time_ms{queryid="1",database="app",server="main"} 1250`,
+		`powa_sentinel_query_mean_time_ms{queryid="2",database="app",server="main"} 3`,
+		`powa_sentinel_query_total_time_ms{queryid="2",database="app",server="main"} 900`,
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("metrics missing %q:\n%s", want, m)
+		}
+	}
+	if strings.Contains(m, `queryid="3"`) {
+		t.Errorf("series beyond server.query_metrics should not be exported:\n%s", m)
+	}
+
+	// The next run replaces the series; queries that dropped out are gone
+	srv.RecordAlert(&model.AlertContext{TopSlowSQL: []model.MetricSnapshot{
+		{QueryID: 4, DatabaseName: "app", ServerName: "main", MeanTime: 7, TotalTime: 70},
+	}})
+	m = metrics()
+	if strings.Contains(m, `queryid="1"`) || strings.Contains(m, `queryid="2"`) {
+		t.Errorf("stale series still exported:\n%s", m)
+	}
+	if !strings.Contains(m, `powa_sentinel_query_mean_time_ms{queryid="4",database="app",server="main"} 7`) {
+		t.Errorf("metrics missing the new query:\n%s", m)
+	}
+}
+
+func TestQueryMetrics_Repositories(t *testing.T) {
+	srv := New(&config.ServerConfig{QueryMetrics: 1}, nil)
+	srv.RecordAlert(&model.AlertContext{Repository: "west", TopSlowSQL: []model.MetricSnapshot{
+		{QueryID: 2, DatabaseName: "app", ServerName: "main", MeanTime: 4, TotalTime: 40},
+	}})
+	srv.RecordAlert(&model.AlertContext{Repository: "east", TopSlowSQL: []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", ServerName: "main", MeanTime: 3, TotalTime: 30},
+	}})
+
+	w := httptest.NewRecorder()
+	srv.handleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	m := string(body)
+
+	// Each repository keeps its own series, in name order
+	east := strings.Index(m, `powa_sentinel_query_mean_time_ms{queryid="1",database="app",server="main",repository="east"} 3`)
+	west := strings.Index(m, `powa_sentinel_query_mean_time_ms{queryid="2",database="app",server="main",repository="west"} 4`)
+	if east < 0 || west < 0 || east > west {
+		t.Errorf("metrics should list both repositories' queries, east first:\n%s", m)
+	}
+}
+
+func TestQueryMetrics_GroupByUser(t *testing.T) {
+	srv := New(&config.ServerConfig{QueryMetrics: 2}, nil)
+	// analysis.group_by_user keeps one row per role of the same query
+	srv.RecordAlert(&model.AlertContext{TopSlowSQL: []model.MetricSnapshot{
+		{QueryID: 1, DatabaseName: "app", ServerName: "main", UserID: 10, MeanTime: 5, TotalTime: 500},
+		{QueryID: 1, DatabaseName: "app", ServerName: "main", UserID: 20, MeanTime: 2, TotalTime: 200},
+	}})
+
+	w := httptest.NewRecorder()
+	srv.handleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	m := string(body)
+
+	// Each role is its own series, so the scrape holds no duplicate samples
+	for _, want := range []string{
+		`powa_sentinel_query_mean_time_ms{queryid="1",database="app",server="main",userid="10"} 5`,
+		`powa_sentinel_query_mean_time_ms{queryid="1",database="app",server="main",userid="20"} 2`,
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("metrics missing %q:\n%s", want, m)
+		}
+	}
+}
+
+func TestCheckDatabase_Repositories(t *testing.T) {
+	srv := New(&config.ServerConfig{}, nil)
+	for _, name := range []string{"east", "west"} {
+		// Nothing listens on port 1: every ping is refused
+		r, err := reader.New(&config.DatabaseConfig{Host: "127.0.0.1", Port: 1, User: "powa", DBName: "powa", SSLMode: "disable"})
+		if err != nil {
+			t.Fatalf("reader.New() error = %v", err)
+		}
+		defer r.Close()
+		srv.AddRepository(name, r)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	health := srv.checkDatabase(ctx)
+	if health.Connected {
+		t.Fatal("checkDatabase() connected with no repository reachable")
+	}
+	if !strings.HasPrefix(health.Error, "east: ") || !strings.Contains(health.Error, "; west: ") {
+		t.Errorf("checkDatabase() error = %q, want one entry per repository", health.Error)
+	}
+
+	w := httptest.NewRecorder()
+	srv.handleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestPprofServer(t *testing.T) {
+	get := func(t *testing.T, h http.Handler, path string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		srv := New(&config.ServerConfig{Port: 0}, nil)
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		defer srv.Stop(context.Background())
+
+		if srv.pprof != nil {
+			t.Error("pprof server started without server.pprof_addr")
+		}
+		if code := get(t, srv.server.Handler, "/debug/pprof/"); code != http.StatusNotFound {
+			t.Errorf("health server /debug/pprof/ status = %d, want 404", code)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		srv := New(&config.ServerConfig{Port: 0, PprofAddr: "127.0.0.1:0"}, nil)
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		defer srv.Stop(context.Background())
+
+		if srv.pprof == nil {
+			t.Fatal("pprof server not started")
+		}
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap", "/debug/pprof/symbol"} {
+			if code := get(t, srv.pprof.Handler, path); code != http.StatusOK {
+				t.Errorf("pprof %s status = %d, want 200", path, code)
+			}
+		}
+		// Never on the health port
+		if code := get(t, srv.server.Handler, "/debug/pprof/"); code != http.StatusNotFound {
+			t.Errorf("health server /debug/pprof/ status = %d, want 404", code)
+		}
+	})
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Send(ctx context.Context, alert *model.AlertContext) error {
+	return errors.New("webhook returned 500")
+}
+
+func (failingNotifier) Name() string { return "failing" }
+
+func TestSendMetrics(t *testing.T) {
+	srv := New(&config.ServerConfig{}, nil)
+	sendMetrics := notifier.NewSendMetrics()
+	srv.SetSendMetrics(sendMetrics)
+	metrics := func() string {
+		w := httptest.NewRecorder()
+		srv.handleMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(w.Result().Body)
+		return string(body)
+	}
+
+	if m := metrics(); strings.Contains(m, "powa_sentinel_notify_") {
+		t.Errorf("send metrics before the first send:\n%s", m)
+	}
+
+	n := notifier.NewMeteredNotifier(failingNotifier{}, "wecom", sendMetrics)
+	_ = n.Send(context.Background(), &model.AlertContext{})
+
+	m := metrics()
+	for _, want := range []string{
+		"# TYPE powa_sentinel_notify_duration_seconds histogram",
+		`powa_sentinel_notify_duration_seconds_bucket{notifier="wecom",le="0.05"} 1`,
+		`powa_sentinel_notify_duration_seconds_bucket{notifier="wecom",le="+Inf"} 1`,
+		`powa_sentinel_notify_duration_seconds_count{notifier="wecom"} 1`,
+		"# TYPE powa_sentinel_notify_failures_total counter",
+		`powa_sentinel_notify_failures_total{notifier="wecom"} 1`,
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("metrics missing %q:\n%s", want, m)
+		}
+	}
+}